@@ -115,11 +115,15 @@ func annotateVariant(_ js.Value, args []js.Value) interface{} {
 		}}
 
 	case annotate.SpecProtein:
-		variants, err = annotate.ReverseMapProteinChange(txCache, spec.GeneName, spec.RefAA, spec.Position, spec.AltAA)
+		var warning string
+		variants, warning, err = annotate.ReverseMapProteinSpec(txCache, spec)
 		if err != nil {
 			return "Error: " + err.Error()
 		}
-		fmt.Fprintf(&header, "Query: %s %c%d%c\n", spec.GeneName, spec.RefAA, spec.Position, spec.AltAA)
+		fmt.Fprintf(&header, "Query: %s %s\n", spec.GeneName, spec.ProteinChange())
+		if warning != "" {
+			fmt.Fprintf(&header, "Warning: %s\n", warning)
+		}
 		fmt.Fprintf(&header, "Found %d genomic variant(s):\n", len(variants))
 		for _, v := range variants {
 			fmt.Fprintf(&header, "  %s:%d %s>%s\n", v.Chrom, v.Pos, v.Ref, v.Alt)
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"go.uber.org/zap"
+
+	"github.com/inodb/vibe-vep/internal/genomebuild"
+	"github.com/inodb/vibe-vep/internal/maf"
+	"github.com/inodb/vibe-vep/internal/vcf"
+)
+
+// assemblyAuto is the --assembly value that detects the build from the input.
+const assemblyAuto = "auto"
+
+// detectSampleSize is how many variants --assembly auto reads from the input.
+const detectSampleSize = 2000
+
+// Input formats accepted by resolveAssembly.
+const (
+	inputVCF = "vcf"
+	inputMAF = "maf"
+)
+
+// resolveAssembly returns assembly unchanged unless it is "auto", in which
+// case the build is detected from the input file: VCF ##reference/##contig
+// headers, MAF NCBI_Build values and positions past a chromosome end are
+// used first, so no transcript cache is loaded when they decide. Without
+// such evidence, sampled REF alleles are compared to the transcript
+// sequences of the installed assemblies one at a time, stopping at the first
+// whose sequences clearly match. Conflicting or inconclusive evidence is an
+// error.
+// When the REF alleles decide, the transcripts loaded for the detected
+// assembly are returned too, for loadCacheReusing; otherwise they are nil.
+func resolveAssembly(logger *zap.Logger, assembly, inputPath, format string) (string, *loadedTranscripts, error) {
+	if !strings.EqualFold(assembly, assemblyAuto) {
+		return assembly, nil, nil
+	}
+	if inputPath == "-" {
+		return "", nil, fmt.Errorf("--assembly auto cannot read stdin twice; pass --assembly GRCh37 or GRCh38")
+	}
+
+	evidence, sample, err := sampleInput(inputPath, format)
+	if err != nil {
+		return "", nil, err
+	}
+	detected, err := genomebuild.Decide(evidence)
+	if err != nil {
+		return "", nil, err
+	}
+	if detected != "" {
+		logger.Info("detected assembly from input", zap.String("assembly", detected), zap.Int("evidence", len(evidence)))
+		return detected, nil, nil
+	}
+
+	var results []genomebuild.Concordance
+	loaded := make(map[string]*loadedTranscripts)
+	for _, a := range genomebuild.Assemblies {
+		cacheDir := DefaultGENCODEPath(a)
+		if cacheDir == "" {
+			continue
+		}
+		c, ok, err := loadTranscripts(logger, a, cacheDir, false, false)
+		if err != nil {
+			logger.Debug("skipping assembly for REF sampling", zap.String("assembly", a), zap.Error(err))
+			continue
+		}
+		loaded[a] = &loadedTranscripts{cache: c, loaded: ok}
+		r := genomebuild.CheckConcordance(a, c, sample)
+		logger.Info("REF concordance", zap.String("assembly", a), zap.Int("checked", r.Checked), zap.Int("matched", r.Matched))
+		results = append(results, r)
+		if r.Conclusive() {
+			break // no need to load the other assembly
+		}
+	}
+	if len(results) == 0 {
+		return "", nil, fmt.Errorf("--assembly auto found no build information in %s and no installed assembly to compare against\nHint: Download with: vibe-vep download --assembly GRCh38", inputPath)
+	}
+	detected, err = genomebuild.DecideByConcordance(results)
+	if err != nil {
+		return "", nil, err
+	}
+	logger.Info("detected assembly from REF alleles", zap.String("assembly", detected))
+	return detected, loaded[detected], nil
+}
+
+// sampleInput reads the header and up to detectSampleSize variants of a VCF
+// or MAF file, returning build evidence and the sampled variants.
+func sampleInput(inputPath, format string) ([]genomebuild.Evidence, []*vcf.Variant, error) {
+	var evidence []genomebuild.Evidence
+	var sample []*vcf.Variant
+	addPosition := func(v *vcf.Variant) {
+		if e, ok := genomebuild.FromPosition(v.NormalizeChrom(), v.End()); ok {
+			evidence = append(evidence, e)
+		}
+		sample = append(sample, v)
+	}
+
+	switch format {
+	case inputVCF:
+		parser, err := vcf.NewParser(inputPath)
+		if err != nil {
+			return nil, nil, err
+		}
+		defer parser.Close()
+		evidence = genomebuild.FromVCFHeader(parser.Header())
+		for len(sample) < detectSampleSize {
+			v, err := parser.Next()
+			if err != nil {
+				return nil, nil, fmt.Errorf("reading variant: %w", err)
+			}
+			if v == nil {
+				break
+			}
+			addPosition(v)
+		}
+	case inputMAF:
+		parser, err := maf.NewParser(inputPath)
+		if err != nil {
+			return nil, nil, err
+		}
+		defer parser.Close()
+		for len(sample) < detectSampleSize {
+			v, mafAnn, err := parser.NextWithAnnotation()
+			if err != nil {
+				return nil, nil, fmt.Errorf("reading variant: %w", err)
+			}
+			if v == nil {
+				break
+			}
+			if a, ok := genomebuild.ParseName(mafAnn.NCBIBuild); ok {
+				evidence = append(evidence, genomebuild.Evidence{Assembly: a, Source: "NCBI_Build"})
+			}
+			addPosition(v)
+		}
+	default:
+		return nil, nil, fmt.Errorf("unknown input format %q", format)
+	}
+	return evidence, sample, nil
+}
@@ -2,6 +2,9 @@ package main
 
 import (
 	"bytes"
+	"compress/gzip"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 )
@@ -42,7 +45,7 @@ func TestVersionFlag(t *testing.T) {
 }
 
 func TestSubcommandHelp(t *testing.T) {
-	subcommands := []string{"annotate", "compare", "convert", "download", "export", "prepare", "version"}
+	subcommands := []string{"annotate", "compare", "convert", "download", "export", "filter", "hgvs", "prepare", "version"}
 
 	for _, sub := range subcommands {
 		t.Run(sub, func(t *testing.T) {
@@ -199,3 +202,106 @@ func TestPickAndMostSevereMutualExclusion(t *testing.T) {
 		t.Errorf("expected 'mutually exclusive' in error, got: %v", err)
 	}
 }
+
+func TestConvertLiftover(t *testing.T) {
+	dir := t.TempDir()
+	chain := filepath.Join(dir, "test.over.chain")
+	input := filepath.Join(dir, "input.vcf")
+	output := filepath.Join(dir, "lifted.vcf")
+	writeFile := func(path, content string) {
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeFile(chain, "chain 1000 chr1 1000 + 0 1000 chr1 2000 + 100 1090 1\n300 10 0\n690\n")
+	writeFile(input, "##fileformat=VCFv4.2\n##contig=<ID=1,length=1000>\n"+
+		"#CHROM\tPOS\tID\tREF\tALT\tQUAL\tFILTER\tINFO\n"+
+		"1\t11\t.\tA\tG\t.\tPASS\t.\n"+
+		"1\t12\t.\tC\tT\t0\tPASS\t.\n"+
+		"1\t305\t.\tC\tG\t.\tPASS\t.\n")
+
+	if _, _, err := executeCommand("convert", "liftover", "--chain", chain, "-o", output, input); err != nil {
+		t.Fatalf("convert liftover failed: %v", err)
+	}
+	lifted, err := os.ReadFile(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(lifted), "1\t111\t.\tA\tG\t.\t") {
+		t.Errorf("expected variant lifted to 1:111, got:\n%s", lifted)
+	}
+	if !strings.Contains(string(lifted), "1\t112\t.\tC\tT\t0\t") {
+		t.Errorf("expected QUAL 0 kept, got:\n%s", lifted)
+	}
+	if strings.Contains(string(lifted), "##contig") {
+		t.Errorf("source contig lines should be dropped, got:\n%s", lifted)
+	}
+	rejected, err := os.ReadFile(output + ".unmapped")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(rejected), "1\t305\t.\tC\tG\t.\tPASS\tLIFTOVER_REJECT=unmapped") {
+		t.Errorf("expected unmapped variant in reject file, got:\n%s", rejected)
+	}
+}
+
+func TestConvertLiftover_GzippedMAF(t *testing.T) {
+	dir := t.TempDir()
+	chain := filepath.Join(dir, "test.over.chain")
+	input := filepath.Join(dir, "input.maf.gz")
+	output := filepath.Join(dir, "lifted.maf")
+	if err := os.WriteFile(chain, []byte("chain 1000 chr1 1000 + 0 1000 chr1 2000 + 100 1090 1\n300 10 0\n690\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	zw.Write([]byte("Hugo_Symbol\tChromosome\tStart_Position\tEnd_Position\tReference_Allele\tTumor_Seq_Allele1\tTumor_Seq_Allele2\n" +
+		"GENE\t1\t11\t11\tA\tA\tG\n"))
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(input, buf.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, _, err := executeCommand("convert", "liftover", "--chain", chain, "-o", output, input); err != nil {
+		t.Fatalf("convert liftover failed: %v", err)
+	}
+	lifted, err := os.ReadFile(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(lifted), "Hugo_Symbol\t") {
+		t.Errorf("expected MAF output, got:\n%s", lifted)
+	}
+	if !strings.Contains(string(lifted), "GENE\t1\t111\t111\tA\tA\tG") {
+		t.Errorf("expected variant lifted to 1:111, got:\n%s", lifted)
+	}
+}
+
+func TestFilterMAF(t *testing.T) {
+	dir := t.TempDir()
+	input := filepath.Join(dir, "annotated.maf")
+	output := filepath.Join(dir, "filtered.maf")
+	if err := os.WriteFile(input, []byte("Hugo_Symbol\tvibe.impact\tvibe.gnomad.af\n"+
+		"KRAS\tMODERATE\t\n"+
+		"TP53\tHIGH\t0.2\n"+
+		"BRAF\tMODIFIER\t\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, _, err := executeCommand("filter", "-f", "IMPACT in HIGH,MODERATE", "-f", "not gnomad_af > 0.001", "-o", output, input); err != nil {
+		t.Fatalf("filter failed: %v", err)
+	}
+	got, err := os.ReadFile(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "Hugo_Symbol\tvibe.impact\tvibe.gnomad.af\nKRAS\tMODERATE\t\n"; string(got) != want {
+		t.Errorf("filtered output = %q, want %q", got, want)
+	}
+
+	if _, _, err := executeCommand("filter", "-f", "IMPACT in", input); err == nil {
+		t.Error("expected error for invalid filter expression")
+	}
+}
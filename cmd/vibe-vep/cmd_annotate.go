@@ -3,7 +3,9 @@ package main
 import (
 	"fmt"
 	"os"
+	"path/filepath"
 	"runtime"
+	"slices"
 	"strings"
 	"text/tabwriter"
 	"time"
@@ -12,6 +14,7 @@ import (
 
 	"github.com/inodb/vibe-vep/internal/annotate"
 	"github.com/inodb/vibe-vep/internal/duckdb"
+	"github.com/inodb/vibe-vep/internal/filter"
 	"github.com/inodb/vibe-vep/internal/maf"
 	"github.com/inodb/vibe-vep/internal/output"
 	"github.com/inodb/vibe-vep/internal/vcf"
@@ -44,6 +47,9 @@ func newAnnotateMAFCmd(verbose *bool) *cobra.Command {
 		mostSevere     bool
 		replace        bool
 		excludeColumns string
+		distance       int64
+		nearest        bool
+		reportNorm     bool
 	)
 
 	cmd := &cobra.Command{
@@ -53,10 +59,18 @@ func newAnnotateMAFCmd(verbose *bool) *cobra.Command {
 
 By default, annotations are appended as vibe.* namespaced columns.
 With --replace, core columns (Hugo_Symbol, Consequence, Variant_Classification,
-Transcript_ID, HGVSc, HGVSp, HGVSp_Short) are overwritten in-place.`,
+Transcript_ID, HGVSc, HGVSp, HGVSp_Short) are overwritten in-place.
+
+Each row reports the annotation on the MAF's own transcript when there is one,
+otherwise the best transcript of its gene ranked by --pick-order.
+
+With --merge-codons, SNVs of the same Tumor_Sample_Barcode that change the
+same codon are assumed in cis and also report their combined codon and
+protein change in the merged_* columns. Input must be sorted.`,
 		Example: `  vibe-vep annotate maf input.maf
   vibe-vep annotate maf -o output.maf input.maf
   vibe-vep annotate maf --replace -o annotated.maf input.maf
+  vibe-vep annotate maf --merge-codons input.maf
   vibe-vep annotate maf --save-results data_mutations.txt`,
 		Args: cobra.ExactArgs(1),
 		PreRunE: func(cmd *cobra.Command, args []string) error {
@@ -80,33 +94,37 @@ Transcript_ID, HGVSc, HGVSp, HGVSp_Short) are overwritten in-place.`,
 					return err
 				}
 			}
+			opts, err := annotateOptionsFromFlags(cmd)
+			if err != nil {
+				return err
+			}
+			opts.replace = viper.GetBool("replace")
+			opts.excludeCols = excludeCols
 			logger, err := newLogger(*verbose)
 			if err != nil {
 				return fmt.Errorf("creating logger: %w", err)
 			}
 			defer logger.Sync()
-			return runAnnotateMAF(logger, args[0],
-				viper.GetString("assembly"),
-				viper.GetString("output"),
-				viper.GetBool("canonical"),
-				viper.GetBool("save-results"),
-				viper.GetBool("no-cache"),
-				viper.GetBool("clear-cache"),
-				viper.GetBool("most-severe"),
-				viper.GetBool("replace"),
-				excludeCols,
-			)
+			return runAnnotateMAF(logger, args[0], opts)
 		},
 	}
 
-	cmd.Flags().StringVar(&assembly, "assembly", "GRCh38", "Genome assembly: GRCh37 or GRCh38")
+	cmd.Flags().StringVar(&assembly, "assembly", "GRCh38", "Genome assembly: GRCh37, GRCh38 or auto (detect from input)")
 	cmd.Flags().StringVarP(&outputFile, "output", "o", "", "Output file (default: stdout)")
 	cmd.Flags().BoolVar(&canonicalOnly, "canonical", false, "Only report canonical transcript annotations")
 	cmd.Flags().BoolVar(&saveResults, "save-results", false, "Save annotation results to DuckDB for later lookup")
 	cmd.Flags().BoolVar(&pick, "pick", false, "One annotation per variant (best transcript)")
 	cmd.Flags().BoolVar(&mostSevere, "most-severe", false, "One annotation per variant (highest impact)")
 	cmd.Flags().BoolVar(&replace, "replace", false, "Overwrite core MAF columns in-place instead of appending vibe.* columns")
+	addPickOrderFlag(cmd)
 	cmd.Flags().StringVar(&excludeColumns, "exclude-columns", "", "Comma-separated list of output columns to exclude (e.g. canonical_ensembl,all_effects)")
+	cmd.Flags().Int64Var(&distance, "distance", annotate.DefaultUpDownDistance, "Flank size in bases for upstream/downstream gene variants (0 disables)")
+	cmd.Flags().BoolVar(&nearest, "nearest", false, "Report the nearest gene, transcript and signed distance for intergenic variants")
+	cmd.Flags().BoolVar(&reportNorm, "report-normalization", false, "Report the original form of variants changed by --normalize")
+	addMergeCodonsFlag(cmd)
+	addFilterFlag(cmd)
+	addNormalizeFlags(cmd)
+	addLiftoverFlags(cmd)
 	addCacheFlags(cmd)
 
 	return cmd
@@ -119,16 +137,33 @@ func newAnnotateVCFCmd(verbose *bool) *cobra.Command {
 		canonicalOnly bool
 		saveResults   bool
 		pick          bool
+		flagPick      bool
 		mostSevere    bool
+		distance      int64
+		nearest       bool
+		reportNorm    bool
+		regionArgs    []string
+		regionsFile   string
 	)
 
 	cmd := &cobra.Command{
 		Use:   "vcf <file>",
 		Short: "Annotate variants in a VCF file",
-		Long:  "Annotate variants in a VCF file with consequence predictions.",
+		Long: `Annotate variants in a VCF file with consequence predictions.
+
+With --region or --regions-file, only records overlapping the regions are
+read from a bgzipped VCF with a tabix (.tbi) or CSI (.csi) index, seeking
+directly to the indexed blocks.
+
+With --merge-codons, SNVs changing the same codon on the same haplotype
+(phased GT in one phase set, or homozygous ALT) also report their combined
+codon and protein change in the MERGED_* CSQ fields. Input must be sorted.`,
 		Example: `  vibe-vep annotate vcf input.vcf
   vibe-vep annotate vcf -o output.vcf input.vcf
   vibe-vep annotate vcf --pick input.vcf
+  vibe-vep annotate vcf --flag-pick --pick-order mane_select,canonical_msk,biotype,impact,length input.vcf
+  vibe-vep annotate vcf --region 12:25200000-25300000 joint.vcf.gz
+  vibe-vep annotate vcf --merge-codons phased.vcf
   cat input.vcf | vibe-vep annotate vcf -`,
 		Args: cobra.ExactArgs(1),
 		PreRunE: func(cmd *cobra.Command, args []string) error {
@@ -138,30 +173,43 @@ func newAnnotateVCFCmd(verbose *bool) *cobra.Command {
 			if viper.GetBool("pick") && viper.GetBool("most-severe") {
 				return fmt.Errorf("--pick and --most-severe are mutually exclusive")
 			}
+			regions, err := parseRegions(regionArgs, viper.GetString("regions-file"))
+			if err != nil {
+				return err
+			}
+			opts, err := annotateOptionsFromFlags(cmd)
+			if err != nil {
+				return err
+			}
+			opts.pick = viper.GetBool("pick")
+			opts.flagPick = viper.GetBool("flag-pick")
+			opts.regions = regions
 			logger, err := newLogger(*verbose)
 			if err != nil {
 				return fmt.Errorf("creating logger: %w", err)
 			}
 			defer logger.Sync()
-			return runAnnotateVCF(logger, args[0],
-				viper.GetString("assembly"),
-				viper.GetString("output"),
-				viper.GetBool("canonical"),
-				viper.GetBool("save-results"),
-				viper.GetBool("no-cache"),
-				viper.GetBool("clear-cache"),
-				viper.GetBool("pick"),
-				viper.GetBool("most-severe"),
-			)
+			return runAnnotateVCF(logger, args[0], opts)
 		},
 	}
 
-	cmd.Flags().StringVar(&assembly, "assembly", "GRCh38", "Genome assembly: GRCh37 or GRCh38")
+	cmd.Flags().StringVar(&assembly, "assembly", "GRCh38", "Genome assembly: GRCh37, GRCh38 or auto (detect from input)")
 	cmd.Flags().StringVarP(&outputFile, "output", "o", "", "Output file (default: stdout)")
 	cmd.Flags().BoolVar(&canonicalOnly, "canonical", false, "Only report canonical transcript annotations")
 	cmd.Flags().BoolVar(&saveResults, "save-results", false, "Save annotation results to DuckDB for later lookup")
 	cmd.Flags().BoolVar(&pick, "pick", false, "One annotation per variant (best transcript)")
+	cmd.Flags().BoolVar(&flagPick, "flag-pick", false, "Keep all annotations and set the CSQ PICK field to 1 on the best transcript of each allele")
 	cmd.Flags().BoolVar(&mostSevere, "most-severe", false, "One annotation per variant (highest impact)")
+	addPickOrderFlag(cmd)
+	cmd.Flags().Int64Var(&distance, "distance", annotate.DefaultUpDownDistance, "Flank size in bases for upstream/downstream gene variants (0 disables)")
+	cmd.Flags().BoolVar(&nearest, "nearest", false, "Report the nearest gene, transcript and signed distance for intergenic variants")
+	cmd.Flags().BoolVar(&reportNorm, "report-normalization", false, "Report the original form of variants changed by --normalize")
+	addMergeCodonsFlag(cmd)
+	addFilterFlag(cmd)
+	addNormalizeFlags(cmd)
+	cmd.Flags().StringArrayVar(&regionArgs, "region", nil, "Only annotate records overlapping chrom[:start[-end]] (repeatable; needs a .tbi/.csi-indexed bgzipped VCF)")
+	cmd.Flags().StringVar(&regionsFile, "regions-file", "", "Only annotate records overlapping the regions in a BED file (needs a .tbi/.csi-indexed bgzipped VCF)")
+	addLiftoverFlags(cmd)
 	addCacheFlags(cmd)
 
 	return cmd
@@ -176,18 +224,41 @@ func newAnnotateVariantCmd(verbose *bool) *cobra.Command {
 	cmd := &cobra.Command{
 		Use:   "variant <spec>",
 		Short: "Annotate a single variant",
-		Long: `Annotate a single variant by genomic coordinates, protein change, HGVSc, or HGVSg notation.
+		Long: `Annotate a single variant by genomic coordinates, protein change, HGVSc, HGVSg notation or dbSNP rsID.
 
 Supported formats:
   Genomic:  12:25245350:C:A  or  chr12:25245350:C>A  or  12-25245350-C-A
-  Protein:  KRAS G12C  or  KRAS p.G12C  or  KRAS p.Gly12Cys
+  Protein:  KRAS G12C  or  KRAS p.G12C  or  KRAS p.Gly12Cys  or  TP53 R213X
+            EGFR E746_A750del  or  KRAS G12_G13dup  or  ERBB2 A775_G776insYVMA
+            EGFR L747_P753delinsS  or  TP53 R175fs  or  TP53 P72Rfs*14
   HGVSc:    KRAS c.35G>T  or  ENST00000311936:c.35G>T  or  KRAS c.34del
-  HGVSg:    5:g.1293968del  or  chr5:g.1293968C>T  or  5:g.1293968_1293970del`,
+            KRAS c.111+1G>A  or  KRAS c.-14C>T  or  KRAS c.*32del
+            KRAS c.76_77insT  or  KRAS c.100dup  or  KRAS c.100_102delinsAT
+            NM_004985.5:c.35G>T (with --transcript-set refseq or merged)
+  HGVSg:    5:g.1293968del  or  chr5:g.1293968C>T  or  5:g.1293968_1293970del
+  dbSNP:    rs121913529 (one annotation per ALT allele; needs the dbSNP genomic index)
+
+Protein changes are mapped on the gene's canonical transcript. Inframe
+deletions, duplications and insertions resolve to the CDS deletions or
+duplications giving the same protein; inserted residues that are not a
+duplication are back-translated. Frameshifts resolve to the 1-2 base
+deletions and 1 base insertions whose first changed residue matches. When
+several genomic variants qualify, each is annotated and a warning says so.
+
+HGVSc positions may be in the CDS, the 5'UTR (c.-14), the 3'UTR (c.*32) or
+an intron (c.111+1, c.112-2). Reference bases are checked against the
+transcript's CDS and 3'UTR sequence; intronic and 5'UTR bases need the
+reference genome (--reference), without which substitutions there are
+mapped unchecked and other changes fail.`,
 		Example: `  vibe-vep annotate variant 12:25245350:C:A
   vibe-vep annotate variant KRAS G12C
+  vibe-vep annotate variant EGFR E746_A750del
+  vibe-vep annotate variant TP53 R175fs
   vibe-vep annotate variant KRAS c.35G>T
   vibe-vep annotate variant ENST00000311936:c.35G>T
-  vibe-vep annotate variant 5:g.1293968del`,
+  vibe-vep annotate variant KRAS c.111+1G>A
+  vibe-vep annotate variant 5:g.1293968del
+  vibe-vep annotate variant rs121913529`,
 		Args: cobra.MinimumNArgs(1),
 		PreRunE: func(cmd *cobra.Command, args []string) error {
 			return viper.BindPFlags(cmd.Flags())
@@ -203,18 +274,78 @@ Supported formats:
 				viper.GetString("type"),
 				viper.GetBool("no-cache"),
 				viper.GetBool("clear-cache"),
+				normalizeOptionsFromViper(),
 			)
 		},
 	}
 
 	cmd.Flags().StringVar(&assembly, "assembly", "GRCh38", "Genome assembly: GRCh37 or GRCh38")
 	cmd.Flags().StringVar(&specType, "type", "", "Force variant type: genomic, protein, hgvsc, or hgvsg (auto-detected if not specified)")
+	addNormalizeFlags(cmd)
 	addCacheFlags(cmd)
 
 	return cmd
 }
 
-func runAnnotateMAF(logger *zap.Logger, inputPath, assembly, outputFile string, canonicalOnly, saveResults, noCache, clearCache, mostSevere, replace bool, excludeCols []string) error {
+// annotateOptions holds the settings of the annotate maf and annotate vcf
+// commands.
+type annotateOptions struct {
+	assembly      string
+	outputFile    string // empty for stdout
+	canonicalOnly bool
+	saveResults   bool
+	noCache       bool
+	clearCache    bool
+	pick          bool // vcf only; MAF rows always report one annotation
+	flagPick      bool // vcf only
+	mostSevere    bool
+	replace       bool     // maf only
+	excludeCols   []string // maf only
+	pickOrder     output.PickOrder
+	distance      int64
+	nearest       bool
+	mergeCodons   bool
+	filter        *filter.Expr // nil if no --filter
+	regions       []vcf.Region // vcf only
+	norm          normalizeOptions
+	liftover      liftoverOptions
+}
+
+// annotateOptionsFromFlags reads the settings shared by annotate maf and
+// annotate vcf; the caller sets the format-specific ones.
+func annotateOptionsFromFlags(cmd *cobra.Command) (annotateOptions, error) {
+	pickOrder, err := pickOrderFromViper()
+	if err != nil {
+		return annotateOptions{}, err
+	}
+	filterExpr, err := filterFromFlags(cmd)
+	if err != nil {
+		return annotateOptions{}, err
+	}
+	return annotateOptions{
+		assembly:      viper.GetString("assembly"),
+		outputFile:    viper.GetString("output"),
+		canonicalOnly: viper.GetBool("canonical"),
+		saveResults:   viper.GetBool("save-results"),
+		noCache:       viper.GetBool("no-cache"),
+		clearCache:    viper.GetBool("clear-cache"),
+		mostSevere:    viper.GetBool("most-severe"),
+		pickOrder:     pickOrder,
+		distance:      viper.GetInt64("distance"),
+		nearest:       viper.GetBool("nearest"),
+		mergeCodons:   viper.GetBool("merge-codons"),
+		filter:        filterExpr,
+		norm:          normalizeOptionsFromViper(),
+		liftover:      liftoverOptionsFromViper(),
+	}, nil
+}
+
+func runAnnotateMAF(logger *zap.Logger, inputPath string, opts annotateOptions) error {
+	assembly, transcripts, err := resolveLiftoverAssembly(logger, opts.assembly, inputPath, inputMAF, opts.liftover)
+	if err != nil {
+		return err
+	}
+
 	parser, err := maf.NewParser(inputPath)
 	if err != nil {
 		if os.IsNotExist(err) {
@@ -224,7 +355,7 @@ func runAnnotateMAF(logger *zap.Logger, inputPath, assembly, outputFile string,
 	}
 	defer parser.Close()
 
-	cr, err := loadCache(logger, assembly, noCache, clearCache)
+	cr, err := loadCacheReusing(logger, assembly, transcripts, opts.noCache, opts.clearCache)
 	if err != nil {
 		return err
 	}
@@ -234,14 +365,29 @@ func runAnnotateMAF(logger *zap.Logger, inputPath, assembly, outputFile string,
 	defer cr.closeSources()
 
 	ann := annotate.NewAnnotator(cr.cache)
-	ann.SetCanonicalOnly(canonicalOnly)
+	ann.SetCanonicalOnly(opts.canonicalOnly)
+	ann.SetUpDownDistance(opts.distance)
+	ann.SetNearest(opts.nearest)
 	ann.SetLogger(logger)
+	ref, err := configureNormalization(logger, ann, assembly, opts.norm)
+	if err != nil {
+		return err
+	}
+	if ref != nil {
+		defer ref.Close()
+	}
+	defer warnRefMismatches(logger, ann, assembly)
+	liftFrom, _, err := configureLiftover(logger, ann, assembly, opts.liftover)
+	if err != nil {
+		return err
+	}
+	defer warnLiftFailures(logger, ann, liftFrom, assembly)
 
 	var out *os.File
-	if outputFile == "" {
+	if opts.outputFile == "" {
 		out = os.Stdout
 	} else {
-		out, err = os.Create(outputFile)
+		out, err = os.Create(opts.outputFile)
 		if err != nil {
 			return fmt.Errorf("creating output file: %w", err)
 		}
@@ -250,11 +396,16 @@ func runAnnotateMAF(logger *zap.Logger, inputPath, assembly, outputFile string,
 
 	var variantResults []duckdb.VariantResult
 	var collectResults *[]duckdb.VariantResult
-	if saveResults && cr.store != nil {
+	if opts.saveResults && cr.store != nil {
 		collectResults = &variantResults
 	}
 
-	if err := runMAFOutput(logger, parser, ann, out, cr.sources, collectResults, mostSevere, replace, excludeCols); err != nil {
+	var merger *annotate.CodonMerger
+	if opts.mergeCodons {
+		merger = annotate.NewCodonMerger(cr.cache)
+	}
+
+	if err := runMAFOutput(logger, parser, ann, out, cr.sources, cr.transcriptSets, collectResults, merger, opts); err != nil {
 		return err
 	}
 
@@ -272,8 +423,13 @@ func runAnnotateMAF(logger *zap.Logger, inputPath, assembly, outputFile string,
 	return nil
 }
 
-func runAnnotateVCF(logger *zap.Logger, inputPath, assembly, outputFile string, canonicalOnly, saveResults, noCache, clearCache, pick, mostSevere bool) error {
-	parser, err := vcf.NewParser(inputPath)
+func runAnnotateVCF(logger *zap.Logger, inputPath string, opts annotateOptions) error {
+	assembly, transcripts, err := resolveLiftoverAssembly(logger, opts.assembly, inputPath, inputVCF, opts.liftover)
+	if err != nil {
+		return err
+	}
+
+	parser, err := openVCF(logger, inputPath, opts.regions)
 	if err != nil {
 		if os.IsNotExist(err) {
 			return fmt.Errorf("%w (check that the file path is correct)", err)
@@ -282,7 +438,7 @@ func runAnnotateVCF(logger *zap.Logger, inputPath, assembly, outputFile string,
 	}
 	defer parser.Close()
 
-	cr, err := loadCache(logger, assembly, noCache, clearCache)
+	cr, err := loadCacheReusing(logger, assembly, transcripts, opts.noCache, opts.clearCache)
 	if err != nil {
 		return err
 	}
@@ -292,28 +448,83 @@ func runAnnotateVCF(logger *zap.Logger, inputPath, assembly, outputFile string,
 	defer cr.closeSources()
 
 	ann := annotate.NewAnnotator(cr.cache)
-	ann.SetCanonicalOnly(canonicalOnly)
+	ann.SetCanonicalOnly(opts.canonicalOnly)
+	ann.SetUpDownDistance(opts.distance)
+	ann.SetNearest(opts.nearest)
 	ann.SetLogger(logger)
+	ref, err := configureNormalization(logger, ann, assembly, opts.norm)
+	if err != nil {
+		return err
+	}
+	if ref != nil {
+		defer ref.Close()
+	}
+	defer warnRefMismatches(logger, ann, assembly)
+	liftFrom, chainPath, err := configureLiftover(logger, ann, assembly, opts.liftover)
+	if err != nil {
+		return err
+	}
+	defer warnLiftFailures(logger, ann, liftFrom, assembly)
 
 	var out *os.File
-	if outputFile == "" {
+	if opts.outputFile == "" {
 		out = os.Stdout
 	} else {
-		out, err = os.Create(outputFile)
+		out, err = os.Create(opts.outputFile)
 		if err != nil {
 			return fmt.Errorf("creating output file: %w", err)
 		}
 		defer out.Close()
 	}
 
-	writer := output.NewVCFWriter(out, parser.Header())
+	header := parser.Header()
+	if liftFrom != "" {
+		header = liftoverVCFHeader(header, liftFrom, assembly, filepath.Base(chainPath))
+	}
+	writer := output.NewVCFWriter(out, header)
 	writer.SetSources(cr.sources)
+	writer.SetNearest(opts.nearest)
+	writer.SetReportNormalization(opts.norm.report)
+	writer.SetRefCheck(opts.norm.checkRef)
+	writer.SetTranscriptSets(cr.transcriptSets)
+	writer.SetMergeCodons(opts.mergeCodons)
+	writer.SetFilter(opts.filter)
+	if opts.flagPick {
+		writer.SetFlagPick(opts.pickOrder)
+	}
 	if err := writer.WriteHeader(); err != nil {
 		return fmt.Errorf("writing header: %w", err)
 	}
 
-	if len(cr.sources) > 0 || pick || mostSevere {
-		for {
+	if len(cr.sources) > 0 || opts.pick || opts.mostSevere || opts.mergeCodons {
+		write := func(r annotate.WorkResult) error {
+			anns := r.Anns
+			for _, src := range cr.sources {
+				src.Annotate(r.Variant, anns)
+			}
+
+			// Apply pick/most-severe filtering
+			if opts.pick && len(anns) > 1 {
+				anns = []*annotate.Annotation{opts.pickOrder.Pick(anns)}
+			} else if opts.mostSevere && len(anns) > 1 {
+				anns = []*annotate.Annotation{opts.pickOrder.MostSevere(anns)}
+			}
+
+			for _, a := range anns {
+				if err := writer.Write(r.Variant, a); err != nil {
+					return fmt.Errorf("writing annotation: %w", err)
+				}
+			}
+			return nil
+		}
+
+		// Merged codons are set on the annotations of every transcript, so
+		// the merger runs before pick/most-severe filtering.
+		var merger *annotate.CodonMerger
+		if opts.mergeCodons {
+			merger = annotate.NewCodonMerger(cr.cache)
+		}
+		for seq := 0; ; seq++ {
 			v, err := parser.Next()
 			if err != nil {
 				return fmt.Errorf("reading variant: %w", err)
@@ -326,20 +537,27 @@ func runAnnotateVCF(logger *zap.Logger, inputPath, assembly, outputFile string,
 				logger.Warn("annotation failed", zap.Error(err))
 				continue
 			}
-			for _, src := range cr.sources {
-				src.Annotate(v, anns)
+			r := annotate.WorkResult{Seq: seq, Variant: v, Anns: anns}
+			if merger == nil {
+				if err := write(r); err != nil {
+					return err
+				}
+				continue
 			}
-
-			// Apply pick/most-severe filtering
-			if pick && len(anns) > 1 {
-				anns = []*annotate.Annotation{output.PickBestAnnotation(anns)}
-			} else if mostSevere && len(anns) > 1 {
-				anns = []*annotate.Annotation{output.PickMostSevere(anns)}
+			released, err := merger.Add(r, annotate.VCFHaplotypes(v))
+			if err != nil {
+				return fmt.Errorf("--merge-codons: %w", err)
 			}
-
-			for _, a := range anns {
-				if err := writer.Write(v, a); err != nil {
-					return fmt.Errorf("writing annotation: %w", err)
+			for _, ready := range released {
+				if err := write(ready); err != nil {
+					return err
+				}
+			}
+		}
+		if merger != nil {
+			for _, ready := range merger.Flush() {
+				if err := write(ready); err != nil {
+					return err
 				}
 			}
 		}
@@ -349,7 +567,7 @@ func runAnnotateVCF(logger *zap.Logger, inputPath, assembly, outputFile string,
 	return ann.AnnotateAll(parser, writer)
 }
 
-func runAnnotateVariant(logger *zap.Logger, specInput, assembly, specType string, noCache, clearCache bool) error {
+func runAnnotateVariant(logger *zap.Logger, specInput, assembly, specType string, noCache, clearCache bool, norm normalizeOptions) error {
 	// Parse variant specification
 	spec, err := annotate.ParseVariantSpec(specInput)
 	if err != nil {
@@ -384,6 +602,13 @@ func runAnnotateVariant(logger *zap.Logger, specInput, assembly, specType string
 
 	ann := annotate.NewAnnotator(cr.cache)
 	ann.SetLogger(logger)
+	ref, err := configureNormalization(logger, ann, assembly, norm)
+	if err != nil {
+		return err
+	}
+	if ref != nil {
+		defer ref.Close()
+	}
 
 	// Convert spec to genomic variant(s)
 	var variants []*vcf.Variant
@@ -397,11 +622,15 @@ func runAnnotateVariant(logger *zap.Logger, specInput, assembly, specType string
 		}}
 
 	case annotate.SpecProtein:
-		variants, err = annotate.ReverseMapProteinChange(cr.cache, spec.GeneName, spec.RefAA, spec.Position, spec.AltAA)
+		var warning string
+		variants, warning, err = annotate.ReverseMapProteinSpec(cr.cache, spec)
 		if err != nil {
 			return err
 		}
-		fmt.Fprintf(os.Stderr, "Query: %s %c%d%c\n\n", spec.GeneName, spec.RefAA, spec.Position, spec.AltAA)
+		if warning != "" {
+			fmt.Fprintf(os.Stderr, "Warning: %s\n\n", warning)
+		}
+		fmt.Fprintf(os.Stderr, "Query: %s %s\n\n", spec.GeneName, spec.ProteinChange())
 		fmt.Fprintf(os.Stderr, "Found %d genomic variant(s):\n", len(variants))
 		for _, v := range variants {
 			fmt.Fprintf(os.Stderr, "  %s:%d %s>%s\n", v.Chrom, v.Pos, v.Ref, v.Alt)
@@ -410,7 +639,7 @@ func runAnnotateVariant(logger *zap.Logger, specInput, assembly, specType string
 
 	case annotate.SpecHGVSc:
 		var warning string
-		variants, warning, err = annotate.ReverseMapHGVScWithWarning(cr.cache, spec.TranscriptID, spec.CDSChange)
+		variants, warning, err = annotate.ReverseMapHGVScWithReference(cr.cache, ann.Reference(), spec.TranscriptID, spec.CDSChange)
 		if err != nil {
 			return err
 		}
@@ -442,6 +671,18 @@ func runAnnotateVariant(logger *zap.Logger, specInput, assembly, specType string
 			fmt.Fprintf(os.Stderr, "  %s:%d %s>%s\n", v.Chrom, v.Pos, v.Ref, v.Alt)
 		}
 		fmt.Fprintln(os.Stderr)
+
+	case annotate.SpecRSID:
+		variants, err = annotate.ResolveRSID(cr.sources, spec.RSID)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(os.Stderr, "Query: %s\n\n", spec.RSID)
+		fmt.Fprintf(os.Stderr, "Found %d genomic variant(s):\n", len(variants))
+		for _, v := range variants {
+			fmt.Fprintf(os.Stderr, "  %s:%d %s>%s\n", v.Chrom, v.Pos, v.Ref, v.Alt)
+		}
+		fmt.Fprintln(os.Stderr)
 	}
 
 	hasSources := len(cr.sources) > 0
@@ -457,6 +698,12 @@ func runAnnotateVariant(logger *zap.Logger, specInput, assembly, specType string
 			logger.Warn("annotation failed", zap.Error(err))
 			continue
 		}
+		if len(anns) > 0 && anns[0].RefMismatch {
+			fmt.Fprintf(os.Stderr, "Warning: REF allele %s does not match the %s reference\n\n", v.Ref, assembly)
+		}
+		if v.Original != nil {
+			fmt.Fprintf(os.Stdout, "Normalized to: %s:%d %s>%s\n\n", v.Chrom, v.Pos, v.Ref, v.Alt)
+		}
 
 		for _, src := range cr.sources {
 			src.Annotate(v, anns)
@@ -533,12 +780,18 @@ func runAnnotateVariant(logger *zap.Logger, specInput, assembly, specType string
 }
 
 // runMAFOutput runs MAF annotation mode, preserving all original columns.
-func runMAFOutput(logger *zap.Logger, parser *maf.Parser, ann *annotate.Annotator, out *os.File, sources []annotate.AnnotationSource, newResults *[]duckdb.VariantResult, mostSevere, replace bool, excludeCols []string) error {
+func runMAFOutput(logger *zap.Logger, parser *maf.Parser, ann *annotate.Annotator, out *os.File, sources []annotate.AnnotationSource, transcriptSets []string, newResults *[]duckdb.VariantResult, merger *annotate.CodonMerger, opts annotateOptions) error {
 	mafWriter := output.NewMAFWriter(out, parser.Header(), parser.Columns())
 	mafWriter.SetSources(sources)
-	mafWriter.SetReplace(replace)
-	if len(excludeCols) > 0 {
-		mafWriter.SetExcludeColumns(excludeCols)
+	mafWriter.SetReplace(opts.replace)
+	mafWriter.SetNearest(opts.nearest)
+	mafWriter.SetReportNormalization(opts.norm.report)
+	mafWriter.SetRefCheck(opts.norm.checkRef)
+	mafWriter.SetTranscriptSets(transcriptSets)
+	mafWriter.SetMergeCodons(merger != nil)
+	mafWriter.SetFilter(opts.filter)
+	if len(opts.excludeCols) > 0 {
+		mafWriter.SetExcludeColumns(opts.excludeCols)
 	}
 
 	if err := mafWriter.WriteHeader(); err != nil {
@@ -571,7 +824,7 @@ func runMAFOutput(logger *zap.Logger, parser *maf.Parser, ann *annotate.Annotato
 		logger.Info("progress", zap.Int("variants_processed", n))
 	}
 
-	if err := annotate.OrderedCollectWithProgress(results, 2*time.Second, progress, func(r annotate.WorkResult) error {
+	writeResult := func(r annotate.WorkResult) error {
 		mafAnn := r.Extra.(*maf.MAFAnnotation)
 		if r.Err != nil {
 			logger.Warn("failed to annotate variant",
@@ -592,10 +845,10 @@ func runMAFOutput(logger *zap.Logger, parser *maf.Parser, ann *annotate.Annotato
 		}
 
 		var best *annotate.Annotation
-		if mostSevere {
-			best = output.PickMostSevere(r.Anns)
+		if opts.mostSevere {
+			best = opts.pickOrder.MostSevere(r.Anns)
 		} else {
-			best = output.SelectBestAnnotation(mafAnn, r.Anns)
+			best = opts.pickOrder.SelectBest(mafAnn, r.Anns)
 		}
 		// Enrich best annotation with annotation sources
 		if best != nil {
@@ -604,9 +857,33 @@ func runMAFOutput(logger *zap.Logger, parser *maf.Parser, ann *annotate.Annotato
 			}
 		}
 		return mafWriter.WriteRow(mafAnn.RawFields, best, r.Anns, r.Variant)
+	}
+
+	if err := annotate.OrderedCollectWithProgress(results, 2*time.Second, progress, func(r annotate.WorkResult) error {
+		if merger == nil {
+			return writeResult(r)
+		}
+		mafAnn := r.Extra.(*maf.MAFAnnotation)
+		released, err := merger.Add(r, annotate.MAFHaplotypes(mafAnn.TumorSampleBarcode))
+		if err != nil {
+			return fmt.Errorf("--merge-codons: %w", err)
+		}
+		for _, ready := range released {
+			if err := writeResult(ready); err != nil {
+				return err
+			}
+		}
+		return nil
 	}); err != nil {
 		return err
 	}
+	if merger != nil {
+		for _, ready := range merger.Flush() {
+			if err := writeResult(ready); err != nil {
+				return err
+			}
+		}
+	}
 
 	if parseErr != nil {
 		return parseErr
@@ -615,6 +892,96 @@ func runMAFOutput(logger *zap.Logger, parser *maf.Parser, ann *annotate.Annotato
 	return mafWriter.Flush()
 }
 
+// addMergeCodonsFlag adds --merge-codons, which reports the combined change
+// of SNVs in the same codon on the same haplotype.
+func addMergeCodonsFlag(cmd *cobra.Command) {
+	cmd.Flags().Bool("merge-codons", false, "Report the combined codon/protein change of SNVs in the same codon on the same haplotype (phased GT/PS, or same MAF Tumor_Sample_Barcode); input must be sorted by position")
+}
+
+// addFilterFlag adds --filter, which keeps only the output records matching
+// a filter expression (see `vibe-vep filter`).
+func addFilterFlag(cmd *cobra.Command) {
+	cmd.Flags().StringArray("filter", nil, "Only write records matching a filter expression, e.g. 'IMPACT in HIGH,MODERATE and gnomad_af < 0.001' (repeatable, combined with and; see vibe-vep filter --help)")
+}
+
+// filterFromFlags parses the --filter expressions, nil if none.
+func filterFromFlags(cmd *cobra.Command) (*filter.Expr, error) {
+	exprs, err := cmd.Flags().GetStringArray("filter")
+	if err != nil {
+		return nil, err
+	}
+	expr, err := filter.Parse(exprs...)
+	if err != nil {
+		return nil, fmt.Errorf("--filter: %w", err)
+	}
+	return expr, nil
+}
+
+// addPickOrderFlag adds --pick-order, which can also be set as pick-order in
+// ~/.vibe-vep.yaml.
+func addPickOrderFlag(cmd *cobra.Command) {
+	cmd.Flags().String("pick-order", "", "Comma-separated transcript ranking used to pick one annotation per variant: mane_select, canonical_msk, canonical_ensembl, biotype, impact, hgvsp, length, set:<name> (a configured transcript set) (default: "+output.DefaultPickOrder.String()+")")
+}
+
+// pickOrderFromViper parses the configured pick order (nil if unset),
+// checking that set:<name> criteria refer to configured transcript sets.
+func pickOrderFromViper() (output.PickOrder, error) {
+	order, err := output.ParsePickOrder(viper.GetString("pick-order"))
+	if err != nil {
+		return nil, fmt.Errorf("--pick-order: %w", err)
+	}
+	sets := transcriptSetNames()
+	for _, c := range order {
+		if name, ok := c.TranscriptSet(); ok && !slices.Contains(sets, name) {
+			return nil, fmt.Errorf("--pick-order: transcript set %q is not configured under transcript-sets", name)
+		}
+	}
+	return order, nil
+}
+
+// parseRegions collects --region values and --regions-file BED intervals.
+func parseRegions(args []string, bedPath string) ([]vcf.Region, error) {
+	var regions []vcf.Region
+	for _, arg := range args {
+		r, err := vcf.ParseRegion(arg)
+		if err != nil {
+			return nil, err
+		}
+		regions = append(regions, r)
+	}
+	if bedPath != "" {
+		bed, err := vcf.ReadBEDRegions(bedPath)
+		if err != nil {
+			return nil, err
+		}
+		if len(bed) == 0 {
+			return nil, fmt.Errorf("no regions in %s", bedPath)
+		}
+		regions = append(regions, bed...)
+	}
+	return regions, nil
+}
+
+// openVCF opens a VCF for streaming, or for an indexed query when regions
+// are given. Regions on chromosomes missing from the index are logged.
+func openVCF(logger *zap.Logger, inputPath string, regions []vcf.Region) (*vcf.Parser, error) {
+	if len(regions) == 0 {
+		return vcf.NewParser(inputPath)
+	}
+	if inputPath == "-" {
+		return nil, fmt.Errorf("region queries need an indexed bgzipped VCF file, not stdin")
+	}
+	parser, err := vcf.NewRegionParser(inputPath, regions)
+	if err != nil {
+		return nil, err
+	}
+	if unresolved := parser.UnresolvedRegionChroms(); len(unresolved) > 0 {
+		logger.Warn("regions on chromosomes not in the VCF index are skipped",
+			zap.Strings("chromosomes", unresolved))
+	}
+	return parser, nil
+}
+
 // yesNo returns "yes" if b is true, "no" otherwise.
 func yesNo(b bool) string {
 	if b {
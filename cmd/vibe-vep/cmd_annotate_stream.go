@@ -21,6 +21,7 @@ func newAnnotateStreamCmd(verbose *bool) *cobra.Command {
 		assembly     string
 		inputFormat  string
 		outputFormat string
+		nearest      bool
 	)
 
 	cmd := &cobra.Command{
@@ -62,6 +63,8 @@ Supported output formats:
 				viper.GetString("output-format"),
 				viper.GetBool("no-cache"),
 				viper.GetBool("clear-cache"),
+				viper.GetBool("nearest"),
+				normalizeOptionsFromViper(),
 			)
 		},
 	}
@@ -69,12 +72,14 @@ Supported output formats:
 	cmd.Flags().StringVar(&assembly, "assembly", "GRCh38", "Genome assembly: GRCh37 or GRCh38")
 	cmd.Flags().StringVar(&inputFormat, "input-format", "genome-nexus-genomic-location-jsonl", "Input format")
 	cmd.Flags().StringVar(&outputFormat, "output-format", "ensembl-vep-jsonl", "Output format: ensembl-vep-jsonl or vibe-vep-jsonl")
+	cmd.Flags().BoolVar(&nearest, "nearest", false, "Report the nearest gene, transcript and signed distance for intergenic variants")
+	addNormalizeFlags(cmd)
 	addCacheFlags(cmd)
 
 	return cmd
 }
 
-func runAnnotateStream(logger *zap.Logger, assembly, inputFmt, outputFmt string, noCache, clearCache bool) error {
+func runAnnotateStream(logger *zap.Logger, assembly, inputFmt, outputFmt string, noCache, clearCache, nearest bool, norm normalizeOptions) error {
 	// Validate formats.
 	switch inputFmt {
 	case "genome-nexus-genomic-location-jsonl":
@@ -105,7 +110,16 @@ func runAnnotateStream(logger *zap.Logger, assembly, inputFmt, outputFmt string,
 	defer cr.closeSources()
 
 	ann := annotate.NewAnnotator(cr.cache)
+	ann.SetNearest(nearest)
 	ann.SetLogger(logger)
+	ref, err := configureNormalization(logger, ann, assembly, norm)
+	if err != nil {
+		return err
+	}
+	if ref != nil {
+		defer ref.Close()
+	}
+	defer warnRefMismatches(logger, ann, assembly)
 
 	writer := output.NewJSONLWriter(os.Stdout, outputFmt, assembly)
 
@@ -146,7 +160,7 @@ func runAnnotateStream(logger *zap.Logger, assembly, inputFmt, outputFmt string,
 			variants = []*vcf.Variant{gl.ToVariant()}
 			inputLabel = gl.FormatInput()
 		} else {
-			// Plain string: try as variant spec (genomic coords, HGVSc, HGVSg, protein)
+			// Plain string: try as variant spec (genomic coords, HGVSc, HGVSg, protein, rsID)
 			spec, err := annotate.ParseVariantSpec(lineStr)
 			if err != nil {
 				logger.Warn("parse error", zap.Error(err), zap.String("input", lineStr))
@@ -160,14 +174,20 @@ func runAnnotateStream(logger *zap.Logger, assembly, inputFmt, outputFmt string,
 				variants = []*vcf.Variant{{Chrom: spec.Chrom, Pos: spec.Pos, Ref: spec.Ref, Alt: spec.Alt}}
 			case annotate.SpecHGVSc:
 				var warning string
-				variants, warning, err = annotate.ReverseMapHGVScWithWarning(cr.cache, spec.TranscriptID, spec.CDSChange)
+				variants, warning, err = annotate.ReverseMapHGVScWithReference(cr.cache, ann.Reference(), spec.TranscriptID, spec.CDSChange)
 				if warning != "" {
 					writer.AddWarning(warning)
 				}
 			case annotate.SpecHGVSg:
 				variants, err = annotate.ResolveHGVSg(cr.cache, spec.Chrom, spec.GenomicChange)
+			case annotate.SpecRSID:
+				variants, err = annotate.ResolveRSID(cr.sources, spec.RSID)
 			case annotate.SpecProtein:
-				variants, err = annotate.ReverseMapProteinChange(cr.cache, spec.GeneName, spec.RefAA, spec.Position, spec.AltAA)
+				var warning string
+				variants, warning, err = annotate.ReverseMapProteinSpec(cr.cache, spec)
+				if warning != "" {
+					writer.AddWarning(warning)
+				}
 			}
 			if err != nil {
 				logger.Warn("resolve error", zap.Error(err), zap.String("input", lineStr))
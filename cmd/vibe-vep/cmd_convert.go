@@ -9,6 +9,7 @@ import (
 	"go.uber.org/zap"
 
 	"github.com/inodb/vibe-vep/internal/annotate"
+	"github.com/inodb/vibe-vep/internal/filter"
 	"github.com/inodb/vibe-vep/internal/output"
 	"github.com/inodb/vibe-vep/internal/vcf"
 	"github.com/spf13/cobra"
@@ -19,10 +20,11 @@ func newConvertCmd(verbose *bool) *cobra.Command {
 	cmd := &cobra.Command{
 		Use:   "convert",
 		Short: "Convert between variant file formats",
-		Long:  "Convert between variant file formats (e.g., VCF to MAF).",
+		Long:  "Convert between variant file formats (e.g., VCF to MAF) and lift coordinates between assemblies.",
 	}
 
 	cmd.AddCommand(newVCF2MAFCmd(verbose))
+	cmd.AddCommand(newLiftoverCmd(verbose))
 
 	return cmd
 }
@@ -33,20 +35,43 @@ func newVCF2MAFCmd(verbose *bool) *cobra.Command {
 		outputFile    string
 		canonicalOnly bool
 		saveResults   bool
+		distance      int64
+		tumorID       string
+		normalID      string
 	)
 
 	cmd := &cobra.Command{
 		Use:   "vcf2maf <input.vcf>",
 		Short: "Convert VCF to MAF format",
-		Long:  "Convert a VCF file to MAF format with consequence annotations.",
+		Long: `Convert a VCF file to MAF format with consequence annotations.
+
+Read counts (t_depth, t_ref_count, t_alt_count and the n_* columns) are filled
+from the AD and DP FORMAT fields; depths stay empty without DP. The tumor and normal samples are taken from
+--tumor-id/--normal-id, else from ##tumor_sample/##normal_sample header lines.
+A single-sample VCF uses that sample as the tumor; a multi-sample VCF without
+a tumor sample (e.g. germline calls) gives one row per sample carrying the ALT
+allele, skipping no-calls (./.).
+
+Each row reports the best transcript ranked by --pick-order; all_effects lists
+every transcript.`,
 		Example: `  vibe-vep convert vcf2maf input.vcf
   vibe-vep convert vcf2maf -o output.maf input.vcf
-  vibe-vep convert vcf2maf --assembly GRCh37 input.vcf`,
+  vibe-vep convert vcf2maf --tumor-id PATIENT1_T --normal-id PATIENT1_N somatic.vcf
+  vibe-vep convert vcf2maf --assembly GRCh37 input.vcf
+  vibe-vep convert vcf2maf --merge-codons phased.vcf`,
 		Args: cobra.ExactArgs(1),
 		PreRunE: func(cmd *cobra.Command, args []string) error {
 			return viper.BindPFlags(cmd.Flags())
 		},
 		RunE: func(cmd *cobra.Command, args []string) error {
+			pickOrder, err := pickOrderFromViper()
+			if err != nil {
+				return err
+			}
+			filterExpr, err := filterFromFlags(cmd)
+			if err != nil {
+				return err
+			}
 			logger, err := newLogger(*verbose)
 			if err != nil {
 				return fmt.Errorf("creating logger: %w", err)
@@ -59,20 +84,39 @@ func newVCF2MAFCmd(verbose *bool) *cobra.Command {
 				viper.GetBool("save-results"),
 				viper.GetBool("no-cache"),
 				viper.GetBool("clear-cache"),
+				viper.GetInt64("distance"),
+				viper.GetString("tumor-id"),
+				viper.GetString("normal-id"),
+				pickOrder,
+				viper.GetBool("merge-codons"),
+				filterExpr,
+				normalizeOptionsFromViper(),
 			)
 		},
 	}
 
-	cmd.Flags().StringVar(&assembly, "assembly", "GRCh38", "Genome assembly: GRCh37 or GRCh38")
+	cmd.Flags().StringVar(&assembly, "assembly", "GRCh38", "Genome assembly: GRCh37, GRCh38 or auto (detect from input)")
 	cmd.Flags().StringVarP(&outputFile, "output", "o", "", "Output file (default: stdout)")
 	cmd.Flags().BoolVar(&canonicalOnly, "canonical", false, "Only report canonical transcript annotations")
 	cmd.Flags().BoolVar(&saveResults, "save-results", false, "Save annotation results to DuckDB for later lookup")
+	cmd.Flags().Int64Var(&distance, "distance", annotate.DefaultUpDownDistance, "Flank size in bases for upstream/downstream gene variants (0 disables)")
+	cmd.Flags().StringVar(&tumorID, "tumor-id", "", "VCF sample to report as the tumor (default: ##tumor_sample header or the only sample)")
+	cmd.Flags().StringVar(&normalID, "normal-id", "", "VCF sample to report as the matched normal (default: ##normal_sample header)")
+	addPickOrderFlag(cmd)
+	addMergeCodonsFlag(cmd)
+	addFilterFlag(cmd)
+	addNormalizeFlags(cmd)
 	addCacheFlags(cmd)
 
 	return cmd
 }
 
-func runConvertVCF2MAF(logger *zap.Logger, inputPath, assembly, outputFile string, canonicalOnly, saveResults, noCache, clearCache bool) error {
+func runConvertVCF2MAF(logger *zap.Logger, inputPath, assembly, outputFile string, canonicalOnly, saveResults, noCache, clearCache bool, distance int64, tumorID, normalID string, pickOrder output.PickOrder, mergeCodons bool, filterExpr *filter.Expr, norm normalizeOptions) error {
+	assembly, transcripts, err := resolveAssembly(logger, assembly, inputPath, inputVCF)
+	if err != nil {
+		return err
+	}
+
 	parser, err := vcf.NewParser(inputPath)
 	if err != nil {
 		if os.IsNotExist(err) {
@@ -82,7 +126,7 @@ func runConvertVCF2MAF(logger *zap.Logger, inputPath, assembly, outputFile strin
 	}
 	defer parser.Close()
 
-	cr, err := loadCache(logger, assembly, noCache, clearCache)
+	cr, err := loadCacheReusing(logger, assembly, transcripts, noCache, clearCache)
 	if err != nil {
 		return err
 	}
@@ -93,7 +137,16 @@ func runConvertVCF2MAF(logger *zap.Logger, inputPath, assembly, outputFile strin
 
 	ann := annotate.NewAnnotator(cr.cache)
 	ann.SetCanonicalOnly(canonicalOnly)
+	ann.SetUpDownDistance(distance)
 	ann.SetLogger(logger)
+	ref, err := configureNormalization(logger, ann, assembly, norm)
+	if err != nil {
+		return err
+	}
+	if ref != nil {
+		defer ref.Close()
+	}
+	defer warnRefMismatches(logger, ann, assembly)
 
 	var out *os.File
 	if outputFile == "" {
@@ -106,14 +159,19 @@ func runConvertVCF2MAF(logger *zap.Logger, inputPath, assembly, outputFile strin
 		defer out.Close()
 	}
 
-	// Determine tumor sample barcode
-	tumorSampleID := "TUMOR"
-	if names := parser.SampleNames(); len(names) > 0 {
-		tumorSampleID = names[0]
+	// Select tumor/normal samples; fall back to somatic caller headers
+	if tumorID == "" && normalID == "" {
+		tumorID, normalID = vcf.TumorNormalFromHeader(parser.Header())
 	}
-
-	writer := output.NewVCF2MAFWriter(out, assembly, tumorSampleID)
+	writer := output.NewVCF2MAFWriter(out, assembly, "TUMOR")
+	if err := writer.SetSamples(parser.SampleNames(), tumorID, normalID); err != nil {
+		return err
+	}
+	writer.SetLogger(logger)
 	writer.SetSources(cr.sources)
+	writer.SetTranscriptSets(cr.transcriptSets)
+	writer.SetMergeCodons(mergeCodons)
+	writer.SetFilter(filterExpr)
 	if err := writer.WriteHeader(); err != nil {
 		return fmt.Errorf("writing header: %w", err)
 	}
@@ -148,7 +206,7 @@ func runConvertVCF2MAF(logger *zap.Logger, inputPath, assembly, outputFile strin
 		logger.Info("progress", zap.Int("variants_processed", n))
 	}
 
-	if err := annotate.OrderedCollectWithProgress(results, 2*time.Second, progress, func(r annotate.WorkResult) error {
+	writeResult := func(r annotate.WorkResult) error {
 		if r.Err != nil {
 			logger.Warn("failed to annotate variant",
 				zap.String("chrom", r.Variant.Chrom),
@@ -161,11 +219,38 @@ func runConvertVCF2MAF(logger *zap.Logger, inputPath, assembly, outputFile strin
 			src.Annotate(r.Variant, r.Anns)
 		}
 
-		best := output.PickBestAnnotation(r.Anns)
+		best := pickOrder.Pick(r.Anns)
 		return writer.WriteRow(r.Variant, best, r.Anns)
+	}
+
+	var merger *annotate.CodonMerger
+	if mergeCodons {
+		merger = annotate.NewCodonMerger(cr.cache)
+	}
+	if err := annotate.OrderedCollectWithProgress(results, 2*time.Second, progress, func(r annotate.WorkResult) error {
+		if merger == nil {
+			return writeResult(r)
+		}
+		released, err := merger.Add(r, annotate.VCFHaplotypes(r.Variant))
+		if err != nil {
+			return fmt.Errorf("--merge-codons: %w", err)
+		}
+		for _, ready := range released {
+			if err := writeResult(ready); err != nil {
+				return err
+			}
+		}
+		return nil
 	}); err != nil {
 		return err
 	}
+	if merger != nil {
+		for _, ready := range merger.Flush() {
+			if err := writeResult(ready); err != nil {
+				return err
+			}
+		}
+	}
 
 	if parseErr != nil {
 		return parseErr
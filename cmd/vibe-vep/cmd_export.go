@@ -75,11 +75,16 @@ transcripts or annotation sources into memory.`,
 			if len(args) == 0 {
 				return fmt.Errorf("input file required (or use --from-cache)")
 			}
+			pickOrder, err := pickOrderFromViper()
+			if err != nil {
+				return err
+			}
 			return runExportParquet(logger, args[0],
 				viper.GetString("assembly"),
 				viper.GetString("output"),
 				viper.GetBool("canonical"),
 				viper.GetBool("pick"),
+				pickOrder,
 				viper.GetInt("row-group-size"),
 				viper.GetBool("no-cache"),
 				viper.GetBool("clear-cache"),
@@ -87,10 +92,11 @@ transcripts or annotation sources into memory.`,
 		},
 	}
 
-	cmd.Flags().StringVar(&assembly, "assembly", "GRCh38", "Genome assembly: GRCh37 or GRCh38")
+	cmd.Flags().StringVar(&assembly, "assembly", "GRCh38", "Genome assembly: GRCh37, GRCh38 or auto (detect from input)")
 	cmd.Flags().StringVarP(&outputFile, "output", "o", "annotations.parquet", "Output Parquet file path")
 	cmd.Flags().BoolVar(&canonicalOnly, "canonical", false, "Only report canonical transcript annotations")
 	cmd.Flags().BoolVar(&pick, "pick", false, "One annotation per variant (best transcript)")
+	addPickOrderFlag(cmd)
 	cmd.Flags().IntVar(&rowGroupSize, "row-group-size", pqexport.DefaultRowGroupSize, "Rows per row group")
 	cmd.Flags().BoolVar(&fromCache, "from-cache", false, "Export directly from DuckDB cache (no transcript loading)")
 	addCacheFlags(cmd)
@@ -98,8 +104,21 @@ transcripts or annotation sources into memory.`,
 	return cmd
 }
 
-func runExportParquet(logger *zap.Logger, inputPath, assembly, outputFile string, canonicalOnly, pick bool, rowGroupSize int, noCache, clearCache bool) error {
-	cr, err := loadCache(logger, assembly, noCache, clearCache)
+func runExportParquet(logger *zap.Logger, inputPath, assembly, outputFile string, canonicalOnly, pick bool, pickOrder output.PickOrder, rowGroupSize int, noCache, clearCache bool) error {
+	// Auto-detect input format by extension
+	ext := strings.ToLower(filepath.Ext(inputPath))
+	isVCF := ext == ".vcf" || ext == ".gz"
+
+	format := inputMAF
+	if isVCF {
+		format = inputVCF
+	}
+	assembly, transcripts, err := resolveAssembly(logger, assembly, inputPath, format)
+	if err != nil {
+		return err
+	}
+
+	cr, err := loadCacheReusing(logger, assembly, transcripts, noCache, clearCache)
 	if err != nil {
 		return err
 	}
@@ -112,16 +131,12 @@ func runExportParquet(logger *zap.Logger, inputPath, assembly, outputFile string
 	ann.SetCanonicalOnly(canonicalOnly)
 	ann.SetLogger(logger)
 
-	// Auto-detect input format by extension
-	ext := strings.ToLower(filepath.Ext(inputPath))
-	isVCF := ext == ".vcf" || ext == ".gz"
-
 	var rows []pqexport.Row
 
 	if isVCF {
-		rows, err = exportVCFToRows(logger, inputPath, ann, cr.sources, pick)
+		rows, err = exportVCFToRows(logger, inputPath, ann, cr.sources, pick, pickOrder)
 	} else {
-		rows, err = exportMAFToRows(logger, inputPath, ann, cr.sources, pick)
+		rows, err = exportMAFToRows(logger, inputPath, ann, cr.sources, pick, pickOrder)
 	}
 	if err != nil {
 		return err
@@ -203,7 +218,7 @@ func runExportParquetFromCache(logger *zap.Logger, assembly, outputFile string,
 	return nil
 }
 
-func exportVCFToRows(logger *zap.Logger, inputPath string, ann *annotate.Annotator, sources []annotate.AnnotationSource, pick bool) ([]pqexport.Row, error) {
+func exportVCFToRows(logger *zap.Logger, inputPath string, ann *annotate.Annotator, sources []annotate.AnnotationSource, pick bool, pickOrder output.PickOrder) ([]pqexport.Row, error) {
 	parser, err := vcf.NewParser(inputPath)
 	if err != nil {
 		return nil, err
@@ -248,7 +263,7 @@ func exportVCFToRows(logger *zap.Logger, inputPath string, ann *annotate.Annotat
 		}
 
 		if pick && len(anns) > 1 {
-			anns = []*annotate.Annotation{output.PickBestAnnotation(anns)}
+			anns = []*annotate.Annotation{pickOrder.Pick(anns)}
 		}
 
 		chrom := r.Variant.NormalizeChrom()
@@ -267,7 +282,7 @@ func exportVCFToRows(logger *zap.Logger, inputPath string, ann *annotate.Annotat
 	return rows, nil
 }
 
-func exportMAFToRows(logger *zap.Logger, inputPath string, ann *annotate.Annotator, sources []annotate.AnnotationSource, pick bool) ([]pqexport.Row, error) {
+func exportMAFToRows(logger *zap.Logger, inputPath string, ann *annotate.Annotator, sources []annotate.AnnotationSource, pick bool, pickOrder output.PickOrder) ([]pqexport.Row, error) {
 	parser, err := maf.NewParser(inputPath)
 	if err != nil {
 		return nil, err
@@ -310,7 +325,7 @@ func exportMAFToRows(logger *zap.Logger, inputPath string, ann *annotate.Annotat
 
 		// For MAF, select best annotation per variant (matching MAF behavior)
 		if pick && len(anns) > 0 {
-			best := output.PickBestAnnotation(anns)
+			best := pickOrder.Pick(anns)
 			if best != nil {
 				anns = []*annotate.Annotation{best}
 			}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/inodb/vibe-vep/internal/filter"
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+)
+
+func newFilterCmd(verbose *bool) *cobra.Command {
+	var (
+		outputFile string
+		format     string
+	)
+
+	cmd := &cobra.Command{
+		Use:   "filter <file>",
+		Short: "Filter annotated VCF, MAF or JSONL output",
+		Long: `Filter vibe-vep (or VEP) output with a filter expression.
+
+An expression combines comparisons of fields with values using and, or, not
+and parentheses:
+
+  is, =        equal             !=          not equal
+  <, <=, >, >= numeric compare   in          one of a comma-separated list
+  match        regular expression           <field>     field is present
+
+Field names are case-insensitive and ignore a vibe. prefix, with '.' and '_'
+treated alike: gnomad_af matches the MAF column vibe.gnomad.af and the CSQ
+field gnomad_af. Multi-valued fields such as Consequence match if any of
+their '&'-separated terms does. Comparisons on missing fields are false.
+Quote values with spaces, parentheses or <>=!.
+
+VCF input is filtered per transcript: CSQ entries that do not match are
+removed (CSQ fields are matched first, then CHROM, POS, ID, REF, ALT, QUAL,
+FILTER and INFO keys) and records left without entries are dropped. MAF input
+is filtered per row. JSONL input is filtered per transcript consequence.
+
+The same expressions can be applied while annotating with --filter.`,
+		Example: `  vibe-vep filter -f 'IMPACT in HIGH,MODERATE' annotated.vcf
+  vibe-vep filter -f 'IMPACT in HIGH,MODERATE and gnomad_af < 0.001 and not clinvar_clnsig match Benign' annotated.maf
+  vibe-vep filter -f 'Consequence is missense_variant' -f 'SYMBOL in KRAS,NRAS' -o ras.vcf annotated.vcf.gz
+  vibe-vep annotate vcf input.vcf | vibe-vep filter --format vcf -f 'CANONICAL_MANE is YES' -`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			exprs, err := cmd.Flags().GetStringArray("filter")
+			if err != nil {
+				return err
+			}
+			if len(exprs) == 0 {
+				return fmt.Errorf("at least one --filter expression is required")
+			}
+			expr, err := filter.Parse(exprs...)
+			if err != nil {
+				return fmt.Errorf("--filter: %w", err)
+			}
+			logger, err := newLogger(*verbose)
+			if err != nil {
+				return fmt.Errorf("creating logger: %w", err)
+			}
+			defer logger.Sync()
+			return runFilter(logger, args[0], outputFile, format, expr)
+		},
+	}
+
+	cmd.Flags().StringArrayP("filter", "f", nil, "Filter expression (repeatable, combined with and)")
+	cmd.Flags().StringVarP(&outputFile, "output", "o", "", "Output file (default: stdout)")
+	cmd.Flags().StringVar(&format, "format", "", "Input format: vcf, maf or jsonl (default: detect from the file name or content)")
+
+	return cmd
+}
+
+func runFilter(logger *zap.Logger, inputPath, outputFile, format string, expr *filter.Expr) error {
+	var in io.Reader
+	if inputPath == "-" {
+		in = os.Stdin
+	} else {
+		f, err := os.Open(inputPath)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		in = f
+	}
+	br := bufio.NewReaderSize(in, 1024*1024)
+	if magic, _ := br.Peek(2); len(magic) == 2 && magic[0] == 0x1f && magic[1] == 0x8b {
+		zr, err := gzip.NewReader(br)
+		if err != nil {
+			return fmt.Errorf("reading %s: %w", inputPath, err)
+		}
+		defer zr.Close()
+		br = bufio.NewReaderSize(zr, 1024*1024)
+	}
+
+	if format == "" {
+		format = detectFilterFormat(inputPath, br)
+	}
+	var run func(io.Reader, io.Writer, *filter.Expr) (filter.Stats, error)
+	switch strings.ToLower(format) {
+	case "vcf":
+		run = filter.VCF
+	case "maf", "tsv":
+		run = filter.MAF
+	case "jsonl", "json":
+		run = filter.JSONL
+	default:
+		return fmt.Errorf("unknown input format %q (use vcf, maf or jsonl)", format)
+	}
+
+	out := os.Stdout
+	if outputFile != "" {
+		var err error
+		out, err = os.Create(outputFile)
+		if err != nil {
+			return fmt.Errorf("creating output file: %w", err)
+		}
+		defer out.Close()
+	}
+
+	st, err := run(br, out, expr)
+	if err != nil {
+		return fmt.Errorf("filtering %s: %w", inputPath, err)
+	}
+	logger.Info("filter complete",
+		zap.String("format", format),
+		zap.Int("records", st.Records),
+		zap.Int("records_kept", st.RecordsKept),
+		zap.Int("entries", st.Entries),
+		zap.Int("entries_kept", st.EntriesKept))
+	return nil
+}
+
+// detectFilterFormat guesses the input format from the file name, then from
+// the first line.
+func detectFilterFormat(path string, br *bufio.Reader) string {
+	name := strings.TrimSuffix(strings.TrimSuffix(path, ".gz"), ".bgz")
+	switch {
+	case strings.HasSuffix(name, ".vcf"):
+		return "vcf"
+	case strings.HasSuffix(name, ".jsonl"), strings.HasSuffix(name, ".json"):
+		return "jsonl"
+	case strings.HasSuffix(name, ".maf"):
+		return "maf"
+	}
+	head, _ := br.Peek(br.Size())
+	head = bytes.TrimLeft(head, " \t\r\n")
+	switch {
+	case bytes.HasPrefix(head, []byte("##fileformat=VCF")):
+		return "vcf"
+	case bytes.HasPrefix(head, []byte("{")):
+		return "jsonl"
+	}
+	return "maf"
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"go.uber.org/zap"
+
+	"github.com/inodb/vibe-vep/internal/annotate"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+func newHGVSCmd(verbose *bool) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "hgvs",
+		Short: "Validate and normalize HGVS notation",
+		Long:  "Validate and normalize HGVS notation offline against the transcript cache and reference genome.",
+	}
+
+	cmd.AddCommand(newHGVSNormalizeCmd(verbose))
+
+	return cmd
+}
+
+func newHGVSNormalizeCmd(verbose *bool) *cobra.Command {
+	var (
+		assembly      string
+		referencePath string
+	)
+
+	cmd := &cobra.Command{
+		Use:   "normalize <notation>",
+		Short: "Rewrite a variant as canonical HGVSg, HGVSc and HGVSp",
+		Long: `Resolve a variant in any notation 'annotate variant' accepts and re-derive
+its canonical HGVS: the HGVSg, and the HGVSc and HGVSp on every overlapping
+transcript. Differences from the input are listed with the reason, e.g.
+a deletion that is not shifted to its 3'-most position, an insertion that
+is a duplication, a missing or unavailable transcript version, or
+single-letter amino acid codes.
+
+The reference genome (--reference, or the indexed FASTA in the data
+directory) is used to left-align genomic input, to shift genomic indels
+3' and to check intronic and UTR bases. Without it, genomic indels are
+reported where they are.`,
+		Example: `  vibe-vep hgvs normalize KRAS c.34_35insG
+  vibe-vep hgvs normalize NM_004985.4:c.35G>T
+  vibe-vep hgvs normalize 5:g.1293968del
+  vibe-vep hgvs normalize KRAS G12C
+  vibe-vep hgvs normalize rs121913529`,
+		Args: cobra.MinimumNArgs(1),
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			return viper.BindPFlags(cmd.Flags())
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			logger, err := newLogger(*verbose)
+			if err != nil {
+				return fmt.Errorf("creating logger: %w", err)
+			}
+			defer logger.Sync()
+			return runHGVSNormalize(logger, strings.Join(args, " "),
+				viper.GetString("assembly"),
+				viper.GetString("reference"),
+				viper.GetBool("no-cache"),
+				viper.GetBool("clear-cache"),
+			)
+		},
+	}
+
+	cmd.Flags().StringVar(&assembly, "assembly", "GRCh38", "Genome assembly: GRCh37 or GRCh38")
+	cmd.Flags().StringVar(&referencePath, "reference", "", "Reference genome FASTA indexed with samtools faidx, plain or bgzip (default: *.fa with .fai in the data directory)")
+	addCacheFlags(cmd)
+
+	return cmd
+}
+
+func runHGVSNormalize(logger *zap.Logger, notation, assembly, referencePath string, noCache, clearCache bool) error {
+	cr, err := loadCache(logger, assembly, noCache, clearCache)
+	if err != nil {
+		return err
+	}
+	if cr.store != nil {
+		defer cr.store.Close()
+	}
+	defer cr.closeSources()
+
+	ann := annotate.NewAnnotator(cr.cache)
+	ann.SetLogger(logger)
+	// The reference is optional here: without one, genomic indels are not
+	// shifted and REF alleles are not checked.
+	norm := normalizeOptions{reference: referencePath}
+	if norm.reference == "" {
+		norm.reference, _ = FindGenomeFASTA(assembly)
+	}
+	norm.enabled = norm.reference != ""
+	norm.checkRef = norm.enabled
+	ref, err := configureNormalization(logger, ann, assembly, norm)
+	if err != nil {
+		return err
+	}
+	if ref != nil {
+		defer ref.Close()
+	}
+
+	res, err := annotate.NormalizeHGVS(ann, cr.cache, cr.sources, notation)
+	if err != nil {
+		return err
+	}
+	if res.Warning != "" {
+		fmt.Fprintf(os.Stderr, "Warning: %s\n\n", res.Warning)
+	}
+
+	fmt.Fprintf(os.Stdout, "Input: %s\n", res.Input)
+	for _, nv := range res.Variants {
+		v := nv.Variant
+		fmt.Fprintf(os.Stdout, "\nVariant: %s:%d %s>%s\n", v.Chrom, v.Pos, v.Ref, v.Alt)
+		fmt.Fprintf(os.Stdout, "HGVSg:   %s\n\n", nv.HGVSg)
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		if len(nv.Changes) == 0 {
+			fmt.Fprintln(os.Stdout, "No changes: the input is already canonical.")
+		} else {
+			fmt.Fprintln(w, "Changed\tFrom\tTo\tReason")
+			for _, c := range nv.Changes {
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", c.Field, c.From, c.To, c.Reason)
+			}
+			w.Flush()
+		}
+
+		if len(nv.Annotations) == 0 {
+			fmt.Fprintln(os.Stdout, "\nNo overlapping transcripts.")
+			continue
+		}
+		fmt.Fprintln(os.Stdout)
+		fmt.Fprintln(w, "Gene\tTranscript\tCanonical_MSK\tCanonical_MANE\tConsequence\tHGVSc\tHGVSp")
+		for _, a := range nv.Annotations {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+				a.GeneName, a.TranscriptID,
+				yesNo(a.IsCanonicalMSK), yesNo(a.IsMANESelect),
+				a.Consequence, a.HGVSc, a.HGVSp)
+		}
+		w.Flush()
+	}
+	return nil
+}
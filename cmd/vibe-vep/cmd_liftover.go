@@ -0,0 +1,495 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"go.uber.org/zap"
+
+	"github.com/inodb/vibe-vep/internal/annotate"
+	"github.com/inodb/vibe-vep/internal/cache"
+	"github.com/inodb/vibe-vep/internal/liftover"
+	"github.com/inodb/vibe-vep/internal/maf"
+	"github.com/inodb/vibe-vep/internal/vcf"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// errAllelesDiverge rejects multi-allelic VCF records whose ALT alleles lift
+// to different positions or REF alleles, which would break sample genotypes.
+var errAllelesDiverge = errors.New("ALT alleles lift to different positions")
+
+// liftoverOptions holds the --liftover-from and --chain settings of the
+// annotate commands.
+type liftoverOptions struct {
+	from  string // source assembly, empty to disable
+	chain string // chain file path, empty to use the downloaded one
+}
+
+// addLiftoverFlags registers --liftover-from and --chain on cmd.
+func addLiftoverFlags(cmd *cobra.Command) {
+	cmd.Flags().String("liftover-from", "", "Lift input from this assembly (e.g. GRCh37) onto --assembly before annotating")
+	cmd.Flags().String("chain", "", "UCSC chain file for --liftover-from (default: downloaded chain in the data directory)")
+}
+
+// liftoverOptionsFromViper reads the flags registered by addLiftoverFlags.
+func liftoverOptionsFromViper() liftoverOptions {
+	return liftoverOptions{
+		from:  viper.GetString("liftover-from"),
+		chain: viper.GetString("chain"),
+	}
+}
+
+// loadChain loads the chain file for lifting from one assembly to another,
+// from chainPath if set or else from the target assembly's data directory.
+func loadChain(logger *zap.Logger, from, to, chainPath string) (*liftover.ChainMap, string, error) {
+	if chainPath == "" {
+		found, ok := FindChainFile(from, to)
+		if !ok {
+			return nil, "", fmt.Errorf("no chain file for %s to %s: run 'vibe-vep download --assembly %s' or pass --chain (%s)",
+				from, to, to, liftover.ChainFileURL(from, to))
+		}
+		chainPath = found
+	}
+	m, err := liftover.Load(chainPath)
+	if err != nil {
+		return nil, "", err
+	}
+	logger.Info("loaded liftover chain", zap.String("from", from), zap.String("to", to), zap.String("chain", chainPath))
+	return m, chainPath, nil
+}
+
+// configureLiftover enables liftover on ann when opts.from is set and returns
+// the source assembly and chain file, or "" if liftover is disabled.
+func configureLiftover(logger *zap.Logger, ann *annotate.Annotator, assembly string, opts liftoverOptions) (from, chainPath string, err error) {
+	if opts.from == "" {
+		return "", "", nil
+	}
+	from, err = normalizeAssembly(opts.from)
+	if err != nil {
+		return "", "", fmt.Errorf("--liftover-from: %w", err)
+	}
+	if from == assembly {
+		return "", "", fmt.Errorf("--liftover-from %s is the same as --assembly", from)
+	}
+	m, chainPath, err := loadChain(logger, from, assembly, opts.chain)
+	if err != nil {
+		return "", "", err
+	}
+	ann.SetLiftover(m)
+	return from, chainPath, nil
+}
+
+// warnLiftFailures logs how many input variants could not be lifted by the
+// annotator set up with configureLiftover. Each is also logged when it fails.
+func warnLiftFailures(logger *zap.Logger, ann *annotate.Annotator, from, to string) {
+	if n := ann.LiftFailures(); n > 0 {
+		logger.Warn("variants could not be lifted and were not annotated",
+			zap.Int64("count", n), zap.String("from", from), zap.String("to", to))
+	}
+}
+
+// resolveLiftoverAssembly resolves --assembly like resolveAssembly. With
+// --liftover-from the input is on the source assembly, so detection from the
+// input is not possible and the target must be given explicitly.
+func resolveLiftoverAssembly(logger *zap.Logger, assembly, inputPath, format string, opts liftoverOptions) (string, *loadedTranscripts, error) {
+	if opts.from == "" {
+		return resolveAssembly(logger, assembly, inputPath, format)
+	}
+	if strings.EqualFold(assembly, assemblyAuto) {
+		return "", nil, fmt.Errorf("--assembly auto cannot be combined with --liftover-from; pass the target assembly")
+	}
+	assembly, err := normalizeAssembly(assembly)
+	return assembly, nil, err
+}
+
+func newLiftoverCmd(verbose *bool) *cobra.Command {
+	var (
+		from          string
+		to            string
+		chainPath     string
+		referencePath string
+		outputFile    string
+		rejectFile    string
+	)
+
+	cmd := &cobra.Command{
+		Use:   "liftover <input.vcf|input.maf>",
+		Short: "Lift VCF or MAF coordinates to another assembly",
+		Long: `Lift variant coordinates between GRCh37 and GRCh38 using a UCSC chain file.
+
+Alleles are reverse-complemented where the chain maps to the opposite strand.
+When a reference genome for the target assembly is available, lifted REF
+alleles are checked against it. Records that cannot be lifted (unmapped,
+split by a chain gap, structural, or REF mismatch) are written to the reject
+file with the reason: a LIFTOVER_REJECT INFO field for VCF, a liftover_reject
+column for MAF.
+
+The input format is detected by extension (.vcf, .vcf.gz → VCF, otherwise MAF).`,
+		Example: `  vibe-vep convert liftover -o lifted.vcf input.vcf
+  vibe-vep convert liftover --from GRCh38 --to GRCh37 -o lifted.maf input.maf
+  vibe-vep convert liftover --chain hg19ToHg38.over.chain.gz --reject unmapped.vcf -o lifted.vcf input.vcf`,
+		Args: cobra.ExactArgs(1),
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			return viper.BindPFlags(cmd.Flags())
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			logger, err := newLogger(*verbose)
+			if err != nil {
+				return fmt.Errorf("creating logger: %w", err)
+			}
+			defer logger.Sync()
+			return runConvertLiftover(logger, args[0],
+				viper.GetString("from"),
+				viper.GetString("to"),
+				viper.GetString("chain"),
+				viper.GetString("reference"),
+				viper.GetString("output"),
+				viper.GetString("reject"),
+			)
+		},
+	}
+
+	cmd.Flags().StringVar(&from, "from", "GRCh37", "Source assembly: GRCh37 or GRCh38")
+	cmd.Flags().StringVar(&to, "to", "GRCh38", "Target assembly: GRCh37 or GRCh38")
+	cmd.Flags().StringVar(&chainPath, "chain", "", "UCSC chain file (default: downloaded chain in the target's data directory)")
+	cmd.Flags().StringVar(&referencePath, "reference", "", "Indexed FASTA of the target assembly for REF checks (default: auto-detect in data directory)")
+	cmd.Flags().StringVarP(&outputFile, "output", "o", "", "Output file (default: stdout)")
+	cmd.Flags().StringVar(&rejectFile, "reject", "", "File for records that cannot be lifted (default: <output>.unmapped)")
+
+	return cmd
+}
+
+// liftStats counts lifted and rejected records by reason.
+type liftStats struct {
+	lifted   int
+	rejected map[string]int
+}
+
+func (s *liftStats) reject(reason string) {
+	if s.rejected == nil {
+		s.rejected = make(map[string]int)
+	}
+	s.rejected[reason]++
+}
+
+func runConvertLiftover(logger *zap.Logger, inputPath, from, to, chainPath, referencePath, outputFile, rejectFile string) error {
+	var err error
+	if from, err = normalizeAssembly(from); err != nil {
+		return fmt.Errorf("--from: %w", err)
+	}
+	if to, err = normalizeAssembly(to); err != nil {
+		return fmt.Errorf("--to: %w", err)
+	}
+	if from == to {
+		return fmt.Errorf("--from and --to are both %s", from)
+	}
+
+	m, chainPath, err := loadChain(logger, from, to, chainPath)
+	if err != nil {
+		return err
+	}
+
+	// The target reference enables REF checks and re-anchoring indels on
+	// reverse-strand chains.
+	var ref annotate.ReferenceSequence
+	if referencePath == "" {
+		referencePath, _ = FindGenomeFASTA(to)
+	}
+	if referencePath != "" {
+		genome, err := cache.OpenGenomeFASTA(referencePath)
+		if err != nil {
+			return fmt.Errorf("opening reference genome: %w", err)
+		}
+		defer genome.Close()
+		ref = genome
+		logger.Info("checking lifted REF alleles against reference", zap.String("reference", referencePath))
+	} else {
+		logger.Warn("no reference genome for target assembly, lifted REF alleles are not checked", zap.String("assembly", to))
+	}
+
+	var out *os.File
+	if outputFile == "" {
+		out = os.Stdout
+	} else {
+		out, err = os.Create(outputFile)
+		if err != nil {
+			return fmt.Errorf("creating output file: %w", err)
+		}
+		defer out.Close()
+	}
+	if rejectFile == "" && outputFile != "" {
+		rejectFile = outputFile + ".unmapped"
+	}
+	var rejects io.Writer = io.Discard
+	if rejectFile != "" {
+		f, err := os.Create(rejectFile)
+		if err != nil {
+			return fmt.Errorf("creating reject file: %w", err)
+		}
+		defer f.Close()
+		rejects = f
+	}
+
+	w := bufio.NewWriter(out)
+	rw := bufio.NewWriter(rejects)
+	var stats liftStats
+
+	lower := strings.ToLower(inputPath)
+	if strings.HasSuffix(lower, ".vcf") || strings.HasSuffix(lower, ".vcf.gz") {
+		err = liftVCF(inputPath, m, ref, from, to, filepath.Base(chainPath), w, rw, &stats)
+	} else {
+		err = liftMAF(inputPath, m, ref, to, w, rw, &stats)
+	}
+	if err != nil {
+		return err
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	if err := rw.Flush(); err != nil {
+		return err
+	}
+
+	rejected := 0
+	fields := []zap.Field{zap.Int("lifted", stats.lifted)}
+	for _, reason := range slices.Sorted(maps.Keys(stats.rejected)) {
+		rejected += stats.rejected[reason]
+		fields = append(fields, zap.Int("rejected_"+reason, stats.rejected[reason]))
+	}
+	logger.Info("liftover complete", fields...)
+	if rejected > 0 && rejectFile == "" {
+		logger.Warn("rejected records were discarded; pass --reject to keep them", zap.Int("rejected", rejected))
+	}
+	return nil
+}
+
+// rejectReason returns a short token describing why a variant was not lifted.
+func rejectReason(err error) string {
+	switch {
+	case errors.Is(err, liftover.ErrUnmapped):
+		return "unmapped"
+	case errors.Is(err, liftover.ErrSplit):
+		return "split"
+	case errors.Is(err, liftover.ErrStructural):
+		return "structural"
+	case errors.Is(err, liftover.ErrNeedsRef):
+		return "needs_reference"
+	case errors.Is(err, liftover.ErrRefMismatch):
+		return "ref_mismatch"
+	case errors.Is(err, errAllelesDiverge):
+		return "alleles_diverge"
+	default:
+		return "error"
+	}
+}
+
+// liftoverVCFHeader rewrites VCF header lines for lifted records: contig,
+// reference and assembly lines of the source assembly are dropped and
+// reference and liftover lines for the target added before #CHROM.
+func liftoverVCFHeader(header []string, from, to, chain string) []string {
+	out := make([]string, 0, len(header)+2)
+	for _, line := range header {
+		switch {
+		case strings.HasPrefix(line, "##contig="),
+			strings.HasPrefix(line, "##reference="),
+			strings.HasPrefix(line, "##assembly="):
+			continue
+		case strings.HasPrefix(line, "#CHROM"):
+			out = append(out,
+				"##reference="+to,
+				fmt.Sprintf("##liftover=<From=%s,To=%s,Chain=%s>", from, to, chain))
+		}
+		out = append(out, line)
+	}
+	return out
+}
+
+// liftVCFRecord lifts every ALT allele of v. Multi-allelic records stay
+// joined and are rejected if their alleles lift differently.
+func liftVCFRecord(m *liftover.ChainMap, v *vcf.Variant, ref annotate.ReferenceSequence) (*vcf.Variant, error) {
+	alleles := vcf.SplitMultiAllelic(v)
+	alts := make([]string, len(alleles))
+	var first *vcf.Variant
+	for i, a := range alleles {
+		lifted, err := m.LiftVariant(a, ref)
+		if err != nil {
+			return nil, err
+		}
+		if first == nil {
+			first = lifted
+		} else if lifted.Chrom != first.Chrom || lifted.Pos != first.Pos || lifted.Ref != first.Ref {
+			return nil, errAllelesDiverge
+		}
+		alts[i] = lifted.Alt
+	}
+	first.Alt = strings.Join(alts, ",")
+	return first, nil
+}
+
+// formatVCFRecord formats v as a VCF data line, appending extraInfo to INFO.
+func formatVCFRecord(v *vcf.Variant, extraInfo string) string {
+	qual := v.FormatQual()
+	info := v.RawInfo
+	if extraInfo != "" {
+		if info == "" || info == "." {
+			info = extraInfo
+		} else {
+			info += ";" + extraInfo
+		}
+	}
+	if info == "" {
+		info = "."
+	}
+	line := strings.Join([]string{v.Chrom, strconv.FormatInt(v.Pos, 10), v.ID, v.Ref, v.Alt, qual, v.Filter, info}, "\t")
+	if v.SampleColumns != "" {
+		line += "\t" + v.SampleColumns
+	}
+	return line + "\n"
+}
+
+func liftVCF(inputPath string, m *liftover.ChainMap, ref annotate.ReferenceSequence, from, to, chain string, w, rejects io.Writer, stats *liftStats) error {
+	parser, err := vcf.NewParser(inputPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return fmt.Errorf("%w (check that the file path is correct)", err)
+		}
+		return err
+	}
+	defer parser.Close()
+
+	for _, line := range liftoverVCFHeader(parser.Header(), from, to, chain) {
+		if _, err := fmt.Fprintln(w, line); err != nil {
+			return err
+		}
+	}
+	for _, line := range parser.Header() {
+		if strings.HasPrefix(line, "#CHROM") {
+			if _, err := fmt.Fprintln(rejects, `##INFO=<ID=LIFTOVER_REJECT,Number=1,Type=String,Description="Reason the record could not be lifted">`); err != nil {
+				return err
+			}
+		}
+		if _, err := fmt.Fprintln(rejects, line); err != nil {
+			return err
+		}
+	}
+
+	for {
+		v, err := parser.Next()
+		if err != nil {
+			return fmt.Errorf("reading variant: %w", err)
+		}
+		if v == nil {
+			return nil
+		}
+		lifted, err := liftVCFRecord(m, v, ref)
+		if err != nil {
+			reason := rejectReason(err)
+			stats.reject(reason)
+			if _, err := io.WriteString(rejects, formatVCFRecord(v, "LIFTOVER_REJECT="+reason)); err != nil {
+				return err
+			}
+			continue
+		}
+		stats.lifted++
+		if _, err := io.WriteString(w, formatVCFRecord(lifted, "")); err != nil {
+			return err
+		}
+	}
+}
+
+// mafAllele formats an allele for a MAF column, using "-" for empty alleles.
+func mafAllele(allele string) string {
+	if allele == "" {
+		return "-"
+	}
+	return allele
+}
+
+func liftMAF(inputPath string, m *liftover.ChainMap, ref annotate.ReferenceSequence, to string, w, rejects io.Writer, stats *liftStats) error {
+	parser, err := maf.NewParser(inputPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return fmt.Errorf("%w (check that the file path is correct)", err)
+		}
+		return err
+	}
+	defer parser.Close()
+
+	cols := parser.Columns()
+	allele1 := -1
+	for i, name := range strings.Split(parser.Header(), "\t") {
+		if name == "Tumor_Seq_Allele1" {
+			allele1 = i
+		}
+	}
+	get := func(fields []string, i int) string {
+		if i >= 0 && i < len(fields) {
+			return fields[i]
+		}
+		return ""
+	}
+	set := func(fields []string, i int, value string) {
+		if i >= 0 && i < len(fields) {
+			fields[i] = value
+		}
+	}
+
+	if _, err := fmt.Fprintln(w, parser.Header()); err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintln(rejects, parser.Header()+"\tliftover_reject"); err != nil {
+		return err
+	}
+
+	for {
+		v, mafAnn, err := parser.NextWithAnnotation()
+		if err != nil {
+			return fmt.Errorf("reading variant: %w", err)
+		}
+		if v == nil {
+			return nil
+		}
+		lifted, err := m.LiftVariant(v, ref)
+		if err != nil {
+			reason := rejectReason(err)
+			stats.reject(reason)
+			if _, err := fmt.Fprintln(rejects, strings.Join(mafAnn.RawFields, "\t")+"\t"+reason); err != nil {
+				return err
+			}
+			continue
+		}
+		stats.lifted++
+
+		fields := append([]string(nil), mafAnn.RawFields...)
+		end := lifted.Pos + int64(len(lifted.Ref)) - 1
+		if lifted.Ref == "" {
+			end = lifted.Pos + 1
+		}
+		// Tumor_Seq_Allele1 follows whichever allele it equals.
+		switch get(fields, allele1) {
+		case "":
+		case get(fields, cols.ReferenceAllele):
+			set(fields, allele1, mafAllele(lifted.Ref))
+		case get(fields, cols.TumorSeqAllele2):
+			set(fields, allele1, mafAllele(lifted.Alt))
+		}
+		set(fields, cols.Chromosome, lifted.Chrom)
+		set(fields, cols.StartPosition, strconv.FormatInt(lifted.Pos, 10))
+		set(fields, cols.EndPosition, strconv.FormatInt(end, 10))
+		set(fields, cols.ReferenceAllele, mafAllele(lifted.Ref))
+		set(fields, cols.TumorSeqAllele2, mafAllele(lifted.Alt))
+		set(fields, cols.NCBIBuild, to)
+		if _, err := fmt.Fprintln(w, strings.Join(fields, "\t")); err != nil {
+			return err
+		}
+	}
+}
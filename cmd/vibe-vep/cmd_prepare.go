@@ -63,6 +63,11 @@ index so subsequent annotate/compare runs start instantly.`,
 			} else {
 				logger.Info("genomic index already up to date")
 			}
+			if err := genomicindex.Migrate(dbPath, bs, func(msg string, args ...any) {
+				logger.Info(fmt.Sprintf(msg, args...))
+			}); err != nil {
+				return fmt.Errorf("migrate genomic index: %w", err)
+			}
 
 			return nil
 		},
@@ -18,6 +18,8 @@ import (
 	"github.com/inodb/vibe-vep/internal/datasource/pfam"
 	"github.com/inodb/vibe-vep/internal/datasource/ptm"
 	"github.com/inodb/vibe-vep/internal/datasource/uniprot"
+	"github.com/inodb/vibe-vep/internal/duckdb"
+	"github.com/inodb/vibe-vep/internal/output"
 	"github.com/inodb/vibe-vep/internal/server"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
@@ -25,11 +27,18 @@ import (
 
 func newServeCmd(verbose *bool) *cobra.Command {
 	var (
-		assembly     string
-		port         int
-		host         string
-		readTimeout  time.Duration
-		writeTimeout time.Duration
+		assembly       string
+		port           int
+		host           string
+		readTimeout    time.Duration
+		writeTimeout   time.Duration
+		requestTimeout time.Duration
+		maxBatchSize   int
+		cacheSize      int
+		persistResults bool
+		normalize      bool
+		checkRef       bool
+		adminToken     string
 	)
 
 	cmd := &cobra.Command{
@@ -38,25 +47,41 @@ func newServeCmd(verbose *bool) *cobra.Command {
 		Long: `Start an HTTP server that provides variant annotation endpoints.
 
 Loads transcript cache and annotation sources at startup, then serves
-annotation requests over HTTP. Supports both Ensembl VEP REST API and
-genome-nexus API compatible endpoints.
+annotation requests over HTTP. Provides a native API returning the
+vibe-vep-jsonl schema, plus Ensembl VEP REST API and genome-nexus API
+compatible endpoints.
 
 Endpoints:
+  Native (vibe-vep-jsonl schema):
+    GET  /v1/annotate?variant={variant}&assembly={assembly}
+    POST /v1/annotate?assembly={assembly}
+    GET  /v1/openapi.json
+
+    variant is any notation the annotate variant command accepts. POST takes
+    a JSON array of them or VCF text (header optional). Responses are JSON
+    arrays, or JSON lines with "Accept: application/x-ndjson".
+
   Ensembl VEP compatibility:
     GET  /ensembl/{assembly}/vep/human/region/{region}/{allele}
     POST /ensembl/{assembly}/vep/human/region
     GET  /ensembl/{assembly}/vep/human/hgvs/{notation}
     POST /ensembl/{assembly}/vep/human/hgvs
 
+    Query options: pick=1 (one consequence per allele, ranked by
+    --pick-order), flag_pick=1 (mark it with "pick": 1)
+
   Genome-nexus compatibility:
     GET  /genome-nexus/{assembly}/annotation/genomic/{genomicLocation}
     POST /genome-nexus/{assembly}/annotation/genomic
     GET  /genome-nexus/{assembly}/annotation/{variant}
     POST /genome-nexus/{assembly}/annotation
+    GET  /genome-nexus/{assembly}/annotation/dbsnp/{rsid}
+    POST /genome-nexus/{assembly}/annotation/dbsnp
 
     Supported ?fields= enrichments (comma-separated):
       annotation_summary  Canonical transcript summary with variantClassification,
-                          hgvspShort, variantType, aminoAcidRef/Alt
+                          hgvspShort, variantType, aminoAcidRef/Alt (the
+                          --pick-order transcript when set)
       clinvar             ClinVar clinical significance
       hotspots            Cancer mutation hotspots
 
@@ -70,9 +95,46 @@ Endpoints:
       mutation_assessor, my_variant_info, oncokb, ptms, nucleotide_context
       entrezGeneId, refseq_transcript_ids (per transcript)
 
+  HGVS normalization:
+    POST /hgvs/normalize?assembly={assembly}
+
   Health/info:
     GET  /health
-    GET  /info`,
+    GET  /info
+    GET  /metrics   Prometheus metrics: per-route request counts and latency
+                    histograms, batch sizes, annotation errors, annotation
+                    source and result cache counters, myvariant.info lookups
+
+  Admin:
+    POST /admin/reload  Reload transcripts and annotation sources (also on
+                        SIGHUP); send "Authorization: Bearer" with --admin-token
+
+POST endpoints annotate their batch in parallel. An item that cannot be
+parsed, resolved or annotated gets an error object in its place (Ensembl:
+{"input", "error"}; genome-nexus: successfully_annotated=false with an
+errorMessage) instead of failing the request. Batches larger than
+--max-batch-size are rejected with 413; items not annotated within
+--request-timeout are returned as errors.
+
+Annotation results are cached in memory (--result-cache-size entries,
+shared with myvariant.info responses) and, with --persist-results, in the
+DuckDB variant cache of each assembly so they survive restarts. Cached
+results are keyed by the transcript data, annotation source versions and
+vibe-vep version, and dropped when any of them change. /info reports the
+cache hit and miss counts.
+
+Each request is logged with a request ID (the client's X-Request-ID header,
+or a generated one, echoed in the response); annotation errors logged while
+serving it carry the same ID.
+
+A reload (POST /admin/reload or SIGHUP) loads the data directory afresh in
+the background while the server keeps serving, then switches to it at once;
+requests already running finish on the old data, which is closed after.
+If any assembly fails to load the old data stays live. A reload never
+rebuilds an annotation index the server has open: a stale one fails the
+reload (rebuild it with vibe-vep prepare while the server is stopped).
+/info reports the data versions currently served and the status of the
+last reload.`,
 		Example: `  # Start server (single assembly)
   vibe-vep serve --assembly GRCh38 --port 8080
 
@@ -80,6 +142,8 @@ Endpoints:
   vibe-vep serve --assembly GRCh38,GRCh37 --port 8080
 
   # Test endpoints
+  curl "http://localhost:8080/v1/annotate?variant=KRAS%20G12C"
+  curl --data-binary @input.vcf -H "Content-Type: text/plain" "http://localhost:8080/v1/annotate?assembly=GRCh38"
   curl "http://localhost:8080/ensembl/grch38/vep/human/region/7:140753336-140753336:1/T"
   curl "http://localhost:8080/genome-nexus/grch38/annotation/genomic/7,140753336,140753336,A,T"`,
 		Args: cobra.NoArgs,
@@ -87,19 +151,31 @@ Endpoints:
 			return viper.BindPFlags(cmd.Flags())
 		},
 		RunE: func(cmd *cobra.Command, args []string) error {
+			pickOrder, err := pickOrderFromViper()
+			if err != nil {
+				return err
+			}
 			logger, err := newLogger(*verbose)
 			if err != nil {
 				return fmt.Errorf("creating logger: %w", err)
 			}
 			defer logger.Sync()
 			return runServe(logger, runServeConfig{
-				assemblies:   viper.GetString("assembly"),
-				host:         viper.GetString("host"),
-				port:         viper.GetInt("port"),
-				readTimeout:  viper.GetDuration("read-timeout"),
-				writeTimeout: viper.GetDuration("write-timeout"),
-				noCache:      viper.GetBool("no-cache"),
-				clearCache:   viper.GetBool("clear-cache"),
+				assemblies:     viper.GetString("assembly"),
+				host:           viper.GetString("host"),
+				port:           viper.GetInt("port"),
+				readTimeout:    viper.GetDuration("read-timeout"),
+				writeTimeout:   viper.GetDuration("write-timeout"),
+				requestTimeout: viper.GetDuration("request-timeout"),
+				maxBatchSize:   viper.GetInt("max-batch-size"),
+				cacheSize:      viper.GetInt("result-cache-size"),
+				persistResults: viper.GetBool("persist-results"),
+				noCache:        viper.GetBool("no-cache"),
+				clearCache:     viper.GetBool("clear-cache"),
+				normalize:      viper.GetBool("normalize"),
+				checkRef:       viper.GetBool("check-ref"),
+				adminToken:     viper.GetString("admin-token"),
+				pickOrder:      pickOrder,
 			})
 		},
 	}
@@ -109,29 +185,55 @@ Endpoints:
 	cmd.Flags().StringVar(&host, "host", "0.0.0.0", "Host to bind to")
 	cmd.Flags().DurationVar(&readTimeout, "read-timeout", 30*time.Second, "HTTP read timeout")
 	cmd.Flags().DurationVar(&writeTimeout, "write-timeout", 60*time.Second, "HTTP write timeout")
+	cmd.Flags().DurationVar(&requestTimeout, "request-timeout", 50*time.Second, "Deadline for annotating one request; batch items not annotated in time are returned as errors (keep below --write-timeout, 0 for none)")
+	cmd.Flags().IntVar(&maxBatchSize, "max-batch-size", 10000, "Maximum number of items in a batch POST (0 for no limit)")
+	cmd.Flags().IntVar(&cacheSize, "result-cache-size", 100000, "Number of annotation results to cache in memory (0 to disable)")
+	cmd.Flags().BoolVar(&persistResults, "persist-results", false, "Also cache annotation results in the DuckDB variant cache, across restarts")
+	cmd.Flags().BoolVar(&normalize, "normalize", false, "Left-align variants against each assembly's reference genome (indexed FASTA in the data directory)")
+	cmd.Flags().BoolVar(&checkRef, "check-ref", false, "Flag variants whose REF allele does not match each assembly's reference genome")
+	cmd.Flags().StringVar(&adminToken, "admin-token", "", "Bearer token required by POST /admin/reload (empty: no token)")
+	addPickOrderFlag(cmd)
 	addCacheFlags(cmd)
 
 	return cmd
 }
 
 type runServeConfig struct {
-	assemblies   string
-	host         string
-	port         int
-	readTimeout  time.Duration
-	writeTimeout time.Duration
-	noCache      bool
-	clearCache   bool
+	assemblies     string
+	host           string
+	port           int
+	readTimeout    time.Duration
+	writeTimeout   time.Duration
+	requestTimeout time.Duration
+	maxBatchSize   int
+	cacheSize      int
+	persistResults bool
+	noCache        bool
+	clearCache     bool
+	normalize      bool
+	checkRef       bool
+	adminToken     string
+	pickOrder      output.PickOrder
 }
 
 func runServe(logger *zap.Logger, cfg runServeConfig) error {
 	srv := server.New(logger, version)
+	srv.SetPickOrder(cfg.pickOrder)
+	srv.SetBatchLimits(cfg.maxBatchSize, cfg.requestTimeout)
+	srv.SetResultCacheSize(cfg.cacheSize)
+
+	// Variant cache stores are opened once per assembly and shared by
+	// reloads, which must not open a second handle on a DuckDB file the
+	// served data still uses. They are closed after the served data.
+	stores := make(map[string]*duckdb.Store)
+	defer func() {
+		for _, store := range stores {
+			store.Close()
+		}
+	}()
 
 	// Load each assembly.
-	assemblyNames := strings.Split(cfg.assemblies, ",")
-	var cacheResults []*cacheResult
-
-	for _, name := range assemblyNames {
+	for _, name := range strings.Split(cfg.assemblies, ",") {
 		name = strings.TrimSpace(name)
 		if name == "" {
 			continue
@@ -141,52 +243,26 @@ func runServe(logger *zap.Logger, cfg runServeConfig) error {
 		if err != nil {
 			return err
 		}
-
-		logger.Info("loading assembly", zap.String("assembly", normalized))
-		start := time.Now()
-
-		cr, err := loadCache(logger, normalized, cfg.noCache, cfg.clearCache)
+		data, store, err := loadServeAssembly(logger, normalized, cfg, nil)
 		if err != nil {
-			return fmt.Errorf("loading assembly %s: %w", normalized, err)
-		}
-		cacheResults = append(cacheResults, cr)
-
-		ann := annotate.NewAnnotator(cr.cache)
-		ann.SetLogger(logger)
-
-		srv.AddAssembly(normalized, cr.cache, ann, cr.sources)
-
-		// Load PFAM domain data if available.
-		if pfamStore := loadPfamStore(logger, normalized); pfamStore != nil {
-			srv.SetPfamStore(normalized, pfamStore)
-		}
-
-		// Load PTM data if available.
-		if ptmStore := loadPtmStore(logger, normalized); ptmStore != nil {
-			srv.SetPtmStore(normalized, ptmStore)
+			srv.Close()
+			return err
 		}
-
-		// Load UniProt mapping if available.
-		if uniprotStore := loadUniprotStore(logger, normalized); uniprotStore != nil {
-			srv.SetUniprotStore(normalized, uniprotStore)
+		if store != nil {
+			stores[normalized] = store
 		}
-
-		logger.Info("assembly loaded",
-			zap.String("assembly", normalized),
-			zap.Int("transcripts", cr.cache.TranscriptCount()),
-			zap.Int("sources", len(cr.sources)),
-			zap.Duration("elapsed", time.Since(start)))
+		srv.AddAssemblyData(data)
 	}
-
 	// Ensure cleanup on shutdown.
-	defer func() {
-		for _, cr := range cacheResults {
-			if cr.store != nil {
-				cr.store.Close()
-			}
-			cr.closeSources()
-		}
-	}()
+	defer srv.Close()
+
+	// Reloads re-read the data directory, but never clear the variant cache
+	// or rebuild indexes the served data holds open.
+	srv.SetLoader(func(assembly string) (*server.AssemblyData, error) {
+		data, _, err := loadServeAssembly(logger, assembly, cfg, &reloadState{store: stores[assembly]})
+		return data, err
+	})
+	srv.SetAdminToken(cfg.adminToken)
 
 	addr := net.JoinHostPort(cfg.host, fmt.Sprintf("%d", cfg.port))
 
@@ -197,7 +273,7 @@ func runServe(logger *zap.Logger, cfg runServeConfig) error {
 		WriteTimeout: cfg.writeTimeout,
 	}
 
-	// Graceful shutdown on SIGINT/SIGTERM.
+	// Graceful shutdown on SIGINT/SIGTERM; reload on SIGHUP.
 	errCh := make(chan error, 1)
 	go func() {
 		logger.Info("server starting", zap.String("addr", addr))
@@ -205,23 +281,101 @@ func runServe(logger *zap.Logger, cfg runServeConfig) error {
 	}()
 
 	sigCh := make(chan os.Signal, 1)
-	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
+	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP)
 
-	select {
-	case err := <-errCh:
-		if err != http.ErrServerClosed {
-			return fmt.Errorf("server error: %w", err)
+	for {
+		select {
+		case err := <-errCh:
+			if err != http.ErrServerClosed {
+				return fmt.Errorf("server error: %w", err)
+			}
+			return nil
+		case sig := <-sigCh:
+			if sig == syscall.SIGHUP {
+				if srv.StartReload() {
+					logger.Info("reloading", zap.String("signal", sig.String()))
+				} else {
+					logger.Warn("reload already running, ignoring signal", zap.String("signal", sig.String()))
+				}
+				continue
+			}
+			logger.Info("shutting down", zap.String("signal", sig.String()))
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+			if err := httpServer.Shutdown(ctx); err != nil {
+				return fmt.Errorf("shutdown error: %w", err)
+			}
+			return nil
 		}
-	case sig := <-sigCh:
-		logger.Info("shutting down", zap.String("signal", sig.String()))
-		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-		defer cancel()
-		if err := httpServer.Shutdown(ctx); err != nil {
-			return fmt.Errorf("shutdown error: %w", err)
+	}
+}
+
+// loadServeAssembly loads the transcript cache, annotation sources,
+// reference genome and PFAM/PTM/UniProt data of an assembly for serving.
+// The returned data's Close releases the files they hold open, except the
+// variant cache store, which is returned for the caller to close. A reload
+// passes the running server's state and gets no store back.
+func loadServeAssembly(logger *zap.Logger, assembly string, cfg runServeConfig, reload *reloadState) (*server.AssemblyData, *duckdb.Store, error) {
+	logger.Info("loading assembly", zap.String("assembly", assembly))
+	start := time.Now()
+
+	var cr *cacheResult
+	var err error
+	if reload != nil {
+		cr, err = reloadCache(logger, assembly, cfg.noCache, reload)
+	} else {
+		cr, err = loadCache(logger, assembly, cfg.noCache, cfg.clearCache)
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("loading assembly %s: %w", assembly, err)
+	}
+	// The store is owned by the caller at startup and by the running server
+	// on reloads; the data must not close it.
+	var store *duckdb.Store
+	if reload == nil {
+		store = cr.store
+	}
+
+	ann := annotate.NewAnnotator(cr.cache)
+	ann.SetLogger(logger)
+	ref, err := configureNormalization(logger, ann, assembly, normalizeOptions{enabled: cfg.normalize, checkRef: cfg.checkRef})
+	if err != nil {
+		if store != nil {
+			store.Close()
+		}
+		cr.closeSources()
+		return nil, nil, err
+	}
+
+	data := &server.AssemblyData{
+		Assembly:  assembly,
+		Cache:     cr.cache,
+		Annotator: ann,
+		Sources:   cr.sources,
+		Pfam:      loadPfamStore(logger, assembly),
+		Ptm:       loadPtmStore(logger, assembly),
+		Uniprot:   loadUniprotStore(logger, assembly),
+		Close: func() {
+			if ref != nil {
+				ref.Close()
+			}
+			cr.closeSources()
+		},
+	}
+	if cfg.persistResults {
+		if cr.store != nil {
+			data.ResultStore = cr.store
+		} else {
+			logger.Warn("--persist-results needs the variant cache, which is disabled (--no-cache)")
 		}
 	}
 
-	return nil
+	logger.Info("assembly loaded",
+		zap.String("assembly", assembly),
+		zap.Int("transcripts", cr.cache.TranscriptCount()),
+		zap.Int("sources", len(cr.sources)),
+		zap.Duration("elapsed", time.Since(start)))
+	return data, store, nil
 }
 
 // loadPfamStore loads PFAM domain data from the raw download directory.
@@ -8,6 +8,7 @@ import (
 	"text/tabwriter"
 
 	"github.com/inodb/vibe-vep/internal/annotate"
+	"github.com/inodb/vibe-vep/internal/datasource/custom"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 )
@@ -165,6 +166,25 @@ func newVersionCmd(verbose *bool) *cobra.Command {
 					}})
 			}
 
+			// Custom sources
+			customs, err := customSourceConfigs()
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+			}
+			for _, cfg := range customs {
+				status := "ready"
+				if fileModDate(cfg.File) == "" {
+					status = "file not found"
+				} else if !custom.Ready(customSourceIndexPath(cacheDir, cfg.Name), cfg) {
+					status = "not indexed (built on first use)"
+				}
+				srcAssembly := "any"
+				if cfg.MatchLevel() == annotate.MatchGenomic {
+					srcAssembly = assembly
+				}
+				infos = append(infos, sourceInfo{cfg.Name, string(cfg.MatchLevel()), srcAssembly, cfg.SourceVersion(), status, cfg.ColumnDefs()})
+			}
+
 			if len(infos) > 0 {
 				fmt.Println()
 				fmt.Println("Annotation Sources:")
@@ -1,9 +1,17 @@
 package main
 
 import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/spf13/viper"
+	"go.uber.org/zap"
+
+	"github.com/inodb/vibe-vep/internal/datasource/custom"
 )
 
 // allAnnotationConfigKeys is the canonical list of annotation source config keys.
@@ -150,8 +158,90 @@ func TestVersionSourcesMatchBuildSources(t *testing.T) {
 	}
 }
 
+// TestCustomSourceConfigs verifies custom sources are read from config in
+// name order, with defaults filled in and invalid entries rejected.
+func TestCustomSourceConfigs(t *testing.T) {
+	viper.Reset()
+	viper.Set("custom", map[string]any{
+		"panel": map[string]any{"file": "/data/panel.bed", "fields": []string{"name"}},
+		"artefacts": map[string]any{
+			"file": "/data/artefacts.vcf.gz", "match": "exact", "fields": []string{"REASON", "AF"},
+		},
+	})
+	cfgs, err := customSourceConfigs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cfgs) != 2 || cfgs[0].Name != "artefacts" || cfgs[1].Name != "panel" {
+		t.Fatalf("got %+v, want artefacts then panel", cfgs)
+	}
+	if cfgs[0].Format != "vcf" || len(cfgs[0].Fields) != 2 {
+		t.Errorf("artefacts = %+v", cfgs[0])
+	}
+	if cfgs[1].Format != "bed" || cfgs[1].Match != "overlap" {
+		t.Errorf("panel = %+v, want bed/overlap", cfgs[1])
+	}
+
+	// An invalid entry is reported without dropping the valid ones.
+	viper.Set("custom", map[string]any{
+		"panel":     map[string]any{"file": "/data/panel.bed", "match": "gene", "fields": []string{"name"}},
+		"artefacts": map[string]any{"file": "/data/artefacts.vcf.gz", "fields": []string{"REASON"}},
+		"broken":    map[string]any{"file": "/data/broken.vcf.gz", "fields": map[string]any{"REASON": 1}},
+	})
+	cfgs, err = customSourceConfigs()
+	if err == nil || !strings.Contains(err.Error(), "custom.panel") || !strings.Contains(err.Error(), "custom.broken") {
+		t.Errorf("expected errors for custom.panel (gene match on a BED file) and custom.broken, got %v", err)
+	}
+	if len(cfgs) != 1 || cfgs[0].Name != "artefacts" {
+		t.Errorf("got %+v, want only artefacts", cfgs)
+	}
+	viper.Reset()
+}
+
 // TestDownloadHelpShowsAllSources verifies that the download command's help
 // text mentions all annotation sources.
+func TestLoadCustomSource_NoBuild(t *testing.T) {
+	cacheDir := t.TempDir()
+	cfg := custom.Config{Name: "genes", Match: custom.MatchGene, Fields: []string{"list"}, File: filepath.Join(t.TempDir(), "genes.tsv")}
+	if err := os.WriteFile(cfg.File, []byte("gene\tlist\nTP53\tblacklist\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	// A missing index is not open anywhere, so a reload may build it.
+	src, err := loadCustomSource(zap.NewNop(), cacheDir, cfg, true)
+	if err != nil {
+		t.Fatalf("building a new index: %v", err)
+	}
+	src.Store().Close()
+
+	// A stale index may be in use by the running server: fail instead.
+	dbPath := customSourceIndexPath(cacheDir, cfg.Name)
+	built, err := os.Stat(dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	later := built.ModTime().Add(time.Hour)
+	if err := os.Chtimes(cfg.File, later, later); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loadCustomSource(zap.NewNop(), cacheDir, cfg, true); !errors.Is(err, errIndexInUse) {
+		t.Fatalf("err = %v, want errIndexInUse", err)
+	}
+	if after, err := os.Stat(dbPath); err != nil || !after.ModTime().Equal(built.ModTime()) {
+		t.Error("stale index was rebuilt")
+	}
+
+	// Outside a reload it is rebuilt.
+	src, err = loadCustomSource(zap.NewNop(), cacheDir, cfg, false)
+	if err != nil {
+		t.Fatalf("rebuilding: %v", err)
+	}
+	src.Store().Close()
+}
+
 func TestDownloadHelpShowsAllSources(t *testing.T) {
 	out, _, err := executeCommand("download", "--help")
 	if err != nil {
@@ -15,6 +15,8 @@ import (
 	"go.uber.org/zap"
 
 	"github.com/inodb/vibe-vep/internal/cache"
+	"github.com/inodb/vibe-vep/internal/genomebuild"
+	"github.com/inodb/vibe-vep/internal/liftover"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 )
@@ -76,9 +78,10 @@ func getGENCODEURLs(assembly string) (gtfURL, fastaURL string) {
 
 func newDownloadCmd(verbose *bool) *cobra.Command {
 	var (
-		assembly  string
-		outputDir string
-		gtfOnly   bool
+		assembly      string
+		outputDir     string
+		gtfOnly       bool
+		transcriptSet string
 	)
 
 	cmd := &cobra.Command{
@@ -87,7 +90,12 @@ func newDownloadCmd(verbose *bool) *cobra.Command {
 		Long: `Download GENCODE annotation files and optional annotation source data.
 
 Core files (always downloaded):
-  GENCODE GTF + FASTA transcripts, canonical transcript overrides
+  GENCODE GTF + FASTA transcripts, canonical transcript overrides,
+  UCSC liftover chain from the other assembly
+
+RefSeq transcripts (with --transcript-set refseq or merged, or
+transcript-set in config):
+  NCBI RefSeq GFF3 + RefSeq transcript FASTA (~100 MB)
 
 Optional annotation sources (enabled via config):
   annotations.alphamissense  AlphaMissense pathogenicity scores (~643 MB)
@@ -102,6 +110,9 @@ Optional annotation sources (enabled via config):
   # Download GRCh37 annotations
   vibe-vep download --assembly GRCh37
 
+  # Also download RefSeq transcripts (NM_ accessions)
+  vibe-vep download --transcript-set refseq
+
   # Enable and download SIFT + PolyPhen-2
   vibe-vep config set annotations.sift true
   vibe-vep config set annotations.polyphen true
@@ -113,23 +124,33 @@ Optional annotation sources (enabled via config):
 				return fmt.Errorf("creating logger: %w", err)
 			}
 			defer logger.Sync()
-			return runDownload(logger, assembly, outputDir, gtfOnly)
+			if transcriptSet == "" {
+				transcriptSet = viper.GetString("transcript-set")
+			}
+			return runDownload(logger, assembly, outputDir, gtfOnly, transcriptSet)
 		},
 	}
 
 	cmd.Flags().StringVar(&assembly, "assembly", "GRCh38", "Genome assembly: GRCh37 or GRCh38")
 	cmd.Flags().StringVar(&outputDir, "output", "", "Output directory (default: ~/.vibe-vep/)")
 	cmd.Flags().BoolVar(&gtfOnly, "gtf-only", false, "Only download GTF annotations (skip FASTA sequences)")
+	cmd.Flags().StringVar(&transcriptSet, "transcript-set", "", "Also download RefSeq transcripts for refseq or merged (default: transcript-set from config)")
 
 	return cmd
 }
 
-func runDownload(logger *zap.Logger, assembly, outputDir string, gtfOnly bool) error {
+func runDownload(logger *zap.Logger, assembly, outputDir string, gtfOnly bool, transcriptSet string) error {
 	var err error
 	assembly, err = normalizeAssembly(assembly)
 	if err != nil {
 		return err
 	}
+	if transcriptSet == "" {
+		transcriptSet = transcriptSourceGENCODE
+	}
+	if err := validateTranscriptSource(transcriptSet); err != nil {
+		return err
+	}
 
 	// Determine output directory
 	if outputDir == "" {
@@ -188,6 +209,34 @@ func runDownload(logger *zap.Logger, assembly, outputDir string, gtfOnly bool) e
 		addChecksum(canonicalFile, sum)
 	}
 
+	// Download RefSeq GFF3 + transcript FASTA for --transcript-set refseq/merged
+	if transcriptSet != transcriptSourceGENCODE {
+		gffURL, rnaURL := cache.RefSeqFileURLs(assembly)
+		fmt.Printf("\nDownloading RefSeq transcripts for %s...\n", assembly)
+		for _, u := range []string{gffURL, rnaURL} {
+			f := filepath.Join(rawDir, filepath.Base(u))
+			sum, err := downloadFile(u, f)
+			if err != nil {
+				return fmt.Errorf("downloading RefSeq: %w", err)
+			}
+			addChecksum(f, sum)
+		}
+	}
+
+	// Download the UCSC chain for lifting the other assembly onto this one
+	// (used by convert liftover and annotate --liftover-from)
+	for _, from := range genomebuild.Assemblies {
+		if from == assembly {
+			continue
+		}
+		chainFile := filepath.Join(rawDir, liftover.ChainFileName(from, assembly))
+		if sum, err := downloadFile(liftover.ChainFileURL(from, assembly), chainFile); err != nil {
+			logger.Warn("could not download liftover chain file", zap.String("from", from), zap.Error(err))
+		} else {
+			addChecksum(chainFile, sum)
+		}
+	}
+
 	// Download AlphaMissense data if enabled in config
 	if viper.GetBool("annotations.alphamissense") {
 		amURL := getAlphaMissenseURL(assembly)
@@ -621,3 +670,65 @@ func FindGENCODEFiles(assembly string) (gtfPath, fastaPath, canonicalPath string
 
 	return "", "", "", false
 }
+
+// FindRefSeqFiles looks for the NCBI RefSeq GFF3 and RefSeq transcript FASTA
+// in the default location, raw/ subdirectory first.
+func FindRefSeqFiles(assembly string) (gffPath, fastaPath string, found bool) {
+	dir := DefaultGENCODEPath(assembly)
+	if dir == "" {
+		return "", "", false
+	}
+	for _, d := range []string{filepath.Join(dir, "raw"), dir} {
+		gffs, _ := filepath.Glob(filepath.Join(d, "GCF_*_genomic.gff.gz"))
+		fastas, _ := filepath.Glob(filepath.Join(d, "GCF_*_rna.fna.gz"))
+		if len(gffs) > 0 && len(fastas) > 0 {
+			return gffs[0], fastas[0], true
+		}
+	}
+	return "", "", false
+}
+
+// FindGenomeFASTA returns the reference genome FASTA for an assembly in the
+// data directory: the first *.fa or *.fasta (raw/ subdirectory first) that
+// has a samtools faidx index next to it. Compressed *.fa.gz files must be
+// bgzipped, with a .gzi index as well.
+func FindGenomeFASTA(assembly string) (string, bool) {
+	dir := DefaultGENCODEPath(assembly)
+	if dir == "" {
+		return "", false
+	}
+	for _, d := range []string{filepath.Join(dir, "raw"), dir} {
+		for _, pattern := range []string{"*.fa", "*.fasta", "*.fa.gz", "*.fasta.gz"} {
+			matches, _ := filepath.Glob(filepath.Join(d, pattern))
+			for _, m := range matches {
+				if _, err := os.Stat(m + ".fai"); err != nil {
+					continue
+				}
+				if strings.HasSuffix(m, ".gz") {
+					if _, err := os.Stat(m + ".gzi"); err != nil {
+						continue
+					}
+				}
+				return m, true
+			}
+		}
+	}
+	return "", false
+}
+
+// FindChainFile returns the UCSC chain file for lifting from one assembly to
+// another, as downloaded into the target assembly's data directory.
+func FindChainFile(from, to string) (string, bool) {
+	dir := DefaultGENCODEPath(to)
+	name := liftover.ChainFileName(from, to)
+	if dir == "" || name == "" {
+		return "", false
+	}
+	for _, d := range []string{filepath.Join(dir, "raw"), dir} {
+		p := filepath.Join(d, name)
+		if _, err := os.Stat(p); err == nil {
+			return p, true
+		}
+	}
+	return "", false
+}
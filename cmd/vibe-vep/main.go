@@ -2,9 +2,12 @@
 package main
 
 import (
+	"errors"
 	"fmt"
+	"maps"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 	"time"
 
@@ -13,6 +16,7 @@ import (
 
 	"github.com/inodb/vibe-vep/internal/annotate"
 	"github.com/inodb/vibe-vep/internal/cache"
+	"github.com/inodb/vibe-vep/internal/datasource/custom"
 	"github.com/inodb/vibe-vep/internal/datasource/ensemblpred"
 	"github.com/inodb/vibe-vep/internal/datasource/gnomad"
 	"github.com/inodb/vibe-vep/internal/datasource/hotspots"
@@ -102,6 +106,8 @@ func newRootCmd() *cobra.Command {
 	rootCmd.AddCommand(newConvertCmd(&verbose))
 	rootCmd.AddCommand(newDownloadCmd(&verbose))
 	rootCmd.AddCommand(newExportCmd(&verbose))
+	rootCmd.AddCommand(newFilterCmd(&verbose))
+	rootCmd.AddCommand(newHGVSCmd(&verbose))
 	rootCmd.AddCommand(newPrepareCmd(&verbose))
 	rootCmd.AddCommand(newServeCmd(&verbose))
 	rootCmd.AddCommand(newVersionCmd(&verbose))
@@ -157,6 +163,113 @@ func main() {
 func addCacheFlags(cmd *cobra.Command) {
 	cmd.Flags().Bool("no-cache", false, "Skip transcript cache, always load from GTF/FASTA")
 	cmd.Flags().Bool("clear-cache", false, "Clear and rebuild transcript and variant caches")
+	cmd.Flags().String("transcript-set", transcriptSourceGENCODE, "Transcripts to annotate against: gencode, refseq (NM_/NR_ accessions) or merged")
+}
+
+// Transcript sources selected with --transcript-set.
+const (
+	transcriptSourceGENCODE = "gencode"
+	transcriptSourceRefSeq  = "refseq"
+	transcriptSourceMerged  = "merged"
+)
+
+// validateTranscriptSource returns an error unless source is a known
+// --transcript-set value.
+func validateTranscriptSource(source string) error {
+	switch source {
+	case transcriptSourceGENCODE, transcriptSourceRefSeq, transcriptSourceMerged:
+		return nil
+	}
+	return fmt.Errorf("invalid --transcript-set %q (use gencode, refseq or merged)", source)
+}
+
+// transcriptSourceFromViper returns the --transcript-set value, defaulting
+// to gencode.
+func transcriptSourceFromViper() (string, error) {
+	source := strings.ToLower(viper.GetString("transcript-set"))
+	if source == "" {
+		source = transcriptSourceGENCODE
+	}
+	return source, validateTranscriptSource(source)
+}
+
+// transcriptCacheDir returns the directory holding the transcript and variant
+// caches for a transcript source. GENCODE caches live directly in the
+// assembly directory; RefSeq and merged caches get their own subdirectory so
+// that switching --transcript-set does not invalidate the others.
+func transcriptCacheDir(cacheDir, source string) string {
+	if source == transcriptSourceGENCODE {
+		return cacheDir
+	}
+	return filepath.Join(cacheDir, source)
+}
+
+// normalizeOptions holds the --normalize, --check-ref, --reference and
+// --report-normalization settings.
+type normalizeOptions struct {
+	enabled   bool
+	checkRef  bool   // flag REF alleles that do not match the reference
+	reference string // FASTA path; empty means look in the data directory
+	report    bool
+}
+
+func addNormalizeFlags(cmd *cobra.Command) {
+	cmd.Flags().Bool("normalize", false, "Left-align and trim variants against the reference genome before annotation")
+	cmd.Flags().Bool("check-ref", false, "Flag variants whose REF allele does not match the reference genome")
+	cmd.Flags().String("reference", "", "Reference genome FASTA indexed with samtools faidx, plain or bgzip (default: *.fa with .fai in the data directory)")
+}
+
+// normalizeOptionsFromViper reads the normalization flags bound by addNormalizeFlags.
+func normalizeOptionsFromViper() normalizeOptions {
+	return normalizeOptions{
+		enabled:   viper.GetBool("normalize"),
+		checkRef:  viper.GetBool("check-ref"),
+		reference: viper.GetString("reference"),
+		report:    viper.GetBool("report-normalization"),
+	}
+}
+
+// configureNormalization sets the reference genome on ann when --normalize,
+// --check-ref or --reference is given, enabling left-alignment and REF checks
+// as requested. The reference is opts.reference if given, otherwise the
+// genome FASTA found in the data directory. Returns the opened reference for
+// the caller to close, or nil when no reference is used.
+func configureNormalization(logger *zap.Logger, ann *annotate.Annotator, assembly string, opts normalizeOptions) (*cache.GenomeFASTA, error) {
+	if !opts.enabled && !opts.checkRef && opts.reference == "" {
+		return nil, nil
+	}
+	path := opts.reference
+	if path == "" {
+		found, ok := FindGenomeFASTA(assembly)
+		if !ok {
+			flag := "--normalize"
+			if !opts.enabled {
+				flag = "--check-ref"
+			}
+			return nil, fmt.Errorf("%s needs a reference genome for %s: pass --reference or place an indexed FASTA (samtools faidx) in %s", flag, assembly, DefaultGENCODEPath(assembly))
+		}
+		path = found
+	}
+	ref, err := cache.OpenGenomeFASTA(path)
+	if err != nil {
+		return nil, fmt.Errorf("opening reference genome: %w", err)
+	}
+	ann.SetReference(ref)
+	ann.SetNormalize(opts.enabled)
+	ann.SetRefCheck(opts.checkRef)
+	logger.Info("using reference genome",
+		zap.String("reference", path),
+		zap.Bool("normalize", opts.enabled),
+		zap.Bool("check_ref", opts.checkRef))
+	return ref, nil
+}
+
+// warnRefMismatches logs the REF mismatch summary, if any, after a run.
+func warnRefMismatches(logger *zap.Logger, ann *annotate.Annotator, assembly string) {
+	if msg := ann.RefMismatchSummary(assembly); msg != "" {
+		checked, mismatched := ann.RefCheckStats()
+		logger.Warn(msg, zap.Int64("checked", checked), zap.Int64("mismatched", mismatched))
+	}
 }
 
 // cacheResult holds the loaded transcript cache and optional DuckDB variant store.
@@ -164,6 +277,16 @@ type cacheResult struct {
 	cache   *cache.Cache
 	store   *duckdb.Store // variant cache (DuckDB), nil if --no-cache
 	sources []annotate.AnnotationSource
+	// transcriptSets are the names of the user transcript sets applied to
+	// the transcripts, for output columns.
+	transcriptSets []string
+}
+
+// loadedTranscripts is a transcript cache loaded by loadTranscripts with
+// the default cache flags, for loadCacheReusing.
+type loadedTranscripts struct {
+	cache  *cache.Cache
+	loaded bool // false if rebuilt from the source files
 }
 
 // closeSources closes any sources that implement io.Closer (e.g. GenomicSource).
@@ -175,6 +298,9 @@ func (cr *cacheResult) closeSources() {
 		if ep, ok := src.(*ensemblpred.Source); ok {
 			ep.Store().Close()
 		}
+		if cs, ok := src.(*custom.Source); ok {
+			cs.Store().Close()
+		}
 	}
 }
 
@@ -193,22 +319,143 @@ func normalizeAssembly(assembly string) (string, error) {
 
 // loadCache loads transcripts using gob transcript cache, and opens DuckDB for variant cache.
 func loadCache(logger *zap.Logger, assembly string, noCache, clearCache bool) (*cacheResult, error) {
+	return loadCacheReusing(logger, assembly, nil, noCache, clearCache)
+}
+
+// loadCacheReusing is loadCache, taking the transcripts from pre instead of
+// loading them again if pre is not nil and neither --no-cache nor
+// --clear-cache is set. pre comes from resolveAssembly.
+func loadCacheReusing(logger *zap.Logger, assembly string, pre *loadedTranscripts, noCache, clearCache bool) (*cacheResult, error) {
+	return loadCacheWith(logger, assembly, pre, noCache, clearCache, nil)
+}
+
+// reloadCache is loadCache for a server reload, while the running server
+// still uses the previous data. The variant cache is not opened again: the
+// store the server opened at startup is used. Stale annotation indexes fail
+// the reload with errIndexInUse rather than being rebuilt under the running
+// server.
+func reloadCache(logger *zap.Logger, assembly string, noCache bool, reload *reloadState) (*cacheResult, error) {
+	return loadCacheWith(logger, assembly, nil, noCache, false, reload)
+}
+
+// reloadState is the data a server reload shares with the running server.
+type reloadState struct {
+	store *duckdb.Store // variant cache, nil if the server runs without one
+}
+
+// loadCacheWith implements loadCacheReusing and, with reload set,
+// reloadCache.
+func loadCacheWith(logger *zap.Logger, assembly string, pre *loadedTranscripts, noCache, clearCache bool, reload *reloadState) (*cacheResult, error) {
 	var err error
 	assembly, err = normalizeAssembly(assembly)
 	if err != nil {
 		return nil, err
 	}
-	gtfPath, fastaPath, canonicalPath, found := FindGENCODEFiles(assembly)
-
-	c := cache.New()
 	cacheDir := DefaultGENCODEPath(assembly)
 	if cacheDir == "" {
 		return nil, fmt.Errorf("cannot determine data directory for %s (set VIBE_VEP_DATA_DIR or HOME)", assembly)
 	}
 
+	var c *cache.Cache
+	var transcriptsLoaded bool
+	if pre != nil && !noCache && !clearCache {
+		c, transcriptsLoaded = pre.cache, pre.loaded
+	} else {
+		c, transcriptsLoaded, err = loadTranscripts(logger, assembly, cacheDir, noCache, clearCache)
+		if err != nil {
+			return nil, err
+		}
+	}
+	source, _ := transcriptSourceFromViper() // validated by loadTranscripts
+
+	// --- Variant cache (DuckDB) ---
+	cr := &cacheResult{cache: c, transcriptSets: transcriptSetNames()}
+	if noCache {
+		return cr, nil
+	}
+
+	// --- Build annotation sources (before DuckDB, so they load even if DuckDB fails) ---
+	cr.sources, err = buildSources(logger, cacheDir, assembly, reload != nil)
+	if err != nil {
+		return nil, err
+	}
+
+	// --- Variant cache (DuckDB) ---
+	dbPath := filepath.Join(transcriptCacheDir(cacheDir, source), "variant_cache.duckdb")
+	var store *duckdb.Store
+	if reload != nil {
+		store = reload.store // nil if the server runs without a variant cache
+	} else if store, err = duckdb.Open(dbPath); err != nil {
+		logger.Warn("could not open variant cache (try --clear-cache or delete "+dbPath+")",
+			zap.Error(err))
+		store = nil
+	}
+	if store != nil {
+		// Clear variant cache when transcripts changed (annotations depend on transcript data)
+		if clearCache || !transcriptsLoaded {
+			if err := store.ClearVariantResults(); err != nil {
+				logger.Warn("could not clear variant cache", zap.Error(err))
+			} else if clearCache {
+				logger.Info("cleared variant cache")
+			}
+		}
+		cr.store = store
+	}
+
+	if len(cr.sources) > 0 {
+		names := make([]string, len(cr.sources))
+		for i, s := range cr.sources {
+			names[i] = s.Name()
+		}
+		logger.Info("annotation sources loaded", zap.Strings("sources", names))
+	}
+
+	return cr, nil
+}
+
+// refseqFiles are the RefSeq inputs for --transcript-set refseq or merged.
+type refseqFiles struct {
+	gff    string
+	fasta  string
+	genome string // reference genome FASTA for sequence checks, empty if none
+}
+
+// loadTranscripts loads the transcript cache for an assembly from the gob
+// cache in cacheDir, falling back to (and then caching) the GENCODE GTF/FASTA
+// and, with --transcript-set refseq or merged, the RefSeq GFF3/FASTA.
+// transcriptsLoaded is false when transcripts were rebuilt from source files.
+func loadTranscripts(logger *zap.Logger, assembly, cacheDir string, noCache, clearCache bool) (c *cache.Cache, transcriptsLoaded bool, err error) {
+	source, err := transcriptSourceFromViper()
+	if err != nil {
+		return nil, false, err
+	}
+	gtfPath, fastaPath, canonicalPath, found := FindGENCODEFiles(assembly)
+	sets, err := configuredTranscriptSets()
+	if err != nil {
+		return nil, false, err
+	}
+
+	var refseq refseqFiles
+	if source != transcriptSourceGENCODE {
+		var refseqFound bool
+		refseq.gff, refseq.fasta, refseqFound = FindRefSeqFiles(assembly)
+		if refseq.genome = viper.GetString("reference"); refseq.genome == "" {
+			refseq.genome, _ = FindGenomeFASTA(assembly)
+		}
+		if source == transcriptSourceRefSeq {
+			// RefSeq only: the RefSeq files take the place of the GENCODE ones.
+			gtfPath, fastaPath, canonicalPath, found = refseq.gff, refseq.fasta, "", refseqFound
+		} else {
+			found = found && refseqFound
+		}
+	}
+
+	c = cache.New()
+
 	if found {
-		logger.Info("using GENCODE cache",
+		logger.Info("using transcript cache",
 			zap.String("assembly", assembly),
+			zap.String("transcript_set", source),
 			zap.String("gtf", gtfPath),
 			zap.String("fasta", fastaPath))
 	}
@@ -221,9 +468,27 @@ func loadCache(logger *zap.Logger, assembly string, noCache, clearCache bool) (*
 		canonicalFP, _ = duckdb.StatFile(canonicalPath)
 	}
 
+	setFPs := make(map[string]duckdb.FileFingerprint, len(sets))
+	for _, set := range sets {
+		fp, err := duckdb.StatFile(set.path)
+		if err != nil {
+			return nil, false, fmt.Errorf("transcript set %s: %w", set.name, err)
+		}
+		setFPs[set.name] = fp
+	}
+
 	// --- Transcript cache (gob) ---
-	transcriptsLoaded := false
-	tc := duckdb.NewTranscriptCache(cacheDir)
+	tc := duckdb.NewTranscriptCache(transcriptCacheDir(cacheDir, source))
+	tc.SetTranscriptSets(setFPs)
+	if source != transcriptSourceGENCODE {
+		gffFP, _ := duckdb.StatFile(refseq.gff)
+		rnaFP, _ := duckdb.StatFile(refseq.fasta)
+		genomeFP := duckdb.FileFingerprint{}
+		if refseq.genome != "" {
+			genomeFP, _ = duckdb.StatFile(refseq.genome)
+		}
+		tc.SetRefSeqFiles(gffFP, rnaFP, genomeFP)
+	}
 
 	if noCache || clearCache {
 		if clearCache {
@@ -255,11 +520,21 @@ func loadCache(logger *zap.Logger, assembly string, noCache, clearCache bool) (*
 
 	if !transcriptsLoaded {
 		if !found {
-			return nil, fmt.Errorf("no GENCODE data or transcript cache found for %s\nHint: Download with: vibe-vep download --assembly %s", assembly, assembly)
+			if source != transcriptSourceGENCODE {
+				return nil, false, fmt.Errorf("no %s transcript data or transcript cache found for %s\nHint: Download with: vibe-vep download --assembly %s --transcript-set %s", source, assembly, assembly, source)
+			}
+			return nil, false, fmt.Errorf("no GENCODE data or transcript cache found for %s\nHint: Download with: vibe-vep download --assembly %s", assembly, assembly)
 		}
-		// Load from GTF/FASTA
-		if err := loadFromGTFFASTA(logger, c, gtfPath, fastaPath, canonicalPath); err != nil {
-			return nil, err
+		// Load from GTF/FASTA and/or RefSeq GFF3/FASTA
+		if source != transcriptSourceRefSeq {
+			if err := loadFromGTFFASTA(logger, c, gtfPath, fastaPath, canonicalPath, sets); err != nil {
+				return nil, false, err
+			}
+		}
+		if source != transcriptSourceGENCODE {
+			if err := loadFromRefSeq(logger, c, refseq, source == transcriptSourceMerged, sets); err != nil {
+				return nil, false, err
+			}
 		}
 
 		// Write transcript cache for next time
@@ -281,49 +556,36 @@ func loadCache(logger *zap.Logger, assembly string, noCache, clearCache bool) (*
 		c.BuildIndex()
 		logger.Debug("built interval tree index", zap.Duration("elapsed", time.Since(start)))
 	}
+	return c, transcriptsLoaded, nil
+}
 
-	// --- Variant cache (DuckDB) ---
-	if noCache {
-		return &cacheResult{cache: c}, nil
+// errIndexInUse is returned when loading sources for a server reload finds
+// a stale index: rebuilding it would replace a file the running server
+// still has open.
+var errIndexInUse = errors.New("index is stale but in use by the running server (rebuild it with vibe-vep prepare while the server is stopped)")
+
+// checkRebuild returns errIndexInUse if noBuild is set and the index at
+// dbPath exists. An index that does not exist yet is not open anywhere and
+// may be built.
+func checkRebuild(dbPath string, noBuild bool) error {
+	if !noBuild {
+		return nil
 	}
-
-	cr := &cacheResult{cache: c}
-
-	// --- Build annotation sources (before DuckDB, so they load even if DuckDB fails) ---
-	cr.sources = buildSources(logger, cacheDir, assembly)
-
-	// --- Variant cache (DuckDB) ---
-	dbPath := filepath.Join(cacheDir, "variant_cache.duckdb")
-	store, err := duckdb.Open(dbPath)
-	if err != nil {
-		logger.Warn("could not open variant cache (try --clear-cache or delete "+dbPath+")",
-			zap.Error(err))
-	} else {
-		// Clear variant cache when transcripts changed (annotations depend on transcript data)
-		if clearCache || !transcriptsLoaded {
-			if err := store.ClearVariantResults(); err != nil {
-				logger.Warn("could not clear variant cache", zap.Error(err))
-			} else if clearCache {
-				logger.Info("cleared variant cache")
-			}
-		}
-		cr.store = store
+	if _, err := os.Stat(dbPath); err != nil {
+		return nil
 	}
-
-	if len(cr.sources) > 0 {
-		names := make([]string, len(cr.sources))
-		for i, s := range cr.sources {
-			names[i] = s.Name()
-		}
-		logger.Info("annotation sources loaded", zap.Strings("sources", names))
-	}
-
-	return cr, nil
+	return fmt.Errorf("%s: %w", dbPath, errIndexInUse)
 }
 
-// buildSources creates annotation sources from config.
-func buildSources(logger *zap.Logger, cacheDir, assembly string) []annotate.AnnotationSource {
+// buildSources creates annotation sources from config. Sources that fail to
+// load are skipped with a warning. With noBuild (server reloads), a stale
+// index fails the whole load with errIndexInUse instead of being rebuilt.
+func buildSources(logger *zap.Logger, cacheDir, assembly string, noBuild bool) ([]annotate.AnnotationSource, error) {
 	var sources []annotate.AnnotationSource
+	inUse := func(err error) ([]annotate.AnnotationSource, error) {
+		(&cacheResult{sources: sources}).closeSources()
+		return nil, err
+	}
 
 	// OncoKB cancer gene list
 	if cglPath := viper.GetString("oncokb.cancer-gene-list"); cglPath != "" {
@@ -342,7 +604,10 @@ func buildSources(logger *zap.Logger, cacheDir, assembly string) []annotate.Anno
 		(viper.GetBool("annotations.signal") && assembly == "grch37") || viper.GetBool("annotations.gnomad") ||
 		viper.GetBool("annotations.dbsnp")
 	if needGenomic {
-		gs, err := loadGenomicIndex(logger, cacheDir, assembly)
+		gs, err := loadGenomicIndex(logger, cacheDir, assembly, noBuild)
+		if errors.Is(err, errIndexInUse) {
+			return inUse(err)
+		}
 		if err != nil {
 			logger.Warn("could not load genomic index (try: vibe-vep prepare --assembly "+assembly+")",
 				zap.Error(err))
@@ -374,6 +639,9 @@ func buildSources(logger *zap.Logger, cacheDir, assembly string) []annotate.Anno
 		if !ensemblpred.Ready(predDBPath, predSources) {
 			// Check if source files exist before trying to build.
 			if _, err := os.Stat(predSources.PredictionsTSV); err == nil {
+				if err := checkRebuild(predDBPath, noBuild); err != nil {
+					return inUse(err)
+				}
 				logger.Info("building Ensembl SIFT/PolyPhen index (this may take several minutes)...")
 				start := time.Now()
 				if err := ensemblpred.Build(predDBPath, predSources, func(msg string, args ...any) {
@@ -399,11 +667,126 @@ func buildSources(logger *zap.Logger, cacheDir, assembly string) []annotate.Anno
 		}
 	}
 
-	return sources
+	// User-configured custom sources (VCF/BED/TSV)
+	customs, err := customSourceConfigs()
+	if err != nil {
+		logger.Warn("skipping invalid custom sources (check custom in config)", zap.Error(err))
+	}
+	for _, cfg := range customs {
+		src, err := loadCustomSource(logger, cacheDir, cfg, noBuild)
+		if errors.Is(err, errIndexInUse) {
+			return inUse(err)
+		}
+		if err != nil {
+			logger.Warn("could not load custom source (check custom."+cfg.Name+" in config)",
+				zap.String("path", cfg.File), zap.Error(err))
+			continue
+		}
+		sources = append(sources, src)
+	}
+
+	return sources, nil
+}
+
+// customSourceConfigs returns the custom sources registered in config,
+// sorted by name:
+//
+//	custom:
+//	  artefacts:
+//	    file: /data/artefacts.vcf.gz
+//	    match: exact
+//	    fields: [REASON]
+//
+// Each entry is read and validated on its own: invalid entries are left out
+// and reported together in the error, alongside the valid configs.
+func customSourceConfigs() ([]custom.Config, error) {
+	names := slices.Sorted(maps.Keys(viper.GetStringMap("custom")))
+	cfgs := make([]custom.Config, 0, len(names))
+	var errs []error
+	for _, name := range names {
+		var cfg custom.Config
+		if err := viper.UnmarshalKey("custom."+name, &cfg); err != nil {
+			errs = append(errs, fmt.Errorf("custom.%s: %w", name, err))
+			continue
+		}
+		cfg.Name = name
+		if err := cfg.Validate(); err != nil {
+			errs = append(errs, fmt.Errorf("custom.%s: %w", name, err))
+			continue
+		}
+		cfgs = append(cfgs, cfg)
+	}
+	return cfgs, errors.Join(errs...)
+}
+
+// customSourceIndexPath returns the SQLite index path of a custom source.
+func customSourceIndexPath(cacheDir, name string) string {
+	return filepath.Join(cacheDir, "custom", name+".sqlite")
+}
+
+// loadCustomSource opens the SQLite index of a custom source, building it
+// first if it is missing, older than the source file or built with another
+// configuration. With noBuild, an existing stale index is an errIndexInUse
+// error instead.
+func loadCustomSource(logger *zap.Logger, cacheDir string, cfg custom.Config, noBuild bool) (*custom.Source, error) {
+	dbPath := customSourceIndexPath(cacheDir, cfg.Name)
+	if !custom.Ready(dbPath, cfg) {
+		if err := checkRebuild(dbPath, noBuild); err != nil {
+			return nil, err
+		}
+		if err := os.MkdirAll(filepath.Dir(dbPath), 0755); err != nil {
+			return nil, err
+		}
+		logger.Info("building custom source index", zap.String("name", cfg.Name), zap.String("path", cfg.File))
+		start := time.Now()
+		n, err := custom.Build(dbPath, cfg)
+		if err != nil {
+			os.Remove(dbPath)
+			return nil, fmt.Errorf("build custom source %s: %w", cfg.Name, err)
+		}
+		logger.Info("built custom source index", zap.String("name", cfg.Name),
+			zap.Int64("entries", n), zap.Duration("elapsed", time.Since(start)))
+	}
+
+	store, err := custom.Open(dbPath, cfg)
+	if err != nil {
+		return nil, fmt.Errorf("open custom source %s: %w", cfg.Name, err)
+	}
+	return custom.NewSource(store), nil
+}
+
+// transcriptSetConfig is a named transcript set registered in config under
+// transcript-sets.
+type transcriptSetConfig struct {
+	name string
+	path string
+}
+
+// configuredTranscriptSets returns the transcript sets registered in config,
+// sorted by name:
+//
+//	transcript-sets:
+//	  oncokb: /data/oncokb_isoforms.tsv
+func configuredTranscriptSets() ([]transcriptSetConfig, error) {
+	m := viper.GetStringMapString("transcript-sets")
+	sets := make([]transcriptSetConfig, 0, len(m))
+	for _, name := range slices.Sorted(maps.Keys(m)) {
+		if err := cache.ValidateTranscriptSetName(name); err != nil {
+			return nil, err
+		}
+		sets = append(sets, transcriptSetConfig{name: name, path: m[name]})
+	}
+	return sets, nil
+}
+
+// transcriptSetNames returns the names of the configured transcript sets,
+// sorted, for output columns.
+func transcriptSetNames() []string {
+	return slices.Sorted(maps.Keys(viper.GetStringMapString("transcript-sets")))
 }
 
 // loadFromGTFFASTA loads transcripts from GENCODE GTF and FASTA files.
-func loadFromGTFFASTA(logger *zap.Logger, c *cache.Cache, gtfPath, fastaPath, canonicalPath string) error {
+func loadFromGTFFASTA(logger *zap.Logger, c *cache.Cache, gtfPath, fastaPath, canonicalPath string, sets []transcriptSetConfig) error {
 	start := time.Now()
 	loader := cache.NewGENCODELoader(gtfPath, fastaPath)
 
@@ -422,6 +805,12 @@ func loadFromGTFFASTA(logger *zap.Logger, c *cache.Cache, gtfPath, fastaPath, ca
 		}
 	}
 
+	loaded, err := loadTranscriptSets(logger, sets)
+	if err != nil {
+		return err
+	}
+	loader.SetTranscriptSets(loaded)
+
 	if err := loader.Load(c); err != nil {
 		return fmt.Errorf("loading GENCODE cache: %w", err)
 	}
@@ -431,6 +820,68 @@ func loadFromGTFFASTA(logger *zap.Logger, c *cache.Cache, gtfPath, fastaPath, ca
 	return nil
 }
 
+// loadFromRefSeq loads RefSeq transcripts from the RefSeq GFF3 and transcript
+// FASTA. With merged set, they are added alongside already loaded GENCODE
+// transcripts, which keep the canonical flags.
+func loadFromRefSeq(logger *zap.Logger, c *cache.Cache, files refseqFiles, merged bool, sets []transcriptSetConfig) error {
+	start := time.Now()
+	before := c.TranscriptCount()
+	loader := cache.NewRefSeqLoader(files.gff, files.fasta)
+	loader.SetSecondary(merged)
+
+	if files.genome != "" {
+		genome, err := cache.OpenGenomeFASTA(files.genome)
+		if err != nil {
+			return fmt.Errorf("opening reference genome for RefSeq: %w", err)
+		}
+		defer genome.Close()
+		loader.SetGenome(genome)
+	} else {
+		logger.Warn("no reference genome found, RefSeq transcripts that differ from the genome are dropped (pass --reference)")
+	}
+
+	loaded, err := loadTranscriptSets(logger, sets)
+	if err != nil {
+		return err
+	}
+	loader.SetTranscriptSets(loaded)
+
+	if err := loader.Load(c); err != nil {
+		return fmt.Errorf("loading RefSeq cache: %w", err)
+	}
+	if n := loader.Mismatches(); n > 0 {
+		logger.Warn("RefSeq transcripts differ from the reference genome and are annotated against the genome sequence",
+			zap.Int("count", n))
+	}
+	if n := loader.Dropped(); n > 0 {
+		logger.Warn("RefSeq coding transcripts dropped: their sequence does not fit the genome alignment (pass --reference to use the genome sequence)",
+			zap.Int("count", n))
+	}
+	logger.Info("loaded transcripts from RefSeq GFF3/FASTA",
+		zap.Int("count", c.TranscriptCount()-before),
+		zap.Duration("elapsed", time.Since(start)))
+	return nil
+}
+
+// loadTranscriptSets loads the configured transcript set files.
+func loadTranscriptSets(logger *zap.Logger, sets []transcriptSetConfig) ([]*cache.TranscriptSet, error) {
+	if len(sets) == 0 {
+		return nil, nil
+	}
+	loaded := make([]*cache.TranscriptSet, len(sets))
+	for i, set := range sets {
+		ts, err := cache.LoadTranscriptSet(set.name, set.path)
+		if err != nil {
+			return nil, err
+		}
+		loaded[i] = ts
+		logger.Info("loaded transcript set",
+			zap.String("name", set.name),
+			zap.Int("transcripts", len(ts.Transcripts)))
+	}
+	return loaded, nil
+}
+
 // rawDirForCache returns the raw/ subdirectory for a cache dir,
 // falling back to the cache dir itself for backward compatibility.
 func rawDirForCache(cacheDir string) string {
@@ -469,10 +920,17 @@ func genomicIndexPath(cacheDir string) string {
 }
 
 // loadGenomicIndex opens (or builds) the unified genomic annotation index.
-func loadGenomicIndex(logger *zap.Logger, cacheDir, assembly string) (*genomicindex.GenomicSource, error) {
+// With noBuild, an existing index that is stale or needs a migration is an
+// errIndexInUse error instead.
+func loadGenomicIndex(logger *zap.Logger, cacheDir, assembly string, noBuild bool) (*genomicindex.GenomicSource, error) {
 	dbPath := genomicIndexPath(cacheDir)
 	bs := genomicIndexSources(cacheDir, assembly)
 
+	if !genomicindex.Ready(dbPath, bs) || genomicindex.NeedsMigration(dbPath, bs) {
+		if err := checkRebuild(dbPath, noBuild); err != nil {
+			return nil, err
+		}
+	}
 	if !genomicindex.Ready(dbPath, bs) {
 		logger.Info("building genomic index (this may take several minutes)...")
 		start := time.Now()
@@ -485,6 +943,11 @@ func loadGenomicIndex(logger *zap.Logger, cacheDir, assembly string) (*genomicin
 	} else {
 		logger.Info("genomic index up to date")
 	}
+	if err := genomicindex.Migrate(dbPath, bs, func(msg string, args ...any) {
+		logger.Info(fmt.Sprintf(msg, args...))
+	}); err != nil {
+		return nil, fmt.Errorf("migrate genomic index: %w", err)
+	}
 
 	store, err := genomicindex.Open(dbPath)
 	if err != nil {
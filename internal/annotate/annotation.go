@@ -1,7 +1,10 @@
 // Package annotate provides variant effect prediction functionality.
 package annotate
 
-import "strings"
+import (
+	"slices"
+	"strings"
+)
 
 // Impact levels for variant consequences.
 const (
@@ -21,6 +24,12 @@ const (
 	ConsequenceSpliceAcceptor    = "splice_acceptor_variant"
 	ConsequenceSpliceDonor       = "splice_donor_variant"
 
+	// HIGH impact (structural variants)
+	ConsequenceTranscriptAblation      = "transcript_ablation"
+	ConsequenceTranscriptAmplification = "transcript_amplification"
+	ConsequenceExonLoss                = "exon_loss_variant"
+	ConsequenceGeneFusion              = "gene_fusion"
+
 	// MODERATE impact
 	ConsequenceMissenseVariant  = "missense_variant"
 	ConsequenceInframeInsertion = "inframe_insertion"
@@ -50,6 +59,11 @@ const (
 	ConsequenceIntergenicVariant = "intergenic_variant"
 	ConsequenceNonCodingExon     = "non_coding_transcript_exon_variant"
 	ConsequenceMatureMiRNA       = "mature_miRNA_variant"
+
+	// MODIFIER impact (structural variants)
+	ConsequenceFeatureTruncation = "feature_truncation"
+	ConsequenceFeatureElongation = "feature_elongation"
+	ConsequenceTranscriptVariant = "transcript_variant"
 )
 
 // Annotation represents the predicted effect of a variant on a transcript.
@@ -70,6 +84,8 @@ type Annotation struct {
 	IsCanonicalMSK     bool // Annotation on MSK canonical transcript
 	IsCanonicalEnsembl bool // Annotation on Ensembl canonical transcript
 	IsMANESelect       bool // Annotation on MANE Select transcript
+	TranscriptSets     []string // Names of the user transcript sets listing the transcript
+	GenomeMismatch     bool     // Transcript's own sequence differs from the reference genome, which was used instead
 	Allele          string            // The alternate allele
 	Biotype         string            // Transcript biotype
 	ExonNumber      string            // Exon number (e.g., "2/5")
@@ -77,10 +93,26 @@ type Annotation struct {
 	CDNAPosition    int64             // Position in cDNA
 	HGVSp           string            // HGVS protein notation (e.g., "p.Gly12Cys")
 	HGVSc           string            // HGVS coding DNA notation (e.g., "c.34G>T")
+	Distance        int64             // Distance to transcript for upstream/downstream variants, 0 otherwise
+	TranscriptLength int64            // CDS length of coding transcripts, exonic length otherwise (for the "length" pick criterion)
+	NearestGene         string // Closest gene symbol for intergenic variants (nearest mode)
+	NearestTranscriptID string // Closest transcript for intergenic variants (nearest mode)
+	NearestDistance     int64  // Signed distance to NearestTranscriptID: negative upstream (5'), positive downstream (3')
+	RefMismatch         bool   // REF allele does not match the reference genome
+	MergedConsequence   string   // Consequence of the codon with all in-cis SNVs applied (see CodonMerger)
+	MergedCodonChange   string   // Combined codon change, e.g. "gtg/AAg"
+	MergedHGVSp         string   // Combined protein change, e.g. "p.Val600Lys"
+	MergedWith          []string // Variant IDs of the other SNVs merged into the codon
 	PeptideMD5      string            // MD5 hex of transcript protein sequence (for Ensembl predictions lookup)
 	Extra           map[string]string // Annotation source data, e.g. "alphamissense.score" → "0.9876"
 }
 
+// InTranscriptSet returns true if the annotation's transcript is listed in
+// the named user transcript set.
+func (a *Annotation) InTranscriptSet(name string) bool {
+	return slices.Contains(a.TranscriptSets, name)
+}
+
 // GetImpact returns the impact level for a given consequence type.
 // For comma-separated consequences, returns the highest impact among all terms.
 func GetImpact(consequence string) string {
@@ -97,7 +129,9 @@ func GetImpact(consequence string) string {
 		switch term {
 		case ConsequenceStopGained, ConsequenceFrameshiftVariant,
 			ConsequenceStopLost, ConsequenceStartLost,
-			ConsequenceSpliceAcceptor, ConsequenceSpliceDonor:
+			ConsequenceSpliceAcceptor, ConsequenceSpliceDonor,
+			ConsequenceTranscriptAblation, ConsequenceTranscriptAmplification,
+			ConsequenceExonLoss, ConsequenceGeneFusion:
 			impact = ImpactHigh
 		case ConsequenceMissenseVariant, ConsequenceInframeInsertion,
 			ConsequenceInframeDeletion, "inframe_variant":
@@ -6,6 +6,7 @@ import (
 	"encoding/hex"
 	"fmt"
 	"runtime"
+	"sync/atomic"
 
 	"go.uber.org/zap"
 
@@ -13,23 +14,42 @@ import (
 	"github.com/inodb/vibe-vep/internal/vcf"
 )
 
-// TranscriptLookup defines the interface for finding transcripts at a position.
+// TranscriptLookup defines the interface for finding transcripts at a position,
+// overlapping a genomic range, or nearest to one.
 type TranscriptLookup interface {
 	FindTranscripts(chrom string, pos int64) []*cache.Transcript
+	FindTranscriptsInRange(chrom string, start, end int64) []*cache.Transcript
+	FindNearestTranscript(chrom string, start, end int64) (*cache.Transcript, int64)
 }
 
+// DefaultUpDownDistance is the default flank size (in bases) within which a
+// variant outside a transcript is reported as upstream or downstream of it.
+// Matches VEP's --distance default.
+const DefaultUpDownDistance int64 = 5000
+
 // Annotator annotates variants with consequence predictions.
 type Annotator struct {
-	cache         TranscriptLookup
-	canonicalOnly bool
-	logger        *zap.Logger
+	cache          TranscriptLookup
+	canonicalOnly  bool
+	upDownDistance int64
+	nearest        bool
+	reference      ReferenceSequence // reference genome, nil if none
+	normalize      bool              // left-align variants against reference
+	refCheck       bool              // validate REF alleles against reference
+	lifter         Lifter            // lift variants from another assembly if set
+	logger         *zap.Logger
+
+	refChecked    atomic.Int64 // variants whose REF was compared to reference
+	refMismatched atomic.Int64 // of which REF did not match
+	liftFailed    atomic.Int64 // variants that could not be lifted
 }
 
 // NewAnnotator creates a new annotator with the given cache.
 func NewAnnotator(c TranscriptLookup) *Annotator {
 	return &Annotator{
-		cache:  c,
-		logger: zap.NewNop(),
+		cache:          c,
+		upDownDistance: DefaultUpDownDistance,
+		logger:         zap.NewNop(),
 	}
 }
 
@@ -38,28 +58,136 @@ func (a *Annotator) SetCanonicalOnly(canonical bool) {
 	a.canonicalOnly = canonical
 }
 
+// SetUpDownDistance sets the flank size (in bases) used for
+// upstream_gene_variant/downstream_gene_variant. Zero disables flank reporting;
+// negative values are treated as zero.
+func (a *Annotator) SetUpDownDistance(d int64) {
+	if d < 0 {
+		d = 0
+	}
+	a.upDownDistance = d
+}
+
+// SetNearest configures whether intergenic variants report the nearest gene,
+// transcript and signed distance.
+func (a *Annotator) SetNearest(nearest bool) {
+	a.nearest = nearest
+}
+
+// SetReference enables normalization: each variant is left-aligned and
+// trimmed against the reference genome (in place, see vcf.Variant.Original)
+// before annotation, so annotation sources and caches see the normalized form.
+// Pass nil to disable.
+func (a *Annotator) SetReference(ref ReferenceSequence) {
+	a.reference = ref
+	a.normalize = ref != nil
+}
+
+// Reference returns the reference genome set with SetReference, or nil.
+func (a *Annotator) Reference() ReferenceSequence {
+	return a.reference
+}
+
+// SetNormalize turns normalization against the reference set with
+// SetReference on or off, e.g. to use the reference only for REF checks.
+func (a *Annotator) SetNormalize(normalize bool) {
+	a.normalize = normalize
+}
+
+// Normalize reports whether variants are normalized.
+func (a *Annotator) Normalize() bool {
+	return a.reference != nil && a.normalize
+}
+
+// SetRefCheck enables checking each variant's REF allele against the
+// reference set with SetReference (see Annotation.RefMismatch and
+// RefMismatchSummary).
+func (a *Annotator) SetRefCheck(check bool) {
+	a.refCheck = check
+}
+
+// RefCheck reports whether REF alleles are checked.
+func (a *Annotator) RefCheck() bool {
+	return a.reference != nil && a.refCheck
+}
+
+// Lifter maps variants from another assembly onto the annotator's assembly.
+// ref is the annotator's reference genome, nil if none is set.
+type Lifter interface {
+	LiftVariant(v *vcf.Variant, ref ReferenceSequence) (*vcf.Variant, error)
+}
+
+// SetLiftover makes Annotate lift each variant (in place) onto the cache's
+// assembly before annotating it. Variants that cannot be lifted fail with the
+// lifter's error. Pass nil to disable.
+func (a *Annotator) SetLiftover(l Lifter) {
+	a.lifter = l
+}
+
+// LiftFailures returns how many variants could not be lifted and so were
+// not annotated.
+func (a *Annotator) LiftFailures() int64 {
+	return a.liftFailed.Load()
+}
+
 // SetLogger sets the logger for warning and info messages.
 func (a *Annotator) SetLogger(l *zap.Logger) {
 	a.logger = l
 }
 
 // Annotate annotates a single variant and returns all annotations.
+// With liftover enabled, v is first lifted in place. When a reference is set,
+// v is left-aligned in place if normalization is enabled, after validating
+// the REF allele if REF checks are enabled.
 func (a *Annotator) Annotate(v *vcf.Variant) ([]*Annotation, error) {
+	return a.AnnotateWithLogger(v, a.logger)
+}
+
+// AnnotateWithLogger is Annotate with its messages logged to logger rather
+// than the annotator's logger, e.g. one tagged with a request ID.
+func (a *Annotator) AnnotateWithLogger(v *vcf.Variant, logger *zap.Logger) ([]*Annotation, error) {
+	if a.lifter != nil {
+		lifted, err := a.lifter.LiftVariant(v, a.reference)
+		if err != nil {
+			a.liftFailed.Add(1)
+			return nil, fmt.Errorf("liftover: %w", err)
+		}
+		*v = *lifted
+	}
+	if a.reference == nil {
+		return a.annotate(v), nil
+	}
+	refMismatch := a.refCheck && a.checkRef(v, logger)
+	if a.normalize && !refMismatch {
+		a.normalizeVariant(v, logger)
+	}
+	anns := a.annotate(v)
+	if refMismatch {
+		for _, ann := range anns {
+			ann.RefMismatch = true
+		}
+	}
+	return anns, nil
+}
+
+// annotate predicts the consequences of v on every transcript in range.
+func (a *Annotator) annotate(v *vcf.Variant) []*Annotation {
 	// Normalize chromosome
 	chrom := v.NormalizeChrom()
 
-	// Find overlapping transcripts
-	transcripts := a.cache.FindTranscripts(chrom, v.Pos)
+	// Symbolic alleles and breakends are annotated over their full span.
+	if v.IsStructural() {
+		return a.annotateSV(v, chrom)
+	}
+
+	// Find transcripts overlapping the full REF span, so deletions that start
+	// upstream of (or between) genes still report every transcript they hit,
+	// plus those within the upstream/downstream flank.
+	end := v.End()
+	transcripts := a.cache.FindTranscriptsInRange(chrom, v.Pos-a.upDownDistance, end+a.upDownDistance)
 
 	if len(transcripts) == 0 {
-		// Intergenic variant
-		ann := &Annotation{
-			VariantID:   FormatVariantID(v.Chrom, v.Pos, v.Ref, v.Alt),
-			Consequence: ConsequenceIntergenicVariant,
-			Impact:      GetImpact(ConsequenceIntergenicVariant),
-			Allele:      v.Alt,
-		}
-		return []*Annotation{ann}, nil
+		return []*Annotation{a.intergenic(v, chrom, v.Pos, end)}
 	}
 
 	var annotations []*Annotation
@@ -70,8 +198,21 @@ func (a *Annotator) Annotate(v *vcf.Variant) ([]*Annotation, error) {
 			continue
 		}
 
-		result := PredictConsequence(v, t)
-		result.HGVSc = FormatHGVSc(v, t, result)
+		var result *ConsequenceResult
+		var distance int64
+		switch {
+		case t.Contains(v.Pos):
+			result = PredictConsequence(v, t)
+			result.HGVSc = FormatHGVSc(v, t, result)
+		case end >= t.Start && v.Pos <= t.End:
+			// The variant starts before the transcript and its REF span runs
+			// into it: the transcript loses its 5'-most (genomic) bases.
+			result = PredictSVConsequence(vcf.SVTypeDEL, v.Pos, end, t)
+		default:
+			// Within the flank but not overlapping the transcript.
+			result = PredictConsequence(v, t)
+			distance = FlankDistance(v.Pos, end, t)
+		}
 
 		// Append biotype-specific modifier terms per VEP convention
 		consequence := result.Consequence
@@ -96,6 +237,8 @@ func (a *Annotator) Annotate(v *vcf.Variant) ([]*Annotation, error) {
 			IsCanonicalMSK:     t.IsCanonicalMSK,
 			IsCanonicalEnsembl: t.IsCanonicalEnsembl,
 			IsMANESelect:       t.IsMANESelect,
+			TranscriptSets:     t.TranscriptSets,
+			GenomeMismatch:     t.GenomeMismatch,
 			Allele:          v.Alt,
 			Biotype:         t.Biotype,
 			ExonNumber:      result.ExonNumber,
@@ -103,6 +246,8 @@ func (a *Annotator) Annotate(v *vcf.Variant) ([]*Annotation, error) {
 			CDNAPosition:    result.CDNAPosition,
 			HGVSp:           result.HGVSp,
 			HGVSc:           result.HGVSc,
+			Distance:        distance,
+			TranscriptLength: t.Length(),
 			PeptideMD5:      peptideMD5(t.CDSSequence),
 		}
 
@@ -111,16 +256,42 @@ func (a *Annotator) Annotate(v *vcf.Variant) ([]*Annotation, error) {
 
 	// If no annotations after filtering, add intergenic
 	if len(annotations) == 0 {
-		ann := &Annotation{
-			VariantID:   FormatVariantID(v.Chrom, v.Pos, v.Ref, v.Alt),
-			Consequence: ConsequenceIntergenicVariant,
-			Impact:      GetImpact(ConsequenceIntergenicVariant),
-			Allele:      v.Alt,
-		}
-		return []*Annotation{ann}, nil
+		return []*Annotation{a.intergenic(v, chrom, v.Pos, end)}
+	}
+
+	return annotations
+}
+
+// intergenic builds the intergenic_variant annotation for a variant spanning
+// [start, end]. In nearest mode it also records the closest transcript.
+func (a *Annotator) intergenic(v *vcf.Variant, chrom string, start, end int64) *Annotation {
+	ann := &Annotation{
+		VariantID:   FormatVariantID(v.Chrom, v.Pos, v.Ref, v.Alt),
+		Consequence: ConsequenceIntergenicVariant,
+		Impact:      GetImpact(ConsequenceIntergenicVariant),
+		Allele:      v.Alt,
+	}
+	if !a.nearest {
+		return ann
 	}
+	t, dist := a.cache.FindNearestTranscript(chrom, start, end)
+	if t == nil {
+		return ann
+	}
+	ann.NearestGene = t.GeneName
+	ann.NearestTranscriptID = t.ID
+	ann.NearestDistance = SignedGeneDistance(start, end, dist, t)
+	return ann
+}
 
-	return annotations, nil
+// SignedGeneDistance orients an unsigned variant-to-transcript distance
+// relative to the transcript's strand: negative when the variant lies
+// upstream (5') of the transcript, positive when it lies downstream (3').
+func SignedGeneDistance(start, end, dist int64, t *cache.Transcript) int64 {
+	if flankConsequence(end < t.Start, t) == ConsequenceUpstreamGene {
+		return -dist
+	}
+	return dist
 }
 
 // AnnotateAll annotates all variants from a parser.
@@ -107,6 +107,66 @@ func TestAnnotator_IntergenicVariant(t *testing.T) {
 	assert.Equal(t, ImpactModifier, annotations[0].Impact)
 }
 
+func TestAnnotator_UpstreamDownstream(t *testing.T) {
+	c := cache.New()
+	c.AddTranscript(createKRASTranscript()) // chr12:25205246-25250929, reverse strand
+	c.BuildIndex()
+	ann := NewAnnotator(c)
+
+	tests := []struct {
+		name     string
+		pos      int64
+		distance int64
+		want     string
+		wantDist int64
+	}{
+		{"after 5' end (reverse strand)", 25251929, DefaultUpDownDistance, ConsequenceUpstreamGene, 1000},
+		{"before 3' end (reverse strand)", 25205046, DefaultUpDownDistance, ConsequenceDownstreamGene, 200},
+		{"beyond default flank", 25256930, DefaultUpDownDistance, ConsequenceIntergenicVariant, 0},
+		{"within custom flank", 25256930, 10000, ConsequenceUpstreamGene, 6001},
+		{"flank disabled", 25205046, 0, ConsequenceIntergenicVariant, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ann.SetUpDownDistance(tt.distance)
+			anns, err := ann.Annotate(&vcf.Variant{Chrom: "12", Pos: tt.pos, Ref: "A", Alt: "G"})
+			require.NoError(t, err)
+			require.Len(t, anns, 1)
+			assert.Equal(t, tt.want, anns[0].Consequence)
+			assert.Equal(t, tt.wantDist, anns[0].Distance)
+		})
+	}
+}
+
+func TestAnnotator_Nearest(t *testing.T) {
+	c := cache.New()
+	c.AddTranscript(createKRASTranscript()) // chr12:25205246-25250929, reverse strand
+	c.BuildIndex()
+	ann := NewAnnotator(c)
+	ann.SetUpDownDistance(0)
+
+	v := &vcf.Variant{Chrom: "12", Pos: 25300929, Ref: "A", Alt: "G"}
+	anns, err := ann.Annotate(v)
+	require.NoError(t, err)
+	require.Len(t, anns, 1)
+	assert.Empty(t, anns[0].NearestGene, "nearest mode off by default")
+
+	ann.SetNearest(true)
+	anns, err = ann.Annotate(v)
+	require.NoError(t, err)
+	require.Len(t, anns, 1)
+	assert.Equal(t, ConsequenceIntergenicVariant, anns[0].Consequence)
+	assert.Equal(t, "KRAS", anns[0].NearestGene)
+	assert.Equal(t, "ENST00000311936", anns[0].NearestTranscriptID)
+	// Past the 5' end of a reverse-strand gene: upstream, so negative.
+	assert.Equal(t, int64(-50000), anns[0].NearestDistance)
+
+	anns, err = ann.Annotate(&vcf.Variant{Chrom: "12", Pos: 25105246, Ref: "A", Alt: "G"})
+	require.NoError(t, err)
+	require.Len(t, anns, 1)
+	assert.Equal(t, int64(100000), anns[0].NearestDistance)
+}
+
 func TestAnnotator_CanonicalOnly(t *testing.T) {
 	testCacheDir := findTestCacheDir(t)
 	c := cache.New()
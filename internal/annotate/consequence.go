@@ -32,6 +32,27 @@ type ConsequenceResult struct {
 	IsDelIns           bool   // True if insertion also modifies the anchor codon (delins format)
 }
 
+// flankConsequence returns upstream_gene_variant or downstream_gene_variant for
+// a variant lying entirely before (genomically) or after a transcript.
+func flankConsequence(before bool, t *cache.Transcript) string {
+	if before == t.IsForwardStrand() {
+		return ConsequenceUpstreamGene
+	}
+	return ConsequenceDownstreamGene
+}
+
+// FlankDistance returns the number of bases between the variant span
+// [start, end] and the nearest end of a transcript, or 0 if they overlap.
+func FlankDistance(start, end int64, t *cache.Transcript) int64 {
+	if end < t.Start {
+		return t.Start - end
+	}
+	if start > t.End {
+		return start - t.End
+	}
+	return 0
+}
+
 // PredictConsequence determines the effect of a variant on a transcript.
 func PredictConsequence(v *vcf.Variant, t *cache.Transcript) *ConsequenceResult {
 	result := &ConsequenceResult{}
@@ -39,19 +60,7 @@ func PredictConsequence(v *vcf.Variant, t *cache.Transcript) *ConsequenceResult
 	// Check if variant is within transcript boundaries
 	if !t.Contains(v.Pos) {
 		// Check upstream/downstream
-		if v.Pos < t.Start {
-			if t.IsForwardStrand() {
-				result.Consequence = ConsequenceUpstreamGene
-			} else {
-				result.Consequence = ConsequenceDownstreamGene
-			}
-		} else {
-			if t.IsForwardStrand() {
-				result.Consequence = ConsequenceDownstreamGene
-			} else {
-				result.Consequence = ConsequenceUpstreamGene
-			}
-		}
+		result.Consequence = flankConsequence(v.Pos < t.Start, t)
 		result.Impact = GetImpact(result.Consequence)
 		return result
 	}
@@ -255,13 +264,22 @@ func predictCodingConsequence(v *vcf.Variant, t *cache.Transcript, exon *cache.E
 	altCodon := MutateCodon(refCodon, posInCodon, altBase)
 	result.AltCodon = altCodon
 
+	// Format codon change (lowercase mutated base)
+	result.CodonChange = formatCodonChange(refCodon, altCodon, posInCodon)
+
+	return classifyCodonChange(t, result, codonNum)
+}
+
+// classifyCodonChange translates result.RefCodon and result.AltCodon, the
+// reference and altered codon codonNum of t, and sets the amino acids,
+// consequence, impact and HGVSp of result.
+func classifyCodonChange(t *cache.Transcript, result *ConsequenceResult, codonNum int64) *ConsequenceResult {
+	refCodon, altCodon := result.RefCodon, result.AltCodon
+
 	// Translate codons
 	result.RefAA = TranslateCodon(refCodon)
 	result.AltAA = TranslateCodon(altCodon)
 
-	// Format codon change (lowercase mutated base)
-	result.CodonChange = formatCodonChange(refCodon, altCodon, posInCodon)
-
 	// Determine consequence type
 	if result.RefAA == result.AltAA {
 		if result.RefAA == '*' {
@@ -0,0 +1,347 @@
+package annotate
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/inodb/vibe-vep/internal/cache"
+	"github.com/inodb/vibe-vep/internal/vcf"
+)
+
+// SampleHaplotypes describes which haplotypes of one sample carry a variant,
+// used to decide whether two variants are in cis.
+type SampleHaplotypes struct {
+	Sample     string // sample name, or the sample column number for VCF input
+	All        bool   // carried on every haplotype: homozygous ALT, or a MAF row (phase unknown, assumed in cis)
+	PhaseSet   int    // PS of a phased genotype, -1 if absent
+	Haplotypes []int  // GT indices carrying the ALT in a phased genotype
+}
+
+// VCFHaplotypes returns the haplotypes carrying v's ALT allele in each
+// sample, from the GT and PS FORMAT fields. Samples with an unphased
+// heterozygous call are included without haplotypes, so they are only in cis
+// with homozygous calls.
+func VCFHaplotypes(v *vcf.Variant) []SampleHaplotypes {
+	gts, err := v.Genotypes()
+	if err != nil {
+		return nil
+	}
+	alt := v.Allele()
+	var haps []SampleHaplotypes
+	for i, g := range gts {
+		if !g.Carries(alt) {
+			continue
+		}
+		h := SampleHaplotypes{Sample: strconv.Itoa(i + 1), PhaseSet: g.PS}
+		switch {
+		case g.HomAlt(alt):
+			h.All = true
+		case g.Phased:
+			for j, a := range g.Alleles {
+				if a == alt {
+					h.Haplotypes = append(h.Haplotypes, j)
+				}
+			}
+		}
+		haps = append(haps, h)
+	}
+	return haps
+}
+
+// MAFHaplotypes returns the haplotypes of a MAF row's tumor sample. MAF has
+// no phase, so SNVs of the same sample in one codon are assumed in cis.
+func MAFHaplotypes(sample string) []SampleHaplotypes {
+	return []SampleHaplotypes{{Sample: sample, All: true, PhaseSet: -1}}
+}
+
+// inCis reports whether some sample carries both variants on one haplotype.
+func inCis(a, b []SampleHaplotypes) bool {
+	for _, x := range a {
+		for _, y := range b {
+			if x.Sample != y.Sample {
+				continue
+			}
+			if x.All || y.All {
+				return true
+			}
+			if x.PhaseSet != y.PhaseSet {
+				continue
+			}
+			for _, hx := range x.Haplotypes {
+				for _, hy := range y.Haplotypes {
+					if hx == hy {
+						return true
+					}
+				}
+			}
+		}
+	}
+	return false
+}
+
+// CodonMerger is a haplotype pass over annotated variants in input order.
+// SNVs that change the same codon of a transcript on the same haplotype are
+// annotated independently by Annotate, which reports a wrong amino acid
+// change for each (e.g. the two SNVs of BRAF V600K). CodonMerger finds them
+// and sets the combined change (Annotation.MergedConsequence,
+// MergedCodonChange, MergedHGVSp) on each component's annotation, with
+// MergedWith linking the other components. The per-variant annotations are
+// left unchanged.
+//
+// Results are held back until no later variant can share a codon with them,
+// which needs input sorted by position within each chromosome and each
+// chromosome's variants together. Variants still held back may come in any
+// order. Add returns an error for input violating this, rather than missing
+// merges.
+type CodonMerger struct {
+	cache       TranscriptLookup
+	pending     []*mergeEntry
+	chrom       string          // chromosome of the last variant
+	releasedPos int64           // last position released on chrom
+	left        map[string]bool // chromosomes done with
+}
+
+// mergeEntry is a buffered result with the codons its SNV changes.
+type mergeEntry struct {
+	result WorkResult
+	haps   []SampleHaplotypes
+	id     string
+	chrom  string
+	pos    int64
+	reach  int64 // last genomic base of any codon changed, -1 if none
+	codons []codonHit
+}
+
+// codonHit is one transcript codon changed by an SNV.
+type codonHit struct {
+	ann        *Annotation
+	transcript *cache.Transcript
+	codon      int64 // codon number
+	posInCodon int   // 0-based
+	base       byte  // ALT base on the coding strand
+}
+
+// NewCodonMerger creates a codon merger looking up transcripts in c.
+func NewCodonMerger(c TranscriptLookup) *CodonMerger {
+	return &CodonMerger{cache: c}
+}
+
+// Add buffers an annotated variant with the sample haplotypes carrying it,
+// merges it with earlier in-cis SNVs in the same codon, and returns the
+// results that can no longer be merged, in input order. It returns an error
+// if the variant comes before an already returned one on its chromosome, or
+// on a chromosome the input had moved on from.
+func (m *CodonMerger) Add(r WorkResult, haps []SampleHaplotypes) ([]WorkResult, error) {
+	e := m.newEntry(r, haps)
+	if err := m.checkOrder(e); err != nil {
+		return nil, err
+	}
+	ready := m.release(e)
+	if len(e.codons) > 0 {
+		m.merge(e)
+	}
+	m.pending = append(m.pending, e)
+	return ready, nil
+}
+
+// checkOrder verifies that e can still be merged with any variant it shares
+// a codon with, and tracks the current chromosome.
+func (m *CodonMerger) checkOrder(e *mergeEntry) error {
+	switch {
+	case e.chrom == "":
+		return nil
+	case e.chrom == m.chrom:
+		if e.pos < m.releasedPos {
+			return fmt.Errorf("codon merging needs input sorted by position: %s:%d comes after %s:%d",
+				e.chrom, e.pos, m.chrom, m.releasedPos)
+		}
+		return nil
+	case m.left[e.chrom]:
+		return fmt.Errorf("codon merging needs each chromosome's variants together: %s comes again after %s",
+			e.chrom, m.chrom)
+	}
+	if m.chrom != "" {
+		if m.left == nil {
+			m.left = make(map[string]bool)
+		}
+		m.left[m.chrom] = true
+	}
+	m.chrom, m.releasedPos = e.chrom, 0
+	return nil
+}
+
+// Flush returns all buffered results.
+func (m *CodonMerger) Flush() []WorkResult {
+	ready := make([]WorkResult, len(m.pending))
+	for i, e := range m.pending {
+		ready[i] = e.result
+	}
+	m.pending = nil
+	return ready
+}
+
+// release removes and returns the leading buffered results that e and any
+// later variant are past.
+func (m *CodonMerger) release(e *mergeEntry) []WorkResult {
+	var ready []WorkResult
+	n := 0
+	for _, p := range m.pending {
+		if p.reach >= 0 && p.chrom == e.chrom && e.pos <= p.reach {
+			break
+		}
+		if p.chrom == m.chrom && p.pos > m.releasedPos {
+			m.releasedPos = p.pos
+		}
+		ready = append(ready, p.result)
+		n++
+	}
+	m.pending = m.pending[n:]
+	return ready
+}
+
+// newEntry collects the codons changed by r's SNV.
+func (m *CodonMerger) newEntry(r WorkResult, haps []SampleHaplotypes) *mergeEntry {
+	e := &mergeEntry{result: r, haps: haps, reach: -1}
+	v := r.Variant
+	if v == nil {
+		return e
+	}
+	e.chrom = v.NormalizeChrom()
+	e.pos = v.Pos
+	if r.Err != nil || !v.IsSNV() || len(haps) == 0 {
+		return e
+	}
+	e.id = FormatVariantID(v.Chrom, v.Pos, v.Ref, v.Alt)
+
+	var transcripts []*cache.Transcript
+	for _, ann := range r.Anns {
+		if ann.CodonChange == "" || ann.ProteinPosition < 1 || ann.CDSPosition < 1 {
+			continue
+		}
+		if transcripts == nil {
+			transcripts = m.cache.FindTranscripts(e.chrom, v.Pos)
+		}
+		var t *cache.Transcript
+		for _, tr := range transcripts {
+			if tr.ID == ann.TranscriptID {
+				t = tr
+				break
+			}
+		}
+		if t == nil || len(GetCodon(t.CDSSequence, ann.ProteinPosition)) != 3 {
+			continue
+		}
+		codon, posInCodon := CDSToCodonPosition(ann.CDSPosition)
+		base := v.Alt[0]
+		if t.IsReverseStrand() {
+			base = Complement(base)
+		}
+		e.codons = append(e.codons, codonHit{ann: ann, transcript: t, codon: codon, posInCodon: posInCodon, base: base})
+
+		first := (codon-1)*3 + 1
+		for _, cdsPos := range []int64{first, first + 2} {
+			if g := CDSToGenomic(cdsPos, t); g > e.reach {
+				e.reach = g
+			}
+		}
+	}
+	return e
+}
+
+// merge combines e with the buffered SNVs it shares a codon with on the same
+// haplotype, for each transcript codon e changes.
+func (m *CodonMerger) merge(e *mergeEntry) {
+	for _, hit := range e.codons {
+		group := []*mergeEntry{e}
+		hits := []codonHit{hit}
+		for _, p := range m.pending {
+			if p.chrom != e.chrom || len(p.codons) == 0 {
+				continue
+			}
+			other, ok := p.hit(hit.transcript.ID, hit.codon)
+			if !ok || other.posInCodon == hit.posInCodon || !inCisWithAll(p, group) {
+				continue
+			}
+			group = append(group, p)
+			hits = append(hits, other)
+		}
+		if len(group) > 1 {
+			applyMergedCodon(group, hits)
+		}
+	}
+}
+
+// hit returns the entry's change to a transcript codon.
+func (e *mergeEntry) hit(transcriptID string, codon int64) (codonHit, bool) {
+	for _, h := range e.codons {
+		if h.codon == codon && h.transcript.ID == transcriptID {
+			return h, true
+		}
+	}
+	return codonHit{}, false
+}
+
+// inCisWithAll reports whether e is in cis with every entry of group.
+func inCisWithAll(e *mergeEntry, group []*mergeEntry) bool {
+	for _, g := range group {
+		if !inCis(e.haps, g.haps) {
+			return false
+		}
+	}
+	return true
+}
+
+// applyMergedCodon applies the SNVs of group (one hit each, same codon, at
+// distinct codon positions) to the codon together and records the combined
+// change on each component's annotation.
+func applyMergedCodon(group []*mergeEntry, hits []codonHit) {
+	t := hits[0].transcript
+	codon := hits[0].codon
+	refCodon := GetCodon(t.CDSSequence, codon)
+	alt := []byte(refCodon)
+	var changed [3]bool
+	for _, h := range hits {
+		if changed[h.posInCodon] {
+			return
+		}
+		alt[h.posInCodon] = h.base
+		changed[h.posInCodon] = true
+	}
+
+	result := &ConsequenceResult{
+		CDSPosition:     (codon-1)*3 + 1,
+		ProteinPosition: codon,
+		RefCodon:        refCodon,
+		AltCodon:        string(alt),
+	}
+	classifyCodonChange(t, result, codon)
+	codonChange := formatMergedCodonChange(refCodon, string(alt), changed)
+
+	for i, h := range hits {
+		h.ann.MergedConsequence = result.Consequence
+		h.ann.MergedCodonChange = codonChange
+		h.ann.MergedHGVSp = result.HGVSp
+		h.ann.MergedWith = nil
+		for j, other := range group {
+			if j != i {
+				h.ann.MergedWith = append(h.ann.MergedWith, other.id)
+			}
+		}
+	}
+}
+
+// formatMergedCodonChange formats a codon change like formatCodonChange,
+// with every changed base of the alternate codon in upper case.
+func formatMergedCodonChange(refCodon, altCodon string, changed [3]bool) string {
+	var buf [7]byte
+	for i := 0; i < 3; i++ {
+		buf[i] = refCodon[i] | 0x20
+		if changed[i] {
+			buf[4+i] = altCodon[i] &^ 0x20
+		} else {
+			buf[4+i] = altCodon[i] | 0x20
+		}
+	}
+	buf[3] = '/'
+	return string(buf[:])
+}
@@ -0,0 +1,153 @@
+package annotate
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/inodb/vibe-vep/internal/vcf"
+)
+
+// krasCodon12 annotates c.35G>T and c.34G>T (KRAS codon 12, GGT, reverse
+// strand) with the given sample columns and runs them through a CodonMerger.
+func krasCodon12(t *testing.T, sample35, sample34 string) []WorkResult {
+	t.Helper()
+	c := createKRASCache()
+	ann := NewAnnotator(c)
+	m := NewCodonMerger(c)
+
+	var out []WorkResult
+	for i, v := range []*vcf.Variant{
+		{Chrom: "12", Pos: 25245350, Ref: "C", Alt: "A", SampleColumns: sample35}, // c.35G>T
+		{Chrom: "12", Pos: 25245351, Ref: "C", Alt: "A", SampleColumns: sample34}, // c.34G>T
+		{Chrom: "12", Pos: 25245360, Ref: "C", Alt: "A", SampleColumns: sample34}, // codon 9
+	} {
+		anns, err := ann.Annotate(v)
+		require.NoError(t, err)
+		ready, err := m.Add(WorkResult{Seq: i, Variant: v, Anns: anns}, VCFHaplotypes(v))
+		require.NoError(t, err)
+		out = append(out, ready...)
+	}
+	out = append(out, m.Flush()...)
+	require.Len(t, out, 3)
+	for i, r := range out {
+		assert.Equal(t, i, r.Seq, "input order kept")
+	}
+	return out
+}
+
+func TestCodonMerger_PhasedCis(t *testing.T) {
+	out := krasCodon12(t, "GT:PS\t0|1:100", "GT:PS\t0|1:100")
+
+	first, second := out[0].Anns[0], out[1].Anns[0]
+	assert.Equal(t, "p.Gly12Val", first.HGVSp, "per-variant annotation kept")
+	assert.Equal(t, "p.Gly12Cys", second.HGVSp)
+	for _, a := range []*Annotation{first, second} {
+		assert.Equal(t, ConsequenceMissenseVariant, a.MergedConsequence)
+		assert.Equal(t, "p.Gly12Phe", a.MergedHGVSp)
+		assert.Equal(t, "ggt/TTt", a.MergedCodonChange)
+	}
+	assert.Equal(t, []string{"12_25245351_C/A"}, first.MergedWith)
+	assert.Equal(t, []string{"12_25245350_C/A"}, second.MergedWith)
+	assert.Empty(t, out[2].Anns[0].MergedWith)
+}
+
+func TestCodonMerger_NotInCis(t *testing.T) {
+	tests := []struct {
+		name               string
+		sample35, sample34 string
+	}{
+		{"trans", "GT:PS\t0|1:100", "GT:PS\t1|0:100"},
+		{"different phase sets", "GT:PS\t0|1:100", "GT:PS\t0|1:200"},
+		{"unphased", "GT\t0/1", "GT\t0/1"},
+		{"different samples", "GT\t1|0\t0|0", "GT\t0|0\t1|0"},
+		{"no genotypes", "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out := krasCodon12(t, tt.sample35, tt.sample34)
+			assert.Empty(t, out[0].Anns[0].MergedHGVSp)
+			assert.Empty(t, out[1].Anns[0].MergedWith)
+		})
+	}
+}
+
+func TestCodonMerger_HomozygousWithUnphased(t *testing.T) {
+	out := krasCodon12(t, "GT\t0/1", "GT\t1/1")
+	assert.Equal(t, "p.Gly12Phe", out[0].Anns[0].MergedHGVSp)
+}
+
+func TestCodonMerger_MAFSample(t *testing.T) {
+	c := createKRASCache()
+	ann := NewAnnotator(c)
+
+	run := func(sample34, sample35 string) []WorkResult {
+		m := NewCodonMerger(c)
+		var out []WorkResult
+		for i, row := range []struct {
+			v      *vcf.Variant
+			sample string
+		}{
+			{&vcf.Variant{Chrom: "12", Pos: 25245351, Ref: "C", Alt: "A"}, sample34},
+			{&vcf.Variant{Chrom: "12", Pos: 25245350, Ref: "C", Alt: "A"}, sample35},
+		} {
+			anns, err := ann.Annotate(row.v)
+			require.NoError(t, err)
+			ready, err := m.Add(WorkResult{Seq: i, Variant: row.v, Anns: anns}, MAFHaplotypes(row.sample))
+			require.NoError(t, err)
+			out = append(out, ready...)
+		}
+		return append(out, m.Flush()...)
+	}
+
+	out := run("TUMOR1", "TUMOR1")
+	assert.Equal(t, "p.Gly12Phe", out[1].Anns[0].MergedHGVSp)
+
+	out = run("TUMOR1", "TUMOR2")
+	assert.Empty(t, out[1].Anns[0].MergedHGVSp)
+}
+
+func TestCodonMerger_StopGained(t *testing.T) {
+	// Codon 12 GGT → TGA needs c.34G>T and c.36T>A.
+	c := createKRASCache()
+	ann := NewAnnotator(c)
+	m := NewCodonMerger(c)
+	var out []WorkResult
+	for i, v := range []*vcf.Variant{
+		{Chrom: "12", Pos: 25245351, Ref: "C", Alt: "A", SampleColumns: "GT\t1|0"},
+		{Chrom: "12", Pos: 25245349, Ref: "A", Alt: "T", SampleColumns: "GT\t1|0"},
+	} {
+		anns, err := ann.Annotate(v)
+		require.NoError(t, err)
+		ready, err := m.Add(WorkResult{Seq: i, Variant: v, Anns: anns}, VCFHaplotypes(v))
+		require.NoError(t, err)
+		out = append(out, ready...)
+	}
+	out = append(out, m.Flush()...)
+	require.Len(t, out, 2)
+	a := out[0].Anns[0]
+	assert.Equal(t, ConsequenceStopGained, a.MergedConsequence)
+	assert.Equal(t, "p.Gly12Ter", a.MergedHGVSp)
+	assert.Equal(t, "ggt/TgA", a.MergedCodonChange)
+}
+
+func TestCodonMerger_Unsorted(t *testing.T) {
+	c := createKRASCache()
+	ann := NewAnnotator(c)
+	m := NewCodonMerger(c)
+	add := func(chrom string, pos int64) error {
+		v := &vcf.Variant{Chrom: chrom, Pos: pos, Ref: "C", Alt: "A", SampleColumns: "GT\t0/1"}
+		anns, err := ann.Annotate(v)
+		require.NoError(t, err)
+		_, err = m.Add(WorkResult{Variant: v, Anns: anns}, VCFHaplotypes(v))
+		return err
+	}
+
+	require.NoError(t, add("12", 25245351))
+	require.NoError(t, add("12", 25245350), "held back variants may come in any order")
+	require.NoError(t, add("12", 25245360))
+	assert.ErrorContains(t, add("12", 25245340), "sorted by position")
+	require.NoError(t, add("13", 100))
+	assert.ErrorContains(t, add("12", 25245400), "12 comes again after 13")
+}
@@ -0,0 +1,226 @@
+package annotate
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/inodb/vibe-vep/internal/cache"
+	"github.com/inodb/vibe-vep/internal/vcf"
+)
+
+// HGVSNormalization is the result of NormalizeHGVS for one input notation.
+type HGVSNormalization struct {
+	Input    string
+	Spec     *VariantSpec
+	Warning  string // from resolving the input: substituted transcript version, ambiguity, missing reference
+	Variants []NormalizedVariant
+}
+
+// NormalizedVariant is one genomic variant an input resolved to, with its
+// canonical HGVS descriptions.
+type NormalizedVariant struct {
+	Variant     *vcf.Variant  // VCF convention; left-aligned if the annotator normalizes
+	HGVSg       string        // e.g. "12:g.25245350C>A"
+	Annotations []*Annotation // overlapping transcripts, each with HGVSc and HGVSp
+	Changes     []HGVSChange  // how the canonical form differs from the input
+}
+
+// HGVSChange records one difference between the input and its canonical
+// description, and why.
+type HGVSChange struct {
+	Field  string // "variant", "transcript", "hgvsg", "hgvsc" or "hgvsp"
+	From   string
+	To     string
+	Reason string
+}
+
+// NormalizeHGVS resolves a variant in any notation ParseVariantSpec accepts
+// and re-derives its canonical descriptions: the HGVSg, and the HGVSc and
+// HGVSp on every overlapping transcript. Changes lists what differs from the
+// input on the transcript (or genome) the input was written against, e.g. a
+// deletion that is not 3'-shifted, an insertion that is a duplication, a
+// missing transcript version or single-letter amino acids.
+//
+// The annotator's reference genome, if any, is used to shift genomic indels
+// and to read intronic and UTR bases; sources resolve rsIDs.
+func NormalizeHGVS(ann *Annotator, c *cache.Cache, sources []AnnotationSource, input string) (*HGVSNormalization, error) {
+	spec, err := ParseVariantSpec(input)
+	if err != nil {
+		return nil, err
+	}
+	ref := ann.Reference()
+	variants, warning, err := ResolveVariantSpec(c, ref, sources, spec)
+	if err != nil {
+		return nil, err
+	}
+
+	res := &HGVSNormalization{Input: strings.TrimSpace(input), Spec: spec}
+	var unshifted bool
+	seen := make(map[string]bool, len(variants))
+	for _, v := range variants {
+		anns, err := ann.Annotate(v)
+		if err != nil {
+			return nil, err
+		}
+		if len(anns) > 0 && anns[0].RefMismatch {
+			warning = joinWarnings(warning, fmt.Sprintf("REF allele %s at %s:%d does not match the reference", v.Ref, v.Chrom, v.Pos))
+		}
+		hgvsg, shifted := FormatHGVSg(ref, v)
+		unshifted = unshifted || !shifted
+		if seen[hgvsg] {
+			continue // equivalent placement of an earlier variant in a repeat
+		}
+		seen[hgvsg] = true
+		nv := NormalizedVariant{Variant: v, HGVSg: hgvsg}
+		for _, a := range anns {
+			if a.HGVSc != "" {
+				nv.Annotations = append(nv.Annotations, a)
+			}
+		}
+		nv.Changes = specChanges(c, spec, res.Input, &nv)
+		res.Variants = append(res.Variants, nv)
+	}
+	if unshifted {
+		warning = joinWarnings(warning, "no reference genome: genomic indels are not 3'-shifted")
+	}
+	res.Warning = warning
+	return res, nil
+}
+
+// specChanges compares the input (and its parsed spec) with the canonical
+// descriptions of one of its variants.
+func specChanges(c *cache.Cache, spec *VariantSpec, input string, nv *NormalizedVariant) []HGVSChange {
+	var changes []HGVSChange
+	add := func(field, from, to, reason string) {
+		changes = append(changes, HGVSChange{Field: field, From: from, To: to, Reason: reason})
+	}
+	v := nv.Variant
+
+	switch spec.Type {
+	case SpecGenomic:
+		if o := v.Original; o != nil {
+			add("variant",
+				fmt.Sprintf("%s:%d:%s:%s", spec.Chrom, o.Pos, o.Ref, o.Alt),
+				fmt.Sprintf("%s:%d:%s:%s", spec.Chrom, v.Pos, v.Ref, v.Alt),
+				"left-aligned and trimmed against the reference")
+		}
+
+	case SpecHGVSg:
+		from := spec.GenomicChange
+		to := nv.HGVSg[strings.Index(nv.HGVSg, ":g.")+3:]
+		if from != to {
+			add("hgvsg", spec.Chrom+":g."+from, nv.HGVSg, hgvsChangeReason(from, to))
+		}
+
+	case SpecHGVSc:
+		lookup := FindHGVScTranscriptWithWarning(c, spec.TranscriptID)
+		t := lookup.Transcript
+		if t == nil {
+			break
+		}
+		if spec.TranscriptID != t.ID {
+			reason := "gene resolved to its canonical transcript"
+			switch {
+			case lookup.Warning != "":
+				reason = "requested transcript version is not in the transcript cache"
+			case isTranscriptAccession(spec.TranscriptID):
+				reason = "transcript version added"
+			}
+			add("transcript", spec.TranscriptID, t.ID, reason)
+		}
+		if a := annotationOn(nv.Annotations, t.ID); a != nil {
+			from, to := spec.CDSChange, hgvsEdit(a.HGVSc)
+			if from != to {
+				add("hgvsc", "c."+from, a.HGVSc, hgvsChangeReason(from, to))
+			}
+		}
+
+	case SpecProtein:
+		t, err := findProteinTranscript(c, spec.GeneName)
+		if err != nil {
+			break
+		}
+		if a := annotationOn(nv.Annotations, t.ID); a != nil && a.HGVSp != "" && !strings.HasSuffix(input, hgvsEdit(a.HGVSp)) {
+			from, to := spec.ProteinChange(), proteinSingleLetter(hgvsEdit(a.HGVSp))
+			reason := "amino acids are written with three-letter codes"
+			if from != to {
+				reason = hgvsChangeReason(from, to)
+			}
+			add("hgvsp", "p."+from, a.HGVSp, reason)
+		}
+	}
+	return changes
+}
+
+// annotationOn returns the annotation on the given transcript, or nil.
+func annotationOn(anns []*Annotation, transcriptID string) *Annotation {
+	for _, a := range anns {
+		if a.TranscriptID == transcriptID {
+			return a
+		}
+	}
+	return nil
+}
+
+// hgvsEdit strips the coordinate prefix ("c.", "n.", "p.") from an HGVS
+// description.
+func hgvsEdit(hgvs string) string {
+	if len(hgvs) > 2 && hgvs[1] == '.' {
+		return hgvs[2:]
+	}
+	return hgvs
+}
+
+var (
+	reThreeLetterAA = regexp.MustCompile(`[A-Z][a-z]{2}`)
+	reEditBases     = regexp.MustCompile(`(del|dup)[ACGTN]+$`)
+)
+
+// proteinSingleLetter rewrites the three-letter amino acid codes of a
+// protein change with single-letter codes ("Gly12Cys" → "G12C").
+func proteinSingleLetter(change string) string {
+	return reThreeLetterAA.ReplaceAllStringFunc(change, func(code string) string {
+		if aa := threeLetterToSingle(code); aa != 0 {
+			return string(aa)
+		}
+		return code
+	})
+}
+
+// hgvsEditKind returns the type of an HGVS edit: "delins", "del", "dup",
+// "ins", "fs", "sub" or "" if unrecognized.
+func hgvsEditKind(edit string) string {
+	switch {
+	case strings.Contains(edit, "delins"):
+		return "delins"
+	case strings.Contains(edit, "fs"):
+		return "fs"
+	case strings.Contains(edit, "del"):
+		return "del"
+	case strings.Contains(edit, "dup"):
+		return "dup"
+	case strings.Contains(edit, "ins"):
+		return "ins"
+	case strings.Contains(edit, ">"), strings.HasSuffix(edit, "="):
+		return "sub"
+	}
+	return ""
+}
+
+// hgvsChangeReason explains why the canonical edit to differs from the
+// input edit from (both without their coordinate prefix).
+func hgvsChangeReason(from, to string) string {
+	fromKind, toKind := hgvsEditKind(from), hgvsEditKind(to)
+	switch {
+	case fromKind == "ins" && toKind == "dup":
+		return "an insertion of a copy of the preceding sequence is a duplication"
+	case reEditBases.ReplaceAllString(from, "$1") == to:
+		return "deleted or duplicated bases are implied by the positions"
+	case fromKind == toKind && (fromKind == "del" || fromKind == "dup" || fromKind == "ins"):
+		return "shifted to the most 3' equivalent position (HGVS 3' rule)"
+	case fromKind != "" && toKind == "delins":
+		return "a multi-base change is written as a deletion-insertion"
+	}
+	return "re-derived from the genomic variant"
+}
@@ -0,0 +1,125 @@
+package annotate
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/inodb/vibe-vep/internal/vcf"
+)
+
+func TestFormatHGVSg(t *testing.T) {
+	ref := seqReference{"1": hgvscTestGenome}
+
+	tests := []struct {
+		name     string
+		pos      int64
+		ref, alt string
+		want     string
+	}{
+		{"snv", 12, "G", "A", "1:g.12G>A"},
+		{"chr prefix", 12, "G", "A", "1:g.12G>A"},
+		{"deletion shifted 3'", 12, "GC", "G", "1:g.14del"},
+		{"MAF deletion", 13, "C", "", "1:g.14del"},
+		{"insertion is a dup", 12, "G", "GC", "1:g.14dup"},
+		{"MAF insertion", 12, "", "C", "1:g.14dup"},
+		{"insertion", 12, "G", "GA", "1:g.12_13insA"},
+		{"mnv", 12, "GC", "AT", "1:g.12_13delinsAT"},
+		{"delins", 12, "GCC", "A", "1:g.12_14delinsA"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chrom := "1"
+			if tt.name == "chr prefix" {
+				chrom = "chr1"
+			}
+			got, shifted := FormatHGVSg(ref, &vcf.Variant{Chrom: chrom, Pos: tt.pos, Ref: tt.ref, Alt: tt.alt})
+			assert.Equal(t, tt.want, got)
+			assert.True(t, shifted)
+		})
+	}
+
+	// Without a reference, indels stay where they are.
+	got, shifted := FormatHGVSg(nil, &vcf.Variant{Chrom: "1", Pos: 12, Ref: "GC", Alt: "G"})
+	assert.Equal(t, "1:g.13del", got)
+	assert.False(t, shifted)
+}
+
+func TestNormalizeHGVS(t *testing.T) {
+	c := createHGVScTestCache()
+	ann := NewAnnotator(c)
+	ann.SetReference(seqReference{"1": hgvscTestGenome})
+
+	tests := []struct {
+		input  string
+		hgvsg  string
+		hgvsc  string
+		field  string
+		reason string
+	}{
+		{"HGVSC:c.3_4insC", "1:g.14dup", "c.5dup", "hgvsc", "duplication"},
+		{"HGVSC:c.4del", "1:g.14del", "c.5del", "hgvsc", "3' rule"},
+		{"HGVSC:c.5delC", "1:g.14del", "c.5del", "hgvsc", "implied by the positions"},
+		{"ENST_HGVSC.2:c.5del", "1:g.14del", "c.5del", "transcript", "version"},
+		{"1:g.13del", "1:g.14del", "c.5del", "hgvsg", "3' rule"},
+		{"1:g.12_13insC", "1:g.14dup", "c.5dup", "hgvsg", "duplication"},
+		{"1:13:CC:C", "1:g.14del", "c.5del", "variant", "left-aligned"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			res, err := NormalizeHGVS(ann, c, nil, tt.input)
+			require.NoError(t, err)
+			require.Len(t, res.Variants, 1, "equivalent placements are merged")
+			nv := res.Variants[0]
+			assert.Equal(t, tt.hgvsg, nv.HGVSg)
+			require.Len(t, nv.Annotations, 1)
+			assert.Equal(t, "ENST_HGVSC", nv.Annotations[0].TranscriptID)
+			assert.Equal(t, tt.hgvsc, nv.Annotations[0].HGVSc)
+			var change *HGVSChange
+			for i := range nv.Changes {
+				if nv.Changes[i].Field == tt.field {
+					change = &nv.Changes[i]
+				}
+			}
+			require.NotNil(t, change, "changes: %+v", nv.Changes)
+			assert.Contains(t, change.Reason, tt.reason)
+		})
+	}
+
+	// Canonical input is reported unchanged.
+	res, err := NormalizeHGVS(ann, c, nil, "ENST_HGVSC:c.5del")
+	require.NoError(t, err)
+	require.Len(t, res.Variants, 1)
+	assert.Empty(t, res.Variants[0].Changes)
+	assert.Empty(t, res.Warning)
+}
+
+func TestNormalizeHGVS_Protein(t *testing.T) {
+	c := createKRASCache()
+	ann := NewAnnotator(c)
+
+	res, err := NormalizeHGVS(ann, c, nil, "KRAS G12C")
+	require.NoError(t, err)
+	require.NotEmpty(t, res.Variants)
+	nv := res.Variants[0]
+	assert.Equal(t, "12:g.25245351C>A", nv.HGVSg)
+	require.Len(t, nv.Changes, 1)
+	assert.Equal(t, HGVSChange{Field: "hgvsp", From: "p.G12C", To: "p.Gly12Cys", Reason: "amino acids are written with three-letter codes"}, nv.Changes[0])
+
+	res, err = NormalizeHGVS(ann, c, nil, "KRAS p.Gly12Cys")
+	require.NoError(t, err)
+	require.NotEmpty(t, res.Variants)
+	assert.Empty(t, res.Variants[0].Changes)
+
+	// Gene names resolve to the canonical transcript.
+	res, err = NormalizeHGVS(ann, c, nil, "KRAS c.34G>T")
+	require.NoError(t, err)
+	require.Len(t, res.Variants, 1)
+	require.Len(t, res.Variants[0].Changes, 1)
+	assert.Equal(t, "transcript", res.Variants[0].Changes[0].Field)
+	assert.Equal(t, "KRAS", res.Variants[0].Changes[0].From)
+
+	_, err = NormalizeHGVS(ann, c, nil, "not a variant")
+	assert.Error(t, err)
+}
@@ -0,0 +1,123 @@
+package annotate
+
+import (
+	"fmt"
+
+	"github.com/inodb/vibe-vep/internal/vcf"
+)
+
+// hgvsgShiftWindow is how many bases are fetched at a time while shifting
+// an indel 3' through a repeat.
+const hgvsgShiftWindow = 64
+
+// FormatHGVSg formats the HGVS genomic notation of a variant, e.g.
+// "12:g.25245350C>A", "5:g.1293969_1293970del" or "7:g.55174772_55174773insTTA".
+// Alleles are trimmed to the changed bases; multi-base substitutions are
+// written as delins.
+//
+// With a reference genome, deletions and insertions are shifted to their
+// 3'-most position (the HGVS 3' rule) and insertions of a copy of the
+// preceding bases are written as duplications. Without one (ref nil) indels
+// are described where they are. The bool reports whether the 3' rule could
+// be applied; it is always true for substitutions and delins.
+func FormatHGVSg(ref ReferenceSequence, v *vcf.Variant) (string, bool) {
+	chrom := v.NormalizeChrom()
+	pos, r, a := trimAlleles(v.Pos, v.Ref, v.Alt)
+	if v.Ref == "" || v.Ref == "-" {
+		pos++ // MAF-style insertion after pos
+	}
+	prefix := chrom + ":g."
+
+	switch {
+	case r == "" && a == "":
+		return prefix + fmt.Sprintf("%d=", v.Pos), true
+	case len(r) == 1 && len(a) == 1:
+		return prefix + fmt.Sprintf("%d%s>%s", pos, r, a), true
+	case r != "" && a != "":
+		return prefix + hgvsgRange(pos, pos+int64(len(r))-1) + "delins" + a, true
+	}
+	if ref == nil {
+		if a == "" {
+			return prefix + hgvsgRange(pos, pos+int64(len(r))-1) + "del", false
+		}
+		return prefix + fmt.Sprintf("%d_%dins%s", pos-1, pos, a), false
+	}
+
+	// next returns the reference base at p, fetching ahead in windows
+	// (one base at a time near the chromosome end).
+	var window string
+	var winStart int64
+	next := func(p int64) (byte, bool) {
+		if window == "" || p < winStart || p >= winStart+int64(len(window)) {
+			w, err := ref.Fetch(v.Chrom, p, p+hgvsgShiftWindow-1)
+			if err != nil {
+				w, err = ref.Fetch(v.Chrom, p, p)
+			}
+			if err != nil || w == "" {
+				return 0, false
+			}
+			window, winStart = w, p
+		}
+		return window[p-winStart], true
+	}
+
+	if a == "" {
+		// Deletion of [pos, pos+len(r)-1]: rotate while the base after it
+		// equals the first deleted base.
+		for {
+			b, ok := next(pos + int64(len(r)))
+			if !ok || b != r[0] {
+				break
+			}
+			r = r[1:] + string(b)
+			pos++
+		}
+		return prefix + hgvsgRange(pos, pos+int64(len(r))-1) + "del", true
+	}
+
+	// Insertion before pos: rotate while the base at pos equals the first
+	// inserted base.
+	for {
+		b, ok := next(pos)
+		if !ok || b != a[0] {
+			break
+		}
+		a = a[1:] + string(b)
+		pos++
+	}
+	start := pos - int64(len(a))
+	if start >= 1 {
+		if prev, err := ref.Fetch(v.Chrom, start, pos-1); err == nil && prev == a {
+			return prefix + hgvsgRange(start, pos-1) + "dup", true
+		}
+	}
+	return prefix + fmt.Sprintf("%d_%dins%s", pos-1, pos, a), true
+}
+
+// trimAlleles removes the bases REF and ALT share, suffix first, then
+// prefix, and returns the position of the first remaining REF base. An
+// insertion has an empty REF and inserts before the returned position.
+func trimAlleles(pos int64, ref, alt string) (int64, string, string) {
+	if ref == "-" {
+		ref = ""
+	}
+	if alt == "-" {
+		alt = ""
+	}
+	for len(ref) > 0 && len(alt) > 0 && ref[len(ref)-1] == alt[len(alt)-1] {
+		ref, alt = ref[:len(ref)-1], alt[:len(alt)-1]
+	}
+	for len(ref) > 0 && len(alt) > 0 && ref[0] == alt[0] {
+		ref, alt = ref[1:], alt[1:]
+		pos++
+	}
+	return pos, ref, alt
+}
+
+// hgvsgRange formats a genomic position or range ("100" or "100_102").
+func hgvsgRange(start, end int64) string {
+	if start == end {
+		return fmt.Sprintf("%d", start)
+	}
+	return fmt.Sprintf("%d_%d", start, end)
+}
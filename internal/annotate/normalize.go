@@ -0,0 +1,143 @@
+package annotate
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"go.uber.org/zap"
+
+	"github.com/inodb/vibe-vep/internal/vcf"
+)
+
+// ReferenceSequence provides reference genome bases. cache.GenomeFASTA
+// implements it.
+type ReferenceSequence interface {
+	// Fetch returns the upper-case bases of the 1-based closed interval [start, end].
+	Fetch(chrom string, start, end int64) (string, error)
+}
+
+// ErrRefMismatch is returned by LeftAlign when the REF allele does not match
+// the reference genome.
+var ErrRefMismatch = errors.New("REF allele does not match reference")
+
+// leftAlignWindow is how many bases are fetched at a time while shifting an
+// indel left through a repeat.
+const leftAlignWindow = 64
+
+// LeftAlign left-aligns and trims a variant against the reference genome, like
+// bcftools norm: shared trailing bases are removed, indels are shifted to the
+// left-most equivalent position, and redundant leading bases are trimmed.
+//
+// VCF-style alleles (indels with an anchor base) are returned in VCF style.
+// MAF-style alleles (empty REF for insertions, with pos the base before the
+// insertion; empty ALT for deletions) are returned in MAF style. SNVs and
+// identical alleles are returned unchanged.
+func LeftAlign(ref ReferenceSequence, chrom string, pos int64, refAllele, altAllele string) (int64, string, string, error) {
+	if refAllele == altAllele || (len(refAllele) == 1 && len(altAllele) == 1) {
+		return pos, refAllele, altAllele, nil
+	}
+	r, a := strings.ToUpper(refAllele), strings.ToUpper(altAllele)
+
+	// Give MAF-style indels a VCF anchor base so both styles share one path.
+	mafStyle := r == "" || a == ""
+	if mafStyle {
+		anchorPos := pos
+		if a == "" {
+			anchorPos = pos - 1
+		}
+		if anchorPos < 1 {
+			return pos, refAllele, altAllele, nil
+		}
+		base, err := ref.Fetch(chrom, anchorPos, anchorPos)
+		if err != nil {
+			return pos, refAllele, altAllele, err
+		}
+		pos, r, a = anchorPos, base+r, base+a
+	}
+
+	// Validate REF against the genome before moving anything.
+	genome, err := ref.Fetch(chrom, pos, pos+int64(len(r))-1)
+	if err != nil {
+		return pos, refAllele, altAllele, err
+	}
+	if genome != r {
+		return pos, refAllele, altAllele, fmt.Errorf("%w at %s:%d: %s vs %s", ErrRefMismatch, chrom, pos, refAllele, genome)
+	}
+
+	// window holds reference bases [winStart, pos-1] to prepend while shifting.
+	var window string
+	var winStart int64
+	prevBase := func() (byte, error) {
+		if winStart == 0 || pos-1 < winStart {
+			winStart = max(1, pos-leftAlignWindow)
+			w, err := ref.Fetch(chrom, winStart, pos-1)
+			if err != nil {
+				return 0, err
+			}
+			window = w
+		}
+		return window[pos-1-winStart], nil
+	}
+
+	for {
+		changed := false
+		if len(r) > 0 && len(a) > 0 && r[len(r)-1] == a[len(a)-1] {
+			r, a = r[:len(r)-1], a[:len(a)-1]
+			changed = true
+		}
+		if len(r) == 0 || len(a) == 0 {
+			if pos <= 1 {
+				break
+			}
+			b, err := prevBase()
+			if err != nil {
+				return pos, refAllele, altAllele, err
+			}
+			pos--
+			r, a = string(b)+r, string(b)+a
+			changed = true
+		}
+		if !changed {
+			break
+		}
+	}
+	for len(r) > 1 && len(a) > 1 && r[0] == a[0] {
+		r, a = r[1:], a[1:]
+		pos++
+	}
+
+	if mafStyle && len(r) != len(a) && r[0] == a[0] {
+		// Drop the anchor again: deletions start after it, insertions keep
+		// pos as the base before the inserted sequence.
+		r, a = r[1:], a[1:]
+		if a == "" {
+			pos++
+		}
+	}
+	return pos, r, a, nil
+}
+
+// normalizeVariant left-aligns v in place against the annotator's reference,
+// recording the input form in v.Original when it changes. Structural
+// variants are left untouched; failures are logged and v is kept as is.
+func (a *Annotator) normalizeVariant(v *vcf.Variant, logger *zap.Logger) {
+	if v.IsStructural() {
+		return
+	}
+	pos, ref, alt, err := LeftAlign(a.reference, v.Chrom, v.Pos, v.Ref, v.Alt)
+	if err != nil {
+		logger.Debug("could not normalize variant",
+			zap.String("chrom", v.Chrom),
+			zap.Int64("pos", v.Pos),
+			zap.Error(err))
+		return
+	}
+	if pos == v.Pos && strings.EqualFold(ref, v.Ref) && strings.EqualFold(alt, v.Alt) {
+		return
+	}
+	if v.Original == nil {
+		v.Original = &vcf.OriginalAllele{Pos: v.Pos, Ref: v.Ref, Alt: v.Alt}
+	}
+	v.Pos, v.Ref, v.Alt = pos, ref, alt
+}
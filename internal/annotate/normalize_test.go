@@ -0,0 +1,98 @@
+package annotate
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/inodb/vibe-vep/internal/cache"
+	"github.com/inodb/vibe-vep/internal/vcf"
+)
+
+// seqReference is an in-memory ReferenceSequence for tests.
+type seqReference map[string]string
+
+func (r seqReference) Fetch(chrom string, start, end int64) (string, error) {
+	seq, ok := r[chrom]
+	if !ok || start < 1 || end > int64(len(seq)) || end < start {
+		return "", fmt.Errorf("%s:%d-%d out of range", chrom, start, end)
+	}
+	return seq[start-1 : end], nil
+}
+
+func TestLeftAlign(t *testing.T) {
+	//                    1         2         3
+	//           123456789012345678901234567890
+	ref := seqReference{"1": "GGGCACACACATTTTTTTAGCTAGGGCCCA"}
+
+	tests := []struct {
+		name             string
+		pos              int64
+		refAl, altAl     string
+		wantPos          int64
+		wantRef, wantAlt string
+	}{
+		// CA repeat at 4-11: deleting one CA unit anywhere shifts to the anchor at 3.
+		{"VCF deletion in repeat", 9, "ACA", "A", 3, "GCA", "G"},
+		{"VCF deletion already left", 3, "GCA", "G", 3, "GCA", "G"},
+		{"VCF insertion in repeat", 11, "A", "ACA", 3, "G", "GCA"},
+		// T homopolymer at 12-18.
+		{"VCF deletion in homopolymer", 17, "TT", "T", 11, "AT", "A"},
+		{"extra trailing context", 16, "TTTA", "TTA", 11, "AT", "A"},
+		{"MAF deletion in homopolymer", 18, "T", "", 12, "T", ""},
+		{"MAF insertion in homopolymer", 18, "", "T", 11, "", "T"},
+		{"MAF insertion in repeat", 11, "", "CA", 3, "", "CA"},
+		{"SNV unchanged", 5, "A", "G", 5, "A", "G"},
+		{"MNV trimmed", 4, "CAC", "CTC", 5, "A", "T"},
+		{"lower case input", 9, "aca", "a", 3, "GCA", "G"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pos, r, a, err := LeftAlign(ref, "1", tt.pos, tt.refAl, tt.altAl)
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantPos, pos)
+			assert.Equal(t, tt.wantRef, r)
+			assert.Equal(t, tt.wantAlt, a)
+		})
+	}
+
+	_, _, _, err := LeftAlign(ref, "1", 9, "GCA", "G")
+	assert.ErrorIs(t, err, ErrRefMismatch)
+}
+
+func TestAnnotator_NormalizesBeforeAnnotation(t *testing.T) {
+	ref := seqReference{"1": "GGGCACACACATTTTTTTAGCTAGGGCCCA"}
+	c := cache.New()
+	c.AddTranscript(&cache.Transcript{
+		ID: "ENST_N", GeneName: "NORM", Chrom: "1", Start: 1, End: 30, Strand: 1,
+		Biotype: "lncRNA", Exons: []cache.Exon{{Number: 1, Start: 1, End: 30, Frame: -1}},
+	})
+	c.BuildIndex()
+
+	ann := NewAnnotator(c)
+	ann.SetReference(ref)
+
+	v := &vcf.Variant{Chrom: "1", Pos: 17, Ref: "TT", Alt: "T"}
+	_, err := ann.Annotate(v)
+	require.NoError(t, err)
+	assert.Equal(t, int64(11), v.Pos)
+	assert.Equal(t, "AT", v.Ref)
+	assert.Equal(t, "A", v.Alt)
+	require.NotNil(t, v.Original)
+	assert.Equal(t, vcf.OriginalAllele{Pos: 17, Ref: "TT", Alt: "T"}, *v.Original)
+
+	// Already normalized: Original stays nil.
+	v = &vcf.Variant{Chrom: "1", Pos: 11, Ref: "AT", Alt: "A"}
+	_, err = ann.Annotate(v)
+	require.NoError(t, err)
+	assert.Nil(t, v.Original)
+
+	// REF mismatch: left untouched.
+	v = &vcf.Variant{Chrom: "1", Pos: 17, Ref: "GG", Alt: "G"}
+	_, err = ann.Annotate(v)
+	require.NoError(t, err)
+	assert.Equal(t, int64(17), v.Pos)
+	assert.Nil(t, v.Original)
+}
@@ -5,6 +5,8 @@ import (
 	"sync"
 	"time"
 
+	"go.uber.org/zap"
+
 	"github.com/inodb/vibe-vep/internal/vcf"
 )
 
@@ -12,7 +14,8 @@ import (
 type WorkItem struct {
 	Seq     int
 	Variant *vcf.Variant
-	Extra   any // caller-specific data (e.g. *maf.MAFAnnotation)
+	Extra   any         // caller-specific data (e.g. *maf.MAFAnnotation)
+	Logger  *zap.Logger // logs annotation messages; nil uses the annotator's logger
 }
 
 // WorkResult holds the annotation output for a single variant.
@@ -42,7 +45,11 @@ func (a *Annotator) ParallelAnnotate(items <-chan WorkItem, workers int) <-chan
 		go func() {
 			defer wg.Done()
 			for item := range items {
-				anns, err := a.Annotate(item.Variant)
+				logger := item.Logger
+				if logger == nil {
+					logger = a.logger
+				}
+				anns, err := a.AnnotateWithLogger(item.Variant, logger)
 				results <- WorkResult{
 					Seq:     item.Seq,
 					Variant: item.Variant,
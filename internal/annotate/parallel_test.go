@@ -15,6 +15,12 @@ type mockLookup struct{}
 
 func (m *mockLookup) FindTranscripts(string, int64) []*cache.Transcript { return nil }
 
+func (m *mockLookup) FindTranscriptsInRange(string, int64, int64) []*cache.Transcript { return nil }
+
+func (m *mockLookup) FindNearestTranscript(string, int64, int64) (*cache.Transcript, int64) {
+	return nil, 0
+}
+
 func makeItems(n int) <-chan WorkItem {
 	ch := make(chan WorkItem, n)
 	for i := range n {
@@ -0,0 +1,93 @@
+package annotate
+
+import (
+	"fmt"
+	"strings"
+
+	"go.uber.org/zap"
+
+	"github.com/inodb/vibe-vep/internal/vcf"
+)
+
+// refMismatchBuildThreshold is the REF mismatch rate above which the input
+// is most likely on a different genome build than the one annotated.
+const refMismatchBuildThreshold = 0.5
+
+// checkRef compares v's REF allele to the reference genome and reports
+// whether it mismatches. Variants that cannot be checked (structural
+// variants, insertions with empty REF, contigs missing from the reference)
+// are not counted. N in either sequence matches any base.
+func (a *Annotator) checkRef(v *vcf.Variant, logger *zap.Logger) bool {
+	if v.Ref == "" || v.Ref == "-" || v.IsStructural() {
+		return false
+	}
+	genome, err := a.reference.Fetch(v.Chrom, v.Pos, v.Pos+int64(len(v.Ref))-1)
+	if err != nil {
+		logger.Debug("could not check REF allele",
+			zap.String("chrom", v.Chrom),
+			zap.Int64("pos", v.Pos),
+			zap.Error(err))
+		return false
+	}
+	a.refChecked.Add(1)
+	if refMatches(v.Ref, genome) {
+		return false
+	}
+	a.refMismatched.Add(1)
+	logger.Debug("REF allele does not match reference",
+		zap.String("chrom", v.Chrom),
+		zap.Int64("pos", v.Pos),
+		zap.String("ref", v.Ref),
+		zap.String("genome", genome))
+	return true
+}
+
+// refMatches compares an input REF allele to upper-case genome bases.
+func refMatches(ref, genome string) bool {
+	if len(ref) != len(genome) {
+		return false
+	}
+	for i := 0; i < len(ref); i++ {
+		r := ref[i] &^ 0x20 // upper-case ASCII letters
+		if r != genome[i] && r != 'N' && genome[i] != 'N' {
+			return false
+		}
+	}
+	return true
+}
+
+// RefCheckStats returns how many variants had their REF allele checked
+// against the reference genome and how many of those mismatched.
+func (a *Annotator) RefCheckStats() (checked, mismatched int64) {
+	return a.refChecked.Load(), a.refMismatched.Load()
+}
+
+// RefMismatchSummary returns a warning describing REF mismatches seen so far
+// against the given assembly, or "" if there were none. When most REF alleles
+// mismatch it suggests the other GRCh build.
+func (a *Annotator) RefMismatchSummary(assembly string) string {
+	checked, mismatched := a.RefCheckStats()
+	if mismatched == 0 {
+		return ""
+	}
+	rate := float64(mismatched) / float64(checked)
+	if rate > refMismatchBuildThreshold {
+		msg := fmt.Sprintf("%.0f%% of REF alleles mismatch %s", 100*rate, assembly)
+		if other := otherAssembly(assembly); other != "" {
+			msg += fmt.Sprintf(" — did you mean %s?", other)
+		}
+		return msg
+	}
+	return fmt.Sprintf("%d of %d REF alleles (%.1f%%) do not match %s", mismatched, checked, 100*rate, assembly)
+}
+
+// otherAssembly returns the other supported GRCh build.
+func otherAssembly(assembly string) string {
+	switch strings.ToUpper(assembly) {
+	case "GRCH38":
+		return "GRCh37"
+	case "GRCH37":
+		return "GRCh38"
+	}
+	return ""
+}
@@ -0,0 +1,72 @@
+package annotate
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/inodb/vibe-vep/internal/cache"
+	"github.com/inodb/vibe-vep/internal/vcf"
+)
+
+func TestAnnotator_RefCheck(t *testing.T) {
+	//                    1         2
+	//           12345678901234567890
+	ref := seqReference{"1": "ACGTACGTNNACGTACGTAC"}
+	c := cache.New()
+	c.BuildIndex()
+
+	ann := NewAnnotator(c)
+	ann.SetReference(ref)
+	ann.SetRefCheck(true)
+
+	tests := []struct {
+		v        vcf.Variant
+		mismatch bool
+	}{
+		{vcf.Variant{Chrom: "1", Pos: 2, Ref: "C", Alt: "T"}, false},
+		{vcf.Variant{Chrom: "1", Pos: 2, Ref: "cg", Alt: "c"}, false},
+		{vcf.Variant{Chrom: "1", Pos: 9, Ref: "A", Alt: "T"}, false}, // N in genome
+		{vcf.Variant{Chrom: "1", Pos: 3, Ref: "N", Alt: "T"}, false}, // N in REF
+		{vcf.Variant{Chrom: "1", Pos: 2, Ref: "", Alt: "T"}, false},  // MAF insertion: not checked
+		{vcf.Variant{Chrom: "2", Pos: 2, Ref: "A", Alt: "T"}, false}, // contig not in reference
+		{vcf.Variant{Chrom: "1", Pos: 2, Ref: "G", Alt: "T"}, true},
+		{vcf.Variant{Chrom: "1", Pos: 11, Ref: "ACGA", Alt: "A"}, true},
+		{vcf.Variant{Chrom: "1", Pos: 19, Ref: "ACG", Alt: "A"}, false}, // runs past the end: not checked
+	}
+	for _, tt := range tests {
+		v := tt.v
+		anns, err := ann.Annotate(&v)
+		require.NoError(t, err)
+		require.NotEmpty(t, anns)
+		assert.Equal(t, tt.mismatch, anns[0].RefMismatch, "%s:%d %s>%s", v.Chrom, v.Pos, v.Ref, v.Alt)
+	}
+
+	checked, mismatched := ann.RefCheckStats()
+	assert.Equal(t, int64(6), checked)
+	assert.Equal(t, int64(2), mismatched)
+	assert.Equal(t, "2 of 6 REF alleles (33.3%) do not match GRCh38", ann.RefMismatchSummary("GRCh38"))
+}
+
+func TestAnnotator_RefMismatchSummary(t *testing.T) {
+	ref := seqReference{"1": "AAAAAAAAAA"}
+	c := cache.New()
+	c.BuildIndex()
+
+	ann := NewAnnotator(c)
+	assert.Empty(t, ann.RefMismatchSummary("GRCh38"), "no reference")
+
+	ann.SetReference(ref)
+	ann.SetRefCheck(true)
+	for pos := int64(1); pos <= 8; pos++ {
+		refAllele := "C"
+		if pos > 7 {
+			refAllele = "A"
+		}
+		_, err := ann.Annotate(&vcf.Variant{Chrom: "1", Pos: pos, Ref: refAllele, Alt: "T"})
+		require.NoError(t, err)
+	}
+	assert.Equal(t, "88% of REF alleles mismatch GRCh38 — did you mean GRCh37?", ann.RefMismatchSummary("GRCh38"))
+	assert.Equal(t, "88% of REF alleles mismatch GRCh37 — did you mean GRCh38?", ann.RefMismatchSummary("GRCh37"))
+}
@@ -13,30 +13,9 @@ import (
 // ReverseMapProteinChange maps a protein change (e.g. KRAS G12C) back to
 // genomic variant(s) using the canonical transcript's CDS sequence.
 func ReverseMapProteinChange(c *cache.Cache, geneName string, refAA byte, protPos int64, altAA byte) ([]*vcf.Variant, error) {
-	transcripts := c.FindTranscriptsByGene(geneName)
-	if len(transcripts) == 0 {
-		return nil, fmt.Errorf("gene %q not found in transcript cache", geneName)
-	}
-
-	// Find canonical protein-coding transcript
-	var canonical *cache.Transcript
-	for _, t := range transcripts {
-		if t.IsCanonicalMSK && t.IsProteinCoding() {
-			canonical = t
-			break
-		}
-	}
-	if canonical == nil {
-		// Fall back to first protein-coding transcript
-		for _, t := range transcripts {
-			if t.IsProteinCoding() {
-				canonical = t
-				break
-			}
-		}
-	}
-	if canonical == nil {
-		return nil, fmt.Errorf("no protein-coding transcript found for gene %q", geneName)
+	canonical, err := findProteinTranscript(c, geneName)
+	if err != nil {
+		return nil, err
 	}
 
 	return reverseMapProtein(canonical, refAA, protPos, altAA)
@@ -124,6 +103,20 @@ func ReverseMapHGVSc(c *cache.Cache, geneOrTranscript string, cdsChange string)
 // ReverseMapHGVScWithWarning is like ReverseMapHGVSc but also returns a warning
 // string if the exact transcript version was requested but not found.
 func ReverseMapHGVScWithWarning(c *cache.Cache, geneOrTranscript string, cdsChange string) ([]*vcf.Variant, string, error) {
+	return ReverseMapHGVScWithReference(c, nil, geneOrTranscript, cdsChange)
+}
+
+// ReverseMapHGVScWithReference is like ReverseMapHGVScWithWarning but reads
+// bases outside the transcript's CDS and 3'UTR sequence (introns, the
+// 5'UTR and flanks) from ref, which may be nil. Without it, deletions,
+// duplications, insertions and delins that need such bases fail, and
+// substitutions there are returned unvalidated with a warning.
+//
+// Supported notation covers the coding DNA grammar: substitutions (35G>T),
+// deletions (923del, 100_102del), duplications (100dup), insertions
+// (76_77insT) and deletion-insertions (100_102delinsAT) at CDS, 5'UTR
+// (-14C>T), 3'UTR (*32del) and intronic (1234+5G>A) positions.
+func ReverseMapHGVScWithReference(c *cache.Cache, ref ReferenceSequence, geneOrTranscript string, cdsChange string) ([]*vcf.Variant, string, error) {
 	// Check for version mismatch warning.
 	result := FindHGVScTranscriptWithWarning(c, geneOrTranscript)
 	warning := result.Warning
@@ -140,7 +133,28 @@ func ReverseMapHGVScWithWarning(c *cache.Cache, geneOrTranscript string, cdsChan
 		return variants, warning, err
 	}
 
-	return nil, warning, fmt.Errorf("unsupported CDS change notation %q (supported: substitutions like 35G>T, deletions like 923del or 100_102del)", cdsChange)
+	// Everything else: UTR and intronic positions, dup, ins and delins
+	if m := reHGVScEdit.FindStringSubmatch(cdsChange); m != nil {
+		if result.Transcript == nil {
+			return nil, warning, fmt.Errorf("transcript %q not found", geneOrTranscript)
+		}
+		variants, note, err := reverseMapHGVScEdit(result.Transcript, ref, m)
+		return variants, joinWarnings(warning, note), err
+	}
+
+	return nil, warning, fmt.Errorf("unsupported CDS change notation %q (supported: substitutions like 35G>T or 1234+5G>A, "+
+		"deletions like 923del or *32del, duplications like 100dup, insertions like 76_77insT and delins like 100_102delinsAT)", cdsChange)
+}
+
+// joinWarnings joins the non-empty warnings with "; ".
+func joinWarnings(warnings ...string) string {
+	var parts []string
+	for _, w := range warnings {
+		if w != "" {
+			parts = append(parts, w)
+		}
+	}
+	return strings.Join(parts, "; ")
 }
 
 // findHGVScTranscript resolves a gene name or transcript ID to a transcript.
@@ -150,6 +164,17 @@ type TranscriptLookupResult struct {
 	Warning    string // non-empty if the exact version was not found
 }
 
+// isTranscriptAccession reports whether id is an Ensembl (ENST) or RefSeq
+// (NM_, NR_, XM_, XR_) transcript ID rather than a gene name.
+func isTranscriptAccession(id string) bool {
+	for _, prefix := range []string{"ENST", "NM_", "NR_", "XM_", "XR_"} {
+		if strings.HasPrefix(id, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
 func findHGVScTranscript(c *cache.Cache, geneOrTranscript string) (*cache.Transcript, error) {
 	result := FindHGVScTranscriptWithWarning(c, geneOrTranscript)
 	if result.Transcript == nil {
@@ -159,9 +184,10 @@ func findHGVScTranscript(c *cache.Cache, geneOrTranscript string) (*cache.Transc
 }
 
 // FindHGVScTranscriptWithWarning looks up a transcript by ID (with optional version)
-// or gene name. Returns a warning if the exact version was requested but not found.
+// (ENST or RefSeq NM_/NR_) or gene name. Returns a warning if the exact
+// version was requested but not found.
 func FindHGVScTranscriptWithWarning(c *cache.Cache, geneOrTranscript string) TranscriptLookupResult {
-	if strings.HasPrefix(geneOrTranscript, "ENST") {
+	if isTranscriptAccession(geneOrTranscript) {
 		// Try exact match first (includes version).
 		transcript := c.GetTranscript(geneOrTranscript)
 		if transcript != nil {
@@ -207,6 +233,9 @@ func reverseMapHGVScSubstitution(c *cache.Cache, geneOrTranscript string, m []st
 	cdsPos, _ := strconv.ParseInt(m[1], 10, 64)
 	cdsRef := m[2]
 	cdsAlt := m[3]
+	if cdsRef == cdsAlt {
+		return nil, fmt.Errorf("substitution at c.%d does not change the base (%s>%s)", cdsPos, cdsRef, cdsAlt)
+	}
 
 	transcript, err := findHGVScTranscript(c, geneOrTranscript)
 	if err != nil {
@@ -588,6 +617,51 @@ func resolveHGVSgDeletion(c *cache.Cache, chrom string, m []string) ([]*vcf.Vari
 	return nil, fmt.Errorf("no transcript with CDS sequence covers positions %s:%d-%d (including padding base)", chrom, padPos, genomicEnd)
 }
 
+// ResolveRSID maps a dbSNP rsID (e.g. "rs121913529") to its alleles using
+// the first source that implements RSIDResolver. Multi-allelic rsIDs give
+// one variant per ALT allele.
+func ResolveRSID(sources []AnnotationSource, rsid string) ([]*vcf.Variant, error) {
+	for _, src := range sources {
+		r, ok := src.(RSIDResolver)
+		if !ok {
+			continue
+		}
+		variants, err := r.LookupRSID(rsid)
+		if err != nil {
+			return nil, err
+		}
+		if len(variants) == 0 {
+			return nil, fmt.Errorf("%s not found in dbSNP", rsid)
+		}
+		return variants, nil
+	}
+	return nil, fmt.Errorf("cannot resolve %s: no dbSNP index loaded (run: vibe-vep download)", rsid)
+}
+
+// ResolveVariantSpec maps a parsed variant specification of any type to its
+// genomic variants in VCF convention. ref (may be nil) is used for HGVSc
+// positions outside the transcript sequence and sources for rsIDs. The
+// warning is non-empty when a transcript version was substituted or the
+// notation does not determine a single genomic event.
+func ResolveVariantSpec(c *cache.Cache, ref ReferenceSequence, sources []AnnotationSource, spec *VariantSpec) ([]*vcf.Variant, string, error) {
+	switch spec.Type {
+	case SpecGenomic:
+		return []*vcf.Variant{{Chrom: spec.Chrom, Pos: spec.Pos, Ref: spec.Ref, Alt: spec.Alt}}, "", nil
+	case SpecProtein:
+		return ReverseMapProteinSpec(c, spec)
+	case SpecHGVSc:
+		return ReverseMapHGVScWithReference(c, ref, spec.TranscriptID, spec.CDSChange)
+	case SpecHGVSg:
+		variants, err := ResolveHGVSg(c, spec.Chrom, spec.GenomicChange)
+		return variants, "", err
+	case SpecRSID:
+		variants, err := ResolveRSID(sources, spec.RSID)
+		return variants, "", err
+	default:
+		return nil, "", fmt.Errorf("unsupported variant spec type %d", spec.Type)
+	}
+}
+
 // findTranscriptByPrefix finds transcripts matching an ID prefix (without version).
 func findTranscriptByPrefix(c *cache.Cache, prefix string) []*cache.Transcript {
 	// Strip version suffix from prefix if present for matching
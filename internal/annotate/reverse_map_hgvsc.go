@@ -0,0 +1,349 @@
+package annotate
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/inodb/vibe-vep/internal/cache"
+	"github.com/inodb/vibe-vep/internal/vcf"
+)
+
+// reHGVScEdit parses the coding DNA grammar handled by reverseMapHGVScEdit:
+// a position or range, each position optionally in the 5'UTR ("-14"), the
+// 3'UTR ("*32") or an intron ("1234+5", "-14-3"), followed by a
+// substitution, delins, del, dup or ins. Deleted and duplicated bases may
+// be given ("100delA", "100_101dupAG") and are then validated.
+var reHGVScEdit = regexp.MustCompile(`^([-*]?\d+(?:[+-]\d+)?)(?:_([-*]?\d+(?:[+-]\d+)?))?` +
+	`(?:([ACGT])>([ACGT])|del([ACGT]*)ins([ACGT]+)|del([ACGT]*)|dup([ACGT]*)|ins([ACGT]+))$`)
+
+// reHGVScPos parses one coding DNA position.
+var reHGVScPos = regexp.MustCompile(`^([-*]?)(\d+)([+-]\d+)?$`)
+
+// hgvscPos is a coding DNA position: a CDS position (region 0), a distance
+// upstream of the start codon ('-') or downstream of the stop codon ('*'),
+// plus an optional intronic offset towards the 3' end of the transcript.
+type hgvscPos struct {
+	region byte
+	num    int64
+	offset int64
+}
+
+func (p hgvscPos) String() string {
+	s := strconv.FormatInt(p.num, 10)
+	if p.region != 0 {
+		s = string(p.region) + s
+	}
+	if p.offset > 0 {
+		s += "+" + strconv.FormatInt(p.offset, 10)
+	} else if p.offset < 0 {
+		s += strconv.FormatInt(p.offset, 10)
+	}
+	return s
+}
+
+func parseHGVScPos(s string) (hgvscPos, error) {
+	m := reHGVScPos.FindStringSubmatch(s)
+	if m == nil {
+		return hgvscPos{}, fmt.Errorf("invalid coding DNA position %q", s)
+	}
+	var p hgvscPos
+	if m[1] != "" {
+		p.region = m[1][0]
+	}
+	p.num, _ = strconv.ParseInt(m[2], 10, 64)
+	if p.num == 0 {
+		return hgvscPos{}, fmt.Errorf("invalid coding DNA position %q (there is no c.0)", s)
+	}
+	if m[3] != "" {
+		p.offset, _ = strconv.ParseInt(m[3], 10, 64)
+		if p.offset == 0 {
+			return hgvscPos{}, fmt.Errorf("invalid intronic offset in %q", s)
+		}
+	}
+	return p, nil
+}
+
+// cds5Genomic returns the genomic position of the first CDS base (the A of
+// the start codon) and cds3Genomic that of the last (the end of the stop
+// codon).
+func cds5Genomic(t *cache.Transcript) int64 {
+	if t.IsReverseStrand() {
+		return t.CDSEnd
+	}
+	return t.CDSStart
+}
+
+func cds3Genomic(t *cache.Transcript) int64 {
+	if t.IsReverseStrand() {
+		return t.CDSStart
+	}
+	return t.CDSEnd
+}
+
+// hgvscPosToGenomic maps a coding DNA position to a genomic position.
+// UTR positions are counted over exonic bases from the CDS ends and may lie
+// up- or downstream of the transcript; intronic offsets are counted from
+// their exon boundary.
+func hgvscPosToGenomic(t *cache.Transcript, p hgvscPos) (int64, error) {
+	var g int64
+	switch p.region {
+	case '-':
+		g = transcriptPosToGenomic(t, GenomicToTranscriptPos(cds5Genomic(t), t)-p.num)
+	case '*':
+		g = transcriptPosToGenomic(t, GenomicToTranscriptPos(cds3Genomic(t), t)+p.num)
+	default:
+		g = CDSToGenomic(p.num, t)
+		if g == 0 {
+			return 0, fmt.Errorf("c.%s is outside the CDS of transcript %s", p, t.ID)
+		}
+	}
+	if g == 0 {
+		return 0, fmt.Errorf("c.%s could not be mapped to genomic coordinates in transcript %s", p, t.ID)
+	}
+	if p.offset == 0 {
+		return g, nil
+	}
+	if t.FindExonIdx(g) < 0 {
+		return 0, fmt.Errorf("c.%s: intronic offset from c.%s, which is not exonic in transcript %s",
+			p, hgvscPos{region: p.region, num: p.num}, t.ID)
+	}
+	if t.IsReverseStrand() {
+		g -= p.offset
+	} else {
+		g += p.offset
+	}
+	if t.FindExonIdx(g) >= 0 {
+		return 0, fmt.Errorf("c.%s is not intronic in transcript %s", p, t.ID)
+	}
+	return g, nil
+}
+
+// transcriptPosToGenomic converts a 1-based transcript position (counted
+// over exonic bases from the transcript 5' end) to a genomic position.
+// Positions before the first or after the last exonic base continue
+// linearly up- or downstream of the transcript. This is the reverse of
+// GenomicToTranscriptPos.
+func transcriptPosToGenomic(t *cache.Transcript, tp int64) int64 {
+	n := len(t.Exons)
+	if n == 0 {
+		return 0
+	}
+	reverse := t.IsReverseStrand()
+	if tp < 1 {
+		if reverse {
+			return t.Exons[n-1].End + (1 - tp)
+		}
+		return t.Exons[0].Start - (1 - tp)
+	}
+	var cum int64
+	for i := 0; i < n; i++ {
+		exon := &t.Exons[i]
+		if reverse {
+			exon = &t.Exons[n-1-i]
+		}
+		exonLen := exon.End - exon.Start + 1
+		if tp <= cum+exonLen {
+			if reverse {
+				return exon.End - (tp - cum - 1)
+			}
+			return exon.Start + (tp - cum - 1)
+		}
+		cum += exonLen
+	}
+	if reverse {
+		return t.Exons[0].Start - (tp - cum)
+	}
+	return t.Exons[n-1].End + (tp - cum)
+}
+
+// transcriptBase returns the genomic-strand base at pos from the
+// transcript's CDS or 3'UTR sequence.
+func transcriptBase(t *cache.Transcript, pos int64) (byte, bool) {
+	var b byte
+	if cdsPos := GenomicToCDS(pos, t); cdsPos > 0 && cdsPos <= int64(len(t.CDSSequence)) {
+		b = t.CDSSequence[cdsPos-1]
+	} else if len(t.UTR3Sequence) > 0 {
+		tp := GenomicToTranscriptPos(pos, t)
+		if tp == 0 {
+			return 0, false
+		}
+		i := tp - GenomicToTranscriptPos(cds3Genomic(t), t)
+		if i < 1 || i > int64(len(t.UTR3Sequence)) {
+			return 0, false
+		}
+		b = t.UTR3Sequence[i-1]
+	} else {
+		return 0, false
+	}
+	if t.IsReverseStrand() {
+		b = Complement(b)
+	}
+	return b, true
+}
+
+// errNoSequence reports that bases could be read from neither the
+// transcript nor a reference genome.
+type errNoSequence struct {
+	chrom      string
+	start, end int64
+	transcript string
+}
+
+func (e *errNoSequence) Error() string {
+	return fmt.Sprintf("no sequence for %s:%d-%d (outside the CDS and 3'UTR of %s; a reference genome is needed)",
+		e.chrom, e.start, e.end, e.transcript)
+}
+
+// genomicBases returns the genomic-strand bases of [start, end], from the
+// transcript sequence where it covers them and otherwise from ref.
+func genomicBases(t *cache.Transcript, ref ReferenceSequence, start, end int64) (string, error) {
+	buf := make([]byte, 0, end-start+1)
+	for pos := start; pos <= end; pos++ {
+		b, ok := transcriptBase(t, pos)
+		if !ok {
+			break
+		}
+		buf = append(buf, b)
+	}
+	if int64(len(buf)) == end-start+1 {
+		return string(buf), nil
+	}
+	if ref == nil {
+		return "", &errNoSequence{t.Chrom, start, end, t.ID}
+	}
+	return ref.Fetch(t.Chrom, start, end)
+}
+
+// hgvscEditPosition formats the position of an edit for messages.
+func hgvscEditPosition(start, end hgvscPos, isRange bool) string {
+	if isRange {
+		return "c." + start.String() + "_" + end.String()
+	}
+	return "c." + start.String()
+}
+
+// reverseMapHGVScEdit maps a parsed coding DNA edit (see reHGVScEdit) on t
+// to a VCF-convention variant. Bases that the edit does not spell out are
+// read from the transcript sequence or, for intronic and 5'UTR positions,
+// from ref (which may be nil). Bases the edit does give are checked against
+// the sequence; a substitution whose base cannot be checked is returned
+// with a note saying so.
+func reverseMapHGVScEdit(t *cache.Transcript, ref ReferenceSequence, m []string) ([]*vcf.Variant, string, error) {
+	start, err := parseHGVScPos(m[1])
+	if err != nil {
+		return nil, "", err
+	}
+	end := start
+	isRange := m[2] != ""
+	if isRange {
+		if end, err = parseHGVScPos(m[2]); err != nil {
+			return nil, "", err
+		}
+	}
+	where := hgvscEditPosition(start, end, isRange)
+
+	gStart, err := hgvscPosToGenomic(t, start)
+	if err != nil {
+		return nil, "", err
+	}
+	gEnd, err := hgvscPosToGenomic(t, end)
+	if err != nil {
+		return nil, "", err
+	}
+	lo, hi := gStart, gEnd
+	if t.IsReverseStrand() {
+		lo, hi = gEnd, gStart
+	}
+	if lo > hi {
+		return nil, "", fmt.Errorf("invalid range %s: end precedes start in transcript %s", where, t.ID)
+	}
+
+	// toGenomic converts bases given on the transcript strand.
+	toGenomic := func(seq string) string {
+		if t.IsReverseStrand() {
+			return ReverseComplement(seq)
+		}
+		return seq
+	}
+	// checkRef validates bases given on the transcript strand against the
+	// genomic-strand sequence.
+	checkRef := func(given, actual string) error {
+		if given != "" && toGenomic(given) != actual {
+			return fmt.Errorf("reference mismatch at %s: expected %s, got %s in transcript %s",
+				where, given, toGenomic(actual), t.ID)
+		}
+		return nil
+	}
+
+	switch {
+	case m[3] != "": // substitution
+		if isRange {
+			return nil, "", fmt.Errorf("substitution %s%s>%s must be at a single position", where, m[3], m[4])
+		}
+		if m[3] == m[4] {
+			return nil, "", fmt.Errorf("substitution at %s does not change the base (%s>%s)", where, m[3], m[4])
+		}
+		var note string
+		actual, err := genomicBases(t, ref, lo, lo)
+		if err != nil {
+			if _, ok := err.(*errNoSequence); !ok {
+				return nil, "", err
+			}
+			note = fmt.Sprintf("reference base at %s not validated (no transcript or genome sequence)", where)
+		} else if err := checkRef(m[3], actual); err != nil {
+			return nil, "", err
+		}
+		return []*vcf.Variant{{Chrom: t.Chrom, Pos: lo, Ref: toGenomic(m[3]), Alt: toGenomic(m[4])}}, note, nil
+
+	case m[6] != "": // delins
+		deleted, err := genomicBases(t, ref, lo, hi)
+		if err != nil {
+			if _, ok := err.(*errNoSequence); !ok || m[5] == "" || int64(len(m[5])) != hi-lo+1 {
+				return nil, "", fmt.Errorf("delins at %s: %w", where, err)
+			}
+			deleted = toGenomic(m[5])
+		} else if err := checkRef(m[5], deleted); err != nil {
+			return nil, "", err
+		}
+		inserted := toGenomic(m[6])
+		if inserted == deleted {
+			return nil, "", fmt.Errorf("delins at %s does not change the sequence", where)
+		}
+		return []*vcf.Variant{{Chrom: t.Chrom, Pos: lo, Ref: deleted, Alt: inserted}}, "", nil
+
+	case m[9] != "": // insertion
+		if !isRange || hi != lo+1 {
+			return nil, "", fmt.Errorf("insertion %s must be between two adjacent positions", where)
+		}
+		pad, err := genomicBases(t, ref, lo, lo)
+		if err != nil {
+			return nil, "", fmt.Errorf("insertion at %s: %w", where, err)
+		}
+		return []*vcf.Variant{{Chrom: t.Chrom, Pos: lo, Ref: pad, Alt: pad + toGenomic(m[9])}}, "", nil
+	}
+
+	// Deletion or duplication: the VCF record is anchored on the base
+	// before the affected bases.
+	isDup := strings.Contains(m[0], "dup")
+	kind, given := "deletion", m[7]
+	if isDup {
+		kind, given = "duplication", m[8]
+	}
+	if lo < 2 {
+		return nil, "", fmt.Errorf("%s at %s: padding base out of range", kind, where)
+	}
+	seq, err := genomicBases(t, ref, lo-1, hi)
+	if err != nil {
+		return nil, "", fmt.Errorf("%s at %s: %w", kind, where, err)
+	}
+	if err := checkRef(given, seq[1:]); err != nil {
+		return nil, "", err
+	}
+	v := &vcf.Variant{Chrom: t.Chrom, Pos: lo - 1, Ref: seq, Alt: seq[:1]}
+	if isDup {
+		v.Ref, v.Alt = seq[:1], seq
+	}
+	return []*vcf.Variant{v}, "", nil
+}
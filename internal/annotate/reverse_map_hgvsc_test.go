@@ -0,0 +1,173 @@
+package annotate
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/inodb/vibe-vep/internal/cache"
+)
+
+// hgvscTestGenome is chromosome 1 for createHGVScTestCache.
+//
+//	         1         2         3         4         5         6
+//	123456789012345678901234567890123456789012345678901234567890
+const hgvscTestGenome = "ACGTTGCAATGGCCTTAAGGCATCGATCGGTACCATGCATGACTGGATCCAAGCTTCCGG"
+
+// createHGVScTestCache returns a cache with a forward-strand transcript
+// whose sequences are taken from hgvscTestGenome: exons 5-20 and 31-45,
+// CDS 10-40 (c.1-c.11 in exon 1, c.12-c.21 in exon 2) and 3'UTR 41-45.
+func createHGVScTestCache() *cache.Cache {
+	g := hgvscTestGenome
+	c := cache.New()
+	c.AddTranscript(&cache.Transcript{
+		ID:       "ENST_HGVSC",
+		GeneName: "HGVSC",
+		Chrom:    "1",
+		Start:    5,
+		End:      45,
+		Strand:   1,
+		Biotype:  "protein_coding",
+		CDSStart: 10,
+		CDSEnd:   40,
+		Exons: []cache.Exon{
+			{Number: 1, Start: 5, End: 20, CDSStart: 10, CDSEnd: 20, Frame: 0},
+			{Number: 2, Start: 31, End: 45, CDSStart: 31, CDSEnd: 40, Frame: 1},
+		},
+		CDSSequence:  g[9:20] + g[30:40],
+		UTR3Sequence: g[40:45],
+	})
+	return c
+}
+
+func TestReverseMapHGVSc_FullGrammar(t *testing.T) {
+	c := createHGVScTestCache()
+	ref := seqReference{"1": hgvscTestGenome}
+
+	tests := []struct {
+		change   string
+		pos      int64
+		ref, alt string
+	}{
+		{"11+2A>G", 22, "A", "G"},
+		{"12-1G>T", 30, "G", "T"},
+		{"-3C>A", 7, "C", "A"},
+		{"*2A>C", 42, "A", "C"},
+		{"*2del", 41, "GA", "G"},
+		{"*2delA", 41, "GA", "G"},
+		{"5_6insT", 14, "C", "CT"},
+		{"5dup", 13, "C", "CC"},
+		{"5_6dupCT", 13, "C", "CCT"},
+		{"5_7delinsAT", 14, "CTT", "AT"},
+		{"5delCinsG", 14, "C", "G"},
+		{"-3del", 6, "GC", "G"},
+		{"11+2_11+3del", 21, "CAT", "C"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.change, func(t *testing.T) {
+			variants, warning, err := ReverseMapHGVScWithReference(c, ref, "HGVSC", tt.change)
+			require.NoError(t, err)
+			assert.Empty(t, warning)
+			require.Len(t, variants, 1)
+			v := variants[0]
+			assert.Equal(t, "1", v.Chrom)
+			assert.Equal(t, tt.pos, v.Pos)
+			assert.Equal(t, tt.ref, v.Ref)
+			assert.Equal(t, tt.alt, v.Alt)
+			assert.Equal(t, hgvscTestGenome[v.Pos-1:v.Pos-1+int64(len(v.Ref))], v.Ref, "REF matches the genome")
+		})
+	}
+}
+
+func TestReverseMapHGVSc_WithoutReference(t *testing.T) {
+	c := createHGVScTestCache()
+
+	// Transcript sequence covers the CDS and 3'UTR.
+	variants, warning, err := ReverseMapHGVScWithWarning(c, "HGVSC", "*2del")
+	require.NoError(t, err)
+	assert.Empty(t, warning)
+	require.Len(t, variants, 1)
+	assert.Equal(t, int64(41), variants[0].Pos)
+
+	// Substitutions outside it are mapped but not validated.
+	variants, warning, err = ReverseMapHGVScWithWarning(c, "HGVSC", "11+2A>G")
+	require.NoError(t, err)
+	assert.Contains(t, warning, "not validated")
+	require.Len(t, variants, 1)
+	assert.Equal(t, int64(22), variants[0].Pos)
+
+	// Other edits there need the genome.
+	_, _, err = ReverseMapHGVScWithWarning(c, "HGVSC", "-3del")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "reference genome is needed")
+}
+
+func TestReverseMapHGVSc_GrammarErrors(t *testing.T) {
+	c := createHGVScTestCache()
+	ref := seqReference{"1": hgvscTestGenome}
+
+	tests := []struct {
+		change string
+		want   string
+	}{
+		{"*2G>C", "reference mismatch at c.*2: expected G, got A"},
+		{"5delA", "reference mismatch at c.5: expected A, got C"},
+		{"5_6dupCC", "reference mismatch"},
+		{"5_7delinsCTT", "does not change"},
+		{"5_7insT", "adjacent"},
+		{"*2_*1del", "invalid range"},
+		{"-6+1A>G", "not exonic"},
+		{"5+2A>G", "not intronic"},
+		{"11+12A>G", "not intronic"},
+		{"30dup", "outside the CDS"},
+		{"5_6A>G", "single position"},
+		{"-6C>C", "does not change the base"},
+		{"5C>C", "does not change the base"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.change, func(t *testing.T) {
+			_, _, err := ReverseMapHGVScWithReference(c, ref, "HGVSC", tt.change)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.want)
+		})
+	}
+}
+
+func TestReverseMapHGVSc_KRAS_UTRAndIntron(t *testing.T) {
+	c := createKRASCache()
+
+	// Reverse strand: bases are complemented and ranges flipped.
+	variants, err := ReverseMapHGVSc(c, "KRAS", "35_36insA")
+	require.NoError(t, err)
+	require.Len(t, variants, 1)
+	assert.Equal(t, int64(25245349), variants[0].Pos)
+	assert.Equal(t, "A", variants[0].Ref)
+	assert.Equal(t, "AT", variants[0].Alt)
+
+	variants, err = ReverseMapHGVSc(c, "KRAS", "34_35delinsTT")
+	require.NoError(t, err)
+	require.Len(t, variants, 1)
+	assert.Equal(t, int64(25245350), variants[0].Pos)
+	assert.Equal(t, "CC", variants[0].Ref)
+	assert.Equal(t, "AA", variants[0].Alt)
+
+	tests := []struct {
+		change string
+		pos    int64
+	}{
+		{"-5C>T", 25245389},    // 5'UTR in exon 2
+		{"-20C>T", 25250759},   // 5'UTR in exon 1, across the intron
+		{"111+1G>A", 25245273}, // donor site after exon 2
+		{"112-2A>G", 25227414}, // acceptor site before exon 3
+	}
+	for _, tt := range tests {
+		t.Run(tt.change, func(t *testing.T) {
+			variants, warning, err := ReverseMapHGVScWithWarning(c, "KRAS", tt.change)
+			require.NoError(t, err)
+			assert.Contains(t, warning, "not validated")
+			require.Len(t, variants, 1)
+			assert.Equal(t, tt.pos, variants[0].Pos)
+		})
+	}
+}
@@ -0,0 +1,397 @@
+package annotate
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/inodb/vibe-vep/internal/cache"
+	"github.com/inodb/vibe-vep/internal/vcf"
+)
+
+// ReverseMapProteinSpec maps a parsed protein change (substitution, inframe
+// deletion, duplication, insertion, delins or frameshift) on the gene's
+// canonical transcript to its candidate genomic variants. The warning is
+// non-empty when the change does not determine a single genomic event.
+func ReverseMapProteinSpec(c *cache.Cache, spec *VariantSpec) ([]*vcf.Variant, string, error) {
+	t, err := findProteinTranscript(c, spec.GeneName)
+	if err != nil {
+		return nil, "", err
+	}
+	var variants []*vcf.Variant
+	var warning string
+	switch spec.ProteinType {
+	case ProteinSubstitution:
+		variants, err = reverseMapProtein(t, spec.RefAA, spec.Position, spec.AltAA)
+	case ProteinFrameshift:
+		variants, err = reverseMapFrameshift(t, spec)
+	default:
+		variants, warning, err = reverseMapInframe(t, spec)
+	}
+	if err != nil {
+		return nil, "", err
+	}
+	if len(variants) > 1 {
+		ambiguity := fmt.Sprintf("%s %s maps to %d candidate genomic variants on %s", spec.GeneName, spec.ProteinChange(), len(variants), t.ID)
+		if warning == "" {
+			warning = ambiguity
+		} else {
+			warning = ambiguity + "; " + warning
+		}
+	}
+	return variants, warning, nil
+}
+
+// findProteinTranscript returns the gene's canonical protein-coding
+// transcript, else its first protein-coding transcript.
+func findProteinTranscript(c *cache.Cache, geneName string) (*cache.Transcript, error) {
+	transcripts := c.FindTranscriptsByGene(geneName)
+	if len(transcripts) == 0 {
+		return nil, fmt.Errorf("gene %q not found in transcript cache", geneName)
+	}
+	for _, t := range transcripts {
+		if t.IsCanonicalMSK && t.IsProteinCoding() {
+			return t, nil
+		}
+	}
+	for _, t := range transcripts {
+		if t.IsProteinCoding() {
+			return t, nil
+		}
+	}
+	return nil, fmt.Errorf("no protein-coding transcript found for gene %q", geneName)
+}
+
+// checkProteinRef verifies the reference residues of a protein change.
+func checkProteinRef(t *cache.Transcript, protein string, spec *VariantSpec) error {
+	for _, r := range []struct {
+		aa  byte
+		pos int64
+	}{{spec.RefAA, spec.Position}, {spec.EndAA, spec.EndPosition}} {
+		if r.pos == 0 {
+			continue
+		}
+		if r.pos < 1 || r.pos > int64(len(protein)) {
+			return fmt.Errorf("protein position %d out of range for transcript %s", r.pos, t.ID)
+		}
+		if got := protein[r.pos-1]; got != r.aa {
+			return fmt.Errorf("reference amino acid mismatch at position %d: expected %c, got %c in transcript %s",
+				r.pos, r.aa, got, t.ID)
+		}
+	}
+	return nil
+}
+
+// cdsEdit replaces Del bases of the CDS at 0-based offset Start with Ins.
+type cdsEdit struct {
+	Start int
+	Del   int
+	Ins   string
+}
+
+func (e cdsEdit) apply(cds string) string {
+	return cds[:e.Start] + e.Ins + cds[e.Start+e.Del:]
+}
+
+// reverseMapInframe maps inframe deletions, duplications, insertions and
+// delins. Candidate CDS deletions (net loss) or duplications (net gain)
+// near the change are kept when they give the expected protein; repeats
+// give several candidates. Changes no deletion or duplication explains are
+// back-translated, which the warning reports.
+func reverseMapInframe(t *cache.Transcript, spec *VariantSpec) ([]*vcf.Variant, string, error) {
+	if len(t.CDSSequence) == 0 {
+		return nil, "", fmt.Errorf("transcript %s has no CDS sequence", t.ID)
+	}
+	cds := t.CDSSequence
+	protein := TranslateSequence(cds)
+	if err := checkProteinRef(t, protein, spec); err != nil {
+		return nil, "", err
+	}
+
+	// The change replaces residues a..b (1-based; b = a-1 for insertions)
+	// with repl.
+	end := spec.EndPosition
+	if end == 0 {
+		end = spec.Position
+	}
+	var a, b int
+	var repl string
+	switch spec.ProteinType {
+	case ProteinDeletion:
+		a, b = int(spec.Position), int(end)
+	case ProteinDuplication:
+		a, b, repl = int(end)+1, int(end), protein[spec.Position-1:end]
+	case ProteinInsertion:
+		a, b, repl = int(end), int(end)-1, spec.InsertedAAs
+	case ProteinDelIns:
+		a, b, repl = int(spec.Position), int(end), spec.InsertedAAs
+	default:
+		return nil, "", fmt.Errorf("unsupported protein change %s", spec.ProteinChange())
+	}
+	expected := protein[:a-1] + repl + protein[b:]
+	delta := 3 * (len(repl) - (b - a + 1))
+
+	// Search CDS offsets covering every protein-equivalent placement.
+	lo, hi := proteinShiftRange(protein, a, b, repl)
+	var edits []cdsEdit
+	for s0 := max(0, 3*(lo-2)); s0 <= min(len(cds), 3*(hi+1)); s0++ {
+		switch {
+		case delta < 0 && s0-delta <= len(cds):
+			edits = append(edits, cdsEdit{Start: s0, Del: -delta})
+		case delta > 0 && s0 >= delta:
+			edits = append(edits, cdsEdit{Start: s0, Ins: cds[s0-delta : s0]})
+		}
+	}
+	variants := mapCDSEdits(t, edits, func(mut string) bool {
+		return inframeMatches(mut, expected, lo, hi, delta)
+	})
+	if len(variants) > 0 {
+		return variants, "", nil
+	}
+	if repl == "" {
+		return nil, "", fmt.Errorf("no CDS deletion gives %s on transcript %s", spec.ProteinChange(), t.ID)
+	}
+
+	// Back-translate the new residues, reusing reference codons where
+	// possible, and trim the bases that do not change.
+	refBases := cds[3*(a-1) : 3*b]
+	var alt strings.Builder
+	for i := 0; i < len(repl); i++ {
+		var near string
+		if 3*(a-1+i)+3 <= len(cds) {
+			near = cds[3*(a-1+i) : 3*(a-1+i)+3]
+		}
+		alt.WriteString(backTranslate(repl[i], near))
+	}
+	edit := trimCDSEdit(cdsEdit{Start: 3 * (a - 1), Del: len(refBases), Ins: alt.String()}, cds)
+	v, err := buildCDSEditVariant(t, edit)
+	if err != nil {
+		return nil, "", fmt.Errorf("%s on transcript %s: %w", spec.ProteinChange(), t.ID, err)
+	}
+	return []*vcf.Variant{v}, fmt.Sprintf("inserted residues of %s were back-translated; the protein change does not determine the inserted bases", spec.ProteinChange()), nil
+}
+
+// proteinShiftRange returns the residues a change replacing protein[a-1:b]
+// with repl may be moved across in a repeat, widened to cover a and b.
+func proteinShiftRange(protein string, a, b int, repl string) (lo, hi int) {
+	lo, hi = a, b
+	if hi < lo {
+		hi = lo
+	}
+	switch {
+	case repl == "" && b >= a:
+		pos := equivalentCDSDeletionPositions(protein, int64(a), int64(b))
+		lo = int(pos[0])
+		hi = int(pos[len(pos)-1]) + (b - a)
+	case b < a:
+		// Insertion before residue a: shift while the rotated insertion
+		// matches the neighbouring residue.
+		for ins, k := repl, a; k > 1 && protein[k-2] == ins[len(ins)-1]; k-- {
+			ins = ins[len(ins)-1:] + ins[:len(ins)-1]
+			lo = k - 1
+		}
+		for ins, k := repl, a; k <= len(protein) && protein[k-1] == ins[0]; k++ {
+			ins = ins[1:] + ins[:1]
+			hi = k + 1
+		}
+	}
+	return lo, min(hi, len(protein))
+}
+
+// inframeMatches reports whether the mutated CDS translates to the expected
+// protein over residues lo..hi (and one either side), where the candidate
+// edits are. Outside that window both are the reference shifted by delta
+// bases.
+func inframeMatches(mut, expected string, lo, hi, delta int) bool {
+	from := max(0, lo-2)
+	to := min(hi+2+max(delta, 0)/3, len(expected), len(mut)/3)
+	if from >= to {
+		return false
+	}
+	return TranslateSequence(mut[3*from:3*to]) == expected[from:to]
+}
+
+// reverseMapFrameshift maps a frameshift at a residue to the 1-2 base
+// deletions and 1 base insertions whose first changed residue it is,
+// checking the new residue and stop distance when given.
+func reverseMapFrameshift(t *cache.Transcript, spec *VariantSpec) ([]*vcf.Variant, error) {
+	if len(t.CDSSequence) == 0 {
+		return nil, fmt.Errorf("transcript %s has no CDS sequence", t.ID)
+	}
+	cds := t.CDSSequence
+	protein := TranslateSequence(cds)
+	if err := checkProteinRef(t, protein, spec); err != nil {
+		return nil, err
+	}
+	p := int(spec.Position)
+
+	var edits []cdsEdit
+	for s0 := max(0, 3*(p-2)); s0 <= min(len(cds)-1, 3*p); s0++ {
+		for _, n := range []int{1, 2} {
+			if s0+n <= len(cds) {
+				edits = append(edits, cdsEdit{Start: s0, Del: n})
+			}
+		}
+		for _, base := range []string{"A", "C", "G", "T"} {
+			edits = append(edits, cdsEdit{Start: s0, Ins: base})
+		}
+	}
+	variants := mapCDSEdits(t, edits, func(mut string) bool {
+		// Residues before p are unchanged and p is the first changed one.
+		from := max(0, p-3)
+		if 3*(p-1) > len(mut) || TranslateSequence(mut[3*from:3*(p-1)]) != protein[from:p-1] {
+			return false
+		}
+		tail := translateToStop(mut[3*(p-1):] + t.UTR3Sequence)
+		if tail == "" || tail[0] == protein[p-1] {
+			return false
+		}
+		if spec.AltAA != 0 && tail[0] != spec.AltAA {
+			return false
+		}
+		if spec.FrameshiftStop > 0 {
+			stop := strings.IndexByte(tail, '*')
+			return stop >= 0 && int64(stop+1) == spec.FrameshiftStop
+		}
+		return true
+	})
+	if len(variants) == 0 {
+		return nil, fmt.Errorf("no single or double base deletion or single base insertion gives %s on transcript %s",
+			spec.ProteinChange(), t.ID)
+	}
+	return variants, nil
+}
+
+// translateToStop translates seq up to and including the first stop codon.
+func translateToStop(seq string) string {
+	var b strings.Builder
+	for i := 0; i+3 <= len(seq); i += 3 {
+		aa := TranslateCodon(seq[i : i+3])
+		b.WriteByte(aa)
+		if aa == '*' {
+			break
+		}
+	}
+	return b.String()
+}
+
+// mapCDSEdits applies each edit to the CDS and returns the genomic variants
+// of those accepted by match, one per distinct mutated sequence (edits
+// within a repeat give the same sequence), ordered by position.
+func mapCDSEdits(t *cache.Transcript, edits []cdsEdit, match func(mut string) bool) []*vcf.Variant {
+	byResult := make(map[string]*vcf.Variant)
+	for _, e := range edits {
+		mut := e.apply(t.CDSSequence)
+		if !match(mut) {
+			continue
+		}
+		v, err := buildCDSEditVariant(t, e)
+		if err != nil {
+			continue
+		}
+		// Keep the leftmost genomic representation of each result.
+		if prev, ok := byResult[mut]; !ok || v.Pos < prev.Pos {
+			byResult[mut] = v
+		}
+	}
+	variants := make([]*vcf.Variant, 0, len(byResult))
+	for _, v := range byResult {
+		variants = append(variants, v)
+	}
+	sort.Slice(variants, func(i, j int) bool {
+		if variants[i].Pos != variants[j].Pos {
+			return variants[i].Pos < variants[j].Pos
+		}
+		return variants[i].Alt < variants[j].Alt
+	})
+	return variants
+}
+
+// buildCDSEditVariant converts a CDS edit to a VCF-convention variant.
+// Insertions and deletions get a padding base on their genomic left. The
+// edited span must not cross an intron.
+func buildCDSEditVariant(t *cache.Transcript, e cdsEdit) (*vcf.Variant, error) {
+	cds := t.CDSSequence
+	deleted := cds[e.Start : e.Start+e.Del]
+	// from..to: 1-based CDS span of the variant including any padding base.
+	from, to := int64(e.Start+1), int64(e.Start+e.Del)
+	ref, alt := deleted, e.Ins
+	if len(deleted) != len(e.Ins) || len(deleted) == 0 {
+		if t.IsReverseStrand() {
+			to++
+			if to > int64(len(cds)) {
+				return nil, fmt.Errorf("padding base out of range")
+			}
+			pad := string(cds[to-1])
+			ref, alt = deleted+pad, e.Ins+pad
+		} else {
+			from--
+			if from < 1 {
+				return nil, fmt.Errorf("padding base out of range")
+			}
+			pad := string(cds[from-1])
+			ref, alt = pad+deleted, pad+e.Ins
+		}
+	}
+	gFrom, gTo := CDSToGenomic(from, t), CDSToGenomic(to, t)
+	if gFrom == 0 || gTo == 0 {
+		return nil, fmt.Errorf("CDS positions %d-%d unmapped", from, to)
+	}
+	if span := gTo - gFrom; span != to-from && span != from-to {
+		return nil, fmt.Errorf("CDS positions %d-%d cross an intron", from, to)
+	}
+	pos := gFrom
+	if t.IsReverseStrand() {
+		pos = gTo
+		ref, alt = ReverseComplement(ref), ReverseComplement(alt)
+	}
+	return &vcf.Variant{Chrom: t.Chrom, Pos: pos, Ref: ref, Alt: alt}, nil
+}
+
+// trimCDSEdit drops the leading and trailing bases an edit leaves unchanged.
+func trimCDSEdit(e cdsEdit, cds string) cdsEdit {
+	del := cds[e.Start : e.Start+e.Del]
+	for len(del) > 0 && len(e.Ins) > 0 && del[0] == e.Ins[0] {
+		del, e.Ins = del[1:], e.Ins[1:]
+		e.Start++
+	}
+	for len(del) > 0 && len(e.Ins) > 0 && del[len(del)-1] == e.Ins[len(e.Ins)-1] {
+		del, e.Ins = del[:len(del)-1], e.Ins[:len(e.Ins)-1]
+	}
+	e.Del = len(del)
+	return e
+}
+
+// backTranslate returns a codon for aa, the one closest to near (a
+// reference codon) when given.
+func backTranslate(aa byte, near string) string {
+	best, bestDist := "", 4
+	for _, codon := range sortedCodons {
+		if codonTable[codon] != aa {
+			continue
+		}
+		dist := 3
+		if len(near) == 3 {
+			dist = 0
+			for i := 0; i < 3; i++ {
+				if codon[i] != near[i] {
+					dist++
+				}
+			}
+		}
+		if dist < bestDist {
+			best, bestDist = codon, dist
+		}
+	}
+	return best
+}
+
+// sortedCodons lists the codons of codonTable in a fixed order.
+var sortedCodons = func() []string {
+	codons := make([]string, 0, len(codonTable))
+	for codon := range codonTable {
+		codons = append(codons, codon)
+	}
+	sort.Strings(codons)
+	return codons
+}()
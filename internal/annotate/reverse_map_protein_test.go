@@ -0,0 +1,147 @@
+package annotate
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/inodb/vibe-vep/internal/cache"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// hgvspOn returns the HGVSp of the annotation on the given transcript.
+func hgvspOn(t *testing.T, anns []*Annotation, transcriptID string) string {
+	t.Helper()
+	for _, a := range anns {
+		if a.TranscriptID == transcriptID {
+			return a.HGVSp
+		}
+	}
+	t.Fatalf("no annotation on %s", transcriptID)
+	return ""
+}
+
+func TestReverseMapProteinSpec_KRAS(t *testing.T) {
+	c := createKRASCache()
+	ann := NewAnnotator(c)
+
+	tests := []struct {
+		input     string
+		wantHGVSp string // HGVSp of every candidate
+		wantN     int    // number of candidates, 0 for any
+		warning   string // substring of the warning, "" for none
+	}{
+		// GGT GGC: duplicating GGTGGC or TGGTGG both give GGGG.
+		{"KRAS G12_G13dup", "p.Gly12_Gly13dup", 2, "2 candidate genomic variants"},
+		{"KRAS p.Gly12_Gly13dup", "p.Gly12_Gly13dup", 2, "2 candidate genomic variants"},
+		{"KRAS G13_V14insGG", "p.Gly12_Gly13dup", 2, "2 candidate genomic variants"},
+		{"KRAS V9del", "p.Val9del", 0, "candidate genomic variants"},
+		{"KRAS G13_V14insW", "p.Gly13_Val14insTrp", 1, "back-translated"},
+		{"KRAS Q61X", "p.Gln61Ter", 1, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			spec, err := ParseVariantSpec(tt.input)
+			require.NoError(t, err)
+			variants, warning, err := ReverseMapProteinSpec(c, spec)
+			require.NoError(t, err)
+			require.NotEmpty(t, variants)
+			if tt.wantN > 0 {
+				assert.Len(t, variants, tt.wantN)
+			} else {
+				assert.Greater(t, len(variants), 1)
+			}
+			if tt.warning == "" {
+				assert.Empty(t, warning)
+			} else {
+				assert.Contains(t, warning, tt.warning)
+			}
+			for _, v := range variants {
+				anns, err := ann.Annotate(v)
+				require.NoError(t, err)
+				assert.Equal(t, tt.wantHGVSp, hgvspOn(t, anns, "ENST00000311936"), "%s:%d %s>%s", v.Chrom, v.Pos, v.Ref, v.Alt)
+			}
+		})
+	}
+}
+
+func TestReverseMapProteinSpec_Frameshift(t *testing.T) {
+	c := createKRASCache()
+	ann := NewAnnotator(c)
+
+	spec, err := ParseVariantSpec("KRAS G12fs")
+	require.NoError(t, err)
+	variants, warning, err := ReverseMapProteinSpec(c, spec)
+	require.NoError(t, err)
+	require.Greater(t, len(variants), 1)
+	assert.Contains(t, warning, "candidate genomic variants")
+	for _, v := range variants {
+		anns, err := ann.Annotate(v)
+		require.NoError(t, err)
+		hgvsp := hgvspOn(t, anns, "ENST00000311936")
+		assert.True(t, strings.HasPrefix(hgvsp, "p.Gly12") && strings.Contains(hgvsp, "fs"), "%s:%d %s>%s gives %s", v.Chrom, v.Pos, v.Ref, v.Alt, hgvsp)
+	}
+
+	// The new residue and stop distance narrow the candidates.
+	first := variants[0]
+	anns, err := ann.Annotate(first)
+	require.NoError(t, err)
+	hgvsp := hgvspOn(t, anns, "ENST00000311936")
+	narrow, err := ParseVariantSpec("KRAS " + strings.TrimPrefix(hgvsp, "p."))
+	require.NoError(t, err)
+	narrowed, _, err := ReverseMapProteinSpec(c, narrow)
+	require.NoError(t, err)
+	assert.Less(t, len(narrowed), len(variants))
+	assert.Contains(t, narrowed, first)
+}
+
+func TestReverseMapProteinSpec_ForwardStrand(t *testing.T) {
+	c := cache.New()
+	c.AddTranscript(&cache.Transcript{
+		ID:             "ENST_FWD",
+		GeneName:       "FWD_GENE",
+		Chrom:          "1",
+		Start:          1000,
+		End:            2000,
+		Strand:         1,
+		Biotype:        "protein_coding",
+		IsCanonicalMSK: true,
+		CDSStart:       1010,
+		CDSEnd:         1099,
+		Exons: []cache.Exon{
+			{Number: 1, Start: 1000, End: 1100, CDSStart: 1010, CDSEnd: 1099, Frame: 0},
+		},
+		CDSSequence: "ATGACTGAATATAAACTTGTGGTAGTTGGAGCTGGTGGCGTAGGCAAGAGTGCCTTGACGATACAGCTAATTCAGAATCATTTTGTGTAA",
+	})
+
+	// E3_Y4del: deleting CDS 7-12 (GAATAT) equals deleting CDS 6-11
+	// (TGAATA), which left-aligns to genomic 1015-1020 padded by C at 1014.
+	spec, err := ParseVariantSpec("FWD_GENE E3_Y4del")
+	require.NoError(t, err)
+	variants, warning, err := ReverseMapProteinSpec(c, spec)
+	require.NoError(t, err)
+	assert.Empty(t, warning)
+	require.Len(t, variants, 1)
+	assert.Equal(t, int64(1014), variants[0].Pos)
+	assert.Equal(t, "CTGAATA", variants[0].Ref)
+	assert.Equal(t, "C", variants[0].Alt)
+}
+
+func TestReverseMapProteinSpec_Errors(t *testing.T) {
+	c := createKRASCache()
+	for _, input := range []string{
+		"KRAS E746_A750del", // reference mismatch
+		"KRAS G12_A13del",   // wrong end residue
+		"KRAS G12_V14insA",  // insertion between non-adjacent residues
+		"BRCA1 Q1756fs",     // gene not in cache
+	} {
+		t.Run(input, func(t *testing.T) {
+			spec, err := ParseVariantSpec(input)
+			if err != nil {
+				return
+			}
+			_, _, err = ReverseMapProteinSpec(c, spec)
+			assert.Error(t, err)
+		})
+	}
+}
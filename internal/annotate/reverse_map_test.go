@@ -4,6 +4,7 @@ import (
 	"testing"
 
 	"github.com/inodb/vibe-vep/internal/cache"
+	"github.com/inodb/vibe-vep/internal/vcf"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -188,7 +189,7 @@ func TestReverseMapHGVSc_GeneNotFound(t *testing.T) {
 func TestReverseMapHGVSc_UnsupportedNotation(t *testing.T) {
 	c := createKRASCache()
 
-	_, err := ReverseMapHGVSc(c, "KRAS", "35delG")
+	_, err := ReverseMapHGVSc(c, "KRAS", "34_36inv")
 	assert.Error(t, err)
 	assert.Contains(t, err.Error(), "unsupported")
 }
@@ -204,6 +205,25 @@ func TestReverseMapHGVSc_ByTranscriptID(t *testing.T) {
 	assert.Equal(t, int64(25245350), v.Pos)
 }
 
+func TestReverseMapHGVSc_ByRefSeqID(t *testing.T) {
+	c := createKRASCache()
+	nm := createKRASTranscript()
+	nm.ID = "NM_004985.5"
+	c.AddTranscript(nm)
+
+	for _, id := range []string{"NM_004985.5", "NM_004985"} {
+		variants, err := ReverseMapHGVSc(c, id, "35G>T")
+		require.NoError(t, err, id)
+		require.Len(t, variants, 1)
+		assert.Equal(t, int64(25245350), variants[0].Pos)
+	}
+
+	result := FindHGVScTranscriptWithWarning(c, "NM_004985.4")
+	require.NotNil(t, result.Transcript)
+	assert.Equal(t, "NM_004985.5", result.Transcript.ID)
+	assert.Contains(t, result.Warning, "NM_004985.4")
+}
+
 func TestReverseMapHGVSc_SingleBaseDel(t *testing.T) {
 	c := createKRASCache()
 
@@ -426,3 +446,37 @@ func TestFindTranscriptsByGene(t *testing.T) {
 	transcripts = c.FindTranscriptsByGene("NONEXISTENT")
 	assert.Empty(t, transcripts)
 }
+
+// rsidSource is an annotation source that resolves rsIDs from a map.
+type rsidSource map[string][]*vcf.Variant
+
+func (rsidSource) Name() string                         { return "rsid" }
+func (rsidSource) Version() string                      { return "test" }
+func (rsidSource) MatchLevel() MatchLevel               { return MatchGenomic }
+func (rsidSource) Columns() []ColumnDef                 { return nil }
+func (rsidSource) Annotate(*vcf.Variant, []*Annotation) {}
+
+func (s rsidSource) LookupRSID(rsid string) ([]*vcf.Variant, error) {
+	return s[rsid], nil
+}
+
+func TestResolveRSID(t *testing.T) {
+	src := rsidSource{"rs121913529": {
+		{Chrom: "12", Pos: 25245350, Ref: "C", Alt: "A", ID: "rs121913529"},
+		{Chrom: "12", Pos: 25245350, Ref: "C", Alt: "G", ID: "rs121913529"},
+	}}
+
+	variants, err := ResolveRSID([]AnnotationSource{src}, "rs121913529")
+	require.NoError(t, err)
+	require.Len(t, variants, 2)
+	assert.Equal(t, "A", variants[0].Alt)
+	assert.Equal(t, "G", variants[1].Alt)
+
+	_, err = ResolveRSID([]AnnotationSource{src}, "rs1")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "not found in dbSNP")
+
+	_, err = ResolveRSID(nil, "rs121913529")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "no dbSNP index loaded")
+}
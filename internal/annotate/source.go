@@ -1,6 +1,11 @@
 package annotate
 
-import "github.com/inodb/vibe-vep/internal/vcf"
+import (
+	"fmt"
+	"os"
+
+	"github.com/inodb/vibe-vep/internal/vcf"
+)
 
 // MatchLevel describes what a source matches on.
 type MatchLevel string
@@ -25,6 +30,31 @@ type AnnotationSource interface {
 	Annotate(v *vcf.Variant, anns []*Annotation)
 }
 
+// RSIDResolver is implemented by sources that can map a dbSNP rsID to its
+// alleles (e.g. the genomic index). LookupRSID returns one VCF-convention
+// variant per ALT allele, or none for an unknown rsID.
+type RSIDResolver interface {
+	LookupRSID(rsid string) ([]*vcf.Variant, error)
+}
+
+// Fingerprinter is implemented by sources whose Version names a release
+// rather than the loaded data. Fingerprint identifies the data itself (e.g.
+// a content hash or the index file's size and modification time), so
+// results cached from it are invalidated when the data changes.
+type Fingerprinter interface {
+	Fingerprint() string
+}
+
+// FileFingerprint returns the size and modification time of path, or ""
+// if it cannot be read.
+func FileFingerprint(path string) string {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return ""
+	}
+	return fmt.Sprintf("%d-%d", fi.Size(), fi.ModTime().UnixNano())
+}
+
 // ColumnDef describes a column provided by an annotation source.
 type ColumnDef struct {
 	Name        string // short name, e.g. "score"
@@ -43,6 +73,7 @@ var CoreColumns = []ColumnDef{
 	{Name: "canonical_mskcc", Description: "MSK canonical transcript"},
 	{Name: "canonical_ensembl", Description: "Ensembl canonical transcript"},
 	{Name: "canonical_mane", Description: "MANE Select transcript"},
+	{Name: "distance", Description: "Distance to transcript for upstream/downstream variants"},
 }
 
 // SetExtra sets a value in the annotation's Extra map.
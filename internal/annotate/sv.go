@@ -0,0 +1,251 @@
+package annotate
+
+import (
+	"strings"
+
+	"github.com/inodb/vibe-vep/internal/cache"
+	"github.com/inodb/vibe-vep/internal/vcf"
+)
+
+// svSpan returns the reference interval affected by a structural variant.
+// For DEL/DUP/INV/CNV the VCF POS is the padding base before the event, so
+// the affected span starts at POS+1. Insertions and breakends are points.
+func svSpan(v *vcf.Variant, svType string) (start, end int64) {
+	end = v.End()
+	switch svType {
+	case vcf.SVTypeINS, vcf.SVTypeBND:
+		return v.Pos, v.Pos
+	}
+	if end > v.Pos {
+		return v.Pos + 1, end
+	}
+	return v.Pos, end
+}
+
+// svClass collapses CNV records into DEL or DUP based on the symbolic
+// allele (<CN0>, <CN1> and <DEL> are losses, everything else is a gain).
+func svClass(v *vcf.Variant, svType string) string {
+	if svType != vcf.SVTypeCNV {
+		return svType
+	}
+	switch v.Alt {
+	case "<DEL>", "<CN0>", "<CN1>":
+		return vcf.SVTypeDEL
+	}
+	return vcf.SVTypeDUP
+}
+
+// PredictSVConsequence determines the effect of a structural variant spanning
+// [start, end] on a transcript. svType is one of the vcf.SVType* constants.
+func PredictSVConsequence(svType string, start, end int64, t *cache.Transcript) *ConsequenceResult {
+	result := &ConsequenceResult{}
+	covers := start <= t.Start && end >= t.End
+
+	var terms []string
+	switch svType {
+	case vcf.SVTypeDEL:
+		if covers {
+			terms = append(terms, ConsequenceTranscriptAblation)
+			break
+		}
+		if exonsWithin(t, start, end) > 0 {
+			terms = append(terms, ConsequenceExonLoss)
+		}
+		if region := svRegionTerm(t, start, end); region != "" {
+			terms = append(terms, region)
+		}
+		terms = append(terms, ConsequenceFeatureTruncation)
+	case vcf.SVTypeDUP:
+		if covers {
+			terms = append(terms, ConsequenceTranscriptAmplification)
+			break
+		}
+		if region := svRegionTerm(t, start, end); region != "" {
+			terms = append(terms, region)
+		}
+		terms = append(terms, ConsequenceFeatureElongation)
+	case vcf.SVTypeINS:
+		if region := svRegionTerm(t, start, end); region != "" {
+			terms = append(terms, region)
+		}
+		terms = append(terms, ConsequenceFeatureElongation)
+	case vcf.SVTypeINV:
+		if covers {
+			// Whole transcript inverted: sequence is intact, only orientation changes.
+			terms = append(terms, ConsequenceTranscriptVariant)
+			break
+		}
+		if region := svRegionTerm(t, start, end); region != "" {
+			terms = append(terms, region)
+		}
+		terms = append(terms, ConsequenceFeatureTruncation)
+	default: // BND and unknown types: a breakpoint disrupts the transcript
+		if region := svRegionTerm(t, start, end); region != "" {
+			terms = append(terms, region)
+		}
+		terms = append(terms, ConsequenceFeatureTruncation)
+	}
+
+	result.Consequence = strings.Join(terms, ",")
+	result.Impact = GetImpact(result.Consequence)
+	result.ExonNumber = svExonRange(t, start, end)
+	return result
+}
+
+// annotateSV annotates a structural variant against every transcript
+// overlapping its span or lying within the upstream/downstream flank. For
+// mated breakends, transcripts at the partner location in a different gene
+// add gene_fusion.
+func (a *Annotator) annotateSV(v *vcf.Variant, chrom string) []*Annotation {
+	svType := v.SVType()
+	start, end := svSpan(v, svType)
+	class := svClass(v, svType)
+	transcripts := a.cache.FindTranscriptsInRange(chrom, start-a.upDownDistance, end+a.upDownDistance)
+
+	var mateGenes map[string]bool
+	if svType == vcf.SVTypeBND {
+		if mateChrom, matePos, ok := v.MateBreakend(); ok {
+			for _, mt := range a.cache.FindTranscripts(mateChrom, matePos) {
+				if mt.GeneName == "" {
+					continue
+				}
+				if mateGenes == nil {
+					mateGenes = make(map[string]bool)
+				}
+				mateGenes[mt.GeneName] = true
+			}
+		}
+	}
+
+	variantID := FormatVariantID(v.Chrom, v.Pos, v.Ref, v.Alt)
+	var annotations []*Annotation
+	for _, t := range transcripts {
+		if a.canonicalOnly && !t.IsCanonicalMSK {
+			continue
+		}
+
+		var distance int64
+		var result *ConsequenceResult
+		if end < t.Start || start > t.End {
+			consequence := flankConsequence(end < t.Start, t)
+			result = &ConsequenceResult{Consequence: consequence, Impact: GetImpact(consequence)}
+			distance = FlankDistance(start, end, t)
+		} else {
+			result = PredictSVConsequence(class, start, end, t)
+		}
+		if distance == 0 && hasFusionPartner(mateGenes, t.GeneName) {
+			result.Consequence = ConsequenceGeneFusion + "," + result.Consequence
+			result.Impact = GetImpact(result.Consequence)
+		}
+
+		annotations = append(annotations, &Annotation{
+			VariantID:          variantID,
+			TranscriptID:       t.ID,
+			GeneName:           t.GeneName,
+			GeneID:             t.GeneID,
+			ProteinID:          t.ProteinID,
+			HGNCId:             t.HGNCId,
+			EntrezGeneID:       t.EntrezGeneID,
+			Consequence:        result.Consequence,
+			Impact:             result.Impact,
+			IsCanonicalMSK:     t.IsCanonicalMSK,
+			IsCanonicalEnsembl: t.IsCanonicalEnsembl,
+			IsMANESelect:       t.IsMANESelect,
+			TranscriptSets:     t.TranscriptSets,
+			Allele:             v.Alt,
+			Biotype:            t.Biotype,
+			ExonNumber:         result.ExonNumber,
+			Distance:           distance,
+			TranscriptLength:   t.Length(),
+		})
+	}
+
+	if len(annotations) == 0 {
+		return []*Annotation{a.intergenic(v, chrom, start, end)}
+	}
+	return annotations
+}
+
+// hasFusionPartner reports whether the breakend partner hits a gene other than gene.
+func hasFusionPartner(mateGenes map[string]bool, gene string) bool {
+	if gene == "" {
+		return false
+	}
+	for g := range mateGenes {
+		if g != gene {
+			return true
+		}
+	}
+	return false
+}
+
+// exonsWithin counts exons entirely contained in [start, end].
+func exonsWithin(t *cache.Transcript, start, end int64) int {
+	n := 0
+	for i := range t.Exons {
+		if t.Exons[i].Start >= start && t.Exons[i].End <= end {
+			n++
+		}
+	}
+	return n
+}
+
+// svRegionTerm returns the transcript region term for the part of the
+// transcript overlapped by [start, end]: coding_sequence_variant if the CDS
+// is hit, a UTR or non-coding exon term if only non-coding exonic sequence is
+// hit, or intron_variant if no exon is touched.
+func svRegionTerm(t *cache.Transcript, start, end int64) string {
+	if start > t.End || end < t.Start {
+		return ""
+	}
+	hitExon := false
+	for i := range t.Exons {
+		if t.Exons[i].Start <= end && t.Exons[i].End >= start {
+			hitExon = true
+			break
+		}
+	}
+	if !hitExon {
+		return ConsequenceIntronVariant
+	}
+	if !t.IsProteinCoding() {
+		if t.Biotype == "miRNA" {
+			return ConsequenceMatureMiRNA
+		}
+		return ConsequenceNonCodingExon
+	}
+	if start <= t.CDSEnd && end >= t.CDSStart {
+		return ConsequenceCodingSequenceVariant
+	}
+	// Exonic but outside the CDS: 5' or 3' UTR depending on strand.
+	if (end < t.CDSStart) == t.IsForwardStrand() {
+		return Consequence5PrimeUTR
+	}
+	return Consequence3PrimeUTR
+}
+
+// svExonRange formats the exons overlapped by [start, end] in VEP style,
+// e.g. "3/10" for a single exon or "2-4/10" for a range. Returns "" if no
+// exon is overlapped.
+func svExonRange(t *cache.Transcript, start, end int64) string {
+	lo, hi := 0, 0
+	for i := range t.Exons {
+		e := &t.Exons[i]
+		if e.Start > end || e.End < start {
+			continue
+		}
+		if lo == 0 || e.Number < lo {
+			lo = e.Number
+		}
+		if e.Number > hi {
+			hi = e.Number
+		}
+	}
+	if lo == 0 {
+		return ""
+	}
+	if lo == hi {
+		return formatExonNumber(lo, len(t.Exons))
+	}
+	return formatInt64(int64(lo)) + "-" + formatExonNumber(hi, len(t.Exons))
+}
@@ -0,0 +1,163 @@
+package annotate
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/inodb/vibe-vep/internal/cache"
+	"github.com/inodb/vibe-vep/internal/vcf"
+)
+
+// newSVTestCache builds an indexed cache with KRAS (chr12, reverse strand)
+// and a small forward-strand gene on chr17 for breakend partners.
+func newSVTestCache() *cache.Cache {
+	c := cache.New()
+	c.AddTranscript(createKRASTranscript())
+	c.AddTranscript(&cache.Transcript{
+		ID:       "ENST_PARTNER",
+		GeneName: "PARTNER",
+		Chrom:    "17",
+		Start:    1000,
+		End:      5000,
+		Strand:   1,
+		Biotype:  "lncRNA",
+		Exons: []cache.Exon{
+			{Number: 1, Start: 1000, End: 1200, Frame: -1},
+			{Number: 2, Start: 4800, End: 5000, Frame: -1},
+		},
+	})
+	c.BuildIndex()
+	return c
+}
+
+func TestPredictSVConsequence(t *testing.T) {
+	kras := createKRASTranscript()
+
+	tests := []struct {
+		name       string
+		svType     string
+		start, end int64
+		wantConseq string
+		wantImpact string
+		wantExon   string
+	}{
+		{"deletion covering transcript", vcf.SVTypeDEL, 25200000, 25260000,
+			ConsequenceTranscriptAblation, ImpactHigh, "1-5/5"},
+		{"deletion removing exons 3-4", vcf.SVTypeDEL, 25225000, 25228000,
+			"exon_loss_variant,coding_sequence_variant,feature_truncation", ImpactHigh, "3-4/5"},
+		{"intronic deletion", vcf.SVTypeDEL, 25230000, 25231000,
+			"intron_variant,feature_truncation", ImpactModifier, ""},
+		{"duplication covering transcript", vcf.SVTypeDUP, 25200000, 25260000,
+			ConsequenceTranscriptAmplification, ImpactHigh, "1-5/5"},
+		{"partial duplication into 5' UTR exon", vcf.SVTypeDUP, 25250800, 25260000,
+			"5_prime_UTR_variant,feature_elongation", ImpactModifier, "1/5"},
+		{"inversion enclosing transcript", vcf.SVTypeINV, 25200000, 25260000,
+			ConsequenceTranscriptVariant, ImpactModifier, "1-5/5"},
+		{"breakend in intron", vcf.SVTypeBND, 25230000, 25230000,
+			"intron_variant,feature_truncation", ImpactModifier, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := PredictSVConsequence(tt.svType, tt.start, tt.end, kras)
+			assert.Equal(t, tt.wantConseq, result.Consequence)
+			assert.Equal(t, tt.wantImpact, result.Impact)
+			assert.Equal(t, tt.wantExon, result.ExonNumber)
+		})
+	}
+}
+
+func TestAnnotate_SymbolicDeletion(t *testing.T) {
+	ann := NewAnnotator(newSVTestCache())
+
+	// Deletion starting upstream of KRAS and ending inside it: the
+	// single-position lookup at POS would miss KRAS entirely.
+	v := &vcf.Variant{
+		Chrom:   "12",
+		Pos:     25190000,
+		Ref:     "N",
+		Alt:     "<DEL>",
+		RawInfo: "SVTYPE=DEL;END=25226000;SVLEN=-36000",
+	}
+	anns, err := ann.Annotate(v)
+	require.NoError(t, err)
+	require.Len(t, anns, 1)
+	assert.Equal(t, "KRAS", anns[0].GeneName)
+	assert.Equal(t, "exon_loss_variant,coding_sequence_variant,feature_truncation", anns[0].Consequence)
+	assert.Equal(t, "<DEL>", anns[0].Allele)
+	assert.Empty(t, anns[0].HGVSc)
+}
+
+func TestAnnotate_SymbolicIntergenic(t *testing.T) {
+	ann := NewAnnotator(newSVTestCache())
+
+	v := &vcf.Variant{Chrom: "12", Pos: 100, Ref: "N", Alt: "<DUP>", RawInfo: "END=500"}
+	anns, err := ann.Annotate(v)
+	require.NoError(t, err)
+	require.Len(t, anns, 1)
+	assert.Equal(t, ConsequenceIntergenicVariant, anns[0].Consequence)
+}
+
+func TestAnnotate_BreakendGeneFusion(t *testing.T) {
+	ann := NewAnnotator(newSVTestCache())
+
+	v := &vcf.Variant{Chrom: "chr12", Pos: 25230000, Ref: "G", Alt: "G]chr17:2000]", RawInfo: "SVTYPE=BND"}
+	anns, err := ann.Annotate(v)
+	require.NoError(t, err)
+	require.Len(t, anns, 1)
+	assert.Equal(t, "gene_fusion,intron_variant,feature_truncation", anns[0].Consequence)
+	assert.Equal(t, ImpactHigh, anns[0].Impact)
+
+	// Partner in an intergenic region: no fusion.
+	v = &vcf.Variant{Chrom: "12", Pos: 25230000, Ref: "G", Alt: "G]17:900000]"}
+	anns, err = ann.Annotate(v)
+	require.NoError(t, err)
+	require.Len(t, anns, 1)
+	assert.Equal(t, "intron_variant,feature_truncation", anns[0].Consequence)
+}
+
+func TestAnnotate_DeletionSpanningIntoTranscript(t *testing.T) {
+	ann := NewAnnotator(newSVTestCache())
+
+	// 2 kb deletion starting upstream of PARTNER (chr17:1000-5000) and
+	// removing its first exon. The anchor base lies outside the transcript.
+	ref := strings.Repeat("A", 2001)
+	v := &vcf.Variant{Chrom: "17", Pos: 500, Ref: ref, Alt: "A"}
+	anns, err := ann.Annotate(v)
+	require.NoError(t, err)
+	require.Len(t, anns, 1)
+	assert.Equal(t, "PARTNER", anns[0].GeneName)
+	assert.Equal(t, "exon_loss_variant,non_coding_transcript_exon_variant,feature_truncation", anns[0].Consequence)
+	assert.Equal(t, "1/2", anns[0].ExonNumber)
+
+	// Same deletion ending before the transcript: upstream within the flank.
+	v = &vcf.Variant{Chrom: "17", Pos: 500, Ref: strings.Repeat("A", 400), Alt: "A"}
+	anns, err = ann.Annotate(v)
+	require.NoError(t, err)
+	require.Len(t, anns, 1)
+	assert.Equal(t, ConsequenceUpstreamGene, anns[0].Consequence)
+	assert.Equal(t, int64(101), anns[0].Distance)
+
+	// Without a flank it is intergenic.
+	ann.SetUpDownDistance(0)
+	anns, err = ann.Annotate(v)
+	require.NoError(t, err)
+	require.Len(t, anns, 1)
+	assert.Equal(t, ConsequenceIntergenicVariant, anns[0].Consequence)
+}
+
+func TestAnnotate_SymbolicDeletionFlank(t *testing.T) {
+	ann := NewAnnotator(newSVTestCache())
+
+	// <DEL> ending 300 bp after PARTNER: downstream on the forward strand.
+	v := &vcf.Variant{Chrom: "17", Pos: 5299, Ref: "N", Alt: "<DEL>", RawInfo: "SVTYPE=DEL;END=6000"}
+	anns, err := ann.Annotate(v)
+	require.NoError(t, err)
+	require.Len(t, anns, 1)
+	assert.Equal(t, "PARTNER", anns[0].GeneName)
+	assert.Equal(t, ConsequenceDownstreamGene, anns[0].Consequence)
+	assert.Equal(t, int64(300), anns[0].Distance)
+}
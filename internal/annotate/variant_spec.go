@@ -15,6 +15,19 @@ const (
 	SpecProtein
 	SpecHGVSc
 	SpecHGVSg
+	SpecRSID
+)
+
+// ProteinChangeType identifies the kind of change in a SpecProtein.
+type ProteinChangeType int
+
+const (
+	ProteinSubstitution ProteinChangeType = iota // G12C, R213*
+	ProteinDeletion                              // E746_A750del
+	ProteinDuplication                           // G12_G13dup
+	ProteinInsertion                             // A775_G776insYVMA
+	ProteinDelIns                                // L747_P753delinsS
+	ProteinFrameshift                            // R175fs, P72Rfs*14
 )
 
 // VariantSpec holds a parsed variant specification.
@@ -29,12 +42,20 @@ type VariantSpec struct {
 	GeneName string
 	RefAA    byte  // single-letter
 	Position int64 // protein position
-	AltAA    byte  // single-letter
+	AltAA    byte  // single-letter; first new residue of a frameshift, 0 if not given
+	// Protein indels and frameshifts
+	ProteinType    ProteinChangeType
+	EndAA          byte   // last residue of a range (A750 in E746_A750del), 0 if none
+	EndPosition    int64  // protein position of EndAA
+	InsertedAAs    string // single-letter residues of ins/delins
+	FrameshiftStop int64  // N of fs*N (new stop is residue Position+N-1), 0 if not given
 	// HGVSc fields
 	TranscriptID string // transcript ID or gene name
 	CDSChange    string // e.g. "35G>T"
 	// HGVSg fields (Chrom reused from genomic)
 	GenomicChange string // e.g. "1293968del" or "1293968C>T"
+	// dbSNP fields
+	RSID string // e.g. "rs121913529"
 }
 
 // AminoAcidThreeToSingle maps three-letter amino acid codes to single-letter.
@@ -51,6 +72,8 @@ func init() {
 var (
 	// Genomic: chr12:25245350:C:A  or  12-25245350-C-A  or  chr12:25245350:C>A
 	reGenomic = regexp.MustCompile(`^(chr)?(\w+)[:\-](\d+)[:\-]([ACGTNacgtn]+)[>:\-/]([ACGTNacgtn]+)$`)
+	// dbSNP: rs121913529
+	reRSID = regexp.MustCompile(`^[Rr][Ss](\d+)$`)
 	// HGVSg: 5:g.1293968del  or  chr5:g.1293968C>T
 	reHGVSg = regexp.MustCompile(`^(?:chr)?(\w+):g\.(.+)$`)
 	// HGVSc: KRAS c.35G>T  or  ENST00000311936:c.35G>T
@@ -59,18 +82,33 @@ var (
 	reHGVScColon = regexp.MustCompile(`^(\S+):c\.(.+)$`)
 	// Protein with three-letter codes: KRAS p.Gly12Cys
 	reProteinThree = regexp.MustCompile(`^(\w+)\s+p?\.?([A-Z][a-z]{2}|\*)(\d+)([A-Z][a-z]{2}|\*)$`)
-	// Protein with single-letter codes: KRAS G12C  or  KRAS p.G12C
-	reProteinSingle = regexp.MustCompile(`^(\w+)\s+p?\.?([ACDEFGHIKLMNPQRSTVWY*])(\d+)([ACDEFGHIKLMNPQRSTVWY*])$`)
+	// Protein with single-letter codes: KRAS G12C  or  KRAS p.G12C  or  TP53 R213X
+	reProteinSingle = regexp.MustCompile(`^(\w+)\s+p?\.?([ACDEFGHIKLMNPQRSTVWY*])(\d+)([ACDEFGHIKLMNPQRSTVWY*X])$`)
+	// Protein indels and frameshifts with single- or three-letter codes:
+	// EGFR E746_A750del, KRAS G12_G13dup, ERBB2 A775_G776insYVMA,
+	// EGFR p.Leu747_Pro753delinsSer, TP53 R175fs, TP53 p.P72Rfs*14
+	reProteinIndel = regexp.MustCompile(`^(\w+)\s+p?\.?\(?` +
+		`(` + aaPattern + `)(\d+)(?:_(` + aaPattern + `)(\d+))?` +
+		`(delins(` + aaSeqPattern + `)|del|dup|ins(` + aaSeqPattern + `)|(` + aaPattern + `)?fs(?:\*|Ter|X)?(\d+|\?)?)\)?$`)
+)
+
+const (
+	aaPattern    = `[A-Z][a-z]{2}|[ACDEFGHIKLMNPQRSTVWY]`
+	aaSeqPattern = `(?:[A-Z][a-z]{2}|[ACDEFGHIKLMNPQRSTVWY*])+`
 )
 
 // ParseVariantSpec parses a variant specification string into a VariantSpec.
-// It tries genomic, then HGVSc, then protein format.
+// It tries rsID, HGVSg, genomic, then HGVSc, then protein format.
 func ParseVariantSpec(input string) (*VariantSpec, error) {
 	input = strings.TrimSpace(input)
 	if input == "" {
 		return nil, fmt.Errorf("empty variant specification")
 	}
 
+	if m := reRSID.FindStringSubmatch(input); m != nil {
+		return &VariantSpec{Type: SpecRSID, RSID: "rs" + m[1]}, nil
+	}
+
 	// Try HGVSg (must be before genomic, since both start with chr/number)
 	if spec, ok := parseHGVSg(input); ok {
 		return spec, nil
@@ -98,8 +136,11 @@ func ParseVariantSpec(input string) (*VariantSpec, error) {
 	if spec, ok := parseProteinSingle(input); ok {
 		return spec, nil
 	}
+	if spec, ok := parseProteinIndel(input); ok {
+		return spec, nil
+	}
 
-	return nil, fmt.Errorf("cannot parse variant specification %q (expected genomic, protein, or HGVSc format)", input)
+	return nil, fmt.Errorf("cannot parse variant specification %q (expected genomic, protein, HGVSc, HGVSg or rsID format)", input)
 }
 
 func parseHGVSg(input string) (*VariantSpec, bool) {
@@ -198,15 +239,128 @@ func parseProteinSingle(input string) (*VariantSpec, bool) {
 	if err != nil {
 		return nil, false
 	}
+	altAA := m[4][0]
+	if altAA == 'X' {
+		altAA = '*' // OncoKB-style nonsense, e.g. R213X
+	}
 	return &VariantSpec{
 		Type:     SpecProtein,
 		GeneName: m[1],
 		RefAA:    m[2][0],
 		Position: pos,
-		AltAA:    m[4][0],
+		AltAA:    altAA,
 	}, true
 }
 
+func parseProteinIndel(input string) (*VariantSpec, bool) {
+	m := reProteinIndel.FindStringSubmatch(input)
+	if m == nil {
+		return nil, false
+	}
+	pos, err := strconv.ParseInt(m[3], 10, 64)
+	if err != nil {
+		return nil, false
+	}
+	spec := &VariantSpec{
+		Type:     SpecProtein,
+		GeneName: m[1],
+		RefAA:    aaCode(m[2]),
+		Position: pos,
+	}
+	if m[4] != "" {
+		spec.EndAA = aaCode(m[4])
+		if spec.EndPosition, err = strconv.ParseInt(m[5], 10, 64); err != nil || spec.EndPosition <= pos {
+			return nil, false
+		}
+	}
+	switch change := m[6]; {
+	case strings.HasPrefix(change, "delins"):
+		spec.ProteinType = ProteinDelIns
+		spec.InsertedAAs = aaSequence(m[7])
+	case change == "del":
+		spec.ProteinType = ProteinDeletion
+	case change == "dup":
+		spec.ProteinType = ProteinDuplication
+	case strings.HasPrefix(change, "ins"):
+		// Insertions are between two adjacent residues.
+		if spec.EndPosition != pos+1 {
+			return nil, false
+		}
+		spec.ProteinType = ProteinInsertion
+		spec.InsertedAAs = aaSequence(m[8])
+	default:
+		if spec.EndAA != 0 {
+			return nil, false
+		}
+		spec.ProteinType = ProteinFrameshift
+		if m[9] != "" {
+			spec.AltAA = aaCode(m[9])
+		}
+		if m[10] != "" && m[10] != "?" {
+			spec.FrameshiftStop, _ = strconv.ParseInt(m[10], 10, 64)
+		}
+	}
+	if spec.RefAA == 0 || (spec.EndPosition > 0 && spec.EndAA == 0) {
+		return nil, false
+	}
+	return spec, true
+}
+
+// aaCode converts a single- or three-letter amino acid code to single-letter.
+func aaCode(code string) byte {
+	if len(code) == 1 {
+		return code[0]
+	}
+	return threeLetterToSingle(code)
+}
+
+// aaSequence converts a run of single- or three-letter amino acid codes
+// (YVMA, TyrValMetAla) to single-letter codes.
+func aaSequence(seq string) string {
+	var b strings.Builder
+	for i := 0; i < len(seq); {
+		if i+3 <= len(seq) && seq[i+1] >= 'a' && seq[i+1] <= 'z' {
+			b.WriteByte(aaCode(seq[i : i+3]))
+			i += 3
+			continue
+		}
+		b.WriteByte(seq[i])
+		i++
+	}
+	return b.String()
+}
+
+// ProteinChange returns the protein change of a SpecProtein in single-letter
+// HGVS form, e.g. "G12C", "E746_A750del" or "P72Rfs*14".
+func (s *VariantSpec) ProteinChange() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%c%d", s.RefAA, s.Position)
+	if s.EndPosition > 0 {
+		fmt.Fprintf(&b, "_%c%d", s.EndAA, s.EndPosition)
+	}
+	switch s.ProteinType {
+	case ProteinSubstitution:
+		b.WriteByte(s.AltAA)
+	case ProteinDeletion:
+		b.WriteString("del")
+	case ProteinDuplication:
+		b.WriteString("dup")
+	case ProteinInsertion:
+		b.WriteString("ins" + s.InsertedAAs)
+	case ProteinDelIns:
+		b.WriteString("delins" + s.InsertedAAs)
+	case ProteinFrameshift:
+		if s.AltAA != 0 {
+			b.WriteByte(s.AltAA)
+		}
+		b.WriteString("fs")
+		if s.FrameshiftStop > 0 {
+			fmt.Fprintf(&b, "*%d", s.FrameshiftStop)
+		}
+	}
+	return b.String()
+}
+
 func threeLetterToSingle(code string) byte {
 	if code == "*" {
 		return '*'
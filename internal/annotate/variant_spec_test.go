@@ -93,6 +93,16 @@ func TestParseVariantSpec(t *testing.T) {
 				}
 			},
 		},
+		{
+			input:    "NM_004985.5:c.35G>T",
+			wantType: SpecHGVSc,
+			check: func(t *testing.T, s *VariantSpec) {
+				t.Helper()
+				if s.TranscriptID != "NM_004985.5" || s.CDSChange != "35G>T" {
+					t.Errorf("got %+v", s)
+				}
+			},
+		},
 		// Stop codon in protein
 		{
 			input:    "TP53 R196*",
@@ -135,7 +145,100 @@ func TestParseVariantSpec(t *testing.T) {
 				}
 			},
 		},
+		// dbSNP rsIDs
+		{
+			input:    "rs121913529",
+			wantType: SpecRSID,
+			check: func(t *testing.T, s *VariantSpec) {
+				t.Helper()
+				if s.RSID != "rs121913529" {
+					t.Errorf("got %+v", s)
+				}
+			},
+		},
+		{
+			input:    "RS121913529",
+			wantType: SpecRSID,
+			check: func(t *testing.T, s *VariantSpec) {
+				t.Helper()
+				if s.RSID != "rs121913529" {
+					t.Errorf("got %+v", s)
+				}
+			},
+		},
 		// Errors
+		// Protein indels, frameshifts and nonsense
+		{
+			input:    "EGFR E746_A750del",
+			wantType: SpecProtein,
+			check: func(t *testing.T, s *VariantSpec) {
+				t.Helper()
+				if s.ProteinType != ProteinDeletion || s.RefAA != 'E' || s.Position != 746 || s.EndAA != 'A' || s.EndPosition != 750 {
+					t.Errorf("got %+v", s)
+				}
+			},
+		},
+		{
+			input:    "ERBB2 p.A775_G776insYVMA",
+			wantType: SpecProtein,
+			check: func(t *testing.T, s *VariantSpec) {
+				t.Helper()
+				if s.ProteinType != ProteinInsertion || s.InsertedAAs != "YVMA" || s.ProteinChange() != "A775_G776insYVMA" {
+					t.Errorf("got %+v", s)
+				}
+			},
+		},
+		{
+			input:    "EGFR p.Leu747_Pro753delinsSer",
+			wantType: SpecProtein,
+			check: func(t *testing.T, s *VariantSpec) {
+				t.Helper()
+				if s.ProteinType != ProteinDelIns || s.ProteinChange() != "L747_P753delinsS" {
+					t.Errorf("got %+v", s)
+				}
+			},
+		},
+		{
+			input:    "KRAS G12_G13dup",
+			wantType: SpecProtein,
+			check: func(t *testing.T, s *VariantSpec) {
+				t.Helper()
+				if s.ProteinType != ProteinDuplication || s.ProteinChange() != "G12_G13dup" {
+					t.Errorf("got %+v", s)
+				}
+			},
+		},
+		{
+			input:    "TP53 R175fs",
+			wantType: SpecProtein,
+			check: func(t *testing.T, s *VariantSpec) {
+				t.Helper()
+				if s.ProteinType != ProteinFrameshift || s.AltAA != 0 || s.FrameshiftStop != 0 || s.ProteinChange() != "R175fs" {
+					t.Errorf("got %+v", s)
+				}
+			},
+		},
+		{
+			input:    "TP53 p.Pro72ArgfsTer14",
+			wantType: SpecProtein,
+			check: func(t *testing.T, s *VariantSpec) {
+				t.Helper()
+				if s.ProteinType != ProteinFrameshift || s.ProteinChange() != "P72Rfs*14" {
+					t.Errorf("got %+v", s)
+				}
+			},
+		},
+		{
+			input:    "TP53 R213X",
+			wantType: SpecProtein,
+			check: func(t *testing.T, s *VariantSpec) {
+				t.Helper()
+				if s.ProteinType != ProteinSubstitution || s.AltAA != '*' {
+					t.Errorf("got %+v", s)
+				}
+			},
+		},
+		{input: "ERBB2 A775_G777insYVMA", wantErr: true},
 		{input: "", wantErr: true},
 		{input: "not a variant", wantErr: true},
 	}
@@ -2,6 +2,9 @@
 package cache
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
 	"sort"
 )
 
@@ -68,6 +71,54 @@ func (c *Cache) FindTranscripts(chrom string, pos int64) []*Transcript {
 	return result
 }
 
+// FindTranscriptsInRange returns all transcripts that overlap the closed
+// genomic interval [start, end].
+func (c *Cache) FindTranscriptsInRange(chrom string, start, end int64) []*Transcript {
+	if c.trees != nil {
+		if tree, ok := c.trees[chrom]; ok {
+			return tree.FindOverlapsRange(start, end)
+		}
+		return nil
+	}
+
+	var result []*Transcript
+	for _, t := range c.transcripts[chrom] {
+		if t.Start <= end && t.End >= start {
+			result = append(result, t)
+		}
+	}
+	return result
+}
+
+// FindNearestTranscript returns the transcript closest to the closed genomic
+// interval [start, end] and its distance in bases (0 if it overlaps), or nil
+// if the chromosome has no transcripts.
+func (c *Cache) FindNearestTranscript(chrom string, start, end int64) (*Transcript, int64) {
+	if c.trees != nil {
+		if tree, ok := c.trees[chrom]; ok {
+			return tree.FindNearest(start, end)
+		}
+		return nil, 0
+	}
+
+	var best *Transcript
+	var bestDist int64
+	for _, t := range c.transcripts[chrom] {
+		var dist int64
+		switch {
+		case t.End < start:
+			dist = start - t.End
+		case t.Start > end:
+			dist = t.Start - end
+		}
+		if best == nil || dist < bestDist ||
+			(dist == bestDist && t.IsCanonicalMSK && !best.IsCanonicalMSK) {
+			best, bestDist = t, dist
+		}
+	}
+	return best, bestDist
+}
+
 // GetTranscript returns a specific transcript by ID, or nil if not found.
 func (c *Cache) GetTranscript(id string) *Transcript {
 	for _, transcripts := range c.transcripts {
@@ -103,6 +154,23 @@ func (c *Cache) TranscriptCount() int {
 	return count
 }
 
+// Fingerprint returns a digest of the transcript models in the cache: IDs,
+// genes, coordinates, CDS bounds, biotypes and canonical flags. It changes
+// when the transcript data changes, so results computed from the cache can
+// be keyed by it.
+func (c *Cache) Fingerprint() string {
+	h := sha256.New()
+	for _, chrom := range c.Chromosomes() {
+		for _, t := range c.transcripts[chrom] {
+			fmt.Fprintf(h, "%s|%s|%s|%s:%d-%d:%d|%d-%d|%d|%s|%t%t%t|%v\n",
+				t.ID, t.GeneName, t.ProteinID, t.Chrom, t.Start, t.End, t.Strand,
+				t.CDSStart, t.CDSEnd, len(t.Exons), t.Biotype,
+				t.IsCanonicalMSK, t.IsCanonicalEnsembl, t.IsMANESelect, t.TranscriptSets)
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
 // Chromosomes returns a sorted list of chromosomes in the cache.
 func (c *Cache) Chromosomes() []string {
 	chroms := make([]string, 0, len(c.transcripts))
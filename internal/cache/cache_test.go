@@ -0,0 +1,103 @@
+package cache
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCache_FindTranscriptsInRange(t *testing.T) {
+	c := New()
+	c.AddTranscript(&Transcript{ID: "A", Chrom: "1", Start: 1000, End: 2000})
+	c.AddTranscript(&Transcript{ID: "B", Chrom: "1", Start: 5000, End: 9000})
+	c.AddTranscript(&Transcript{ID: "C", Chrom: "2", Start: 1000, End: 2000})
+
+	ids := func(txs []*Transcript) map[string]bool {
+		m := map[string]bool{}
+		for _, tx := range txs {
+			m[tx.ID] = true
+		}
+		return m
+	}
+
+	check := func(label string) {
+		assert.Equal(t, map[string]bool{"A": true, "B": true}, ids(c.FindTranscriptsInRange("1", 1500, 6000)), label)
+		assert.Equal(t, map[string]bool{"B": true}, ids(c.FindTranscriptsInRange("1", 2001, 5000)), label+": boundaries inclusive")
+		assert.Empty(t, c.FindTranscriptsInRange("1", 2001, 4999), label+": gap")
+		assert.Empty(t, c.FindTranscriptsInRange("3", 0, 10000), label+": unknown chromosome")
+		// A point range matches FindTranscripts.
+		assert.Equal(t, ids(c.FindTranscripts("1", 1500)), ids(c.FindTranscriptsInRange("1", 1500, 1500)), label)
+	}
+
+	check("linear scan")
+	c.BuildIndex()
+	check("interval tree")
+}
+
+func TestCache_FindNearestTranscript(t *testing.T) {
+	c := New()
+	c.AddTranscript(&Transcript{ID: "LONG", Chrom: "1", Start: 100, End: 4000})
+	c.AddTranscript(&Transcript{ID: "A", Chrom: "1", Start: 1000, End: 2000})
+	c.AddTranscript(&Transcript{ID: "B", Chrom: "1", Start: 10000, End: 12000})
+	c.AddTranscript(&Transcript{ID: "B_CANON", Chrom: "1", Start: 10000, End: 11000, IsCanonicalMSK: true})
+
+	check := func(label string) {
+		tr, dist := c.FindNearestTranscript("1", 6000, 6000)
+		if assert.NotNil(t, tr, label) {
+			// LONG ends 2000 bp before; B starts 4000 bp after.
+			assert.Equal(t, "LONG", tr.ID, label)
+			assert.Equal(t, int64(2000), dist, label)
+		}
+
+		tr, dist = c.FindNearestTranscript("1", 7500, 7600)
+		if assert.NotNil(t, tr, label) {
+			assert.Equal(t, "B_CANON", tr.ID, label+": tie prefers canonical")
+			assert.Equal(t, int64(2400), dist, label)
+		}
+
+		tr, dist = c.FindNearestTranscript("1", 13000, 13000)
+		if assert.NotNil(t, tr, label) {
+			assert.Equal(t, "B", tr.ID, label)
+			assert.Equal(t, int64(1000), dist, label)
+		}
+
+		tr, dist = c.FindNearestTranscript("1", 1500, 1500)
+		if assert.NotNil(t, tr, label) {
+			assert.Equal(t, int64(0), dist, label+": overlap")
+		}
+
+		tr, _ = c.FindNearestTranscript("2", 1, 1)
+		assert.Nil(t, tr, label+": unknown chromosome")
+	}
+
+	check("linear scan")
+	c.BuildIndex()
+	check("interval tree")
+}
+
+func TestCache_Fingerprint(t *testing.T) {
+	build := func(canonical bool) *Cache {
+		c := New()
+		c.AddTranscript(&Transcript{ID: "A", Chrom: "1", Start: 1000, End: 2000, IsCanonicalMSK: canonical})
+		c.AddTranscript(&Transcript{ID: "C", Chrom: "2", Start: 1000, End: 2000})
+		return c
+	}
+
+	assert.Equal(t, build(true).Fingerprint(), build(true).Fingerprint())
+	assert.NotEqual(t, build(true).Fingerprint(), build(false).Fingerprint(), "canonical flag changed")
+	assert.NotEqual(t, New().Fingerprint(), build(true).Fingerprint())
+}
+
+func TestTranscript_Length(t *testing.T) {
+	coding := &Transcript{
+		CDSStart: 150, CDSEnd: 320,
+		Exons: []Exon{
+			{Start: 100, End: 200, CDSStart: 150, CDSEnd: 200},
+			{Start: 300, End: 400, CDSStart: 300, CDSEnd: 320},
+		},
+	}
+	assert.Equal(t, int64(51+21), coding.Length())
+
+	nonCoding := &Transcript{Exons: coding.Exons}
+	assert.Equal(t, int64(101+101), nonCoding.Length())
+}
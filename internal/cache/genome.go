@@ -0,0 +1,240 @@
+package cache
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// faiEntry is one line of a samtools faidx index.
+type faiEntry struct {
+	length    int64 // sequence length in bases
+	offset    int64 // byte offset of the first base
+	lineBases int64 // bases per line
+	lineWidth int64 // bytes per line, including the newline
+}
+
+// gziEntry maps the start of a BGZF block to its uncompressed offset.
+type gziEntry struct {
+	compressed   int64
+	uncompressed int64
+}
+
+// GenomeFASTA provides random access to a reference genome FASTA indexed
+// with samtools faidx (a .fai file next to the FASTA, plus a .gzi file for
+// bgzip-compressed FASTA). Reads use ReadAt, so a GenomeFASTA is safe for
+// concurrent use.
+type GenomeFASTA struct {
+	path   string
+	f      *os.File
+	size   int64
+	index  map[string]faiEntry
+	names  []string   // sequence names in index order
+	blocks []gziEntry // BGZF block offsets; nil for uncompressed FASTA
+}
+
+// OpenGenomeFASTA opens a reference FASTA and its .fai index. A FASTA ending
+// in .gz must be bgzip-compressed with a .gzi index next to it.
+func OpenGenomeFASTA(path string) (*GenomeFASTA, error) {
+	index, names, err := readFAI(path + ".fai")
+	if err != nil {
+		return nil, err
+	}
+	var blocks []gziEntry
+	if strings.HasSuffix(path, ".gz") {
+		blocks, err = readGZI(path + ".gzi")
+		if err != nil {
+			return nil, err
+		}
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("open genome FASTA: %w", err)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("stat genome FASTA: %w", err)
+	}
+	return &GenomeFASTA{path: path, f: f, size: info.Size(), index: index, names: names, blocks: blocks}, nil
+}
+
+// readGZI parses a bgzip .gzi index: a little-endian uint64 entry count
+// followed by (compressed, uncompressed) uint64 offset pairs. The implicit
+// first block at offset 0 is added.
+func readGZI(path string) ([]gziEntry, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("open bgzip index (compress with bgzip and run samtools faidx): %w", err)
+	}
+	if len(data) < 8 {
+		return nil, fmt.Errorf("%s: truncated index", path)
+	}
+	n := binary.LittleEndian.Uint64(data)
+	if uint64(len(data)-8) != n*16 {
+		return nil, fmt.Errorf("%s: expected %d entries, file has %d bytes", path, n, len(data))
+	}
+	blocks := make([]gziEntry, 0, n+1)
+	blocks = append(blocks, gziEntry{})
+	for i := uint64(0); i < n; i++ {
+		off := 8 + i*16
+		blocks = append(blocks, gziEntry{
+			compressed:   int64(binary.LittleEndian.Uint64(data[off:])),
+			uncompressed: int64(binary.LittleEndian.Uint64(data[off+8:])),
+		})
+	}
+	return blocks, nil
+}
+
+// readFAI parses a samtools faidx index.
+func readFAI(path string) (map[string]faiEntry, []string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("open FASTA index (create with samtools faidx): %w", err)
+	}
+	defer f.Close()
+
+	index := make(map[string]faiEntry)
+	var names []string
+	scanner := bufio.NewScanner(f)
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		line := scanner.Text()
+		if line == "" {
+			continue
+		}
+		fields := strings.Split(line, "\t")
+		if len(fields) < 5 {
+			return nil, nil, fmt.Errorf("%s line %d: expected 5 columns, got %d", path, lineNum, len(fields))
+		}
+		var nums [4]int64
+		for i := range nums {
+			n, err := strconv.ParseInt(fields[i+1], 10, 64)
+			if err != nil {
+				return nil, nil, fmt.Errorf("%s line %d: %w", path, lineNum, err)
+			}
+			nums[i] = n
+		}
+		if nums[2] <= 0 || nums[3] < nums[2] {
+			return nil, nil, fmt.Errorf("%s line %d: invalid line length", path, lineNum)
+		}
+		index[fields[0]] = faiEntry{length: nums[0], offset: nums[1], lineBases: nums[2], lineWidth: nums[3]}
+		names = append(names, fields[0])
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, nil, fmt.Errorf("read FASTA index: %w", err)
+	}
+	return index, names, nil
+}
+
+// Path returns the FASTA file path.
+func (g *GenomeFASTA) Path() string {
+	return g.path
+}
+
+// Close closes the underlying file.
+func (g *GenomeFASTA) Close() error {
+	return g.f.Close()
+}
+
+// Chromosomes returns the sequence names in index order.
+func (g *GenomeFASTA) Chromosomes() []string {
+	return g.names
+}
+
+// SequenceLength returns the length of a chromosome, accepting names with or
+// without a "chr" prefix. Returns false if the chromosome is not in the index.
+func (g *GenomeFASTA) SequenceLength(chrom string) (int64, bool) {
+	e, ok := g.lookup(chrom)
+	return e.length, ok
+}
+
+// lookup resolves a chromosome name against the index, trying the name as
+// given and with the "chr" prefix added or removed (including MT ↔ chrM).
+func (g *GenomeFASTA) lookup(chrom string) (faiEntry, bool) {
+	if e, ok := g.index[chrom]; ok {
+		return e, true
+	}
+	var alt []string
+	if bare, ok := strings.CutPrefix(chrom, "chr"); ok {
+		alt = append(alt, bare)
+		if bare == "M" {
+			alt = append(alt, "MT")
+		}
+	} else {
+		alt = append(alt, "chr"+chrom)
+		if chrom == "MT" {
+			alt = append(alt, "chrM")
+		}
+	}
+	for _, name := range alt {
+		if e, ok := g.index[name]; ok {
+			return e, true
+		}
+	}
+	return faiEntry{}, false
+}
+
+// Fetch returns the upper-cased reference bases for the 1-based closed
+// interval [start, end] on chrom.
+func (g *GenomeFASTA) Fetch(chrom string, start, end int64) (string, error) {
+	e, ok := g.lookup(chrom)
+	if !ok {
+		return "", fmt.Errorf("chromosome %s not in reference %s", chrom, g.path)
+	}
+	if start < 1 || end < start || end > e.length {
+		return "", fmt.Errorf("region %s:%d-%d outside reference (length %d)", chrom, start, end, e.length)
+	}
+
+	// Byte range covering the requested bases, including embedded newlines.
+	from := e.offset + (start-1)/e.lineBases*e.lineWidth + (start-1)%e.lineBases
+	to := e.offset + (end-1)/e.lineBases*e.lineWidth + (end-1)%e.lineBases
+	buf := make([]byte, to-from+1)
+	if err := g.readAt(buf, from); err != nil {
+		return "", fmt.Errorf("read %s:%d-%d: %w", chrom, start, end, err)
+	}
+
+	seq := make([]byte, 0, end-start+1)
+	for _, b := range buf {
+		switch {
+		case b == '\n' || b == '\r':
+			continue
+		case b >= 'a' && b <= 'z':
+			b -= 'a' - 'A'
+		}
+		seq = append(seq, b)
+	}
+	return string(seq), nil
+}
+
+// readAt fills buf with the uncompressed FASTA bytes starting at offset.
+func (g *GenomeFASTA) readAt(buf []byte, offset int64) error {
+	if g.blocks == nil {
+		_, err := g.f.ReadAt(buf, offset)
+		return err
+	}
+
+	// Start decompressing at the last block that begins at or before offset;
+	// the gzip reader walks the following BGZF blocks as one multistream.
+	i := sort.Search(len(g.blocks), func(i int) bool {
+		return g.blocks[i].uncompressed > offset
+	}) - 1
+	b := g.blocks[i]
+	zr, err := gzip.NewReader(io.NewSectionReader(g.f, b.compressed, g.size-b.compressed))
+	if err != nil {
+		return err
+	}
+	defer zr.Close()
+	if _, err := io.CopyN(io.Discard, zr, offset-b.uncompressed); err != nil {
+		return err
+	}
+	_, err = io.ReadFull(zr, buf)
+	return err
+}
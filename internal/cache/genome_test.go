@@ -0,0 +1,137 @@
+package cache
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeTestGenome writes a FASTA with the given line width and a matching
+// .fai index, returning the FASTA path.
+func writeTestGenome(t *testing.T, names []string, seqs map[string]string, lineBases int) string {
+	t.Helper()
+	dir := t.TempDir()
+	path := filepath.Join(dir, "genome.fa")
+
+	var fa, fai strings.Builder
+	for _, name := range names {
+		seq := seqs[name]
+		fa.WriteString(">" + name + " description\n")
+		offset := fa.Len()
+		for i := 0; i < len(seq); i += lineBases {
+			end := min(i+lineBases, len(seq))
+			fa.WriteString(seq[i:end] + "\n")
+		}
+		fmt.Fprintf(&fai, "%s\t%d\t%d\t%d\t%d\n", name, len(seq), offset, lineBases, lineBases+1)
+	}
+	require.NoError(t, os.WriteFile(path, []byte(fa.String()), 0o644))
+	require.NoError(t, os.WriteFile(path+".fai", []byte(fai.String()), 0o644))
+	return path
+}
+
+func TestGenomeFASTA_Fetch(t *testing.T) {
+	seqs := map[string]string{
+		"chr1": "ACGTACGTAAccggttNNACGTACGTAC",
+		"chrM": "GATCACAGGT",
+	}
+	path := writeTestGenome(t, []string{"chr1", "chrM"}, seqs, 8)
+
+	g, err := OpenGenomeFASTA(path)
+	require.NoError(t, err)
+	defer g.Close()
+
+	assert.Equal(t, []string{"chr1", "chrM"}, g.Chromosomes())
+
+	tests := []struct {
+		chrom      string
+		start, end int64
+		want       string
+	}{
+		{"chr1", 1, 4, "ACGT"},
+		{"chr1", 7, 10, "GTAA"},                     // spans a line break
+		{"chr1", 9, 16, "AACCGGTT"},                 // lower case is upper-cased
+		{"1", 1, 28, strings.ToUpper(seqs["chr1"])}, // bare name resolves to chr1
+		{"MT", 1, 3, "GAT"},                         // MT resolves to chrM
+	}
+	for _, tt := range tests {
+		got, err := g.Fetch(tt.chrom, tt.start, tt.end)
+		require.NoError(t, err, "%s:%d-%d", tt.chrom, tt.start, tt.end)
+		assert.Equal(t, tt.want, got, "%s:%d-%d", tt.chrom, tt.start, tt.end)
+	}
+
+	n, ok := g.SequenceLength("1")
+	assert.True(t, ok)
+	assert.Equal(t, int64(28), n)
+
+	_, err = g.Fetch("chr1", 20, 29)
+	assert.Error(t, err, "past end of chromosome")
+	_, err = g.Fetch("chr2", 1, 1)
+	assert.Error(t, err, "unknown chromosome")
+}
+
+// bgzipTestGenome compresses a FASTA written by writeTestGenome into gzip
+// members of blockSize uncompressed bytes (like bgzip) and writes the .fai
+// and .gzi indexes, returning the .fa.gz path.
+func bgzipTestGenome(t *testing.T, path string, blockSize int) string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+
+	var gz bytes.Buffer
+	var gzi []uint64
+	for off := 0; off < len(data); off += blockSize {
+		if off > 0 {
+			gzi = append(gzi, uint64(gz.Len()), uint64(off))
+		}
+		zw := gzip.NewWriter(&gz)
+		_, err := zw.Write(data[off:min(off+blockSize, len(data))])
+		require.NoError(t, err)
+		require.NoError(t, zw.Close())
+	}
+	index := binary.LittleEndian.AppendUint64(nil, uint64(len(gzi)/2))
+	for _, v := range gzi {
+		index = binary.LittleEndian.AppendUint64(index, v)
+	}
+
+	gzPath := path + ".gz"
+	require.NoError(t, os.WriteFile(gzPath, gz.Bytes(), 0o644))
+	require.NoError(t, os.WriteFile(gzPath+".gzi", index, 0o644))
+	fai, err := os.ReadFile(path + ".fai")
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(gzPath+".fai", fai, 0o644))
+	return gzPath
+}
+
+func TestGenomeFASTA_FetchBgzip(t *testing.T) {
+	seq := strings.Repeat("ACGTTGCAAC", 20)
+	path := bgzipTestGenome(t, writeTestGenome(t, []string{"chr7"}, map[string]string{"chr7": seq}, 10), 16)
+
+	g, err := OpenGenomeFASTA(path)
+	require.NoError(t, err)
+	defer g.Close()
+
+	for _, r := range [][2]int64{{1, 1}, {1, 200}, {15, 15}, {14, 31}, {95, 140}, {200, 200}} {
+		got, err := g.Fetch("7", r[0], r[1])
+		require.NoError(t, err, "%v", r)
+		assert.Equal(t, seq[r[0]-1:r[1]], got, "%v", r)
+	}
+
+	require.NoError(t, os.Remove(path+".gzi"))
+	_, err = OpenGenomeFASTA(path)
+	assert.ErrorContains(t, err, "bgzip")
+}
+
+func TestOpenGenomeFASTA_MissingIndex(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "genome.fa")
+	require.NoError(t, os.WriteFile(path, []byte(">1\nACGT\n"), 0o644))
+	_, err := OpenGenomeFASTA(path)
+	assert.ErrorContains(t, err, "samtools faidx")
+}
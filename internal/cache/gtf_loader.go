@@ -223,41 +223,46 @@ func (l *GTFLoader) parseGTF(reader io.Reader, filterChrom string) (map[string]*
 			}
 		}
 
-		// Calculate CDS portions of exons and reading frames
-		if t.CDSStart > 0 && t.CDSEnd > 0 {
-			cdsPosition := int64(0)
-			for i := range exons {
-				e := &exons[i]
-				// Check if exon overlaps CDS
-				if e.End >= t.CDSStart && e.Start <= t.CDSEnd {
-					e.CDSStart = max(e.Start, t.CDSStart)
-					e.CDSEnd = min(e.End, t.CDSEnd)
-
-					// Calculate frame based on CDS position
-					if t.Strand == 1 {
-						e.Frame = int(cdsPosition % 3)
-						cdsPosition += e.CDSEnd - e.CDSStart + 1
-					}
+		assignExonCDS(t, exons)
+
+		t.Exons = exons
+	}
+
+	return transcripts, nil
+}
+
+// assignExonCDS sets the CDS portion and reading frame of each exon (sorted
+// by genomic position) from the transcript's CDS boundaries.
+func assignExonCDS(t *Transcript, exons []Exon) {
+	if t.CDSStart > 0 && t.CDSEnd > 0 {
+		cdsPosition := int64(0)
+		for i := range exons {
+			e := &exons[i]
+			// Check if exon overlaps CDS
+			if e.End >= t.CDSStart && e.Start <= t.CDSEnd {
+				e.CDSStart = max(e.Start, t.CDSStart)
+				e.CDSEnd = min(e.End, t.CDSEnd)
+
+				// Calculate frame based on CDS position
+				if t.Strand == 1 {
+					e.Frame = int(cdsPosition % 3)
+					cdsPosition += e.CDSEnd - e.CDSStart + 1
 				}
 			}
+		}
 
-			// For reverse strand, calculate frames in reverse order
-			if t.Strand == -1 {
-				cdsPosition = 0
-				for i := len(exons) - 1; i >= 0; i-- {
-					e := &exons[i]
-					if e.CDSStart > 0 && e.CDSEnd > 0 {
-						e.Frame = int(cdsPosition % 3)
-						cdsPosition += e.CDSEnd - e.CDSStart + 1
-					}
+		// For reverse strand, calculate frames in reverse order
+		if t.Strand == -1 {
+			cdsPosition = 0
+			for i := len(exons) - 1; i >= 0; i-- {
+				e := &exons[i]
+				if e.CDSStart > 0 && e.CDSEnd > 0 {
+					e.Frame = int(cdsPosition % 3)
+					cdsPosition += e.CDSEnd - e.CDSStart + 1
 				}
 			}
 		}
-
-		t.Exons = exons
 	}
-
-	return transcripts, nil
 }
 
 // parseLine parses a single GTF line.
@@ -364,6 +369,7 @@ type GENCODELoader struct {
 	mskCanonicalOverrides  CanonicalOverrides
 	ensCanonicalOverrides  CanonicalOverrides
 	entrezGeneIDs          GeneEntrezMap
+	transcriptSets         []*TranscriptSet
 }
 
 // NewGENCODELoader creates a loader for GENCODE GTF + FASTA files.
@@ -387,6 +393,12 @@ func (l *GENCODELoader) SetEntrezGeneIDs(entrez GeneEntrezMap) {
 	l.entrezGeneIDs = entrez
 }
 
+// SetTranscriptSets sets named transcript sets; each transcript records the
+// names of the sets listing it in Transcript.TranscriptSets.
+func (l *GENCODELoader) SetTranscriptSets(sets []*TranscriptSet) {
+	l.transcriptSets = sets
+}
+
 // Load loads all transcripts and sequences into the cache.
 func (l *GENCODELoader) Load(c *Cache) error {
 	// Load GTF annotations
@@ -410,6 +422,10 @@ func (l *GENCODELoader) Load(c *Cache) error {
 		}
 	}
 
+	if len(l.transcriptSets) > 0 {
+		applyTranscriptSets(c, l.transcriptSets)
+	}
+
 	// Load FASTA sequences if provided
 	if l.fastaPath != "" {
 		l.fasta = NewFASTALoader(l.fastaPath)
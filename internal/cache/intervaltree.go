@@ -6,7 +6,7 @@ import "sort"
 // Transcripts are loaded once and never modified after build.
 type IntervalTree struct {
 	intervals []interval
-	maxEnd    []int64 // maxEnd[i] = max(End) for intervals[i:]
+	maxEnd    []int64 // maxEnd[i] = max(End) for intervals[:i+1]
 }
 
 type interval struct {
@@ -30,13 +30,15 @@ func BuildIntervalTree(transcripts []*Transcript) *IntervalTree {
 		return intervals[i].start < intervals[j].start
 	})
 
-	// Build suffix-max array: maxEnd[i] = max(end) for intervals[i:]
+	// Build prefix-max array: maxEnd[i] = max(end) for intervals[:i+1].
+	// Scanning backwards from the query boundary, once maxEnd[i] falls
+	// below the query start no earlier interval can reach it.
 	maxEnd := make([]int64, len(intervals))
-	maxEnd[len(intervals)-1] = intervals[len(intervals)-1].end
-	for i := len(intervals) - 2; i >= 0; i-- {
+	maxEnd[0] = intervals[0].end
+	for i := 1; i < len(intervals); i++ {
 		maxEnd[i] = intervals[i].end
-		if maxEnd[i+1] > maxEnd[i] {
-			maxEnd[i] = maxEnd[i+1]
+		if maxEnd[i-1] > maxEnd[i] {
+			maxEnd[i] = maxEnd[i-1]
 		}
 	}
 
@@ -45,30 +47,89 @@ func BuildIntervalTree(transcripts []*Transcript) *IntervalTree {
 
 // FindOverlaps returns all transcripts whose [Start, End] range contains pos.
 func (t *IntervalTree) FindOverlaps(pos int64) []*Transcript {
+	return t.FindOverlapsRange(pos, pos)
+}
+
+// FindOverlapsRange returns all transcripts whose [Start, End] range overlaps
+// the closed interval [start, end].
+func (t *IntervalTree) FindOverlapsRange(start, end int64) []*Transcript {
 	if len(t.intervals) == 0 {
 		return nil
 	}
+	if end < start {
+		start, end = end, start
+	}
 
 	var result []*Transcript
 
-	// Binary search: find rightmost interval with start <= pos.
-	// All candidates must have start <= pos, so we only need to scan
-	// from index 0 to that boundary.
+	// Binary search: candidates must have interval.start <= end.
 	hi := sort.Search(len(t.intervals), func(i int) bool {
-		return t.intervals[i].start > pos
+		return t.intervals[i].start > end
 	})
-	// hi is the first index with start > pos; candidates are [0, hi).
+	// hi is the first index with start > end; candidates are [0, hi).
 
 	for i := hi - 1; i >= 0; i-- {
-		// Prune: maxEnd[i] is the max end for intervals[i:].
-		// If maxEnd[i] < pos, no interval from 0..i can contain pos.
-		if t.maxEnd[i] < pos {
+		// Prune: maxEnd[i] is the max end for intervals[:i+1].
+		// If maxEnd[i] < start, no interval from 0..i can reach start.
+		if t.maxEnd[i] < start {
 			break
 		}
-		if t.intervals[i].end >= pos {
+		if t.intervals[i].end >= start {
 			result = append(result, t.intervals[i].transcript)
 		}
 	}
 
 	return result
 }
+
+// FindNearest returns the transcript closest to the closed interval
+// [start, end] together with its distance in bases (0 if it overlaps).
+// Ties are broken in favour of MSK canonical transcripts. Returns nil if the
+// tree is empty.
+func (t *IntervalTree) FindNearest(start, end int64) (*Transcript, int64) {
+	if len(t.intervals) == 0 {
+		return nil, 0
+	}
+	if end < start {
+		start, end = end, start
+	}
+
+	var best *Transcript
+	var bestDist int64
+	consider := func(tr *Transcript, dist int64) {
+		if best == nil || dist < bestDist ||
+			(dist == bestDist && tr.IsCanonicalMSK && !best.IsCanonicalMSK) {
+			best, bestDist = tr, dist
+		}
+	}
+
+	for _, tr := range t.FindOverlapsRange(start, end) {
+		consider(tr, 0)
+	}
+	if best != nil {
+		return best, 0
+	}
+
+	// Right side: intervals sorted by start, so the first ones past end are closest.
+	hi := sort.Search(len(t.intervals), func(i int) bool {
+		return t.intervals[i].start > end
+	})
+	for i := hi; i < len(t.intervals); i++ {
+		dist := t.intervals[i].start - end
+		if best != nil && dist > bestDist {
+			break
+		}
+		consider(t.intervals[i].transcript, dist)
+	}
+
+	// Left side: nothing in [0, hi) overlaps, so every end is before start.
+	// Stop once no earlier interval can end closer than the current best.
+	for i := hi - 1; i >= 0; i-- {
+		if best != nil && start-t.maxEnd[i] > bestDist {
+			break
+		}
+		consider(t.intervals[i].transcript, start-t.intervals[i].end)
+	}
+
+	return best, bestDist
+}
@@ -113,3 +113,45 @@ func TestIntervalTree_MatchesLinearScan(t *testing.T) {
 		assert.Equal(t, linearIDs, treeIDs, "pos=%d", pos)
 	}
 }
+
+func TestIntervalTree_LongIntervalBeforeShort(t *testing.T) {
+	// A long interval starting first must still be found when a later,
+	// shorter interval ends before the query position.
+	transcripts := []*Transcript{
+		{ID: "long", Start: 100, End: 1000},
+		{ID: "short", Start: 110, End: 120},
+	}
+	tree := BuildIntervalTree(transcripts)
+
+	results := tree.FindOverlaps(500)
+	assert.Len(t, results, 1)
+	assert.Equal(t, "long", results[0].ID)
+}
+
+func TestIntervalTree_FindOverlapsRange(t *testing.T) {
+	transcripts := []*Transcript{
+		{ID: "A", Start: 1000, End: 5000},
+		{ID: "B", Start: 2000, End: 3000},
+		{ID: "C", Start: 4000, End: 8000},
+		{ID: "D", Start: 6000, End: 7000},
+		{ID: "E", Start: 9000, End: 10000},
+	}
+	tree := BuildIntervalTree(transcripts)
+
+	for start := int64(0); start <= 11000; start += 250 {
+		for _, width := range []int64{0, 100, 1500, 6000} {
+			end := start + width
+			linearIDs := map[string]bool{}
+			for _, tx := range transcripts {
+				if tx.Start <= end && tx.End >= start {
+					linearIDs[tx.ID] = true
+				}
+			}
+			treeIDs := map[string]bool{}
+			for _, tx := range tree.FindOverlapsRange(start, end) {
+				treeIDs[tx.ID] = true
+			}
+			assert.Equal(t, linearIDs, treeIDs, "range=%d-%d", start, end)
+		}
+	}
+}
@@ -0,0 +1,442 @@
+package cache
+
+import (
+	"bufio"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// refseqAssemblies maps assemblies to their NCBI RefSeq genome annotation
+// release directory.
+var refseqAssemblies = map[string]string{
+	"GRCh37": "GCF_000001405.25_GRCh37.p13",
+	"GRCh38": "GCF_000001405.40_GRCh38.p14",
+}
+
+// RefSeqFileURLs returns the NCBI download URLs of the RefSeq GFF3
+// annotation and RefSeq transcript (RNA) FASTA for an assembly.
+func RefSeqFileURLs(assembly string) (gffURL, fastaURL string) {
+	release, ok := refseqAssemblies[assembly]
+	if !ok {
+		release = refseqAssemblies["GRCh38"]
+	}
+	base := "https://ftp.ncbi.nlm.nih.gov/genomes/all/GCF/000/001/405/" + release + "/" + release
+	return base + "_genomic.gff.gz", base + "_rna.fna.gz"
+}
+
+// RefSeqLoader loads curated RefSeq transcripts (NM_/NR_ accessions) from an
+// NCBI RefSeq GFF3 annotation and the matching RefSeq transcript FASTA.
+// Predicted (XM_/XR_) transcripts and transcripts on unplaced, alt and patch
+// sequences are skipped.
+//
+// RefSeq transcript sequences do not always match the reference genome. With
+// a genome set (SetGenome), the coding sequence is taken from the genome so
+// that it agrees with genomic coordinates, and transcripts whose RefSeq
+// sequence differs are flagged with Transcript.GenomeMismatch, which
+// annotations carry as a warning. Without a genome, transcripts whose RefSeq
+// CDS does not fit the exon model (no in-frame stop at the end of the CDS)
+// are dropped, as are transcripts aligned to the genome with gaps (cDNA_match
+// Gap attributes with insertions or deletions), whose RefSeq sequence
+// positions do not follow the exons.
+type RefSeqLoader struct {
+	gffPath        string
+	fastaPath      string
+	genome         *GenomeFASTA
+	secondary      bool
+	transcriptSets []*TranscriptSet
+	gapped         map[string]bool // transcripts aligned to the genome with indels
+	mismatches     int
+	dropped        int
+}
+
+// NewRefSeqLoader creates a loader for RefSeq GFF3 + transcript FASTA files.
+func NewRefSeqLoader(gffPath, fastaPath string) *RefSeqLoader {
+	return &RefSeqLoader{gffPath: gffPath, fastaPath: fastaPath}
+}
+
+// SetGenome sets the reference genome used to check RefSeq sequences.
+func (l *RefSeqLoader) SetGenome(g *GenomeFASTA) {
+	l.genome = g
+}
+
+// SetSecondary marks the RefSeq transcripts as loaded alongside GENCODE
+// (--transcript-set merged): RefSeq Select transcripts are then not flagged
+// as MSK canonical, so canonical picks stay on the GENCODE transcripts.
+func (l *RefSeqLoader) SetSecondary(secondary bool) {
+	l.secondary = secondary
+}
+
+// SetTranscriptSets sets named transcript sets; each transcript records the
+// names of the sets listing it in Transcript.TranscriptSets.
+func (l *RefSeqLoader) SetTranscriptSets(sets []*TranscriptSet) {
+	l.transcriptSets = sets
+}
+
+// Mismatches returns the number of loaded transcripts whose RefSeq sequence
+// differs from the reference genome.
+func (l *RefSeqLoader) Mismatches() int {
+	return l.mismatches
+}
+
+// Dropped returns the number of coding transcripts left out because their
+// RefSeq sequence could not be used and no genome was set.
+func (l *RefSeqLoader) Dropped() int {
+	return l.dropped
+}
+
+// Load loads all RefSeq transcripts and sequences into the cache.
+func (l *RefSeqLoader) Load(c *Cache) error {
+	transcripts, err := l.loadGFF()
+	if err != nil {
+		return fmt.Errorf("load GFF3: %w", err)
+	}
+
+	var fasta *FASTALoader
+	if l.fastaPath != "" {
+		fasta = NewFASTALoader(l.fastaPath)
+		if err := fasta.Load(); err != nil {
+			return fmt.Errorf("load FASTA: %w", err)
+		}
+	}
+
+	l.mismatches, l.dropped = 0, 0
+	for _, t := range transcripts {
+		if t.IsProteinCoding() && (fasta != nil || l.genome != nil) && !l.attachSequence(t, fasta) {
+			l.dropped++
+			continue
+		}
+		c.AddTranscript(t)
+	}
+	if len(l.transcriptSets) > 0 {
+		applyTranscriptSets(c, l.transcriptSets)
+	}
+	return nil
+}
+
+// loadGFF opens and parses the GFF3 file.
+func (l *RefSeqLoader) loadGFF() ([]*Transcript, error) {
+	f, err := os.Open(l.gffPath)
+	if err != nil {
+		return nil, fmt.Errorf("open GFF3 file: %w", err)
+	}
+	defer f.Close()
+
+	var reader io.Reader = f
+	if strings.HasSuffix(l.gffPath, ".gz") {
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			return nil, fmt.Errorf("open gzip reader: %w", err)
+		}
+		defer gz.Close()
+		reader = gz
+	}
+	return l.parseGFF(reader)
+}
+
+// parseGFF parses RefSeq GFF3 content and returns the curated transcripts in
+// file order.
+func (l *RefSeqLoader) parseGFF(reader io.Reader) ([]*Transcript, error) {
+	scanner := bufio.NewScanner(reader)
+	buf := make([]byte, 0, 64*1024)
+	scanner.Buffer(buf, 1024*1024)
+
+	var order []string
+	transcripts := make(map[string]*Transcript) // GFF ID (e.g. rna-NM_004333.6) -> transcript
+	exonsByParent := make(map[string][]Exon)
+	cdsByParent := make(map[string][][2]int64)
+	proteinByParent := make(map[string]string)
+	l.gapped = make(map[string]bool)
+
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" || line[0] == '#' {
+			continue
+		}
+		fields := strings.Split(line, "\t")
+		if len(fields) < 9 {
+			continue
+		}
+		chrom, ok := refseqChrom(fields[0])
+		if !ok {
+			continue
+		}
+		start, err1 := strconv.ParseInt(fields[3], 10, 64)
+		end, err2 := strconv.ParseInt(fields[4], 10, 64)
+		if err1 != nil || err2 != nil {
+			continue
+		}
+		attrs := parseGFFAttributes(fields[8])
+
+		parent := attrs["Parent"]
+		switch fields[2] {
+		case "cDNA_match":
+			target, _, _ := strings.Cut(attrs["Target"], " ")
+			if isCuratedRefSeq(target) && hasIndelGap(attrs["Gap"]) {
+				l.gapped[target] = true
+			}
+		case "exon":
+			if isCuratedRefSeq(strings.TrimPrefix(parent, "rna-")) {
+				exonsByParent[parent] = append(exonsByParent[parent], Exon{Start: start, End: end, Frame: -1})
+			}
+		case "CDS":
+			if isCuratedRefSeq(strings.TrimPrefix(parent, "rna-")) {
+				cdsByParent[parent] = append(cdsByParent[parent], [2]int64{start, end})
+				proteinByParent[parent] = attrs["protein_id"]
+			}
+		default:
+			id := attrs["transcript_id"]
+			if id == "" || !strings.HasPrefix(attrs["ID"], "rna-") || !isCuratedRefSeq(id) {
+				continue
+			}
+			tags := attrs["tag"]
+			t := &Transcript{
+				ID:           id,
+				GeneName:     attrs["gene"],
+				Chrom:        chrom,
+				Start:        start,
+				End:          end,
+				Strand:       parseStrand(fields[6]),
+				Biotype:      refseqBiotype(fields[2]),
+				IsMANESelect: strings.Contains(tags, "MANE Select"),
+			}
+			t.IsCanonicalMSK = !l.secondary && (t.IsMANESelect || strings.Contains(tags, "RefSeq Select"))
+			for _, xref := range strings.Split(attrs["Dbxref"], ",") {
+				switch {
+				case strings.HasPrefix(xref, "GeneID:"):
+					t.GeneID = strings.TrimPrefix(xref, "GeneID:")
+					t.EntrezGeneID = t.GeneID
+				case strings.HasPrefix(xref, "HGNC:"):
+					t.HGNCId = strings.TrimPrefix(xref, "HGNC:")
+				}
+			}
+			if _, dup := transcripts[attrs["ID"]]; !dup {
+				order = append(order, attrs["ID"])
+			}
+			transcripts[attrs["ID"]] = t
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("scan GFF3: %w", err)
+	}
+
+	result := make([]*Transcript, 0, len(order))
+	for _, gffID := range order {
+		t := transcripts[gffID]
+		exons := exonsByParent[gffID]
+		if len(exons) == 0 {
+			continue
+		}
+		sort.Slice(exons, func(i, j int) bool { return exons[i].Start < exons[j].Start })
+		for i := range exons {
+			if t.Strand == 1 {
+				exons[i].Number = i + 1
+			} else {
+				exons[i].Number = len(exons) - i
+			}
+		}
+		for _, region := range cdsByParent[gffID] {
+			if t.CDSStart == 0 || region[0] < t.CDSStart {
+				t.CDSStart = region[0]
+			}
+			if region[1] > t.CDSEnd {
+				t.CDSEnd = region[1]
+			}
+		}
+		t.ProteinID = proteinByParent[gffID]
+		assignExonCDS(t, exons)
+		t.Exons = exons
+		result = append(result, t)
+	}
+	return result, nil
+}
+
+// attachSequence sets the CDS and 3'UTR sequences of a coding transcript from
+// the RefSeq FASTA and/or the reference genome. It returns false if the
+// transcript has no usable sequence and should be dropped.
+func (l *RefSeqLoader) attachSequence(t *Transcript, fasta *FASTALoader) bool {
+	utr5, cdsLen := t.cdsOffset()
+	var cds, utr3 string
+	if fasta != nil {
+		if _, seq, ok := fasta.lookupSequence(t.ID); ok && int64(len(seq)) >= utr5+cdsLen {
+			cds = seq[utr5 : utr5+cdsLen]
+			utr3 = seq[utr5+cdsLen : min(int64(len(seq)), utr5+cdsLen+300)]
+		}
+	}
+
+	if l.genome != nil {
+		if spliced, err := splicedGenomicSequence(l.genome, t); err == nil && int64(len(spliced)) >= utr5+cdsLen {
+			gCDS := spliced[utr5 : utr5+cdsLen]
+			if l.gapped[t.ID] || (cds != "" && cds != gCDS) {
+				t.GenomeMismatch = true
+				l.mismatches++
+			}
+			cds = gCDS
+			utr3 = spliced[utr5+cdsLen : min(int64(len(spliced)), utr5+cdsLen+300)]
+			t.setCDSSequence(cds, utr3)
+			return true
+		}
+	}
+
+	// Without the genome, a gapped alignment means the exon offsets do not
+	// locate the CDS in the RefSeq sequence.
+	if l.gapped[t.ID] || cds == "" || !endsInFrameStop(cds) {
+		return false
+	}
+	t.setCDSSequence(cds, utr3)
+	return true
+}
+
+// hasIndelGap reports whether a GFF3 Gap attribute (e.g. "M185 I3 M250")
+// has insertions or deletions.
+func hasIndelGap(gap string) bool {
+	for _, op := range strings.Fields(gap) {
+		if op[0] == 'I' || op[0] == 'D' {
+			return true
+		}
+	}
+	return false
+}
+
+// setCDSSequence sets the CDS and 3'UTR sequences and the protein length.
+func (t *Transcript) setCDSSequence(cds, utr3 string) {
+	t.CDSSequence = cds
+	t.UTR3Sequence = utr3
+	t.ProteinLength = len(cds) / 3
+	if t.ProteinLength > 0 {
+		t.ProteinLength-- // subtract stop codon
+	}
+}
+
+// cdsOffset returns the spliced length of the 5'UTR and of the CDS.
+func (t *Transcript) cdsOffset() (utr5, cdsLen int64) {
+	for _, e := range t.Exons {
+		if e.IsCoding() {
+			cdsLen += e.CDSEnd - e.CDSStart + 1
+		}
+		switch {
+		case t.Strand == 1 && e.Start < t.CDSStart:
+			utr5 += min(e.End, t.CDSStart-1) - e.Start + 1
+		case t.Strand == -1 && e.End > t.CDSEnd:
+			utr5 += e.End - max(e.Start, t.CDSEnd+1) + 1
+		}
+	}
+	return utr5, cdsLen
+}
+
+// splicedGenomicSequence returns the exonic genome sequence of a transcript
+// in transcript orientation.
+func splicedGenomicSequence(g *GenomeFASTA, t *Transcript) (string, error) {
+	var b strings.Builder
+	for _, e := range t.Exons {
+		seq, err := g.Fetch(t.Chrom, e.Start, e.End)
+		if err != nil {
+			return "", err
+		}
+		b.WriteString(seq)
+	}
+	if t.Strand == -1 {
+		return reverseComplement(b.String()), nil
+	}
+	return b.String(), nil
+}
+
+// endsInFrameStop returns true if cds is a whole number of codons ending in
+// its only stop codon.
+func endsInFrameStop(cds string) bool {
+	if len(cds) < 3 || len(cds)%3 != 0 {
+		return false
+	}
+	for i := 0; i < len(cds); i += 3 {
+		switch cds[i : i+3] {
+		case "TAA", "TAG", "TGA":
+			return i == len(cds)-3
+		}
+	}
+	return false
+}
+
+// reverseComplement returns the reverse complement of a DNA sequence.
+func reverseComplement(seq string) string {
+	out := make([]byte, len(seq))
+	for i := 0; i < len(seq); i++ {
+		var c byte
+		switch seq[i] {
+		case 'A':
+			c = 'T'
+		case 'T':
+			c = 'A'
+		case 'C':
+			c = 'G'
+		case 'G':
+			c = 'C'
+		default:
+			c = 'N'
+		}
+		out[len(seq)-1-i] = c
+	}
+	return string(out)
+}
+
+// refseqChrom maps a RefSeq chromosome accession (e.g. NC_000007.14) to a
+// chromosome name. Unplaced, alt and patch sequences are not mapped.
+func refseqChrom(seqID string) (string, bool) {
+	if strings.HasPrefix(seqID, "NC_012920.") {
+		return "MT", true
+	}
+	num, ok := strings.CutPrefix(seqID, "NC_0000")
+	if !ok || len(num) < 2 {
+		return "", false
+	}
+	n, err := strconv.Atoi(num[:2])
+	switch {
+	case err != nil || n < 1 || n > 24:
+		return "", false
+	case n == 23:
+		return "X", true
+	case n == 24:
+		return "Y", true
+	}
+	return strconv.Itoa(n), true
+}
+
+// isCuratedRefSeq returns true for curated RefSeq transcript accessions.
+func isCuratedRefSeq(id string) bool {
+	return strings.HasPrefix(id, "NM_") || strings.HasPrefix(id, "NR_")
+}
+
+// refseqBiotype maps a GFF3 transcript feature type to a GENCODE-style
+// biotype.
+func refseqBiotype(featureType string) string {
+	switch featureType {
+	case "mRNA":
+		return "protein_coding"
+	case "lnc_RNA":
+		return "lncRNA"
+	case "transcript", "primary_transcript":
+		return "misc_RNA"
+	}
+	return featureType
+}
+
+// parseGFFAttributes parses a GFF3 attribute column (key=value;key=value),
+// decoding percent-escaped values.
+func parseGFFAttributes(attrStr string) map[string]string {
+	attrs := make(map[string]string)
+	for _, part := range strings.Split(attrStr, ";") {
+		key, value, ok := strings.Cut(part, "=")
+		if !ok {
+			continue
+		}
+		if unescaped, err := url.PathUnescape(value); err == nil {
+			value = unescaped
+		}
+		attrs[strings.TrimSpace(key)] = value
+	}
+	return attrs
+}
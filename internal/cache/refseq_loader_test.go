@@ -0,0 +1,194 @@
+package cache
+
+import (
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Test genome: a two-exon plus-strand gene on chr1 (exons 6-15 and 21-30,
+// CDS 8-27 = ATG AAA CCT GGC TAA) and its mirror image on the minus strand
+// of chr2.
+const refseqTestChr1 = "GGGGG" + "CCATGAAACC" + "GTAAG" + "TGGCTAAGGG" + "TTTTT"
+
+const refseqTestGFF = `##gff-version 3
+NC_000001.11	BestRefSeq	gene	6	30	.	+	.	ID=gene-TST1;Dbxref=GeneID:1001;gene=TST1
+NC_000001.11	BestRefSeq	mRNA	6	30	.	+	.	ID=rna-NM_000001.1;Parent=gene-TST1;Dbxref=GeneID:1001,HGNC:HGNC:11;gene=TST1;tag=MANE Select;transcript_id=NM_000001.1
+NC_000001.11	BestRefSeq	exon	6	15	.	+	.	ID=exon-NM_000001.1-1;Parent=rna-NM_000001.1;gene=TST1;transcript_id=NM_000001.1
+NC_000001.11	BestRefSeq	exon	21	30	.	+	.	ID=exon-NM_000001.1-2;Parent=rna-NM_000001.1;gene=TST1;transcript_id=NM_000001.1
+NC_000001.11	BestRefSeq	CDS	8	15	.	+	0	ID=cds-NP_000001.1;Parent=rna-NM_000001.1;gene=TST1;protein_id=NP_000001.1
+NC_000001.11	BestRefSeq	CDS	21	27	.	+	1	ID=cds-NP_000001.1;Parent=rna-NM_000001.1;gene=TST1;protein_id=NP_000001.1
+NC_000001.11	BestRefSeq	mRNA	6	30	.	+	.	ID=rna-NM_000002.1;Parent=gene-TST1;gene=TST1;tag=RefSeq Select;transcript_id=NM_000002.1
+NC_000001.11	BestRefSeq	exon	6	15	.	+	.	Parent=rna-NM_000002.1;transcript_id=NM_000002.1
+NC_000001.11	BestRefSeq	exon	21	30	.	+	.	Parent=rna-NM_000002.1;transcript_id=NM_000002.1
+NC_000001.11	BestRefSeq	CDS	8	15	.	+	0	Parent=rna-NM_000002.1;protein_id=NP_000002.1
+NC_000001.11	BestRefSeq	CDS	21	27	.	+	1	Parent=rna-NM_000002.1;protein_id=NP_000002.1
+NC_000001.11	BestRefSeq	mRNA	6	30	.	+	.	ID=rna-NM_000003.1;Parent=gene-TST1;gene=TST1;transcript_id=NM_000003.1
+NC_000001.11	BestRefSeq	exon	6	15	.	+	.	Parent=rna-NM_000003.1;transcript_id=NM_000003.1
+NC_000001.11	BestRefSeq	exon	21	30	.	+	.	Parent=rna-NM_000003.1;transcript_id=NM_000003.1
+NC_000001.11	BestRefSeq	CDS	8	15	.	+	0	Parent=rna-NM_000003.1;protein_id=NP_000003.1
+NC_000001.11	BestRefSeq	CDS	21	27	.	+	1	Parent=rna-NM_000003.1;protein_id=NP_000003.1
+NC_000001.11	RefSeq	cDNA_match	6	15	.	+	.	ID=aln-NM_000003.1;Target=NM_000003.1 1 11 +;gap_count=1;Gap=M1 I1 M9
+NC_000001.11	RefSeq	cDNA_match	21	30	.	+	.	ID=aln-NM_000003.1;Target=NM_000003.1 12 21 +;gap_count=1
+NC_000001.11	Gnomon	mRNA	6	30	.	+	.	ID=rna-XM_000001.1;Parent=gene-TST1;gene=TST1;transcript_id=XM_000001.1
+NC_000001.11	Gnomon	exon	6	30	.	+	.	Parent=rna-XM_000001.1;transcript_id=XM_000001.1
+NT_187361.1	BestRefSeq	mRNA	6	30	.	+	.	ID=rna-NM_000009.1;gene=ALT;transcript_id=NM_000009.1
+NT_187361.1	BestRefSeq	exon	6	30	.	+	.	Parent=rna-NM_000009.1;transcript_id=NM_000009.1
+NC_000002.12	BestRefSeq	mRNA	6	30	.	-	.	ID=rna-NM_000004.1;gene=TST2;transcript_id=NM_000004.1
+NC_000002.12	BestRefSeq	exon	6	15	.	-	.	Parent=rna-NM_000004.1;transcript_id=NM_000004.1
+NC_000002.12	BestRefSeq	exon	21	30	.	-	.	Parent=rna-NM_000004.1;transcript_id=NM_000004.1
+NC_000002.12	BestRefSeq	CDS	9	15	.	-	1	Parent=rna-NM_000004.1;protein_id=NP_000004.1
+NC_000002.12	BestRefSeq	CDS	21	28	.	-	0	Parent=rna-NM_000004.1;protein_id=NP_000004.1
+NC_000002.12	BestRefSeq	lnc_RNA	40	60	.	+	.	ID=rna-NR_000005.1;gene=TST3;transcript_id=NR_000005.1
+NC_000002.12	BestRefSeq	exon	40	60	.	+	.	Parent=rna-NR_000005.1;transcript_id=NR_000005.1
+`
+
+// refseqTestFASTA holds the RefSeq sequences: NM_000001 matches the genome
+// (plus a poly-A tail), NM_000002 has a CDS substitution, NM_000003 a 5'UTR
+// insertion, and NM_000004 matches the genome.
+const refseqTestFASTA = `>NM_000001.1 Homo sapiens test gene 1 (TST1), mRNA
+CCATGAAACCTGGCTAAGGGAAAA
+>NM_000002.1 Homo sapiens test gene 1 (TST1), transcript variant 2, mRNA
+CCATGAAACCTGGATAAGGG
+>NM_000003.1 Homo sapiens test gene 1 (TST1), transcript variant 3, mRNA
+CCCATGAAACCTGGCTAAGGG
+>NM_000004.1 Homo sapiens test gene 2 (TST2), mRNA
+CCATGAAACCTGGCTAAGGG
+`
+
+func loadRefSeqTest(t *testing.T, withGenome bool) (*Cache, *RefSeqLoader) {
+	t.Helper()
+	dir := t.TempDir()
+	gffPath := filepath.Join(dir, "refseq.gff")
+	fastaPath := filepath.Join(dir, "refseq_rna.fna")
+	require.NoError(t, os.WriteFile(gffPath, []byte(refseqTestGFF), 0o644))
+	require.NoError(t, os.WriteFile(fastaPath, []byte(refseqTestFASTA), 0o644))
+
+	loader := NewRefSeqLoader(gffPath, fastaPath)
+	if withGenome {
+		seqs := map[string]string{"1": refseqTestChr1, "2": reverseComplement(refseqTestChr1)}
+		g, err := OpenGenomeFASTA(writeTestGenome(t, []string{"1", "2"}, seqs, 10))
+		require.NoError(t, err)
+		t.Cleanup(func() { g.Close() })
+		loader.SetGenome(g)
+	}
+	c := New()
+	require.NoError(t, loader.Load(c))
+	return c, loader
+}
+
+func TestRefSeqLoader_Transcripts(t *testing.T) {
+	c, _ := loadRefSeqTest(t, false)
+	assert.Equal(t, 4, c.TranscriptCount(), "XM_ and NT_ transcripts are skipped, gapped NM_000003 dropped")
+
+	tx := c.GetTranscript("NM_000001.1")
+	require.NotNil(t, tx)
+	assert.Equal(t, "1", tx.Chrom)
+	assert.Equal(t, "TST1", tx.GeneName)
+	assert.Equal(t, "1001", tx.GeneID)
+	assert.Equal(t, "1001", tx.EntrezGeneID)
+	assert.Equal(t, "HGNC:11", tx.HGNCId)
+	assert.Equal(t, "NP_000001.1", tx.ProteinID)
+	assert.Equal(t, "protein_coding", tx.Biotype)
+	assert.True(t, tx.IsMANESelect)
+	assert.True(t, tx.IsCanonicalMSK)
+	assert.Equal(t, int64(8), tx.CDSStart)
+	assert.Equal(t, int64(27), tx.CDSEnd)
+	assert.Equal(t, "ATGAAACCTGGCTAA", tx.CDSSequence)
+	assert.Equal(t, "GGGAAAA", tx.UTR3Sequence)
+	assert.Equal(t, 4, tx.ProteinLength)
+	assert.False(t, tx.GenomeMismatch)
+
+	assert.True(t, c.GetTranscript("NM_000002.1").IsCanonicalMSK, "RefSeq Select")
+
+	minus := c.GetTranscript("NM_000004.1")
+	require.NotNil(t, minus)
+	assert.Equal(t, int8(-1), minus.Strand)
+	assert.Equal(t, 1, minus.Exons[1].Number, "exon numbers follow the transcript")
+	assert.Equal(t, "ATGAAACCTGGCTAA", minus.CDSSequence)
+
+	nr := c.GetTranscript("NR_000005.1")
+	require.NotNil(t, nr)
+	assert.Equal(t, "lncRNA", nr.Biotype)
+	assert.False(t, nr.IsProteinCoding())
+}
+
+func TestRefSeqLoader_GenomeMismatch(t *testing.T) {
+	// Without a genome, the substitution goes unnoticed but NM_000003, whose
+	// alignment has a gap, is dropped.
+	c, loader := loadRefSeqTest(t, false)
+	assert.Equal(t, "ATGAAACCTGGATAA", c.GetTranscript("NM_000002.1").CDSSequence)
+	assert.False(t, c.GetTranscript("NM_000002.1").GenomeMismatch)
+	assert.Nil(t, c.GetTranscript("NM_000003.1"))
+	assert.Equal(t, 0, loader.Mismatches())
+	assert.Equal(t, 1, loader.Dropped())
+
+	// With a genome, both are flagged and use the genomic CDS.
+	c, loader = loadRefSeqTest(t, true)
+	for _, id := range []string{"NM_000002.1", "NM_000003.1"} {
+		tx := c.GetTranscript(id)
+		assert.True(t, tx.GenomeMismatch, id)
+		assert.Equal(t, "ATGAAACCTGGCTAA", tx.CDSSequence, id)
+		assert.Equal(t, "GGG", tx.UTR3Sequence, id)
+	}
+	assert.False(t, c.GetTranscript("NM_000001.1").GenomeMismatch)
+	assert.False(t, c.GetTranscript("NM_000004.1").GenomeMismatch)
+	assert.Equal(t, 2, loader.Mismatches())
+	assert.Equal(t, 0, loader.Dropped())
+}
+
+func TestHasIndelGap(t *testing.T) {
+	assert.True(t, hasIndelGap("M185 I3 M250"))
+	assert.True(t, hasIndelGap("M10 D1 M5"))
+	assert.False(t, hasIndelGap("M435"))
+	assert.False(t, hasIndelGap(""))
+}
+
+func TestRefSeqLoader_Secondary(t *testing.T) {
+	dir := t.TempDir()
+	gffPath := filepath.Join(dir, "refseq.gff.gz")
+	writeGzip(t, gffPath, refseqTestGFF)
+
+	loader := NewRefSeqLoader(gffPath, "")
+	loader.SetSecondary(true)
+	c := New()
+	require.NoError(t, loader.Load(c))
+	tx := c.GetTranscript("NM_000001.1")
+	require.NotNil(t, tx)
+	assert.True(t, tx.IsMANESelect)
+	assert.False(t, tx.IsCanonicalMSK)
+	assert.Empty(t, tx.CDSSequence)
+}
+
+func TestRefSeqChrom(t *testing.T) {
+	for in, want := range map[string]string{
+		"NC_000001.11": "1", "NC_000010.10": "10", "NC_000023.11": "X",
+		"NC_000024.10": "Y", "NC_012920.1": "MT", "NT_187361.1": "", "NC_000025.1": "",
+	} {
+		got, ok := refseqChrom(in)
+		assert.Equal(t, want, got, in)
+		assert.Equal(t, want != "", ok, in)
+	}
+}
+
+func TestRefSeqFileURLs(t *testing.T) {
+	gff, fasta := RefSeqFileURLs("GRCh37")
+	assert.True(t, strings.HasSuffix(gff, "GCF_000001405.25_GRCh37.p13_genomic.gff.gz"), gff)
+	assert.True(t, strings.HasSuffix(fasta, "GCF_000001405.25_GRCh37.p13_rna.fna.gz"), fasta)
+}
+
+func writeGzip(t *testing.T, path, content string) {
+	t.Helper()
+	f, err := os.Create(path)
+	require.NoError(t, err)
+	zw := gzip.NewWriter(f)
+	_, err = zw.Write([]byte(content))
+	require.NoError(t, err)
+	require.NoError(t, zw.Close())
+	require.NoError(t, f.Close())
+}
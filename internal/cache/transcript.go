@@ -27,6 +27,8 @@ type Transcript struct {
 	IsCanonicalMSK     bool // MSK canonical transcript
 	IsCanonicalEnsembl bool // Ensembl canonical transcript (from GTF tag)
 	IsMANESelect    bool   // MANE Select transcript
+	TranscriptSets  []string // Names of the user transcript sets listing this transcript
+	GenomeMismatch  bool     // RefSeq transcript sequence differs from the reference genome
 	Exons           []Exon // Exons sorted ascending by genomic Start
 	CDSStart        int64  // CDS start (genomic, 1-based), 0 if non-coding
 	CDSEnd          int64  // CDS end (genomic, 1-based), 0 if non-coding
@@ -130,6 +132,22 @@ func (t *Transcript) IsProteinCoding() bool {
 	return t.CDSStart > 0 && t.CDSEnd > 0
 }
 
+// Length returns the translated (CDS) length in bases of a coding transcript,
+// or the spliced exonic length of a non-coding one.
+func (t *Transcript) Length() int64 {
+	coding := t.IsProteinCoding()
+	var n int64
+	for _, e := range t.Exons {
+		switch {
+		case !coding:
+			n += e.End - e.Start + 1
+		case e.IsCoding():
+			n += e.CDSEnd - e.CDSStart + 1
+		}
+	}
+	return n
+}
+
 // IsForwardStrand returns true if the transcript is on the forward strand.
 func (t *Transcript) IsForwardStrand() bool {
 	return t.Strand == 1
@@ -0,0 +1,95 @@
+package cache
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// TranscriptSet is a named, user-supplied list of transcripts, such as a
+// reporting isoform list or the OncoKB isoforms.
+type TranscriptSet struct {
+	Name        string
+	Transcripts map[string]bool // versionless transcript IDs
+}
+
+// transcriptSetNameRe restricts set names to what can be used in column names.
+var transcriptSetNameRe = regexp.MustCompile(`^[a-z][a-z0-9_]*$`)
+
+// ValidateTranscriptSetName returns an error unless name is lowercase
+// letters, digits and underscores, starting with a letter.
+func ValidateTranscriptSetName(name string) error {
+	if !transcriptSetNameRe.MatchString(name) {
+		return fmt.Errorf("invalid transcript set name %q (use lowercase letters, digits and _)", name)
+	}
+	return nil
+}
+
+// LoadTranscriptSet loads a transcript set from a TSV file.
+func LoadTranscriptSet(name, path string) (*TranscriptSet, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("open transcript set %s: %w", name, err)
+	}
+	defer f.Close()
+
+	set, err := ParseTranscriptSet(name, f)
+	if err != nil {
+		return nil, fmt.Errorf("transcript set %s (%s): %w", name, path, err)
+	}
+	return set, nil
+}
+
+// ParseTranscriptSet parses transcript set TSV content: one transcript per
+// line as gene<TAB>transcript_id, or just transcript_id. Transcript versions
+// are ignored. Blank lines, # comments and a header row (a transcript column
+// without digits, e.g. "transcript_id") are skipped.
+func ParseTranscriptSet(name string, reader io.Reader) (*TranscriptSet, error) {
+	if err := ValidateTranscriptSetName(name); err != nil {
+		return nil, err
+	}
+	set := &TranscriptSet{Name: name, Transcripts: make(map[string]bool)}
+	scanner := bufio.NewScanner(reader)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || line[0] == '#' {
+			continue
+		}
+		fields := strings.Split(line, "\t")
+		id := strings.TrimSpace(fields[0])
+		if len(fields) > 1 {
+			id = strings.TrimSpace(fields[1])
+		}
+		if !strings.ContainsAny(id, "0123456789") {
+			continue
+		}
+		set.Transcripts[stripVersion(id)] = true
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("scan transcript set: %w", err)
+	}
+	if len(set.Transcripts) == 0 {
+		return nil, fmt.Errorf("no transcripts found")
+	}
+	return set, nil
+}
+
+// applyTranscriptSets records in each transcript the names of the sets that
+// list it, in the order of sets.
+func applyTranscriptSets(c *Cache, sets []*TranscriptSet) {
+	for _, chrom := range c.Chromosomes() {
+		for _, t := range c.FindTranscriptsByChrom(chrom) {
+			t.TranscriptSets = nil
+			id := stripVersion(t.ID)
+			for _, set := range sets {
+				if set.Transcripts[id] && !slices.Contains(t.TranscriptSets, set.Name) {
+					t.TranscriptSets = append(t.TranscriptSets, set.Name)
+				}
+			}
+		}
+	}
+}
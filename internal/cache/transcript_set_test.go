@@ -0,0 +1,42 @@
+package cache
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseTranscriptSet(t *testing.T) {
+	input := "# OncoKB isoforms\n" +
+		"gene\ttranscript_id\n" +
+		"KRAS\tENST00000311936.8\n" +
+		"\n" +
+		"ENST00000269305\n"
+
+	set, err := ParseTranscriptSet("oncokb", strings.NewReader(input))
+	require.NoError(t, err)
+	assert.Equal(t, "oncokb", set.Name)
+	assert.Equal(t, map[string]bool{"ENST00000311936": true, "ENST00000269305": true}, set.Transcripts)
+
+	_, err = ParseTranscriptSet("oncokb", strings.NewReader("gene\ttranscript_id\n"))
+	assert.ErrorContains(t, err, "no transcripts")
+	_, err = ParseTranscriptSet("OncoKB-v4", strings.NewReader(input))
+	assert.ErrorContains(t, err, "invalid transcript set name")
+}
+
+func TestApplyTranscriptSets(t *testing.T) {
+	c := New()
+	kras := &Transcript{ID: "ENST00000311936.8", Chrom: "12", Start: 100, End: 200}
+	other := &Transcript{ID: "ENST00000256078.10", Chrom: "12", Start: 100, End: 300}
+	c.AddTranscript(kras)
+	c.AddTranscript(other)
+
+	oncokb := &TranscriptSet{Name: "oncokb", Transcripts: map[string]bool{"ENST00000311936": true}}
+	report := &TranscriptSet{Name: "report", Transcripts: map[string]bool{"ENST00000311936": true, "ENST00000256078": true}}
+	applyTranscriptSets(c, []*TranscriptSet{oncokb, report})
+
+	assert.Equal(t, []string{"oncokb", "report"}, kras.TranscriptSets)
+	assert.Equal(t, []string{"report"}, other.TranscriptSets)
+}
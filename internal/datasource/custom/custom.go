@@ -0,0 +1,719 @@
+// Package custom provides user-configured annotation sources, the equivalent
+// of VEP's --custom: a VCF, BED or TSV file whose fields are attached to
+// matching variants as extra columns. Each file is indexed into a SQLite
+// database on first use and rebuilt when the file or its configuration
+// changes.
+package custom
+
+import (
+	"bufio"
+	"compress/gzip"
+	"database/sql"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/inodb/vibe-vep/internal/annotate"
+	"github.com/inodb/vibe-vep/internal/genomicindex"
+	_ "modernc.org/sqlite"
+)
+
+// File formats.
+const (
+	FormatVCF = "vcf"
+	FormatBED = "bed"
+	FormatTSV = "tsv"
+)
+
+// Match modes.
+const (
+	MatchExact           = "exact"            // chrom, pos, ref and alt
+	MatchOverlap         = "overlap"          // any overlap with the variant's REF span
+	MatchProteinPosition = "protein_position" // transcript (or gene) and amino acid position
+	MatchGene            = "gene"             // gene symbol
+)
+
+// Config describes one custom source, registered in config under
+// custom.<name>.
+type Config struct {
+	Name    string            `mapstructure:"-"`
+	File    string            `mapstructure:"file"`
+	Format  string            `mapstructure:"format"`  // vcf, bed or tsv (default: from the file extension)
+	Match   string            `mapstructure:"match"`   // exact, overlap, protein_position or gene
+	Fields  []string          `mapstructure:"fields"`  // INFO keys (VCF), extra columns in order (BED) or header names (TSV)
+	Columns map[string]string `mapstructure:"columns"` // TSV header names of chrom, pos, end, ref, alt, gene, transcript, protein_position
+	Version string            `mapstructure:"version"`
+}
+
+// defaultColumns are the TSV header names used for the key columns unless
+// overridden in Config.Columns.
+var defaultColumns = map[string]string{
+	"chrom":            "chrom",
+	"pos":              "pos",
+	"end":              "end",
+	"ref":              "ref",
+	"alt":              "alt",
+	"gene":             "gene",
+	"transcript":       "transcript",
+	"protein_position": "protein_position",
+}
+
+var (
+	nameRe  = regexp.MustCompile(`^[a-z][a-z0-9_]*$`)
+	fieldRe = regexp.MustCompile(`^[A-Za-z0-9_.-]+$`)
+)
+
+// Validate fills in the format from the file extension and checks that the
+// format, match mode and fields are usable together.
+func (c *Config) Validate() error {
+	if !nameRe.MatchString(c.Name) {
+		return fmt.Errorf("invalid custom source name %q (use lowercase letters, digits and _)", c.Name)
+	}
+	if c.File == "" {
+		return fmt.Errorf("custom source %s: file is required", c.Name)
+	}
+	if c.Format == "" {
+		c.Format = formatFromPath(c.File)
+	}
+	if len(c.Fields) == 0 {
+		return fmt.Errorf("custom source %s: fields is required", c.Name)
+	}
+	for _, f := range c.Fields {
+		if !fieldRe.MatchString(f) {
+			return fmt.Errorf("custom source %s: invalid field name %q", c.Name, f)
+		}
+	}
+
+	var modes []string
+	switch c.Format {
+	case FormatVCF:
+		modes = []string{MatchExact, MatchOverlap}
+	case FormatBED:
+		modes = []string{MatchOverlap}
+	case FormatTSV:
+		modes = []string{MatchExact, MatchOverlap, MatchProteinPosition, MatchGene}
+	default:
+		return fmt.Errorf("custom source %s: unknown format %q (use vcf, bed or tsv)", c.Name, c.Format)
+	}
+	if c.Match == "" {
+		c.Match = modes[0]
+	}
+	for _, m := range modes {
+		if c.Match == m {
+			return nil
+		}
+	}
+	return fmt.Errorf("custom source %s: match %q is not supported for %s (use %s)", c.Name, c.Match, c.Format, strings.Join(modes, ", "))
+}
+
+// MatchLevel returns the annotate.MatchLevel of the match mode.
+func (c *Config) MatchLevel() annotate.MatchLevel {
+	switch c.Match {
+	case MatchProteinPosition:
+		return annotate.MatchProteinPosition
+	case MatchGene:
+		return annotate.MatchGene
+	default:
+		return annotate.MatchGenomic
+	}
+}
+
+// ColumnDefs returns the columns the source provides, one per field.
+func (c *Config) ColumnDefs() []annotate.ColumnDef {
+	cols := make([]annotate.ColumnDef, len(c.Fields))
+	for i, f := range c.Fields {
+		cols[i] = annotate.ColumnDef{Name: f, Description: f + " from " + filepath.Base(c.File)}
+	}
+	return cols
+}
+
+// SourceVersion returns the configured version, else the file's
+// modification date.
+func (c *Config) SourceVersion() string {
+	if c.Version != "" {
+		return c.Version
+	}
+	if fi, err := os.Stat(c.File); err == nil {
+		return fi.ModTime().Format("2006-01-02")
+	}
+	return ""
+}
+
+// formatFromPath guesses the format from the file extension, ignoring .gz/.bgz.
+func formatFromPath(path string) string {
+	p := strings.ToLower(path)
+	p = strings.TrimSuffix(strings.TrimSuffix(p, ".gz"), ".bgz")
+	switch {
+	case strings.HasSuffix(p, ".vcf"):
+		return FormatVCF
+	case strings.HasSuffix(p, ".bed"):
+		return FormatBED
+	default:
+		return FormatTSV
+	}
+}
+
+// column returns the TSV header name for a key column.
+func (c *Config) column(key string) string {
+	if name, ok := c.Columns[key]; ok {
+		return name
+	}
+	return defaultColumns[key]
+}
+
+// fingerprint identifies the settings an index was built with, so a config
+// change rebuilds it.
+func (c *Config) fingerprint() string {
+	cols := make([]string, 0, len(defaultColumns))
+	for _, key := range []string{"chrom", "pos", "end", "ref", "alt", "gene", "transcript", "protein_position"} {
+		cols = append(cols, key+"="+c.column(key))
+	}
+	return strings.Join([]string{c.Format, c.Match, strings.Join(c.Fields, ","), strings.Join(cols, ",")}, "|")
+}
+
+// Store provides lookups against a custom source's SQLite index.
+type Store struct {
+	db      *sql.DB
+	cfg     Config
+	maxSpan int64 // longest overlap interval, bounds the overlap query
+	query   *sql.Stmt
+
+	fingerprint string // index file size and modification time at Open
+}
+
+// Open opens a custom source index built by Build for cfg.
+func Open(dbPath string, cfg Config) (*Store, error) {
+	db, err := sql.Open("sqlite", dbPath+"?mode=ro&_pragma=mmap_size%3D268435456")
+	if err != nil {
+		return nil, fmt.Errorf("open sqlite: %w", err)
+	}
+	s := &Store{db: db, cfg: cfg, fingerprint: annotate.FileFingerprint(dbPath)}
+
+	var span string
+	if err := db.QueryRow(`SELECT value FROM meta WHERE key = 'max_span'`).Scan(&span); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("read index metadata: %w", err)
+	}
+	s.maxSpan, _ = strconv.ParseInt(span, 10, 64)
+
+	cols := make([]string, len(cfg.Fields))
+	for i := range cols {
+		cols[i] = "f" + strconv.Itoa(i)
+	}
+	sel := "SELECT " + strings.Join(cols, ", ") + " FROM entries WHERE "
+	switch cfg.Match {
+	case MatchExact:
+		sel += "chrom = ? AND start = ? AND ref = ? AND alt = ?"
+	case MatchOverlap:
+		sel += "chrom = ? AND start BETWEEN ? AND ? AND end >= ?"
+	case MatchProteinPosition:
+		sel += "key = ? AND start = ?"
+	case MatchGene:
+		sel += "key = ?"
+	}
+	if s.query, err = db.Prepare(sel + " ORDER BY rowid"); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("prepare lookup: %w", err)
+	}
+	return s, nil
+}
+
+// Close closes the prepared statement and database.
+func (s *Store) Close() error {
+	if s.query != nil {
+		s.query.Close()
+	}
+	return s.db.Close()
+}
+
+// Fingerprint identifies the index build: the database file's size and
+// modification time when it was opened.
+func (s *Store) Fingerprint() string {
+	return s.fingerprint
+}
+
+// Config returns the configuration the store was opened with.
+func (s *Store) Config() Config {
+	return s.cfg
+}
+
+// LookupExact returns the field values of entries with the given allele, in
+// canonical (MAF-style) form as produced by genomicindex.NormalizeAlleles.
+func (s *Store) LookupExact(chrom string, pos int64, ref, alt string) [][]string {
+	return s.lookup(chrom, pos, ref, alt)
+}
+
+// LookupOverlap returns the field values of entries overlapping [start, end].
+func (s *Store) LookupOverlap(chrom string, start, end int64) [][]string {
+	return s.lookup(chrom, start-s.maxSpan, end, start)
+}
+
+// LookupProteinPosition returns the field values of entries for a transcript
+// (versionless) or gene and amino acid position.
+func (s *Store) LookupProteinPosition(key string, pos int64) [][]string {
+	return s.lookup(key, pos)
+}
+
+// LookupGene returns the field values of entries for a gene symbol.
+func (s *Store) LookupGene(gene string) [][]string {
+	return s.lookup(gene)
+}
+
+func (s *Store) lookup(args ...any) [][]string {
+	rows, err := s.query.Query(args...)
+	if err != nil {
+		return nil
+	}
+	defer rows.Close()
+
+	var out [][]string
+	for rows.Next() {
+		vals := make([]string, len(s.cfg.Fields))
+		ptrs := make([]any, len(vals))
+		for i := range vals {
+			ptrs[i] = &vals[i]
+		}
+		if err := rows.Scan(ptrs...); err != nil {
+			return out
+		}
+		out = append(out, vals)
+	}
+	return out
+}
+
+// Ready returns true if dbPath exists, is newer than cfg.File and was built
+// with the same configuration.
+func Ready(dbPath string, cfg Config) bool {
+	dbInfo, err := os.Stat(dbPath)
+	if err != nil || dbInfo.Size() == 0 {
+		return false
+	}
+	if srcInfo, err := os.Stat(cfg.File); err == nil && srcInfo.ModTime().After(dbInfo.ModTime()) {
+		return false
+	}
+
+	db, err := sql.Open("sqlite", dbPath+"?mode=ro")
+	if err != nil {
+		return false
+	}
+	defer db.Close()
+	var fp string
+	if err := db.QueryRow(`SELECT value FROM meta WHERE key = 'config'`).Scan(&fp); err != nil {
+		return false
+	}
+	return fp == cfg.fingerprint()
+}
+
+// entry is one indexed record.
+type entry struct {
+	chrom      string
+	start, end int64
+	ref, alt   string
+	key        string
+	vals       []string
+}
+
+// Build indexes cfg.File into a SQLite database at dbPath (overwriting any
+// existing file) and returns the number of entries.
+func Build(dbPath string, cfg Config) (int64, error) {
+	os.Remove(dbPath)
+
+	db, err := sql.Open("sqlite", dbPath)
+	if err != nil {
+		return 0, fmt.Errorf("create sqlite: %w", err)
+	}
+	defer db.Close()
+
+	for _, pragma := range []string{
+		"PRAGMA journal_mode = OFF",
+		"PRAGMA synchronous = OFF",
+		"PRAGMA temp_store = MEMORY",
+	} {
+		if _, err := db.Exec(pragma); err != nil {
+			return 0, fmt.Errorf("set pragma %q: %w", pragma, err)
+		}
+	}
+
+	var cols strings.Builder
+	for i := range cfg.Fields {
+		fmt.Fprintf(&cols, ",\n\t\tf%d TEXT NOT NULL DEFAULT ''", i)
+	}
+	if _, err := db.Exec(`CREATE TABLE entries (
+		chrom TEXT NOT NULL DEFAULT '',
+		start INTEGER NOT NULL DEFAULT 0,
+		end INTEGER NOT NULL DEFAULT 0,
+		ref TEXT NOT NULL DEFAULT '',
+		alt TEXT NOT NULL DEFAULT '',
+		key TEXT NOT NULL DEFAULT ''` + cols.String() + `
+	)`); err != nil {
+		return 0, fmt.Errorf("create table: %w", err)
+	}
+	if _, err := db.Exec(`CREATE TABLE meta (key TEXT PRIMARY KEY, value TEXT NOT NULL)`); err != nil {
+		return 0, fmt.Errorf("create meta table: %w", err)
+	}
+
+	f, err := os.Open(cfg.File)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	var r io.Reader = f
+	if strings.HasSuffix(cfg.File, ".gz") || strings.HasSuffix(cfg.File, ".bgz") {
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			return 0, err
+		}
+		defer gz.Close()
+		r = gz
+	}
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 4*1024*1024), 4*1024*1024)
+
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	placeholders := strings.Repeat(", ?", len(cfg.Fields))
+	var names strings.Builder
+	for i := range cfg.Fields {
+		fmt.Fprintf(&names, ", f%d", i)
+	}
+	stmt, err := tx.Prepare(`INSERT INTO entries (chrom, start, end, ref, alt, key` + names.String() + `) VALUES (?, ?, ?, ?, ?, ?` + placeholders + `)`)
+	if err != nil {
+		return 0, err
+	}
+	defer stmt.Close()
+
+	var count, maxSpan int64
+	emit := func(e entry) error {
+		args := []any{e.chrom, e.start, e.end, e.ref, e.alt, e.key}
+		for _, v := range e.vals {
+			args = append(args, v)
+		}
+		if _, err := stmt.Exec(args...); err != nil {
+			return fmt.Errorf("insert row: %w", err)
+		}
+		if span := e.end - e.start; span > maxSpan {
+			maxSpan = span
+		}
+		count++
+		return nil
+	}
+
+	switch cfg.Format {
+	case FormatVCF:
+		err = parseVCF(scanner, cfg, emit)
+	case FormatBED:
+		err = parseBED(scanner, cfg, emit)
+	default:
+		err = parseTSV(scanner, cfg, emit)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("parse %s: %w", cfg.File, err)
+	}
+
+	var index string
+	switch cfg.Match {
+	case MatchExact:
+		index = "chrom, start, ref, alt"
+	case MatchOverlap:
+		index = "chrom, start"
+	case MatchProteinPosition:
+		index = "key, start"
+	case MatchGene:
+		index = "key"
+	}
+	if _, err := tx.Exec(`CREATE INDEX entries_lookup ON entries (` + index + `)`); err != nil {
+		return 0, fmt.Errorf("create index: %w", err)
+	}
+	if _, err := tx.Exec(`INSERT INTO meta (key, value) VALUES ('config', ?), ('max_span', ?)`,
+		cfg.fingerprint(), strconv.FormatInt(maxSpan, 10)); err != nil {
+		return 0, fmt.Errorf("write metadata: %w", err)
+	}
+	return count, tx.Commit()
+}
+
+// parseVCF indexes each ALT allele of a VCF. Fields are INFO keys (flags
+// give "Y") or ID, QUAL and FILTER. Values of INFO keys declared Number=A or
+// Number=R are split per allele.
+func parseVCF(scanner *bufio.Scanner, cfg Config, emit func(entry) error) error {
+	number := make(map[string]string) // INFO key → Number
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(line, "##INFO=<") {
+			id, num := infoHeaderNumber(line)
+			number[id] = num
+			continue
+		}
+		if len(line) == 0 || line[0] == '#' {
+			continue
+		}
+		cols := strings.SplitN(line, "\t", 9)
+		if len(cols) < 8 {
+			continue
+		}
+		pos, err := strconv.ParseInt(cols[1], 10, 64)
+		if err != nil {
+			continue
+		}
+		chrom := strings.TrimPrefix(cols[0], "chr")
+		info := parseInfo(cols[7])
+		alts := strings.Split(cols[4], ",")
+
+		for i, alt := range alts {
+			if alt == "." || alt == "*" || strings.HasPrefix(alt, "<") {
+				continue
+			}
+			vals := make([]string, len(cfg.Fields))
+			for j, field := range cfg.Fields {
+				switch field {
+				case "ID":
+					vals[j] = missingToEmpty(cols[2])
+				case "QUAL":
+					vals[j] = missingToEmpty(cols[5])
+				case "FILTER":
+					vals[j] = missingToEmpty(cols[6])
+				default:
+					vals[j] = alleleValue(info[field], number[field], i)
+				}
+			}
+
+			e := entry{chrom: chrom, vals: vals}
+			if cfg.Match == MatchExact {
+				e.start, e.ref, e.alt = genomicindex.NormalizeAlleles(pos, cols[3], alt)
+				e.end = e.start
+			} else {
+				e.start, e.end = pos, pos+int64(len(cols[3]))-1
+			}
+			if err := emit(e); err != nil {
+				return err
+			}
+			if cfg.Match == MatchOverlap {
+				break // the REF span is shared by all alleles
+			}
+		}
+	}
+	return scanner.Err()
+}
+
+// parseInfo splits an INFO column into key/value pairs; flags map to "Y".
+func parseInfo(s string) map[string]string {
+	info := make(map[string]string)
+	if s == "." {
+		return info
+	}
+	for _, kv := range strings.Split(s, ";") {
+		if k, v, ok := strings.Cut(kv, "="); ok {
+			info[k] = v
+		} else if kv != "" {
+			info[kv] = "Y"
+		}
+	}
+	return info
+}
+
+// infoHeaderNumber returns the ID and Number of an ##INFO header line.
+func infoHeaderNumber(line string) (id, number string) {
+	body := strings.TrimSuffix(strings.TrimPrefix(line, "##INFO=<"), ">")
+	for _, kv := range strings.Split(body, ",") {
+		k, v, _ := strings.Cut(kv, "=")
+		switch k {
+		case "ID":
+			id = v
+		case "Number":
+			number = v
+		}
+		if id != "" && number != "" {
+			break
+		}
+	}
+	return id, number
+}
+
+// alleleValue returns the value of ALT allele i for an INFO value with the
+// given header Number.
+func alleleValue(v, number string, i int) string {
+	idx := -1
+	switch number {
+	case "A":
+		idx = i
+	case "R":
+		idx = i + 1
+	}
+	if idx >= 0 {
+		if parts := strings.Split(v, ","); idx < len(parts) {
+			v = parts[idx]
+		}
+	}
+	return missingToEmpty(v)
+}
+
+func missingToEmpty(s string) string {
+	if s == "." {
+		return ""
+	}
+	return s
+}
+
+// parseBED indexes BED intervals. Fields name the columns after chrom, start
+// and end, in order (e.g. name, score).
+func parseBED(scanner *bufio.Scanner, cfg Config, emit func(entry) error) error {
+	for scanner.Scan() {
+		line := scanner.Text()
+		if len(line) == 0 || line[0] == '#' || strings.HasPrefix(line, "track") || strings.HasPrefix(line, "browser") {
+			continue
+		}
+		cols := strings.Split(line, "\t")
+		if len(cols) < 3 {
+			continue
+		}
+		start, err1 := strconv.ParseInt(cols[1], 10, 64)
+		end, err2 := strconv.ParseInt(cols[2], 10, 64)
+		if err1 != nil || err2 != nil {
+			continue
+		}
+		vals := make([]string, len(cfg.Fields))
+		for j := range cfg.Fields {
+			if 3+j < len(cols) {
+				vals[j] = cols[3+j]
+			}
+		}
+		// BED is 0-based half-open; store 1-based closed.
+		e := entry{chrom: strings.TrimPrefix(cols[0], "chr"), start: start + 1, end: end, vals: vals}
+		if err := emit(e); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
+// parseTSV indexes a TSV with a header line. Header names are matched case
+// insensitively, ignoring a leading '#'.
+func parseTSV(scanner *bufio.Scanner, cfg Config, emit func(entry) error) error {
+	var header map[string]int
+	for header == nil && scanner.Scan() {
+		line := scanner.Text()
+		if line == "" {
+			continue
+		}
+		header = make(map[string]int)
+		for i, name := range strings.Split(line, "\t") {
+			header[strings.ToLower(strings.TrimPrefix(name, "#"))] = i
+		}
+	}
+	if header == nil {
+		return scanner.Err()
+	}
+	col := func(key string) int {
+		if i, ok := header[strings.ToLower(cfg.column(key))]; ok {
+			return i
+		}
+		return -1
+	}
+
+	var need []string
+	switch cfg.Match {
+	case MatchExact:
+		need = []string{"chrom", "pos", "ref", "alt"}
+	case MatchOverlap:
+		need = []string{"chrom", "pos"}
+	case MatchProteinPosition:
+		need = []string{"protein_position"}
+		if col("transcript") < 0 && col("gene") < 0 {
+			return fmt.Errorf("no %q or %q column", cfg.column("transcript"), cfg.column("gene"))
+		}
+	case MatchGene:
+		need = []string{"gene"}
+	}
+	for _, key := range need {
+		if col(key) < 0 {
+			return fmt.Errorf("no %q column", cfg.column(key))
+		}
+	}
+	fields := make([]int, len(cfg.Fields))
+	for j, f := range cfg.Fields {
+		i, ok := header[strings.ToLower(f)]
+		if !ok {
+			return fmt.Errorf("no %q column", f)
+		}
+		fields[j] = i
+	}
+	chromCol, posCol, endCol, refCol, altCol := col("chrom"), col("pos"), col("end"), col("ref"), col("alt")
+	txCol, geneCol, protCol := col("transcript"), col("gene"), col("protein_position")
+
+	get := func(cols []string, i int) string {
+		if i < 0 || i >= len(cols) {
+			return ""
+		}
+		return cols[i]
+	}
+	for scanner.Scan() {
+		line := scanner.Text()
+		if len(line) == 0 || line[0] == '#' {
+			continue
+		}
+		cols := strings.Split(line, "\t")
+		vals := make([]string, len(fields))
+		for j, i := range fields {
+			vals[j] = get(cols, i)
+		}
+		e := entry{vals: vals}
+
+		switch cfg.Match {
+		case MatchExact, MatchOverlap:
+			pos, err := strconv.ParseInt(get(cols, posCol), 10, 64)
+			if err != nil {
+				continue
+			}
+			e.chrom = strings.TrimPrefix(get(cols, chromCol), "chr")
+			if cfg.Match == MatchExact {
+				e.start, e.ref, e.alt = genomicindex.NormalizeAlleles(pos, dashToEmpty(get(cols, refCol)), dashToEmpty(get(cols, altCol)))
+				e.end = e.start
+			} else {
+				e.start, e.end = pos, pos
+				if end, err := strconv.ParseInt(get(cols, endCol), 10, 64); err == nil && end > pos {
+					e.end = end
+				}
+			}
+		case MatchProteinPosition:
+			pos, err := strconv.ParseInt(get(cols, protCol), 10, 64)
+			if err != nil {
+				continue
+			}
+			// Rows without a transcript are keyed by gene.
+			e.key, e.start = stripVersion(get(cols, txCol)), pos
+			if e.key == "" {
+				e.key = get(cols, geneCol)
+			}
+		case MatchGene:
+			e.key = get(cols, geneCol)
+		}
+		if err := emit(e); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
+// dashToEmpty maps the MAF "-" allele to the empty canonical allele.
+func dashToEmpty(s string) string {
+	if s == "-" {
+		return ""
+	}
+	return s
+}
+
+// stripVersion removes a transcript version suffix (ENST00000311936.8 →
+// ENST00000311936).
+func stripVersion(id string) string {
+	if i := strings.IndexByte(id, '.'); i >= 0 {
+		return id[:i]
+	}
+	return id
+}
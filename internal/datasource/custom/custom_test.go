@@ -0,0 +1,167 @@
+package custom
+
+import (
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/inodb/vibe-vep/internal/annotate"
+	"github.com/inodb/vibe-vep/internal/vcf"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testVCF = `##fileformat=VCFv4.2
+##INFO=<ID=REASON,Number=.,Type=String,Description="Artefact reasons">
+##INFO=<ID=AF,Number=A,Type=Float,Description="Allele frequency">
+#CHROM	POS	ID	REF	ALT	QUAL	FILTER	INFO
+chr12	25245350	art1	C	A,T	.	PASS	REASON=strand_bias,homopolymer;AF=0.1,0.2;PANEL
+1	66926	art2	AG	A	.	LowQual	REASON=indel_artifact
+`
+
+// openSource builds an index for cfg with the given file contents and
+// returns a Source backed by it.
+func openSource(t *testing.T, cfg Config, name, content string) *Source {
+	t.Helper()
+	dir := t.TempDir()
+	cfg.File = filepath.Join(dir, name)
+	if filepath.Ext(name) == ".gz" {
+		f, err := os.Create(cfg.File)
+		require.NoError(t, err)
+		gz := gzip.NewWriter(f)
+		_, err = gz.Write([]byte(content))
+		require.NoError(t, err)
+		require.NoError(t, gz.Close())
+		require.NoError(t, f.Close())
+	} else {
+		require.NoError(t, os.WriteFile(cfg.File, []byte(content), 0644))
+	}
+	require.NoError(t, cfg.Validate())
+
+	dbPath := filepath.Join(dir, cfg.Name+".sqlite")
+	assert.False(t, Ready(dbPath, cfg))
+	_, err := Build(dbPath, cfg)
+	require.NoError(t, err)
+	assert.True(t, Ready(dbPath, cfg))
+
+	store, err := Open(dbPath, cfg)
+	require.NoError(t, err)
+	t.Cleanup(func() { store.Close() })
+	return NewSource(store)
+}
+
+func annotateOne(s *Source, v *vcf.Variant, ann *annotate.Annotation) *annotate.Annotation {
+	s.Annotate(v, []*annotate.Annotation{ann})
+	return ann
+}
+
+func TestVCFExact(t *testing.T) {
+	s := openSource(t, Config{Name: "artefacts", Fields: []string{"REASON", "AF", "PANEL", "ID", "FILTER"}}, "artefacts.vcf.gz", testVCF)
+	assert.Equal(t, annotate.MatchGenomic, s.MatchLevel())
+	assert.Equal(t, "AF", s.Columns()[1].Name)
+
+	a := annotateOne(s, &vcf.Variant{Chrom: "12", Pos: 25245350, Ref: "C", Alt: "T"}, &annotate.Annotation{})
+	assert.Equal(t, "strand_bias,homopolymer", a.GetExtra("artefacts", "REASON"))
+	assert.Equal(t, "0.2", a.GetExtra("artefacts", "AF"), "Number=A value split per allele")
+	assert.Equal(t, "Y", a.GetExtra("artefacts", "PANEL"))
+	assert.Equal(t, "art1", a.GetExtra("artefacts", "ID"))
+	assert.Equal(t, "PASS", a.GetExtra("artefacts", "FILTER"))
+
+	// Deletion matched in normalized form.
+	a = annotateOne(s, &vcf.Variant{Chrom: "chr1", Pos: 66926, Ref: "AG", Alt: "A"}, &annotate.Annotation{})
+	assert.Equal(t, "indel_artifact", a.GetExtra("artefacts", "REASON"))
+
+	a = annotateOne(s, &vcf.Variant{Chrom: "12", Pos: 25245350, Ref: "C", Alt: "G"}, &annotate.Annotation{})
+	assert.Empty(t, a.Extra)
+}
+
+func TestBEDOverlap(t *testing.T) {
+	bed := "track name=blacklist\nchr12\t25245340\t25245350\tKRAS_region\t5\n12\t25245349\t25245360\tsecond\t7\n"
+	s := openSource(t, Config{Name: "blacklist", Fields: []string{"name", "score"}}, "blacklist.bed", bed)
+
+	// 1-based 25245350 is the last base of [25245340, 25245350) and the
+	// first of [25245349, 25245360).
+	a := annotateOne(s, &vcf.Variant{Chrom: "12", Pos: 25245350, Ref: "C", Alt: "A"}, &annotate.Annotation{})
+	assert.Equal(t, "KRAS_region,second", a.GetExtra("blacklist", "name"))
+	assert.Equal(t, "5,7", a.GetExtra("blacklist", "score"))
+
+	a = annotateOne(s, &vcf.Variant{Chrom: "12", Pos: 25245340, Ref: "C", Alt: "A"}, &annotate.Annotation{})
+	assert.Empty(t, a.Extra, "BED start is 0-based")
+
+	// A deletion spanning into the interval overlaps it.
+	a = annotateOne(s, &vcf.Variant{Chrom: "12", Pos: 25245338, Ref: "CTGA", Alt: "C"}, &annotate.Annotation{})
+	assert.Equal(t, "KRAS_region", a.GetExtra("blacklist", "name"))
+}
+
+func TestTSVProteinPosition(t *testing.T) {
+	tsv := "Hugo_Symbol\ttranscript_id\taa_pos\tcount\n" +
+		"KRAS\tENST00000311936.8\t12\t2175\n" +
+		"BRAF\t\t600\t897\n"
+	s := openSource(t, Config{
+		Name:    "inhouse",
+		Match:   MatchProteinPosition,
+		Fields:  []string{"count"},
+		Columns: map[string]string{"gene": "hugo_symbol", "transcript": "transcript_id", "protein_position": "aa_pos"},
+	}, "inhouse.tsv", tsv)
+	assert.Equal(t, annotate.MatchProteinPosition, s.MatchLevel())
+
+	v := &vcf.Variant{Chrom: "12", Pos: 25245350, Ref: "C", Alt: "A"}
+	kras := &annotate.Annotation{GeneName: "KRAS", TranscriptID: "ENST00000311936", ProteinPosition: 12}
+	other := &annotate.Annotation{GeneName: "KRAS", TranscriptID: "ENST00000256078", ProteinPosition: 12}
+	s.Annotate(v, []*annotate.Annotation{kras, other})
+	assert.Equal(t, "2175", kras.GetExtra("inhouse", "count"))
+	assert.Empty(t, other.Extra, "other transcript")
+
+	braf := annotateOne(s, v, &annotate.Annotation{GeneName: "BRAF", TranscriptID: "ENST00000646891", ProteinPosition: 600})
+	assert.Equal(t, "897", braf.GetExtra("inhouse", "count"), "gene-keyed row")
+}
+
+func TestTSVGeneAndExact(t *testing.T) {
+	genes := "#gene\tlist\nTP53\tgermline_blacklist\n"
+	s := openSource(t, Config{Name: "genes", Match: MatchGene, Fields: []string{"list"}}, "genes.tsv", genes)
+	a := annotateOne(s, &vcf.Variant{}, &annotate.Annotation{GeneName: "TP53"})
+	assert.Equal(t, "germline_blacklist", a.GetExtra("genes", "list"))
+
+	sites := "chrom\tpos\tref\talt\tnote\n12\t25245350\tC\tA\thot\n1\t66927\tG\t-\tdel\n"
+	s = openSource(t, Config{Name: "sites", Fields: []string{"note"}}, "sites.tsv", sites)
+	a = annotateOne(s, &vcf.Variant{Chrom: "1", Pos: 66926, Ref: "AG", Alt: "A"}, &annotate.Annotation{})
+	assert.Equal(t, "del", a.GetExtra("sites", "note"))
+}
+
+func TestReady_ConfigChange(t *testing.T) {
+	s := openSource(t, Config{Name: "artefacts", Fields: []string{"REASON"}}, "artefacts.vcf", testVCF)
+	cfg := s.Store().Config()
+	dbPath := filepath.Join(filepath.Dir(cfg.File), "artefacts.sqlite")
+
+	cfg.Fields = []string{"REASON", "AF"}
+	assert.False(t, Ready(dbPath, cfg))
+}
+
+func TestConfigValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     Config
+		wantErr bool
+	}{
+		{"vcf default exact", Config{Name: "a", File: "x.vcf.gz", Fields: []string{"AF"}}, false},
+		{"bed overlap", Config{Name: "a", File: "x.bed", Fields: []string{"name"}}, false},
+		{"bed exact", Config{Name: "a", File: "x.bed", Match: MatchExact, Fields: []string{"name"}}, true},
+		{"vcf gene", Config{Name: "a", File: "x.vcf", Match: MatchGene, Fields: []string{"AF"}}, true},
+		{"bad name", Config{Name: "A-b", File: "x.tsv", Fields: []string{"f"}}, true},
+		{"bad field", Config{Name: "a", File: "x.tsv", Fields: []string{"f|g"}}, true},
+		{"no fields", Config{Name: "a", File: "x.tsv"}, true},
+		{"no file", Config{Name: "a", Fields: []string{"f"}}, true},
+		{"unknown format", Config{Name: "a", File: "x", Format: "xlsx", Fields: []string{"f"}}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.cfg.Validate()
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
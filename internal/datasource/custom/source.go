@@ -0,0 +1,91 @@
+package custom
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/inodb/vibe-vep/internal/annotate"
+	"github.com/inodb/vibe-vep/internal/genomicindex"
+	"github.com/inodb/vibe-vep/internal/vcf"
+)
+
+// Source implements annotate.AnnotationSource for a custom source. Values
+// of several matching entries are joined with ",".
+type Source struct {
+	store *Store
+	keys  []string // pre-built Extra keys, one per field
+}
+
+// NewSource creates an AnnotationSource backed by the given Store.
+func NewSource(store *Store) *Source {
+	cfg := store.Config()
+	keys := make([]string, len(cfg.Fields))
+	for i, f := range cfg.Fields {
+		keys[i] = cfg.Name + "." + f
+	}
+	return &Source{store: store, keys: keys}
+}
+
+func (s *Source) Name() string                    { return s.store.cfg.Name }
+func (s *Source) Store() *Store                   { return s.store }
+func (s *Source) Fingerprint() string             { return s.store.Fingerprint() }
+func (s *Source) Version() string                 { return s.store.cfg.SourceVersion() }
+func (s *Source) MatchLevel() annotate.MatchLevel { return s.store.cfg.MatchLevel() }
+func (s *Source) Columns() []annotate.ColumnDef   { return s.store.cfg.ColumnDefs() }
+
+// Annotate sets the fields of matching entries. Genomic matches apply to
+// every annotation; protein position matches use the annotation's transcript
+// (versionless) or, for files keyed by gene, its gene symbol.
+func (s *Source) Annotate(v *vcf.Variant, anns []*annotate.Annotation) {
+	switch s.store.cfg.Match {
+	case MatchExact:
+		pos, ref, alt := genomicindex.NormalizeAlleles(v.Pos, v.Ref, v.Alt)
+		s.set(anns, s.store.LookupExact(v.NormalizeChrom(), pos, ref, alt))
+	case MatchOverlap:
+		end := v.Pos + int64(len(v.Ref)) - 1
+		if end < v.Pos {
+			end = v.Pos
+		}
+		s.set(anns, s.store.LookupOverlap(v.NormalizeChrom(), v.Pos, end))
+	case MatchProteinPosition:
+		for _, ann := range anns {
+			if ann.ProteinPosition == 0 {
+				continue
+			}
+			rows := s.store.LookupProteinPosition(stripVersion(ann.TranscriptID), ann.ProteinPosition)
+			if len(rows) == 0 && ann.GeneName != "" {
+				rows = s.store.LookupProteinPosition(ann.GeneName, ann.ProteinPosition)
+			}
+			s.set([]*annotate.Annotation{ann}, rows)
+		}
+	case MatchGene:
+		for _, ann := range anns {
+			if ann.GeneName != "" {
+				s.set([]*annotate.Annotation{ann}, s.store.LookupGene(ann.GeneName))
+			}
+		}
+	}
+}
+
+// set joins the distinct non-empty values of each field over rows and sets
+// them on anns.
+func (s *Source) set(anns []*annotate.Annotation, rows [][]string) {
+	if len(rows) == 0 {
+		return
+	}
+	for i, key := range s.keys {
+		var vals []string
+		for _, row := range rows {
+			if row[i] != "" && !slices.Contains(vals, row[i]) {
+				vals = append(vals, row[i])
+			}
+		}
+		if len(vals) == 0 {
+			continue
+		}
+		val := strings.Join(vals, ",")
+		for _, ann := range anns {
+			ann.SetExtraKey(key, val)
+		}
+	}
+}
@@ -54,6 +54,27 @@ func ParseVCFLine(line string) (Entry, string, bool) {
 	return entry, chrom, true
 }
 
+// ParseVCFAlleles is like ParseVCFLine but returns one Entry per ALT allele
+// of a multi-allelic record.
+func ParseVCFAlleles(line string) ([]Entry, string, bool) {
+	first, chrom, ok := ParseVCFLine(line)
+	if !ok {
+		return nil, "", false
+	}
+	fields := strings.SplitN(line, "\t", 6)
+	alts := strings.Split(fields[4], ",")
+	entries := make([]Entry, 0, len(alts))
+	for _, alt := range alts {
+		if alt == "" || alt == "." {
+			continue
+		}
+		e := first
+		e.Alt = alt
+		entries = append(entries, e)
+	}
+	return entries, chrom, len(entries) > 0
+}
+
 // NormalizeChrom removes "chr" prefix and converts NCBI RefSeq accessions
 // (e.g., "NC_000001.11") to plain chromosome numbers.
 func NormalizeChrom(chrom string) string {
@@ -92,3 +92,25 @@ func TestNormalizeChrom(t *testing.T) {
 		})
 	}
 }
+
+func TestParseVCFAlleles(t *testing.T) {
+	entries, chrom, ok := ParseVCFAlleles("NC_000012.12\t25245350\trs121913529\tC\tA,G,T\t.\t.\tRS=121913529")
+	if !ok {
+		t.Fatal("expected ok")
+	}
+	if chrom != "12" {
+		t.Errorf("chrom=%q, want 12", chrom)
+	}
+	if len(entries) != 3 {
+		t.Fatalf("got %d entries, want 3", len(entries))
+	}
+	for i, alt := range []string{"A", "G", "T"} {
+		if entries[i].Alt != alt || entries[i].Ref != "C" || entries[i].Pos != 25245350 || entries[i].ID != "rs121913529" {
+			t.Errorf("entry %d = %+v, want C>%s at 25245350", i, entries[i], alt)
+		}
+	}
+
+	if _, _, ok := ParseVCFAlleles("1\t10177\t.\tA\tAC\t.\t.\t."); ok {
+		t.Error("expected no entries without an RS ID")
+	}
+}
@@ -63,6 +63,8 @@ type Store struct {
 	cache      map[cacheKey][]byte // decompressed matrix (LRU)
 	compressed map[cacheKey][]byte // preloaded compressed data (nil if not preloaded)
 	preloaded  bool                // true if compressed data is in memory
+
+	fingerprint string // index file size and modification time at Open
 }
 
 type cacheKey struct {
@@ -87,9 +89,17 @@ func Open(dbPath string) (*Store, error) {
 		db:       db,
 		lookupPS: ps,
 		cache:    make(map[cacheKey][]byte, 128),
+
+		fingerprint: annotate.FileFingerprint(dbPath),
 	}, nil
 }
 
+// Fingerprint identifies the index build: the database file's size and
+// modification time when it was opened.
+func (s *Store) Fingerprint() string {
+	return s.fingerprint
+}
+
 // Preload reads all prediction matrices from SQLite into memory so that
 // subsequent lookups don't hit the database. This trades ~200-500MB RAM
 // for instant lookups, which is critical on networked filesystems like EFS
@@ -541,6 +551,7 @@ func NewSource(store *Store) *Source {
 
 func (s *Source) Name() string                   { return "ensembl_predictions" }
 func (s *Source) Version() string                 { return "115" }
+func (s *Source) Fingerprint() string             { return s.store.Fingerprint() }
 func (s *Source) MatchLevel() annotate.MatchLevel { return annotate.MatchProteinPosition }
 func (s *Source) Store() *Store                   { return s.store }
 
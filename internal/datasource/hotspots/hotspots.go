@@ -10,6 +10,8 @@ import (
 	"sort"
 	"strconv"
 	"strings"
+
+	"github.com/inodb/vibe-vep/internal/annotate"
 )
 
 // Hotspot represents a single hotspot entry at a protein position.
@@ -25,6 +27,8 @@ type Hotspot struct {
 // so lookups require matching the transcript, not just the gene.
 type Store struct {
 	data map[string][]Hotspot // transcript ID → sorted hotspots
+
+	fingerprint string // file size and modification time at Load
 }
 
 // Load parses a hotspots TSV file (hotspots_v2_and_3d.txt format).
@@ -94,7 +98,7 @@ func Load(path string) (*Store, error) {
 		data[gene] = dedup(spots)
 	}
 
-	return &Store{data: data}, nil
+	return &Store{data: data, fingerprint: annotate.FileFingerprint(path)}, nil
 }
 
 // Lookup checks if a transcript+position is a known hotspot.
@@ -129,6 +133,11 @@ func (s *Store) HotspotCount() int {
 	return n
 }
 
+// Fingerprint identifies the loaded file: its size and modification time.
+func (s *Store) Fingerprint() string {
+	return s.fingerprint
+}
+
 // typePriority returns a priority for hotspot types (lower = preferred).
 // "single residue" is direct evidence, preferred over structural prediction ("3d").
 func typePriority(t string) int {
@@ -1,23 +1,44 @@
 package oncokb
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"slices"
+
 	"github.com/inodb/vibe-vep/internal/annotate"
 	"github.com/inodb/vibe-vep/internal/vcf"
 )
 
 // Source wraps a CancerGeneList as an annotate.AnnotationSource.
 type Source struct {
-	cgl CancerGeneList
+	cgl         CancerGeneList
+	fingerprint string
 }
 
 // NewSource creates an AnnotationSource backed by the given CancerGeneList.
 func NewSource(cgl CancerGeneList) *Source {
-	return &Source{cgl: cgl}
+	return &Source{cgl: cgl, fingerprint: cgl.fingerprint()}
+}
+
+// fingerprint returns a digest of the genes and their types.
+func (c CancerGeneList) fingerprint() string {
+	genes := make([]string, 0, len(c))
+	for gene := range c {
+		genes = append(genes, gene)
+	}
+	slices.Sort(genes)
+	h := sha256.New()
+	for _, gene := range genes {
+		fmt.Fprintf(h, "%s\t%s\n", gene, c[gene].GeneType)
+	}
+	return hex.EncodeToString(h.Sum(nil))
 }
 
 func (s *Source) Name() string                   { return "oncokb" }
 func (s *Source) Version() string                 { return "cancerGeneList.tsv" }
 func (s *Source) MatchLevel() annotate.MatchLevel { return annotate.MatchGene }
+func (s *Source) Fingerprint() string             { return s.fingerprint }
 
 func (s *Source) Columns() []annotate.ColumnDef {
 	return []annotate.ColumnDef{
@@ -0,0 +1,78 @@
+package duckdb
+
+import (
+	"fmt"
+	"strings"
+)
+
+// resultLookupChunk is the maximum number of keys per LookupResults query.
+const resultLookupChunk = 500
+
+// LookupResults returns the serialized annotation results cached under the
+// given keys for dataVersion. Keys without a result are absent from the map.
+func (s *Store) LookupResults(keys []string, dataVersion string) (map[string][]byte, error) {
+	found := make(map[string][]byte)
+	for start := 0; start < len(keys); start += resultLookupChunk {
+		chunk := keys[start:min(start+resultLookupChunk, len(keys))]
+		args := make([]any, 0, len(chunk)+1)
+		args = append(args, dataVersion)
+		for _, k := range chunk {
+			args = append(args, k)
+		}
+		rows, err := s.db.Query(`SELECT key, data FROM annotation_results
+			WHERE data_version=? AND key IN (?`+strings.Repeat(",?", len(chunk)-1)+`)`, args...)
+		if err != nil {
+			return nil, fmt.Errorf("query results: %w", err)
+		}
+		for rows.Next() {
+			var key string
+			var data []byte
+			if err := rows.Scan(&key, &data); err != nil {
+				rows.Close()
+				return nil, fmt.Errorf("scan result: %w", err)
+			}
+			found[key] = data
+		}
+		err = rows.Err()
+		rows.Close()
+		if err != nil {
+			return nil, fmt.Errorf("iterate results: %w", err)
+		}
+	}
+	return found, nil
+}
+
+// WriteResults caches serialized annotation results under dataVersion,
+// replacing any earlier result with the same key.
+func (s *Store) WriteResults(dataVersion string, results map[string][]byte) error {
+	if len(results) == 0 {
+		return nil
+	}
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("begin: %w", err)
+	}
+	stmt, err := tx.Prepare(`INSERT OR REPLACE INTO annotation_results (key, data_version, data) VALUES (?, ?, ?)`)
+	if err != nil {
+		tx.Rollback()
+		return fmt.Errorf("prepare insert: %w", err)
+	}
+	defer stmt.Close()
+	for key, data := range results {
+		if _, err := stmt.Exec(key, dataVersion, data); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("insert result: %w", err)
+		}
+	}
+	return tx.Commit()
+}
+
+// DeleteStaleResults removes the cached results written for any data
+// version other than dataVersion and returns how many were removed.
+func (s *Store) DeleteStaleResults(dataVersion string) (int64, error) {
+	res, err := s.db.Exec("DELETE FROM annotation_results WHERE data_version <> ?", dataVersion)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
@@ -96,5 +96,14 @@ func (s *Store) ensureSchema() error {
 		return err
 	}
 
+	_, err = s.db.Exec(`CREATE TABLE IF NOT EXISTS annotation_results (
+		key VARCHAR PRIMARY KEY,
+		data_version VARCHAR,
+		data BLOB
+	)`)
+	if err != nil {
+		return err
+	}
+
 	return nil
 }
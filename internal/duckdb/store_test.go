@@ -1,6 +1,7 @@
 package duckdb
 
 import (
+	"path/filepath"
 	"testing"
 	"time"
 
@@ -104,6 +105,33 @@ func TestClearVariantResults(t *testing.T) {
 	assert.Empty(t, anns)
 }
 
+func TestWriteAndLookupResults(t *testing.T) {
+	s := openInMemory(t)
+
+	require.NoError(t, s.WriteResults("v1", map[string][]byte{
+		"GRCh38|12|25245350|C|A": []byte(`{"anns":1}`),
+		"GRCh38|7|140753336|A|T": []byte(`{"anns":2}`),
+	}))
+	// Rewriting a key replaces it.
+	require.NoError(t, s.WriteResults("v1", map[string][]byte{"GRCh38|7|140753336|A|T": []byte(`{"anns":3}`)}))
+
+	got, err := s.LookupResults([]string{"GRCh38|12|25245350|C|A", "GRCh38|7|140753336|A|T", "GRCh38|1|1|A|G"}, "v1")
+	require.NoError(t, err)
+	assert.Equal(t, map[string][]byte{
+		"GRCh38|12|25245350|C|A": []byte(`{"anns":1}`),
+		"GRCh38|7|140753336|A|T": []byte(`{"anns":3}`),
+	}, got)
+
+	// Results of another data version are not returned, and are pruned.
+	got, err = s.LookupResults([]string{"GRCh38|12|25245350|C|A"}, "v2")
+	require.NoError(t, err)
+	assert.Empty(t, got)
+
+	n, err := s.DeleteStaleResults("v2")
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), n)
+}
+
 func TestSearchByGene(t *testing.T) {
 	s := openInMemory(t)
 
@@ -265,6 +293,58 @@ func TestTranscriptCacheValidation(t *testing.T) {
 	assert.False(t, tc.Valid(gtf, fastaChanged, canonical))
 }
 
+func TestTranscriptCacheValidation_TranscriptSets(t *testing.T) {
+	dir := t.TempDir()
+	tc := NewTranscriptCache(dir)
+
+	now := time.Now()
+	fp := FileFingerprint{Size: 1000, ModTime: now}
+	oncokb := FileFingerprint{Size: 50, ModTime: now}
+	tc.SetTranscriptSets(map[string]FileFingerprint{"oncokb": oncokb})
+
+	c := cache.New()
+	c.AddTranscript(&cache.Transcript{
+		ID: "ENST00000001.1", Chrom: "1", Start: 100, End: 200, Strand: 1,
+	})
+	require.NoError(t, tc.Write(c, fp, fp, fp))
+	assert.True(t, tc.Valid(fp, fp, fp))
+
+	// Changed set file → stale
+	tc.SetTranscriptSets(map[string]FileFingerprint{"oncokb": {Size: 60, ModTime: now}})
+	assert.False(t, tc.Valid(fp, fp, fp))
+
+	// Added or removed set → stale
+	tc.SetTranscriptSets(map[string]FileFingerprint{"oncokb": oncokb, "report": oncokb})
+	assert.False(t, tc.Valid(fp, fp, fp))
+	tc.SetTranscriptSets(nil)
+	assert.False(t, tc.Valid(fp, fp, fp))
+}
+
+func TestTranscriptCacheValidation_RefSeq(t *testing.T) {
+	// Cache in a subdirectory that does not exist yet (e.g. grch38/refseq).
+	dir := filepath.Join(t.TempDir(), "refseq")
+	tc := NewTranscriptCache(dir)
+
+	now := time.Now()
+	fp := FileFingerprint{Size: 1000, ModTime: now}
+	gff := FileFingerprint{Size: 2000, ModTime: now}
+	genome := FileFingerprint{Size: 3000, ModTime: now}
+	tc.SetRefSeqFiles(gff, fp, genome)
+
+	c := cache.New()
+	c.AddTranscript(&cache.Transcript{
+		ID: "NM_000001.1", Chrom: "1", Start: 100, End: 200, Strand: 1,
+	})
+	require.NoError(t, tc.Write(c, fp, fp, fp))
+	assert.True(t, tc.Valid(fp, fp, fp))
+
+	// Changed or missing reference genome → stale
+	tc.SetRefSeqFiles(gff, fp, FileFingerprint{Size: 3001, ModTime: now})
+	assert.False(t, tc.Valid(fp, fp, fp))
+	tc.SetRefSeqFiles(gff, fp, FileFingerprint{})
+	assert.False(t, tc.Valid(fp, fp, fp))
+}
+
 func TestTranscriptCacheClear(t *testing.T) {
 	dir := t.TempDir()
 	tc := NewTranscriptCache(dir)
@@ -4,9 +4,11 @@ import (
 	"crypto/sha256"
 	"encoding/gob"
 	"fmt"
+	"maps"
 	"os"
 	"path/filepath"
 	"reflect"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
@@ -34,7 +36,9 @@ func transcriptSchemaHash() string {
 //	~/.vibe-vep/{assembly}/transcripts.gob       (serialized transcripts)
 //	~/.vibe-vep/{assembly}/transcripts.gob.meta  (source file fingerprints)
 type TranscriptCache struct {
-	dir string // cache directory (e.g. ~/.vibe-vep/grch38)
+	dir    string                     // cache directory (e.g. ~/.vibe-vep/grch38)
+	sets   map[string]FileFingerprint // transcript set name -> TSV fingerprint
+	refseq []FileFingerprint          // RefSeq GFF3, FASTA and reference genome, nil if unused
 }
 
 // NewTranscriptCache creates a transcript cache for the given directory.
@@ -50,6 +54,43 @@ func (tc *TranscriptCache) metaPath() string {
 	return filepath.Join(tc.dir, "transcripts.gob.meta")
 }
 
+// SetTranscriptSets records the files of the named transcript sets applied
+// to the cached transcripts, so that adding, removing or changing a set
+// invalidates the cache.
+func (tc *TranscriptCache) SetTranscriptSets(sets map[string]FileFingerprint) {
+	tc.sets = sets
+}
+
+// SetRefSeqFiles records the RefSeq GFF3, RefSeq transcript FASTA and the
+// reference genome used to check RefSeq sequences (zero if none), for
+// transcripts loaded with --transcript-set refseq or merged.
+func (tc *TranscriptCache) SetRefSeqFiles(gff, fasta, genome FileFingerprint) {
+	tc.refseq = []FileFingerprint{gff, fasta, genome}
+}
+
+// sourceMeta returns the metadata entries describing the transcript sets and
+// RefSeq files.
+func (tc *TranscriptCache) sourceMeta() []string {
+	names := slices.Sorted(maps.Keys(tc.sets))
+	lines := []string{"transcript_sets=" + strings.Join(names, ",")}
+	for _, name := range names {
+		fp := tc.sets[name]
+		lines = append(lines,
+			"transcript_set."+name+"_size="+strconv.FormatInt(fp.Size, 10),
+			"transcript_set."+name+"_modtime="+fp.ModTime.UTC().Format(time.RFC3339Nano))
+	}
+	for i, name := range []string{"refseq_gff", "refseq_fasta", "refseq_genome"} {
+		var fp FileFingerprint
+		if tc.refseq != nil {
+			fp = tc.refseq[i]
+		}
+		lines = append(lines,
+			name+"_size="+strconv.FormatInt(fp.Size, 10),
+			name+"_modtime="+fp.ModTime.UTC().Format(time.RFC3339Nano))
+	}
+	return lines
+}
+
 // Valid checks whether the cached transcripts match the current source files.
 func (tc *TranscriptCache) Valid(gtf, fasta, canonical FileFingerprint) bool {
 	meta, err := tc.readMeta()
@@ -66,6 +107,10 @@ func (tc *TranscriptCache) Valid(gtf, fasta, canonical FileFingerprint) bool {
 		{"canonical_modtime", canonical.ModTime.UTC().Format(time.RFC3339Nano)},
 		{"schema_hash", transcriptSchemaHash()},
 	}
+	for _, line := range tc.sourceMeta() {
+		k, v, _ := strings.Cut(line, "=")
+		checks = append(checks, struct{ key, val string }{k, v})
+	}
 
 	for _, c := range checks {
 		if meta[c.key] != c.val {
@@ -109,6 +154,9 @@ func (tc *TranscriptCache) Write(c *cache.Cache, gtf, fasta, canonical FileFinge
 		data[chrom] = c.FindTranscriptsByChrom(chrom)
 	}
 
+	if err := os.MkdirAll(tc.dir, 0755); err != nil {
+		return fmt.Errorf("create transcript cache: %w", err)
+	}
 	f, err := os.Create(tc.gobPath())
 	if err != nil {
 		return fmt.Errorf("create transcript cache: %w", err)
@@ -142,9 +190,9 @@ func (tc *TranscriptCache) writeMeta(gtf, fasta, canonical FileFingerprint) erro
 		"canonical_size=" + strconv.FormatInt(canonical.Size, 10),
 		"canonical_modtime=" + canonical.ModTime.UTC().Format(time.RFC3339Nano),
 		"schema_hash=" + transcriptSchemaHash(),
-		"created_at=" + time.Now().UTC().Format(time.RFC3339),
-		"",
 	}
+	lines = append(lines, tc.sourceMeta()...)
+	lines = append(lines, "created_at="+time.Now().UTC().Format(time.RFC3339), "")
 	return os.WriteFile(tc.metaPath(), []byte(strings.Join(lines, "\n")), 0644)
 }
 
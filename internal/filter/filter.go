@@ -0,0 +1,424 @@
+// Package filter implements the expression language used by `vibe-vep
+// filter` and `annotate --filter` to select annotated records, modelled on
+// VEP's filter_vep:
+//
+//	IMPACT in HIGH,MODERATE and gnomad_af < 0.001 and not clinvar_clnsig match Benign
+//
+// An expression combines comparisons with and, or, not and parentheses. A
+// comparison is a field, an operator and a value; a field on its own tests
+// that the field is present and non-empty. Operators:
+//
+//	is, =, eq       equal (either as strings or as numbers)
+//	!=, ne          not equal
+//	<, lt, <=, lte  numeric less than (or equal)
+//	>, gt, >=, gte  numeric greater than (or equal)
+//	in              equal to one of a comma-separated list
+//	match, re       contains a match of a regular expression
+//
+// Field names are matched case-insensitively, ignoring a "vibe." prefix and
+// treating '.' and '_' alike, so gnomad_af finds the MAF column
+// vibe.gnomad.af and the CSQ field gnomad.af. Values holding several
+// '&'- or ','-separated entries (e.g. Consequence) satisfy is, in and the
+// numeric operators if any entry does. Comparisons on a missing field are
+// false, so "not clinvar_clnsig match Benign" keeps records without ClinVar
+// data. Values containing spaces, parentheses or any of <>=! must be quoted
+// with ' or ".
+package filter
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Record gives access to the fields of one record (a CSQ entry, MAF row or
+// JSON transcript consequence). Get returns false for missing or empty
+// fields. Field names are normalized with NormalizeField.
+type Record interface {
+	Get(field string) (string, bool)
+}
+
+// NormalizeField returns the lookup form of a field name: lower case,
+// without a "vibe." prefix and with '.' and '-' replaced by '_'.
+func NormalizeField(name string) string {
+	name = strings.ToLower(name)
+	name = strings.TrimPrefix(name, "vibe.")
+	return strings.NewReplacer(".", "_", "-", "_").Replace(name)
+}
+
+// Expr is a parsed filter expression.
+type Expr struct {
+	src  string
+	root node
+}
+
+// String returns the expression as given to Parse.
+func (e *Expr) String() string { return e.src }
+
+// Match reports whether rec satisfies the expression. A nil Expr matches
+// every record.
+func (e *Expr) Match(rec Record) bool {
+	if e == nil {
+		return true
+	}
+	return e.root.eval(rec)
+}
+
+// Parse parses a filter expression. Several expressions (e.g. repeated
+// --filter flags) are combined with and.
+func Parse(exprs ...string) (*Expr, error) {
+	var roots []node
+	var srcs []string
+	for _, s := range exprs {
+		if strings.TrimSpace(s) == "" {
+			continue
+		}
+		p := &parser{tokens: tokenize(s)}
+		n, err := p.parseOr()
+		if err != nil {
+			return nil, fmt.Errorf("filter %q: %w", s, err)
+		}
+		if p.pos < len(p.tokens) {
+			return nil, fmt.Errorf("filter %q: unexpected %q", s, p.tokens[p.pos])
+		}
+		roots = append(roots, n)
+		srcs = append(srcs, s)
+	}
+	switch len(roots) {
+	case 0:
+		return nil, nil
+	case 1:
+		return &Expr{src: srcs[0], root: roots[0]}, nil
+	}
+	return &Expr{src: strings.Join(srcs, " and "), root: andNode(roots)}, nil
+}
+
+// node is an expression tree node.
+type node interface {
+	eval(rec Record) bool
+}
+
+type andNode []node
+
+func (n andNode) eval(rec Record) bool {
+	for _, c := range n {
+		if !c.eval(rec) {
+			return false
+		}
+	}
+	return true
+}
+
+type orNode []node
+
+func (n orNode) eval(rec Record) bool {
+	for _, c := range n {
+		if c.eval(rec) {
+			return true
+		}
+	}
+	return false
+}
+
+type notNode struct{ child node }
+
+func (n notNode) eval(rec Record) bool { return !n.child.eval(rec) }
+
+// existsNode tests that a field is present.
+type existsNode struct{ field string }
+
+func (n existsNode) eval(rec Record) bool {
+	_, ok := rec.Get(n.field)
+	return ok
+}
+
+// op is a comparison operator.
+type op int
+
+const (
+	opEq op = iota
+	opNe
+	opLt
+	opLte
+	opGt
+	opGte
+	opIn
+	opMatch
+)
+
+var ops = map[string]op{
+	"is": opEq, "=": opEq, "==": opEq, "eq": opEq,
+	"!=": opNe, "ne": opNe,
+	"<": opLt, "lt": opLt,
+	"<=": opLte, "lte": opLte,
+	">": opGt, "gt": opGt,
+	">=": opGte, "gte": opGte,
+	"in":    opIn,
+	"match": opMatch, "matches": opMatch, "re": opMatch, "regex": opMatch,
+}
+
+// cmpNode compares a field with a value.
+type cmpNode struct {
+	field  string
+	op     op
+	value  string
+	num    float64 // value as a number (numeric operators)
+	isNum  bool
+	values []string // opIn
+	re     *regexp.Regexp
+}
+
+func (n *cmpNode) eval(rec Record) bool {
+	v, ok := rec.Get(n.field)
+	if !ok {
+		return false
+	}
+	switch n.op {
+	case opMatch:
+		return n.re.MatchString(v)
+	case opNe:
+		return !n.equal(v)
+	case opEq:
+		return n.equal(v)
+	}
+	for _, part := range splitValues(v) {
+		switch n.op {
+		case opIn:
+			for _, want := range n.values {
+				if part == want {
+					return true
+				}
+			}
+		default:
+			f, err := strconv.ParseFloat(part, 64)
+			if err != nil {
+				continue
+			}
+			if (n.op == opLt && f < n.num) || (n.op == opLte && f <= n.num) ||
+				(n.op == opGt && f > n.num) || (n.op == opGte && f >= n.num) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// equal reports whether any entry of v equals the value, as a string or,
+// when both are numbers, numerically.
+func (n *cmpNode) equal(v string) bool {
+	for _, part := range splitValues(v) {
+		if part == n.value {
+			return true
+		}
+		if n.isNum {
+			if f, err := strconv.ParseFloat(part, 64); err == nil && f == n.num {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// splitValues splits a multi-valued field on '&' and ','.
+func splitValues(v string) []string {
+	return strings.FieldsFunc(v, func(r rune) bool { return r == '&' || r == ',' })
+}
+
+// parser is a recursive-descent parser over tokens.
+type parser struct {
+	tokens []string
+	pos    int
+}
+
+func (p *parser) peek() string {
+	if p.pos < len(p.tokens) {
+		return p.tokens[p.pos]
+	}
+	return ""
+}
+
+func (p *parser) next() string {
+	t := p.peek()
+	p.pos++
+	return t
+}
+
+func (p *parser) parseOr() (node, error) {
+	n, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	nodes := []node{n}
+	for strings.EqualFold(p.peek(), "or") {
+		p.next()
+		n, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		nodes = append(nodes, n)
+	}
+	if len(nodes) == 1 {
+		return nodes[0], nil
+	}
+	return orNode(nodes), nil
+}
+
+func (p *parser) parseAnd() (node, error) {
+	n, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	nodes := []node{n}
+	for strings.EqualFold(p.peek(), "and") {
+		p.next()
+		n, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		nodes = append(nodes, n)
+	}
+	if len(nodes) == 1 {
+		return nodes[0], nil
+	}
+	return andNode(nodes), nil
+}
+
+func (p *parser) parseNot() (node, error) {
+	if strings.EqualFold(p.peek(), "not") {
+		p.next()
+		n, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return notNode{n}, nil
+	}
+	return p.parsePrimary()
+}
+
+func (p *parser) parsePrimary() (node, error) {
+	t := p.next()
+	switch {
+	case t == "":
+		return nil, fmt.Errorf("unexpected end of expression")
+	case t == "(":
+		n, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if p.next() != ")" {
+			return nil, fmt.Errorf("missing )")
+		}
+		return n, nil
+	case t == ")" || isKeyword(t):
+		return nil, fmt.Errorf("unexpected %q", t)
+	}
+	field := NormalizeField(unquote(t))
+
+	o, ok := ops[strings.ToLower(p.peek())]
+	if !ok {
+		return existsNode{field}, nil
+	}
+	opTok := p.next()
+	value, err := p.parseValue(o)
+	if err != nil {
+		return nil, fmt.Errorf("%s %s: %w", t, opTok, err)
+	}
+
+	n := &cmpNode{field: field, op: o, value: value}
+	if f, err := strconv.ParseFloat(value, 64); err == nil {
+		n.num, n.isNum = f, true
+	}
+	switch o {
+	case opLt, opLte, opGt, opGte:
+		if !n.isNum {
+			return nil, fmt.Errorf("%s %s: %q is not a number", t, opTok, value)
+		}
+	case opIn:
+		for _, v := range strings.Split(value, ",") {
+			if v = strings.TrimSpace(v); v != "" {
+				n.values = append(n.values, v)
+			}
+		}
+	case opMatch:
+		if n.re, err = regexp.Compile(value); err != nil {
+			return nil, fmt.Errorf("%s %s: %w", t, opTok, err)
+		}
+	}
+	return n, nil
+}
+
+// parseValue reads a comparison value. A list for in may be split over
+// several tokens around its commas ("HIGH, MODERATE").
+func (p *parser) parseValue(o op) (string, error) {
+	t := p.next()
+	if t == "" || t == "(" || t == ")" {
+		return "", fmt.Errorf("missing value")
+	}
+	value := unquote(t)
+	if o == opIn {
+		for strings.HasSuffix(value, ",") || strings.HasPrefix(p.peek(), ",") {
+			next := p.peek()
+			if next == "" || next == ")" || next == "(" || isKeyword(next) {
+				break
+			}
+			value += unquote(p.next())
+		}
+	}
+	return value, nil
+}
+
+func isKeyword(t string) bool {
+	switch strings.ToLower(t) {
+	case "and", "or", "not":
+		return true
+	}
+	return false
+}
+
+// unquote strips matching single or double quotes.
+func unquote(t string) string {
+	if len(t) >= 2 && (t[0] == '"' || t[0] == '\'') && t[len(t)-1] == t[0] {
+		return t[1 : len(t)-1]
+	}
+	return t
+}
+
+// tokenize splits an expression into words, quoted strings, parentheses and
+// comparison symbols.
+func tokenize(s string) []string {
+	var tokens []string
+	for i := 0; i < len(s); {
+		c := s[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n':
+			i++
+		case c == '(' || c == ')':
+			tokens = append(tokens, string(c))
+			i++
+		case c == '"' || c == '\'':
+			j := strings.IndexByte(s[i+1:], c)
+			if j < 0 {
+				tokens = append(tokens, s[i:])
+				return tokens
+			}
+			tokens = append(tokens, s[i:i+j+2])
+			i += j + 2
+		case strings.ContainsRune("<>=!", rune(c)):
+			j := i + 1
+			if j < len(s) && s[j] == '=' {
+				j++
+			}
+			tokens = append(tokens, s[i:j])
+			i = j
+		default:
+			j := i
+			for j < len(s) && !strings.ContainsRune(" \t\n()<>=!", rune(s[j])) {
+				j++
+			}
+			tokens = append(tokens, s[i:j])
+			i = j
+		}
+	}
+	return tokens
+}
@@ -0,0 +1,103 @@
+package filter
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseAndMatch(t *testing.T) {
+	rec := Map{}
+	rec.Set("IMPACT", "MODERATE")
+	rec.Set("Consequence", "missense_variant&splice_region_variant")
+	rec.Set("vibe.gnomad.af", "0.0002")
+	rec.Set("clinvar.clnsig", "Likely_benign")
+	rec.Set("SYMBOL", "KRAS")
+	rec.Set("Protein_position", "12")
+	rec.Set("HGVSp", "p.Gly12Cys")
+	rec.Set("EXON", "")
+
+	tests := []struct {
+		expr string
+		want bool
+	}{
+		{"IMPACT in HIGH,MODERATE", true},
+		{"IMPACT in HIGH, MODERATE", true},
+		{"IMPACT in HIGH", false},
+		{"impact is MODERATE", true},
+		{"IMPACT = moderate", false},
+		{"IMPACT != HIGH", true},
+		{"Consequence is splice_region_variant", true},
+		{"Consequence match ^missense", true},
+		{"gnomad_af < 0.001", true},
+		{"gnomad.af >= 0.001", false},
+		{"gnomad_af = 2e-4", true},
+		{"Protein_position > 10 and Protein_position lte 12", true},
+		{"clinvar_clnsig match Benign", false},
+		{"clinvar_clnsig match '(?i)benign'", true},
+		{"not clinvar_clnsig match Pathogenic", true},
+		{"not missing_field match Benign", true},
+		{"missing_field < 1", false},
+		{"missing_field", false},
+		{"SYMBOL", true},
+		{"EXON", false},
+		{"IMPACT in HIGH,MODERATE and gnomad_af < 0.001 and not clinvar_clnsig match Benign", true},
+		{"IMPACT is HIGH or SYMBOL is KRAS", true},
+		{"IMPACT is HIGH or (SYMBOL is KRAS and gnomad_af > 0.01)", false},
+		{"not (IMPACT is HIGH or SYMBOL is BRAF)", true},
+		{`HGVSp is "p.Gly12Cys"`, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			e, err := Parse(tt.expr)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, e.Match(rec))
+		})
+	}
+}
+
+func TestParse_Multiple(t *testing.T) {
+	e, err := Parse("IMPACT is HIGH", "", "SYMBOL is TP53")
+	require.NoError(t, err)
+	assert.Equal(t, "IMPACT is HIGH and SYMBOL is TP53", e.String())
+	assert.True(t, e.Match(Map{"impact": "HIGH", "symbol": "TP53"}))
+	assert.False(t, e.Match(Map{"impact": "HIGH", "symbol": "KRAS"}))
+
+	e, err = Parse("")
+	require.NoError(t, err)
+	assert.Nil(t, e)
+	assert.True(t, e.Match(Map{}), "nil expression matches everything")
+}
+
+func TestParse_Errors(t *testing.T) {
+	for _, expr := range []string{
+		"IMPACT is",
+		"(IMPACT is HIGH",
+		"IMPACT is HIGH)",
+		"gnomad_af < abc",
+		"SYMBOL match (",
+		"and IMPACT is HIGH",
+		"IMPACT is HIGH or",
+	} {
+		t.Run(expr, func(t *testing.T) {
+			_, err := Parse(expr)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestColumnsAndChain(t *testing.T) {
+	cols := NewColumns([]string{"Hugo_Symbol", "vibe.consequence", "vibe.gnomad.af"})
+	r := cols.Row([]string{"KRAS", "missense_variant", "."})
+	v, ok := r.Get("hugo_symbol")
+	assert.True(t, ok)
+	assert.Equal(t, "KRAS", v)
+	_, ok = r.Get("gnomad_af")
+	assert.False(t, ok, "'.' is missing")
+
+	vcfRec := VCFRecord("12", 25245350, "rs121913529", "C", "A", ".", "PASS", "DP=10;SOMATIC;CSQ=x")
+	e, err := Parse("consequence is missense_variant and FILTER is PASS and DP > 5 and SOMATIC and not CSQ")
+	require.NoError(t, err)
+	assert.True(t, e.Match(Chain{r, vcfRec}))
+}
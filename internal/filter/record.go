@@ -0,0 +1,104 @@
+package filter
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Columns maps the column names of a tabular format (MAF header, CSQ
+// Format) to their positions, for building Row records.
+type Columns struct {
+	index map[string]int
+}
+
+// NewColumns indexes column names. The first of several columns with the
+// same normalized name wins.
+func NewColumns(names []string) *Columns {
+	c := &Columns{index: make(map[string]int, len(names))}
+	for i, name := range names {
+		key := NormalizeField(name)
+		if _, ok := c.index[key]; !ok {
+			c.index[key] = i
+		}
+	}
+	return c
+}
+
+// Row returns a Record over the values of one row.
+func (c *Columns) Row(values []string) Record {
+	return row{cols: c, values: values}
+}
+
+type row struct {
+	cols   *Columns
+	values []string
+}
+
+func (r row) Get(field string) (string, bool) {
+	i, ok := r.cols.index[field]
+	if !ok || i >= len(r.values) {
+		return "", false
+	}
+	v := r.values[i]
+	return v, v != "" && v != "."
+}
+
+// Map is a Record backed by a map from normalized field names to values.
+type Map map[string]string
+
+// Set sets a field, normalizing its name.
+func (m Map) Set(field, value string) {
+	m[NormalizeField(field)] = value
+}
+
+func (m Map) Get(field string) (string, bool) {
+	v, ok := m[field]
+	return v, ok && v != "" && v != "."
+}
+
+// Chain is a Record that looks a field up in each record in turn, e.g. a
+// CSQ entry and then its VCF record.
+type Chain []Record
+
+func (c Chain) Get(field string) (string, bool) {
+	for _, r := range c {
+		if v, ok := r.Get(field); ok {
+			return v, true
+		}
+	}
+	return "", false
+}
+
+// VCFRecord returns a Record over the fixed columns of a VCF line (CHROM,
+// POS, ID, REF, ALT, QUAL, FILTER) and its INFO keys. INFO flags have the
+// value "Y".
+func VCFRecord(chrom string, pos int64, id, ref, alt, qual, filter, info string) Map {
+	m := Map{
+		"chrom":  chrom,
+		"pos":    strconv.FormatInt(pos, 10),
+		"id":     id,
+		"ref":    ref,
+		"alt":    alt,
+		"qual":   qual,
+		"filter": filter,
+	}
+	if info == "." {
+		return m
+	}
+	for _, kv := range strings.Split(info, ";") {
+		if kv == "" {
+			continue
+		}
+		k, v, ok := strings.Cut(kv, "=")
+		if !ok {
+			v = "Y"
+		}
+		if k == "CSQ" {
+			continue
+		}
+		if key := NormalizeField(k); m[key] == "" {
+			m[key] = v
+		}
+	}
+	return m
+}
@@ -0,0 +1,333 @@
+package filter
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Stats counts the records read and written by a stream filter. For VCF and
+// JSONL, Entries and EntriesKept count the CSQ entries or transcript
+// consequences.
+type Stats struct {
+	Records     int
+	RecordsKept int
+	Entries     int
+	EntriesKept int
+}
+
+// maxLine is the longest input line accepted by the stream filters.
+const maxLine = 64 * 1024 * 1024
+
+func newScanner(r io.Reader) *bufio.Scanner {
+	s := bufio.NewScanner(r)
+	s.Buffer(make([]byte, 0, 1024*1024), maxLine)
+	return s
+}
+
+// VCF copies an annotated VCF from r to w, keeping the CSQ entries that
+// match expr and dropping records left without any. Records without CSQ
+// are matched on their VCF columns and INFO keys alone. Header lines are
+// copied unchanged.
+func VCF(r io.Reader, w io.Writer, expr *Expr) (Stats, error) {
+	var st Stats
+	bw := bufio.NewWriter(w)
+	cols := NewColumns(nil)
+	sc := newScanner(r)
+	for sc.Scan() {
+		line := sc.Text()
+		if strings.HasPrefix(line, "#") {
+			if fields, ok := csqFormat(line); ok {
+				cols = NewColumns(fields)
+			}
+			bw.WriteString(line)
+			bw.WriteByte('\n')
+			continue
+		}
+		if line == "" {
+			continue
+		}
+		st.Records++
+		f := strings.SplitN(line, "\t", 9)
+		if len(f) < 8 {
+			return st, fmt.Errorf("line %d: expected at least 8 columns, got %d", st.Records, len(f))
+		}
+		pos, err := strconv.ParseInt(f[1], 10, 64)
+		if err != nil {
+			return st, fmt.Errorf("line %d: invalid POS %q", st.Records, f[1])
+		}
+		rec := VCFRecord(f[0], pos, f[2], f[3], f[4], f[5], f[6], f[7])
+
+		info, csq, hasCSQ := splitCSQ(f[7])
+		if !hasCSQ {
+			if !expr.Match(rec) {
+				continue
+			}
+		} else {
+			entries := strings.Split(csq, ",")
+			var kept []string
+			for _, entry := range entries {
+				if expr.Match(Chain{cols.Row(strings.Split(entry, "|")), rec}) {
+					kept = append(kept, entry)
+				}
+			}
+			st.Entries += len(entries)
+			if len(kept) == 0 {
+				continue
+			}
+			st.EntriesKept += len(kept)
+			if len(kept) < len(entries) {
+				f[7] = appendCSQ(info, strings.Join(kept, ","))
+				line = strings.Join(f, "\t")
+			}
+		}
+		st.RecordsKept++
+		bw.WriteString(line)
+		bw.WriteByte('\n')
+	}
+	if err := sc.Err(); err != nil {
+		return st, err
+	}
+	return st, bw.Flush()
+}
+
+// csqFormat returns the field names of a CSQ ##INFO header line
+// ("... Format: Allele|Consequence|...").
+func csqFormat(line string) ([]string, bool) {
+	if !strings.HasPrefix(line, "##INFO=<ID=CSQ,") {
+		return nil, false
+	}
+	_, format, ok := strings.Cut(line, "Format: ")
+	if !ok {
+		return nil, false
+	}
+	format, _, _ = strings.Cut(format, "\"")
+	return strings.Split(format, "|"), true
+}
+
+// splitCSQ separates the CSQ value from the other INFO keys.
+func splitCSQ(info string) (rest []string, csq string, ok bool) {
+	for _, kv := range strings.Split(info, ";") {
+		if v, found := strings.CutPrefix(kv, "CSQ="); found {
+			csq, ok = v, true
+			continue
+		}
+		if kv != "" && kv != "." {
+			rest = append(rest, kv)
+		}
+	}
+	return rest, csq, ok
+}
+
+func appendCSQ(info []string, csq string) string {
+	return strings.Join(append(info, "CSQ="+csq), ";")
+}
+
+// MAF copies a MAF or other tab-separated file with a header row from r to
+// w, keeping the rows that match expr. Leading '#' comment lines and the
+// header are copied unchanged.
+func MAF(r io.Reader, w io.Writer, expr *Expr) (Stats, error) {
+	var st Stats
+	bw := bufio.NewWriter(w)
+	var cols *Columns
+	sc := newScanner(r)
+	for sc.Scan() {
+		line := sc.Text()
+		if cols == nil {
+			if !strings.HasPrefix(line, "#") && line != "" {
+				cols = NewColumns(strings.Split(line, "\t"))
+			}
+			bw.WriteString(line)
+			bw.WriteByte('\n')
+			continue
+		}
+		if line == "" {
+			continue
+		}
+		st.Records++
+		if !expr.Match(cols.Row(strings.Split(line, "\t"))) {
+			continue
+		}
+		st.RecordsKept++
+		bw.WriteString(line)
+		bw.WriteByte('\n')
+	}
+	if err := sc.Err(); err != nil {
+		return st, err
+	}
+	return st, bw.Flush()
+}
+
+// JSONL copies JSON Lines annotation output (vibe-vep or VEP style) from r
+// to w, keeping the transcript_consequences that match expr and dropping
+// lines left without any. Each consequence is matched on its own fields,
+// the keys of its extra object and then the variant's top-level fields;
+// arrays are joined with '&' and true is "Y". Lines without
+// transcript_consequences are matched on their top-level fields.
+func JSONL(r io.Reader, w io.Writer, expr *Expr) (Stats, error) {
+	var st Stats
+	bw := bufio.NewWriter(w)
+	sc := newScanner(r)
+	for sc.Scan() {
+		line := bytes.TrimSpace(sc.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		st.Records++
+		obj, err := decodeObject(line)
+		if err != nil {
+			return st, fmt.Errorf("line %d: %w", st.Records, err)
+		}
+		top := Map{}
+		tcIdx := -1
+		for i, kv := range obj {
+			if kv.key == "transcript_consequences" {
+				tcIdx = i
+				continue
+			}
+			flattenValue(top, kv.key, kv.value)
+		}
+
+		if tcIdx < 0 {
+			if !expr.Match(top) {
+				continue
+			}
+		} else {
+			var tcs []json.RawMessage
+			if err := json.Unmarshal(obj[tcIdx].value, &tcs); err != nil {
+				return st, fmt.Errorf("line %d: transcript_consequences: %w", st.Records, err)
+			}
+			var kept [][]byte
+			for _, tc := range tcs {
+				st.Entries++
+				fields, err := decodeObject(tc)
+				if err != nil {
+					return st, fmt.Errorf("line %d: transcript_consequences: %w", st.Records, err)
+				}
+				m := Map{}
+				for _, kv := range fields {
+					flattenValue(m, kv.key, kv.value)
+				}
+				if expr.Match(Chain{m, top}) {
+					kept = append(kept, tc)
+				}
+			}
+			if len(kept) == 0 {
+				continue
+			}
+			st.EntriesKept += len(kept)
+			if len(kept) < len(tcs) {
+				obj[tcIdx].value = append(append([]byte{'['}, bytes.Join(kept, []byte{','})...), ']')
+				line = encodeObject(obj)
+			}
+		}
+		st.RecordsKept++
+		bw.Write(line)
+		bw.WriteByte('\n')
+	}
+	if err := sc.Err(); err != nil {
+		return st, err
+	}
+	return st, bw.Flush()
+}
+
+// keyValue is one member of a JSON object, kept in input order.
+type keyValue struct {
+	key   string
+	value json.RawMessage
+}
+
+func decodeObject(data []byte) ([]keyValue, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	if t, err := dec.Token(); err != nil {
+		return nil, err
+	} else if t != json.Delim('{') {
+		return nil, fmt.Errorf("expected a JSON object")
+	}
+	var obj []keyValue
+	for dec.More() {
+		t, err := dec.Token()
+		if err != nil {
+			return nil, err
+		}
+		key, _ := t.(string)
+		var v json.RawMessage
+		if err := dec.Decode(&v); err != nil {
+			return nil, err
+		}
+		obj = append(obj, keyValue{key, v})
+	}
+	return obj, nil
+}
+
+func encodeObject(obj []keyValue) []byte {
+	var b bytes.Buffer
+	b.WriteByte('{')
+	for i, kv := range obj {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		key, _ := json.Marshal(kv.key)
+		b.Write(key)
+		b.WriteByte(':')
+		b.Write(kv.value)
+	}
+	b.WriteByte('}')
+	return b.Bytes()
+}
+
+// flattenValue sets key to the string form of a JSON value. The members of
+// an "extra" object are set under their own names; other objects are
+// skipped.
+func flattenValue(m Map, key string, raw json.RawMessage) {
+	var v any
+	if err := json.Unmarshal(raw, &v); err != nil {
+		return
+	}
+	switch x := v.(type) {
+	case map[string]any:
+		if key != "extra" {
+			return
+		}
+		for k, ev := range x {
+			if s, ok := scalarString(ev); ok {
+				if _, exists := m[NormalizeField(k)]; !exists {
+					m.Set(k, s)
+				}
+			}
+		}
+	case []any:
+		parts := make([]string, 0, len(x))
+		for _, e := range x {
+			if s, ok := scalarString(e); ok && s != "" {
+				parts = append(parts, s)
+			}
+		}
+		m.Set(key, strings.Join(parts, "&"))
+	default:
+		if s, ok := scalarString(x); ok {
+			m.Set(key, s)
+		}
+	}
+}
+
+func scalarString(v any) (string, bool) {
+	switch x := v.(type) {
+	case string:
+		return x, true
+	case float64:
+		return strconv.FormatFloat(x, 'g', -1, 64), true
+	case bool:
+		if x {
+			return "Y", true
+		}
+		return "", true
+	case nil:
+		return "", true
+	}
+	return "", false
+}
@@ -0,0 +1,71 @@
+package filter
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVCF(t *testing.T) {
+	in := strings.Join([]string{
+		"##fileformat=VCFv4.2",
+		`##INFO=<ID=CSQ,Number=.,Type=String,Description="Consequence annotations from vibe-vep. Format: Allele|Consequence|IMPACT|SYMBOL|gnomad_af">`,
+		"#CHROM\tPOS\tID\tREF\tALT\tQUAL\tFILTER\tINFO",
+		"12\t25245350\t.\tC\tA\t.\tPASS\tDP=10;CSQ=A|missense_variant|MODERATE|KRAS|,A|downstream_gene_variant|MODIFIER|KRAS|",
+		"17\t7675088\t.\tC\tT\t.\tPASS\tCSQ=T|synonymous_variant|LOW|TP53|0.2",
+		"1\t100\t.\tA\tG\t.\tPASS\tDP=3",
+	}, "\n") + "\n"
+
+	expr, err := Parse("IMPACT in HIGH,MODERATE and not gnomad_af > 0.001")
+	require.NoError(t, err)
+	var out bytes.Buffer
+	st, err := VCF(strings.NewReader(in), &out, expr)
+	require.NoError(t, err)
+
+	lines := strings.Split(strings.TrimRight(out.String(), "\n"), "\n")
+	require.Len(t, lines, 4)
+	assert.Equal(t, "12\t25245350\t.\tC\tA\t.\tPASS\tDP=10;CSQ=A|missense_variant|MODERATE|KRAS|", lines[3])
+	assert.Equal(t, Stats{Records: 3, RecordsKept: 1, Entries: 3, EntriesKept: 1}, st)
+
+	// Records without CSQ are matched on INFO.
+	expr, err = Parse("DP < 5")
+	require.NoError(t, err)
+	out.Reset()
+	_, err = VCF(strings.NewReader(in), &out, expr)
+	require.NoError(t, err)
+	assert.True(t, strings.HasSuffix(out.String(), "1\t100\t.\tA\tG\t.\tPASS\tDP=3\n"))
+	assert.NotContains(t, out.String(), "7675088")
+}
+
+func TestMAF(t *testing.T) {
+	in := "#version 2.4\n" +
+		"Hugo_Symbol\tvibe.consequence\tvibe.gnomad.af\n" +
+		"KRAS\tmissense_variant\t\n" +
+		"TP53\tmissense_variant\t0.3\n" +
+		"BRAF\tintron_variant\t\n"
+
+	expr, err := Parse("consequence is missense_variant and not gnomad_af >= 0.01")
+	require.NoError(t, err)
+	var out bytes.Buffer
+	st, err := MAF(strings.NewReader(in), &out, expr)
+	require.NoError(t, err)
+	assert.Equal(t, "#version 2.4\nHugo_Symbol\tvibe.consequence\tvibe.gnomad.af\nKRAS\tmissense_variant\t\n", out.String())
+	assert.Equal(t, 3, st.Records)
+	assert.Equal(t, 1, st.RecordsKept)
+}
+
+func TestJSONL(t *testing.T) {
+	in := `{"input":"12:g.25245350C>A","most_severe_consequence":"missense_variant","transcript_consequences":[{"transcript_id":"ENST00000256078","hgvsc":"c.35G>T","consequence_terms":["missense_variant"],"impact":"MODERATE","canonical":true,"extra":{"gnomad.af":"0.0001"}},{"transcript_id":"ENST00000557334","consequence_terms":["downstream_gene_variant"],"impact":"MODIFIER"}]}
+{"input":"1:g.100A>G","transcript_consequences":[{"transcript_id":"ENST1","consequence_terms":["intron_variant"],"impact":"MODIFIER"}]}
+`
+	expr, err := Parse("impact in HIGH,MODERATE and canonical and gnomad_af < 0.001 and input match ^12")
+	require.NoError(t, err)
+	var out bytes.Buffer
+	st, err := JSONL(strings.NewReader(in), &out, expr)
+	require.NoError(t, err)
+	assert.Equal(t, `{"input":"12:g.25245350C>A","most_severe_consequence":"missense_variant","transcript_consequences":[{"transcript_id":"ENST00000256078","hgvsc":"c.35G>T","consequence_terms":["missense_variant"],"impact":"MODERATE","canonical":true,"extra":{"gnomad.af":"0.0001"}}]}`+"\n", out.String())
+	assert.Equal(t, Stats{Records: 2, RecordsKept: 1, Entries: 3, EntriesKept: 1}, st)
+}
@@ -0,0 +1,101 @@
+package genomebuild
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/inodb/vibe-vep/internal/annotate"
+	"github.com/inodb/vibe-vep/internal/cache"
+	"github.com/inodb/vibe-vep/internal/vcf"
+)
+
+// Thresholds for deciding an assembly from REF concordance.
+const (
+	minConcordanceChecked = 20   // coding REF bases needed per assembly
+	minConcordanceRate    = 0.9  // match rate required of the chosen assembly
+	minConcordanceMargin  = 0.25 // lead required over the other assembly
+)
+
+// Concordance is the agreement of sampled REF alleles with one assembly's
+// transcript sequences.
+type Concordance struct {
+	Assembly string
+	Checked  int // variants whose first REF base falls in a coding sequence
+	Matched  int // of which the base agrees with the CDS
+}
+
+// Rate returns the fraction of checked REF bases that matched.
+func (c Concordance) Rate() float64 {
+	if c.Checked == 0 {
+		return 0
+	}
+	return float64(c.Matched) / float64(c.Checked)
+}
+
+// Conclusive reports whether enough REF bases were checked and matched for
+// the assembly to be chosen without comparing it to the other one: at
+// another build's coordinates nearly all bases would mismatch.
+func (c Concordance) Conclusive() bool {
+	return c.Checked >= minConcordanceChecked && c.Rate() >= minConcordanceRate
+}
+
+// String formats the concordance as "GRCh38 97% of 120".
+func (c Concordance) String() string {
+	return fmt.Sprintf("%s %.0f%% of %d", c.Assembly, 100*c.Rate(), c.Checked)
+}
+
+// CheckConcordance compares the first REF base of each variant with the
+// coding sequence of a protein-coding transcript of c covering it.
+func CheckConcordance(assembly string, c *cache.Cache, variants []*vcf.Variant) Concordance {
+	res := Concordance{Assembly: assembly}
+	for _, v := range variants {
+		if v.Ref == "" || v.Ref == "-" || v.IsStructural() {
+			continue
+		}
+		ref := strings.ToUpper(v.Ref[:1])[0]
+		for _, t := range c.FindTranscripts(v.NormalizeChrom(), v.Pos) {
+			cdsPos := annotate.GenomicToCDS(v.Pos, t)
+			if cdsPos < 1 || cdsPos > int64(len(t.CDSSequence)) {
+				continue
+			}
+			base := t.CDSSequence[cdsPos-1]
+			if t.IsReverseStrand() {
+				base = annotate.Complement(base)
+			}
+			res.Checked++
+			if base == ref {
+				res.Matched++
+			}
+			break
+		}
+	}
+	return res
+}
+
+// DecideByConcordance picks the assembly whose transcripts agree with the
+// sampled REF alleles: at least minConcordanceRate of enough checked bases,
+// and clearly ahead of any other assembly checked.
+func DecideByConcordance(results []Concordance) (string, error) {
+	var usable []Concordance
+	for _, r := range results {
+		if r.Checked >= minConcordanceChecked {
+			usable = append(usable, r)
+		}
+	}
+	if len(usable) == 0 {
+		return "", fmt.Errorf("too few coding variants to compare REF alleles (need %d); pass --assembly explicitly", minConcordanceChecked)
+	}
+	sort.SliceStable(usable, func(i, j int) bool { return usable[i].Rate() > usable[j].Rate() })
+
+	best := usable[0]
+	if best.Rate() >= minConcordanceRate &&
+		(len(usable) == 1 || best.Rate()-usable[1].Rate() >= minConcordanceMargin) {
+		return best.Assembly, nil
+	}
+	parts := make([]string, len(results))
+	for i, r := range results {
+		parts[i] = r.String()
+	}
+	return "", fmt.Errorf("REF alleles match no assembly clearly (%s); pass --assembly explicitly", strings.Join(parts, ", "))
+}
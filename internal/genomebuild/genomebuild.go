@@ -0,0 +1,194 @@
+// Package genomebuild detects the genome assembly (GRCh37 or GRCh38) of
+// variant input files from header lines, build columns, chromosome lengths
+// and REF alleles.
+package genomebuild
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Supported assemblies.
+const (
+	GRCh37 = "GRCh37"
+	GRCh38 = "GRCh38"
+)
+
+// Assemblies lists the supported assemblies.
+var Assemblies = []string{GRCh38, GRCh37}
+
+// chromLengths holds primary chromosome lengths per assembly. Chromosomes
+// whose length is the same in both (MT) are omitted.
+var chromLengths = map[string]map[string]int64{
+	GRCh37: {
+		"1": 249250621, "2": 243199373, "3": 198022430, "4": 191154276,
+		"5": 180915260, "6": 171115067, "7": 159138663, "8": 146364022,
+		"9": 141213431, "10": 135534747, "11": 135006516, "12": 133851895,
+		"13": 115169878, "14": 107349540, "15": 102531392, "16": 90354753,
+		"17": 81195210, "18": 78077248, "19": 59128983, "20": 63025520,
+		"21": 48129895, "22": 51304566, "X": 155270560, "Y": 59373566,
+	},
+	GRCh38: {
+		"1": 248956422, "2": 242193529, "3": 198295559, "4": 190214555,
+		"5": 181538259, "6": 170805979, "7": 159345973, "8": 145138636,
+		"9": 138394717, "10": 133797422, "11": 135086622, "12": 133275309,
+		"13": 114364328, "14": 107043718, "15": 101991189, "16": 90338345,
+		"17": 83257441, "18": 80373285, "19": 58617616, "20": 64444167,
+		"21": 46709983, "22": 50818468, "X": 156040895, "Y": 57227415,
+	},
+}
+
+// Evidence is one observation about the assembly of an input file.
+type Evidence struct {
+	Assembly string // GRCh37 or GRCh38
+	Source   string // where it came from, e.g. "##reference" or "NCBI_Build"
+}
+
+// ParseName maps an assembly name as found in files (GRCh37, hg19, b37,
+// hs37d5, human_g1k_v37, 37, GRCh38, hg38, Homo_sapiens_assembly38, 38, ...)
+// to GRCh37 or GRCh38.
+func ParseName(name string) (string, bool) {
+	s := strings.ToLower(strings.TrimSpace(name))
+	switch s {
+	case "":
+		return "", false
+	case "37", "b37":
+		return GRCh37, true
+	case "38", "b38":
+		return GRCh38, true
+	}
+	for _, p := range []string{"grch38", "hg38", "assembly38", "_b38", "hs38"} {
+		if strings.Contains(s, p) {
+			return GRCh38, true
+		}
+	}
+	for _, p := range []string{"grch37", "hg19", "hs37", "g1k_v37", "_b37", "ncbi37"} {
+		if strings.Contains(s, p) {
+			return GRCh37, true
+		}
+	}
+	return "", false
+}
+
+// ContigAssembly returns the assembly whose chromosome has exactly the given
+// length, accepting names with or without a "chr" prefix.
+func ContigAssembly(chrom string, length int64) (string, bool) {
+	chrom = strings.TrimPrefix(chrom, "chr")
+	for _, a := range Assemblies {
+		if chromLengths[a][chrom] == length {
+			return a, true
+		}
+	}
+	return "", false
+}
+
+// FromPosition returns evidence when pos lies beyond the end of chrom in one
+// assembly but not the other.
+func FromPosition(chrom string, pos int64) (Evidence, bool) {
+	chrom = strings.TrimPrefix(chrom, "chr")
+	n37, n38 := chromLengths[GRCh37][chrom], chromLengths[GRCh38][chrom]
+	switch {
+	case n37 == 0 || n38 == 0:
+		return Evidence{}, false
+	case pos > n37 && pos <= n38:
+		return Evidence{Assembly: GRCh38, Source: "position beyond GRCh37 chromosome end"}, true
+	case pos > n38 && pos <= n37:
+		return Evidence{Assembly: GRCh37, Source: "position beyond GRCh38 chromosome end"}, true
+	}
+	return Evidence{}, false
+}
+
+// FromVCFHeader collects evidence from ##reference, ##assembly and ##contig
+// header lines.
+func FromVCFHeader(lines []string) []Evidence {
+	var ev []Evidence
+	for _, line := range lines {
+		switch {
+		case strings.HasPrefix(line, "##reference="):
+			if a, ok := ParseName(strings.TrimPrefix(line, "##reference=")); ok {
+				ev = append(ev, Evidence{Assembly: a, Source: "##reference"})
+			}
+		case strings.HasPrefix(line, "##assembly="):
+			if a, ok := ParseName(strings.TrimPrefix(line, "##assembly=")); ok {
+				ev = append(ev, Evidence{Assembly: a, Source: "##assembly"})
+			}
+		case strings.HasPrefix(line, "##contig=<"):
+			attrs := parseStructuredHeader(line[len("##contig=<"):])
+			if a, ok := ParseName(attrs["assembly"]); ok {
+				ev = append(ev, Evidence{Assembly: a, Source: "##contig assembly"})
+			}
+			if n, err := strconv.ParseInt(attrs["length"], 10, 64); err == nil {
+				if a, ok := ContigAssembly(attrs["ID"], n); ok {
+					ev = append(ev, Evidence{Assembly: a, Source: "##contig length"})
+				}
+			}
+		}
+	}
+	return ev
+}
+
+// parseStructuredHeader splits the key=value pairs of a structured VCF header
+// value such as `ID=1,length=249250621>`. Quoted values may contain commas.
+func parseStructuredHeader(s string) map[string]string {
+	s = strings.TrimSuffix(s, ">")
+	attrs := make(map[string]string)
+	for len(s) > 0 {
+		key, rest, ok := strings.Cut(s, "=")
+		if !ok {
+			break
+		}
+		var val string
+		if strings.HasPrefix(rest, `"`) {
+			end := strings.Index(rest[1:], `"`)
+			if end < 0 {
+				val, rest = rest[1:], ""
+			} else {
+				val, rest = rest[1:end+1], rest[end+2:]
+			}
+			rest = strings.TrimPrefix(rest, ",")
+		} else {
+			val, rest, _ = strings.Cut(rest, ",")
+		}
+		attrs[strings.TrimSpace(key)] = val
+		s = rest
+	}
+	return attrs
+}
+
+// Decide returns the assembly supported by all evidence, or "" when there is
+// none. Evidence for both assemblies is an error naming the sources on each
+// side.
+func Decide(evidence []Evidence) (string, error) {
+	sources := make(map[string][]string)
+	for _, e := range evidence {
+		if !contains(sources[e.Assembly], e.Source) {
+			sources[e.Assembly] = append(sources[e.Assembly], e.Source)
+		}
+	}
+	switch len(sources) {
+	case 0:
+		return "", nil
+	case 1:
+		for a := range sources {
+			return a, nil
+		}
+	}
+	var sides []string
+	for _, a := range Assemblies {
+		s := sources[a]
+		sort.Strings(s)
+		sides = append(sides, fmt.Sprintf("%s (%s)", a, strings.Join(s, ", ")))
+	}
+	return "", fmt.Errorf("conflicting genome build evidence: %s; pass --assembly explicitly", strings.Join(sides, " vs "))
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,130 @@
+package genomebuild
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/inodb/vibe-vep/internal/cache"
+	"github.com/inodb/vibe-vep/internal/vcf"
+)
+
+func TestParseName(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"GRCh37", GRCh37},
+		{"37", GRCh37},
+		{"hg19", GRCh37},
+		{"file:///ref/human_g1k_v37.fasta", GRCh37},
+		{"hs37d5", GRCh37},
+		{"GRCh38", GRCh38},
+		{"38", GRCh38},
+		{"hg38", GRCh38},
+		{"/ref/Homo_sapiens_assembly38.fasta", GRCh38},
+		{"GCA_000001405.15_GRCh38_no_alt_analysis_set", GRCh38},
+		{"", ""},
+		{"mm10", ""},
+	}
+	for _, tt := range tests {
+		got, ok := ParseName(tt.name)
+		assert.Equal(t, tt.want, got, tt.name)
+		assert.Equal(t, tt.want != "", ok, tt.name)
+	}
+}
+
+func TestFromVCFHeader(t *testing.T) {
+	ev := FromVCFHeader([]string{
+		"##fileformat=VCFv4.2",
+		"##reference=file:///data/hs37d5.fa",
+		`##contig=<ID=1,length=249250621,assembly=b37>`,
+		`##contig=<ID=chr7,length=159345973,description="a, b">`,
+		`##contig=<ID=GL000192.1,length=547496>`,
+		"#CHROM\tPOS\tID\tREF\tALT\tQUAL\tFILTER\tINFO",
+	})
+	assert.Equal(t, []Evidence{
+		{GRCh37, "##reference"},
+		{GRCh37, "##contig assembly"},
+		{GRCh37, "##contig length"},
+		{GRCh38, "##contig length"},
+	}, ev)
+
+	_, err := Decide(ev)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "GRCh38 (##contig length) vs GRCh37 (##contig assembly, ##contig length, ##reference)")
+}
+
+func TestFromPosition(t *testing.T) {
+	e, ok := FromPosition("chr1", 249_000_000)
+	require.True(t, ok)
+	assert.Equal(t, GRCh37, e.Assembly)
+
+	e, ok = FromPosition("17", 82_000_000)
+	require.True(t, ok)
+	assert.Equal(t, GRCh38, e.Assembly)
+
+	_, ok = FromPosition("1", 1_000_000)
+	assert.False(t, ok)
+	_, ok = FromPosition("MT", 20_000)
+	assert.False(t, ok)
+}
+
+func TestDecide(t *testing.T) {
+	a, err := Decide(nil)
+	require.NoError(t, err)
+	assert.Empty(t, a)
+
+	a, err = Decide([]Evidence{{GRCh37, "NCBI_Build"}, {GRCh37, "NCBI_Build"}})
+	require.NoError(t, err)
+	assert.Equal(t, GRCh37, a)
+}
+
+// codingCache returns a cache with one forward-strand coding transcript on
+// chromosome 1 whose CDS (positions 101-130) is cds.
+func codingCache(cds string) *cache.Cache {
+	c := cache.New()
+	tr := &cache.Transcript{
+		ID: "ENST_T", GeneName: "T", Chrom: "1", Start: 1, End: 200, Strand: 1, Biotype: "protein_coding",
+		CDSStart: 101, CDSEnd: 130, CDSSequence: cds,
+		Exons: []cache.Exon{{Number: 1, Start: 1, End: 200, CDSStart: 101, CDSEnd: 130, Frame: 0}},
+	}
+	tr.BuildCDSIndex()
+	c.AddTranscript(tr)
+	c.BuildIndex()
+	return c
+}
+
+func TestConcordance(t *testing.T) {
+	cds := "ATGGCCAAGCTTGGATCCGAATTCTAGTAA"
+	c38 := codingCache(cds)
+	c37 := codingCache(strings.Repeat("C", len(cds)))
+
+	var sample []*vcf.Variant
+	for i := 0; i < 25; i++ {
+		pos := int64(101 + i)
+		sample = append(sample, &vcf.Variant{Chrom: "1", Pos: pos, Ref: string(cds[i]), Alt: "N"})
+	}
+	sample = append(sample, &vcf.Variant{Chrom: "1", Pos: 50, Ref: "A", Alt: "T"}) // UTR: not checked
+
+	r38 := CheckConcordance(GRCh38, c38, sample)
+	r37 := CheckConcordance(GRCh37, c37, sample)
+	assert.Equal(t, Concordance{GRCh38, 25, 25}, r38)
+	assert.Equal(t, 25, r37.Checked)
+	assert.Less(t, r37.Rate(), 0.5)
+
+	assert.True(t, r38.Conclusive())
+	assert.False(t, r37.Conclusive())
+
+	a, err := DecideByConcordance([]Concordance{r38, r37})
+	require.NoError(t, err)
+	assert.Equal(t, GRCh38, a)
+
+	_, err = DecideByConcordance([]Concordance{{GRCh38, 25, 20}, {GRCh37, 25, 19}})
+	assert.ErrorContains(t, err, "match no assembly clearly")
+
+	_, err = DecideByConcordance([]Concordance{{GRCh38, 5, 5}})
+	assert.ErrorContains(t, err, "too few coding variants")
+}
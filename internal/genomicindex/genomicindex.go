@@ -4,11 +4,13 @@ import (
 	"bufio"
 	"compress/gzip"
 	"database/sql"
+	"errors"
 	"fmt"
 	"os"
 	"strconv"
 	"strings"
 
+	"github.com/inodb/vibe-vep/internal/annotate"
 	"github.com/inodb/vibe-vep/internal/datasource/clinvar"
 	"github.com/inodb/vibe-vep/internal/datasource/dbsnp"
 	"github.com/inodb/vibe-vep/internal/datasource/gnomad"
@@ -20,8 +22,15 @@ import (
 type Store struct {
 	db       *sql.DB
 	lookupPS *sql.Stmt
+	rsidPS   *sql.Stmt // nil for indexes built without the dbsnp_alleles table
+
+	fingerprint string // index file size and modification time at Open
 }
 
+// ErrNoRSIDIndex is returned by LookupRSID when the database was built
+// without the dbSNP rsID index.
+var ErrNoRSIDIndex = errors.New("genomic index has no rsID index (download dbSNP data and run: vibe-vep prepare)")
+
 // Open opens an existing genomic annotation database and prepares the lookup statement.
 func Open(dbPath string) (*Store, error) {
 	db, err := sql.Open("sqlite", dbPath+"?_pragma=mmap_size%3D2147483648&_pragma=journal_mode%3DWAL")
@@ -39,7 +48,26 @@ func Open(dbPath string) (*Store, error) {
 		return nil, fmt.Errorf("prepare lookup: %w", err)
 	}
 
-	return &Store{db: db, lookupPS: ps}, nil
+	// Indexes built before rsID support lack dbsnp_alleles; Migrate adds
+	// it when dbSNP data is present, so this only happens for databases
+	// built without it.
+	var rsidPS *sql.Stmt
+	if tableExists(db, "dbsnp_alleles") {
+		rsidPS, err = db.Prepare(`SELECT chrom, pos, ref, alt FROM dbsnp_alleles WHERE rsid=? ORDER BY chrom, pos, ref, alt`)
+		if err != nil {
+			ps.Close()
+			db.Close()
+			return nil, fmt.Errorf("prepare rsID lookup: %w", err)
+		}
+	}
+
+	return &Store{db: db, lookupPS: ps, rsidPS: rsidPS, fingerprint: annotate.FileFingerprint(dbPath)}, nil
+}
+
+// Fingerprint identifies the index build: the database file's size and
+// modification time when it was opened.
+func (s *Store) Fingerprint() string {
+	return s.fingerprint
 }
 
 // Lookup performs a point lookup for a single variant.
@@ -57,11 +85,53 @@ func (s *Store) Lookup(chrom string, pos int64, ref, alt string) (Result, bool)
 	return r, true
 }
 
-// Close closes the prepared statement and database.
+// LookupRSID returns the alleles dbSNP lists for an rsID ("rs121913529"),
+// one per ALT allele, in VCF convention. It returns no alleles and no
+// error for an unknown rsID.
+func (s *Store) LookupRSID(rsid string) ([]Allele, error) {
+	if s.rsidPS == nil {
+		return nil, ErrNoRSIDIndex
+	}
+	id, ok := parseRSID(rsid)
+	if !ok {
+		return nil, fmt.Errorf("invalid rsID %q", rsid)
+	}
+	rows, err := s.rsidPS.Query(id)
+	if err != nil {
+		return nil, fmt.Errorf("rsID lookup: %w", err)
+	}
+	defer rows.Close()
+	var alleles []Allele
+	for rows.Next() {
+		var a Allele
+		if err := rows.Scan(&a.Chrom, &a.Pos, &a.Ref, &a.Alt); err != nil {
+			return nil, fmt.Errorf("rsID lookup: %w", err)
+		}
+		alleles = append(alleles, a)
+	}
+	return alleles, rows.Err()
+}
+
+// parseRSID returns the number of an rsID such as "rs121913529".
+func parseRSID(rsid string) (int64, bool) {
+	if len(rsid) < 3 || !strings.EqualFold(rsid[:2], "rs") {
+		return 0, false
+	}
+	id, err := strconv.ParseInt(rsid[2:], 10, 64)
+	if err != nil || id <= 0 {
+		return 0, false
+	}
+	return id, true
+}
+
+// Close closes the prepared statements and database.
 func (s *Store) Close() error {
 	if s.lookupPS != nil {
 		s.lookupPS.Close()
 	}
+	if s.rsidPS != nil {
+		s.rsidPS.Close()
+	}
 	return s.db.Close()
 }
 
@@ -97,9 +167,51 @@ func Ready(dbPath string, sources BuildSources) bool {
 	if err := quickCheck(dbPath); err != nil {
 		return false
 	}
+
 	return true
 }
 
+// NeedsMigration reports whether an existing index predates a schema change
+// that Migrate can apply in place: currently, the dbsnp_alleles rsID index
+// is missing while dbSNP data is available.
+func NeedsMigration(dbPath string, sources BuildSources) bool {
+	if sources.DbSnpVCF == "" {
+		return false
+	}
+	if _, err := os.Stat(sources.DbSnpVCF); err != nil {
+		return false
+	}
+	return !hasTable(dbPath, "dbsnp_alleles")
+}
+
+// Migrate upgrades an index built by an older version in place instead of
+// rebuilding it. It adds the dbsnp_alleles rsID index, loading only the
+// rsIDs from the dbSNP VCF. The database must not be open elsewhere.
+func Migrate(dbPath string, sources BuildSources, logf func(string, ...any)) error {
+	if !NeedsMigration(dbPath, sources) {
+		return nil
+	}
+
+	db, err := sql.Open("sqlite", dbPath)
+	if err != nil {
+		return fmt.Errorf("open database: %w", err)
+	}
+	defer db.Close()
+
+	if err := createRSIDTable(db); err != nil {
+		return err
+	}
+	logf("adding rsID index from %s", sources.DbSnpVCF)
+	n, err := loadDbSNP(db, sources.DbSnpVCF, true)
+	if err != nil {
+		// Leave the index as it was so the migration is retried next time.
+		db.Exec("DROP TABLE dbsnp_alleles")
+		return fmt.Errorf("load dbSNP rsIDs: %w", err)
+	}
+	logf("  indexed %d dbSNP alleles by rsID", n)
+	return nil
+}
+
 // quickCheck opens the database and verifies the genomic_annotations table
 // exists and can return a row. This catches corruption, truncation, and
 // schema mismatches without scanning the full table.
@@ -115,6 +227,23 @@ func quickCheck(dbPath string) error {
 	return db.QueryRow("SELECT 1 FROM genomic_annotations LIMIT 1").Scan(&n)
 }
 
+// hasTable reports whether the database at dbPath has the named table.
+func hasTable(dbPath, table string) bool {
+	db, err := sql.Open("sqlite", dbPath+"?mode=ro")
+	if err != nil {
+		return false
+	}
+	defer db.Close()
+	return tableExists(db, table)
+}
+
+// tableExists reports whether the database has the named table.
+func tableExists(db *sql.DB, table string) bool {
+	var n int
+	err := db.QueryRow("SELECT 1 FROM sqlite_master WHERE type='table' AND name=?", table).Scan(&n)
+	return err == nil
+}
+
 // Build creates the SQLite database from source files. Each source is loaded
 // in a single transaction. The database is created at dbPath (overwriting any
 // existing file).
@@ -170,6 +299,10 @@ func Build(dbPath string, sources BuildSources, logf func(string, ...any)) error
 		return fmt.Errorf("create table: %w", err)
 	}
 
+	if err := createRSIDTable(db); err != nil {
+		return err
+	}
+
 	// 1. AlphaMissense
 	if sources.AlphaMissenseTSV != "" {
 		if _, err := os.Stat(sources.AlphaMissenseTSV); err == nil {
@@ -222,7 +355,7 @@ func Build(dbPath string, sources BuildSources, logf func(string, ...any)) error
 	if sources.DbSnpVCF != "" {
 		if _, err := os.Stat(sources.DbSnpVCF); err == nil {
 			logf("loading dbSNP from %s", sources.DbSnpVCF)
-			n, err := loadDbSNP(db, sources.DbSnpVCF)
+			n, err := loadDbSNP(db, sources.DbSnpVCF, false)
 			if err != nil {
 				return fmt.Errorf("load dbSNP: %w", err)
 			}
@@ -568,8 +701,28 @@ func loadGnomad(db *sql.DB, vcfPath, version string) (int64, error) {
 	return count, nil
 }
 
-// loadDbSNP parses a gzipped dbSNP VCF and upserts RS IDs into the DB.
-func loadDbSNP(db *sql.DB, vcfPath string) (int64, error) {
+// createRSIDTable creates the reverse dbSNP index: rsID → alleles in VCF
+// convention (anchor base kept, so they can be annotated as-is). It is
+// filled by loadDbSNP.
+func createRSIDTable(db *sql.DB) error {
+	if _, err := db.Exec(`CREATE TABLE dbsnp_alleles (
+		rsid INTEGER NOT NULL,
+		chrom TEXT NOT NULL,
+		pos INTEGER NOT NULL,
+		ref TEXT NOT NULL,
+		alt TEXT NOT NULL,
+		PRIMARY KEY (rsid, chrom, pos, ref, alt)
+	) WITHOUT ROWID`); err != nil {
+		return fmt.Errorf("create dbsnp_alleles table: %w", err)
+	}
+	return nil
+}
+
+// loadDbSNP parses a gzipped dbSNP VCF and upserts RS IDs into the DB, one
+// row per ALT allele. Each allele is also added to the dbsnp_alleles rsID
+// index. With rsidOnly, genomic_annotations is left untouched and only the
+// rsID index is filled.
+func loadDbSNP(db *sql.DB, vcfPath string, rsidOnly bool) (int64, error) {
 	f, err := os.Open(vcfPath)
 	if err != nil {
 		return 0, err
@@ -604,6 +757,12 @@ func loadDbSNP(db *sql.DB, vcfPath string) (int64, error) {
 	}
 	defer stmt.Close()
 
+	rsidStmt, err := tx.Prepare(`INSERT OR IGNORE INTO dbsnp_alleles (rsid, chrom, pos, ref, alt) VALUES (?, ?, ?, ?, ?)`)
+	if err != nil {
+		return 0, err
+	}
+	defer rsidStmt.Close()
+
 	var count int64
 	for scanner.Scan() {
 		line := scanner.Text()
@@ -611,16 +770,28 @@ func loadDbSNP(db *sql.DB, vcfPath string) (int64, error) {
 			continue
 		}
 
-		entry, chrom, ok := dbsnp.ParseVCFLine(line)
+		entries, chrom, ok := dbsnp.ParseVCFAlleles(line)
 		if !ok {
 			continue
 		}
 
-		nPos, nRef, nAlt := NormalizeAlleles(entry.Pos, entry.Ref, entry.Alt)
-		if _, err := stmt.Exec(chrom, nPos, nRef, nAlt, entry.ID); err != nil {
-			return 0, fmt.Errorf("upsert dbSNP row: %w", err)
+		for _, entry := range entries {
+			nPos, nRef, nAlt := NormalizeAlleles(entry.Pos, entry.Ref, entry.Alt)
+			if !rsidOnly {
+				if _, err := stmt.Exec(chrom, nPos, nRef, nAlt, entry.ID); err != nil {
+					return 0, fmt.Errorf("upsert dbSNP row: %w", err)
+				}
+			}
+			// A record may carry several IDs ("rs1;rs2").
+			for _, id := range strings.Split(entry.ID, ";") {
+				if n, ok := parseRSID(id); ok {
+					if _, err := rsidStmt.Exec(n, chrom, entry.Pos, entry.Ref, entry.Alt); err != nil {
+						return 0, fmt.Errorf("insert dbSNP rsID row: %w", err)
+					}
+				}
+			}
+			count++
 		}
-		count++
 	}
 	if err := scanner.Err(); err != nil {
 		return 0, err
@@ -2,9 +2,11 @@ package genomicindex
 
 import (
 	"database/sql"
+	"errors"
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/inodb/vibe-vep/internal/annotate"
 	"github.com/inodb/vibe-vep/internal/vcf"
@@ -652,3 +654,127 @@ func TestReady(t *testing.T) {
 		t.Error("Ready should return true for valid database")
 	}
 }
+
+// TestLookupRSID verifies that Build fills the rsID reverse index with
+// VCF-convention alleles, one row per ALT.
+func TestLookupRSID(t *testing.T) {
+	dir := t.TempDir()
+	dbPath := filepath.Join(dir, "test.sqlite")
+
+	vcfContent := `##fileformat=VCFv4.2
+#CHROM	POS	ID	REF	ALT	QUAL	FILTER	INFO
+NC_000012.12	25245350	rs121913529	C	A,G,T	.	.	RS=121913529
+NC_000002.12	20000	rs555	GA	G	.	.	RS=555
+NC_000001.11	100	rs7;rs8	A	C	.	.	RS=7
+`
+	vcfPath := filepath.Join(dir, "dbsnp.vcf")
+	if err := os.WriteFile(vcfPath, []byte(vcfContent), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := Build(dbPath, BuildSources{DbSnpVCF: vcfPath}, func(string, ...any) {}); err != nil {
+		t.Fatal(err)
+	}
+	store, err := Open(dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	alleles, err := store.LookupRSID("rs121913529")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(alleles) != 3 {
+		t.Fatalf("got %d alleles, want 3: %+v", len(alleles), alleles)
+	}
+	for i, alt := range []string{"A", "G", "T"} {
+		want := Allele{Chrom: "12", Pos: 25245350, Ref: "C", Alt: alt}
+		if alleles[i] != want {
+			t.Errorf("allele %d = %+v, want %+v", i, alleles[i], want)
+		}
+	}
+
+	// Every ALT is also in the forward table.
+	if r, ok := store.Lookup("12", 25245350, "C", "T"); !ok || r.DbSnpID != "rs121913529" {
+		t.Errorf("forward lookup of third ALT: ok=%v id=%q", ok, r.DbSnpID)
+	}
+
+	// Indels keep the VCF anchor base.
+	alleles, err = store.LookupRSID("rs555")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(alleles) != 1 || alleles[0] != (Allele{Chrom: "2", Pos: 20000, Ref: "GA", Alt: "G"}) {
+		t.Errorf("rs555 = %+v, want 2:20000 GA>G", alleles)
+	}
+
+	// Merged records are reachable from each ID.
+	for _, id := range []string{"rs7", "rs8"} {
+		alleles, err = store.LookupRSID(id)
+		if err != nil || len(alleles) != 1 {
+			t.Errorf("%s: got %+v, %v", id, alleles, err)
+		}
+	}
+
+	alleles, err = store.LookupRSID("rs999")
+	if err != nil || len(alleles) != 0 {
+		t.Errorf("unknown rsID: got %+v, %v", alleles, err)
+	}
+	if _, err := store.LookupRSID("chr1"); err == nil {
+		t.Error("expected error for malformed rsID")
+	}
+}
+
+func TestLookupRSID_OldIndex(t *testing.T) {
+	dbPath := setupTestDB(t)
+	store, err := Open(dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	if _, err := store.LookupRSID("rs121913529"); !errors.Is(err, ErrNoRSIDIndex) {
+		t.Errorf("err=%v, want ErrNoRSIDIndex", err)
+	}
+
+	store.Close()
+
+	// An index without the rsID table stays usable and is migrated in place
+	// once dbSNP data is present.
+	vcfPath := filepath.Join(t.TempDir(), "dbsnp.vcf")
+	vcfData := "#CHROM\tPOS\tID\tREF\tALT\tQUAL\tFILTER\tINFO\nNC_000012.12\t25245351\trs121913529\tC\tA\t.\t.\tRS=121913529\n"
+	if err := os.WriteFile(vcfPath, []byte(vcfData), 0644); err != nil {
+		t.Fatal(err)
+	}
+	old := time.Now().Add(-time.Hour)
+	if err := os.Chtimes(vcfPath, old, old); err != nil {
+		t.Fatal(err)
+	}
+	bs := BuildSources{DbSnpVCF: vcfPath}
+	if !Ready(dbPath, bs) {
+		t.Error("Ready should not force a rebuild when dbsnp_alleles is missing")
+	}
+	if !NeedsMigration(dbPath, bs) {
+		t.Fatal("NeedsMigration should report the missing dbsnp_alleles table")
+	}
+	if err := Migrate(dbPath, bs, func(string, ...any) {}); err != nil {
+		t.Fatal(err)
+	}
+	if NeedsMigration(dbPath, bs) {
+		t.Error("NeedsMigration should be false after Migrate")
+	}
+
+	store, err = Open(dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	alleles, err := store.LookupRSID("rs121913529")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(alleles) != 1 || alleles[0] != (Allele{Chrom: "12", Pos: 25245351, Ref: "C", Alt: "A"}) {
+		t.Errorf("rs121913529 = %+v, want 12:25245351 C>A", alleles)
+	}
+}
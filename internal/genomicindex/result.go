@@ -37,6 +37,15 @@ type Result struct {
 	DbSnpID      string
 }
 
+// Allele is one ALT allele of a dbSNP rsID in VCF convention: indels keep
+// their anchor base, as in the dbSNP VCF.
+type Allele struct {
+	Chrom string
+	Pos   int64
+	Ref   string
+	Alt   string
+}
+
 // BuildSources holds paths to the source data files for building the index.
 type BuildSources struct {
 	AlphaMissenseTSV string // gzipped TSV (e.g. AlphaMissense_hg38.tsv.gz)
@@ -40,6 +40,7 @@ func NewSource(store *Store, version string) *GenomicSource {
 
 func (s *GenomicSource) Name() string                   { return "" }
 func (s *GenomicSource) Version() string                 { return s.version }
+func (s *GenomicSource) Fingerprint() string             { return s.store.Fingerprint() }
 func (s *GenomicSource) MatchLevel() annotate.MatchLevel { return annotate.MatchGenomic }
 
 func (s *GenomicSource) Columns() []annotate.ColumnDef {
@@ -137,6 +138,20 @@ func (s *GenomicSource) Annotate(v *vcf.Variant, anns []*annotate.Annotation) {
 	}
 }
 
+// LookupRSID returns one variant per ALT allele of a dbSNP rsID, making
+// GenomicSource an annotate.RSIDResolver.
+func (s *GenomicSource) LookupRSID(rsid string) ([]*vcf.Variant, error) {
+	alleles, err := s.store.LookupRSID(rsid)
+	if err != nil {
+		return nil, err
+	}
+	variants := make([]*vcf.Variant, len(alleles))
+	for i, a := range alleles {
+		variants[i] = &vcf.Variant{Chrom: a.Chrom, Pos: a.Pos, ID: rsid, Ref: a.Ref, Alt: a.Alt}
+	}
+	return variants, nil
+}
+
 // Store returns the underlying Store (for Close).
 func (s *GenomicSource) Store() *Store {
 	return s.store
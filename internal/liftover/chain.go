@@ -0,0 +1,272 @@
+// Package liftover converts genomic coordinates between assemblies using
+// UCSC chain files.
+package liftover
+
+import (
+	"bufio"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Errors describing why a region could not be lifted.
+var (
+	ErrUnmapped = errors.New("no chain covers the region")
+	ErrSplit    = errors.New("region is split by a chain gap or across chains")
+)
+
+// ucscNames maps assembly names to UCSC database names.
+var ucscNames = map[string]string{"GRCh37": "hg19", "GRCh38": "hg38"}
+
+// ChainFileName returns the UCSC chain file name for lifting from one
+// assembly to another, e.g. hg19ToHg38.over.chain.gz.
+func ChainFileName(from, to string) string {
+	f, t := ucscNames[from], ucscNames[to]
+	if f == "" || t == "" {
+		return ""
+	}
+	return f + "To" + strings.ToUpper(t[:1]) + t[1:] + ".over.chain.gz"
+}
+
+// ChainFileURL returns the UCSC download URL of the chain file for lifting
+// from one assembly to another.
+func ChainFileURL(from, to string) string {
+	name := ChainFileName(from, to)
+	if name == "" {
+		return ""
+	}
+	return "https://hgdownload.soe.ucsc.edu/goldenPath/" + ucscNames[from] + "/liftOver/" + name
+}
+
+// block is an ungapped alignment block of a chain, in 0-based half-open
+// source coordinates.
+type block struct {
+	start, end int64 // source interval
+	qStart     int64 // target start on the chain's target strand
+	chain      *chain
+}
+
+// chain holds the header fields of one chain needed for mapping.
+type chain struct {
+	score   int64
+	qName   string
+	qSize   int64
+	qStrand byte
+}
+
+// Region is a 1-based closed interval on the target assembly.
+type Region struct {
+	Chrom  string
+	Start  int64
+	End    int64
+	Strand int8 // +1, or -1 if the target is reverse-complemented
+}
+
+// ChainMap lifts coordinates using the blocks of a chain file, indexed by
+// source chromosome. It is read-only after loading and safe for concurrent use.
+type ChainMap struct {
+	blocks map[string][]block // sorted by start
+	maxEnd map[string][]int64 // prefix maximum of block ends
+}
+
+// Load reads a UCSC chain file, gzip-compressed or plain.
+func Load(path string) (*ChainMap, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("open chain file: %w", err)
+	}
+	defer f.Close()
+
+	br := bufio.NewReader(f)
+	var r io.Reader = br
+	if magic, err := br.Peek(2); err == nil && magic[0] == 0x1f && magic[1] == 0x8b {
+		gz, err := gzip.NewReader(br)
+		if err != nil {
+			return nil, fmt.Errorf("open chain file: %w", err)
+		}
+		defer gz.Close()
+		r = gz
+	}
+	m, err := Read(r)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return m, nil
+}
+
+// Read parses chain-format data.
+func Read(r io.Reader) (*ChainMap, error) {
+	m := &ChainMap{blocks: make(map[string][]block), maxEnd: make(map[string][]int64)}
+
+	scanner := bufio.NewScanner(r)
+	lineNum := 0
+	var cur *chain
+	var tName string
+	var tPos, qPos int64
+	for scanner.Scan() {
+		lineNum++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || line[0] == '#' {
+			continue
+		}
+		fields := strings.Fields(line)
+		if fields[0] == "chain" {
+			if len(fields) < 12 {
+				return nil, fmt.Errorf("line %d: chain header has %d fields, want 12", lineNum, len(fields))
+			}
+			nums, err := parseInts(fields[1], fields[5], fields[8], fields[10])
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", lineNum, err)
+			}
+			cur = &chain{score: nums[0], qName: fields[7], qSize: nums[2], qStrand: fields[9][0]}
+			tName = fields[2]
+			tPos, qPos = nums[1], nums[3]
+			continue
+		}
+		if cur == nil {
+			return nil, fmt.Errorf("line %d: alignment data before chain header", lineNum)
+		}
+		nums, err := parseInts(fields...)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", lineNum, err)
+		}
+		size := nums[0]
+		m.blocks[tName] = append(m.blocks[tName], block{start: tPos, end: tPos + size, qStart: qPos, chain: cur})
+		tPos += size
+		qPos += size
+		switch len(nums) {
+		case 1: // last block of the chain
+			cur = nil
+		case 3:
+			tPos += nums[1]
+			qPos += nums[2]
+		default:
+			return nil, fmt.Errorf("line %d: expected 1 or 3 numbers, got %d", lineNum, len(nums))
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("read chain file: %w", err)
+	}
+
+	for name, bs := range m.blocks {
+		sort.Slice(bs, func(i, j int) bool { return bs[i].start < bs[j].start })
+		maxEnd := make([]int64, len(bs))
+		for i, b := range bs {
+			maxEnd[i] = b.end
+			if i > 0 && maxEnd[i-1] > b.end {
+				maxEnd[i] = maxEnd[i-1]
+			}
+		}
+		m.maxEnd[name] = maxEnd
+	}
+	return m, nil
+}
+
+func parseInts(fields ...string) ([]int64, error) {
+	nums := make([]int64, len(fields))
+	for i, f := range fields {
+		n, err := strconv.ParseInt(f, 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		nums[i] = n
+	}
+	return nums, nil
+}
+
+// chromName resolves a chromosome name against the chain's source names,
+// trying it as given and with the "chr" prefix added or removed (including
+// MT ↔ chrM).
+func (m *ChainMap) chromName(chrom string) (string, bool) {
+	if _, ok := m.blocks[chrom]; ok {
+		return chrom, true
+	}
+	var alt []string
+	if bare, ok := strings.CutPrefix(chrom, "chr"); ok {
+		alt = append(alt, bare)
+		if bare == "M" {
+			alt = append(alt, "MT")
+		}
+	} else {
+		alt = append(alt, "chr"+chrom)
+		if chrom == "MT" {
+			alt = append(alt, "chrM")
+		}
+	}
+	for _, name := range alt {
+		if _, ok := m.blocks[name]; ok {
+			return name, true
+		}
+	}
+	return "", false
+}
+
+// findBlock returns the highest-scoring block containing the 0-based source
+// position pos.
+func (m *ChainMap) findBlock(name string, pos int64) *block {
+	bs, maxEnd := m.blocks[name], m.maxEnd[name]
+	i := sort.Search(len(bs), func(i int) bool { return bs[i].start > pos }) - 1
+	var best *block
+	for ; i >= 0 && maxEnd[i] > pos; i-- {
+		b := &bs[i]
+		if pos < b.end && (best == nil || b.chain.score > best.chain.score) {
+			best = b
+		}
+	}
+	return best
+}
+
+// mapPos maps a 0-based source position within b to a 0-based forward-strand
+// target position.
+func (b *block) mapPos(pos int64) int64 {
+	q := b.qStart + pos - b.start
+	if b.chain.qStrand == '-' {
+		return b.chain.qSize - 1 - q
+	}
+	return q
+}
+
+// MapRange lifts the 1-based closed interval [start, end] on chrom. Both ends
+// must lift through the same chain with the interval's length preserved;
+// otherwise ErrUnmapped or ErrSplit is returned. The target chromosome is
+// written in the input's naming style (with or without "chr").
+func (m *ChainMap) MapRange(chrom string, start, end int64) (Region, error) {
+	name, ok := m.chromName(chrom)
+	if !ok {
+		return Region{}, ErrUnmapped
+	}
+	first, last := m.findBlock(name, start-1), m.findBlock(name, end-1)
+	if first == nil || last == nil {
+		return Region{}, ErrUnmapped
+	}
+	if first.chain != last.chain {
+		return Region{}, ErrSplit
+	}
+	s, e := first.mapPos(start-1)+1, last.mapPos(end-1)+1
+	strand := int8(1)
+	if first.chain.qStrand == '-' {
+		s, e = e, s
+		strand = -1
+	}
+	if e-s != end-start {
+		return Region{}, ErrSplit
+	}
+	return Region{Chrom: targetChromName(chrom, first.chain.qName), Start: s, End: e, Strand: strand}, nil
+}
+
+// targetChromName formats a target chromosome in the style of the input name.
+func targetChromName(input, target string) string {
+	if strings.HasPrefix(input, "chr") {
+		return target
+	}
+	bare := strings.TrimPrefix(target, "chr")
+	if bare == "M" {
+		return "MT"
+	}
+	return bare
+}
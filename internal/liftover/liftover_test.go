@@ -0,0 +1,145 @@
+package liftover
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/inodb/vibe-vep/internal/annotate"
+	"github.com/inodb/vibe-vep/internal/cache"
+	"github.com/inodb/vibe-vep/internal/vcf"
+)
+
+// testChain lifts chr1 forward with a 10-base gap at source 301-310, and
+// chr2 onto the reverse strand of chr5 (source p → target 1001-p).
+const testChain = `chain 1000 chr1 1000 + 0 1000 chr1 2000 + 100 1090 1
+300 10 0
+690
+
+chain 900 chr2 500 + 0 500 chr5 1000 - 0 500 2
+500
+`
+
+// seqReference is an in-memory annotate.ReferenceSequence.
+type seqReference map[string]string
+
+func (r seqReference) Fetch(chrom string, start, end int64) (string, error) {
+	seq, ok := r[chrom]
+	if !ok || start < 1 || end > int64(len(seq)) || end < start {
+		return "", fmt.Errorf("%s:%d-%d out of range", chrom, start, end)
+	}
+	return seq[start-1 : end], nil
+}
+
+func loadTestChain(t *testing.T) *ChainMap {
+	t.Helper()
+	m, err := Read(strings.NewReader(testChain))
+	require.NoError(t, err)
+	return m
+}
+
+func TestMapRange(t *testing.T) {
+	m := loadTestChain(t)
+
+	r, err := m.MapRange("1", 11, 11)
+	require.NoError(t, err)
+	assert.Equal(t, Region{Chrom: "1", Start: 111, End: 111, Strand: 1}, r)
+
+	r, err = m.MapRange("chr1", 311, 320)
+	require.NoError(t, err)
+	assert.Equal(t, Region{Chrom: "chr1", Start: 401, End: 410, Strand: 1}, r)
+
+	r, err = m.MapRange("2", 10, 12)
+	require.NoError(t, err)
+	assert.Equal(t, Region{Chrom: "5", Start: 989, End: 991, Strand: -1}, r)
+
+	_, err = m.MapRange("1", 305, 305)
+	assert.ErrorIs(t, err, ErrUnmapped, "in chain gap")
+	_, err = m.MapRange("1", 299, 312)
+	assert.ErrorIs(t, err, ErrSplit, "spans chain gap")
+	_, err = m.MapRange("3", 1, 1)
+	assert.ErrorIs(t, err, ErrUnmapped, "no chain for chromosome")
+}
+
+func TestLiftVariant(t *testing.T) {
+	m := loadTestChain(t)
+
+	// Source chr2 and its reverse complement at chr5:501-1000.
+	var b strings.Builder
+	for i := 0; i < 500; i++ {
+		b.WriteByte("ACGTTGCAGT"[(i*7+i/10)%10])
+	}
+	src := b.String()
+	target := seqReference{"5": strings.Repeat("A", 500) + annotate.ReverseComplement(src)}
+
+	tests := []struct {
+		name string
+		in   vcf.Variant
+		want vcf.Variant
+	}{
+		{"forward SNV", vcf.Variant{Chrom: "1", Pos: 11, Ref: "C", Alt: "T"},
+			vcf.Variant{Chrom: "1", Pos: 111, Ref: "C", Alt: "T"}},
+		{"reverse SNV", vcf.Variant{Chrom: "2", Pos: 10, Ref: src[9:10], Alt: "N"},
+			vcf.Variant{Chrom: "5", Pos: 991, Ref: annotate.ReverseComplement(src[9:10]), Alt: "N"}},
+		{"reverse anchored deletion", vcf.Variant{Chrom: "2", Pos: 10, Ref: src[9:12], Alt: src[9:10]},
+			vcf.Variant{Chrom: "5", Pos: 988, Ref: target["5"][987:990], Alt: target["5"][987:988]}},
+		{"reverse anchored insertion", vcf.Variant{Chrom: "2", Pos: 10, Ref: src[9:10], Alt: src[9:10] + "AC"},
+			vcf.Variant{Chrom: "5", Pos: 990, Ref: target["5"][989:990], Alt: target["5"][989:990] + "GT"}},
+		{"reverse MAF deletion", vcf.Variant{Chrom: "2", Pos: 10, Ref: src[9:11], Alt: ""},
+			vcf.Variant{Chrom: "5", Pos: 990, Ref: annotate.ReverseComplement(src[9:11]), Alt: ""}},
+		{"reverse MAF insertion", vcf.Variant{Chrom: "2", Pos: 10, Ref: "", Alt: "AAC"},
+			vcf.Variant{Chrom: "5", Pos: 990, Ref: "", Alt: "GTT"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var ref annotate.ReferenceSequence
+			if tt.want.Chrom == "5" {
+				ref = target
+			}
+			got, err := m.LiftVariant(&tt.in, ref)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want.Chrom, got.Chrom)
+			assert.Equal(t, tt.want.Pos, got.Pos)
+			assert.Equal(t, tt.want.Ref, got.Ref)
+			assert.Equal(t, tt.want.Alt, got.Alt)
+		})
+	}
+
+	_, err := m.LiftVariant(&vcf.Variant{Chrom: "2", Pos: 10, Ref: src[9:12], Alt: src[9:10]}, nil)
+	assert.ErrorIs(t, err, ErrNeedsRef)
+
+	wrong := "A"
+	if src[9] == 'A' {
+		wrong = "C"
+	}
+	_, err = m.LiftVariant(&vcf.Variant{Chrom: "2", Pos: 10, Ref: wrong, Alt: "G"}, target)
+	assert.ErrorIs(t, err, ErrRefMismatch)
+
+	_, err = m.LiftVariant(&vcf.Variant{Chrom: "1", Pos: 100, Ref: "N", Alt: "<DEL>"}, nil)
+	assert.ErrorIs(t, err, ErrStructural)
+}
+
+func TestChainFileName(t *testing.T) {
+	assert.Equal(t, "hg19ToHg38.over.chain.gz", ChainFileName("GRCh37", "GRCh38"))
+	assert.Equal(t, "https://hgdownload.soe.ucsc.edu/goldenPath/hg38/liftOver/hg38ToHg19.over.chain.gz", ChainFileURL("GRCh38", "GRCh37"))
+	assert.Empty(t, ChainFileName("GRCh37", "mm10"))
+}
+
+func TestAnnotatorLiftover(t *testing.T) {
+	c := cache.New()
+	c.BuildIndex()
+	ann := annotate.NewAnnotator(c)
+	ann.SetLiftover(loadTestChain(t))
+
+	v := &vcf.Variant{Chrom: "2", Pos: 10, Ref: "A", Alt: "G"}
+	anns, err := ann.Annotate(v)
+	require.NoError(t, err)
+	assert.NotEmpty(t, anns)
+	assert.Equal(t, vcf.Variant{Chrom: "5", Pos: 991, Ref: "T", Alt: "C"}, *v, "lifted in place")
+
+	_, err = ann.Annotate(&vcf.Variant{Chrom: "1", Pos: 305, Ref: "A", Alt: "G"})
+	assert.ErrorIs(t, err, ErrUnmapped)
+}
@@ -0,0 +1,84 @@
+package liftover
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/inodb/vibe-vep/internal/annotate"
+	"github.com/inodb/vibe-vep/internal/vcf"
+)
+
+// Errors describing why a variant could not be lifted, in addition to
+// ErrUnmapped and ErrSplit.
+var (
+	ErrStructural  = errors.New("structural variants are not lifted")
+	ErrNeedsRef    = errors.New("anchored indel on a reverse-strand chain needs the target reference")
+	ErrRefMismatch = errors.New("REF does not match the target reference")
+)
+
+// LiftVariant returns a copy of v with coordinates on the target assembly.
+// Alleles are reverse-complemented when the chain maps to the reverse strand;
+// VCF-style indels then get a new anchor base from ref. When ref is non-nil
+// the lifted REF allele is checked against it. MAF-style alleles (empty REF
+// or ALT) keep their convention.
+func (m *ChainMap) LiftVariant(v *vcf.Variant, ref annotate.ReferenceSequence) (*vcf.Variant, error) {
+	if v.IsStructural() {
+		return nil, ErrStructural
+	}
+	insertion := v.Ref == "" || v.Ref == "-"
+
+	// Span to lift: an insertion is lifted by its two flanking bases.
+	start, end := v.Pos, v.Pos+int64(len(v.Ref))-1
+	if insertion {
+		end = v.Pos + 1
+	}
+	r, err := m.MapRange(v.Chrom, start, end)
+	if err != nil {
+		return nil, err
+	}
+
+	out := *v
+	out.Chrom, out.Pos = r.Chrom, r.Start
+	if r.Strand < 0 {
+		anchored := len(v.Ref) != len(v.Alt) && len(v.Ref) > 0 && len(v.Alt) > 0 && v.Ref[0] == v.Alt[0]
+		switch {
+		case anchored:
+			// The anchor base ends up after the variant; re-anchor on the base
+			// before the lifted core alleles.
+			if ref == nil {
+				return nil, ErrNeedsRef
+			}
+			anchor, err := ref.Fetch(r.Chrom, r.Start-1, r.Start-1)
+			if err != nil {
+				return nil, fmt.Errorf("fetch anchor base: %w", err)
+			}
+			out.Pos = r.Start - 1
+			out.Ref = anchor + reverseComplementAllele(v.Ref[1:])
+			out.Alt = anchor + reverseComplementAllele(v.Alt[1:])
+		default:
+			out.Ref = reverseComplementAllele(v.Ref)
+			out.Alt = reverseComplementAllele(v.Alt)
+		}
+	}
+
+	if ref != nil && !insertion {
+		genome, err := ref.Fetch(out.Chrom, out.Pos, out.Pos+int64(len(out.Ref))-1)
+		if err != nil {
+			return nil, fmt.Errorf("fetch target REF: %w", err)
+		}
+		if !strings.EqualFold(genome, out.Ref) {
+			return nil, fmt.Errorf("%w (%s vs %s)", ErrRefMismatch, out.Ref, genome)
+		}
+	}
+	return &out, nil
+}
+
+// reverseComplementAllele reverse-complements an allele, keeping the MAF
+// placeholders "" and "-".
+func reverseComplementAllele(allele string) string {
+	if allele == "" || allele == "-" {
+		return allele
+	}
+	return annotate.ReverseComplement(strings.ToUpper(allele))
+}
@@ -29,6 +29,7 @@ const (
 	ColHGVSc                 = "HGVSc"
 	ColVariantClassification = "Variant_Classification"
 	ColHGVSp                 = "HGVSp"
+	ColTumorSampleBarcode    = "Tumor_Sample_Barcode"
 )
 
 // ColumnIndices holds the indices of important MAF columns.
@@ -47,6 +48,7 @@ type ColumnIndices struct {
 	HGVSc                 int
 	VariantClassification int
 	HGVSp                 int
+	TumorSampleBarcode    int
 }
 
 // MAFAnnotation holds the original MAF annotation data for validation.
@@ -60,6 +62,7 @@ type MAFAnnotation struct {
 	HGVSc                 string
 	VariantClassification string
 	HGVSp                 string
+	TumorSampleBarcode    string
 	RawFields             []string // Full tab-split row for MAF output
 }
 
@@ -192,6 +195,7 @@ func (p *Parser) parseColumnIndices(headerLine string) error {
 		HGVSc:                 -1,
 		VariantClassification: -1,
 		HGVSp:                 -1,
+		TumorSampleBarcode:    -1,
 	}
 
 	for i, col := range columns {
@@ -224,6 +228,8 @@ func (p *Parser) parseColumnIndices(headerLine string) error {
 			p.columns.VariantClassification = i
 		case ColHGVSp:
 			p.columns.HGVSp = i
+		case ColTumorSampleBarcode:
+			p.columns.TumorSampleBarcode = i
 		}
 	}
 
@@ -387,6 +393,9 @@ func (p *Parser) parseLineWithAnnotation(line string) (*vcf.Variant, *MAFAnnotat
 	if p.columns.HGVSp >= 0 && p.columns.HGVSp < len(fields) {
 		ann.HGVSp = fields[p.columns.HGVSp]
 	}
+	if p.columns.TumorSampleBarcode >= 0 && p.columns.TumorSampleBarcode < len(fields) {
+		ann.TumorSampleBarcode = fields[p.columns.TumorSampleBarcode]
+	}
 
 	return v, ann, nil
 }
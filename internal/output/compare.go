@@ -17,105 +17,26 @@ func transcriptBaseID(id string) string {
 	return id
 }
 
-// SelectBestAnnotation picks the best VEP annotation to compare against a MAF entry.
+// SelectBestAnnotation picks the best VEP annotation to compare against a MAF
+// entry, using DefaultPickOrder.
 func SelectBestAnnotation(mafAnn *maf.MAFAnnotation, vepAnns []*annotate.Annotation) *annotate.Annotation {
-	var bestAnn *annotate.Annotation
-
-	// Pass 1: transcript ID match ignoring version suffix.
-	if mafAnn.TranscriptID != "" {
-		mafBase := transcriptBaseID(mafAnn.TranscriptID)
-		for _, ann := range vepAnns {
-			if transcriptBaseID(ann.TranscriptID) == mafBase {
-				if isCodingConsequence(mafAnn.Consequence) && !isProteinCodingBiotype(ann.Biotype) {
-					break
-				}
-				return ann
-			}
-		}
-	}
-
-	// Pass 2: same gene, prefer protein-coding > canonical > higher impact
-	var sameGene *annotate.Annotation
-	if mafAnn.HugoSymbol != "" {
-		for _, ann := range vepAnns {
-			if ann.GeneName == mafAnn.HugoSymbol {
-				if sameGene == nil || AnnotationBetter(ann, sameGene) {
-					sameGene = ann
-				}
-			}
-		}
-	}
-	if sameGene != nil {
-		return sameGene
-	}
-
-	// Pass 3: prefer protein-coding > canonical > higher impact
-	for _, ann := range vepAnns {
-		if bestAnn == nil || AnnotationBetter(ann, bestAnn) {
-			bestAnn = ann
-		}
-	}
-	return bestAnn
+	return DefaultPickOrder.SelectBest(mafAnn, vepAnns)
 }
 
 // PickBestAnnotation selects the best annotation without MAF context.
 func PickBestAnnotation(anns []*annotate.Annotation) *annotate.Annotation {
-	if len(anns) == 0 {
-		return nil
-	}
-	best := anns[0]
-	for _, ann := range anns[1:] {
-		if AnnotationBetter(ann, best) {
-			best = ann
-		}
-	}
-	return best
+	return DefaultPickOrder.Pick(anns)
 }
 
 // PickMostSevere selects the highest-impact annotation.
 func PickMostSevere(anns []*annotate.Annotation) *annotate.Annotation {
-	if len(anns) == 0 {
-		return nil
-	}
-	best := anns[0]
-	for _, ann := range anns[1:] {
-		annImpact := annotate.ImpactRank(ann.Impact)
-		bestImpact := annotate.ImpactRank(best.Impact)
-		if annImpact > bestImpact {
-			best = ann
-		} else if annImpact == bestImpact {
-			if ann.IsCanonicalMSK && !best.IsCanonicalMSK {
-				best = ann
-			} else if ann.IsCanonicalMSK == best.IsCanonicalMSK {
-				if isProteinCodingBiotype(ann.Biotype) && !isProteinCodingBiotype(best.Biotype) {
-					best = ann
-				}
-			}
-		}
-	}
-	return best
+	return PickOrder(nil).MostSevere(anns)
 }
 
 // AnnotationBetter returns true if ann is a better pick than current for comparison.
 // Priority order: protein-coding biotype > canonical > impact > has HGVSp.
 func AnnotationBetter(ann, current *annotate.Annotation) bool {
-	annCoding := isProteinCodingBiotype(ann.Biotype)
-	curCoding := isProteinCodingBiotype(current.Biotype)
-	if annCoding != curCoding {
-		return annCoding
-	}
-	if ann.IsCanonicalMSK != current.IsCanonicalMSK {
-		return ann.IsCanonicalMSK
-	}
-	annImpact := annotate.ImpactRank(ann.Impact)
-	curImpact := annotate.ImpactRank(current.Impact)
-	if annImpact != curImpact {
-		return annImpact > curImpact
-	}
-	if (ann.HGVSp != "") != (current.HGVSp != "") {
-		return ann.HGVSp != ""
-	}
-	return false
+	return DefaultPickOrder.Better(ann, current)
 }
 
 // isProteinCodingBiotype returns true if the biotype has coding potential.
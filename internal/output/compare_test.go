@@ -481,8 +481,8 @@ func TestValidateExcludeColumns(t *testing.T) {
 
 func TestValidOutputColumns(t *testing.T) {
 	cols := ValidOutputColumns()
-	// 10 core + all_effects = 11
-	assert.Len(t, cols, 11)
+	// 11 core + all_effects = 12
+	assert.Len(t, cols, 12)
 	assert.True(t, cols["hugo_symbol"])
 	assert.True(t, cols["all_effects"])
 	assert.False(t, cols["not_a_column"])
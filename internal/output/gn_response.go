@@ -19,6 +19,10 @@ type GNMarshalOptions struct {
 	IncludeSignal            bool
 	IncludeMyVariantInfo     bool
 
+	// PickOrder, when set, chooses the transcript summarized in
+	// annotation_summary instead of the highest-impact Ensembl canonical one.
+	PickOrder PickOrder
+
 	// MyVariantInfoData holds pre-fetched myvariant.info data to include in the response.
 	// The handler populates this before calling MarshalGNAnnotation.
 	MyVariantInfoData *GNMyVariantInfoAnnotation
@@ -27,6 +31,11 @@ type GNMarshalOptions struct {
 // MarshalGNAnnotation builds a GNAnnotation from a variant and its annotations,
 // then marshals it to JSON. This produces a genome-nexus compatible response.
 func MarshalGNAnnotation(input string, v *vcf.Variant, anns []*annotate.Annotation, assembly string, opts ...GNMarshalOptions) ([]byte, error) {
+	var opt GNMarshalOptions
+	if len(opts) > 0 {
+		opt = opts[0]
+	}
+
 	ref, alt := alleleStrings(v)
 
 	end := v.Pos + int64(len(v.Ref)) - 1
@@ -68,6 +77,9 @@ func MarshalGNAnnotation(input string, v *vcf.Variant, anns []*annotate.Annotati
 			canonicalImpact = impact
 		}
 	}
+	if len(opt.PickOrder) > 0 && len(anns) > 0 {
+		canonicalAnn = opt.PickOrder.Pick(anns)
+	}
 
 	for _, ann := range anns {
 		canonical := ""
@@ -121,10 +133,6 @@ func MarshalGNAnnotation(input string, v *vcf.Variant, anns []*annotate.Annotati
 	sortCanonicalFirst(result.TranscriptConsequences)
 
 	// Build optional enrichments.
-	var opt GNMarshalOptions
-	if len(opts) > 0 {
-		opt = opts[0]
-	}
 	if opt.IncludeAnnotationSummary {
 		result.AnnotationSummary = buildAnnotationSummary(variant, v, anns, canonicalAnn, assembly)
 	}
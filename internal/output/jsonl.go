@@ -4,6 +4,7 @@ import (
 	"bufio"
 	"encoding/json"
 	"io"
+	"slices"
 	"strconv"
 	"strings"
 
@@ -42,11 +43,31 @@ type VEPTranscriptConsequence struct {
 	Exon   string `json:"exon,omitempty"`
 	Intron string `json:"intron,omitempty"`
 
+	// Distance to transcript for upstream/downstream variants
+	Distance int64 `json:"distance,omitempty"`
+
+	// Nearest gene for intergenic variants (nearest mode)
+	NearestGene         string `json:"nearest_gene,omitempty"`
+	NearestTranscriptID string `json:"nearest_transcript_id,omitempty"`
+	NearestDistance     int64  `json:"nearest_distance,omitempty"`
+
 	// Predictions
 	SIFTScore          *float64 `json:"sift_score,omitempty"`
 	SIFTPrediction     string   `json:"sift_prediction,omitempty"`
 	PolyPhenScore      *float64 `json:"polyphen_score,omitempty"`
 	PolyPhenPrediction string   `json:"polyphen_prediction,omitempty"`
+
+	// Pick is 1 on the consequence chosen by the pick order (flag_pick).
+	Pick int `json:"pick,omitempty"`
+
+	// Names of the user transcript sets listing the transcript
+	TranscriptSets []string `json:"transcript_sets,omitempty"`
+
+	// Combined change of in-cis SNVs in the same codon
+	MergedConsequence string   `json:"merged_consequence,omitempty"`
+	MergedCodons      string   `json:"merged_codons,omitempty"`
+	MergedHGVSp       string   `json:"merged_hgvsp,omitempty"`
+	MergedWith        []string `json:"merged_with,omitempty"`
 }
 
 // VEPVariantAnnotation represents the top-level VEP JSON output for one variant.
@@ -69,6 +90,9 @@ type VibeVepTranscriptConsequence struct {
 	TranscriptID          string            `json:"transcript_id"`
 	HugoSymbol            string            `json:"hugo_symbol,omitempty"`
 	GeneID                string            `json:"gene_id,omitempty"`
+	EntrezGeneID          string            `json:"entrez_gene_id,omitempty"`
+	HGNCID                string            `json:"hgnc_id,omitempty"`
+	ProteinID             string            `json:"protein_id,omitempty"`
 	Consequence           string            `json:"consequence"`
 	Impact                string            `json:"impact"`
 	VariantClassification string            `json:"variant_classification,omitempty"`
@@ -86,21 +110,40 @@ type VibeVepTranscriptConsequence struct {
 	CanonicalMSKCC        bool              `json:"canonical_mskcc,omitempty"`
 	CanonicalEnsembl      bool              `json:"canonical_ensembl,omitempty"`
 	CanonicalMANE         bool              `json:"canonical_mane,omitempty"`
+	TranscriptSets        []string          `json:"transcript_sets,omitempty"`
+	MergedConsequence     string            `json:"merged_consequence,omitempty"`
+	MergedCodons          string            `json:"merged_codons,omitempty"`
+	MergedHGVSp           string            `json:"merged_hgvsp,omitempty"`
+	MergedWith            []string          `json:"merged_with,omitempty"`
+	Distance              int64             `json:"distance,omitempty"`
+	NearestGene           string            `json:"nearest_gene,omitempty"`
+	NearestTranscriptID   string            `json:"nearest_transcript_id,omitempty"`
+	NearestDistance       int64             `json:"nearest_distance,omitempty"`
+	PeptideMD5            string            `json:"peptide_md5,omitempty"`
 	Extra                 map[string]string `json:"extra,omitempty"`
 }
 
-// VibeVepVariantAnnotation is the top-level vibe-vep native JSON output.
+// VibeVepVariantAnnotation is the top-level vibe-vep native JSON output, as
+// written by the vibe-vep-jsonl format and returned by the server's /v1 API.
 type VibeVepVariantAnnotation struct {
-	Input                  string                          `json:"input"`
-	Chromosome             string                          `json:"chromosome"`
-	Start                  int64                           `json:"start"`
-	End                    int64                           `json:"end"`
-	ReferenceAllele        string                          `json:"reference_allele"`
-	VariantAllele          string                          `json:"variant_allele"`
-	Assembly               string                          `json:"assembly"`
-	MostSevereConsequence  string                          `json:"most_severe_consequence"`
-	TranscriptConsequences []VibeVepTranscriptConsequence  `json:"transcript_consequences"`
-	Warnings               []string                        `json:"warnings,omitempty"`
+	Input                  string                         `json:"input"`
+	Chromosome             string                         `json:"chromosome"`
+	Start                  int64                          `json:"start"`
+	End                    int64                          `json:"end"`
+	ReferenceAllele        string                         `json:"reference_allele"`
+	VariantAllele          string                         `json:"variant_allele"`
+	Assembly               string                         `json:"assembly"`
+	MostSevereConsequence  string                         `json:"most_severe_consequence"`
+	TranscriptConsequences []VibeVepTranscriptConsequence `json:"transcript_consequences"`
+	Warnings               []string                       `json:"warnings,omitempty"`
+	OriginalVariant        string                         `json:"original_variant,omitempty"` // chrom:pos:ref/alt before left-alignment
+}
+
+// VibeVepError is written in place of a VibeVepVariantAnnotation for an
+// input that could not be parsed, resolved or annotated.
+type VibeVepError struct {
+	Error string `json:"error"`
+	Input string `json:"input"`
 }
 
 // JSONLWriter writes annotations in JSONL format (one JSON line per variant).
@@ -165,14 +208,35 @@ func (j *JSONLWriter) Flush() error {
 	return j.w.Flush()
 }
 
+// refMismatchWarning is added to variants whose REF allele does not match
+// the reference genome.
+const refMismatchWarning = "REF allele does not match the reference genome"
+
+// annotationWarnings returns warnings plus those raised by the annotations:
+// a REF mismatch, and transcripts annotated against the genome sequence
+// because their own sequence differs from it.
+func annotationWarnings(warnings []string, anns []*annotate.Annotation) []string {
+	if hasRefMismatch(anns) {
+		warnings = append(slices.Clip(warnings), refMismatchWarning)
+	}
+	for _, ann := range anns {
+		if ann.GenomeMismatch {
+			warnings = append(slices.Clip(warnings), "transcript "+ann.TranscriptID+
+				" sequence differs from the reference genome; the genome sequence was used")
+		}
+	}
+	return warnings
+}
+
 func (j *JSONLWriter) flushVariant() error {
 	var line []byte
 	var err error
 
 	switch j.format {
 	case "vibe-vep-jsonl":
-		line, err = j.marshalVibeVep()
+		line, err = json.Marshal(NewVibeVepVariantAnnotation(j.input, j.curVariant, j.curAnns, j.assembly, j.warnings))
 	default: // ensembl-vep-jsonl
+		j.warnings = annotationWarnings(j.warnings, j.curAnns)
 		line, err = j.marshalVEP()
 	}
 	if err != nil {
@@ -198,7 +262,7 @@ func (j *JSONLWriter) marshalVEP() ([]byte, error) {
 	v := j.curVariant
 	ref, alt := alleleStrings(v)
 
-	end := v.Pos + int64(len(v.Ref)) - 1
+	end := v.End()
 	if end < v.Pos {
 		end = v.Pos // insertions
 	}
@@ -246,6 +310,17 @@ func (j *JSONLWriter) marshalVEP() ([]byte, error) {
 			HGVSp:            ann.HGVSp,
 			Exon:             ann.ExonNumber,
 			Intron:           ann.IntronNumber,
+			Distance:         ann.Distance,
+			TranscriptSets:   ann.TranscriptSets,
+
+			MergedConsequence: ann.MergedConsequence,
+			MergedCodons:      ann.MergedCodonChange,
+			MergedHGVSp:       ann.MergedHGVSp,
+			MergedWith:        ann.MergedWith,
+
+			NearestGene:         ann.NearestGene,
+			NearestTranscriptID: ann.NearestTranscriptID,
+			NearestDistance:     ann.NearestDistance,
 		}
 
 		// SIFT/PolyPhen from annotation source extras.
@@ -270,27 +345,33 @@ func (j *JSONLWriter) marshalVEP() ([]byte, error) {
 	return json.Marshal(result)
 }
 
-func (j *JSONLWriter) marshalVibeVep() ([]byte, error) {
-	v := j.curVariant
+// NewVibeVepVariantAnnotation builds the vibe-vep native output for a
+// variant and its annotations. warnings are those of the input (e.g. a
+// substituted transcript version); a REF mismatch warning is added when the
+// annotations carry one.
+func NewVibeVepVariantAnnotation(input string, v *vcf.Variant, anns []*annotate.Annotation, assembly string, warnings []string) *VibeVepVariantAnnotation {
 	ref, alt := alleleStrings(v)
 
-	end := v.Pos + int64(len(v.Ref)) - 1
+	end := v.End()
 	if end < v.Pos {
 		end = v.Pos
 	}
 
-	result := VibeVepVariantAnnotation{
-		Input:           j.input,
+	result := &VibeVepVariantAnnotation{
+		Input:           input,
 		Chromosome:      v.Chrom,
 		Start:           v.Pos,
 		End:             end,
 		ReferenceAllele: ref,
 		VariantAllele:   alt,
-		Assembly:        j.assembly,
+		Assembly:        assembly,
+	}
+	if v.Original != nil {
+		result.OriginalVariant = formatAlleleKey(v.Chrom, v.Original.Pos, v.Original.Ref, v.Original.Alt)
 	}
 
 	bestImpact := -1
-	for _, ann := range j.curAnns {
+	for _, ann := range anns {
 		impact := annotate.ImpactRank(ann.Impact)
 		if impact > bestImpact {
 			bestImpact = impact
@@ -298,42 +379,54 @@ func (j *JSONLWriter) marshalVibeVep() ([]byte, error) {
 		}
 	}
 
-	for _, ann := range j.curAnns {
+	for _, ann := range anns {
 		tc := VibeVepTranscriptConsequence{
 			TranscriptID:          ann.TranscriptID,
 			HugoSymbol:            ann.GeneName,
 			GeneID:                ann.GeneID,
+			EntrezGeneID:          ann.EntrezGeneID,
+			HGNCID:                ann.HGNCId,
+			ProteinID:             ann.ProteinID,
 			Consequence:           ann.Consequence,
 			Impact:                ann.Impact,
 			VariantClassification: SOToMAFClassification(ann.Consequence, v),
-			HGVSc:                ann.HGVSc,
-			HGVSp:                ann.HGVSp,
-			HGVSpShort:           HGVSpToShort(ann.HGVSp),
-			ProteinPosition:      ann.ProteinPosition,
-			CDSPosition:          ann.CDSPosition,
-			CDNAPosition:         ann.CDNAPosition,
-			AminoAcidChange:      ann.AminoAcidChange,
-			Codons:               ann.CodonChange,
-			Exon:                 ann.ExonNumber,
-			Intron:               ann.IntronNumber,
-			Biotype:              ann.Biotype,
-			CanonicalMSKCC:       ann.IsCanonicalMSK,
-			CanonicalEnsembl:     ann.IsCanonicalEnsembl,
-			CanonicalMANE:        ann.IsMANESelect,
-			Extra:                ann.Extra,
+			HGVSc:                 ann.HGVSc,
+			HGVSp:                 ann.HGVSp,
+			HGVSpShort:            HGVSpToShort(ann.HGVSp),
+			ProteinPosition:       ann.ProteinPosition,
+			CDSPosition:           ann.CDSPosition,
+			CDNAPosition:          ann.CDNAPosition,
+			AminoAcidChange:       ann.AminoAcidChange,
+			Codons:                ann.CodonChange,
+			Exon:                  ann.ExonNumber,
+			Intron:                ann.IntronNumber,
+			Biotype:               ann.Biotype,
+			CanonicalMSKCC:        ann.IsCanonicalMSK,
+			CanonicalEnsembl:      ann.IsCanonicalEnsembl,
+			CanonicalMANE:         ann.IsMANESelect,
+			TranscriptSets:        ann.TranscriptSets,
+			MergedConsequence:     ann.MergedConsequence,
+			MergedCodons:          ann.MergedCodonChange,
+			MergedHGVSp:           ann.MergedHGVSp,
+			MergedWith:            ann.MergedWith,
+			Distance:              ann.Distance,
+			NearestGene:           ann.NearestGene,
+			NearestTranscriptID:   ann.NearestTranscriptID,
+			NearestDistance:       ann.NearestDistance,
+			PeptideMD5:            ann.PeptideMD5,
+			Extra:                 ann.Extra,
 		}
 		result.TranscriptConsequences = append(result.TranscriptConsequences, tc)
 	}
 
-	result.Warnings = j.warnings
+	result.Warnings = annotationWarnings(warnings, anns)
 
-	return json.Marshal(result)
+	return result
 }
 
 // WriteError writes an error as a JSON line to stdout.
 func (j *JSONLWriter) WriteError(input string, errMsg string) error {
-	obj := map[string]string{"error": errMsg, "input": input}
-	line, err := json.Marshal(obj)
+	line, err := json.Marshal(VibeVepError{Error: errMsg, Input: input})
 	if err != nil {
 		return err
 	}
@@ -371,13 +464,32 @@ func firstConsequence(c string) string {
 	return c
 }
 
+// stripVersion removes the version from an Ensembl ID (ENST00000311936.8 →
+// ENST00000311936). RefSeq accessions (NM_004985.5) keep their version,
+// which is part of how they are cited in clinical reports.
 func stripVersion(id string) string {
+	if isRefSeqAccession(id) {
+		return id
+	}
 	if i := strings.IndexByte(id, '.'); i >= 0 {
 		return id[:i]
 	}
 	return id
 }
 
+// isRefSeqAccession reports whether id is a RefSeq transcript or protein
+// accession such as NM_004985.5 or NP_004976.2.
+func isRefSeqAccession(id string) bool {
+	if len(id) < 4 || id[2] != '_' {
+		return false
+	}
+	switch id[:2] {
+	case "NM", "NR", "NP", "XM", "XR", "XP":
+		return true
+	}
+	return false
+}
+
 // formatAminoAcidsVEP converts "G12C" to "G/C" (VEP format).
 func formatAminoAcidsVEP(change string) string {
 	if len(change) < 2 {
@@ -385,4 +497,3 @@ func formatAminoAcidsVEP(change string) string {
 	}
 	return change[:1] + "/" + change[len(change)-1:]
 }
-
@@ -3,6 +3,7 @@ package output
 import (
 	"bytes"
 	"encoding/json"
+	"strings"
 	"testing"
 
 	"github.com/inodb/vibe-vep/internal/annotate"
@@ -154,6 +155,126 @@ func TestJSONLWriterVibeVep(t *testing.T) {
 	}
 }
 
+func TestJSONLWriterDistance(t *testing.T) {
+	for _, format := range []string{"vep-jsonl", "vibe-vep-jsonl"} {
+		var buf bytes.Buffer
+		w := NewJSONLWriter(&buf, format, "GRCh38")
+		w.SetInput("12,25251929,25251929,A,G")
+
+		v := &vcf.Variant{Chrom: "12", Pos: 25251929, Ref: "A", Alt: "G"}
+		ann := &annotate.Annotation{
+			TranscriptID: "ENST00000311936.8",
+			GeneName:     "KRAS",
+			Consequence:  "upstream_gene_variant",
+			Impact:       "MODIFIER",
+			Allele:       "G",
+			Distance:     1000,
+		}
+		if err := w.Write(v, ann); err != nil {
+			t.Fatal(err)
+		}
+		if err := w.Flush(); err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(buf.String(), `"distance":1000`) {
+			t.Errorf("%s: missing distance field: %s", format, buf.String())
+		}
+	}
+}
+
+func TestJSONLWriterOriginalVariant(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewJSONLWriter(&buf, "vibe-vep-jsonl", "GRCh38")
+
+	v := &vcf.Variant{Chrom: "1", Pos: 11, Ref: "AT", Alt: "A", Original: &vcf.OriginalAllele{Pos: 17, Ref: "TT", Alt: "T"}}
+	ann := &annotate.Annotation{Consequence: "intergenic_variant", Impact: "MODIFIER", Allele: "A"}
+	if err := w.Write(v, ann); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	var result VibeVepVariantAnnotation
+	if err := json.Unmarshal(buf.Bytes(), &result); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	if result.Start != 11 {
+		t.Errorf("start=%d, want normalized 11", result.Start)
+	}
+	if result.OriginalVariant != "1:17:TT/T" {
+		t.Errorf("original_variant=%q, want %q", result.OriginalVariant, "1:17:TT/T")
+	}
+}
+
+func TestJSONLWriterRefMismatchWarning(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewJSONLWriter(&buf, "ensembl-vep-jsonl", "GRCh38")
+
+	v := &vcf.Variant{Chrom: "1", Pos: 100, Ref: "C", Alt: "T"}
+	ann := &annotate.Annotation{Consequence: "intergenic_variant", Impact: "MODIFIER", Allele: "T", RefMismatch: true}
+	if err := w.Write(v, ann); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	var result VEPVariantAnnotation
+	if err := json.Unmarshal(buf.Bytes(), &result); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	if len(result.Warnings) != 1 || result.Warnings[0] != refMismatchWarning {
+		t.Errorf("warnings=%v, want [%q]", result.Warnings, refMismatchWarning)
+	}
+}
+
+func TestAnnotationWarnings_GenomeMismatch(t *testing.T) {
+	anns := []*annotate.Annotation{
+		{TranscriptID: "NM_000001.1"},
+		{TranscriptID: "NM_000002.1", GenomeMismatch: true},
+	}
+	got := annotationWarnings(nil, anns)
+	want := "transcript NM_000002.1 sequence differs from the reference genome; the genome sequence was used"
+	if len(got) != 1 || got[0] != want {
+		t.Errorf("warnings=%v, want [%q]", got, want)
+	}
+}
+
+func TestNewVibeVepVariantAnnotation(t *testing.T) {
+	v := &vcf.Variant{Chrom: "12", Pos: 25245350, Ref: "C", Alt: "A"}
+	ann := &annotate.Annotation{
+		TranscriptID: "ENST00000311936.8",
+		GeneName:     "KRAS",
+		ProteinID:    "ENSP00000256078",
+		HGNCId:       "HGNC:6407",
+		EntrezGeneID: "3845",
+		Consequence:  "missense_variant",
+		Impact:       "MODERATE",
+		IsMANESelect: true,
+		PeptideMD5:   "0123456789abcdef0123456789abcdef",
+		RefMismatch:  true,
+		Extra:        map[string]string{"alphamissense.score": "0.99"},
+	}
+	warnings := make([]string, 1, 2)
+	warnings[0] = "transcript version substituted"
+
+	result := NewVibeVepVariantAnnotation("12:25245350:C:A", v, []*annotate.Annotation{ann}, "GRCh38", warnings)
+	if len(result.Warnings) != 2 || result.Warnings[1] != refMismatchWarning {
+		t.Errorf("warnings=%v, want the input warning and %q", result.Warnings, refMismatchWarning)
+	}
+	if len(warnings[:cap(warnings)][1]) != 0 {
+		t.Error("caller's warnings slice was modified")
+	}
+	tc := result.TranscriptConsequences[0]
+	if tc.ProteinID != ann.ProteinID || tc.HGNCID != ann.HGNCId || tc.EntrezGeneID != ann.EntrezGeneID {
+		t.Errorf("ids: got %q/%q/%q", tc.ProteinID, tc.HGNCID, tc.EntrezGeneID)
+	}
+	if !tc.CanonicalMANE || tc.PeptideMD5 != ann.PeptideMD5 || tc.Extra["alphamissense.score"] != "0.99" {
+		t.Errorf("unexpected consequence: %+v", tc)
+	}
+}
+
 func TestJSONLWriterError(t *testing.T) {
 	var buf bytes.Buffer
 	w := NewJSONLWriter(&buf, "ensembl-vep-jsonl", "GRCh38")
@@ -263,3 +384,18 @@ func TestJSONLWriterSIFTPolyPhen(t *testing.T) {
 		t.Errorf("polyphen_prediction=%q, want %q", tc.PolyPhenPrediction, "probably_damaging")
 	}
 }
+
+func TestStripVersion(t *testing.T) {
+	tests := map[string]string{
+		"ENST00000311936.8": "ENST00000311936",
+		"ENST00000311936":   "ENST00000311936",
+		"NM_004985.5":       "NM_004985.5", // RefSeq keeps its version
+		"NR_024540.1":       "NR_024540.1",
+		"":                  "",
+	}
+	for in, want := range tests {
+		if got := stripVersion(in); got != want {
+			t.Errorf("stripVersion(%q)=%q, want %q", in, got, want)
+		}
+	}
+}
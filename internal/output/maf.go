@@ -3,9 +3,11 @@ package output
 import (
 	"bufio"
 	"io"
+	"strconv"
 	"strings"
 
 	"github.com/inodb/vibe-vep/internal/annotate"
+	"github.com/inodb/vibe-vep/internal/filter"
 	"github.com/inodb/vibe-vep/internal/maf"
 	"github.com/inodb/vibe-vep/internal/vcf"
 )
@@ -20,6 +22,13 @@ type MAFWriter struct {
 	sources    []annotate.AnnotationSource
 	sourceKeys []string // pre-built Extra map keys for source columns
 	replace    bool
+	nearest    bool // append nearest-gene columns
+	reportNorm bool // append the normalized variant column
+	refCheck   bool // append the REF mismatch column
+	transcriptSets []string // user transcript sets, one column each
+	mergeCodons bool // append the merged codon columns
+	filter      *filter.Expr    // rows to write, nil for all
+	columnNames *filter.Columns // output header, for filtering
 	excludeCols map[string]bool // columns to exclude from output
 }
 
@@ -54,6 +63,46 @@ func (m *MAFWriter) SetReplace(replace bool) {
 	m.replace = replace
 }
 
+// SetNearest appends nearest_gene, nearest_transcript_id and nearest_distance
+// columns (populated for intergenic variants) after the core columns.
+func (m *MAFWriter) SetNearest(nearest bool) {
+	m.nearest = nearest
+}
+
+// SetReportNormalization appends a normalized_variant column holding the
+// left-aligned chrom:pos:ref/alt for rows whose variant was changed by
+// normalization. The original form remains in the input columns.
+func (m *MAFWriter) SetReportNormalization(report bool) {
+	m.reportNorm = report
+}
+
+// SetRefCheck appends a ref_mismatch column set to "YES" for rows whose
+// Reference_Allele does not match the reference genome.
+func (m *MAFWriter) SetRefCheck(check bool) {
+	m.refCheck = check
+}
+
+// SetTranscriptSets appends a transcript_set_<name> column per user
+// transcript set, set to "YES" for rows whose transcript is in the set.
+func (m *MAFWriter) SetTranscriptSets(names []string) {
+	m.transcriptSets = names
+}
+
+// SetMergeCodons appends the merged_* columns describing the combined change
+// of in-cis SNVs in the same codon (see annotate.CodonMerger).
+func (m *MAFWriter) SetMergeCodons(merge bool) {
+	m.mergeCodons = merge
+}
+
+// SetFilter writes only the rows matching expr, evaluated over the output
+// columns.
+func (m *MAFWriter) SetFilter(expr *filter.Expr) {
+	m.filter = expr
+}
+
+// nearestColumns are the columns written when nearest mode is enabled.
+var nearestColumns = []string{"nearest_gene", "nearest_transcript_id", "nearest_distance"}
+
 // WriteHeader writes the MAF header line.
 // In default mode, appends vibe.* core columns + source columns.
 // In replace mode, keeps original header unchanged and appends only source columns.
@@ -70,6 +119,50 @@ func (m *MAFWriter) WriteHeader() error {
 		}
 	}
 
+	if m.nearest {
+		for _, col := range nearestColumns {
+			if m.replace {
+				header += "\t" + col
+			} else {
+				header += "\tvibe." + col
+			}
+		}
+	}
+
+	if m.reportNorm {
+		if m.replace {
+			header += "\tnormalized_variant"
+		} else {
+			header += "\tvibe.normalized_variant"
+		}
+	}
+
+	if m.refCheck {
+		if m.replace {
+			header += "\tref_mismatch"
+		} else {
+			header += "\tvibe.ref_mismatch"
+		}
+	}
+
+	for _, name := range m.transcriptSets {
+		if m.replace {
+			header += "\t" + TranscriptSetColumn(name)
+		} else {
+			header += "\tvibe." + TranscriptSetColumn(name)
+		}
+	}
+
+	if m.mergeCodons {
+		for _, col := range MergedCodonColumns {
+			if m.replace {
+				header += "\t" + col
+			} else {
+				header += "\tvibe." + col
+			}
+		}
+	}
+
 	// all_effects column (before source columns)
 	if !m.excludeCols["all_effects"] {
 		if m.replace {
@@ -99,6 +192,7 @@ func (m *MAFWriter) WriteHeader() error {
 		}
 	}
 
+	m.columnNames = filter.NewColumns(strings.Split(header, "\t"))
 	_, err := m.w.WriteString(header + "\n")
 	return err
 }
@@ -115,7 +209,7 @@ func (m *MAFWriter) WriteRow(rawFields []string, ann *annotate.Annotation, allAn
 
 // writeRowAppend writes a row in default (append) mode.
 func (m *MAFWriter) writeRowAppend(rawFields []string, ann *annotate.Annotation, allAnns []*annotate.Annotation, v *vcf.Variant) error {
-	row := make([]string, len(rawFields), len(rawFields)+10+len(m.sources)*3)
+	row := make([]string, len(rawFields), len(rawFields)+len(annotate.CoreColumns)+1+len(m.sources)*3)
 	copy(row, rawFields)
 
 	// Core prediction columns — each guarded by excludeCols
@@ -126,6 +220,19 @@ func (m *MAFWriter) writeRowAppend(rawFields []string, ann *annotate.Annotation,
 		}
 		row = append(row, coreValues[i])
 	}
+	if m.nearest {
+		row = appendNearest(row, ann)
+	}
+	if m.reportNorm {
+		row = append(row, normalizedVariant(v))
+	}
+	if m.refCheck {
+		row = append(row, refMismatchValue(ann))
+	}
+	row = appendTranscriptSets(row, ann, m.transcriptSets)
+	if m.mergeCodons {
+		row = appendMergedCodon(row, ann, ",")
+	}
 
 	// all_effects column
 	if !m.excludeCols["all_effects"] {
@@ -141,8 +248,7 @@ func (m *MAFWriter) writeRowAppend(rawFields []string, ann *annotate.Annotation,
 		row = append(row, val)
 	}
 
-	_, err := m.w.WriteString(strings.Join(row, "\t") + "\n")
-	return err
+	return m.writeFields(row)
 }
 
 // writeRowReplace writes a row in replace mode, overwriting core columns in-place.
@@ -174,6 +280,19 @@ func (m *MAFWriter) writeRowReplace(rawFields []string, ann *annotate.Annotation
 			setIfPresent(row, m.columns.HGVSpShort, HGVSpToShort(ann.HGVSp))
 		}
 	}
+	if m.nearest {
+		row = appendNearest(row, ann)
+	}
+	if m.reportNorm {
+		row = append(row, normalizedVariant(v))
+	}
+	if m.refCheck {
+		row = append(row, refMismatchValue(ann))
+	}
+	row = appendTranscriptSets(row, ann, m.transcriptSets)
+	if m.mergeCodons {
+		row = appendMergedCodon(row, ann, ",")
+	}
 
 	// all_effects column
 	if !m.excludeCols["all_effects"] {
@@ -189,15 +308,23 @@ func (m *MAFWriter) writeRowReplace(rawFields []string, ann *annotate.Annotation
 		row = append(row, val)
 	}
 
+	return m.writeFields(row)
+}
+
+// writeFields writes a row unless it fails the filter.
+func (m *MAFWriter) writeFields(row []string) error {
+	if m.filter != nil && !m.filter.Match(m.columnNames.Row(row)) {
+		return nil
+	}
 	_, err := m.w.WriteString(strings.Join(row, "\t") + "\n")
 	return err
 }
 
-// coreValues returns the 10 core column values for an annotation.
+// coreValues returns the 11 core column values for an annotation.
 // The order matches annotate.CoreColumns.
-func (m *MAFWriter) coreValues(ann *annotate.Annotation, v *vcf.Variant) [10]string {
+func (m *MAFWriter) coreValues(ann *annotate.Annotation, v *vcf.Variant) [11]string {
 	if ann == nil {
-		return [10]string{}
+		return [11]string{}
 	}
 	canonMSK := ""
 	if ann.IsCanonicalMSK {
@@ -211,7 +338,11 @@ func (m *MAFWriter) coreValues(ann *annotate.Annotation, v *vcf.Variant) [10]str
 	if ann.IsMANESelect {
 		canonMANE = "YES"
 	}
-	return [10]string{
+	distance := ""
+	if ann.Distance > 0 {
+		distance = strconv.FormatInt(ann.Distance, 10)
+	}
+	return [11]string{
 		ann.GeneName,                              // hugo_symbol
 		ann.Consequence,                           // consequence
 		SOToMAFClassification(ann.Consequence, v), // variant_classification
@@ -222,9 +353,64 @@ func (m *MAFWriter) coreValues(ann *annotate.Annotation, v *vcf.Variant) [10]str
 		canonMSK,                                   // canonical_mskcc
 		canonEns,                                   // canonical_ensembl
 		canonMANE,                                  // canonical_mane
+		distance,                                   // distance
 	}
 }
 
+// appendNearest appends the nearest-gene column values for an annotation.
+func appendNearest(row []string, ann *annotate.Annotation) []string {
+	if ann == nil || ann.NearestTranscriptID == "" {
+		return append(row, "", "", "")
+	}
+	return append(row, ann.NearestGene, ann.NearestTranscriptID, strconv.FormatInt(ann.NearestDistance, 10))
+}
+
+// normalizedVariant returns the variant as chrom:pos:ref/alt if normalization
+// changed it, or "".
+func normalizedVariant(v *vcf.Variant) string {
+	if v == nil || v.Original == nil {
+		return ""
+	}
+	return formatAlleleKey(v.Chrom, v.Pos, v.Ref, v.Alt)
+}
+
+// refMismatchValue returns "YES" if the annotation flags a REF mismatch.
+func refMismatchValue(ann *annotate.Annotation) string {
+	if ann != nil && ann.RefMismatch {
+		return "YES"
+	}
+	return ""
+}
+
+// TranscriptSetColumn returns the output column name of a user transcript set.
+func TranscriptSetColumn(name string) string {
+	return "transcript_set_" + name
+}
+
+// appendTranscriptSets appends "YES" or "" for each named transcript set.
+func appendTranscriptSets(row []string, ann *annotate.Annotation, names []string) []string {
+	for _, name := range names {
+		val := ""
+		if ann != nil && ann.InTranscriptSet(name) {
+			val = "YES"
+		}
+		row = append(row, val)
+	}
+	return row
+}
+
+// MergedCodonColumns are the columns written when codon merging is enabled.
+var MergedCodonColumns = []string{"merged_consequence", "merged_codons", "merged_hgvsp", "merged_with"}
+
+// appendMergedCodon appends the merged codon values for an annotation, with
+// the linked variant IDs joined by sep.
+func appendMergedCodon(row []string, ann *annotate.Annotation, sep string) []string {
+	if ann == nil || len(ann.MergedWith) == 0 {
+		return append(row, "", "", "", "")
+	}
+	return append(row, ann.MergedConsequence, ann.MergedCodonChange, ann.MergedHGVSp, strings.Join(ann.MergedWith, sep))
+}
+
 // setIfPresent sets row[idx] = val if idx >= 0 and within bounds.
 func setIfPresent(row []string, idx int, val string) {
 	if idx >= 0 && idx < len(row) {
@@ -265,8 +451,11 @@ func SOToMAFClassification(consequence string, v *vcf.Variant) string {
 		return "In_Frame_Del"
 	case "inframe_insertion":
 		return "In_Frame_Ins"
-	case "splice_donor_variant", "splice_acceptor_variant":
+	case "splice_donor_variant", "splice_acceptor_variant",
+		"transcript_ablation", "exon_loss_variant":
 		return "Splice_Site"
+	case "transcript_amplification":
+		return "Intron"
 	case "splice_region_variant":
 		return "Splice_Region"
 	case "stop_lost":
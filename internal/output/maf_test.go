@@ -21,8 +21,8 @@ func TestMAFWriter_Header(t *testing.T) {
 		t.Fatal(err)
 	}
 	got := buf.String()
-	// Header should have original columns + 10 vibe.* core columns + vibe.all_effects
-	wantPrefix := header + "\tvibe.hugo_symbol\tvibe.consequence\tvibe.variant_classification\tvibe.transcript_id\tvibe.hgvsc\tvibe.hgvsp\tvibe.hgvsp_short\tvibe.canonical_mskcc\tvibe.canonical_ensembl\tvibe.canonical_mane\tvibe.all_effects\n"
+	// Header should have original columns + 11 vibe.* core columns + vibe.all_effects
+	wantPrefix := header + "\tvibe.hugo_symbol\tvibe.consequence\tvibe.variant_classification\tvibe.transcript_id\tvibe.hgvsc\tvibe.hgvsp\tvibe.hgvsp_short\tvibe.canonical_mskcc\tvibe.canonical_ensembl\tvibe.canonical_mane\tvibe.distance\tvibe.all_effects\n"
 	if got != wantPrefix {
 		t.Errorf("header = %q, want %q", got, wantPrefix)
 	}
@@ -63,9 +63,9 @@ func TestMAFWriter_PreservesAllColumns(t *testing.T) {
 
 	got := strings.TrimRight(buf.String(), "\n")
 	parts := strings.Split(got, "\t")
-	// 20 original + 10 vibe.* core columns + 1 vibe.all_effects
-	if len(parts) != 31 {
-		t.Fatalf("expected 31 columns, got %d", len(parts))
+	// 20 original + 11 vibe.* core columns + 1 vibe.all_effects
+	if len(parts) != 32 {
+		t.Fatalf("expected 32 columns, got %d", len(parts))
 	}
 	for i := 0; i < 20; i++ {
 		want := fields[i]
@@ -463,6 +463,216 @@ func TestMAFWriter_AllEffects_NilAnnotation(t *testing.T) {
 	}
 }
 
+func TestMAFWriter_Nearest(t *testing.T) {
+	cols := maf.ColumnIndices{
+		HugoSymbol: 0, Consequence: -1,
+		Chromosome: -1, StartPosition: -1, EndPosition: -1,
+		ReferenceAllele: -1, TumorSeqAllele2: -1,
+		HGVSpShort: -1, TranscriptID: -1, VariantType: -1,
+		NCBIBuild: -1, HGVSc: -1, VariantClassification: -1, HGVSp: -1,
+	}
+	ann := &annotate.Annotation{
+		Consequence:         "intergenic_variant",
+		NearestGene:         "KRAS",
+		NearestTranscriptID: "ENST00000311936",
+		NearestDistance:     -50000,
+	}
+
+	for _, replace := range []bool{false, true} {
+		var buf bytes.Buffer
+		w := NewMAFWriter(&buf, "Hugo_Symbol", cols)
+		w.SetReplace(replace)
+		w.SetNearest(true)
+		w.SetExcludeColumns([]string{"all_effects"})
+		if err := w.WriteHeader(); err != nil {
+			t.Fatal(err)
+		}
+		if err := w.WriteRow([]string{""}, ann, []*annotate.Annotation{ann}, &vcf.Variant{Ref: "A", Alt: "G"}); err != nil {
+			t.Fatal(err)
+		}
+		if err := w.Flush(); err != nil {
+			t.Fatal(err)
+		}
+
+		lines := strings.Split(strings.TrimRight(buf.String(), "\n"), "\n")
+		header := strings.Split(lines[0], "\t")
+		row := strings.Split(lines[1], "\t")
+		n := len(header)
+		want := "nearest_gene"
+		if !replace {
+			want = "vibe.nearest_gene"
+		}
+		if header[n-3] != want {
+			t.Errorf("replace=%v: header[%d] = %q, want %q", replace, n-3, header[n-3], want)
+		}
+		if got := strings.Join(row[n-3:], ","); got != "KRAS,ENST00000311936,-50000" {
+			t.Errorf("replace=%v: nearest values = %q", replace, got)
+		}
+	}
+}
+
+func TestMAFWriter_ReportNormalization(t *testing.T) {
+	cols := maf.ColumnIndices{
+		HugoSymbol: 0, Consequence: -1,
+		Chromosome: -1, StartPosition: -1, EndPosition: -1,
+		ReferenceAllele: -1, TumorSeqAllele2: -1,
+		HGVSpShort: -1, TranscriptID: -1, VariantType: -1,
+		NCBIBuild: -1, HGVSc: -1, VariantClassification: -1, HGVSp: -1,
+	}
+	var buf bytes.Buffer
+	w := NewMAFWriter(&buf, "Hugo_Symbol", cols)
+	w.SetReportNormalization(true)
+	w.SetExcludeColumns([]string{"all_effects"})
+	if err := w.WriteHeader(); err != nil {
+		t.Fatal(err)
+	}
+
+	ann := &annotate.Annotation{GeneName: "NORM", Consequence: "intron_variant"}
+	moved := &vcf.Variant{Chrom: "1", Pos: 12, Ref: "T", Alt: "", Original: &vcf.OriginalAllele{Pos: 18, Ref: "T"}}
+	same := &vcf.Variant{Chrom: "1", Pos: 5, Ref: "A", Alt: "G"}
+	for _, v := range []*vcf.Variant{moved, same} {
+		if err := w.WriteRow([]string{"NORM"}, ann, []*annotate.Annotation{ann}, v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	lines := strings.Split(strings.TrimRight(buf.String(), "\n"), "\n")
+	if !strings.HasSuffix(lines[0], "\tvibe.normalized_variant") {
+		t.Errorf("header = %q", lines[0])
+	}
+	if !strings.HasSuffix(lines[1], "\t1:12:T/-") {
+		t.Errorf("moved row = %q", lines[1])
+	}
+	if !strings.HasSuffix(lines[2], "\t") {
+		t.Errorf("unchanged row should have empty normalized_variant: %q", lines[2])
+	}
+}
+
+func TestMAFWriter_RefCheck(t *testing.T) {
+	cols := maf.ColumnIndices{
+		HugoSymbol: 0, Consequence: -1,
+		Chromosome: -1, StartPosition: -1, EndPosition: -1,
+		ReferenceAllele: -1, TumorSeqAllele2: -1,
+		HGVSpShort: -1, TranscriptID: -1, VariantType: -1,
+		NCBIBuild: -1, HGVSc: -1, VariantClassification: -1, HGVSp: -1,
+	}
+	var buf bytes.Buffer
+	w := NewMAFWriter(&buf, "Hugo_Symbol", cols)
+	w.SetReplace(true)
+	w.SetRefCheck(true)
+	w.SetExcludeColumns([]string{"all_effects"})
+	if err := w.WriteHeader(); err != nil {
+		t.Fatal(err)
+	}
+
+	v := &vcf.Variant{Chrom: "1", Pos: 5, Ref: "A", Alt: "G"}
+	for _, ann := range []*annotate.Annotation{
+		{GeneName: "BAD", Consequence: "intron_variant", RefMismatch: true},
+		{GeneName: "GOOD", Consequence: "intron_variant"},
+	} {
+		if err := w.WriteRow([]string{"X"}, ann, []*annotate.Annotation{ann}, v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	lines := strings.Split(strings.TrimRight(buf.String(), "\n"), "\n")
+	want := []string{"Hugo_Symbol\tref_mismatch", "BAD\tYES", "GOOD\t"}
+	for i, line := range want {
+		if lines[i] != line {
+			t.Errorf("line %d = %q, want %q", i, lines[i], line)
+		}
+	}
+}
+
+func TestMAFWriter_TranscriptSets(t *testing.T) {
+	cols := maf.ColumnIndices{
+		HugoSymbol: 0, Consequence: -1,
+		Chromosome: -1, StartPosition: -1, EndPosition: -1,
+		ReferenceAllele: -1, TumorSeqAllele2: -1,
+		HGVSpShort: -1, TranscriptID: -1, VariantType: -1,
+		NCBIBuild: -1, HGVSc: -1, VariantClassification: -1, HGVSp: -1,
+	}
+	var buf bytes.Buffer
+	w := NewMAFWriter(&buf, "Hugo_Symbol", cols)
+	w.SetReplace(true)
+	w.SetTranscriptSets([]string{"oncokb", "report"})
+	w.SetExcludeColumns([]string{"all_effects"})
+	if err := w.WriteHeader(); err != nil {
+		t.Fatal(err)
+	}
+
+	v := &vcf.Variant{Chrom: "12", Pos: 5, Ref: "A", Alt: "G"}
+	for _, ann := range []*annotate.Annotation{
+		{GeneName: "KRAS", Consequence: "missense_variant", TranscriptSets: []string{"oncokb"}},
+		{GeneName: "NONE", Consequence: "intron_variant"},
+	} {
+		if err := w.WriteRow([]string{"X"}, ann, []*annotate.Annotation{ann}, v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	lines := strings.Split(strings.TrimRight(buf.String(), "\n"), "\n")
+	want := []string{"Hugo_Symbol\ttranscript_set_oncokb\ttranscript_set_report", "KRAS\tYES\t", "NONE\t\t"}
+	for i, line := range want {
+		if lines[i] != line {
+			t.Errorf("line %d = %q, want %q", i, lines[i], line)
+		}
+	}
+}
+
+func TestMAFWriter_MergeCodons(t *testing.T) {
+	cols := maf.ColumnIndices{
+		HugoSymbol: 0, Consequence: -1,
+		Chromosome: -1, StartPosition: -1, EndPosition: -1,
+		ReferenceAllele: -1, TumorSeqAllele2: -1,
+		HGVSpShort: -1, TranscriptID: -1, VariantType: -1,
+		NCBIBuild: -1, HGVSc: -1, VariantClassification: -1, HGVSp: -1,
+	}
+	var buf bytes.Buffer
+	w := NewMAFWriter(&buf, "Hugo_Symbol", cols)
+	w.SetReplace(true)
+	w.SetMergeCodons(true)
+	w.SetExcludeColumns([]string{"all_effects"})
+	if err := w.WriteHeader(); err != nil {
+		t.Fatal(err)
+	}
+
+	v := &vcf.Variant{Chrom: "12", Pos: 5, Ref: "A", Alt: "G"}
+	for _, ann := range []*annotate.Annotation{
+		{GeneName: "KRAS", Consequence: "missense_variant", MergedConsequence: "missense_variant",
+			MergedCodonChange: "ggt/TTt", MergedHGVSp: "p.Gly12Phe", MergedWith: []string{"12_25245351_C/A"}},
+		{GeneName: "NONE", Consequence: "intron_variant"},
+	} {
+		if err := w.WriteRow([]string{"X"}, ann, []*annotate.Annotation{ann}, v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	lines := strings.Split(strings.TrimRight(buf.String(), "\n"), "\n")
+	want := []string{
+		"Hugo_Symbol\tmerged_consequence\tmerged_codons\tmerged_hgvsp\tmerged_with",
+		"KRAS\tmissense_variant\tggt/TTt\tp.Gly12Phe\t12_25245351_C/A",
+		"NONE\t\t\t\t",
+	}
+	for i, line := range want {
+		if lines[i] != line {
+			t.Errorf("line %d = %q, want %q", i, lines[i], line)
+		}
+	}
+}
+
 func TestMAFWriter_AllEffects_Disabled(t *testing.T) {
 	var buf bytes.Buffer
 	cols := maf.ColumnIndices{
@@ -491,11 +701,11 @@ func TestMAFWriter_AllEffects_Disabled(t *testing.T) {
 	if strings.Contains(got, "all_effects") {
 		t.Error("all_effects column should not appear when disabled")
 	}
-	// 1 orig + 9 core = 10 columns per row (no all_effects)
+	// 1 orig + 11 core = 12 columns per row (no all_effects)
 	lines := strings.Split(strings.TrimRight(got, "\n"), "\n")
 	parts := strings.Split(lines[1], "\t")
-	if len(parts) != 11 {
-		t.Errorf("expected 11 columns (no all_effects), got %d", len(parts))
+	if len(parts) != 12 {
+		t.Errorf("expected 12 columns (no all_effects), got %d", len(parts))
 	}
 }
 
@@ -524,6 +734,9 @@ func TestSOToMAFClassification(t *testing.T) {
 		{"downstream_gene_variant", "C", "T", "3'Flank"},
 		{"upstream_gene_variant", "C", "T", "5'Flank"},
 		{"non_coding_transcript_exon_variant", "C", "T", "RNA"},
+		{"transcript_ablation", "N", "<DEL>", "Splice_Site"},
+		{"exon_loss_variant,coding_sequence_variant,feature_truncation", "N", "<DEL>", "Splice_Site"},
+		{"transcript_amplification", "N", "<DUP>", "Intron"},
 		// Comma-separated: use first term
 		{"missense_variant,splice_region_variant", "C", "T", "Missense_Mutation"},
 	}
@@ -785,11 +998,11 @@ func TestMAFWriter_ExcludeMultipleColumns(t *testing.T) {
 		t.Error("vibe.all_effects should appear in header")
 	}
 
-	// Row: 1 orig + 8 core (10 - 2 excluded) + 1 all_effects = 10 columns
+	// Row: 1 orig + 9 core (11 - 2 excluded) + 1 all_effects = 11 columns
 	lines := strings.Split(strings.TrimRight(got, "\n"), "\n")
 	parts := strings.Split(lines[1], "\t")
-	if len(parts) != 10 {
-		t.Errorf("expected 10 columns (1 orig + 8 core + 1 all_effects), got %d", len(parts))
+	if len(parts) != 11 {
+		t.Errorf("expected 11 columns (1 orig + 9 core + 1 all_effects), got %d", len(parts))
 	}
 }
 
@@ -8,9 +8,18 @@ import (
 	"github.com/inodb/vibe-vep/internal/vcf"
 )
 
+// VEPMarshalOptions controls transcript picking in the VEP response, like the
+// pick and flag_pick options of the Ensembl VEP REST API.
+type VEPMarshalOptions struct {
+	PickOrder PickOrder // ranking used to pick one consequence per allele
+	Pick      bool      // only return the picked consequences
+	FlagPick  bool      // set pick=1 on the picked consequences
+}
+
 // MarshalVEPAnnotation builds a VEPVariantAnnotation from a variant and its annotations,
 // then marshals it to JSON. This is the standalone equivalent of JSONLWriter.marshalVEP().
-func MarshalVEPAnnotation(input string, v *vcf.Variant, anns []*annotate.Annotation, assembly string) ([]byte, error) {
+// The zero VEPMarshalOptions returns every consequence unflagged.
+func MarshalVEPAnnotation(input string, v *vcf.Variant, anns []*annotate.Annotation, assembly string, opt VEPMarshalOptions) ([]byte, error) {
 	ref, alt := alleleStrings(v)
 
 	end := v.Pos + int64(len(v.Ref)) - 1
@@ -39,7 +48,15 @@ func MarshalVEPAnnotation(input string, v *vcf.Variant, anns []*annotate.Annotat
 		}
 	}
 
+	var picked map[*annotate.Annotation]bool
+	if opt.Pick || opt.FlagPick {
+		picked = opt.PickOrder.PickedAnnotations(anns)
+	}
+
 	for _, ann := range anns {
+		if opt.Pick && !picked[ann] {
+			continue
+		}
 		tc := VEPTranscriptConsequence{
 			TranscriptID:     stripVersion(ann.TranscriptID),
 			GeneID:           ann.GeneID,
@@ -61,6 +78,12 @@ func MarshalVEPAnnotation(input string, v *vcf.Variant, anns []*annotate.Annotat
 			HGVSp:            ann.HGVSp,
 			Exon:             ann.ExonNumber,
 			Intron:           ann.IntronNumber,
+			TranscriptSets:   ann.TranscriptSets,
+
+			MergedConsequence: ann.MergedConsequence,
+			MergedCodons:      ann.MergedCodonChange,
+			MergedHGVSp:       ann.MergedHGVSp,
+			MergedWith:        ann.MergedWith,
 		}
 
 		// SIFT/PolyPhen from annotation source extras.
@@ -76,6 +99,9 @@ func MarshalVEPAnnotation(input string, v *vcf.Variant, anns []*annotate.Annotat
 			}
 		}
 		tc.PolyPhenPrediction = ann.GetExtraKey("polyphen.prediction")
+		if opt.FlagPick && picked[ann] {
+			tc.Pick = 1
+		}
 
 		result.TranscriptConsequences = append(result.TranscriptConsequences, tc)
 	}
@@ -0,0 +1,231 @@
+package output
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/inodb/vibe-vep/internal/annotate"
+	"github.com/inodb/vibe-vep/internal/maf"
+)
+
+// PickCriterion is one transcript ranking rule of a PickOrder.
+type PickCriterion string
+
+// Pick criteria, each preferring the annotation that has the property.
+const (
+	PickMANESelect       PickCriterion = "mane_select"       // MANE Select transcript
+	PickCanonicalMSK     PickCriterion = "canonical_msk"     // MSK canonical transcript
+	PickCanonicalEnsembl PickCriterion = "canonical_ensembl" // Ensembl canonical transcript
+	PickBiotype          PickCriterion = "biotype"           // protein-coding biotype
+	PickImpact           PickCriterion = "impact"            // higher impact
+	PickHGVSp            PickCriterion = "hgvsp"             // has an HGVSp
+	PickLength           PickCriterion = "length"            // longer transcript (see Annotation.TranscriptLength)
+)
+
+// pickSetPrefix prefixes criteria that prefer transcripts listed in a user
+// transcript set, e.g. "set:oncokb".
+const pickSetPrefix = "set:"
+
+// PickTranscriptSet returns the criterion preferring transcripts in the named
+// user transcript set.
+func PickTranscriptSet(name string) PickCriterion {
+	return PickCriterion(pickSetPrefix + name)
+}
+
+// TranscriptSet returns the set name of a transcript set criterion.
+func (c PickCriterion) TranscriptSet() (string, bool) {
+	name, ok := strings.CutPrefix(string(c), pickSetPrefix)
+	return name, ok && name != ""
+}
+
+// pickCriteria lists the valid criteria in documentation order.
+var pickCriteria = []PickCriterion{
+	PickMANESelect, PickCanonicalMSK, PickCanonicalEnsembl,
+	PickBiotype, PickImpact, PickHGVSp, PickLength,
+}
+
+// PickOrder ranks the annotations of a variant to choose one: the first
+// criterion on which two annotations differ decides, and full ties keep the
+// earlier annotation. A nil PickOrder uses DefaultPickOrder.
+type PickOrder []PickCriterion
+
+// DefaultPickOrder is the ranking used when no pick order is configured.
+var DefaultPickOrder = PickOrder{PickBiotype, PickCanonicalMSK, PickImpact, PickHGVSp}
+
+// mostSevereOrder is the ranking used by PickMostSevere when no pick order is
+// configured.
+var mostSevereOrder = PickOrder{PickImpact, PickCanonicalMSK, PickBiotype}
+
+// ParsePickOrder parses a comma-separated list of criteria, e.g.
+// "mane_select,canonical_msk,biotype,impact,length" or "set:oncokb,biotype".
+// An empty string returns a nil PickOrder. Transcript set names are not
+// checked against the configured sets.
+func ParsePickOrder(s string) (PickOrder, error) {
+	var order PickOrder
+	seen := make(map[PickCriterion]bool)
+	for _, field := range strings.Split(s, ",") {
+		c := PickCriterion(strings.ToLower(strings.TrimSpace(field)))
+		if c == "" {
+			continue
+		}
+		if _, isSet := c.TranscriptSet(); !isSet && !slices.Contains(pickCriteria, c) {
+			names := make([]string, len(pickCriteria))
+			for i, v := range pickCriteria {
+				names[i] = string(v)
+			}
+			return nil, fmt.Errorf("unknown pick criterion %q; valid criteria: %s, set:<name>", c, strings.Join(names, ", "))
+		}
+		if seen[c] {
+			return nil, fmt.Errorf("pick criterion %q listed more than once", c)
+		}
+		seen[c] = true
+		order = append(order, c)
+	}
+	return order, nil
+}
+
+// String formats the order as a comma-separated list.
+func (o PickOrder) String() string {
+	names := make([]string, len(o.criteria()))
+	for i, c := range o.criteria() {
+		names[i] = string(c)
+	}
+	return strings.Join(names, ",")
+}
+
+func (o PickOrder) criteria() PickOrder {
+	if len(o) == 0 {
+		return DefaultPickOrder
+	}
+	return o
+}
+
+// Better returns true if ann ranks above current.
+func (o PickOrder) Better(ann, current *annotate.Annotation) bool {
+	for _, c := range o.criteria() {
+		if r := compareCriterion(c, ann, current); r != 0 {
+			return r > 0
+		}
+	}
+	return false
+}
+
+// Pick returns the best-ranked annotation, or nil if anns is empty.
+func (o PickOrder) Pick(anns []*annotate.Annotation) *annotate.Annotation {
+	if len(anns) == 0 {
+		return nil
+	}
+	best := anns[0]
+	for _, ann := range anns[1:] {
+		if o.Better(ann, best) {
+			best = ann
+		}
+	}
+	return best
+}
+
+// MostSevere returns the highest-impact annotation, breaking ties with the
+// rest of the order. A nil PickOrder breaks ties by MSK canonical, then
+// protein-coding biotype.
+func (o PickOrder) MostSevere(anns []*annotate.Annotation) *annotate.Annotation {
+	severe := mostSevereOrder
+	if len(o) > 0 {
+		severe = PickOrder{PickImpact}
+		for _, c := range o {
+			if c != PickImpact {
+				severe = append(severe, c)
+			}
+		}
+	}
+	return severe.Pick(anns)
+}
+
+// SelectBest picks the annotation to report for a MAF entry: the annotation
+// on the MAF's own transcript (ignoring version) if there is one, otherwise
+// the best-ranked annotation of the MAF's gene, otherwise the best-ranked
+// annotation overall.
+func (o PickOrder) SelectBest(mafAnn *maf.MAFAnnotation, anns []*annotate.Annotation) *annotate.Annotation {
+	// Pass 1: transcript ID match ignoring version suffix.
+	if mafAnn.TranscriptID != "" {
+		mafBase := transcriptBaseID(mafAnn.TranscriptID)
+		for _, ann := range anns {
+			if transcriptBaseID(ann.TranscriptID) == mafBase {
+				if isCodingConsequence(mafAnn.Consequence) && !isProteinCodingBiotype(ann.Biotype) {
+					break
+				}
+				return ann
+			}
+		}
+	}
+
+	// Pass 2: best-ranked annotation of the same gene.
+	var sameGene *annotate.Annotation
+	if mafAnn.HugoSymbol != "" {
+		for _, ann := range anns {
+			if ann.GeneName == mafAnn.HugoSymbol {
+				if sameGene == nil || o.Better(ann, sameGene) {
+					sameGene = ann
+				}
+			}
+		}
+	}
+	if sameGene != nil {
+		return sameGene
+	}
+
+	// Pass 3: best-ranked annotation overall.
+	return o.Pick(anns)
+}
+
+// compareCriterion returns a positive number if a ranks above b on c, a
+// negative number if b ranks above a, and 0 on a tie.
+func compareCriterion(c PickCriterion, a, b *annotate.Annotation) int {
+	switch c {
+	case PickMANESelect:
+		return compareBool(a.IsMANESelect, b.IsMANESelect)
+	case PickCanonicalMSK:
+		return compareBool(a.IsCanonicalMSK, b.IsCanonicalMSK)
+	case PickCanonicalEnsembl:
+		return compareBool(a.IsCanonicalEnsembl, b.IsCanonicalEnsembl)
+	case PickBiotype:
+		return compareBool(isProteinCodingBiotype(a.Biotype), isProteinCodingBiotype(b.Biotype))
+	case PickImpact:
+		return annotate.ImpactRank(a.Impact) - annotate.ImpactRank(b.Impact)
+	case PickHGVSp:
+		return compareBool(a.HGVSp != "", b.HGVSp != "")
+	case PickLength:
+		return cmp.Compare(a.TranscriptLength, b.TranscriptLength)
+	}
+	if name, ok := c.TranscriptSet(); ok {
+		return compareBool(a.InTranscriptSet(name), b.InTranscriptSet(name))
+	}
+	return 0
+}
+
+func compareBool(a, b bool) int {
+	switch {
+	case a && !b:
+		return 1
+	case b && !a:
+		return -1
+	}
+	return 0
+}
+
+// PickedAnnotations returns the annotation picked for each allele of a
+// variant's annotations (see VCFWriter.SetFlagPick).
+func (o PickOrder) PickedAnnotations(anns []*annotate.Annotation) map[*annotate.Annotation]bool {
+	best := make(map[string]*annotate.Annotation)
+	for _, ann := range anns {
+		if cur, ok := best[ann.Allele]; !ok || o.Better(ann, cur) {
+			best[ann.Allele] = ann
+		}
+	}
+	picked := make(map[*annotate.Annotation]bool, len(best))
+	for _, ann := range best {
+		picked[ann] = true
+	}
+	return picked
+}
@@ -0,0 +1,103 @@
+package output
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/inodb/vibe-vep/internal/annotate"
+	"github.com/inodb/vibe-vep/internal/maf"
+)
+
+func TestParsePickOrder(t *testing.T) {
+	order, err := ParsePickOrder(" MANE_select, canonical_msk,biotype,impact,length ")
+	require.NoError(t, err)
+	assert.Equal(t, PickOrder{PickMANESelect, PickCanonicalMSK, PickBiotype, PickImpact, PickLength}, order)
+	assert.Equal(t, "mane_select,canonical_msk,biotype,impact,length", order.String())
+
+	order, err = ParsePickOrder("")
+	require.NoError(t, err)
+	assert.Nil(t, order)
+	assert.Equal(t, "biotype,canonical_msk,impact,hgvsp", order.String())
+
+	order, err = ParsePickOrder("set:oncokb,canonical_msk")
+	require.NoError(t, err)
+	assert.Equal(t, PickOrder{PickTranscriptSet("oncokb"), PickCanonicalMSK}, order)
+
+	_, err = ParsePickOrder("mane_select,tsl")
+	assert.ErrorContains(t, err, `unknown pick criterion "tsl"`)
+	_, err = ParsePickOrder("set:")
+	assert.ErrorContains(t, err, "unknown pick criterion")
+	_, err = ParsePickOrder("impact,impact")
+	assert.ErrorContains(t, err, "more than once")
+}
+
+func TestPickOrder_Pick(t *testing.T) {
+	msk := &annotate.Annotation{TranscriptID: "MSK", Biotype: "protein_coding", Impact: "MODERATE", IsCanonicalMSK: true, TranscriptLength: 500}
+	mane := &annotate.Annotation{TranscriptID: "MANE", Biotype: "protein_coding", Impact: "MODERATE", IsMANESelect: true, IsCanonicalEnsembl: true, TranscriptLength: 600, TranscriptSets: []string{"oncokb"}}
+	long := &annotate.Annotation{TranscriptID: "LONG", Biotype: "protein_coding", Impact: "HIGH", TranscriptLength: 900}
+	anns := []*annotate.Annotation{msk, mane, long}
+
+	tests := []struct {
+		order string
+		want  string
+	}{
+		{"", "MSK"},
+		{"mane_select,canonical_msk,biotype,impact,length", "MANE"},
+		{"canonical_ensembl", "MANE"},
+		{"canonical_msk,mane_select", "MSK"},
+		{"length", "LONG"},
+		{"impact", "LONG"},
+		{"hgvsp", "MSK"}, // full tie keeps the first annotation
+		{"set:oncokb", "MANE"},
+		{"set:other,length", "LONG"},
+	}
+	for _, tt := range tests {
+		order, err := ParsePickOrder(tt.order)
+		require.NoError(t, err)
+		assert.Equal(t, tt.want, order.Pick(anns).TranscriptID, tt.order)
+	}
+	assert.Nil(t, PickOrder(nil).Pick(nil))
+}
+
+func TestPickOrder_MostSevere(t *testing.T) {
+	msk := &annotate.Annotation{TranscriptID: "MSK", Biotype: "protein_coding", Impact: "HIGH", IsCanonicalMSK: true}
+	mane := &annotate.Annotation{TranscriptID: "MANE", Biotype: "protein_coding", Impact: "HIGH", IsMANESelect: true}
+	low := &annotate.Annotation{TranscriptID: "LOW", Biotype: "protein_coding", Impact: "LOW", IsMANESelect: true, IsCanonicalMSK: true}
+	anns := []*annotate.Annotation{low, msk, mane}
+
+	assert.Equal(t, "MSK", PickOrder(nil).MostSevere(anns).TranscriptID)
+	order := PickOrder{PickMANESelect, PickCanonicalMSK, PickImpact}
+	assert.Equal(t, "MANE", order.MostSevere(anns).TranscriptID, "impact still ranks first")
+	assert.Equal(t, "LOW", order.Pick(anns).TranscriptID)
+}
+
+func TestPickOrder_SelectBest(t *testing.T) {
+	anns := []*annotate.Annotation{
+		{TranscriptID: "ENST1.1", GeneName: "A", Biotype: "protein_coding", IsCanonicalMSK: true},
+		{TranscriptID: "ENST2.1", GeneName: "B", Biotype: "protein_coding", IsCanonicalMSK: true},
+		{TranscriptID: "ENST3.1", GeneName: "B", Biotype: "protein_coding", IsMANESelect: true},
+	}
+	order := PickOrder{PickMANESelect, PickCanonicalMSK}
+
+	// The MAF's own transcript wins over the pick order.
+	got := order.SelectBest(&maf.MAFAnnotation{TranscriptID: "ENST2", HugoSymbol: "B"}, anns)
+	assert.Equal(t, "ENST2.1", got.TranscriptID)
+
+	got = order.SelectBest(&maf.MAFAnnotation{HugoSymbol: "B"}, anns)
+	assert.Equal(t, "ENST3.1", got.TranscriptID)
+	got = PickOrder(nil).SelectBest(&maf.MAFAnnotation{HugoSymbol: "B"}, anns)
+	assert.Equal(t, "ENST2.1", got.TranscriptID)
+
+	got = order.SelectBest(&maf.MAFAnnotation{HugoSymbol: "C"}, anns)
+	assert.Equal(t, "ENST3.1", got.TranscriptID)
+}
+
+func TestPickOrder_PickedAnnotations(t *testing.T) {
+	a1 := &annotate.Annotation{Allele: "T", Impact: "LOW"}
+	a2 := &annotate.Annotation{Allele: "T", Impact: "HIGH"}
+	c1 := &annotate.Annotation{Allele: "C", Impact: "MODIFIER"}
+	picked := PickOrder{PickImpact}.PickedAnnotations([]*annotate.Annotation{a1, a2, c1})
+	assert.Equal(t, map[*annotate.Annotation]bool{a2: true, c1: true}, picked)
+}
@@ -8,10 +8,12 @@ import (
 	"strings"
 
 	"github.com/inodb/vibe-vep/internal/annotate"
+	"github.com/inodb/vibe-vep/internal/filter"
 	"github.com/inodb/vibe-vep/internal/vcf"
 )
 
-// CSQ sub-field names in VEP convention order.
+// CSQ sub-field names in VEP convention order. The optional fields follow,
+// and DISTANCE comes last so that adding it did not shift the others.
 var csqFields = []string{
 	"Allele",
 	"Consequence",
@@ -38,10 +40,19 @@ var csqFields = []string{
 // VCFWriter writes annotations in VCF format with a CSQ INFO field.
 // Annotations are buffered per variant and flushed when the variant changes.
 type VCFWriter struct {
-	w           *bufio.Writer
-	headerLines []string // original VCF header lines (## and #CHROM)
-	sources     []annotate.AnnotationSource
-	sourceKeys  []string // pre-built Extra map keys for source columns
+	w              *bufio.Writer
+	headerLines    []string // original VCF header lines (## and #CHROM)
+	sources        []annotate.AnnotationSource
+	sourceKeys     []string // pre-built Extra map keys for source columns
+	nearest        bool     // include the NEAREST* fields
+	reportNorm     bool     // add OLD_VARIANT for left-aligned records
+	refCheck       bool     // add the REF_MISMATCH flag
+	flagPick       bool     // include the PICK field
+	pickOrder      PickOrder
+	transcriptSets []string        // user transcript sets, one CSQ field each
+	mergeCodons    bool            // include the MERGED_* fields
+	filter         *filter.Expr    // CSQ entries to keep, nil for all
+	csqColumns     *filter.Columns // CSQ field names, for filtering
 
 	// Buffered state for the current variant.
 	currentChrom string                 // chromosome for grouping
@@ -66,10 +77,78 @@ func (vw *VCFWriter) SetSources(sources []annotate.AnnotationSource) {
 	vw.sourceKeys = buildSourceKeys(sources)
 }
 
+// SetNearest adds NEAREST (closest gene for intergenic variants),
+// NEAREST_TRANSCRIPT and NEAREST_DISTANCE (signed, negative upstream) fields
+// to CSQ.
+func (vw *VCFWriter) SetNearest(nearest bool) {
+	vw.nearest = nearest
+}
+
+// SetReportNormalization adds an OLD_VARIANT INFO field (chrom:pos:ref/alt,
+// as written by bcftools norm) to records whose position or alleles were
+// changed by left-alignment.
+func (vw *VCFWriter) SetReportNormalization(report bool) {
+	vw.reportNorm = report
+}
+
+// SetRefCheck adds a REF_MISMATCH INFO flag to records whose REF allele does
+// not match the reference genome (see annotate.Annotation.RefMismatch).
+func (vw *VCFWriter) SetRefCheck(check bool) {
+	vw.refCheck = check
+}
+
+// SetFlagPick adds a PICK field to CSQ, set to 1 on the annotation of each
+// allele that order ranks first. All annotations are still written.
+func (vw *VCFWriter) SetFlagPick(order PickOrder) {
+	vw.flagPick = true
+	vw.pickOrder = order
+}
+
+// SetTranscriptSets adds a TRANSCRIPT_SET_<NAME> field per user transcript set
+// to CSQ, set to YES for transcripts in the set.
+func (vw *VCFWriter) SetTranscriptSets(names []string) {
+	vw.transcriptSets = names
+}
+
+// SetMergeCodons adds MERGED_CONSEQUENCE, MERGED_CODONS, MERGED_HGVSP and
+// MERGED_WITH fields to CSQ, describing the combined change of in-cis SNVs
+// in the same codon (see annotate.CodonMerger). MERGED_WITH lists the other
+// variants joined by '&'.
+func (vw *VCFWriter) SetMergeCodons(merge bool) {
+	vw.mergeCodons = merge
+}
+
+// SetFilter keeps only the CSQ entries matching expr, evaluated over the CSQ
+// fields and the record's VCF columns and INFO keys. Records with no
+// matching entry are not written.
+func (vw *VCFWriter) SetFilter(expr *filter.Expr) {
+	vw.filter = expr
+}
+
+// oldVariantHeader declares the OLD_VARIANT INFO field.
+const oldVariantHeader = `##INFO=<ID=OLD_VARIANT,Number=.,Type=String,Description="Original chr:pos:ref/alt before left-alignment">`
+
+// refMismatchHeader declares the REF_MISMATCH INFO flag.
+const refMismatchHeader = `##INFO=<ID=REF_MISMATCH,Number=0,Type=Flag,Description="REF allele does not match the reference genome">`
+
 // WriteHeader writes the original VCF header lines with an inserted CSQ INFO line.
 func (vw *VCFWriter) WriteHeader() error {
 	allFields := make([]string, len(csqFields))
 	copy(allFields, csqFields)
+	if vw.nearest {
+		allFields = append(allFields, "NEAREST", "NEAREST_TRANSCRIPT", "NEAREST_DISTANCE")
+	}
+	if vw.flagPick {
+		allFields = append(allFields, "PICK")
+	}
+	for _, name := range vw.transcriptSets {
+		allFields = append(allFields, strings.ToUpper(TranscriptSetColumn(name)))
+	}
+	if vw.mergeCodons {
+		for _, col := range MergedCodonColumns {
+			allFields = append(allFields, strings.ToUpper(col))
+		}
+	}
 	for _, src := range vw.sources {
 		name := src.Name()
 		for _, col := range src.Columns() {
@@ -80,6 +159,9 @@ func (vw *VCFWriter) WriteHeader() error {
 			}
 		}
 	}
+	allFields = append(allFields, "DISTANCE")
+
+	vw.csqColumns = filter.NewColumns(allFields)
 
 	csqLine := fmt.Sprintf(
 		"##INFO=<ID=CSQ,Number=.,Type=String,Description=\"Consequence annotations from vibe-vep. Format: %s\">",
@@ -92,6 +174,16 @@ func (vw *VCFWriter) WriteHeader() error {
 			if _, err := vw.w.WriteString(csqLine + "\n"); err != nil {
 				return err
 			}
+			if vw.reportNorm {
+				if _, err := vw.w.WriteString(oldVariantHeader + "\n"); err != nil {
+					return err
+				}
+			}
+			if vw.refCheck {
+				if _, err := vw.w.WriteString(refMismatchHeader + "\n"); err != nil {
+					return err
+				}
+			}
 		}
 		if _, err := vw.w.WriteString(line + "\n"); err != nil {
 			return err
@@ -109,7 +201,10 @@ func (vw *VCFWriter) Write(v *vcf.Variant, ann *annotate.Annotation) error {
 		}
 	}
 
-	if !vw.hasVariant || vw.currentChrom != v.Chrom || vw.currentPos != v.Pos {
+	// Split alleles of one record share chrom, pos and REF; left-alignment can
+	// move an allele to its own record.
+	if !vw.hasVariant || vw.currentChrom != v.Chrom || vw.currentPos != v.Pos ||
+		(len(vw.currentVars) > 0 && vw.currentVars[0].Ref != v.Ref) {
 		vw.currentChrom = v.Chrom
 		vw.currentPos = v.Pos
 		vw.hasVariant = true
@@ -160,6 +255,37 @@ func (vw *VCFWriter) flushVariant() error {
 
 	// Reconstruct INFO field from raw string
 	info := vw.formatInfo(v.RawInfo)
+	if vw.reportNorm {
+		if old := oldVariants(vw.currentVars); old != "" {
+			info = appendInfo(info, "OLD_VARIANT="+old)
+		}
+	}
+	if vw.refCheck && hasRefMismatch(vw.annotations) {
+		info = appendInfo(info, "REF_MISMATCH")
+	}
+	qual := v.FormatQual()
+
+	var picked map[*annotate.Annotation]bool
+	if vw.flagPick {
+		picked = vw.pickOrder.PickedAnnotations(vw.annotations)
+	}
+	var kept []string // CSQ entries matching the filter
+	if vw.filter != nil {
+		rec := filter.VCFRecord(v.Chrom, v.Pos, v.ID, v.Ref, alt, qual, v.Filter, info)
+		var eb strings.Builder
+		for _, ann := range vw.annotations {
+			eb.Reset()
+			vw.writeCSQEntry(&eb, ann, picked[ann])
+			entry := eb.String()
+			if vw.filter.Match(filter.Chain{vw.csqColumns.Row(strings.Split(entry, "|")), rec}) {
+				kept = append(kept, entry)
+			}
+		}
+		if len(kept) == 0 {
+			vw.resetVariant()
+			return nil
+		}
+	}
 
 	// Build CSQ value and append to INFO
 	var lb strings.Builder
@@ -176,11 +302,7 @@ func (vw *VCFWriter) flushVariant() error {
 	lb.WriteByte('\t')
 	lb.WriteString(alt)
 	lb.WriteByte('\t')
-	if v.Qual != 0 {
-		lb.WriteString(strconv.FormatFloat(v.Qual, 'g', -1, 64))
-	} else {
-		lb.WriteByte('.')
-	}
+	lb.WriteString(qual)
 	lb.WriteByte('\t')
 	lb.WriteString(v.Filter)
 	lb.WriteByte('\t')
@@ -190,11 +312,15 @@ func (vw *VCFWriter) flushVariant() error {
 		lb.WriteString(info)
 		lb.WriteString(";CSQ=")
 	}
-	for i, ann := range vw.annotations {
-		if i > 0 {
-			lb.WriteByte(',')
+	if vw.filter != nil {
+		lb.WriteString(strings.Join(kept, ","))
+	} else {
+		for i, ann := range vw.annotations {
+			if i > 0 {
+				lb.WriteByte(',')
+			}
+			vw.writeCSQEntry(&lb, ann, picked[ann])
 		}
-		vw.writeCSQEntry(&lb, ann)
 	}
 
 	// Append FORMAT + sample columns if present
@@ -208,13 +334,16 @@ func (vw *VCFWriter) flushVariant() error {
 		return err
 	}
 
-	// Reset buffer
+	vw.resetVariant()
+	return nil
+}
+
+// resetVariant clears the buffered variant.
+func (vw *VCFWriter) resetVariant() {
 	vw.hasVariant = false
 	vw.currentVars = nil
 	vw.annotations = nil
 	vw.alts = nil
-
-	return nil
 }
 
 // formatInfo strips any existing CSQ field from the raw INFO string.
@@ -256,7 +385,7 @@ func (vw *VCFWriter) formatInfo(rawInfo string) string {
 }
 
 // writeCSQEntry writes a single annotation as a pipe-delimited CSQ entry to a builder.
-func (vw *VCFWriter) writeCSQEntry(b *strings.Builder, ann *annotate.Annotation) {
+func (vw *VCFWriter) writeCSQEntry(b *strings.Builder, ann *annotate.Annotation, picked bool) {
 	// Write core fields separated by |
 	b.WriteString(ann.Allele)
 	b.WriteByte('|')
@@ -311,12 +440,87 @@ func (vw *VCFWriter) writeCSQEntry(b *strings.Builder, ann *annotate.Annotation)
 	if ann.IsMANESelect {
 		b.WriteString("YES")
 	}
+	if vw.nearest {
+		b.WriteByte('|')
+		b.WriteString(ann.NearestGene)
+		b.WriteByte('|')
+		b.WriteString(ann.NearestTranscriptID)
+		b.WriteByte('|')
+		if ann.NearestTranscriptID != "" {
+			b.WriteString(strconv.FormatInt(ann.NearestDistance, 10))
+		}
+	}
+	if vw.flagPick {
+		b.WriteByte('|')
+		if picked {
+			b.WriteByte('1')
+		}
+	}
+	for _, name := range vw.transcriptSets {
+		b.WriteByte('|')
+		if ann.InTranscriptSet(name) {
+			b.WriteString("YES")
+		}
+	}
+	if vw.mergeCodons {
+		for _, val := range appendMergedCodon(nil, ann, "&") {
+			b.WriteByte('|')
+			b.WriteString(val)
+		}
+	}
 
 	// Append annotation source fields from Extra map using pre-built keys
 	for _, key := range vw.sourceKeys {
 		b.WriteByte('|')
 		b.WriteString(ann.GetExtraKey(key))
 	}
+
+	b.WriteByte('|')
+	if ann.Distance > 0 {
+		b.WriteString(strconv.FormatInt(ann.Distance, 10))
+	}
+}
+
+// oldVariants formats the pre-normalization form of each left-aligned
+// variant as chrom:pos:ref/alt, comma-separated. Returns "" if none changed.
+func oldVariants(vars []*vcf.Variant) string {
+	var parts []string
+	for _, v := range vars {
+		if v.Original != nil {
+			parts = append(parts, formatAlleleKey(v.Chrom, v.Original.Pos, v.Original.Ref, v.Original.Alt))
+		}
+	}
+	return strings.Join(parts, ",")
+}
+
+// appendInfo appends a key=value pair or flag to an INFO string.
+func appendInfo(info, field string) string {
+	if info == "." {
+		return field
+	}
+	return info + ";" + field
+}
+
+// hasRefMismatch reports whether any annotation flags a REF mismatch.
+func hasRefMismatch(anns []*annotate.Annotation) bool {
+	for _, a := range anns {
+		if a.RefMismatch {
+			return true
+		}
+	}
+	return false
+}
+
+// formatAlleleKey formats a variant as chrom:pos:ref/alt, writing empty
+// (MAF-style) alleles as "-".
+func formatAlleleKey(chrom string, pos int64, ref, alt string) string {
+	if ref == "" {
+		ref = "-"
+	}
+	if alt == "" {
+		alt = "-"
+	}
+	return chrom + ":" + strconv.FormatInt(pos, 10) + ":" + ref + "/" + alt
 }
 
 // buildSourceKeys pre-computes the Extra map keys for all source columns.
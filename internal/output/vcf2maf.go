@@ -2,11 +2,16 @@ package output
 
 import (
 	"bufio"
+	"fmt"
 	"io"
+	"slices"
 	"strconv"
 	"strings"
 
+	"go.uber.org/zap"
+
 	"github.com/inodb/vibe-vep/internal/annotate"
+	"github.com/inodb/vibe-vep/internal/filter"
 	"github.com/inodb/vibe-vep/internal/vcf"
 )
 
@@ -43,17 +48,38 @@ var vcf2mafColumns = []string{
 	"Protein_position",
 	"Amino_acids",
 	"Codons",
+	"DISTANCE",
+	"t_depth",
+	"t_ref_count",
+	"t_alt_count",
+	"n_depth",
+	"n_ref_count",
+	"n_alt_count",
 	"all_effects",
 }
 
 // VCF2MAFWriter converts annotated VCF variants to MAF format.
+//
+// Without samples (see SetSamples) every row uses the constructor's tumor
+// barcode. With a tumor sample, each variant gives one row with read counts
+// from the tumor and normal FORMAT fields. Multi-sample VCFs without a tumor
+// sample give one row per sample carrying the ALT allele.
 type VCF2MAFWriter struct {
 	w             *bufio.Writer
 	assembly      string
 	tumorSampleID string
+	samples       []string // VCF sample names, in column order
+	tumorIdx      int      // index of the tumor sample, -1 if none
+	normalIdx     int      // index of the matched normal sample, -1 if none
+	perSample     bool     // one row per carrier sample
+	logger        *zap.Logger
 	sources       []annotate.AnnotationSource
 	sourceKeys    []string // pre-built Extra map keys for source columns
 	excludeCols   map[string]bool // columns to exclude from output
+	transcriptSets []string       // user transcript sets, one column each
+	mergeCodons   bool            // append the merged codon columns
+	filter        *filter.Expr    // rows to write, nil for all
+	columnNames   *filter.Columns // output header, for filtering
 	headerWritten bool
 }
 
@@ -63,7 +89,46 @@ func NewVCF2MAFWriter(w io.Writer, assembly, tumorSampleID string) *VCF2MAFWrite
 		w:             bufio.NewWriter(w),
 		assembly:      assembly,
 		tumorSampleID: tumorSampleID,
+		tumorIdx:      -1,
+		normalIdx:     -1,
+		logger:        zap.NewNop(),
+	}
+}
+
+// SetSamples sets the VCF sample names and selects the tumor and matched
+// normal samples by name. With no tumorID, a single sample is used as the
+// tumor and multiple samples switch to one row per carrier sample (excluding
+// the normal). Returns an error if a named sample is not in the VCF.
+func (m *VCF2MAFWriter) SetSamples(names []string, tumorID, normalID string) error {
+	m.samples = names
+	m.tumorIdx, m.normalIdx, m.perSample = -1, -1, false
+	if normalID != "" {
+		m.normalIdx = slices.Index(names, normalID)
+		if m.normalIdx < 0 {
+			return fmt.Errorf("normal sample %q not found in VCF samples %v", normalID, names)
+		}
+	}
+	switch {
+	case tumorID != "":
+		m.tumorIdx = slices.Index(names, tumorID)
+		if m.tumorIdx < 0 {
+			return fmt.Errorf("tumor sample %q not found in VCF samples %v", tumorID, names)
+		}
+	case len(names) == 1:
+		m.tumorIdx = 0
+	case len(names) > 1:
+		m.perSample = true
+	}
+	if m.tumorIdx >= 0 {
+		m.tumorSampleID = names[m.tumorIdx]
 	}
+	return nil
+}
+
+// SetLogger sets the logger for per-record warnings about invalid FORMAT
+// values.
+func (m *VCF2MAFWriter) SetLogger(l *zap.Logger) {
+	m.logger = l
 }
 
 // SetSources registers annotation sources whose columns will be appended.
@@ -80,6 +145,24 @@ func (m *VCF2MAFWriter) SetExcludeColumns(cols []string) {
 	}
 }
 
+// SetTranscriptSets appends a transcript_set_<name> column per user
+// transcript set, set to "YES" for rows whose transcript is in the set.
+func (m *VCF2MAFWriter) SetTranscriptSets(names []string) {
+	m.transcriptSets = names
+}
+
+// SetMergeCodons appends the merged_* columns describing the combined change
+// of in-cis SNVs in the same codon (see annotate.CodonMerger).
+func (m *VCF2MAFWriter) SetMergeCodons(merge bool) {
+	m.mergeCodons = merge
+}
+
+// SetFilter writes only the rows matching expr, evaluated over the output
+// columns.
+func (m *VCF2MAFWriter) SetFilter(expr *filter.Expr) {
+	m.filter = expr
+}
+
 // WriteHeader writes the MAF header line.
 func (m *VCF2MAFWriter) WriteHeader() error {
 	cols := make([]string, 0, len(vcf2mafColumns))
@@ -89,6 +172,12 @@ func (m *VCF2MAFWriter) WriteHeader() error {
 		}
 		cols = append(cols, c)
 	}
+	for _, name := range m.transcriptSets {
+		cols = append(cols, TranscriptSetColumn(name))
+	}
+	if m.mergeCodons {
+		cols = append(cols, MergedCodonColumns...)
+	}
 
 	// Append source columns
 	for _, src := range m.sources {
@@ -102,16 +191,67 @@ func (m *VCF2MAFWriter) WriteHeader() error {
 		}
 	}
 
+	m.columnNames = filter.NewColumns(cols)
 	_, err := m.w.WriteString(strings.Join(cols, "\t") + "\n")
 	m.headerWritten = true
 	return err
 }
 
-// WriteRow writes a single MAF row from a VCF variant and its best annotation.
-// allAnns contains all transcript annotations for the all_effects column.
+// WriteRow writes the MAF rows of a VCF variant and its best annotation: one
+// row, or one per carrier sample (see SetSamples); samples with a no-call GT
+// (./.) are skipped. Invalid FORMAT values are logged and left empty. allAnns
+// contains all transcript annotations for the all_effects column.
 func (m *VCF2MAFWriter) WriteRow(v *vcf.Variant, ann *annotate.Annotation, allAnns []*annotate.Annotation) error {
-	ref, alt, start, end := VCFToMAFAlleles(v.Pos, v.Ref, v.Alt)
-	variantType := VariantType(ref, alt)
+	var gts []vcf.Genotype
+	if len(m.samples) > 0 {
+		var err error
+		if gts, err = v.Genotypes(); err != nil {
+			m.logger.Warn("invalid FORMAT value",
+				zap.String("chrom", v.Chrom),
+				zap.Int64("pos", v.Pos),
+				zap.Error(err))
+		}
+		if len(gts) != len(m.samples) {
+			gts = nil
+		}
+	}
+	sampleGT := func(i int) *vcf.Genotype {
+		if i < 0 || gts == nil {
+			return nil
+		}
+		return &gts[i]
+	}
+	normal := sampleGT(m.normalIdx)
+
+	if !m.perSample || gts == nil {
+		return m.writeRow(v, ann, allAnns, m.tumorSampleID, sampleGT(m.tumorIdx), normal)
+	}
+	alt := v.Allele()
+	for i, name := range m.samples {
+		g := &gts[i]
+		if i == m.normalIdx || g.NoCall() || (g.HasGT() && !g.Carries(alt)) {
+			continue
+		}
+		if err := m.writeRow(v, ann, allAnns, name, g, normal); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeRow writes one MAF row for the given tumor barcode. tumor and normal
+// hold the samples' FORMAT fields, nil if unknown.
+func (m *VCF2MAFWriter) writeRow(v *vcf.Variant, ann *annotate.Annotation, allAnns []*annotate.Annotation, tumorBarcode string, tumor, normal *vcf.Genotype) error {
+	var ref, alt, variantType string
+	var start, end int64
+	if v.IsStructural() {
+		// Symbolic alleles and breakends keep their VCF form; the span comes from END/SVLEN.
+		ref, alt, start, end = v.Ref, v.Alt, v.Pos, v.End()
+		variantType = v.SVType()
+	} else {
+		ref, alt, start, end = VCFToMAFAlleles(v.Pos, v.Ref, v.Alt)
+		variantType = VariantType(ref, alt)
+	}
 
 	var b strings.Builder
 	b.Grow(512)
@@ -150,9 +290,13 @@ func (m *VCF2MAFWriter) WriteRow(v *vcf.Variant, ann *annotate.Annotation, allAn
 		writeField("") // Variant_Classification
 	}
 	writeField(variantType) // Variant_Type
-	writeField(ref)         // Reference_Allele
-	writeField(ref)         // Tumor_Seq_Allele1
-	writeField(alt)         // Tumor_Seq_Allele2
+	writeField(ref) // Reference_Allele
+	if tumor != nil && tumor.HomAlt(v.Allele()) {
+		writeField(alt) // Tumor_Seq_Allele1
+	} else {
+		writeField(ref) // Tumor_Seq_Allele1
+	}
+	writeField(alt) // Tumor_Seq_Allele2
 
 	// dbSNP RS ID
 	if v.ID != "" && v.ID != "." {
@@ -160,9 +304,13 @@ func (m *VCF2MAFWriter) WriteRow(v *vcf.Variant, ann *annotate.Annotation, allAn
 	} else {
 		writeField("")
 	}
-	writeField("")              // dbSNP_Val_Status
-	writeField(m.tumorSampleID) // Tumor_Sample_Barcode
-	writeField("")              // Matched_Norm_Sample_Barcode
+	writeField("")           // dbSNP_Val_Status
+	writeField(tumorBarcode) // Tumor_Sample_Barcode
+	if m.normalIdx >= 0 {
+		writeField(m.samples[m.normalIdx]) // Matched_Norm_Sample_Barcode
+	} else {
+		writeField("") // Matched_Norm_Sample_Barcode
+	}
 
 	if ann != nil {
 		writeField(ann.HGVSc)              // HGVSc
@@ -195,15 +343,46 @@ func (m *VCF2MAFWriter) WriteRow(v *vcf.Variant, ann *annotate.Annotation, allAn
 		}
 		writeField(ann.AminoAcidChange) // Amino_acids
 		writeField(ann.CodonChange)     // Codons
-		if !m.excludeCols["all_effects"] {
-			writeField(FormatAllEffects(allAnns)) // all_effects
+		if ann.Distance > 0 {
+			writeInt(ann.Distance) // DISTANCE
+		} else {
+			writeField("")
 		}
 	} else {
-		for range 13 {
+		for range 15 { // HGVSc through DISTANCE
+			writeField("")
+		}
+	}
+
+	// Read counts: t_depth, t_ref_count, t_alt_count, n_depth, n_ref_count, n_alt_count
+	writeCount := func(n int) {
+		if n >= 0 {
+			writeInt(int64(n))
+		} else {
+			writeField("")
+		}
+	}
+	for _, g := range []*vcf.Genotype{tumor, normal} {
+		if g == nil {
 			writeField("")
+			writeField("")
+			writeField("")
+			continue
 		}
-		if !m.excludeCols["all_effects"] {
-			writeField(FormatAllEffects(allAnns)) // all_effects
+		writeCount(g.Depth())
+		writeCount(g.RefCount())
+		writeCount(g.AltCount(v.Allele()))
+	}
+
+	if !m.excludeCols["all_effects"] {
+		writeField(FormatAllEffects(allAnns)) // all_effects
+	}
+	for _, set := range appendTranscriptSets(nil, ann, m.transcriptSets) {
+		writeField(set)
+	}
+	if m.mergeCodons {
+		for _, val := range appendMergedCodon(nil, ann, ",") {
+			writeField(val)
 		}
 	}
 
@@ -216,9 +395,14 @@ func (m *VCF2MAFWriter) WriteRow(v *vcf.Variant, ann *annotate.Annotation, allAn
 		}
 	}
 
-	b.WriteByte('\n')
-	_, err := m.w.WriteString(b.String())
-	return err
+	line := b.String()
+	if m.filter != nil && !m.filter.Match(m.columnNames.Row(strings.Split(line, "\t"))) {
+		return nil
+	}
+	if _, err := m.w.WriteString(line); err != nil {
+		return err
+	}
+	return m.w.WriteByte('\n')
 }
 
 // Flush flushes any buffered data.
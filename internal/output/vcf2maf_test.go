@@ -216,6 +216,34 @@ func TestVCF2MAFWriter_Insertion(t *testing.T) {
 	assert.Equal(t, "Frame_Shift_Ins", fields[8]) // Variant_Classification
 }
 
+func TestVCF2MAFWriter_SymbolicDeletion(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewVCF2MAFWriter(&buf, "GRCh38", "SAMPLE")
+	require.NoError(t, w.WriteHeader())
+
+	v := &vcf.Variant{
+		Chrom: "12", Pos: 25190000, ID: ".", Ref: "N", Alt: "<DEL>",
+		RawInfo: "SVTYPE=DEL;END=25226000",
+	}
+	ann := &annotate.Annotation{
+		GeneName:    "KRAS",
+		Consequence: "exon_loss_variant,coding_sequence_variant,feature_truncation",
+		Impact:      "HIGH",
+	}
+
+	require.NoError(t, w.WriteRow(v, ann, []*annotate.Annotation{ann}))
+	require.NoError(t, w.Flush())
+
+	lines := strings.Split(strings.TrimRight(buf.String(), "\n"), "\n")
+	fields := strings.Split(lines[1], "\t")
+	assert.Equal(t, "25190000", fields[5])    // Start
+	assert.Equal(t, "25226000", fields[6])    // End
+	assert.Equal(t, "DEL", fields[9])         // Variant_Type
+	assert.Equal(t, "N", fields[10])          // Reference_Allele
+	assert.Equal(t, "<DEL>", fields[12])      // Tumor_Seq_Allele2
+	assert.Equal(t, "Splice_Site", fields[8]) // Variant_Classification
+}
+
 func TestVCF2MAFWriter_NilAnnotation(t *testing.T) {
 	var buf bytes.Buffer
 	w := NewVCF2MAFWriter(&buf, "GRCh38", "SAMPLE")
@@ -235,3 +263,77 @@ func TestVCF2MAFWriter_NilAnnotation(t *testing.T) {
 	assert.Equal(t, "1", fields[4]) // Chromosome
 	assert.Equal(t, "SNP", fields[9])
 }
+
+// vcf2mafRows writes v through w and returns the data rows as maps keyed by
+// column name.
+func vcf2mafRows(t *testing.T, w *VCF2MAFWriter, buf *bytes.Buffer, v *vcf.Variant) []map[string]string {
+	t.Helper()
+	require.NoError(t, w.WriteHeader())
+	require.NoError(t, w.WriteRow(v, nil, nil))
+	require.NoError(t, w.Flush())
+
+	lines := strings.Split(strings.TrimRight(buf.String(), "\n"), "\n")
+	header := strings.Split(lines[0], "\t")
+	var rows []map[string]string
+	for _, line := range lines[1:] {
+		fields := strings.Split(line, "\t")
+		require.Len(t, fields, len(header))
+		row := make(map[string]string, len(header))
+		for i, col := range header {
+			row[col] = fields[i]
+		}
+		rows = append(rows, row)
+	}
+	return rows
+}
+
+func TestVCF2MAFWriter_TumorNormalCounts(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewVCF2MAFWriter(&buf, "GRCh38", "TUMOR")
+	require.NoError(t, w.SetSamples([]string{"N1", "T1"}, "T1", "N1"))
+
+	v := &vcf.Variant{
+		Chrom: "12", Pos: 25245351, ID: ".", Ref: "C", Alt: "A",
+		SampleColumns: "GT:AD:DP\t0/0:40,0:41\t0/1:30,12:42",
+	}
+	rows := vcf2mafRows(t, w, &buf, v)
+	require.Len(t, rows, 1)
+	row := rows[0]
+	assert.Equal(t, "T1", row["Tumor_Sample_Barcode"])
+	assert.Equal(t, "N1", row["Matched_Norm_Sample_Barcode"])
+	assert.Equal(t, "C", row["Tumor_Seq_Allele1"])
+	assert.Equal(t, "42", row["t_depth"])
+	assert.Equal(t, "30", row["t_ref_count"])
+	assert.Equal(t, "12", row["t_alt_count"])
+	assert.Equal(t, "41", row["n_depth"])
+	assert.Equal(t, "40", row["n_ref_count"])
+	assert.Equal(t, "0", row["n_alt_count"])
+
+	assert.Error(t, w.SetSamples([]string{"N1", "T1"}, "T2", ""))
+}
+
+func TestVCF2MAFWriter_PerCarrierSample(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewVCF2MAFWriter(&buf, "GRCh38", "TUMOR")
+	require.NoError(t, w.SetSamples([]string{"S1", "S2", "S3", "S4", "S5"}, "", ""))
+
+	// Second ALT allele of a split multi-allelic record; S4 and S5 are no-calls.
+	v := vcf.SplitMultiAllelic(&vcf.Variant{
+		Chrom: "1", Pos: 100, ID: ".", Ref: "A", Alt: "C,T",
+		SampleColumns: "GT:AD:DP\t0/1:10,5,0:15\t2/2:0,0,20:20\t0/2:8,0,9\t./.:0,0,0:0\t.",
+	})[1]
+	rows := vcf2mafRows(t, w, &buf, v)
+	require.Len(t, rows, 2)
+
+	assert.Equal(t, "S2", rows[0]["Tumor_Sample_Barcode"])
+	assert.Equal(t, "T", rows[0]["Tumor_Seq_Allele1"], "homozygous ALT")
+	assert.Equal(t, "20", rows[0]["t_alt_count"])
+	assert.Equal(t, "20", rows[0]["t_depth"])
+
+	assert.Equal(t, "S3", rows[1]["Tumor_Sample_Barcode"])
+	assert.Equal(t, "A", rows[1]["Tumor_Seq_Allele1"])
+	assert.Equal(t, "8", rows[1]["t_ref_count"])
+	assert.Equal(t, "9", rows[1]["t_alt_count"])
+	assert.Equal(t, "", rows[1]["t_depth"], "no DP")
+	assert.Equal(t, "", rows[1]["n_depth"])
+}
@@ -6,6 +6,7 @@ import (
 	"testing"
 
 	"github.com/inodb/vibe-vep/internal/annotate"
+	"github.com/inodb/vibe-vep/internal/filter"
 	"github.com/inodb/vibe-vep/internal/vcf"
 )
 
@@ -139,8 +140,8 @@ func TestVCFWriter_SingleAnnotation(t *testing.T) {
 
 	csq := strings.TrimPrefix(info, "CSQ=")
 	parts := strings.Split(csq, "|")
-	if len(parts) != len(csqFields) {
-		t.Fatalf("CSQ has %d fields, want %d", len(parts), len(csqFields))
+	if len(parts) != len(csqFields)+1 { // + DISTANCE
+		t.Fatalf("CSQ has %d fields, want %d", len(parts), len(csqFields)+1)
 	}
 
 	// Check specific CSQ fields
@@ -164,6 +165,174 @@ func TestVCFWriter_SingleAnnotation(t *testing.T) {
 	}
 }
 
+func TestVCFWriter_Distance(t *testing.T) {
+	headers := []string{
+		"##fileformat=VCFv4.2",
+		"#CHROM\tPOS\tID\tREF\tALT\tQUAL\tFILTER\tINFO",
+	}
+
+	var buf bytes.Buffer
+	w := NewVCFWriter(&buf, headers)
+	if err := w.WriteHeader(); err != nil {
+		t.Fatal(err)
+	}
+
+	v := &vcf.Variant{Chrom: "12", Pos: 25251929, Ref: "A", Alt: "G", Info: map[string]interface{}{}}
+	ann := &annotate.Annotation{
+		Allele:       "G",
+		Consequence:  "upstream_gene_variant",
+		Impact:       "MODIFIER",
+		GeneName:     "KRAS",
+		TranscriptID: "ENST00000311936",
+		Distance:     1000,
+	}
+	if err := w.Write(v, ann); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	lines := strings.Split(strings.TrimRight(buf.String(), "\n"), "\n")
+	info := strings.Split(lines[len(lines)-1], "\t")[7]
+	parts := strings.Split(strings.TrimPrefix(info, "CSQ="), "|")
+
+	// DISTANCE is the last CSQ field.
+	if !strings.Contains(buf.String(), "|DISTANCE\">") {
+		t.Error("DISTANCE is not the last field of the CSQ header")
+	}
+	if got := parts[len(parts)-1]; got != "1000" {
+		t.Errorf("DISTANCE = %q, want %q", got, "1000")
+	}
+}
+
+func TestVCFWriter_Nearest(t *testing.T) {
+	headers := []string{
+		"##fileformat=VCFv4.2",
+		"#CHROM\tPOS\tID\tREF\tALT\tQUAL\tFILTER\tINFO",
+	}
+
+	var buf bytes.Buffer
+	w := NewVCFWriter(&buf, headers)
+	w.SetNearest(true)
+	if err := w.WriteHeader(); err != nil {
+		t.Fatal(err)
+	}
+
+	v := &vcf.Variant{Chrom: "12", Pos: 25300929, Ref: "A", Alt: "G", Info: map[string]interface{}{}}
+	ann := &annotate.Annotation{
+		Allele:              "G",
+		Consequence:         "intergenic_variant",
+		Impact:              "MODIFIER",
+		NearestGene:         "KRAS",
+		NearestTranscriptID: "ENST00000311936",
+		NearestDistance:     -20000,
+	}
+	if err := w.Write(v, ann); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	out := buf.String()
+	if !strings.Contains(out, "CANONICAL_MANE|NEAREST|NEAREST_TRANSCRIPT|NEAREST_DISTANCE|DISTANCE\"") {
+		t.Errorf("CSQ header missing NEAREST: %s", out)
+	}
+	lines := strings.Split(strings.TrimRight(out, "\n"), "\n")
+	info := strings.Split(lines[len(lines)-1], "\t")[7]
+	parts := strings.Split(strings.TrimPrefix(info, "CSQ="), "|")
+	if len(parts) != len(csqFields)+4 {
+		t.Fatalf("CSQ has %d fields, want %d", len(parts), len(csqFields)+4)
+	}
+	if got := strings.Join(parts[len(parts)-4:len(parts)-1], "|"); got != "KRAS|ENST00000311936|-20000" {
+		t.Errorf("NEAREST fields = %q, want %q", got, "KRAS|ENST00000311936|-20000")
+	}
+}
+
+func TestVCFWriter_ReportNormalization(t *testing.T) {
+	headers := []string{
+		"##fileformat=VCFv4.2",
+		"#CHROM\tPOS\tID\tREF\tALT\tQUAL\tFILTER\tINFO",
+	}
+
+	var buf bytes.Buffer
+	w := NewVCFWriter(&buf, headers)
+	w.SetReportNormalization(true)
+	if err := w.WriteHeader(); err != nil {
+		t.Fatal(err)
+	}
+
+	v := &vcf.Variant{
+		Chrom: "1", Pos: 11, ID: ".", Ref: "AT", Alt: "A", Filter: "PASS", RawInfo: "DP=10",
+		Original: &vcf.OriginalAllele{Pos: 17, Ref: "TT", Alt: "T"},
+	}
+	ann := &annotate.Annotation{Allele: "A", Consequence: "intron_variant", Impact: "MODIFIER"}
+	if err := w.Write(v, ann); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	out := buf.String()
+	if !strings.Contains(out, "##INFO=<ID=OLD_VARIANT,") {
+		t.Errorf("missing OLD_VARIANT header: %s", out)
+	}
+	lines := strings.Split(strings.TrimRight(out, "\n"), "\n")
+	fields := strings.Split(lines[len(lines)-1], "\t")
+	if fields[1] != "11" || fields[3] != "AT" {
+		t.Errorf("record should use normalized form, got pos=%s ref=%s", fields[1], fields[3])
+	}
+	if !strings.HasPrefix(fields[7], "DP=10;OLD_VARIANT=1:17:TT/T;CSQ=") {
+		t.Errorf("INFO = %q", fields[7])
+	}
+}
+
+func TestVCFWriter_RefCheck(t *testing.T) {
+	headers := []string{
+		"##fileformat=VCFv4.2",
+		"#CHROM\tPOS\tID\tREF\tALT\tQUAL\tFILTER\tINFO",
+	}
+
+	var buf bytes.Buffer
+	w := NewVCFWriter(&buf, headers)
+	w.SetRefCheck(true)
+	if err := w.WriteHeader(); err != nil {
+		t.Fatal(err)
+	}
+
+	bad := &vcf.Variant{Chrom: "1", Pos: 100, ID: ".", Ref: "C", Alt: "T", Filter: "PASS", RawInfo: "."}
+	good := &vcf.Variant{Chrom: "1", Pos: 200, ID: ".", Ref: "A", Alt: "G", Filter: "PASS", RawInfo: "DP=5"}
+	writes := []struct {
+		v   *vcf.Variant
+		ann *annotate.Annotation
+	}{
+		{bad, &annotate.Annotation{Allele: "T", Consequence: "intron_variant", Impact: "MODIFIER", RefMismatch: true}},
+		{good, &annotate.Annotation{Allele: "G", Consequence: "intron_variant", Impact: "MODIFIER"}},
+	}
+	for _, wr := range writes {
+		if err := w.Write(wr.v, wr.ann); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	out := buf.String()
+	if !strings.Contains(out, "##INFO=<ID=REF_MISMATCH,Number=0,Type=Flag,") {
+		t.Errorf("missing REF_MISMATCH header: %s", out)
+	}
+	lines := strings.Split(strings.TrimRight(out, "\n"), "\n")
+	if info := strings.Split(lines[len(lines)-2], "\t")[7]; !strings.HasPrefix(info, "REF_MISMATCH;CSQ=") {
+		t.Errorf("mismatched record INFO = %q", info)
+	}
+	if info := strings.Split(lines[len(lines)-1], "\t")[7]; !strings.HasPrefix(info, "DP=5;CSQ=") {
+		t.Errorf("matching record INFO = %q", info)
+	}
+}
+
 func TestVCFWriter_MultipleAnnotations(t *testing.T) {
 	headers := []string{
 		"##fileformat=VCFv4.2",
@@ -447,3 +616,102 @@ func TestVCFWriter_MultipleVariants(t *testing.T) {
 		t.Errorf("second data line should contain TP53: %s", dataLines[1])
 	}
 }
+
+func TestVCFWriter_FlagPick(t *testing.T) {
+	headers := []string{
+		"##fileformat=VCFv4.2",
+		"#CHROM\tPOS\tID\tREF\tALT\tQUAL\tFILTER\tINFO",
+	}
+
+	var buf bytes.Buffer
+	w := NewVCFWriter(&buf, headers)
+	w.SetFlagPick(PickOrder{PickMANESelect, PickCanonicalMSK})
+	if err := w.WriteHeader(); err != nil {
+		t.Fatal(err)
+	}
+
+	v := &vcf.Variant{Chrom: "12", Pos: 25245351, Ref: "C", Alt: "A", Info: map[string]interface{}{}}
+	anns := []*annotate.Annotation{
+		{Allele: "A", TranscriptID: "ENST00000311936", IsCanonicalMSK: true},
+		{Allele: "A", TranscriptID: "ENST00000256078", IsMANESelect: true},
+		{Allele: "A", TranscriptID: "ENST00000557334"},
+	}
+	for _, ann := range anns {
+		if err := w.Write(v, ann); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	out := buf.String()
+	if !strings.Contains(out, "CANONICAL_MANE|PICK|DISTANCE\"") {
+		t.Errorf("CSQ header missing PICK: %s", out)
+	}
+	lines := strings.Split(strings.TrimRight(out, "\n"), "\n")
+	info := strings.Split(lines[len(lines)-1], "\t")[7]
+	entries := strings.Split(strings.TrimPrefix(info, "CSQ="), ",")
+	if len(entries) != 3 {
+		t.Fatalf("got %d CSQ entries, want all 3 kept", len(entries))
+	}
+	for i, want := range []string{"", "1", ""} {
+		parts := strings.Split(entries[i], "|")
+		if len(parts) != len(csqFields)+2 {
+			t.Fatalf("CSQ has %d fields, want %d", len(parts), len(csqFields)+2)
+		}
+		if got := parts[len(parts)-2]; got != want {
+			t.Errorf("entry %d PICK = %q, want %q", i, got, want)
+		}
+	}
+}
+
+func TestVCFWriter_Filter(t *testing.T) {
+	headers := []string{
+		"##fileformat=VCFv4.2",
+		"#CHROM\tPOS\tID\tREF\tALT\tQUAL\tFILTER\tINFO",
+	}
+	expr, err := filter.Parse("IMPACT in HIGH,MODERATE and DP > 5")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	w := NewVCFWriter(&buf, headers)
+	w.SetFilter(expr)
+	if err := w.WriteHeader(); err != nil {
+		t.Fatal(err)
+	}
+
+	kras := &vcf.Variant{Chrom: "12", Pos: 25245351, Ref: "C", Alt: "A", RawInfo: "DP=10"}
+	for _, ann := range []*annotate.Annotation{
+		{Allele: "A", TranscriptID: "ENST00000256078", Consequence: "missense_variant", Impact: "MODERATE"},
+		{Allele: "A", TranscriptID: "ENST00000557334", Consequence: "downstream_gene_variant", Impact: "MODIFIER"},
+	} {
+		if err := w.Write(kras, ann); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// No entry passes: the record is dropped.
+	other := &vcf.Variant{Chrom: "12", Pos: 25300000, Ref: "G", Alt: "T", RawInfo: "DP=10"}
+	if err := w.Write(other, &annotate.Annotation{Allele: "T", TranscriptID: "ENST00000311936", Consequence: "intron_variant", Impact: "MODIFIER"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	var records []string
+	for _, line := range strings.Split(strings.TrimRight(buf.String(), "\n"), "\n") {
+		if !strings.HasPrefix(line, "#") {
+			records = append(records, line)
+		}
+	}
+	if len(records) != 1 {
+		t.Fatalf("got %d records, want 1: %v", len(records), records)
+	}
+	_, csq, _ := strings.Cut(strings.Split(records[0], "\t")[7], "CSQ=")
+	if entries := strings.Split(csq, ","); len(entries) != 1 || !strings.Contains(entries[0], "ENST00000256078") {
+		t.Errorf("CSQ = %q, want only the missense entry", csq)
+	}
+}
@@ -61,6 +61,7 @@ func AnnotationToRow(chrom string, pos int64, ref, alt string, ann *annotate.Ann
 		IsCanonicalMSK:     ann.IsCanonicalMSK,
 		IsCanonicalEnsembl: ann.IsCanonicalEnsembl,
 		IsMANESelect:       ann.IsMANESelect,
+		TranscriptSets:     strings.Join(ann.TranscriptSets, ","),
 		Allele:       ann.Allele,
 		Biotype:      ann.Biotype,
 		ExonNumber:   ann.ExonNumber,
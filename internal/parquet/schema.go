@@ -30,6 +30,7 @@ type Row struct {
 	IsCanonicalMSK     bool `parquet:"is_canonical_msk,zstd"`
 	IsCanonicalEnsembl bool `parquet:"is_canonical_ensembl,zstd"`
 	IsMANESelect       bool `parquet:"is_mane_select,zstd"`
+	TranscriptSets     string `parquet:"transcript_sets,dict,zstd"` // comma-separated user transcript set names
 	Allele       string `parquet:"allele,zstd"`
 	Biotype      string `parquet:"biotype,dict,zstd"`
 	ExonNumber   string `parquet:"exon_number,zstd"`
@@ -0,0 +1,289 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"go.uber.org/zap"
+
+	"github.com/inodb/vibe-vep/internal/annotate"
+	"github.com/inodb/vibe-vep/internal/output"
+	"github.com/inodb/vibe-vep/internal/vcf"
+)
+
+// errDeadline is reported for batch items that were not annotated before the
+// request deadline.
+var errDeadline = errors.New("request deadline exceeded before the variant was annotated")
+
+// batchItem is one entry of a batch POST: its input label and the variants
+// it resolved to, or the error that stopped it.
+type batchItem struct {
+	input    string
+	variants []*vcf.Variant
+	warning  string // from resolving the input, e.g. a substituted transcript version
+	err      error
+}
+
+// batchResult is the annotation of one variant of a batch item.
+type batchResult struct {
+	variant *vcf.Variant
+	anns    []*annotate.Annotation
+	err     error
+}
+
+// batchRef locates a variant within a batch: items[item].variants[idx].
+type batchRef struct {
+	item, idx int
+}
+
+// requestContext returns the request's context bounded by the server's
+// request timeout, if one is set.
+func (s *Server) requestContext(r *http.Request) (context.Context, context.CancelFunc) {
+	s.mu.RLock()
+	timeout := s.requestTimeout
+	s.mu.RUnlock()
+	if timeout <= 0 {
+		return context.WithCancel(r.Context())
+	}
+	return context.WithTimeout(r.Context(), timeout)
+}
+
+// checkBatchSize records the size of a batch of n items and writes a 413
+// error and returns false if it exceeds the server's maximum batch size.
+func (s *Server) checkBatchSize(w http.ResponseWriter, r *http.Request, n int) bool {
+	s.metrics.observeBatch(r.Pattern, n)
+	s.mu.RLock()
+	limit := s.maxBatchSize
+	s.mu.RUnlock()
+	if limit > 0 && n > limit {
+		writeError(w, http.StatusRequestEntityTooLarge,
+			fmt.Sprintf("batch of %d items exceeds the maximum of %d", n, limit))
+		return false
+	}
+	return true
+}
+
+// resolveBatch resolves each input of a batch to its variants. Inputs that
+// fail to resolve, or resolve to nothing, keep their error; inputs not
+// reached before rctx is done get errDeadline.
+func resolveBatch(rctx context.Context, inputs []string, resolve func(string) ([]*vcf.Variant, error)) []batchItem {
+	return resolveBatchWarn(rctx, inputs, func(in string) ([]*vcf.Variant, string, error) {
+		variants, err := resolve(in)
+		return variants, "", err
+	})
+}
+
+// resolveBatchWarn is resolveBatch for resolvers that also return a warning.
+func resolveBatchWarn(rctx context.Context, inputs []string, resolve func(string) ([]*vcf.Variant, string, error)) []batchItem {
+	items := make([]batchItem, len(inputs))
+	for i, in := range inputs {
+		items[i].input = in
+		if rctx.Err() != nil {
+			items[i].err = errDeadline
+			continue
+		}
+		items[i].variants, items[i].warning, items[i].err = resolve(in)
+		if items[i].err == nil && len(items[i].variants) == 0 {
+			items[i].err = fmt.Errorf("could not resolve variant: %s", in)
+		}
+	}
+	return items
+}
+
+// annotateBatch annotates the variants of all items with
+// Annotator.ParallelAnnotate and runs the assembly's annotation sources on
+// each, in input order, serving cached results where it can. It returns,
+// per item, one result per variant.
+// Variants of items that failed to resolve are skipped; variants not
+// annotated before rctx is done get errDeadline.
+func (s *Server) annotateBatch(rctx context.Context, ctx *assemblyContext, items []batchItem) [][]batchResult {
+	results := make([][]batchResult, len(items))
+	var refs []batchRef
+	var keys []string
+	caching := s.cachingEnabled(ctx)
+	for i, item := range items {
+		if item.err != nil {
+			continue
+		}
+		results[i] = make([]batchResult, len(item.variants))
+		for j, v := range item.variants {
+			results[i][j] = batchResult{variant: v, err: errDeadline}
+			refs = append(refs, batchRef{item: i, idx: j})
+			if caching {
+				keys = append(keys, resultKey(ctx, v))
+			}
+		}
+	}
+
+	// Serve cached results; only the rest go to the annotator.
+	var cached map[string]*cachedResult
+	if caching {
+		cached = s.lookupResults(ctx, keys)
+	}
+	type pendingRef struct {
+		batchRef
+		key string
+	}
+	var pending []pendingRef
+	for n, ref := range refs {
+		if caching {
+			if cr, ok := cached[keys[n]]; ok {
+				res := &results[ref.item][ref.idx]
+				res.anns, res.err = cr.apply(res.variant), nil
+				continue
+			}
+			pending = append(pending, pendingRef{ref, keys[n]})
+			continue
+		}
+		pending = append(pending, pendingRef{batchRef: ref})
+	}
+
+	logger := s.requestLogger(rctx)
+	work := make(chan annotate.WorkItem)
+	go func() {
+		defer close(work)
+		for seq, p := range pending {
+			select {
+			case work <- annotate.WorkItem{Seq: seq, Variant: items[p.item].variants[p.idx], Extra: p, Logger: logger}:
+			case <-rctx.Done():
+				return
+			}
+		}
+	}()
+
+	fresh := make(map[string]*cachedResult)
+	annotate.OrderedCollect(ctx.annotator.ParallelAnnotate(work, 0), func(wr annotate.WorkResult) error {
+		p := wr.Extra.(pendingRef)
+		res := &results[p.item][p.idx]
+		switch {
+		case wr.Err != nil:
+			logger.Error("annotation error", zap.Error(wr.Err), zap.String("input", items[p.item].input))
+			s.metrics.annotationError(ctx.assembly)
+			res.err = fmt.Errorf("annotation failed: %w", wr.Err)
+		case rctx.Err() != nil:
+			// Leave errDeadline: the sources and marshaling are skipped too.
+		default:
+			s.runSources(ctx, wr.Variant, wr.Anns)
+			res.anns, res.err = wr.Anns, nil
+			if caching {
+				fresh[p.key] = newCachedResult(wr.Variant, wr.Anns)
+			}
+		}
+		return nil
+	})
+	if caching {
+		s.saveResults(ctx, fresh)
+	}
+	return results
+}
+
+// marshalGNBatch marshals the annotated batch as genome-nexus annotations,
+// with an error object in place of each input or variant that failed.
+func (s *Server) marshalGNBatch(rctx context.Context, ctx *assemblyContext, items []batchItem, results [][]batchResult, baseOpts output.GNMarshalOptions) []json.RawMessage {
+	out := make([]json.RawMessage, 0, len(items))
+	for i, item := range items {
+		if item.err != nil {
+			out = append(out, gnErrorJSON(item.input, ctx.assembly, item.err.Error()))
+			continue
+		}
+		for _, res := range results[i] {
+			if res.err != nil {
+				out = append(out, gnErrorJSON(item.input, ctx.assembly, res.err.Error()))
+				continue
+			}
+			opts := baseOpts
+			s.enrichMyVariantInfo(rctx, &opts, res.variant, ctx.assembly, res.anns)
+			data, err := output.MarshalGNAnnotation(item.input, res.variant, res.anns, ctx.assembly, opts)
+			if err != nil {
+				data = gnErrorJSON(item.input, ctx.assembly, "marshal error: "+err.Error())
+			}
+			out = append(out, data)
+		}
+	}
+	return out
+}
+
+// marshalVEPBatch marshals the annotated batch as Ensembl VEP results, with
+// an error object in place of each input or variant that failed.
+func marshalVEPBatch(ctx *assemblyContext, items []batchItem, results [][]batchResult, opts output.VEPMarshalOptions) []json.RawMessage {
+	out := make([]json.RawMessage, 0, len(items))
+	for i, item := range items {
+		if item.err != nil {
+			out = append(out, vepErrorJSON(item.input, item.err.Error()))
+			continue
+		}
+		for _, res := range results[i] {
+			if res.err != nil {
+				out = append(out, vepErrorJSON(item.input, res.err.Error()))
+				continue
+			}
+			data, err := output.MarshalVEPAnnotation(item.input, res.variant, res.anns, ctx.assembly, opts)
+			if err != nil {
+				data = vepErrorJSON(item.input, "marshal error: "+err.Error())
+			}
+			out = append(out, data)
+		}
+	}
+	return out
+}
+
+// marshalVibeVepBatch marshals the annotated batch in the vibe-vep native
+// format, with an error object in place of each input or variant that failed.
+func marshalVibeVepBatch(ctx *assemblyContext, items []batchItem, results [][]batchResult) []json.RawMessage {
+	out := make([]json.RawMessage, 0, len(items))
+	for i, item := range items {
+		if item.err != nil {
+			out = append(out, vibeVepErrorJSON(item.input, item.err.Error()))
+			continue
+		}
+		var warnings []string
+		if item.warning != "" {
+			warnings = []string{item.warning}
+		}
+		for _, res := range results[i] {
+			if res.err != nil {
+				out = append(out, vibeVepErrorJSON(item.input, res.err.Error()))
+				continue
+			}
+			data, err := json.Marshal(output.NewVibeVepVariantAnnotation(item.input, res.variant, res.anns, ctx.assembly, warnings))
+			if err != nil {
+				data = vibeVepErrorJSON(item.input, "marshal error: "+err.Error())
+			}
+			out = append(out, data)
+		}
+	}
+	return out
+}
+
+// gnErrorJSON marshals a genome-nexus style error object for one query.
+func gnErrorJSON(variant, assembly, msg string) json.RawMessage {
+	data, _ := json.Marshal(gnError(variant, assembly, msg))
+	return data
+}
+
+// gnError builds the genome-nexus error object written by writeGNError.
+func gnError(variant, assembly, msg string) map[string]interface{} {
+	return map[string]interface{}{
+		"variant":                variant,
+		"originalVariantQuery":   variant,
+		"assembly_name":          assembly,
+		"successfully_annotated": false,
+		"errorMessage":           msg,
+	}
+}
+
+// vepErrorJSON marshals an error object for one input of an Ensembl VEP
+// batch request.
+func vepErrorJSON(input, msg string) json.RawMessage {
+	data, _ := json.Marshal(map[string]string{"input": input, "error": msg})
+	return data
+}
+
+// vibeVepErrorJSON marshals the vibe-vep native error object for one input.
+func vibeVepErrorJSON(input, msg string) json.RawMessage {
+	data, _ := json.Marshal(output.VibeVepError{Error: msg, Input: input})
+	return data
+}
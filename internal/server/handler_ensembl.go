@@ -21,6 +21,7 @@ func (s *Server) handleEnsemblRegionGet(w http.ResponseWriter, r *http.Request)
 	if ctx == nil {
 		return
 	}
+	opts := s.parseVEPMarshalOptions(r)
 
 	region := r.PathValue("region")
 	allele := r.PathValue("allele")
@@ -32,14 +33,14 @@ func (s *Server) handleEnsemblRegionGet(w http.ResponseWriter, r *http.Request)
 	}
 
 	input := region + "/" + allele
-	anns, err := s.annotateVariant(ctx, v)
+	anns, err := s.annotateVariant(r.Context(), ctx, v)
 	if err != nil {
-		s.logger.Error("annotation error", zap.Error(err), zap.String("input", input))
+		s.requestLogger(r.Context()).Error("annotation error", zap.Error(err), zap.String("input", input))
 		writeError(w, http.StatusInternalServerError, "annotation failed: "+err.Error())
 		return
 	}
 
-	data, err := output.MarshalVEPAnnotation(input, v, anns, ctx.assembly)
+	data, err := output.MarshalVEPAnnotation(input, v, anns, ctx.assembly, opts)
 	if err != nil {
 		writeError(w, http.StatusInternalServerError, "marshal error: "+err.Error())
 		return
@@ -54,11 +55,14 @@ func (s *Server) handleEnsemblRegionGet(w http.ResponseWriter, r *http.Request)
 
 // handleEnsemblRegionPost handles POST /ensembl/{assembly}/vep/human/region
 // Body: {"variants": ["7:140753336-140753336:1/T", ...]}
+// Variants are annotated in parallel; one that cannot be parsed or annotated
+// gets an {"input", "error"} object instead of failing the request.
 func (s *Server) handleEnsemblRegionPost(w http.ResponseWriter, r *http.Request) {
 	ctx := s.requireAssembly(w, r)
 	if ctx == nil {
 		return
 	}
+	opts := s.parseVEPMarshalOptions(r)
 
 	var body struct {
 		Variants []string `json:"variants"`
@@ -71,41 +75,28 @@ func (s *Server) handleEnsemblRegionPost(w http.ResponseWriter, r *http.Request)
 		writeError(w, http.StatusBadRequest, "variants array is empty")
 		return
 	}
+	if !s.checkBatchSize(w, r, len(body.Variants)) {
+		return
+	}
 
-	results := make([]json.RawMessage, 0, len(body.Variants))
-	for _, input := range body.Variants {
+	rctx, cancel := s.requestContext(r)
+	defer cancel()
+	items := resolveBatch(rctx, body.Variants, func(input string) ([]*vcf.Variant, error) {
 		// Format: "7:140753336-140753336:1/T" → region="7:140753336-140753336:1", allele="T"
 		lastSlash := strings.LastIndex(input, "/")
 		if lastSlash < 0 {
-			writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid variant format %q (expected region/allele)", input))
-			return
-		}
-		region := input[:lastSlash]
-		allele := input[lastSlash+1:]
-
-		v, err := parseEnsemblRegion(region, allele)
-		if err != nil {
-			writeError(w, http.StatusBadRequest, fmt.Sprintf("variant %q: %s", input, err.Error()))
-			return
+			return nil, fmt.Errorf("invalid variant format %q (expected region/allele)", input)
 		}
-
-		anns, err := s.annotateVariant(ctx, v)
+		v, err := parseEnsemblRegion(input[:lastSlash], input[lastSlash+1:])
 		if err != nil {
-			s.logger.Error("annotation error", zap.Error(err), zap.String("input", input))
-			writeError(w, http.StatusInternalServerError, "annotation failed: "+err.Error())
-			return
+			return nil, err
 		}
-
-		data, err := output.MarshalVEPAnnotation(input, v, anns, ctx.assembly)
-		if err != nil {
-			writeError(w, http.StatusInternalServerError, "marshal error: "+err.Error())
-			return
-		}
-		results = append(results, data)
-	}
+		return []*vcf.Variant{v}, nil
+	})
+	results := s.annotateBatch(rctx, ctx, items)
 
 	w.Header().Set("Content-Type", "application/json")
-	writeJSONArray(w, results)
+	writeJSONArray(w, marshalVEPBatch(ctx, items, results, opts))
 }
 
 // handleEnsemblHGVSGet handles GET /ensembl/{assembly}/vep/human/hgvs/{notation}
@@ -114,6 +105,7 @@ func (s *Server) handleEnsemblHGVSGet(w http.ResponseWriter, r *http.Request) {
 	if ctx == nil {
 		return
 	}
+	opts := s.parseVEPMarshalOptions(r)
 
 	notation := r.PathValue("notation")
 	variants, err := s.resolveHGVS(ctx, notation)
@@ -124,14 +116,14 @@ func (s *Server) handleEnsemblHGVSGet(w http.ResponseWriter, r *http.Request) {
 
 	results := make([]json.RawMessage, 0, len(variants))
 	for _, v := range variants {
-		anns, err := s.annotateVariant(ctx, v)
+		anns, err := s.annotateVariant(r.Context(), ctx, v)
 		if err != nil {
-			s.logger.Error("annotation error", zap.Error(err), zap.String("input", notation))
+			s.requestLogger(r.Context()).Error("annotation error", zap.Error(err), zap.String("input", notation))
 			writeError(w, http.StatusInternalServerError, "annotation failed: "+err.Error())
 			return
 		}
 
-		data, err := output.MarshalVEPAnnotation(notation, v, anns, ctx.assembly)
+		data, err := output.MarshalVEPAnnotation(notation, v, anns, ctx.assembly, opts)
 		if err != nil {
 			writeError(w, http.StatusInternalServerError, "marshal error: "+err.Error())
 			return
@@ -145,11 +137,14 @@ func (s *Server) handleEnsemblHGVSGet(w http.ResponseWriter, r *http.Request) {
 
 // handleEnsemblHGVSPost handles POST /ensembl/{assembly}/vep/human/hgvs
 // Body: {"hgvs_notations": ["ENST00000311936:c.35G>T", ...]}
+// Notations are annotated in parallel; one that cannot be resolved or
+// annotated gets an {"input", "error"} object instead of failing the request.
 func (s *Server) handleEnsemblHGVSPost(w http.ResponseWriter, r *http.Request) {
 	ctx := s.requireAssembly(w, r)
 	if ctx == nil {
 		return
 	}
+	opts := s.parseVEPMarshalOptions(r)
 
 	var body struct {
 		HGVSNotations []string `json:"hgvs_notations"`
@@ -162,34 +157,32 @@ func (s *Server) handleEnsemblHGVSPost(w http.ResponseWriter, r *http.Request) {
 		writeError(w, http.StatusBadRequest, "hgvs_notations array is empty")
 		return
 	}
+	if !s.checkBatchSize(w, r, len(body.HGVSNotations)) {
+		return
+	}
 
-	results := make([]json.RawMessage, 0, len(body.HGVSNotations))
-	for _, notation := range body.HGVSNotations {
-		variants, err := s.resolveHGVS(ctx, notation)
-		if err != nil {
-			writeError(w, http.StatusBadRequest, fmt.Sprintf("notation %q: %s", notation, err.Error()))
-			return
-		}
+	rctx, cancel := s.requestContext(r)
+	defer cancel()
+	items := resolveBatch(rctx, body.HGVSNotations, func(notation string) ([]*vcf.Variant, error) {
+		return s.resolveHGVS(ctx, notation)
+	})
+	results := s.annotateBatch(rctx, ctx, items)
 
-		for _, v := range variants {
-			anns, err := s.annotateVariant(ctx, v)
-			if err != nil {
-				s.logger.Error("annotation error", zap.Error(err), zap.String("input", notation))
-				writeError(w, http.StatusInternalServerError, "annotation failed: "+err.Error())
-				return
-			}
+	w.Header().Set("Content-Type", "application/json")
+	writeJSONArray(w, marshalVEPBatch(ctx, items, results, opts))
+}
 
-			data, err := output.MarshalVEPAnnotation(notation, v, anns, ctx.assembly)
-			if err != nil {
-				writeError(w, http.StatusInternalServerError, "marshal error: "+err.Error())
-				return
-			}
-			results = append(results, data)
-		}
+// parseVEPMarshalOptions reads the pick=1 and flag_pick=1 query parameters of
+// the Ensembl VEP REST API.
+func (s *Server) parseVEPMarshalOptions(r *http.Request) output.VEPMarshalOptions {
+	q := r.URL.Query()
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return output.VEPMarshalOptions{
+		PickOrder: s.pickOrder,
+		Pick:      q.Get("pick") == "1",
+		FlagPick:  q.Get("flag_pick") == "1",
 	}
-
-	w.Header().Set("Content-Type", "application/json")
-	writeJSONArray(w, results)
 }
 
 // parseEnsemblRegion parses "7:140753336-140753336:1" into a vcf.Variant.
@@ -242,20 +235,8 @@ func (s *Server) resolveHGVS(ctx *assemblyContext, notation string) ([]*vcf.Vari
 	if err != nil {
 		return nil, err
 	}
-
-	switch spec.Type {
-	case annotate.SpecGenomic:
-		return []*vcf.Variant{{Chrom: spec.Chrom, Pos: spec.Pos, Ref: spec.Ref, Alt: spec.Alt}}, nil
-	case annotate.SpecHGVSc:
-		variants, _, err := annotate.ReverseMapHGVScWithWarning(ctx.cache, spec.TranscriptID, spec.CDSChange)
-		return variants, err
-	case annotate.SpecHGVSg:
-		return annotate.ResolveHGVSg(ctx.cache, spec.Chrom, spec.GenomicChange)
-	case annotate.SpecProtein:
-		return annotate.ReverseMapProteinChange(ctx.cache, spec.GeneName, spec.RefAA, spec.Position, spec.AltAA)
-	default:
-		return nil, fmt.Errorf("unsupported variant spec type for %q", notation)
-	}
+	variants, _, err := annotate.ResolveVariantSpec(ctx.cache, ctx.annotator.Reference(), ctx.sources, spec)
+	return variants, err
 }
 
 // writeJSONArray writes a JSON array from pre-marshaled elements.
@@ -312,6 +312,37 @@ func TestEnsemblRegionPost_Batch(t *testing.T) {
 	}
 }
 
+func TestEnsemblRegionPost_PartialFailure(t *testing.T) {
+	handler := newTestServerWithKRAS(t).Handler()
+
+	body := `{"variants": ["12:25245351-25245351:1/A", "bad", "12:25245350-25245350:1/T"]}`
+	req := httptest.NewRequest(http.MethodPost, "/ensembl/grch38/vep/human/region", strings.NewReader(body))
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var resp []map[string]any
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if len(resp) != 3 {
+		t.Fatalf("expected 3 results, got %d", len(resp))
+	}
+	if resp[1]["input"] != "bad" {
+		t.Errorf("error input: got %v, want bad", resp[1]["input"])
+	}
+	if msg, _ := resp[1]["error"].(string); !strings.Contains(msg, "expected region/allele") {
+		t.Errorf("error: got %q", msg)
+	}
+	for _, i := range []int{0, 2} {
+		if resp[i]["most_severe_consequence"] == nil {
+			t.Errorf("variant %d: not annotated: %v", i, resp[i])
+		}
+	}
+}
+
 // --- Error cases ---
 
 func TestEnsemblUnknownAssembly(t *testing.T) {
@@ -417,3 +448,46 @@ func contains(ss []string, s string) bool {
 	}
 	return false
 }
+
+func TestEnsemblRegionGet_Pick(t *testing.T) {
+	srv := newTestServerWithKRAS(t)
+	srv.SetPickOrder(output.PickOrder{output.PickCanonicalMSK})
+	handler := srv.Handler()
+
+	get := func(query string) output.VEPVariantAnnotation {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodGet, "/ensembl/grch38/vep/human/region/12:25245351-25245351:1/A?"+query, nil)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+		}
+		var resp []output.VEPVariantAnnotation
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("decode: %v", err)
+		}
+		return resp[0]
+	}
+
+	flagged := get("flag_pick=1")
+	if len(flagged.TranscriptConsequences) < 2 {
+		t.Fatalf("expected all transcript consequences, got %d", len(flagged.TranscriptConsequences))
+	}
+	var picks []string
+	for _, tc := range flagged.TranscriptConsequences {
+		if tc.Pick == 1 {
+			picks = append(picks, tc.TranscriptID)
+		}
+	}
+	if len(picks) != 1 || picks[0] != "ENST00000311936" {
+		t.Errorf("picked %v, want [ENST00000311936]", picks)
+	}
+
+	picked := get("pick=1")
+	if len(picked.TranscriptConsequences) != 1 || picked.TranscriptConsequences[0].TranscriptID != "ENST00000311936" {
+		t.Errorf("pick=1 returned %+v, want only ENST00000311936", picked.TranscriptConsequences)
+	}
+	if picked.TranscriptConsequences[0].Pick != 0 {
+		t.Error("pick field set without flag_pick=1")
+	}
+}
@@ -1,6 +1,7 @@
 package server
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
@@ -34,15 +35,15 @@ func (s *Server) handleGNGenomicGet(w http.ResponseWriter, r *http.Request) {
 	v := gl.ToVariant()
 	inputLabel := gl.FormatInput()
 
-	anns, err := s.annotateVariant(ctx, v)
+	anns, err := s.annotateVariant(r.Context(), ctx, v)
 	if err != nil {
-		s.logger.Error("annotation error", zap.Error(err), zap.String("input", inputLabel))
+		s.requestLogger(r.Context()).Error("annotation error", zap.Error(err), zap.String("input", inputLabel))
 		writeError(w, http.StatusInternalServerError, "annotation failed: "+err.Error())
 		return
 	}
 
-	opts := parseGNMarshalOptions(r)
-	s.enrichMyVariantInfo(&opts, v, ctx.assembly, anns)
+	opts := s.parseGNMarshalOptions(r)
+	s.enrichMyVariantInfo(r.Context(), &opts, v, ctx.assembly, anns)
 	data, err := output.MarshalGNAnnotation(inputLabel, v, anns, ctx.assembly, opts)
 	if err != nil {
 		writeError(w, http.StatusInternalServerError, "marshal error: "+err.Error())
@@ -55,7 +56,8 @@ func (s *Server) handleGNGenomicGet(w http.ResponseWriter, r *http.Request) {
 }
 
 // handleGNGenomicPost handles POST /genome-nexus/{assembly}/annotation/genomic
-// Body: JSON array of GenomicLocation objects.
+// Body: JSON array of GenomicLocation objects. Locations are annotated in
+// parallel; one that fails gets a successfully_annotated=false object.
 func (s *Server) handleGNGenomicPost(w http.ResponseWriter, r *http.Request) {
 	ctx := s.requireAssembly(w, r)
 	if ctx == nil {
@@ -71,32 +73,20 @@ func (s *Server) handleGNGenomicPost(w http.ResponseWriter, r *http.Request) {
 		writeError(w, http.StatusBadRequest, "empty array")
 		return
 	}
+	if !s.checkBatchSize(w, r, len(locations)) {
+		return
+	}
 
-	baseOpts := parseGNMarshalOptions(r)
-	results := make([]json.RawMessage, 0, len(locations))
-	for _, gl := range locations {
-		v := gl.ToVariant()
-		inputLabel := gl.FormatInput()
-
-		anns, err := s.annotateVariant(ctx, v)
-		if err != nil {
-			s.logger.Error("annotation error", zap.Error(err), zap.String("input", inputLabel))
-			writeError(w, http.StatusInternalServerError, "annotation failed: "+err.Error())
-			return
-		}
-
-		opts := baseOpts
-		s.enrichMyVariantInfo(&opts, v, ctx.assembly, anns)
-		data, err := output.MarshalGNAnnotation(inputLabel, v, anns, ctx.assembly, opts)
-		if err != nil {
-			writeError(w, http.StatusInternalServerError, "marshal error: "+err.Error())
-			return
-		}
-		results = append(results, data)
+	rctx, cancel := s.requestContext(r)
+	defer cancel()
+	items := make([]batchItem, len(locations))
+	for i, gl := range locations {
+		items[i] = batchItem{input: gl.FormatInput(), variants: []*vcf.Variant{gl.ToVariant()}}
 	}
+	results := s.annotateBatch(rctx, ctx, items)
 
 	w.Header().Set("Content-Type", "application/json")
-	writeJSONArray(w, results)
+	writeJSONArray(w, s.marshalGNBatch(rctx, ctx, items, results, s.parseGNMarshalOptions(r)))
 }
 
 // handleGNHGVSGet handles GET /genome-nexus/{assembly}/annotation/{variant}
@@ -123,15 +113,15 @@ func (s *Server) handleGNHGVSGet(w http.ResponseWriter, r *http.Request) {
 	}
 
 	v := variants[0]
-	anns, err := s.annotateVariant(ctx, v)
+	anns, err := s.annotateVariant(r.Context(), ctx, v)
 	if err != nil {
-		s.logger.Error("annotation error", zap.Error(err), zap.String("input", notation))
+		s.requestLogger(r.Context()).Error("annotation error", zap.Error(err), zap.String("input", notation))
 		writeGNError(w, notation, ctx.assembly, "annotation failed: "+err.Error())
 		return
 	}
 
-	opts := parseGNMarshalOptions(r)
-	s.enrichMyVariantInfo(&opts, v, ctx.assembly, anns)
+	opts := s.parseGNMarshalOptions(r)
+	s.enrichMyVariantInfo(r.Context(), &opts, v, ctx.assembly, anns)
 	data, err := output.MarshalGNAnnotation(notation, v, anns, ctx.assembly, opts)
 	if err != nil {
 		writeError(w, http.StatusInternalServerError, "marshal error: "+err.Error())
@@ -144,7 +134,8 @@ func (s *Server) handleGNHGVSGet(w http.ResponseWriter, r *http.Request) {
 }
 
 // handleGNHGVSPost handles POST /genome-nexus/{assembly}/annotation
-// Body: JSON array of HGVS notation strings.
+// Body: JSON array of HGVS notation strings. Notations are annotated in
+// parallel; one that fails gets a successfully_annotated=false object.
 func (s *Server) handleGNHGVSPost(w http.ResponseWriter, r *http.Request) {
 	ctx := s.requireAssembly(w, r)
 	if ctx == nil {
@@ -160,43 +151,106 @@ func (s *Server) handleGNHGVSPost(w http.ResponseWriter, r *http.Request) {
 		writeError(w, http.StatusBadRequest, "empty array")
 		return
 	}
+	if !s.checkBatchSize(w, r, len(notations)) {
+		return
+	}
 
-	baseOpts := parseGNMarshalOptions(r)
-	results := make([]json.RawMessage, 0, len(notations))
-	for _, notation := range notations {
-		variants, err := s.resolveHGVS(ctx, notation)
-		if err != nil {
-			writeError(w, http.StatusBadRequest, fmt.Sprintf("notation %q: %s", notation, err.Error()))
-			return
-		}
+	rctx, cancel := s.requestContext(r)
+	defer cancel()
+	items := resolveBatch(rctx, notations, func(notation string) ([]*vcf.Variant, error) {
+		return s.resolveHGVS(ctx, notation)
+	})
+	results := s.annotateBatch(rctx, ctx, items)
 
-		for _, v := range variants {
-			anns, err := s.annotateVariant(ctx, v)
-			if err != nil {
-				s.logger.Error("annotation error", zap.Error(err), zap.String("input", notation))
-				writeError(w, http.StatusInternalServerError, "annotation failed: "+err.Error())
-				return
-			}
+	w.Header().Set("Content-Type", "application/json")
+	writeJSONArray(w, s.marshalGNBatch(rctx, ctx, items, results, s.parseGNMarshalOptions(r)))
+}
 
-			opts := baseOpts
-			s.enrichMyVariantInfo(&opts, v, ctx.assembly, anns)
-			data, err := output.MarshalGNAnnotation(notation, v, anns, ctx.assembly, opts)
-			if err != nil {
-				writeError(w, http.StatusInternalServerError, "marshal error: "+err.Error())
-				return
-			}
-			results = append(results, data)
-		}
+// handleGNDbSNPGet handles GET /genome-nexus/{assembly}/annotation/dbsnp/{rsid}
+// It always returns an array with one annotation per ALT allele, so
+// single- and multi-allelic rsIDs have the same shape.
+func (s *Server) handleGNDbSNPGet(w http.ResponseWriter, r *http.Request) {
+	ctx := s.requireAssembly(w, r)
+	if ctx == nil {
+		return
+	}
+
+	rsid := r.PathValue("rsid")
+	variants, err := annotate.ResolveRSID(ctx.sources, rsid)
+	if err != nil {
+		writeGNError(w, rsid, ctx.assembly, err.Error())
+		return
+	}
+
+	results, err := s.gnAnnotateAll(r.Context(), ctx, rsid, variants, s.parseGNMarshalOptions(r))
+	if err != nil {
+		writeGNError(w, rsid, ctx.assembly, err.Error())
+		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
 	writeJSONArray(w, results)
 }
 
+// handleGNDbSNPPost handles POST /genome-nexus/{assembly}/annotation/dbsnp
+// Body: JSON array of rsIDs. Multi-allelic rsIDs give one annotation per
+// ALT allele; an rsID that fails gets a successfully_annotated=false object.
+func (s *Server) handleGNDbSNPPost(w http.ResponseWriter, r *http.Request) {
+	ctx := s.requireAssembly(w, r)
+	if ctx == nil {
+		return
+	}
+
+	var rsids []string
+	if err := json.NewDecoder(r.Body).Decode(&rsids); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid JSON body: "+err.Error())
+		return
+	}
+	if len(rsids) == 0 {
+		writeError(w, http.StatusBadRequest, "empty array")
+		return
+	}
+	if !s.checkBatchSize(w, r, len(rsids)) {
+		return
+	}
+
+	rctx, cancel := s.requestContext(r)
+	defer cancel()
+	items := resolveBatch(rctx, rsids, func(rsid string) ([]*vcf.Variant, error) {
+		return annotate.ResolveRSID(ctx.sources, rsid)
+	})
+	results := s.annotateBatch(rctx, ctx, items)
+
+	w.Header().Set("Content-Type", "application/json")
+	writeJSONArray(w, s.marshalGNBatch(rctx, ctx, items, results, s.parseGNMarshalOptions(r)))
+}
+
+// gnAnnotateAll annotates each variant resolved from one query and marshals
+// it as a genome-nexus annotation.
+func (s *Server) gnAnnotateAll(rctx context.Context, ctx *assemblyContext, query string, variants []*vcf.Variant, baseOpts output.GNMarshalOptions) ([]json.RawMessage, error) {
+	results := make([]json.RawMessage, 0, len(variants))
+	for _, v := range variants {
+		anns, err := s.annotateVariant(rctx, ctx, v)
+		if err != nil {
+			s.requestLogger(rctx).Error("annotation error", zap.Error(err), zap.String("input", query))
+			return nil, fmt.Errorf("annotation failed: %w", err)
+		}
+
+		opts := baseOpts
+		s.enrichMyVariantInfo(rctx, &opts, v, ctx.assembly, anns)
+		data, err := output.MarshalGNAnnotation(query, v, anns, ctx.assembly, opts)
+		if err != nil {
+			return nil, fmt.Errorf("marshal error: %w", err)
+		}
+		results = append(results, data)
+	}
+	return results, nil
+}
+
 // enrichMyVariantInfo populates opts.MyVariantInfoData from local annotation extras
 // (gnomAD, dbSNP) when available, falling back to myvariant.info API when local data
 // is not available.
-func (s *Server) enrichMyVariantInfo(opts *output.GNMarshalOptions, v *vcf.Variant, assembly string, anns []*annotate.Annotation) {
+func (s *Server) enrichMyVariantInfo(rctx context.Context, opts *output.GNMarshalOptions, v *vcf.Variant, assembly string, anns []*annotate.Annotation) {
 	if !opts.IncludeMyVariantInfo {
 		return
 	}
@@ -236,6 +290,7 @@ func (s *Server) enrichMyVariantInfo(opts *output.GNMarshalOptions, v *vcf.Varia
 				mvi.GnomadExome = buildLocalGnomad(gnomadAF, gnomadAC, gnomadAN, gnomadNhomalt)
 			}
 			opts.MyVariantInfoData = &output.GNMyVariantInfoAnnotation{Annotation: mvi}
+			s.metrics.myVariantInfo(true, false)
 			return
 		}
 	}
@@ -245,9 +300,10 @@ func (s *Server) enrichMyVariantInfo(opts *output.GNMarshalOptions, v *vcf.Varia
 		return
 	}
 	hgvsg := fmt.Sprintf("%s:g.%d%s>%s", v.Chrom, v.Pos, ref, alt)
-	resp, err := s.myVariantClient.Fetch(hgvsg, assembly)
+	resp, err := s.fetchMyVariantInfo(hgvsg, assembly)
 	if err != nil {
-		s.logger.Warn("myvariant.info fetch failed", zap.Error(err), zap.String("hgvsg", hgvsg))
+		s.requestLogger(rctx).Warn("myvariant.info fetch failed", zap.Error(err), zap.String("hgvsg", hgvsg))
+		s.metrics.myVariantInfo(false, true)
 		return
 	}
 	if resp == nil {
@@ -336,9 +392,13 @@ func convertMyVariantInfo(src *myvariantinfo.MyVariantInfo) *output.GNMyVariantI
 
 // parseGNMarshalOptions extracts GN marshal options from query parameters.
 // Supports both comma-separated (?fields=a,b) and repeated (?fields=a&fields=b).
-func parseGNMarshalOptions(r *http.Request) output.GNMarshalOptions {
+// The annotation_summary transcript follows the server's pick order, if set.
+func (s *Server) parseGNMarshalOptions(r *http.Request) output.GNMarshalOptions {
 	fields := strings.Join(r.URL.Query()["fields"], ",")
+	s.mu.RLock()
+	defer s.mu.RUnlock()
 	return output.GNMarshalOptions{
+		PickOrder:                s.pickOrder,
 		IncludeAnnotationSummary: strings.Contains(fields, "annotation_summary"),
 		IncludeClinVar:           strings.Contains(fields, "clinvar"),
 		IncludeHotspots:          strings.Contains(fields, "hotspots"),
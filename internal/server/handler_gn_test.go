@@ -1,14 +1,17 @@
 package server
 
 import (
+	"context"
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
 	"strings"
 	"testing"
 
+	"github.com/inodb/vibe-vep/internal/annotate"
 	"github.com/inodb/vibe-vep/internal/input"
 	"github.com/inodb/vibe-vep/internal/output"
+	"github.com/inodb/vibe-vep/internal/vcf"
 )
 
 // --- GN Genomic GET ---
@@ -160,6 +163,51 @@ func TestGNGenomicPost_Batch(t *testing.T) {
 	}
 }
 
+func TestGNGenomicPost_Deadline(t *testing.T) {
+	handler := newTestServerWithKRAS(t).Handler()
+
+	body := `[{"chromosome":"12","start":25245351,"end":25245351,"referenceAllele":"C","variantAllele":"A"}]`
+	rctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	req := httptest.NewRequest(http.MethodPost, "/genome-nexus/grch38/annotation/genomic", strings.NewReader(body)).WithContext(rctx)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var resp []map[string]any
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if len(resp) != 1 {
+		t.Fatalf("expected 1 result, got %d", len(resp))
+	}
+	if resp[0]["successfully_annotated"] != false {
+		t.Errorf("expected successfully_annotated=false, got %v", resp[0]["successfully_annotated"])
+	}
+	if msg, _ := resp[0]["errorMessage"].(string); !strings.Contains(msg, "deadline") {
+		t.Errorf("errorMessage: got %q", msg)
+	}
+}
+
+func TestGNGenomicPost_MaxBatchSize(t *testing.T) {
+	srv := newTestServerWithKRAS(t)
+	srv.SetBatchLimits(1, 0)
+
+	body := `[
+		{"chromosome":"12","start":25245351,"end":25245351,"referenceAllele":"C","variantAllele":"A"},
+		{"chromosome":"12","start":25245350,"end":25245350,"referenceAllele":"C","variantAllele":"T"}
+	]`
+	req := httptest.NewRequest(http.MethodPost, "/genome-nexus/grch38/annotation/genomic", strings.NewReader(body))
+	w := httptest.NewRecorder()
+	srv.Handler().ServeHTTP(w, req)
+
+	if w.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("expected 413, got %d: %s", w.Code, w.Body.String())
+	}
+}
+
 // --- GN HGVS GET ---
 
 func TestGNHGVSGet_KRASG12C(t *testing.T) {
@@ -239,6 +287,167 @@ func TestGNHGVSPost_Batch(t *testing.T) {
 	}
 }
 
+func TestGNHGVSPost_PartialFailure(t *testing.T) {
+	handler := newTestServerWithKRAS(t).Handler()
+
+	body := `["12:25245351:C:A", "not a variant", "12:25245350:C:T"]`
+	req := httptest.NewRequest(http.MethodPost, "/genome-nexus/grch38/annotation", strings.NewReader(body))
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var resp []output.GNAnnotation
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if len(resp) != 3 {
+		t.Fatalf("expected 3 results, got %d", len(resp))
+	}
+	if !resp[0].SuccessfullyAnnotated || !resp[2].SuccessfullyAnnotated {
+		t.Error("expected the valid notations to be annotated")
+	}
+	if resp[1].SuccessfullyAnnotated {
+		t.Error("expected successfully_annotated=false for the invalid notation")
+	}
+	if resp[1].OriginalVariantQuery != "not a variant" || resp[1].AssemblyName != "GRCh38" {
+		t.Errorf("error object: query %q, assembly %q", resp[1].OriginalVariantQuery, resp[1].AssemblyName)
+	}
+}
+
+// --- GN dbSNP ---
+
+// rsidSource resolves rsIDs from a map, standing in for the genomic index.
+type rsidSource map[string][]*vcf.Variant
+
+func (rsidSource) Name() string                                  { return "rsid" }
+func (rsidSource) Version() string                               { return "test" }
+func (rsidSource) MatchLevel() annotate.MatchLevel               { return annotate.MatchGenomic }
+func (rsidSource) Columns() []annotate.ColumnDef                 { return nil }
+func (rsidSource) Annotate(*vcf.Variant, []*annotate.Annotation) {}
+
+func (s rsidSource) LookupRSID(rsid string) ([]*vcf.Variant, error) {
+	return s[rsid], nil
+}
+
+// newTestServerWithDbSNP creates a KRAS server whose sources resolve
+// rs121913529 (multi-allelic, C>A/G) and rs112445441 (C>T).
+func newTestServerWithDbSNP(t *testing.T) *Server {
+	t.Helper()
+	srv := newTestServerWithKRAS(t)
+	ctx := srv.getAssembly("grch38")
+	ctx.sources = []annotate.AnnotationSource{rsidSource{
+		"rs121913529": {
+			{Chrom: "12", Pos: 25245351, Ref: "C", Alt: "A", ID: "rs121913529"},
+			{Chrom: "12", Pos: 25245351, Ref: "C", Alt: "G", ID: "rs121913529"},
+		},
+		"rs112445441": {
+			{Chrom: "12", Pos: 25245350, Ref: "C", Alt: "T", ID: "rs112445441"},
+		},
+	}}
+	return srv
+}
+
+func TestGNDbSNPGet_SingleAllele(t *testing.T) {
+	handler := newTestServerWithDbSNP(t).Handler()
+
+	req := httptest.NewRequest(http.MethodGet, "/genome-nexus/grch38/annotation/dbsnp/rs112445441", nil)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	// A single-allele rsID still gives an array, like multi-allelic ones.
+	var results []output.GNAnnotation
+	if err := json.Unmarshal(w.Body.Bytes(), &results); err != nil {
+		t.Fatalf("decode: %v\nbody: %s", err, w.Body.String())
+	}
+	if len(results) != 1 {
+		t.Fatalf("expected 1 annotation, got %d", len(results))
+	}
+	resp := results[0]
+	if !resp.SuccessfullyAnnotated {
+		t.Error("expected successfully_annotated=true")
+	}
+	if resp.OriginalVariantQuery != "rs112445441" {
+		t.Errorf("originalVariantQuery: got %q, want rs112445441", resp.OriginalVariantQuery)
+	}
+	if resp.AlleleString != "C/T" {
+		t.Errorf("allele_string: got %q, want C/T", resp.AlleleString)
+	}
+}
+
+func TestGNDbSNPGet_MultiAllelic(t *testing.T) {
+	handler := newTestServerWithDbSNP(t).Handler()
+
+	req := httptest.NewRequest(http.MethodGet, "/genome-nexus/grch38/annotation/dbsnp/rs121913529", nil)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var resp []output.GNAnnotation
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode: %v\nbody: %s", err, w.Body.String())
+	}
+	if len(resp) != 2 {
+		t.Fatalf("expected one annotation per ALT, got %d", len(resp))
+	}
+	for i, want := range []string{"C/A", "C/G"} {
+		if resp[i].AlleleString != want {
+			t.Errorf("annotation %d allele_string: got %q, want %q", i, resp[i].AlleleString, want)
+		}
+		if resp[i].MostSevereConsequence != "missense_variant" {
+			t.Errorf("annotation %d: got %q, want missense_variant", i, resp[i].MostSevereConsequence)
+		}
+	}
+}
+
+func TestGNDbSNPGet_NotFound(t *testing.T) {
+	handler := newTestServerWithDbSNP(t).Handler()
+
+	req := httptest.NewRequest(http.MethodGet, "/genome-nexus/grch38/annotation/dbsnp/rs1", nil)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	var resp map[string]any
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if resp["successfully_annotated"] != false {
+		t.Errorf("expected successfully_annotated=false, got %v", resp["successfully_annotated"])
+	}
+	if msg, _ := resp["errorMessage"].(string); !strings.Contains(msg, "not found in dbSNP") {
+		t.Errorf("errorMessage: got %q", msg)
+	}
+}
+
+func TestGNDbSNPPost_Batch(t *testing.T) {
+	handler := newTestServerWithDbSNP(t).Handler()
+
+	body := `["rs121913529", "rs112445441"]`
+	req := httptest.NewRequest(http.MethodPost, "/genome-nexus/grch38/annotation/dbsnp", strings.NewReader(body))
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var resp []output.GNAnnotation
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if len(resp) != 3 {
+		t.Fatalf("expected 3 annotations, got %d", len(resp))
+	}
+	if resp[2].OriginalVariantQuery != "rs112445441" {
+		t.Errorf("last annotation query: got %q, want rs112445441", resp[2].OriginalVariantQuery)
+	}
+}
+
 // --- Error cases ---
 
 func TestGNGenomicBadLocation(t *testing.T) {
@@ -0,0 +1,148 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/inodb/vibe-vep/internal/annotate"
+)
+
+// hgvsNormalizeResponse is the JSON result of normalizing one notation.
+type hgvsNormalizeResponse struct {
+	Input    string                  `json:"input"`
+	Valid    bool                    `json:"valid"`
+	Error    string                  `json:"error,omitempty"`
+	Warning  string                  `json:"warning,omitempty"`
+	Variants []hgvsNormalizedVariant `json:"variants,omitempty"`
+}
+
+type hgvsNormalizedVariant struct {
+	Chrom       string                     `json:"chrom"`
+	Pos         int64                      `json:"pos"`
+	Ref         string                     `json:"ref"`
+	Alt         string                     `json:"alt"`
+	HGVSg       string                     `json:"hgvsg"`
+	Changes     []hgvsChangeJSON           `json:"changes"`
+	Transcripts []hgvsNormalizedTranscript `json:"transcripts"`
+}
+
+type hgvsChangeJSON struct {
+	Field  string `json:"field"`
+	From   string `json:"from"`
+	To     string `json:"to"`
+	Reason string `json:"reason"`
+}
+
+type hgvsNormalizedTranscript struct {
+	TranscriptID string `json:"transcript_id"`
+	GeneSymbol   string `json:"gene_symbol"`
+	Canonical    bool   `json:"canonical"`
+	MANESelect   bool   `json:"mane_select"`
+	Consequence  string `json:"consequence"`
+	HGVSc        string `json:"hgvsc"`
+	HGVSp        string `json:"hgvsp,omitempty"`
+}
+
+// handleHGVSNormalize handles POST /hgvs/normalize?assembly=GRCh38
+// Body: JSON array of notations in any format annotate.ParseVariantSpec
+// accepts. Each notation gets a result; notations that cannot be resolved
+// are reported with valid=false and an error instead of failing the request,
+// as are those not reached before the request deadline.
+func (s *Server) handleHGVSNormalize(w http.ResponseWriter, r *http.Request) {
+	ctx := s.requireQueryAssembly(w, r)
+	if ctx == nil {
+		return
+	}
+
+	var notations []string
+	if err := json.NewDecoder(r.Body).Decode(&notations); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid JSON body: "+err.Error())
+		return
+	}
+	if len(notations) == 0 {
+		writeError(w, http.StatusBadRequest, "empty array")
+		return
+	}
+	if !s.checkBatchSize(w, r, len(notations)) {
+		return
+	}
+
+	rctx, cancel := s.requestContext(r)
+	defer cancel()
+	results := make([]hgvsNormalizeResponse, 0, len(notations))
+	for _, notation := range notations {
+		if rctx.Err() != nil {
+			results = append(results, hgvsNormalizeResponse{Input: strings.TrimSpace(notation), Error: errDeadline.Error()})
+			continue
+		}
+		results = append(results, normalizeHGVS(ctx, notation))
+	}
+	writeJSON(w, http.StatusOK, results)
+}
+
+// normalizeHGVS normalizes one notation in the given assembly context.
+func normalizeHGVS(ctx *assemblyContext, notation string) hgvsNormalizeResponse {
+	resp := hgvsNormalizeResponse{Input: strings.TrimSpace(notation)}
+	res, err := annotate.NormalizeHGVS(ctx.annotator, ctx.cache, ctx.sources, notation)
+	if err != nil {
+		resp.Error = err.Error()
+		return resp
+	}
+	resp.Valid = true
+	resp.Warning = res.Warning
+	for _, nv := range res.Variants {
+		v := nv.Variant
+		out := hgvsNormalizedVariant{
+			Chrom:       v.Chrom,
+			Pos:         v.Pos,
+			Ref:         v.Ref,
+			Alt:         v.Alt,
+			HGVSg:       nv.HGVSg,
+			Changes:     make([]hgvsChangeJSON, 0, len(nv.Changes)),
+			Transcripts: make([]hgvsNormalizedTranscript, 0, len(nv.Annotations)),
+		}
+		for _, c := range nv.Changes {
+			out.Changes = append(out.Changes, hgvsChangeJSON(c))
+		}
+		for _, a := range nv.Annotations {
+			out.Transcripts = append(out.Transcripts, hgvsNormalizedTranscript{
+				TranscriptID: a.TranscriptID,
+				GeneSymbol:   a.GeneName,
+				Canonical:    a.IsCanonicalMSK,
+				MANESelect:   a.IsMANESelect,
+				Consequence:  a.Consequence,
+				HGVSc:        a.HGVSc,
+				HGVSp:        a.HGVSp,
+			})
+		}
+		resp.Variants = append(resp.Variants, out)
+	}
+	return resp
+}
+
+// requireQueryAssembly is requireAssembly for endpoints that take the
+// assembly as the ?assembly= parameter, defaulting to defaultAssembly.
+func (s *Server) requireQueryAssembly(w http.ResponseWriter, r *http.Request) *assemblyContext {
+	name := r.URL.Query().Get("assembly")
+	if name == "" {
+		name = s.defaultAssembly()
+	}
+	ctx := s.acquireAssembly(r, name)
+	if ctx == nil {
+		writeError(w, http.StatusNotFound, "assembly "+name+" not loaded (pass ?assembly=)")
+	}
+	return ctx
+}
+
+// defaultAssembly returns the only loaded assembly, else "grch38".
+func (s *Server) defaultAssembly() string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if len(s.assemblies) == 1 {
+		for name := range s.assemblies {
+			return name
+		}
+	}
+	return "grch38"
+}
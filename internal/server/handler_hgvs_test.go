@@ -0,0 +1,77 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestHGVSNormalizePost(t *testing.T) {
+	handler := newTestServerWithKRAS(t).Handler()
+
+	body := `["KRAS G12C", "ENST00000311936:c.34G>T", "not a variant"]`
+	req := httptest.NewRequest(http.MethodPost, "/hgvs/normalize", strings.NewReader(body))
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var resp []hgvsNormalizeResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode: %v\nbody: %s", err, w.Body.String())
+	}
+	if len(resp) != 3 {
+		t.Fatalf("expected 3 results, got %d", len(resp))
+	}
+
+	// Single-letter protein change: canonical three-letter HGVSp.
+	g12c := resp[0]
+	if !g12c.Valid || len(g12c.Variants) == 0 {
+		t.Fatalf("KRAS G12C: %+v", g12c)
+	}
+	v := g12c.Variants[0]
+	if v.HGVSg != "12:g.25245351C>A" {
+		t.Errorf("hgvsg: got %q, want 12:g.25245351C>A", v.HGVSg)
+	}
+	if len(v.Changes) != 1 || v.Changes[0].Field != "hgvsp" || v.Changes[0].To != "p.Gly12Cys" {
+		t.Errorf("changes: got %+v, want hgvsp → p.Gly12Cys", v.Changes)
+	}
+	var found bool
+	for _, tr := range v.Transcripts {
+		if strings.HasPrefix(tr.TranscriptID, "ENST00000311936") {
+			found = true
+			if tr.HGVSc != "c.34G>T" || tr.HGVSp != "p.Gly12Cys" {
+				t.Errorf("canonical transcript: got %s %s", tr.HGVSc, tr.HGVSp)
+			}
+		}
+	}
+	if !found {
+		t.Error("canonical transcript ENST00000311936 not reported")
+	}
+
+	// Unversioned transcript: the version is added.
+	hgvsc := resp[1]
+	if !hgvsc.Valid || len(hgvsc.Variants) != 1 {
+		t.Fatalf("ENST00000311936:c.34G>T: %+v", hgvsc)
+	}
+
+	// Unparseable notations are reported per item.
+	if resp[2].Valid || resp[2].Error == "" {
+		t.Errorf("expected an error for an unparseable notation, got %+v", resp[2])
+	}
+}
+
+func TestHGVSNormalizeUnknownAssembly(t *testing.T) {
+	handler := newTestServer(t).Handler()
+
+	req := httptest.NewRequest(http.MethodPost, "/hgvs/normalize?assembly=hg19", strings.NewReader(`["KRAS G12C"]`))
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	if w.Code != http.StatusNotFound {
+		t.Errorf("expected 404, got %d", w.Code)
+	}
+}
@@ -0,0 +1,200 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/inodb/vibe-vep/internal/annotate"
+	"github.com/inodb/vibe-vep/internal/output"
+	"github.com/inodb/vibe-vep/internal/vcf"
+)
+
+// ndjsonContentType is the media type of the vibe-vep-jsonl response: one
+// JSON object per line.
+const ndjsonContentType = "application/x-ndjson"
+
+// vcfHeader is prepended to VCF bodies that have no header lines.
+const vcfHeader = "#CHROM\tPOS\tID\tREF\tALT\tQUAL\tFILTER\tINFO\n"
+
+// handleV1AnnotateGet handles GET /v1/annotate?variant=...&assembly=GRCh38
+// The variant is any spec annotate.ParseVariantSpec accepts. The response is
+// an array of vibe-vep annotations, one per genomic variant the spec
+// resolves to; as for POST, a variant that fails gets an {"error", "input"}
+// object in its place. Only when all of them fail is the request an error.
+func (s *Server) handleV1AnnotateGet(w http.ResponseWriter, r *http.Request) {
+	ctx := s.requireQueryAssembly(w, r)
+	if ctx == nil {
+		return
+	}
+	spec := strings.TrimSpace(r.URL.Query().Get("variant"))
+	if spec == "" {
+		writeError(w, http.StatusBadRequest, "missing variant parameter")
+		return
+	}
+
+	rctx, cancel := s.requestContext(r)
+	defer cancel()
+	items := resolveBatchWarn(rctx, []string{spec}, s.resolveSpec(ctx))
+	if items[0].err != nil {
+		writeJSON(w, v1ErrorStatus(items[0].err, http.StatusBadRequest), output.VibeVepError{Error: items[0].err.Error(), Input: spec})
+		return
+	}
+	results := s.annotateBatch(rctx, ctx, items)
+	var firstErr error
+	failed := 0
+	for _, res := range results[0] {
+		if res.err != nil {
+			failed++
+			if firstErr == nil {
+				firstErr = res.err
+			}
+		}
+	}
+	if failed == len(results[0]) && firstErr != nil {
+		writeJSON(w, v1ErrorStatus(firstErr, http.StatusInternalServerError), output.VibeVepError{Error: firstErr.Error(), Input: spec})
+		return
+	}
+	writeVibeVep(w, r, marshalVibeVepBatch(ctx, items, results))
+}
+
+// v1ErrorStatus returns the status of a GET /v1/annotate error: 504 if the
+// variant was not handled before the request deadline, else code.
+func v1ErrorStatus(err error, code int) int {
+	if errors.Is(err, errDeadline) {
+		return http.StatusGatewayTimeout
+	}
+	return code
+}
+
+// handleV1AnnotatePost handles POST /v1/annotate?assembly=GRCh38
+// Body: a JSON array of variant specs, or VCF text (header lines optional).
+// Each spec or VCF record gets one annotation per variant (multi-allelic
+// records one per ALT allele), in input order; one that fails gets an
+// {"error", "input"} object instead.
+func (s *Server) handleV1AnnotatePost(w http.ResponseWriter, r *http.Request) {
+	ctx := s.requireQueryAssembly(w, r)
+	if ctx == nil {
+		return
+	}
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "read body: "+err.Error())
+		return
+	}
+	body = bytes.TrimSpace(body)
+	if len(body) == 0 {
+		writeError(w, http.StatusBadRequest, "empty body")
+		return
+	}
+
+	rctx, cancel := s.requestContext(r)
+	defer cancel()
+	var items []batchItem
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType == "application/json" || body[0] == '[' {
+		var specs []string
+		if err := json.Unmarshal(body, &specs); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid JSON body: "+err.Error())
+			return
+		}
+		if len(specs) == 0 {
+			writeError(w, http.StatusBadRequest, "empty array")
+			return
+		}
+		if !s.checkBatchSize(w, r, len(specs)) {
+			return
+		}
+		items = resolveBatchWarn(rctx, specs, s.resolveSpec(ctx))
+	} else {
+		items, err = parseVCFBatch(body)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "invalid VCF: "+err.Error())
+			return
+		}
+		if len(items) == 0 {
+			writeError(w, http.StatusBadRequest, "no VCF records")
+			return
+		}
+		if !s.checkBatchSize(w, r, len(items)) {
+			return
+		}
+	}
+
+	results := s.annotateBatch(rctx, ctx, items)
+	writeVibeVep(w, r, marshalVibeVepBatch(ctx, items, results))
+}
+
+// resolveSpec returns a resolver of variant specs in the given assembly
+// context, for resolveBatchWarn.
+func (s *Server) resolveSpec(ctx *assemblyContext) func(string) ([]*vcf.Variant, string, error) {
+	return func(input string) ([]*vcf.Variant, string, error) {
+		spec, err := annotate.ParseVariantSpec(input)
+		if err != nil {
+			return nil, "", err
+		}
+		return annotate.ResolveVariantSpec(ctx.cache, ctx.annotator.Reference(), ctx.sources, spec)
+	}
+}
+
+// parseVCFBatch parses VCF text into one batch item per record, labelled
+// chrom:pos:ref:alt. Records that fail to parse become items with the
+// error, labelled by line number.
+func parseVCFBatch(body []byte) ([]batchItem, error) {
+	offset := 0
+	if body[0] != '#' {
+		body = append([]byte(vcfHeader), body...)
+		offset = 1
+	}
+	// The parser drops a last line without a newline.
+	body = append(body, '\n')
+	p, err := vcf.NewParserFromReader(bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+
+	var items []batchItem
+	for {
+		v, err := p.Next()
+		if err != nil {
+			var pe *vcf.ParseError
+			if errors.As(err, &pe) {
+				pe.Line -= offset
+			}
+			items = append(items, batchItem{input: fmt.Sprintf("line %d", p.LineNumber()-offset), err: err})
+			continue
+		}
+		if v == nil {
+			return items, nil
+		}
+		items = append(items, batchItem{
+			input:    fmt.Sprintf("%s:%d:%s:%s", v.Chrom, v.Pos, v.Ref, v.Alt),
+			variants: vcf.SplitMultiAllelic(v),
+		})
+	}
+}
+
+// writeVibeVep writes vibe-vep annotations as a JSON array or, if the client
+// accepts application/x-ndjson, as JSON lines like vibe-vep-jsonl output.
+func writeVibeVep(w http.ResponseWriter, r *http.Request, items []json.RawMessage) {
+	if !strings.Contains(r.Header.Get("Accept"), ndjsonContentType) {
+		w.Header().Set("Content-Type", "application/json")
+		writeJSONArray(w, items)
+		return
+	}
+	w.Header().Set("Content-Type", ndjsonContentType)
+	for _, item := range items {
+		w.Write(item)
+		w.Write([]byte("\n"))
+	}
+}
+
+// handleOpenAPI handles GET /v1/openapi.json.
+func (s *Server) handleOpenAPI(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, openAPIDocument(s.version))
+}
@@ -0,0 +1,300 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/inodb/vibe-vep/internal/annotate"
+	"github.com/inodb/vibe-vep/internal/output"
+	"github.com/inodb/vibe-vep/internal/vcf"
+)
+
+func TestV1AnnotateGet(t *testing.T) {
+	handler := newTestServerWithKRAS(t).Handler()
+
+	req := httptest.NewRequest(http.MethodGet, "/v1/annotate?variant="+url.QueryEscape("12:25245351:C:A"), nil)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var results []output.VibeVepVariantAnnotation
+	if err := json.Unmarshal(w.Body.Bytes(), &results); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if len(results) != 1 {
+		t.Fatalf("expected 1 annotation, got %d", len(results))
+	}
+	res := results[0]
+	if res.Input != "12:25245351:C:A" || res.Assembly != "GRCh38" || res.MostSevereConsequence != "missense_variant" {
+		t.Errorf("unexpected annotation: input %q, assembly %q, most severe %q", res.Input, res.Assembly, res.MostSevereConsequence)
+	}
+	var canonical bool
+	for _, tc := range res.TranscriptConsequences {
+		if tc.CanonicalMSKCC && tc.HugoSymbol == "KRAS" {
+			canonical = true
+			if tc.HGVSpShort != "p.G12C" {
+				t.Errorf("canonical hgvsp_short: got %q, want p.G12C", tc.HGVSpShort)
+			}
+		}
+	}
+	if !canonical {
+		t.Error("no canonical KRAS consequence")
+	}
+}
+
+func TestV1AnnotateGet_Errors(t *testing.T) {
+	handler := newTestServerWithKRAS(t).Handler()
+	tests := []struct {
+		path string
+		code int
+	}{
+		{"/v1/annotate", http.StatusBadRequest},
+		{"/v1/annotate?variant=not-a-variant", http.StatusBadRequest},
+		{"/v1/annotate?variant=12:25245351:C:A&assembly=GRCh37", http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.path, nil))
+		if w.Code != tt.code {
+			t.Errorf("%s: got %d, want %d: %s", tt.path, w.Code, tt.code, w.Body.String())
+		}
+	}
+}
+
+func TestV1AnnotateGet_Deadline(t *testing.T) {
+	handler := newTestServerWithKRAS(t).Handler()
+
+	rctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	req := httptest.NewRequest(http.MethodGet, "/v1/annotate?variant=12:25245351:C:A", nil).WithContext(rctx)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if w.Code != http.StatusGatewayTimeout {
+		t.Fatalf("expected 504, got %d: %s", w.Code, w.Body.String())
+	}
+	var res output.VibeVepError
+	if err := json.Unmarshal(w.Body.Bytes(), &res); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if res.Input != "12:25245351:C:A" || !strings.Contains(res.Error, "deadline") {
+		t.Errorf("unexpected error: %+v", res)
+	}
+}
+
+// altLifter is a liftover that fails for one ALT allele and keeps the rest.
+type altLifter string
+
+func (a altLifter) LiftVariant(v *vcf.Variant, ref annotate.ReferenceSequence) (*vcf.Variant, error) {
+	if v.Alt == string(a) {
+		return nil, errors.New("no chain for position")
+	}
+	return v, nil
+}
+
+func TestV1AnnotateGet_PartialFailure(t *testing.T) {
+	srv := newTestServerWithDbSNP(t)
+	srv.getAssembly("grch38").annotator.SetLiftover(altLifter("G"))
+	handler := srv.Handler()
+
+	// One allele of a multi-allelic rsID fails: the other is still returned.
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/annotate?variant=rs121913529", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var results []map[string]any
+	if err := json.Unmarshal(w.Body.Bytes(), &results); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if len(results) != 2 {
+		t.Fatalf("expected 2 results, got %d: %s", len(results), w.Body.String())
+	}
+	if results[0]["error"] != nil || results[0]["variant_allele"] != "A" {
+		t.Errorf("first allele: got %v, want an annotation of C>A", results[0])
+	}
+	if msg, _ := results[1]["error"].(string); !strings.Contains(msg, "liftover") || results[1]["input"] != "rs121913529" {
+		t.Errorf("second allele: got %v, want a liftover error", results[1])
+	}
+
+	// When every allele fails, the request does.
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/annotate?variant=12:25245351:C:G", nil))
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("expected 500, got %d: %s", w.Code, w.Body.String())
+	}
+}
+
+func TestV1AnnotatePost_Specs(t *testing.T) {
+	handler := newTestServerWithKRAS(t).Handler()
+
+	body := `["12:25245351:C:A", "not-a-variant", "KRAS G12C"]`
+	req := httptest.NewRequest(http.MethodPost, "/v1/annotate?assembly=grch38", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var results []map[string]any
+	if err := json.Unmarshal(w.Body.Bytes(), &results); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if len(results) < 3 {
+		t.Fatalf("expected at least 3 results, got %d", len(results))
+	}
+	if results[0]["input"] != "12:25245351:C:A" || results[0]["error"] != nil {
+		t.Errorf("first result: %v", results[0])
+	}
+	if results[1]["input"] != "not-a-variant" || results[1]["error"] == nil {
+		t.Errorf("expected an error for the second input, got %v", results[1])
+	}
+	for _, res := range results[2:] {
+		if res["input"] != "KRAS G12C" || res["error"] != nil {
+			t.Errorf("protein result: %v", res)
+		}
+	}
+}
+
+func TestV1AnnotatePost_VCF(t *testing.T) {
+	handler := newTestServerWithKRAS(t).Handler()
+
+	body := "12\t25245351\t.\tC\tA,T\t.\t.\t.\n" +
+		"12\tnot-a-position\t.\tC\tA\t.\t.\t.\n" +
+		"chr12\t25245350\t.\tC\tT\t.\t.\t."
+	req := httptest.NewRequest(http.MethodPost, "/v1/annotate", strings.NewReader(body))
+	req.Header.Set("Content-Type", "text/plain")
+	req.Header.Set("Accept", ndjsonContentType)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if ct := w.Header().Get("Content-Type"); ct != ndjsonContentType {
+		t.Errorf("Content-Type: got %q", ct)
+	}
+
+	var lines []map[string]any
+	sc := bufio.NewScanner(w.Body)
+	for sc.Scan() {
+		var line map[string]any
+		if err := json.Unmarshal(sc.Bytes(), &line); err != nil {
+			t.Fatalf("line %d: %v", len(lines)+1, err)
+		}
+		lines = append(lines, line)
+	}
+	// The multi-allelic record gives one annotation per ALT allele.
+	want := []struct{ input, alt, err string }{
+		{"12:25245351:C:A,T", "A", ""},
+		{"12:25245351:C:A,T", "T", ""},
+		{"line 2", "", "vcf parse error at line 2: invalid position: not-a-position"},
+		{"chr12:25245350:C:T", "T", ""},
+	}
+	if len(lines) != len(want) {
+		t.Fatalf("expected %d lines, got %d: %s", len(want), len(lines), w.Body.String())
+	}
+	for i, wt := range want {
+		got := lines[i]
+		if got["input"] != wt.input {
+			t.Errorf("line %d input: got %v, want %q", i, got["input"], wt.input)
+		}
+		if wt.err != "" {
+			if got["error"] != wt.err {
+				t.Errorf("line %d error: got %v, want %q", i, got["error"], wt.err)
+			}
+			continue
+		}
+		if got["variant_allele"] != wt.alt {
+			t.Errorf("line %d variant_allele: got %v, want %q", i, got["variant_allele"], wt.alt)
+		}
+	}
+}
+
+func TestV1OpenAPI(t *testing.T) {
+	handler := newTestServer(t).Handler()
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/openapi.json", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", w.Code)
+	}
+
+	var doc struct {
+		OpenAPI    string                    `json:"openapi"`
+		Paths      map[string]map[string]any `json:"paths"`
+		Components struct {
+			Schemas map[string]struct {
+				Properties map[string]any `json:"properties"`
+				Required   []string       `json:"required"`
+			} `json:"schemas"`
+		} `json:"components"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &doc); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if doc.OpenAPI != "3.0.3" {
+		t.Errorf("openapi: got %q", doc.OpenAPI)
+	}
+	for _, method := range []string{"get", "post"} {
+		if doc.Paths["/v1/annotate"][method] == nil {
+			t.Errorf("missing %s /v1/annotate", method)
+		}
+	}
+	if get, _ := doc.Paths["/v1/annotate"]["get"].(map[string]any); get["responses"].(map[string]any)["504"] == nil {
+		t.Error("GET /v1/annotate: missing 504 response")
+	}
+
+	// Every JSON field of the output types is in the generated schemas.
+	for _, typ := range []reflect.Type{
+		reflect.TypeFor[output.VibeVepVariantAnnotation](),
+		reflect.TypeFor[output.VibeVepTranscriptConsequence](),
+		reflect.TypeFor[output.VibeVepError](),
+	} {
+		schema, ok := doc.Components.Schemas[typ.Name()]
+		if !ok {
+			t.Errorf("missing schema %s", typ.Name())
+			continue
+		}
+		for i := range typ.NumField() {
+			name, _, _ := strings.Cut(typ.Field(i).Tag.Get("json"), ",")
+			if _, ok := schema.Properties[name]; !ok {
+				t.Errorf("%s: missing property %s", typ.Name(), name)
+			}
+		}
+	}
+	if req := doc.Components.Schemas["VibeVepTranscriptConsequence"].Required; !reflect.DeepEqual(req, []string{"transcript_id", "consequence", "impact"}) {
+		t.Errorf("VibeVepTranscriptConsequence required: got %v", req)
+	}
+}
+
+func TestSchemaRef_AnonymousStructs(t *testing.T) {
+	type named struct {
+		A struct {
+			X string `json:"x"`
+		} `json:"a"`
+		B struct {
+			Y int64 `json:"y,omitempty"`
+		} `json:"b"`
+	}
+	schemas := make(map[string]any)
+	schemaRef(reflect.TypeFor[named](), schemas)
+
+	if _, ok := schemas[""]; ok {
+		t.Error("anonymous struct registered as a component")
+	}
+	props := schemas["named"].(map[string]any)["properties"].(map[string]any)
+	a := props["a"].(map[string]any)["properties"].(map[string]any)
+	b := props["b"].(map[string]any)["properties"].(map[string]any)
+	if a["x"] == nil || b["y"] == nil || a["y"] != nil || b["x"] != nil {
+		t.Errorf("anonymous structs not inlined separately: a=%v b=%v", a, b)
+	}
+}
@@ -0,0 +1,322 @@
+package server
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/inodb/vibe-vep/internal/annotate"
+	"github.com/inodb/vibe-vep/internal/vcf"
+)
+
+var (
+	// durationBuckets are the upper bounds (seconds) of request latency buckets.
+	durationBuckets = []float64{0.001, 0.005, 0.01, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60}
+	// batchBuckets are the upper bounds of batch size buckets.
+	batchBuckets = []float64{1, 10, 50, 100, 500, 1000, 5000, 10000, 50000}
+)
+
+// histogram is a cumulative histogram in the Prometheus sense.
+type histogram struct {
+	bounds []float64
+	counts []uint64 // per bucket (not cumulative); the last is +Inf
+	sum    float64
+	count  uint64
+}
+
+func newHistogram(bounds []float64) *histogram {
+	return &histogram{bounds: bounds, counts: make([]uint64, len(bounds)+1)}
+}
+
+func (h *histogram) observe(v float64) {
+	i, _ := slices.BinarySearch(h.bounds, v)
+	h.counts[i]++
+	h.sum += v
+	h.count++
+}
+
+// routeCode labels a request counter.
+type routeCode struct {
+	route string
+	code  int
+}
+
+// metrics collects the server's Prometheus metrics. Cache counters live in
+// resultCache and are read when scraped.
+type metrics struct {
+	mu               sync.Mutex
+	requests         map[routeCode]uint64
+	durations        map[string]*histogram // by route
+	batchSizes       map[string]*histogram // by route
+	annotationErrors map[string]uint64     // by assembly
+	sourceCalls      map[string]uint64     // by source
+	sourceSeconds    map[string]float64    // by source
+	myVariantLocal   uint64                // my_variant_info served from local extras
+	myVariantErrors  uint64                // failed myvariant.info fetches
+}
+
+func newMetrics() *metrics {
+	return &metrics{
+		requests:         make(map[routeCode]uint64),
+		durations:        make(map[string]*histogram),
+		batchSizes:       make(map[string]*histogram),
+		annotationErrors: make(map[string]uint64),
+		sourceCalls:      make(map[string]uint64),
+		sourceSeconds:    make(map[string]float64),
+	}
+}
+
+func (m *metrics) observeRequest(route string, code int, d time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.requests[routeCode{route, code}]++
+	h, ok := m.durations[route]
+	if !ok {
+		h = newHistogram(durationBuckets)
+		m.durations[route] = h
+	}
+	h.observe(d.Seconds())
+}
+
+func (m *metrics) observeBatch(route string, n int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	h, ok := m.batchSizes[route]
+	if !ok {
+		h = newHistogram(batchBuckets)
+		m.batchSizes[route] = h
+	}
+	h.observe(float64(n))
+}
+
+func (m *metrics) annotationError(assembly string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.annotationErrors[assembly]++
+}
+
+func (m *metrics) sourceCall(source string, d time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sourceCalls[source]++
+	m.sourceSeconds[source] += d.Seconds()
+}
+
+func (m *metrics) myVariantInfo(local, failed bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if local {
+		m.myVariantLocal++
+	}
+	if failed {
+		m.myVariantErrors++
+	}
+}
+
+// runSources runs the assembly's annotation sources on an annotated variant,
+// timing each.
+func (s *Server) runSources(ctx *assemblyContext, v *vcf.Variant, anns []*annotate.Annotation) {
+	for _, src := range ctx.sources {
+		start := time.Now()
+		src.Annotate(v, anns)
+		s.metrics.sourceCall(sourceLabel(src), time.Since(start))
+	}
+}
+
+// sourceLabel names a source for metrics: its Name, or its package for the
+// unnamed genomic index source.
+func sourceLabel(src annotate.AnnotationSource) string {
+	if name := src.Name(); name != "" {
+		return name
+	}
+	pkg, _, _ := strings.Cut(fmt.Sprintf("%T", src), ".")
+	return strings.TrimPrefix(pkg, "*")
+}
+
+// handleMetrics writes the metrics in the Prometheus text exposition format.
+func (s *Server) handleMetrics(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	m := s.metrics
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	header(w, "vibe_vep_http_requests_total", "counter", "HTTP requests by route and status code.")
+	keys := make([]routeCode, 0, len(m.requests))
+	for k := range m.requests {
+		keys = append(keys, k)
+	}
+	slices.SortFunc(keys, func(a, b routeCode) int {
+		if c := strings.Compare(a.route, b.route); c != 0 {
+			return c
+		}
+		return a.code - b.code
+	})
+	for _, k := range keys {
+		fmt.Fprintf(w, "vibe_vep_http_requests_total{route=%s,code=\"%d\"} %d\n", quoteLabel(k.route), k.code, m.requests[k])
+	}
+
+	header(w, "vibe_vep_http_request_duration_seconds", "histogram", "HTTP request latency by route.")
+	writeHistograms(w, "vibe_vep_http_request_duration_seconds", "route", m.durations)
+
+	header(w, "vibe_vep_batch_size", "histogram", "Number of items per batch POST by route.")
+	writeHistograms(w, "vibe_vep_batch_size", "route", m.batchSizes)
+
+	header(w, "vibe_vep_annotation_errors_total", "counter", "Variants that failed to annotate by assembly.")
+	for _, a := range sortedKeys(m.annotationErrors) {
+		fmt.Fprintf(w, "vibe_vep_annotation_errors_total{assembly=%s} %d\n", quoteLabel(a), m.annotationErrors[a])
+	}
+
+	header(w, "vibe_vep_source_annotations_total", "counter", "Variants annotated by each annotation source.")
+	for _, src := range sortedKeys(m.sourceCalls) {
+		fmt.Fprintf(w, "vibe_vep_source_annotations_total{source=%s} %d\n", quoteLabel(src), m.sourceCalls[src])
+	}
+	header(w, "vibe_vep_source_seconds_total", "counter", "Time spent in each annotation source.")
+	for _, src := range sortedKeys(m.sourceSeconds) {
+		fmt.Fprintf(w, "vibe_vep_source_seconds_total{source=%s} %s\n", quoteLabel(src), formatFloat(m.sourceSeconds[src]))
+	}
+
+	st := s.results.stats()
+	header(w, "vibe_vep_result_cache_requests_total", "counter", "Annotation result cache lookups by outcome.")
+	fmt.Fprintf(w, "vibe_vep_result_cache_requests_total{result=\"hit\"} %d\n", st.Hits)
+	fmt.Fprintf(w, "vibe_vep_result_cache_requests_total{result=\"miss\"} %d\n", st.Misses)
+	header(w, "vibe_vep_result_cache_persistent_hits_total", "counter", "Result cache hits read from the persistent store.")
+	fmt.Fprintf(w, "vibe_vep_result_cache_persistent_hits_total %d\n", st.PersistentHits)
+	header(w, "vibe_vep_result_cache_entries", "gauge", "Entries in the in-memory result cache.")
+	fmt.Fprintf(w, "vibe_vep_result_cache_entries %d\n", st.Entries)
+
+	header(w, "vibe_vep_my_variant_info_lookups_total", "counter", "my_variant_info enrichments by where the data came from.")
+	fmt.Fprintf(w, "vibe_vep_my_variant_info_lookups_total{source=\"local\"} %d\n", m.myVariantLocal)
+	fmt.Fprintf(w, "vibe_vep_my_variant_info_lookups_total{source=\"cache\"} %d\n", st.MyVariantInfoHits)
+	fmt.Fprintf(w, "vibe_vep_my_variant_info_lookups_total{source=\"remote\"} %d\n", st.MyVariantInfoMisses)
+	header(w, "vibe_vep_my_variant_info_errors_total", "counter", "Failed myvariant.info API requests.")
+	fmt.Fprintf(w, "vibe_vep_my_variant_info_errors_total %d\n", m.myVariantErrors)
+
+	header(w, "vibe_vep_goroutines", "gauge", "Number of goroutines.")
+	fmt.Fprintf(w, "vibe_vep_goroutines %d\n", runtime.NumGoroutine())
+}
+
+func header(w io.Writer, name, typ, help string) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, typ)
+}
+
+func writeHistograms(w io.Writer, name, label string, hs map[string]*histogram) {
+	for _, key := range sortedKeys(hs) {
+		h := hs[key]
+		lv := quoteLabel(key)
+		var cum uint64
+		for i, bound := range h.bounds {
+			cum += h.counts[i]
+			fmt.Fprintf(w, "%s_bucket{%s=%s,le=\"%s\"} %d\n", name, label, lv, formatFloat(bound), cum)
+		}
+		fmt.Fprintf(w, "%s_bucket{%s=%s,le=\"+Inf\"} %d\n", name, label, lv, h.count)
+		fmt.Fprintf(w, "%s_sum{%s=%s} %s\n", name, label, lv, formatFloat(h.sum))
+		fmt.Fprintf(w, "%s_count{%s=%s} %d\n", name, label, lv, h.count)
+	}
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+// quoteLabel quotes a label value, escaping backslashes, quotes and newlines.
+func quoteLabel(v string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(v) + `"`
+}
+
+// requestIDKey is the context key of the request ID.
+type requestIDKey struct{}
+
+// requestLogger returns the server logger tagged with the request ID of rctx.
+func (s *Server) requestLogger(rctx context.Context) *zap.Logger {
+	if id, ok := rctx.Value(requestIDKey{}).(string); ok {
+		return s.logger.With(zap.String("request_id", id))
+	}
+	return s.logger
+}
+
+// newRequestID returns a random 16-character hex request ID.
+func newRequestID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// statusWriter records the status code and size of a response.
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+	bytes  int
+}
+
+func (w *statusWriter) WriteHeader(code int) {
+	w.status = code
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *statusWriter) Write(b []byte) (int, error) {
+	n, err := w.ResponseWriter.Write(b)
+	w.bytes += n
+	return n, err
+}
+
+// instrument assigns each request an ID (the client's X-Request-ID, if
+// sent), records its metrics and writes an access log entry. Health checks
+// and scrapes are logged at debug level.
+func (s *Server) instrument(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		id := r.Header.Get("X-Request-ID")
+		if id == "" || len(id) > 128 {
+			id = newRequestID()
+		}
+		w.Header().Set("X-Request-ID", id)
+		r = r.WithContext(context.WithValue(r.Context(), requestIDKey{}, id))
+		r, cleanups := withCleanups(r)
+		defer cleanups.run()
+
+		sw := &statusWriter{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(sw, r)
+		elapsed := time.Since(start)
+
+		// r.Pattern is set by the mux; unmatched requests share one label.
+		route := r.Pattern
+		if route == "" {
+			route = "unmatched"
+		}
+		s.metrics.observeRequest(route, sw.status, elapsed)
+
+		log := s.logger.Info
+		if route == "GET /health" || route == "GET /metrics" {
+			log = s.logger.Debug
+		}
+		log("request",
+			zap.String("request_id", id),
+			zap.String("method", r.Method),
+			zap.String("path", r.URL.Path),
+			zap.String("route", route),
+			zap.Int("status", sw.status),
+			zap.Int("bytes", sw.bytes),
+			zap.Duration("duration", elapsed),
+			zap.String("remote", r.RemoteAddr))
+	})
+}
@@ -0,0 +1,155 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+
+	"github.com/inodb/vibe-vep/internal/annotate"
+	"github.com/inodb/vibe-vep/internal/cache"
+)
+
+func TestMetrics(t *testing.T) {
+	srv := newTestServerWithKRAS(t)
+	handler := srv.Handler()
+
+	for range 2 {
+		postGNGenomic(t, srv, krasBatch)
+	}
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/no/such/route", nil))
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", w.Code)
+	}
+	body := w.Body.String()
+
+	route := `route="POST /genome-nexus/{assembly}/annotation/genomic"`
+	for _, want := range []string{
+		"# TYPE vibe_vep_http_request_duration_seconds histogram",
+		`vibe_vep_http_requests_total{` + route + `,code="200"} 2`,
+		`vibe_vep_http_requests_total{route="unmatched",code="404"} 1`,
+		`vibe_vep_http_request_duration_seconds_bucket{` + route + `,le="+Inf"} 2`,
+		`vibe_vep_http_request_duration_seconds_count{` + route + `} 2`,
+		`vibe_vep_batch_size_bucket{` + route + `,le="1"} 0`,
+		`vibe_vep_batch_size_bucket{` + route + `,le="10"} 2`,
+		`vibe_vep_batch_size_sum{` + route + `} 4`,
+		`vibe_vep_result_cache_requests_total{result="miss"} 0`,
+		`vibe_vep_my_variant_info_lookups_total{source="remote"} 0`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("metrics missing %q\n%s", want, body)
+		}
+	}
+}
+
+func TestMetrics_QuoteLabel(t *testing.T) {
+	if got := quoteLabel("a\"b\\c\nd"); got != `"a\"b\\c\nd"` {
+		t.Errorf("quoteLabel: got %s", got)
+	}
+}
+
+// newObservedServer creates a server with an empty cache whose logs are
+// recorded.
+func newObservedServer(t *testing.T) (*Server, *observer.ObservedLogs) {
+	t.Helper()
+	core, logs := observer.New(zapcore.DebugLevel)
+	srv := New(zap.New(core), "test")
+	c := cache.New()
+	srv.AddAssembly("GRCh38", c, annotate.NewAnnotator(c), nil)
+	return srv, logs
+}
+
+func TestAccessLog_RequestID(t *testing.T) {
+	srv, logs := newObservedServer(t)
+	handler := srv.Handler()
+
+	req := httptest.NewRequest(http.MethodGet, "/genome-nexus/grch38/annotation/genomic/7,100,100,A,T", nil)
+	req.Header.Set("X-Request-ID", "client-id-1")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if got := w.Header().Get("X-Request-ID"); got != "client-id-1" {
+		t.Errorf("X-Request-ID: got %q, want client-id-1", got)
+	}
+
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/health", nil))
+	generated := w.Header().Get("X-Request-ID")
+	if !regexp.MustCompile(`^[0-9a-f]{16}$`).MatchString(generated) {
+		t.Errorf("generated X-Request-ID: got %q", generated)
+	}
+
+	entries := logs.FilterMessage("request").All()
+	if len(entries) != 2 {
+		t.Fatalf("expected 2 access log entries, got %d", len(entries))
+	}
+	first := entries[0].ContextMap()
+	if first["request_id"] != "client-id-1" || first["route"] != "GET /genome-nexus/{assembly}/annotation/genomic/{genomicLocation}" || first["status"] != int64(200) {
+		t.Errorf("access log: %v", first)
+	}
+	if entries[0].Level != zapcore.InfoLevel || entries[1].Level != zapcore.DebugLevel {
+		t.Errorf("levels: got %v, %v; want info, debug (health check)", entries[0].Level, entries[1].Level)
+	}
+	if entries[1].ContextMap()["request_id"] != generated {
+		t.Errorf("health check request_id: got %v, want %s", entries[1].ContextMap()["request_id"], generated)
+	}
+}
+
+func TestRequestLogger(t *testing.T) {
+	srv, logs := newObservedServer(t)
+
+	rctx := context.WithValue(context.Background(), requestIDKey{}, "abc")
+	srv.requestLogger(rctx).Warn("myvariant.info fetch failed")
+	srv.requestLogger(context.Background()).Warn("no request")
+
+	entries := logs.All()
+	if got := entries[0].ContextMap()["request_id"]; got != "abc" {
+		t.Errorf("request_id: got %v, want abc", got)
+	}
+	if _, ok := entries[1].ContextMap()["request_id"]; ok {
+		t.Error("unexpected request_id outside a request")
+	}
+}
+
+// missingReference is a reference genome without any contig.
+type missingReference struct{}
+
+func (missingReference) Fetch(chrom string, start, end int64) (string, error) {
+	return "", errors.New("contig not found")
+}
+
+func TestAnnotatorLog_RequestID(t *testing.T) {
+	srv, logs := newObservedServer(t)
+	ctx := srv.assemblies["grch38"]
+	ctx.annotator.SetReference(missingReference{})
+	ctx.annotator.SetRefCheck(true)
+	handler := srv.Handler()
+
+	req := httptest.NewRequest(http.MethodGet, "/genome-nexus/grch38/annotation/genomic/7,100,100,A,T", nil)
+	req.Header.Set("X-Request-ID", "single-1")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	req = httptest.NewRequest(http.MethodPost, "/genome-nexus/grch38/annotation/genomic",
+		strings.NewReader(`[{"chromosome":"7","start":100,"end":100,"referenceAllele":"A","variantAllele":"T"}]`))
+	req.Header.Set("X-Request-ID", "batch-1")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	entries := logs.FilterMessage("could not check REF allele").All()
+	if len(entries) != 2 {
+		t.Fatalf("expected 2 REF check messages, got %d", len(entries))
+	}
+	for i, want := range []string{"single-1", "batch-1"} {
+		if got := entries[i].ContextMap()["request_id"]; got != want {
+			t.Errorf("message %d request_id: got %v, want %s", i, got, want)
+		}
+	}
+}
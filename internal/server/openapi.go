@@ -0,0 +1,187 @@
+package server
+
+import (
+	"reflect"
+	"strings"
+
+	"github.com/inodb/vibe-vep/internal/output"
+)
+
+// openAPIDocument returns the OpenAPI 3 description of the /v1 API. The
+// response schemas are generated from the output types the vibe-vep-jsonl
+// format is written with, so the document follows them.
+func openAPIDocument(version string) map[string]any {
+	schemas := map[string]any{
+		"Error": map[string]any{
+			"type":       "object",
+			"properties": map[string]any{"error": map[string]any{"type": "string"}},
+			"required":   []string{"error"},
+		},
+	}
+	annotation := schemaRef(reflect.TypeFor[output.VibeVepVariantAnnotation](), schemas)
+	itemError := schemaRef(reflect.TypeFor[output.VibeVepError](), schemas)
+	errorResponse := func(desc string) map[string]any {
+		return map[string]any{
+			"description": desc,
+			"content":     jsonContent(map[string]any{"$ref": "#/components/schemas/Error"}),
+		}
+	}
+	// Annotations are a JSON array, or one item per line if the client
+	// accepts application/x-ndjson.
+	annotations := func(item map[string]any) map[string]any {
+		return map[string]any{
+			"application/json": map[string]any{"schema": map[string]any{"type": "array", "items": item}},
+			ndjsonContentType:  map[string]any{"schema": item},
+		}
+	}
+
+	assemblyParam := map[string]any{
+		"name":        "assembly",
+		"in":          "query",
+		"description": "Assembly to annotate against, e.g. GRCh38 (default: the only loaded assembly, else GRCh38).",
+		"schema":      map[string]any{"type": "string"},
+	}
+	variantParam := map[string]any{
+		"name":     "variant",
+		"in":       "query",
+		"required": true,
+		"description": "Variant in genomic (12:25245350:C:A, 12-25245350-C-A), HGVSg (12:g.25245350C>A), " +
+			"HGVSc (ENST00000311936:c.35G>T, KRAS c.35G>T), protein (KRAS G12C, KRAS p.Gly12Cys) or dbSNP (rs121913529) notation.",
+		"schema": map[string]any{"type": "string"},
+	}
+
+	return map[string]any{
+		"openapi": "3.0.3",
+		"info": map[string]any{
+			"title":       "vibe-vep",
+			"version":     version,
+			"description": "Native vibe-vep variant annotation API. Annotations use the vibe-vep-jsonl schema of the CLI.",
+		},
+		"paths": map[string]any{
+			"/v1/annotate": map[string]any{
+				"get": map[string]any{
+					"operationId": "annotateVariant",
+					"summary":     "Annotate one variant",
+					"description": "Returns one annotation per genomic variant the notation resolves to (e.g. per ALT allele of an rsID). " +
+						"A variant that cannot be annotated gets an error object in its place; the request fails only if all do.",
+					"parameters": []any{variantParam, assemblyParam},
+					"responses": map[string]any{
+						"200": map[string]any{
+							"description": "Annotations and per-variant errors",
+							"content":     annotations(map[string]any{"oneOf": []any{annotation, itemError}}),
+						},
+						"400": map[string]any{"description": "The variant could not be parsed or resolved", "content": jsonContent(itemError)},
+						"404": errorResponse("Assembly not loaded"),
+						"500": map[string]any{"description": "No variant could be annotated", "content": jsonContent(itemError)},
+						"504": map[string]any{"description": "The request deadline passed before any variant was annotated", "content": jsonContent(itemError)},
+					},
+				},
+				"post": map[string]any{
+					"operationId": "annotateVariants",
+					"summary":     "Annotate a batch of variants",
+					"description": "Annotates a JSON array of variant notations (as for GET) or VCF text, in input order. " +
+						"Multi-allelic VCF records give one annotation per ALT allele. An input that cannot be parsed, " +
+						"resolved or annotated before the request deadline gets an error object in its place.",
+					"parameters": []any{assemblyParam},
+					"requestBody": map[string]any{
+						"required": true,
+						"content": map[string]any{
+							"application/json": map[string]any{
+								"schema": map[string]any{"type": "array", "items": map[string]any{"type": "string"}},
+							},
+							"text/plain": map[string]any{
+								"schema": map[string]any{"type": "string", "description": "VCF; the header is optional."},
+							},
+						},
+					},
+					"responses": map[string]any{
+						"200": map[string]any{
+							"description": "Annotations and per-input errors",
+							"content":     annotations(map[string]any{"oneOf": []any{annotation, itemError}}),
+						},
+						"400": errorResponse("Invalid body"),
+						"404": errorResponse("Assembly not loaded"),
+						"413": errorResponse("Batch larger than the server's maximum"),
+					},
+				},
+			},
+			"/v1/openapi.json": map[string]any{
+				"get": map[string]any{
+					"operationId": "openAPI",
+					"summary":     "This document",
+					"responses": map[string]any{
+						"200": map[string]any{"description": "OpenAPI 3 document", "content": jsonContent(map[string]any{"type": "object"})},
+					},
+				},
+			},
+		},
+		"components": map[string]any{"schemas": schemas},
+	}
+}
+
+func jsonContent(schema map[string]any) map[string]any {
+	return map[string]any{"application/json": map[string]any{"schema": schema}}
+}
+
+// schemaRef returns the JSON schema of t, adding named struct types to
+// schemas and referring to them by name.
+func schemaRef(t reflect.Type, schemas map[string]any) map[string]any {
+	switch t.Kind() {
+	case reflect.Pointer:
+		return schemaRef(t.Elem(), schemas)
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int32:
+		return map[string]any{"type": "integer", "format": "int32"}
+	case reflect.Int64:
+		return map[string]any{"type": "integer", "format": "int64"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number", "format": "double"}
+	case reflect.Slice, reflect.Array:
+		return map[string]any{"type": "array", "items": schemaRef(t.Elem(), schemas)}
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": schemaRef(t.Elem(), schemas)}
+	case reflect.Struct:
+		// Anonymous structs have no name to key a component by; inline them.
+		if t.Name() == "" {
+			return structSchema(t, schemas)
+		}
+		ref := map[string]any{"$ref": "#/components/schemas/" + t.Name()}
+		if _, ok := schemas[t.Name()]; ok {
+			return ref
+		}
+		schemas[t.Name()] = nil // placeholder for recursive types
+		schemas[t.Name()] = structSchema(t, schemas)
+		return ref
+	default:
+		return map[string]any{}
+	}
+}
+
+// structSchema returns the object schema of struct type t, with its fields
+// named by their json tags.
+func structSchema(t reflect.Type, schemas map[string]any) map[string]any {
+	props := make(map[string]any)
+	var required []string
+	for i := range t.NumField() {
+		f := t.Field(i)
+		name, opts, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if !f.IsExported() || name == "-" {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		props[name] = schemaRef(f.Type, schemas)
+		if !strings.Contains(opts, "omitempty") {
+			required = append(required, name)
+		}
+	}
+	schema := map[string]any{"type": "object", "properties": props}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+	return schema
+}
@@ -0,0 +1,276 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/inodb/vibe-vep/internal/annotate"
+	"github.com/inodb/vibe-vep/internal/cache"
+	"github.com/inodb/vibe-vep/internal/datasource/pfam"
+	"github.com/inodb/vibe-vep/internal/datasource/ptm"
+	"github.com/inodb/vibe-vep/internal/datasource/uniprot"
+	"github.com/inodb/vibe-vep/internal/duckdb"
+)
+
+// AssemblyData is the data of one assembly, as registered with
+// AddAssemblyData or returned by a Loader.
+type AssemblyData struct {
+	Assembly    string // normalized: "GRCh38"
+	Cache       *cache.Cache
+	Annotator   *annotate.Annotator
+	Sources     []annotate.AnnotationSource
+	Pfam        *pfam.Store    // nil if not available
+	Ptm         *ptm.Store     // nil if not available
+	Uniprot     *uniprot.Store // nil if not available
+	ResultStore *duckdb.Store  // persistent result cache, nil if none (see SetResultStore)
+	Close       func()         // releases the data's open files, nil if none
+}
+
+// Loader loads the data of an assembly from disk. It is called to reload
+// each loaded assembly (see Reload).
+type Loader func(assembly string) (*AssemblyData, error)
+
+// reloadStatus reports the last reload, for /info.
+type reloadStatus struct {
+	Running  bool      `json:"running"`
+	Started  time.Time `json:"started,omitzero"`
+	Finished time.Time `json:"finished,omitzero"`
+	Error    string    `json:"error,omitempty"`
+}
+
+// AddAssemblyData registers an assembly. If the assembly is already loaded,
+// the new data replaces it; the old data is closed once the requests using
+// it have finished.
+func (s *Server) AddAssemblyData(d *AssemblyData) {
+	ctx := s.newAssemblyContext(d)
+	s.mu.Lock()
+	key := strings.ToLower(d.Assembly)
+	old := s.assemblies[key]
+	s.assemblies[key] = ctx
+	s.mu.Unlock()
+	if old != nil {
+		go s.retire(old)
+	}
+}
+
+// newAssemblyContext builds the context serving d.
+func (s *Server) newAssemblyContext(d *AssemblyData) *assemblyContext {
+	ctx := &assemblyContext{
+		annotator:   d.Annotator,
+		sources:     d.Sources,
+		cache:       d.Cache,
+		pfam:        d.Pfam,
+		ptm:         d.Ptm,
+		uniprot:     d.Uniprot,
+		assembly:    d.Assembly,
+		resultStore: d.ResultStore,
+		closer:      d.Close,
+		loadedAt:    time.Now(),
+	}
+	ctx.dataVersion = dataVersion(s.version, ctx)
+	if ctx.resultStore != nil {
+		s.pruneResultStore(ctx)
+	}
+	return ctx
+}
+
+// retire waits for the requests using a replaced assembly context to
+// finish, then closes its data.
+func (s *Server) retire(ctx *assemblyContext) {
+	ctx.inflight.Wait()
+	if ctx.closer != nil {
+		ctx.closer()
+	}
+	s.logger.Info("closed replaced assembly data",
+		zap.String("assembly", ctx.assembly), zap.String("data_version", ctx.dataVersion))
+}
+
+// SetLoader sets the function Reload uses to load assemblies.
+func (s *Server) SetLoader(l Loader) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.loader = l
+}
+
+// SetAdminToken requires the bearer token on /admin endpoints. With an
+// empty token they are open.
+func (s *Server) SetAdminToken(token string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.adminToken = token
+}
+
+// Reload loads every loaded assembly afresh with the loader and swaps the
+// new data in. Requests in flight finish on the old data, which is then
+// closed. If any assembly fails to load, none is replaced.
+func (s *Server) Reload() error {
+	s.mu.RLock()
+	loader := s.loader
+	names := make([]string, 0, len(s.assemblies))
+	for _, ctx := range s.assemblies {
+		names = append(names, ctx.assembly)
+	}
+	s.mu.RUnlock()
+	if loader == nil {
+		return fmt.Errorf("no loader set")
+	}
+
+	start := time.Now()
+	fresh := make([]*assemblyContext, 0, len(names))
+	for _, name := range names {
+		d, err := loader(name)
+		if err != nil {
+			for _, ctx := range fresh {
+				if ctx.closer != nil {
+					ctx.closer()
+				}
+			}
+			return fmt.Errorf("loading %s: %w", name, err)
+		}
+		fresh = append(fresh, s.newAssemblyContext(d))
+	}
+
+	s.mu.Lock()
+	var old []*assemblyContext
+	for _, ctx := range fresh {
+		key := strings.ToLower(ctx.assembly)
+		if prev := s.assemblies[key]; prev != nil {
+			old = append(old, prev)
+		}
+		s.assemblies[key] = ctx
+	}
+	s.mu.Unlock()
+
+	// Results of the old contexts are unreachable under the new data
+	// versions; drop them rather than let them age out of the LRU.
+	if s.results.lru != nil {
+		s.results.lru.Purge()
+	}
+
+	for _, ctx := range fresh {
+		s.logger.Info("assembly reloaded",
+			zap.String("assembly", ctx.assembly),
+			zap.String("data_version", ctx.dataVersion),
+			zap.Int("transcripts", ctx.cache.TranscriptCount()),
+			zap.Int("sources", len(ctx.sources)),
+			zap.Duration("elapsed", time.Since(start)))
+	}
+	for _, ctx := range old {
+		go s.retire(ctx)
+	}
+	return nil
+}
+
+// StartReload runs Reload in the background. It returns false if a reload
+// is already running.
+func (s *Server) StartReload() bool {
+	s.reloadMu.Lock()
+	if s.reload.Running {
+		s.reloadMu.Unlock()
+		return false
+	}
+	s.reload = reloadStatus{Running: true, Started: time.Now()}
+	s.reloadMu.Unlock()
+
+	go func() {
+		err := s.Reload()
+		if err != nil {
+			s.logger.Error("reload failed", zap.Error(err))
+		}
+		s.reloadMu.Lock()
+		defer s.reloadMu.Unlock()
+		s.reload.Running = false
+		s.reload.Finished = time.Now()
+		if err != nil {
+			s.reload.Error = err.Error()
+		}
+	}()
+	return true
+}
+
+// reloadState returns the status of the last reload.
+func (s *Server) reloadState() reloadStatus {
+	s.reloadMu.Lock()
+	defer s.reloadMu.Unlock()
+	return s.reload
+}
+
+// Close closes the data of every assembly once its requests have finished.
+func (s *Server) Close() {
+	s.mu.Lock()
+	ctxs := make([]*assemblyContext, 0, len(s.assemblies))
+	for key, ctx := range s.assemblies {
+		ctxs = append(ctxs, ctx)
+		delete(s.assemblies, key)
+	}
+	s.mu.Unlock()
+	for _, ctx := range ctxs {
+		s.retire(ctx)
+	}
+}
+
+// handleAdminReload handles POST /admin/reload: it starts reloading the
+// transcript cache and annotation sources of every assembly and returns
+// 202. Progress is reported by /info.
+func (s *Server) handleAdminReload(w http.ResponseWriter, r *http.Request) {
+	s.mu.RLock()
+	token, loader := s.adminToken, s.loader
+	s.mu.RUnlock()
+	if token != "" && r.Header.Get("Authorization") != "Bearer "+token {
+		writeError(w, http.StatusUnauthorized, "missing or invalid admin token")
+		return
+	}
+	if loader == nil {
+		writeError(w, http.StatusNotImplemented, "reload is not configured")
+		return
+	}
+	if !s.StartReload() {
+		writeError(w, http.StatusConflict, "a reload is already running")
+		return
+	}
+	s.requestLogger(r.Context()).Info("reload started")
+	writeJSON(w, http.StatusAccepted, map[string]string{"status": "reloading"})
+}
+
+// cleanupsKey is the context key of a request's requestCleanups.
+type cleanupsKey struct{}
+
+// requestCleanups are run when a request finishes (see instrument).
+type requestCleanups struct {
+	fns []func()
+}
+
+func (c *requestCleanups) run() {
+	for _, fn := range c.fns {
+		fn()
+	}
+}
+
+// acquireAssembly returns the assembly context for the given name
+// (case-insensitive) and holds it until the request finishes, so a reload
+// does not close its data while the request uses it.
+func (s *Server) acquireAssembly(r *http.Request, name string) *assemblyContext {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	ctx := s.assemblies[strings.ToLower(name)]
+	if ctx == nil {
+		return nil
+	}
+	if cl, ok := r.Context().Value(cleanupsKey{}).(*requestCleanups); ok {
+		ctx.inflight.Add(1)
+		cl.fns = append(cl.fns, ctx.inflight.Done)
+	}
+	return ctx
+}
+
+// withCleanups returns r with an empty list of cleanups to run when it
+// finishes.
+func withCleanups(r *http.Request) (*http.Request, *requestCleanups) {
+	cl := &requestCleanups{}
+	return r.WithContext(context.WithValue(r.Context(), cleanupsKey{}, cl)), cl
+}
@@ -0,0 +1,191 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/inodb/vibe-vep/internal/annotate"
+	"github.com/inodb/vibe-vep/internal/cache"
+	"github.com/inodb/vibe-vep/internal/vcf"
+)
+
+// versionSource is an annotation source that only has a version.
+type versionSource string
+
+func (versionSource) Name() string                                  { return "test-source" }
+func (s versionSource) Version() string                             { return string(s) }
+func (versionSource) MatchLevel() annotate.MatchLevel               { return annotate.MatchGenomic }
+func (versionSource) Columns() []annotate.ColumnDef                 { return nil }
+func (versionSource) Annotate(*vcf.Variant, []*annotate.Annotation) {}
+
+// testAssemblyData returns empty GRCh38 data whose source has the given
+// version; closed is signalled when it is closed.
+func testAssemblyData(version string, closed chan<- string) *AssemblyData {
+	c := cache.New()
+	return &AssemblyData{
+		Assembly:  "GRCh38",
+		Cache:     c,
+		Annotator: annotate.NewAnnotator(c),
+		Sources:   []annotate.AnnotationSource{versionSource(version)},
+		Close:     func() { closed <- version },
+	}
+}
+
+// reloadInfo returns the reload status and the first assembly of /info.
+func reloadInfo(t *testing.T, srv *Server) (reloadStatus, string, string) {
+	t.Helper()
+	w := httptest.NewRecorder()
+	srv.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/info", nil))
+	var info struct {
+		Reload     reloadStatus `json:"reload"`
+		Assemblies []struct {
+			DataVersion string `json:"data_version"`
+			Sources     []struct {
+				Name    string `json:"name"`
+				Version string `json:"version"`
+			} `json:"sources"`
+		} `json:"assemblies"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &info); err != nil {
+		t.Fatalf("decode /info: %v", err)
+	}
+	a := info.Assemblies[0]
+	return info.Reload, a.DataVersion, a.Sources[0].Version
+}
+
+func TestReload(t *testing.T) {
+	closed := make(chan string, 2)
+	srv := New(zap.NewNop(), "test")
+	srv.SetResultCacheSize(10)
+	srv.AddAssemblyData(testAssemblyData("v1", closed))
+	srv.SetLoader(func(assembly string) (*AssemblyData, error) {
+		if assembly != "GRCh38" {
+			t.Errorf("loader called for %q", assembly)
+		}
+		return testAssemblyData("v2", closed), nil
+	})
+	_, before, _ := reloadInfo(t, srv)
+
+	// A request holding the old data delays closing it.
+	r, cleanups := withCleanups(httptest.NewRequest(http.MethodGet, "/", nil))
+	if srv.acquireAssembly(r, "grch38") == nil {
+		t.Fatal("assembly not found")
+	}
+
+	srv.results.lru.Add(before+"|GRCh38|12|25245351|C|A", &cachedResult{})
+
+	if err := srv.Reload(); err != nil {
+		t.Fatalf("Reload: %v", err)
+	}
+	if n := srv.results.lru.Len(); n != 0 {
+		t.Errorf("result cache holds %d entries after reload, want 0", n)
+	}
+	_, after, sourceVersion := reloadInfo(t, srv)
+	if after == before {
+		t.Errorf("data version unchanged: %s", after)
+	}
+	if sourceVersion != "v2" {
+		t.Errorf("source version: got %q, want v2", sourceVersion)
+	}
+
+	select {
+	case v := <-closed:
+		t.Fatalf("%s closed while a request used it", v)
+	case <-time.After(50 * time.Millisecond):
+	}
+	cleanups.run()
+	select {
+	case v := <-closed:
+		if v != "v1" {
+			t.Errorf("closed %s, want v1", v)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("old data not closed after the request finished")
+	}
+
+	srv.Close()
+	if v := <-closed; v != "v2" {
+		t.Errorf("Close closed %s, want v2", v)
+	}
+}
+
+func TestReload_LoadError(t *testing.T) {
+	closed := make(chan string, 1)
+	srv := New(zap.NewNop(), "test")
+	srv.AddAssemblyData(testAssemblyData("v1", closed))
+	srv.SetLoader(func(string) (*AssemblyData, error) {
+		return nil, errors.New("corrupt cache")
+	})
+	_, before, _ := reloadInfo(t, srv)
+
+	if err := srv.Reload(); err == nil {
+		t.Fatal("expected an error")
+	}
+	if _, after, _ := reloadInfo(t, srv); after != before {
+		t.Errorf("data version changed after a failed reload: %s -> %s", before, after)
+	}
+	if len(closed) != 0 {
+		t.Error("live data closed after a failed reload")
+	}
+}
+
+func TestAdminReload(t *testing.T) {
+	closed := make(chan string, 2)
+	srv := New(zap.NewNop(), "test")
+	srv.AddAssemblyData(testAssemblyData("v1", closed))
+	handler := srv.Handler()
+	post := func(token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/admin/reload", nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w
+	}
+
+	if w := post(""); w.Code != http.StatusNotImplemented {
+		t.Errorf("without loader: got %d, want 501", w.Code)
+	}
+
+	release := make(chan struct{})
+	srv.SetLoader(func(string) (*AssemblyData, error) {
+		<-release
+		return testAssemblyData("v2", closed), nil
+	})
+	srv.SetAdminToken("secret")
+	if w := post("wrong"); w.Code != http.StatusUnauthorized {
+		t.Errorf("bad token: got %d, want 401", w.Code)
+	}
+	if w := post("secret"); w.Code != http.StatusAccepted {
+		t.Fatalf("got %d, want 202: %s", w.Code, w.Body.String())
+	}
+	if w := post("secret"); w.Code != http.StatusConflict {
+		t.Errorf("while reloading: got %d, want 409", w.Code)
+	}
+	if st, _, _ := reloadInfo(t, srv); !st.Running {
+		t.Error("reload not reported as running")
+	}
+
+	close(release)
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		st, _, version := reloadInfo(t, srv)
+		if !st.Running {
+			if st.Error != "" || version != "v2" {
+				t.Errorf("after reload: error %q, source version %q", st.Error, version)
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("reload did not finish")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -0,0 +1,290 @@
+package server
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"go.uber.org/zap"
+
+	"github.com/inodb/vibe-vep/internal/annotate"
+	"github.com/inodb/vibe-vep/internal/datasource/myvariantinfo"
+	"github.com/inodb/vibe-vep/internal/vcf"
+)
+
+// lruCache is a fixed-size least-recently-used map, safe for concurrent use.
+type lruCache struct {
+	mu    sync.Mutex
+	size  int
+	ll    *list.List
+	items map[string]*list.Element
+}
+
+type lruEntry struct {
+	key   string
+	value any
+}
+
+func newLRU(size int) *lruCache {
+	return &lruCache{size: size, ll: list.New(), items: make(map[string]*list.Element)}
+}
+
+// Get returns the value for key and marks it most recently used.
+func (c *lruCache) Get(key string) (any, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.items[key]
+	if !ok {
+		return nil, false
+	}
+	c.ll.MoveToFront(e)
+	return e.Value.(*lruEntry).value, true
+}
+
+// Add sets the value for key, evicting the least recently used entry if the
+// cache is full.
+func (c *lruCache) Add(key string, value any) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e, ok := c.items[key]; ok {
+		e.Value.(*lruEntry).value = value
+		c.ll.MoveToFront(e)
+		return
+	}
+	c.items[key] = c.ll.PushFront(&lruEntry{key: key, value: value})
+	if c.ll.Len() > c.size {
+		oldest := c.ll.Back()
+		c.ll.Remove(oldest)
+		delete(c.items, oldest.Value.(*lruEntry).key)
+	}
+}
+
+// Purge removes all entries.
+func (c *lruCache) Purge() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.ll.Init()
+	clear(c.items)
+}
+
+// Len returns the number of cached entries.
+func (c *lruCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.ll.Len()
+}
+
+// resultCache caches annotation results in memory, in front of each
+// assembly's optional persistent result store, and myvariant.info responses.
+type resultCache struct {
+	lru *lruCache // nil if in-memory caching is disabled
+
+	hits       atomic.Int64 // results served from memory or a store
+	storeHits  atomic.Int64 // of hits, those read from a persistent store
+	misses     atomic.Int64
+	remoteHits atomic.Int64 // myvariant.info responses served from memory
+	remoteMiss atomic.Int64
+}
+
+// ResultCacheStats reports result cache activity since the server started.
+type ResultCacheStats struct {
+	Capacity            int   `json:"capacity"`
+	Entries             int   `json:"entries"`
+	Hits                int64 `json:"hits"`
+	PersistentHits      int64 `json:"persistent_hits"`
+	Misses              int64 `json:"misses"`
+	MyVariantInfoHits   int64 `json:"my_variant_info_hits"`
+	MyVariantInfoMisses int64 `json:"my_variant_info_misses"`
+}
+
+func (rc *resultCache) stats() ResultCacheStats {
+	st := ResultCacheStats{
+		Hits:                rc.hits.Load(),
+		PersistentHits:      rc.storeHits.Load(),
+		Misses:              rc.misses.Load(),
+		MyVariantInfoHits:   rc.remoteHits.Load(),
+		MyVariantInfoMisses: rc.remoteMiss.Load(),
+	}
+	if rc.lru != nil {
+		st.Capacity = rc.lru.size
+		st.Entries = rc.lru.Len()
+	}
+	return st
+}
+
+// cachedResult is a cached annotation result: the variant as the annotator
+// left it (lifted over or normalized) and its annotations after the sources
+// ran. It is shared between requests and never modified.
+type cachedResult struct {
+	Chrom    string
+	Pos      int64
+	Ref      string
+	Alt      string
+	Original *vcf.OriginalAllele
+	Anns     []annotate.Annotation
+}
+
+// newCachedResult copies an annotated variant into a cachedResult.
+func newCachedResult(v *vcf.Variant, anns []*annotate.Annotation) *cachedResult {
+	cr := &cachedResult{Chrom: v.Chrom, Pos: v.Pos, Ref: v.Ref, Alt: v.Alt, Anns: make([]annotate.Annotation, len(anns))}
+	if v.Original != nil {
+		o := *v.Original
+		cr.Original = &o
+	}
+	for i, a := range anns {
+		cr.Anns[i] = cloneAnnotation(a)
+	}
+	return cr
+}
+
+// apply rewrites v as the annotator did and returns a private copy of the
+// annotations. v keeps its chromosome spelling ("chr12" or "12") unless
+// liftover moved it to another chromosome.
+func (cr *cachedResult) apply(v *vcf.Variant) []*annotate.Annotation {
+	if strings.TrimPrefix(cr.Chrom, "chr") != v.NormalizeChrom() {
+		v.Chrom = cr.Chrom
+	}
+	v.Pos, v.Ref, v.Alt = cr.Pos, cr.Ref, cr.Alt
+	v.Original = nil
+	if cr.Original != nil {
+		o := *cr.Original
+		v.Original = &o
+	}
+	anns := make([]*annotate.Annotation, len(cr.Anns))
+	for i := range cr.Anns {
+		a := cloneAnnotation(&cr.Anns[i])
+		anns[i] = &a
+	}
+	return anns
+}
+
+// cloneAnnotation returns a copy of a that shares no maps or slices with it.
+func cloneAnnotation(a *annotate.Annotation) annotate.Annotation {
+	c := *a
+	c.TranscriptSets = slices.Clone(a.TranscriptSets)
+	c.MergedWith = slices.Clone(a.MergedWith)
+	c.Extra = maps.Clone(a.Extra)
+	return c
+}
+
+// dataVersion identifies the data an assembly's annotations derive from:
+// the server version, the transcripts, the annotation sources and their
+// versions and data fingerprints, and the reference genome settings. Cached
+// results are keyed by it, so changing any of them invalidates the cache.
+func dataVersion(serverVersion string, ctx *assemblyContext) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\n%s\n%s\n", serverVersion, ctx.assembly, ctx.cache.Fingerprint())
+	for _, src := range ctx.sources {
+		fmt.Fprintf(h, "%s=%s", src.Name(), src.Version())
+		if fp, ok := src.(annotate.Fingerprinter); ok {
+			fmt.Fprintf(h, " %s", fp.Fingerprint())
+		}
+		fmt.Fprintln(h)
+	}
+	fmt.Fprintf(h, "reference=%t normalize=%t refcheck=%t\n", ctx.annotator.Reference() != nil, ctx.annotator.Normalize(), ctx.annotator.RefCheck())
+	return hex.EncodeToString(h.Sum(nil))[:16]
+}
+
+// resultKey returns the cache key of a variant before annotation.
+func resultKey(ctx *assemblyContext, v *vcf.Variant) string {
+	return fmt.Sprintf("%s|%s|%d|%s|%s", ctx.assembly, v.NormalizeChrom(), v.Pos,
+		strings.ToUpper(v.Ref), strings.ToUpper(v.Alt))
+}
+
+// lookupResults returns the cached results for the given keys, from memory
+// or the assembly's result store. Keys without a result are absent.
+func (s *Server) lookupResults(ctx *assemblyContext, keys []string) map[string]*cachedResult {
+	found := make(map[string]*cachedResult)
+	var missing []string
+	for _, key := range keys {
+		if s.results.lru != nil {
+			if cr, ok := s.results.lru.Get(ctx.dataVersion + "|" + key); ok {
+				found[key] = cr.(*cachedResult)
+				continue
+			}
+		}
+		missing = append(missing, key)
+	}
+	if ctx.resultStore != nil && len(missing) > 0 {
+		stored, err := ctx.resultStore.LookupResults(missing, ctx.dataVersion)
+		if err != nil {
+			s.logger.Warn("result store lookup failed", zap.Error(err))
+		}
+		for key, data := range stored {
+			var cr cachedResult
+			if err := json.Unmarshal(data, &cr); err != nil {
+				continue
+			}
+			found[key] = &cr
+			s.results.storeHits.Add(1)
+			if s.results.lru != nil {
+				s.results.lru.Add(ctx.dataVersion+"|"+key, &cr)
+			}
+		}
+	}
+	for _, key := range keys {
+		if _, ok := found[key]; ok {
+			s.results.hits.Add(1)
+		} else {
+			s.results.misses.Add(1)
+		}
+	}
+	return found
+}
+
+// saveResults caches newly computed results in memory and in the assembly's
+// result store.
+func (s *Server) saveResults(ctx *assemblyContext, results map[string]*cachedResult) {
+	if s.results.lru != nil {
+		for key, cr := range results {
+			s.results.lru.Add(ctx.dataVersion+"|"+key, cr)
+		}
+	}
+	if ctx.resultStore == nil || len(results) == 0 {
+		return
+	}
+	rows := make(map[string][]byte, len(results))
+	for key, cr := range results {
+		data, err := json.Marshal(cr)
+		if err != nil {
+			continue
+		}
+		rows[key] = data
+	}
+	if err := ctx.resultStore.WriteResults(ctx.dataVersion, rows); err != nil {
+		s.logger.Warn("result store write failed", zap.Error(err))
+	}
+}
+
+// cachingEnabled reports whether annotation results of ctx are cached.
+func (s *Server) cachingEnabled(ctx *assemblyContext) bool {
+	return s.results.lru != nil || ctx.resultStore != nil
+}
+
+// fetchMyVariantInfo fetches a myvariant.info annotation, caching responses
+// (including "not found") in memory.
+func (s *Server) fetchMyVariantInfo(hgvsg, assembly string) (*myvariantinfo.MyVariantInfoResponse, error) {
+	key := "myvariant.info|" + assembly + "|" + hgvsg
+	if s.results.lru != nil {
+		if resp, ok := s.results.lru.Get(key); ok {
+			s.results.remoteHits.Add(1)
+			return resp.(*myvariantinfo.MyVariantInfoResponse), nil
+		}
+	}
+	s.results.remoteMiss.Add(1)
+	resp, err := s.myVariantClient.Fetch(hgvsg, assembly)
+	if err != nil {
+		return nil, err
+	}
+	if s.results.lru != nil {
+		s.results.lru.Add(key, resp)
+	}
+	return resp, nil
+}
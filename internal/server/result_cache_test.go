@@ -0,0 +1,147 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+
+	"github.com/inodb/vibe-vep/internal/annotate"
+	"github.com/inodb/vibe-vep/internal/duckdb"
+)
+
+func TestLRUEviction(t *testing.T) {
+	c := newLRU(2)
+	c.Add("a", 1)
+	c.Add("b", 2)
+	c.Get("a") // b is now the least recently used
+	c.Add("c", 3)
+
+	if _, ok := c.Get("b"); ok {
+		t.Error("expected b to be evicted")
+	}
+	for _, k := range []string{"a", "c"} {
+		if _, ok := c.Get(k); !ok {
+			t.Errorf("expected %s to be cached", k)
+		}
+	}
+	if c.Len() != 2 {
+		t.Errorf("Len: got %d, want 2", c.Len())
+	}
+}
+
+// fingerprintSource is a source whose data changes without its version.
+type fingerprintSource struct {
+	versionSource
+	fingerprint string
+}
+
+func (s fingerprintSource) Fingerprint() string { return s.fingerprint }
+
+func TestDataVersion_Fingerprint(t *testing.T) {
+	srv := New(zap.NewNop(), "test")
+	version := func(fingerprint string) string {
+		d := testAssemblyData("v1", nil)
+		d.Sources = []annotate.AnnotationSource{fingerprintSource{"v1", fingerprint}}
+		return srv.newAssemblyContext(d).dataVersion
+	}
+	if version("100-1") == version("100-2") {
+		t.Error("data version should change with the source fingerprint")
+	}
+	if version("100-1") != version("100-1") {
+		t.Error("data version should be stable for the same fingerprint")
+	}
+}
+
+// postGNGenomic posts a genome-nexus genomic batch and returns the body.
+func postGNGenomic(t *testing.T, srv *Server, body string) string {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodPost, "/genome-nexus/grch38/annotation/genomic", strings.NewReader(body))
+	w := httptest.NewRecorder()
+	srv.Handler().ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	return w.Body.String()
+}
+
+// cacheInfo returns the result_cache and first assembly sections of /info.
+func cacheInfo(t *testing.T, srv *Server) (ResultCacheStats, string) {
+	t.Helper()
+	w := httptest.NewRecorder()
+	srv.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/info", nil))
+	var info struct {
+		ResultCache ResultCacheStats `json:"result_cache"`
+		Assemblies  []struct {
+			DataVersion string `json:"data_version"`
+		} `json:"assemblies"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &info); err != nil {
+		t.Fatalf("decode /info: %v", err)
+	}
+	return info.ResultCache, info.Assemblies[0].DataVersion
+}
+
+const krasBatch = `[
+	{"chromosome":"12","start":25245351,"end":25245351,"referenceAllele":"C","variantAllele":"A"},
+	{"chromosome":"chr12","start":25245350,"end":25245350,"referenceAllele":"C","variantAllele":"T"}
+]`
+
+func TestResultCache_Memory(t *testing.T) {
+	srv := newTestServerWithKRAS(t)
+	srv.SetResultCacheSize(100)
+
+	first := postGNGenomic(t, srv, krasBatch)
+	second := postGNGenomic(t, srv, krasBatch)
+	if first != second {
+		t.Errorf("cached response differs:\nfirst:  %s\nsecond: %s", first, second)
+	}
+
+	stats, _ := cacheInfo(t, srv)
+	if stats.Misses != 2 || stats.Hits != 2 {
+		t.Errorf("hits/misses: got %d/%d, want 2/2", stats.Hits, stats.Misses)
+	}
+	if stats.Entries != 2 || stats.Capacity != 100 {
+		t.Errorf("entries/capacity: got %d/%d, want 2/100", stats.Entries, stats.Capacity)
+	}
+}
+
+func TestResultCache_Persistent(t *testing.T) {
+	store, err := duckdb.Open("")
+	if err != nil {
+		t.Fatalf("open store: %v", err)
+	}
+	defer store.Close()
+
+	srv := newTestServerWithKRAS(t)
+	srv.SetResultStore("GRCh38", store)
+	first := postGNGenomic(t, srv, krasBatch)
+
+	// A restarted server with the same data reads the stored results.
+	restarted := newTestServerWithKRAS(t)
+	restarted.SetResultStore("GRCh38", store)
+	if got := postGNGenomic(t, restarted, krasBatch); got != first {
+		t.Errorf("stored response differs:\nfirst: %s\ngot:   %s", first, got)
+	}
+	stats, version := cacheInfo(t, restarted)
+	if stats.Hits != 2 || stats.PersistentHits != 2 || stats.Misses != 0 {
+		t.Errorf("hits/persistent/misses: got %d/%d/%d, want 2/2/0", stats.Hits, stats.PersistentHits, stats.Misses)
+	}
+
+	// A server with other data (here: another version) invalidates them.
+	upgraded := newTestServerWithKRAS(t)
+	upgraded.version = "test-2"
+	upgraded.AddAssembly("GRCh38", restarted.getAssembly("grch38").cache, restarted.getAssembly("grch38").annotator, nil)
+	upgraded.SetResultStore("GRCh38", store)
+	postGNGenomic(t, upgraded, krasBatch)
+	stats, upgradedVersion := cacheInfo(t, upgraded)
+	if upgradedVersion == version {
+		t.Errorf("data version unchanged: %s", version)
+	}
+	if stats.Hits != 0 || stats.Misses != 2 {
+		t.Errorf("hits/misses after upgrade: got %d/%d, want 0/2", stats.Hits, stats.Misses)
+	}
+}
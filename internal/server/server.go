@@ -2,10 +2,12 @@
 package server
 
 import (
+	"context"
 	"encoding/json"
 	"net/http"
 	"strings"
 	"sync"
+	"time"
 
 	"go.uber.org/zap"
 
@@ -15,6 +17,8 @@ import (
 	"github.com/inodb/vibe-vep/internal/datasource/pfam"
 	"github.com/inodb/vibe-vep/internal/datasource/ptm"
 	"github.com/inodb/vibe-vep/internal/datasource/uniprot"
+	"github.com/inodb/vibe-vep/internal/duckdb"
+	"github.com/inodb/vibe-vep/internal/output"
 	"github.com/inodb/vibe-vep/internal/vcf"
 )
 
@@ -27,6 +31,17 @@ type assemblyContext struct {
 	ptm       *ptm.Store
 	uniprot   *uniprot.Store
 	assembly  string // normalized: "GRCh38"
+
+	// dataVersion keys cached results (see dataVersion); resultStore
+	// persists them, nil if results are only cached in memory.
+	dataVersion string
+	resultStore *duckdb.Store
+
+	// inflight counts the requests using the context (see acquireAssembly);
+	// closer releases its data once it is replaced and they have finished.
+	inflight sync.WaitGroup
+	closer   func()
+	loadedAt time.Time
 }
 
 // Server is the HTTP annotation server.
@@ -36,6 +51,16 @@ type Server struct {
 	logger          *zap.Logger
 	version         string
 	myVariantClient *myvariantinfo.Client
+	pickOrder       output.PickOrder
+	maxBatchSize    int           // 0: unlimited
+	requestTimeout  time.Duration // 0: no deadline beyond the client's
+	results         resultCache
+	metrics         *metrics
+	loader          Loader // nil: reload is not configured
+	adminToken      string // "": /admin endpoints are open
+
+	reloadMu sync.Mutex
+	reload   reloadStatus
 }
 
 // New creates a new Server.
@@ -45,18 +70,64 @@ func New(logger *zap.Logger, version string) *Server {
 		logger:          logger,
 		version:         version,
 		myVariantClient: myvariantinfo.NewClient(),
+		metrics:         newMetrics(),
 	}
 }
 
 // AddAssembly registers an assembly with its annotator and sources.
 func (s *Server) AddAssembly(assembly string, c *cache.Cache, ann *annotate.Annotator, sources []annotate.AnnotationSource) {
+	s.AddAssemblyData(&AssemblyData{Assembly: assembly, Cache: c, Annotator: ann, Sources: sources})
+}
+
+// SetPickOrder sets the transcript ranking used for pick=1/flag_pick=1 and
+// the genome-nexus annotation_summary transcript.
+func (s *Server) SetPickOrder(order output.PickOrder) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.pickOrder = order
+}
+
+// SetBatchLimits sets the maximum number of items in a batch POST (0 for no
+// limit) and the deadline for annotating one request (0 for none). Batch
+// items not annotated before the deadline are returned as errors.
+func (s *Server) SetBatchLimits(maxBatchSize int, requestTimeout time.Duration) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
-	s.assemblies[strings.ToLower(assembly)] = &assemblyContext{
-		annotator: ann,
-		sources:   sources,
-		cache:     c,
-		assembly:  assembly,
+	s.maxBatchSize = maxBatchSize
+	s.requestTimeout = requestTimeout
+}
+
+// SetResultCacheSize enables an in-memory LRU cache of up to entries
+// annotation results and myvariant.info responses. Call before serving.
+func (s *Server) SetResultCacheSize(entries int) {
+	s.results.lru = nil
+	if entries > 0 {
+		s.results.lru = newLRU(entries)
+	}
+}
+
+// SetResultStore persists the annotation results of the given assembly in
+// store, so they survive restarts. Results computed from other data (see
+// dataVersion) are deleted from it. Call before serving.
+func (s *Server) SetResultStore(assembly string, store *duckdb.Store) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ctx, ok := s.assemblies[strings.ToLower(assembly)]
+	if !ok {
+		return
+	}
+	ctx.resultStore = store
+	s.pruneResultStore(ctx)
+}
+
+// pruneResultStore deletes results computed from other data than ctx's from
+// its result store.
+func (s *Server) pruneResultStore(ctx *assemblyContext) {
+	n, err := ctx.resultStore.DeleteStaleResults(ctx.dataVersion)
+	if err != nil {
+		s.logger.Warn("could not prune result store", zap.String("assembly", ctx.assembly), zap.Error(err))
+	} else if n > 0 {
+		s.logger.Info("pruned stale cached results", zap.String("assembly", ctx.assembly), zap.Int64("results", n))
 	}
 }
 
@@ -98,9 +169,21 @@ func (s *Server) getAssembly(name string) *assemblyContext {
 func (s *Server) Handler() http.Handler {
 	mux := http.NewServeMux()
 
-	// Health and info endpoints.
+	// Health, info and metrics endpoints.
 	mux.HandleFunc("GET /health", s.handleHealth)
 	mux.HandleFunc("GET /info", s.handleInfo)
+	mux.HandleFunc("GET /metrics", s.handleMetrics)
+
+	// Reload of the transcript cache and annotation sources.
+	mux.HandleFunc("POST /admin/reload", s.handleAdminReload)
+
+	// Native vibe-vep API.
+	mux.HandleFunc("GET /v1/annotate", s.handleV1AnnotateGet)
+	mux.HandleFunc("POST /v1/annotate", s.handleV1AnnotatePost)
+	mux.HandleFunc("GET /v1/openapi.json", s.handleOpenAPI)
+
+	// HGVS normalization (offline variant validator).
+	mux.HandleFunc("POST /hgvs/normalize", s.handleHGVSNormalize)
 
 	// Ensembl VEP compatibility endpoints.
 	mux.HandleFunc("GET /ensembl/{assembly}/vep/human/region/{region}/{allele}", s.handleEnsemblRegionGet)
@@ -111,6 +194,8 @@ func (s *Server) Handler() http.Handler {
 	// Genome-nexus compatibility endpoints.
 	mux.HandleFunc("GET /genome-nexus/{assembly}/annotation/genomic/{genomicLocation}", s.handleGNGenomicGet)
 	mux.HandleFunc("POST /genome-nexus/{assembly}/annotation/genomic", s.handleGNGenomicPost)
+	mux.HandleFunc("GET /genome-nexus/{assembly}/annotation/dbsnp/{rsid}", s.handleGNDbSNPGet)
+	mux.HandleFunc("POST /genome-nexus/{assembly}/annotation/dbsnp", s.handleGNDbSNPPost)
 	mux.HandleFunc("GET /genome-nexus/{assembly}/annotation/{variant...}", s.handleGNHGVSGet)
 	mux.HandleFunc("POST /genome-nexus/{assembly}/annotation", s.handleGNHGVSPost)
 
@@ -141,7 +226,7 @@ func (s *Server) Handler() http.Handler {
 	mux.HandleFunc("POST /genome-nexus/{assembly}/cancer_hotspots/genomic", emptyArray)
 	mux.HandleFunc("GET /genome-nexus/{assembly}/ensembl/xrefs", emptyArray)
 
-	return corsMiddleware(mux)
+	return s.instrument(corsMiddleware(mux))
 }
 
 // corsMiddleware adds CORS headers to allow cross-origin requests.
@@ -165,43 +250,73 @@ func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
 }
 
-// handleInfo returns server version and loaded assemblies.
+// handleInfo returns server version and loaded assemblies, with the
+// versions of the data currently serving each.
 func (s *Server) handleInfo(w http.ResponseWriter, r *http.Request) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
+	type sourceInfo struct {
+		Name    string `json:"name"`
+		Version string `json:"version"`
+	}
 	type assemblyInfo struct {
-		Name             string `json:"name"`
-		TranscriptCount  int    `json:"transcript_count"`
-		AnnotationSources int   `json:"annotation_sources"`
+		Name              string       `json:"name"`
+		TranscriptCount   int          `json:"transcript_count"`
+		AnnotationSources int          `json:"annotation_sources"`
+		Sources           []sourceInfo `json:"sources"`
+		DataVersion       string       `json:"data_version"`
+		PersistentCache   bool         `json:"persistent_cache"`
+		LoadedAt          time.Time    `json:"loaded_at"`
 	}
 
 	assemblies := make([]assemblyInfo, 0, len(s.assemblies))
 	for _, ctx := range s.assemblies {
+		sources := make([]sourceInfo, 0, len(ctx.sources))
+		for _, src := range ctx.sources {
+			sources = append(sources, sourceInfo{Name: sourceLabel(src), Version: src.Version()})
+		}
 		assemblies = append(assemblies, assemblyInfo{
-			Name:             ctx.assembly,
-			TranscriptCount:  ctx.cache.TranscriptCount(),
+			Name:              ctx.assembly,
+			TranscriptCount:   ctx.cache.TranscriptCount(),
 			AnnotationSources: len(ctx.sources),
+			Sources:           sources,
+			DataVersion:       ctx.dataVersion,
+			PersistentCache:   ctx.resultStore != nil,
+			LoadedAt:          ctx.loadedAt,
 		})
 	}
 
 	info := map[string]any{
-		"version":    s.version,
-		"assemblies": assemblies,
+		"version":      s.version,
+		"assemblies":   assemblies,
+		"result_cache": s.results.stats(),
+		"reload":       s.reloadState(),
 	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(info)
 }
 
-// annotateVariant runs the annotator + sources for a single variant in the given assembly context.
-func (s *Server) annotateVariant(ctx *assemblyContext, v *vcf.Variant) ([]*annotate.Annotation, error) {
-	anns, err := ctx.annotator.Annotate(v)
+// annotateVariant runs the annotator + sources for a single variant in the
+// given assembly context, or returns the cached result. Annotator messages
+// are logged with the request ID of rctx.
+func (s *Server) annotateVariant(rctx context.Context, ctx *assemblyContext, v *vcf.Variant) ([]*annotate.Annotation, error) {
+	var key string
+	if s.cachingEnabled(ctx) {
+		key = resultKey(ctx, v)
+		if cr, ok := s.lookupResults(ctx, []string{key})[key]; ok {
+			return cr.apply(v), nil
+		}
+	}
+	anns, err := ctx.annotator.AnnotateWithLogger(v, s.requestLogger(rctx))
 	if err != nil {
+		s.metrics.annotationError(ctx.assembly)
 		return nil, err
 	}
-	for _, src := range ctx.sources {
-		src.Annotate(v, anns)
+	s.runSources(ctx, v, anns)
+	if key != "" {
+		s.saveResults(ctx, map[string]*cachedResult{key: newCachedResult(v, anns)})
 	}
 	return anns, nil
 }
@@ -222,19 +337,14 @@ func writeError(w http.ResponseWriter, status int, msg string) {
 // assembly_name and variant fields. This allows clients (like the frontend)
 // to detect the genome build even when annotation fails.
 func writeGNError(w http.ResponseWriter, variant, assembly, msg string) {
-	writeJSON(w, http.StatusOK, map[string]interface{}{
-		"variant":                variant,
-		"originalVariantQuery":   variant,
-		"assembly_name":          assembly,
-		"successfully_annotated": false,
-		"errorMessage":           msg,
-	})
+	writeJSON(w, http.StatusOK, gnError(variant, assembly, msg))
 }
 
-// requireAssembly extracts and validates the assembly from the URL path.
+// requireAssembly extracts and validates the assembly from the URL path and
+// holds it for the rest of the request (see acquireAssembly).
 func (s *Server) requireAssembly(w http.ResponseWriter, r *http.Request) *assemblyContext {
 	name := r.PathValue("assembly")
-	ctx := s.getAssembly(name)
+	ctx := s.acquireAssembly(r, name)
 	if ctx == nil {
 		loaded := make([]string, 0)
 		s.mu.RLock()
@@ -0,0 +1,148 @@
+package vcf
+
+import (
+	"bytes"
+	"compress/flate"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// ErrNotBGZF is returned when an indexed query is attempted on a file that is
+// not BGZF-compressed (plain gzip cannot be seeked).
+var ErrNotBGZF = errors.New("file is not BGZF-compressed (compress with bgzip)")
+
+// bgzfReader reads a BGZF file block by block and can seek to BGZF virtual
+// offsets (compressed block offset << 16 | offset within the block).
+type bgzfReader struct {
+	r       io.ReaderAt
+	block   []byte // uncompressed data of the current block
+	coffset int64  // file offset of the current block
+	next    int64  // file offset of the following block
+	pos     int    // read position within block
+	eof     bool
+}
+
+func newBGZFReader(r io.ReaderAt) *bgzfReader {
+	return &bgzfReader{r: r}
+}
+
+// readBlock loads the block at file offset coff.
+func (b *bgzfReader) readBlock(coff int64) error {
+	var hdr [18]byte
+	n, err := b.r.ReadAt(hdr[:], coff)
+	if n == 0 && err == io.EOF {
+		b.eof = true
+		b.block, b.pos, b.coffset, b.next = nil, 0, coff, coff
+		return io.EOF
+	}
+	if n < len(hdr) {
+		return ErrNotBGZF
+	}
+	if hdr[0] != 0x1f || hdr[1] != 0x8b || hdr[2] != 8 || hdr[3]&4 == 0 {
+		return ErrNotBGZF
+	}
+	xlen := int(binary.LittleEndian.Uint16(hdr[10:12]))
+	extra := make([]byte, xlen)
+	if _, err := b.r.ReadAt(extra, coff+12); err != nil {
+		return fmt.Errorf("read BGZF header: %w", err)
+	}
+	bsize := -1
+	for i := 0; i+4 <= len(extra); {
+		slen := int(binary.LittleEndian.Uint16(extra[i+2 : i+4]))
+		if extra[i] == 'B' && extra[i+1] == 'C' && slen == 2 && i+6 <= len(extra) {
+			bsize = int(binary.LittleEndian.Uint16(extra[i+4:i+6])) + 1
+			break
+		}
+		i += 4 + slen
+	}
+	if bsize < 0 {
+		return ErrNotBGZF
+	}
+
+	raw := make([]byte, bsize)
+	if _, err := b.r.ReadAt(raw, coff); err != nil && err != io.EOF {
+		return fmt.Errorf("read BGZF block: %w", err)
+	}
+	isize := binary.LittleEndian.Uint32(raw[bsize-4:])
+	data := make([]byte, isize)
+	fr := flate.NewReader(bytes.NewReader(raw[12+xlen : bsize-8]))
+	defer fr.Close()
+	if _, err := io.ReadFull(fr, data); err != nil {
+		return fmt.Errorf("inflate BGZF block at %d: %w", coff, err)
+	}
+
+	b.block, b.pos, b.coffset, b.next = data, 0, coff, coff+int64(bsize)
+	return nil
+}
+
+// Seek positions the reader at virtual offset voff.
+func (b *bgzfReader) Seek(voff uint64) error {
+	coff, uoff := int64(voff>>16), int(voff&0xffff)
+	b.eof = false
+	if b.block == nil || coff != b.coffset {
+		if err := b.readBlock(coff); err != nil && err != io.EOF {
+			return err
+		}
+	}
+	if uoff > len(b.block) {
+		return fmt.Errorf("BGZF virtual offset %d past end of block", voff)
+	}
+	b.pos = uoff
+	return nil
+}
+
+// fill makes sure unread data is available, skipping empty blocks.
+// Returns io.EOF at the end of the file.
+func (b *bgzfReader) fill() error {
+	for b.pos >= len(b.block) {
+		if b.eof {
+			return io.EOF
+		}
+		if err := b.readBlock(b.next); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Tell returns the virtual offset of the next unread byte.
+func (b *bgzfReader) Tell() uint64 {
+	return uint64(b.coffset)<<16 | uint64(b.pos)
+}
+
+// Read implements io.Reader.
+func (b *bgzfReader) Read(p []byte) (int, error) {
+	if err := b.fill(); err != nil {
+		return 0, err
+	}
+	n := copy(p, b.block[b.pos:])
+	b.pos += n
+	return n, nil
+}
+
+// ReadLine reads the next line without its terminator and returns it with
+// the virtual offset it starts at.
+func (b *bgzfReader) ReadLine() (string, uint64, error) {
+	if err := b.fill(); err != nil {
+		return "", 0, err
+	}
+	start := b.Tell()
+	var line []byte
+	for {
+		if i := bytes.IndexByte(b.block[b.pos:], '\n'); i >= 0 {
+			line = append(line, b.block[b.pos:b.pos+i]...)
+			b.pos += i + 1
+			break
+		}
+		line = append(line, b.block[b.pos:]...)
+		b.pos = len(b.block)
+		if err := b.fill(); err == io.EOF {
+			break
+		} else if err != nil {
+			return "", 0, err
+		}
+	}
+	return string(bytes.TrimRight(line, "\r")), start, nil
+}
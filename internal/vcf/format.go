@@ -0,0 +1,213 @@
+package vcf
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Genotype holds the FORMAT fields of one sample that vibe-vep uses.
+// Missing values are nil (AD, AF) or -1 (DP, PS).
+type Genotype struct {
+	GT      string    // raw genotype, e.g. "0/1", "1|1", "./."
+	Alleles []int     // allele indices from GT, -1 for a missing call
+	Phased  bool      // GT uses the phased separator '|'
+	AD      []int     // allelic depths: REF then each ALT
+	DP      int       // read depth
+	AF      []float64 // allele frequency of each ALT
+	PS      int       // phase set of a phased GT; phased calls without PS share one set
+}
+
+// Genotypes parses the FORMAT and sample columns of v, one Genotype per
+// sample in header order. Returns nil if the record has no sample columns.
+// Invalid values are left missing; the genotypes are returned together with
+// an error describing the first of them.
+func (v *Variant) Genotypes() ([]Genotype, error) {
+	if v.SampleColumns == "" {
+		return nil, nil
+	}
+	cols := strings.Split(v.SampleColumns, "\t")
+	keys := strings.Split(cols[0], ":")
+	gts := make([]Genotype, len(cols)-1)
+	var firstErr error
+	for i, col := range cols[1:] {
+		g := Genotype{DP: -1, PS: -1}
+		for j, val := range strings.Split(col, ":") {
+			if j >= len(keys) || val == "" {
+				continue
+			}
+			if keys[j] == "GT" {
+				g.GT = val
+				g.Alleles, g.Phased = parseGT(val)
+				continue
+			}
+			if val == "." {
+				continue
+			}
+			var err error
+			switch keys[j] {
+			case "AD":
+				g.AD, err = parseInts(val)
+			case "DP":
+				g.DP, err = parseCount(val)
+			case "AF":
+				g.AF, err = parseFloats(val)
+			case "PS":
+				g.PS, err = parseCount(val)
+			}
+			if err != nil && firstErr == nil {
+				firstErr = fmt.Errorf("sample %d: invalid %s %q", i+1, keys[j], val)
+			}
+		}
+		gts[i] = g
+	}
+	return gts, firstErr
+}
+
+// parseGT splits a GT value into allele indices (-1 for '.').
+func parseGT(gt string) ([]int, bool) {
+	phased := strings.Contains(gt, "|")
+	parts := strings.FieldsFunc(gt, func(r rune) bool { return r == '/' || r == '|' })
+	alleles := make([]int, len(parts))
+	for i, p := range parts {
+		n, err := strconv.Atoi(p)
+		if err != nil {
+			n = -1
+		}
+		alleles[i] = n
+	}
+	return alleles, phased
+}
+
+// parseCount parses a non-negative integer, returning -1 if it is invalid.
+func parseCount(s string) (int, error) {
+	n, err := strconv.Atoi(s)
+	if err != nil || n < 0 {
+		return -1, fmt.Errorf("invalid count %q", s)
+	}
+	return n, nil
+}
+
+func parseInts(s string) ([]int, error) {
+	parts := strings.Split(s, ",")
+	nums := make([]int, len(parts))
+	for i, p := range parts {
+		if p == "." {
+			nums[i] = -1
+			continue
+		}
+		n, err := strconv.Atoi(p)
+		if err != nil {
+			return nil, err
+		}
+		nums[i] = n
+	}
+	return nums, nil
+}
+
+func parseFloats(s string) ([]float64, error) {
+	parts := strings.Split(s, ",")
+	nums := make([]float64, len(parts))
+	for i, p := range parts {
+		if p == "." {
+			nums[i] = -1
+			continue
+		}
+		f, err := strconv.ParseFloat(p, 64)
+		if err != nil {
+			return nil, err
+		}
+		nums[i] = f
+	}
+	return nums, nil
+}
+
+// HasGT reports whether the sample has a genotype call.
+func (g Genotype) HasGT() bool {
+	return g.GT != ""
+}
+
+// NoCall reports whether the sample has a GT with no called allele, e.g.
+// "./." or ".".
+func (g Genotype) NoCall() bool {
+	if !g.HasGT() {
+		return false
+	}
+	for _, a := range g.Alleles {
+		if a >= 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// Carries reports whether the genotype contains ALT allele alt (1-based).
+func (g Genotype) Carries(alt int) bool {
+	for _, a := range g.Alleles {
+		if a == alt {
+			return true
+		}
+	}
+	return false
+}
+
+// HomAlt reports whether every called allele is ALT allele alt (1-based).
+func (g Genotype) HomAlt(alt int) bool {
+	called := 0
+	for _, a := range g.Alleles {
+		if a < 0 {
+			continue
+		}
+		if a != alt {
+			return false
+		}
+		called++
+	}
+	return called > 0
+}
+
+// RefCount returns the REF read count from AD, or -1 if unknown.
+func (g Genotype) RefCount() int {
+	if len(g.AD) == 0 {
+		return -1
+	}
+	return g.AD[0]
+}
+
+// AltCount returns the read count of ALT allele alt (1-based) from AD, or -1
+// if unknown.
+func (g Genotype) AltCount(alt int) int {
+	if alt < 1 || alt >= len(g.AD) {
+		return -1
+	}
+	return g.AD[alt]
+}
+
+// Depth returns DP, or -1 if absent. The sum of AD is not used instead, as
+// callers commonly leave filtered reads out of AD.
+func (g Genotype) Depth() int {
+	return g.DP
+}
+
+// Allele returns the 1-based index of v's ALT allele in its original record,
+// for use with Genotype methods.
+func (v *Variant) Allele() int {
+	if v.AltIndex > 0 {
+		return v.AltIndex
+	}
+	return 1
+}
+
+// TumorNormalFromHeader returns the tumor and normal sample names declared by
+// somatic callers in ##tumor_sample= and ##normal_sample= header lines
+// (e.g. GATK Mutect2), or "" if absent.
+func TumorNormalFromHeader(header []string) (tumor, normal string) {
+	for _, line := range header {
+		if v, ok := strings.CutPrefix(line, "##tumor_sample="); ok {
+			tumor = v
+		} else if v, ok := strings.CutPrefix(line, "##normal_sample="); ok {
+			normal = v
+		}
+	}
+	return tumor, normal
+}
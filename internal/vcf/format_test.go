@@ -0,0 +1,100 @@
+package vcf
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVariant_Genotypes(t *testing.T) {
+	v := &Variant{SampleColumns: "GT:AD:DP:AF\t0/1:20,10:31:0.33\t1|1:0,15:.:1\t./.:.:.:.\t0/2:5,0,7"}
+
+	gts, err := v.Genotypes()
+	require.NoError(t, err)
+	require.Len(t, gts, 4)
+
+	het := gts[0]
+	assert.Equal(t, "0/1", het.GT)
+	assert.Equal(t, []int{0, 1}, het.Alleles)
+	assert.False(t, het.Phased)
+	assert.Equal(t, []int{20, 10}, het.AD)
+	assert.Equal(t, 31, het.DP)
+	assert.Equal(t, []float64{0.33}, het.AF)
+	assert.True(t, het.Carries(1))
+	assert.False(t, het.HomAlt(1))
+	assert.Equal(t, 20, het.RefCount())
+	assert.Equal(t, 10, het.AltCount(1))
+	assert.Equal(t, 31, het.Depth())
+
+	hom := gts[1]
+	assert.True(t, hom.Phased)
+	assert.True(t, hom.HomAlt(1))
+	assert.Equal(t, -1, hom.Depth(), "DP missing: AD is not summed")
+
+	missing := gts[2]
+	assert.True(t, missing.HasGT())
+	assert.True(t, missing.NoCall())
+	assert.False(t, het.NoCall())
+	assert.Equal(t, []int{-1, -1}, missing.Alleles)
+	assert.False(t, missing.Carries(1))
+	assert.Equal(t, -1, missing.RefCount())
+	assert.Equal(t, -1, missing.Depth())
+
+	// Trailing FORMAT fields may be dropped.
+	second := gts[3]
+	assert.True(t, second.Carries(2))
+	assert.False(t, second.Carries(1))
+	assert.Equal(t, 7, second.AltCount(2))
+	assert.Equal(t, -1, second.DP)
+}
+
+func TestVariant_GenotypesPhaseSet(t *testing.T) {
+	v := &Variant{SampleColumns: "GT:PS\t0|1:140753336\t1|0:.\t0/1"}
+
+	gts, err := v.Genotypes()
+	require.NoError(t, err)
+	require.Len(t, gts, 3)
+	assert.True(t, gts[0].Phased)
+	assert.Equal(t, 140753336, gts[0].PS)
+	assert.Equal(t, -1, gts[1].PS, "missing PS")
+	assert.Equal(t, -1, gts[2].PS, "no PS value")
+}
+
+func TestVariant_GenotypesNoSamples(t *testing.T) {
+	gts, err := (&Variant{}).Genotypes()
+	require.NoError(t, err)
+	assert.Nil(t, gts)
+
+	gts, err = (&Variant{SampleColumns: "GT:DP:AD\t0/1:many:3,4\t.:12"}).Genotypes()
+	assert.Error(t, err)
+	require.Len(t, gts, 2, "invalid values do not drop the record")
+	assert.Equal(t, -1, gts[0].DP)
+	assert.Equal(t, []int{3, 4}, gts[0].AD)
+	assert.True(t, gts[1].NoCall())
+	assert.Equal(t, 12, gts[1].DP)
+}
+
+func TestSplitMultiAllelic_AltIndex(t *testing.T) {
+	v := &Variant{Chrom: "1", Pos: 100, Ref: "A", Alt: "C,T"}
+	assert.Equal(t, 1, v.Allele())
+
+	variants := SplitMultiAllelic(v)
+	require.Len(t, variants, 2)
+	assert.Equal(t, 1, variants[0].Allele())
+	assert.Equal(t, 2, variants[1].Allele())
+}
+
+func TestTumorNormalFromHeader(t *testing.T) {
+	tumor, normal := TumorNormalFromHeader([]string{
+		"##fileformat=VCFv4.2",
+		"##normal_sample=PATIENT1_N",
+		"##tumor_sample=PATIENT1_T",
+	})
+	assert.Equal(t, "PATIENT1_T", tumor)
+	assert.Equal(t, "PATIENT1_N", normal)
+
+	tumor, normal = TumorNormalFromHeader([]string{"##fileformat=VCFv4.2"})
+	assert.Empty(t, tumor)
+	assert.Empty(t, normal)
+}
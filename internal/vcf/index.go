@@ -0,0 +1,278 @@
+package vcf
+
+import (
+	"compress/gzip"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+)
+
+// Chunk is a range of BGZF virtual offsets [Begin, End) holding records.
+type Chunk struct {
+	Begin, End uint64
+}
+
+// refIndex holds the binning index of one sequence.
+type refIndex struct {
+	bins    map[uint32][]Chunk
+	loffset map[uint32]uint64 // CSI: smallest record offset per bin
+	linear  []uint64          // TBI: smallest record offset per 16 kb window
+}
+
+// Index is a tabix (.tbi) or CSI (.csi) index of a BGZF-compressed VCF.
+type Index struct {
+	names    []string
+	refs     map[string]int
+	minShift int
+	depth    int
+	csi      bool
+	seqs     []refIndex
+}
+
+// LoadIndex loads the .tbi or .csi index of a BGZF-compressed VCF, trying
+// path.tbi then path.csi.
+func LoadIndex(vcfPath string) (*Index, error) {
+	for _, ext := range []string{".tbi", ".csi"} {
+		data, err := readGzipFile(vcfPath + ext)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		idx, err := parseIndex(data)
+		if err != nil {
+			return nil, fmt.Errorf("%s%s: %w", vcfPath, ext, err)
+		}
+		return idx, nil
+	}
+	return nil, fmt.Errorf("no .tbi or .csi index for %s (create one with tabix -p vcf or bcftools index)", vcfPath)
+}
+
+func readGzipFile(path string) ([]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	zr, err := gzip.NewReader(f)
+	if err != nil {
+		return nil, fmt.Errorf("open index %s: %w", path, err)
+	}
+	defer zr.Close()
+	data, err := io.ReadAll(zr)
+	if err != nil {
+		return nil, fmt.Errorf("read index %s: %w", path, err)
+	}
+	return data, nil
+}
+
+// indexBuf decodes little-endian index data.
+type indexBuf struct {
+	data []byte
+	err  error
+}
+
+func (b *indexBuf) take(n int) []byte {
+	if b.err != nil {
+		return nil
+	}
+	if n < 0 || n > len(b.data) {
+		b.err = io.ErrUnexpectedEOF
+		return nil
+	}
+	out := b.data[:n]
+	b.data = b.data[n:]
+	return out
+}
+
+func (b *indexBuf) int32() int32 {
+	p := b.take(4)
+	if p == nil {
+		return 0
+	}
+	return int32(binary.LittleEndian.Uint32(p))
+}
+
+func (b *indexBuf) uint64() uint64 {
+	p := b.take(8)
+	if p == nil {
+		return 0
+	}
+	return binary.LittleEndian.Uint64(p)
+}
+
+// parseNames decodes the tabix header following the magic (or the CSI aux
+// data) and returns the sequence names.
+func parseNames(b *indexBuf) []string {
+	for range 6 { // format, col_seq, col_beg, col_end, meta, skip
+		b.int32()
+	}
+	names := b.take(int(b.int32()))
+	if b.err != nil {
+		return nil
+	}
+	return strings.Split(strings.TrimRight(string(names), "\x00"), "\x00")
+}
+
+func parseIndex(data []byte) (*Index, error) {
+	b := &indexBuf{data: data}
+	idx := &Index{refs: make(map[string]int)}
+
+	switch string(b.take(4)) {
+	case "TBI\x01":
+		idx.minShift, idx.depth = 14, 5
+		nRef := int(b.int32())
+		idx.names = parseNames(b)
+		if b.err == nil && len(idx.names) != nRef {
+			return nil, fmt.Errorf("index names %d sequences, header says %d", len(idx.names), nRef)
+		}
+	case "CSI\x01":
+		idx.csi = true
+		idx.minShift, idx.depth = int(b.int32()), int(b.int32())
+		aux := b.take(int(b.int32()))
+		if len(aux) == 0 && b.err == nil {
+			return nil, fmt.Errorf("CSI index has no sequence names (not a VCF index?)")
+		}
+		idx.names = parseNames(&indexBuf{data: aux})
+		b.int32() // n_ref
+	default:
+		return nil, fmt.Errorf("not a tabix or CSI index")
+	}
+
+	idx.seqs = make([]refIndex, len(idx.names))
+	for i := range idx.seqs {
+		ref := refIndex{bins: make(map[uint32][]Chunk)}
+		if idx.csi {
+			ref.loffset = make(map[uint32]uint64)
+		}
+		nBin := int(b.int32())
+		for range nBin {
+			bin := uint32(b.int32())
+			if idx.csi {
+				ref.loffset[bin] = b.uint64()
+			}
+			nChunk := int(b.int32())
+			if b.err != nil || nChunk < 0 {
+				break
+			}
+			chunks := make([]Chunk, nChunk)
+			for j := range chunks {
+				chunks[j] = Chunk{Begin: b.uint64(), End: b.uint64()}
+			}
+			ref.bins[bin] = chunks
+		}
+		if !idx.csi {
+			nIntv := int(b.int32())
+			if b.err == nil && nIntv > 0 {
+				ref.linear = make([]uint64, nIntv)
+				for j := range ref.linear {
+					ref.linear[j] = b.uint64()
+				}
+			}
+		}
+		if b.err != nil {
+			return nil, fmt.Errorf("truncated index: %w", b.err)
+		}
+		idx.seqs[i] = ref
+	}
+	for i, name := range idx.names {
+		idx.refs[name] = i
+	}
+	return idx, nil
+}
+
+// Names returns the indexed sequence names in file order.
+func (idx *Index) Names() []string {
+	return idx.names
+}
+
+// SeqName resolves chrom against the indexed names, adding or removing the
+// "chr" prefix if needed.
+func (idx *Index) SeqName(chrom string) (string, bool) {
+	if _, ok := idx.refs[chrom]; ok {
+		return chrom, true
+	}
+	alt := "chr" + chrom
+	if bare, ok := strings.CutPrefix(chrom, "chr"); ok {
+		alt = bare
+	}
+	if _, ok := idx.refs[alt]; ok {
+		return alt, true
+	}
+	return "", false
+}
+
+// reg2bins returns the bins overlapping the 0-based half-open interval
+// [beg, end).
+func reg2bins(beg, end int64, minShift, depth int) []uint32 {
+	end--
+	var bins []uint32
+	s := minShift + depth*3
+	t := 0
+	for l := 0; l <= depth; l++ {
+		for i := t + int(beg>>s); i <= t+int(end>>s); i++ {
+			bins = append(bins, uint32(i))
+		}
+		s -= 3
+		t += 1 << (l * 3)
+	}
+	return bins
+}
+
+// Chunks returns the merged chunks, in file order, that may hold records
+// overlapping the 1-based closed interval [start, end] of chrom.
+func (idx *Index) Chunks(chrom string, start, end int64) []Chunk {
+	name, ok := idx.SeqName(chrom)
+	if !ok {
+		return nil
+	}
+	ref := &idx.seqs[idx.refs[name]]
+	beg := max(start-1, 0)
+	maxPos := int64(1) << (idx.minShift + idx.depth*3)
+	if end <= 0 || end > maxPos {
+		end = maxPos
+	}
+	if beg >= end {
+		return nil
+	}
+
+	// Records overlapping beg or later all start at or after minOff.
+	var minOff uint64
+	if idx.csi {
+		// Deepest existing bin containing beg, as htslib does.
+		for l := idx.depth; l >= 0; l-- {
+			first := ((1 << (l * 3)) - 1) / 7
+			bin := uint32(first + int(beg>>(idx.minShift+(idx.depth-l)*3)))
+			if off, ok := ref.loffset[bin]; ok {
+				minOff = off
+				break
+			}
+		}
+	} else if len(ref.linear) > 0 {
+		w := int(beg >> idx.minShift)
+		minOff = ref.linear[min(w, len(ref.linear)-1)]
+	}
+
+	var chunks []Chunk
+	for _, bin := range reg2bins(beg, end, idx.minShift, idx.depth) {
+		for _, c := range ref.bins[bin] {
+			if c.End > minOff {
+				chunks = append(chunks, c)
+			}
+		}
+	}
+	sort.Slice(chunks, func(i, j int) bool { return chunks[i].Begin < chunks[j].Begin })
+	merged := chunks[:0]
+	for _, c := range chunks {
+		if n := len(merged); n > 0 && c.Begin <= merged[n-1].End {
+			merged[n-1].End = max(merged[n-1].End, c.End)
+			continue
+		}
+		merged = append(merged, c)
+	}
+	return merged
+}
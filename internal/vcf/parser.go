@@ -18,7 +18,9 @@ type Parser struct {
 	gzipReader  *gzip.Reader
 	lineNumber  int
 	header      []string
-	sampleNames []string // sample names from #CHROM header line
+	sampleNames []string     // sample names from #CHROM header line
+	bgzf        *bgzfReader  // set for indexed region queries
+	query       *regionQuery // set by NewRegionParser
 }
 
 // NewParser creates a new VCF parser for the given file.
@@ -129,6 +131,9 @@ func (p *Parser) parseHeader() error {
 // Next reads the next variant from the VCF file.
 // Returns nil, nil when there are no more variants.
 func (p *Parser) Next() (*Variant, error) {
+	if p.query != nil {
+		return p.nextInRegions()
+	}
 	line, err := p.reader.ReadString('\n')
 	if err != nil {
 		if err == io.EOF {
@@ -207,6 +212,7 @@ func (p *Parser) parseLine(line string) (*Variant, error) {
 		Ref:           ref,
 		Alt:           alt,
 		Qual:          qual,
+		RawQual:       qualStr,
 		Filter:        filter,
 		RawInfo:       rawInfo,
 		SampleColumns: sampleCols,
@@ -251,10 +257,12 @@ func SplitMultiAllelic(v *Variant) []*Variant {
 			Ref:           v.Ref,
 			Alt:           alt,
 			Qual:          v.Qual,
+			RawQual:       v.RawQual,
 			Filter:        v.Filter,
 			Info:          v.Info,
 			RawInfo:       v.RawInfo,
 			SampleColumns: v.SampleColumns,
+			AltIndex:      i + 1,
 		}
 	}
 
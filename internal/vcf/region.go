@@ -0,0 +1,225 @@
+package vcf
+
+import (
+	"bufio"
+	"cmp"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Region is a 1-based closed genomic interval. End 0 means the end of the
+// chromosome.
+type Region struct {
+	Chrom string
+	Start int64
+	End   int64
+}
+
+// String formats the region as chrom:start-end.
+func (r Region) String() string {
+	if r.End == 0 {
+		return fmt.Sprintf("%s:%d-", r.Chrom, r.Start)
+	}
+	return fmt.Sprintf("%s:%d-%d", r.Chrom, r.Start, r.End)
+}
+
+// ParseRegion parses a samtools-style region: "12", "12:25245351" or
+// "12:25200000-25300000" (commas in numbers are ignored).
+func ParseRegion(s string) (Region, error) {
+	chrom, span, hasSpan := strings.Cut(strings.TrimSpace(s), ":")
+	if chrom == "" {
+		return Region{}, fmt.Errorf("invalid region %q", s)
+	}
+	r := Region{Chrom: chrom, Start: 1}
+	if !hasSpan {
+		return r, nil
+	}
+	span = strings.ReplaceAll(span, ",", "")
+	startStr, endStr, hasEnd := strings.Cut(span, "-")
+	start, err := strconv.ParseInt(startStr, 10, 64)
+	if err != nil || start < 1 {
+		return Region{}, fmt.Errorf("invalid region %q: bad start", s)
+	}
+	r.Start, r.End = start, start
+	if hasEnd {
+		r.End = 0
+		if endStr != "" {
+			end, err := strconv.ParseInt(endStr, 10, 64)
+			if err != nil || end < start {
+				return Region{}, fmt.Errorf("invalid region %q: bad end", s)
+			}
+			r.End = end
+		}
+	}
+	return r, nil
+}
+
+// ReadBEDRegions reads regions from a BED file (0-based, half-open), skipping
+// comment, track and browser lines.
+func ReadBEDRegions(path string) ([]Region, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("open regions file: %w", err)
+	}
+	defer f.Close()
+
+	var regions []Region
+	scanner := bufio.NewScanner(f)
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || line[0] == '#' || strings.HasPrefix(line, "track") || strings.HasPrefix(line, "browser") {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) < 3 {
+			return nil, fmt.Errorf("%s line %d: expected chrom, start and end", path, lineNum)
+		}
+		start, err1 := strconv.ParseInt(fields[1], 10, 64)
+		end, err2 := strconv.ParseInt(fields[2], 10, 64)
+		if err1 != nil || err2 != nil || start < 0 || end <= start {
+			return nil, fmt.Errorf("%s line %d: invalid interval %s-%s", path, lineNum, fields[1], fields[2])
+		}
+		regions = append(regions, Region{Chrom: fields[0], Start: start + 1, End: end})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("read regions file: %w", err)
+	}
+	return regions, nil
+}
+
+// regionQuery iterates the records of a BGZF-compressed VCF overlapping a set
+// of regions, using its index.
+type regionQuery struct {
+	idx        *Index
+	regions    []Region // sorted by index order and start, merged, chrom as indexed
+	unresolved []string // region chromosomes not in the index
+	ri         int      // current region, -1 before the first
+	chunks     []Chunk  // chunks of the current region
+	ci         int      // next chunk
+	inChunk    bool
+	chunkEnd   uint64
+	emitted    bool
+	last       uint64 // virtual offset of the last record returned
+}
+
+// NewRegionParser opens a BGZF-compressed VCF with a .tbi or .csi index and
+// returns a parser whose Next yields only records overlapping regions, seeking
+// directly to the indexed blocks. Each record is returned once, in file order,
+// even if several regions overlap it. Regions on sequences that are not in the
+// index are ignored; UnresolvedRegionChroms lists them.
+func NewRegionParser(path string, regions []Region) (*Parser, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("open vcf file: %w", err)
+	}
+	idx, err := LoadIndex(path)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	p := &Parser{file: file, bgzf: newBGZFReader(file)}
+	p.reader = bufio.NewReader(p.bgzf)
+	if err := p.parseHeader(); err != nil {
+		p.Close()
+		return nil, err
+	}
+	resolved, unresolved := normalizeRegions(idx, regions)
+	p.query = &regionQuery{idx: idx, regions: resolved, unresolved: unresolved, ri: -1}
+	return p, nil
+}
+
+// UnresolvedRegionChroms returns the chromosomes of the query regions that
+// are not in the index, in the order first given. Such regions match no
+// records. Returns nil for a parser not made by NewRegionParser.
+func (p *Parser) UnresolvedRegionChroms() []string {
+	if p.query == nil {
+		return nil
+	}
+	return p.query.unresolved
+}
+
+// normalizeRegions resolves region chromosomes against the index, sorts them
+// in file order and merges overlaps. Regions on chromosomes not in the index
+// are dropped and their chromosomes returned as unresolved.
+func normalizeRegions(idx *Index, regions []Region) (out []Region, unresolved []string) {
+	for _, r := range regions {
+		if name, ok := idx.SeqName(r.Chrom); ok {
+			r.Chrom = name
+			out = append(out, r)
+		} else if !slices.Contains(unresolved, r.Chrom) {
+			unresolved = append(unresolved, r.Chrom)
+		}
+	}
+	slices.SortFunc(out, func(a, b Region) int {
+		if c := idx.refs[a.Chrom] - idx.refs[b.Chrom]; c != 0 {
+			return c
+		}
+		return cmp.Compare(a.Start, b.Start)
+	})
+	merged := out[:0]
+	for _, r := range out {
+		if n := len(merged); n > 0 {
+			prev := &merged[n-1]
+			if prev.Chrom == r.Chrom && (prev.End == 0 || r.Start <= prev.End+1) {
+				if prev.End != 0 && (r.End == 0 || r.End > prev.End) {
+					prev.End = r.End
+				}
+				continue
+			}
+		}
+		merged = append(merged, r)
+	}
+	return merged, unresolved
+}
+
+// nextInRegions returns the next record overlapping the query regions.
+func (p *Parser) nextInRegions() (*Variant, error) {
+	q := p.query
+	for {
+		if q.inChunk {
+			line, off, err := p.bgzf.ReadLine()
+			if err == io.EOF || (err == nil && off >= q.chunkEnd) {
+				q.inChunk = false
+				continue
+			}
+			if err != nil {
+				return nil, fmt.Errorf("read variant line: %w", err)
+			}
+			p.lineNumber++
+			if line == "" || line[0] == '#' || (q.emitted && off <= q.last) {
+				continue
+			}
+			v, err := p.parseLine(line)
+			if err != nil {
+				return nil, err
+			}
+			r := q.regions[q.ri]
+			if v.Chrom != r.Chrom || v.End() < r.Start || (r.End > 0 && v.Pos > r.End) {
+				continue
+			}
+			q.emitted, q.last = true, off
+			return v, nil
+		}
+		if q.ci < len(q.chunks) {
+			c := q.chunks[q.ci]
+			q.ci++
+			if err := p.bgzf.Seek(c.Begin); err != nil {
+				return nil, fmt.Errorf("seek vcf file: %w", err)
+			}
+			q.inChunk, q.chunkEnd = true, c.End
+			continue
+		}
+		if q.ri+1 >= len(q.regions) {
+			return nil, nil
+		}
+		q.ri++
+		r := q.regions[q.ri]
+		q.chunks, q.ci = q.idx.Chunks(r.Chrom, r.Start, r.End), 0
+	}
+}
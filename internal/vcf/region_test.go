@@ -0,0 +1,321 @@
+package vcf
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// bgzfCompress compresses data into BGZF blocks of blockSize uncompressed
+// bytes plus the EOF block, and returns the file and each block's offset.
+func bgzfCompress(t *testing.T, data []byte, blockSize int) ([]byte, []int64) {
+	t.Helper()
+	var out bytes.Buffer
+	var offsets []int64
+	writeBlock := func(chunk []byte) {
+		offsets = append(offsets, int64(out.Len()))
+		var cdata bytes.Buffer
+		fw, err := flate.NewWriter(&cdata, flate.DefaultCompression)
+		require.NoError(t, err)
+		_, err = fw.Write(chunk)
+		require.NoError(t, err)
+		require.NoError(t, fw.Close())
+
+		hdr := []byte{0x1f, 0x8b, 8, 4, 0, 0, 0, 0, 0, 0xff, 6, 0, 'B', 'C', 2, 0}
+		hdr = binary.LittleEndian.AppendUint16(hdr, uint16(len(hdr)+2+cdata.Len()+8-1))
+		out.Write(hdr)
+		out.Write(cdata.Bytes())
+		out.Write(binary.LittleEndian.AppendUint32(nil, crc32.ChecksumIEEE(chunk)))
+		out.Write(binary.LittleEndian.AppendUint32(nil, uint32(len(chunk))))
+	}
+	for off := 0; off < len(data); off += blockSize {
+		writeBlock(data[off:min(off+blockSize, len(data))])
+	}
+	writeBlock(nil) // EOF marker
+	return out.Bytes(), offsets
+}
+
+// reg2bin returns the smallest bin containing [beg, end).
+func reg2bin(beg, end int64, minShift, depth int) uint32 {
+	end--
+	s, t := minShift, ((1<<(depth*3))-1)/7
+	for l := depth; l > 0; l-- {
+		if beg>>s == end>>s {
+			return uint32(t + int(beg>>s))
+		}
+		s += 3
+		t -= 1 << ((l - 1) * 3)
+	}
+	return 0
+}
+
+// binFirstWindow returns the first smallest-level window covered by bin.
+func binFirstWindow(bin uint32, depth int) int {
+	for l := depth; l >= 0; l-- {
+		first := ((1 << (l * 3)) - 1) / 7
+		if int(bin) >= first {
+			return (int(bin) - first) << ((depth - l) * 3)
+		}
+	}
+	return 0
+}
+
+// writeIndexedVCF writes header and records as a BGZF VCF with a .tbi (or
+// .csi) index and returns its path.
+func writeIndexedVCF(t *testing.T, header string, records []string, blockSize int, csi bool) string {
+	t.Helper()
+	data := header
+	starts := make([]int, len(records))
+	for i, rec := range records {
+		starts[i] = len(data)
+		data += rec + "\n"
+	}
+	gz, offsets := bgzfCompress(t, []byte(data), blockSize)
+	voff := func(u int) uint64 {
+		return uint64(offsets[u/blockSize])<<16 | uint64(u%blockSize)
+	}
+
+	type seqIdx struct {
+		bins   map[uint32][]Chunk
+		linear []uint64
+	}
+	var names []string
+	seqs := map[string]*seqIdx{}
+	for i, rec := range records {
+		f := strings.Split(rec, "\t")
+		s := seqs[f[0]]
+		if s == nil {
+			s = &seqIdx{bins: map[uint32][]Chunk{}}
+			seqs[f[0]] = s
+			names = append(names, f[0])
+		}
+		var pos int64
+		fmt.Sscan(f[1], &pos)
+		beg, end := pos-1, pos-1+int64(len(f[3]))
+		c := Chunk{voff(starts[i]), voff(starts[i] + len(rec) + 1)}
+		bin := reg2bin(beg, end, 14, 5)
+		s.bins[bin] = append(s.bins[bin], c)
+		for w := beg >> 14; w <= (end-1)>>14; w++ {
+			for int64(len(s.linear)) <= w {
+				s.linear = append(s.linear, 0)
+			}
+			if s.linear[w] == 0 {
+				s.linear[w] = c.Begin
+			}
+		}
+	}
+
+	le := binary.LittleEndian
+	var meta []byte
+	for _, v := range []uint32{2, 1, 2, 0, '#', 0} {
+		meta = le.AppendUint32(meta, v)
+	}
+	nm := strings.Join(names, "\x00") + "\x00"
+	meta = le.AppendUint32(meta, uint32(len(nm)))
+	meta = append(meta, nm...)
+
+	var idx []byte
+	if csi {
+		idx = append([]byte("CSI\x01"), le.AppendUint32(le.AppendUint32(nil, 14), 5)...)
+		idx = le.AppendUint32(idx, uint32(len(meta)))
+		idx = append(idx, meta...)
+		idx = le.AppendUint32(idx, uint32(len(names)))
+	} else {
+		idx = le.AppendUint32([]byte("TBI\x01"), uint32(len(names)))
+		idx = append(idx, meta...)
+	}
+	for _, name := range names {
+		s := seqs[name]
+		// Empty windows take the next window's offset.
+		for w := len(s.linear) - 2; w >= 0; w-- {
+			if s.linear[w] == 0 {
+				s.linear[w] = s.linear[w+1]
+			}
+		}
+		idx = le.AppendUint32(idx, uint32(len(s.bins)))
+		for bin, chunks := range s.bins {
+			idx = le.AppendUint32(idx, bin)
+			if csi {
+				// Like htslib, a CSI bin's loffset is the linear index
+				// offset of its first 16 kb window.
+				idx = le.AppendUint64(idx, s.linear[min(binFirstWindow(bin, 5), len(s.linear)-1)])
+			}
+			idx = le.AppendUint32(idx, uint32(len(chunks)))
+			for _, c := range chunks {
+				idx = le.AppendUint64(le.AppendUint64(idx, c.Begin), c.End)
+			}
+		}
+		if !csi {
+			idx = le.AppendUint32(idx, uint32(len(s.linear)))
+			for _, off := range s.linear {
+				idx = le.AppendUint64(idx, off)
+			}
+		}
+	}
+
+	path := filepath.Join(t.TempDir(), "test.vcf.gz")
+	require.NoError(t, os.WriteFile(path, gz, 0o644))
+	var zidx bytes.Buffer
+	zw := gzip.NewWriter(&zidx)
+	_, err := zw.Write(idx)
+	require.NoError(t, err)
+	require.NoError(t, zw.Close())
+	ext := ".tbi"
+	if csi {
+		ext = ".csi"
+	}
+	require.NoError(t, os.WriteFile(path+ext, zidx.Bytes(), 0o644))
+	return path
+}
+
+const regionTestHeader = "##fileformat=VCFv4.2\n#CHROM\tPOS\tID\tREF\tALT\tQUAL\tFILTER\tINFO\n"
+
+// regionTestRecords returns records every 997 bases on chromosomes 1-3, plus
+// a 3 kb deletion on chromosome 2.
+func regionTestRecords() []string {
+	var records []string
+	for _, chrom := range []string{"1", "2", "3"} {
+		for pos := 1000; pos < 100000; pos += 997 {
+			ref := "A"
+			if chrom == "2" && pos == 48856 {
+				ref = "A" + strings.Repeat("C", 3000)
+			}
+			records = append(records, fmt.Sprintf("%s\t%d\t%s_%d\t%s\tG\t.\tPASS\t.", chrom, pos, chrom, pos, ref))
+		}
+	}
+	return records
+}
+
+func queryIDs(t *testing.T, path string, regions ...Region) []string {
+	t.Helper()
+	p, err := NewRegionParser(path, regions)
+	require.NoError(t, err)
+	defer p.Close()
+	var ids []string
+	for {
+		v, err := p.Next()
+		require.NoError(t, err)
+		if v == nil {
+			return ids
+		}
+		ids = append(ids, v.ID)
+	}
+}
+
+func TestRegionParser(t *testing.T) {
+	for _, csi := range []bool{false, true} {
+		t.Run(fmt.Sprintf("csi=%v", csi), func(t *testing.T) {
+			path := writeIndexedVCF(t, regionTestHeader, regionTestRecords(), 300, csi)
+
+			assert.Equal(t, []string{"1_50850", "1_51847"}, queryIDs(t, path, Region{Chrom: "1", Start: 50000, End: 52000}))
+			assert.Equal(t, []string{"3_99703"}, queryIDs(t, path, Region{Chrom: "chr3", Start: 99000}), "chr prefix, open end")
+			assert.Empty(t, queryIDs(t, path, Region{Chrom: "1", Start: 50851, End: 51846}))
+			assert.Empty(t, queryIDs(t, path, Region{Chrom: "X", Start: 1, End: 1000}))
+
+			// The deletion at 2:48856 spans 48856-51856.
+			assert.Equal(t, []string{"2_48856", "2_49853", "2_50850"}, queryIDs(t, path, Region{Chrom: "2", Start: 49500, End: 51000}))
+
+			// Overlapping and out-of-order regions return each record once, in file order.
+			assert.Equal(t, []string{"1_2994", "1_3991", "3_1000"}, queryIDs(t, path,
+				Region{Chrom: "3", Start: 1, End: 1000},
+				Region{Chrom: "1", Start: 3000, End: 4000},
+				Region{Chrom: "1", Start: 2994, End: 3500}))
+
+			all := queryIDs(t, path, Region{Chrom: "2", Start: 1})
+			assert.Len(t, all, 100)
+		})
+	}
+}
+
+func TestRegionParser_Header(t *testing.T) {
+	path := writeIndexedVCF(t, regionTestHeader, regionTestRecords(), 64, false)
+	p, err := NewRegionParser(path, nil)
+	require.NoError(t, err)
+	defer p.Close()
+	assert.Equal(t, strings.Split(strings.TrimSpace(regionTestHeader), "\n"), p.Header())
+	v, err := p.Next()
+	require.NoError(t, err)
+	assert.Nil(t, v, "no regions")
+}
+
+func TestRegionParser_UnresolvedChroms(t *testing.T) {
+	path := writeIndexedVCF(t, regionTestHeader, regionTestRecords(), 64, false)
+	p, err := NewRegionParser(path, []Region{
+		{Chrom: "X", Start: 1, End: 1000},
+		{Chrom: "chr1", Start: 1000, End: 1000},
+		{Chrom: "GL000192.1", Start: 1},
+		{Chrom: "X", Start: 5000},
+	})
+	require.NoError(t, err)
+	defer p.Close()
+	assert.Equal(t, []string{"X", "GL000192.1"}, p.UnresolvedRegionChroms())
+
+	plain, err := NewParser(path)
+	require.NoError(t, err)
+	defer plain.Close()
+	assert.Nil(t, plain.UnresolvedRegionChroms())
+}
+
+func TestRegionParser_Errors(t *testing.T) {
+	dir := t.TempDir()
+	plain := filepath.Join(dir, "plain.vcf.gz")
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	zw.Write([]byte(regionTestHeader))
+	zw.Close()
+	require.NoError(t, os.WriteFile(plain, buf.Bytes(), 0o644))
+
+	_, err := NewRegionParser(plain, nil)
+	assert.ErrorContains(t, err, "no .tbi or .csi index")
+
+	require.NoError(t, os.WriteFile(plain+".tbi", buf.Bytes(), 0o644))
+	_, err = NewRegionParser(plain, nil)
+	assert.ErrorContains(t, err, "not a tabix or CSI index")
+}
+
+func TestParseRegion(t *testing.T) {
+	tests := []struct {
+		in   string
+		want Region
+	}{
+		{"12", Region{Chrom: "12", Start: 1}},
+		{"12:25245351", Region{Chrom: "12", Start: 25245351, End: 25245351}},
+		{"chr12:25,200,000-25,300,000", Region{Chrom: "chr12", Start: 25200000, End: 25300000}},
+		{"X:100-", Region{Chrom: "X", Start: 100}},
+	}
+	for _, tt := range tests {
+		got, err := ParseRegion(tt.in)
+		require.NoError(t, err, tt.in)
+		assert.Equal(t, tt.want, got, tt.in)
+	}
+	for _, bad := range []string{"", ":1-2", "1:0", "1:200-100", "1:a-b"} {
+		_, err := ParseRegion(bad)
+		assert.Error(t, err, bad)
+	}
+}
+
+func TestReadBEDRegions(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "regions.bed")
+	require.NoError(t, os.WriteFile(path, []byte("track name=x\n# comment\n12\t25200000\t25300000\tKRAS\nchr7\t0\t10\n"), 0o644))
+
+	regions, err := ReadBEDRegions(path)
+	require.NoError(t, err)
+	assert.Equal(t, []Region{
+		{Chrom: "12", Start: 25200001, End: 25300000},
+		{Chrom: "chr7", Start: 1, End: 10},
+	}, regions)
+
+	require.NoError(t, os.WriteFile(path, []byte("12\t100\n"), 0o644))
+	_, err = ReadBEDRegions(path)
+	assert.Error(t, err)
+}
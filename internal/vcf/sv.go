@@ -0,0 +1,161 @@
+package vcf
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Structural variant types (VCF SVTYPE values).
+const (
+	SVTypeDEL = "DEL"
+	SVTypeDUP = "DUP"
+	SVTypeINS = "INS"
+	SVTypeINV = "INV"
+	SVTypeCNV = "CNV"
+	SVTypeBND = "BND"
+)
+
+// IsSymbolic returns true if the ALT allele is a symbolic allele such as <DEL> or <DUP:TANDEM>.
+func (v *Variant) IsSymbolic() bool {
+	return len(v.Alt) > 2 && v.Alt[0] == '<' && v.Alt[len(v.Alt)-1] == '>'
+}
+
+// IsBreakend returns true if the ALT allele uses breakend notation,
+// either a mated breakend (e.g. G]17:198982], [13:123456[T) or a single
+// breakend (e.g. G., .G).
+func (v *Variant) IsBreakend() bool {
+	if strings.ContainsAny(v.Alt, "[]") {
+		return true
+	}
+	return len(v.Alt) > 1 && (v.Alt[0] == '.' || v.Alt[len(v.Alt)-1] == '.')
+}
+
+// IsStructural returns true if the variant is a structural variant: a symbolic
+// allele, a breakend, or a record carrying an SVTYPE INFO field.
+func (v *Variant) IsStructural() bool {
+	if v.IsSymbolic() || v.IsBreakend() {
+		return true
+	}
+	_, ok := v.InfoValue("SVTYPE")
+	return ok
+}
+
+// InfoValue returns the value of an INFO key from the raw INFO string.
+// Flag fields return "" with ok=true. Scans RawInfo without building a map.
+func (v *Variant) InfoValue(key string) (string, bool) {
+	for rest := v.RawInfo; rest != ""; {
+		field := rest
+		if i := strings.IndexByte(rest, ';'); i >= 0 {
+			field = rest[:i]
+			rest = rest[i+1:]
+		} else {
+			rest = ""
+		}
+		if !strings.HasPrefix(field, key) {
+			continue
+		}
+		if len(field) == len(key) {
+			return "", true
+		}
+		if field[len(key)] == '=' {
+			return field[len(key)+1:], true
+		}
+	}
+	return "", false
+}
+
+// SVType returns the structural variant type. The INFO SVTYPE field takes
+// precedence; otherwise the type is derived from the symbolic ALT allele
+// (e.g. <DEL:ME:ALU> → DEL) or breakend notation (BND). Returns "" for
+// sequence-resolved variants.
+func (v *Variant) SVType() string {
+	if t, ok := v.InfoValue("SVTYPE"); ok && t != "" {
+		return strings.ToUpper(t)
+	}
+	if v.IsSymbolic() {
+		t := v.Alt[1 : len(v.Alt)-1]
+		if i := strings.IndexByte(t, ':'); i >= 0 {
+			t = t[:i]
+		}
+		return strings.ToUpper(t)
+	}
+	if v.IsBreakend() {
+		return SVTypeBND
+	}
+	return ""
+}
+
+// SVLen returns the absolute value of the INFO SVLEN field, or 0 if absent.
+// For multi-valued SVLEN only the first value is used.
+func (v *Variant) SVLen() int64 {
+	s, ok := v.InfoValue("SVLEN")
+	if !ok {
+		return 0
+	}
+	if i := strings.IndexByte(s, ','); i >= 0 {
+		s = s[:i]
+	}
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return 0
+	}
+	if n < 0 {
+		n = -n
+	}
+	return n
+}
+
+// End returns the last reference base affected by the variant (1-based, inclusive).
+// For structural variants this is INFO END, falling back to POS+SVLEN;
+// insertions and breakends end at POS. For sequence-resolved variants it is
+// POS+len(REF)-1.
+func (v *Variant) End() int64 {
+	if v.IsStructural() {
+		if s, ok := v.InfoValue("END"); ok {
+			if end, err := strconv.ParseInt(s, 10, 64); err == nil && end >= v.Pos {
+				return end
+			}
+		}
+		switch v.SVType() {
+		case SVTypeINS, SVTypeBND:
+			return v.Pos
+		}
+		if n := v.SVLen(); n > 0 {
+			return v.Pos + n
+		}
+		return v.Pos
+	}
+	if len(v.Ref) == 0 {
+		return v.Pos
+	}
+	return v.Pos + int64(len(v.Ref)) - 1
+}
+
+// MateBreakend parses the partner location from a mated breakend ALT allele
+// (e.g. "G]17:198982]" → "17", 198982). Returns ok=false for non-breakend
+// alleles and single breakends.
+func (v *Variant) MateBreakend() (chrom string, pos int64, ok bool) {
+	open := strings.IndexAny(v.Alt, "[]")
+	if open < 0 {
+		return "", 0, false
+	}
+	bracket := v.Alt[open]
+	closing := strings.IndexByte(v.Alt[open+1:], bracket)
+	if closing < 0 {
+		return "", 0, false
+	}
+	loc := v.Alt[open+1 : open+1+closing]
+	colon := strings.LastIndexByte(loc, ':')
+	if colon <= 0 {
+		return "", 0, false
+	}
+	pos, err := strconv.ParseInt(loc[colon+1:], 10, 64)
+	if err != nil {
+		return "", 0, false
+	}
+	chrom = loc[:colon]
+	if len(chrom) > 3 && chrom[:3] == "chr" {
+		chrom = chrom[3:]
+	}
+	return chrom, pos, true
+}
@@ -1,6 +1,8 @@
 // Package vcf provides VCF file parsing functionality.
 package vcf
 
+import "strconv"
+
 // Variant represents a single genomic variant from a VCF file.
 type Variant struct {
 	Chrom         string                 // Chromosome name (e.g., "12", "chr12")
@@ -8,11 +10,34 @@ type Variant struct {
 	ID            string                 // Variant identifier (e.g., rs ID)
 	Ref           string                 // Reference allele
 	Alt           string                 // Alternate allele (single allele after splitting)
-	Qual          float64                // Quality score
+	Qual          float64                // Quality score, 0 if missing
+	RawQual       string                 // Raw QUAL field string ("." if missing), empty for non-VCF input
 	Filter        string                 // Filter status (PASS or filter name)
 	Info          map[string]interface{} // INFO field key-value pairs (lazily parsed from RawInfo)
 	RawInfo       string                 // Raw INFO field string (used for passthrough)
-	SampleColumns string                 // Raw tab-joined FORMAT + sample columns (preserved, not parsed)
+	SampleColumns string                 // Raw tab-joined FORMAT + sample columns (preserved, parsed on demand by Genotypes)
+	AltIndex      int                    // 1-based index of Alt in the record's ALT list after splitting, 0 if not split
+	Original      *OriginalAllele        // Input position/alleles before normalization, nil if unchanged
+}
+
+// OriginalAllele records a variant's position and alleles as they appeared in
+// the input, before left-alignment rewrote them.
+type OriginalAllele struct {
+	Pos int64
+	Ref string
+	Alt string
+}
+
+// FormatQual returns the QUAL field to write for v: the input field of a VCF
+// record, else Qual with 0 written as missing.
+func (v *Variant) FormatQual() string {
+	if v.RawQual != "" {
+		return v.RawQual
+	}
+	if v.Qual == 0 {
+		return "."
+	}
+	return strconv.FormatFloat(v.Qual, 'g', -1, 64)
 }
 
 // IsSNV returns true if the variant is a single nucleotide variant.
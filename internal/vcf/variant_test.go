@@ -132,3 +132,95 @@ func TestVariant_KRASG12C(t *testing.T) {
 	assert.False(t, v.IsIndel(), "KRAS G12C should not be classified as indel")
 	assert.Equal(t, "12", v.NormalizeChrom())
 }
+
+func TestVariant_StructuralAlleles(t *testing.T) {
+	tests := []struct {
+		name       string
+		alt        string
+		info       string
+		structural bool
+		svType     string
+	}{
+		{"SNV", "T", "DP=10", false, ""},
+		{"symbolic deletion", "<DEL>", "SVTYPE=DEL;END=2000", true, SVTypeDEL},
+		{"symbolic subtype", "<DEL:ME:ALU>", "END=2000", true, SVTypeDEL},
+		{"tandem duplication", "<DUP:TANDEM>", ".", true, SVTypeDUP},
+		{"mated breakend", "G]17:198982]", ".", true, SVTypeBND},
+		{"reverse breakend", "[13:123456[T", ".", true, SVTypeBND},
+		{"single breakend", "G.", ".", true, SVTypeBND},
+		{"SVTYPE on sequence allele", "ACGTACGT", "SVTYPE=INS;SVLEN=7", true, SVTypeINS},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := &Variant{Pos: 1000, Ref: "A", Alt: tt.alt, RawInfo: tt.info}
+			assert.Equal(t, tt.structural, v.IsStructural())
+			assert.Equal(t, tt.svType, v.SVType())
+		})
+	}
+}
+
+func TestVariant_End(t *testing.T) {
+	tests := []struct {
+		name string
+		ref  string
+		alt  string
+		info string
+		want int64
+	}{
+		{"SNV", "A", "T", ".", 1000},
+		{"deletion", "ACGT", "A", ".", 1003},
+		{"INFO END", "N", "<DEL>", "SVTYPE=DEL;END=5000", 5000},
+		{"SVLEN fallback", "N", "<DUP>", "SVLEN=250", 1250},
+		{"negative SVLEN", "N", "<DEL>", "SVLEN=-300", 1300},
+		{"insertion is a point", "N", "<INS>", "SVLEN=300", 1000},
+		{"breakend is a point", "G", "G]17:198982]", ".", 1000},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := &Variant{Pos: 1000, Ref: tt.ref, Alt: tt.alt, RawInfo: tt.info}
+			assert.Equal(t, tt.want, v.End())
+		})
+	}
+}
+
+func TestVariant_InfoValue(t *testing.T) {
+	v := &Variant{RawInfo: "SVTYPEX=1;SVTYPE=DEL;IMPRECISE;END=500"}
+
+	val, ok := v.InfoValue("SVTYPE")
+	assert.True(t, ok)
+	assert.Equal(t, "DEL", val)
+
+	val, ok = v.InfoValue("IMPRECISE")
+	assert.True(t, ok, "flag field")
+	assert.Equal(t, "", val)
+
+	_, ok = v.InfoValue("SVLEN")
+	assert.False(t, ok)
+}
+
+func TestVariant_MateBreakend(t *testing.T) {
+	tests := []struct {
+		alt   string
+		chrom string
+		pos   int64
+		ok    bool
+	}{
+		{"G]17:198982]", "17", 198982, true},
+		{"]chr13:123456]T", "13", 123456, true},
+		{"[HLA-A*01:01:01:01:100[A", "HLA-A*01:01:01:01", 100, true},
+		{"G.", "", 0, false},
+		{"<DEL>", "", 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.alt, func(t *testing.T) {
+			v := &Variant{Alt: tt.alt}
+			chrom, pos, ok := v.MateBreakend()
+			assert.Equal(t, tt.ok, ok)
+			assert.Equal(t, tt.chrom, chrom)
+			assert.Equal(t, tt.pos, pos)
+		})
+	}
+}